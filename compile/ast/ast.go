@@ -111,6 +111,12 @@ type (
 		Type CommentType
 		Text string
 	}
+
+	MatchArm struct {
+		baseNode
+		Conds []Expr // @var Expr[]|null List of conditions, null for default arm
+		Body  Expr
+	}
 )
 
 // TypeHint 类型标识
@@ -370,6 +376,12 @@ type (
 		Expr Expr
 	}
 
+	MatchExpr struct {
+		baseExpr
+		Cond Expr
+		Arms []*MatchArm
+	}
+
 	VariableExpr struct {
 		baseExpr
 		Name Node // @var Ident|Expr Name
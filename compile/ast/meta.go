@@ -4,6 +4,8 @@ package ast
 type NodeMeta struct {
 	RawValue string
 	Comments []*Comment
+	Start    Position // 节点起始位置
+	End      Position // 节点结束位置(不含)
 	Others   map[string]any
 }
 
@@ -26,3 +28,17 @@ func MetaComments(n Node) []*Comment {
 	}
 	return nil
 }
+
+func MetaStart(n Node) Position {
+	if meta := n.Meta(); meta != nil {
+		return meta.Start
+	}
+	return Position{}
+}
+
+func MetaEnd(n Node) Position {
+	if meta := n.Meta(); meta != nil {
+		return meta.End
+	}
+	return Position{}
+}
@@ -0,0 +1,19 @@
+package ast
+
+import "fmt"
+
+// Position 源码位置，Line 及 Column 从 1 开始，零值表示无位置信息
+type Position struct {
+	Offset int // 字节偏移
+	Line   int // 行号
+	Column int // 列号(按字节计算)
+}
+
+func (p Position) IsValid() bool { return p.Line > 0 }
+
+func (p Position) String() string {
+	if !p.IsValid() {
+		return "-"
+	}
+	return fmt.Sprintf("%d:%d", p.Line, p.Column)
+}
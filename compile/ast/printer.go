@@ -173,6 +173,8 @@ func (p *printer) node(node Node) {
 		p.param(x)
 	case *Arg:
 		p.arg(x)
+	case *MatchArm:
+		p.matchArm(x)
 	default:
 		p.unexpect(x)
 	}
@@ -272,6 +274,15 @@ func (p *printer) arg(n *Arg) {
 	p.print(n.Value)
 }
 
+func (p *printer) matchArm(n *MatchArm) {
+	if n.Conds == nil {
+		p.print("default")
+	} else {
+		printList(p, n.Conds, ", ")
+	}
+	p.print(" => ", n.Body)
+}
+
 func (p *printer) param(n *Param) {
 	if n.Type != nil {
 		p.print(n.Type, " ")
@@ -475,6 +486,10 @@ func (p *printer) expr(n Expr) {
 		}
 	case *ThrowExpr:
 		p.print("throw ", x.Expr)
+	case *MatchExpr:
+		p.print("match (", x.Cond, ") {")
+		printList(p, x.Arms, ", ")
+		p.print("}")
 	case *VariableExpr:
 		if nameIdent, ok := x.Name.(*Ident); ok {
 			p.print("$", nameIdent.Name)
@@ -0,0 +1,41 @@
+package ast
+
+import "reflect"
+
+// Inspect 深度优先遍历节点 n 及其子节点，与 go/ast.Inspect 一致：
+// 对每个节点调用 f(node)，f 返回 true 时继续遍历其子节点，所有子节点遍历后调用 f(nil)
+func Inspect(n Node, f func(Node) bool) {
+	if n == nil || reflect.ValueOf(n).IsNil() || !f(n) {
+		return
+	}
+	walkFields(reflect.ValueOf(n).Elem(), f)
+	f(nil)
+}
+
+// walkFields 按字段声明顺序遍历结构体中的子节点及节点列表，忽略未导出的字段(节点元信息)
+func walkFields(v reflect.Value, f func(Node) bool) {
+	if v.Kind() != reflect.Struct {
+		return
+	}
+	for i := 0; i < v.NumField(); i++ {
+		if v.Type().Field(i).IsExported() {
+			walkValue(v.Field(i), f)
+		}
+	}
+}
+
+func walkValue(v reflect.Value, f func(Node) bool) {
+	switch v.Kind() {
+	case reflect.Interface, reflect.Pointer:
+		if v.IsNil() {
+			return
+		}
+		if node, ok := v.Interface().(Node); ok {
+			Inspect(node, f)
+		}
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			walkValue(v.Index(i), f)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package astcache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/heyuuu/gophp/compile/ast"
+	"github.com/heyuuu/gophp/compile/parser"
+	"os"
+	"path/filepath"
+)
+
+// Cache 以源码内容哈希为键的 ast 文件缓存，缓存文件存放于 dir 下
+type Cache struct {
+	dir string
+}
+
+func New(dir string) *Cache {
+	return &Cache{dir: dir}
+}
+
+func (c *Cache) Dir() string { return c.dir }
+
+// Key 缓存键，由解析器版本、编码格式版本及源码内容共同决定
+func Key(code string) string {
+	h := sha256.New()
+	_, _ = fmt.Fprintf(h, "%s\x00%d\x00", parser.Version, formatVersion)
+	h.Write([]byte(code))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func (c *Cache) path(key string) string {
+	return filepath.Join(c.dir, key[:2], key+".ast")
+}
+
+// Load 读取代码对应的缓存，缓存不存在或已损坏时返回 false
+func (c *Cache) Load(code string) (*ast.File, bool) {
+	data, err := os.ReadFile(c.path(Key(code)))
+	if err != nil {
+		return nil, false
+	}
+	file, err := Decode(data)
+	if err != nil {
+		return nil, false
+	}
+	return file, true
+}
+
+// Store 写入缓存。先写入临时文件再重命名，避免并发读取到不完整的数据
+func (c *Cache) Store(code string, file *ast.File) error {
+	data, err := Encode(file)
+	if err != nil {
+		return err
+	}
+
+	path := c.path(Key(code))
+	if err = os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err = tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
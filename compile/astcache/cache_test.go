@@ -0,0 +1,65 @@
+package astcache
+
+import (
+	"github.com/heyuuu/gophp/compile/ast"
+	"github.com/heyuuu/gophp/compile/parser"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestEncodeDecode(t *testing.T) {
+	files, _ := filepath.Glob("../../testdata/compile/gammar/*.php")
+	if len(files) == 0 {
+		t.Fatal("testdata not found")
+	}
+	for _, file := range files {
+		t.Run(filepath.Base(file), func(t *testing.T) {
+			code, err := os.ReadFile(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want, err := parser.ParseCode(string(code))
+			if err != nil {
+				t.Fatal(err)
+			}
+			data, err := Encode(want)
+			if err != nil {
+				t.Fatalf("Encode() error = %v", err)
+			}
+			got, err := Decode(data)
+			if err != nil {
+				t.Fatalf("Decode() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				gotStr, _ := ast.PrintFile(got)
+				wantStr, _ := ast.PrintFile(want)
+				t.Errorf("Decode() = %v, want %v", gotStr, wantStr)
+			}
+		})
+	}
+}
+
+func TestCache(t *testing.T) {
+	cache := New(t.TempDir())
+	code := "<?php\nnamespace A;\necho strlen('abc'), [1, , 2];\n"
+	if _, ok := cache.Load(code); ok {
+		t.Fatal("Load() hit on empty cache")
+	}
+
+	file, err := parser.ParseCode(code)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = cache.Store(code, file); err != nil {
+		t.Fatalf("Store() error = %v", err)
+	}
+	got, ok := cache.Load(code)
+	if !ok || !reflect.DeepEqual(got, file) {
+		t.Errorf("Load() = %v, %v, want stored file", got, ok)
+	}
+	if _, ok = cache.Load(code + "\n"); ok {
+		t.Error("Load() hit for different code")
+	}
+}
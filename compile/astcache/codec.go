@@ -0,0 +1,374 @@
+package astcache
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/heyuuu/gophp/compile/ast"
+	"math"
+	"reflect"
+	"slices"
+)
+
+// 编码格式：按字段顺序依次写入各字段的值，整数使用 varint，接口值前写入类型编号(0 表示 nil)。
+// 节点元数据(NodeMeta)位于未导出的 baseNode 中，在节点的导出字段之后单独写入
+
+// formatVersion 编码格式版本，修改编码格式或 interfaceTypes 时需要更新
+const formatVersion = 2
+
+var magic = []byte("GPAST")
+
+// interfaceTypes 接口字段中可能出现的具体类型，编号为下标 + 1
+var interfaceTypes = []reflect.Type{
+	reflect.TypeFor[string](),
+	reflect.TypeFor[bool](),
+	reflect.TypeFor[int](),
+	reflect.TypeFor[float64](),
+}
+
+var interfaceTypeIndex = map[reflect.Type]int{}
+
+var (
+	nodeType     = reflect.TypeFor[ast.Node]()
+	nodeMetaType = reflect.TypeFor[*ast.NodeMeta]()
+)
+
+func init() {
+	nodes := []ast.Node{
+		(*ast.Arg)(nil),
+		(*ast.Ident)(nil),
+		(*ast.Param)(nil),
+		(*ast.Comment)(nil),
+		(*ast.SimpleType)(nil),
+		(*ast.IntersectionType)(nil),
+		(*ast.UnionType)(nil),
+		(*ast.NullableType)(nil),
+		(*ast.Name)(nil),
+		(*ast.IntLit)(nil),
+		(*ast.FloatLit)(nil),
+		(*ast.StringLit)(nil),
+		(*ast.ArrayExpr)(nil),
+		(*ast.ArrayItemExpr)(nil),
+		(*ast.ClosureExpr)(nil),
+		(*ast.ClosureUseExpr)(nil),
+		(*ast.ArrowFunctionExpr)(nil),
+		(*ast.IndexExpr)(nil),
+		(*ast.CastExpr)(nil),
+		(*ast.UnaryExpr)(nil),
+		(*ast.BinaryOpExpr)(nil),
+		(*ast.AssignExpr)(nil),
+		(*ast.AssignOpExpr)(nil),
+		(*ast.AssignRefExpr)(nil),
+		(*ast.IssetExpr)(nil),
+		(*ast.EmptyExpr)(nil),
+		(*ast.EvalExpr)(nil),
+		(*ast.IncludeExpr)(nil),
+		(*ast.CloneExpr)(nil),
+		(*ast.ErrorSuppressExpr)(nil),
+		(*ast.ExitExpr)(nil),
+		(*ast.ConstFetchExpr)(nil),
+		(*ast.ClassConstFetchExpr)(nil),
+		(*ast.MagicConstExpr)(nil),
+		(*ast.InstanceofExpr)(nil),
+		(*ast.ListExpr)(nil),
+		(*ast.PrintExpr)(nil),
+		(*ast.PropertyFetchExpr)(nil),
+		(*ast.StaticPropertyFetchExpr)(nil),
+		(*ast.ShellExecExpr)(nil),
+		(*ast.TernaryExpr)(nil),
+		(*ast.ThrowExpr)(nil),
+		(*ast.VariableExpr)(nil),
+		(*ast.YieldExpr)(nil),
+		(*ast.YieldFromExpr)(nil),
+		(*ast.FuncCallExpr)(nil),
+		(*ast.NewExpr)(nil),
+		(*ast.MethodCallExpr)(nil),
+		(*ast.StaticCallExpr)(nil),
+		(*ast.EmptyStmt)(nil),
+		(*ast.BlockStmt)(nil),
+		(*ast.ExprStmt)(nil),
+		(*ast.ReturnStmt)(nil),
+		(*ast.LabelStmt)(nil),
+		(*ast.GotoStmt)(nil),
+		(*ast.IfStmt)(nil),
+		(*ast.ElseIfStmt)(nil),
+		(*ast.ElseStmt)(nil),
+		(*ast.SwitchStmt)(nil),
+		(*ast.CaseStmt)(nil),
+		(*ast.ForStmt)(nil),
+		(*ast.ForeachStmt)(nil),
+		(*ast.BreakStmt)(nil),
+		(*ast.ContinueStmt)(nil),
+		(*ast.WhileStmt)(nil),
+		(*ast.DoStmt)(nil),
+		(*ast.TryCatchStmt)(nil),
+		(*ast.CatchStmt)(nil),
+		(*ast.FinallyStmt)(nil),
+		(*ast.ConstStmt)(nil),
+		(*ast.EchoStmt)(nil),
+		(*ast.GlobalStmt)(nil),
+		(*ast.HaltCompilerStmt)(nil),
+		(*ast.InlineHTMLStmt)(nil),
+		(*ast.StaticStmt)(nil),
+		(*ast.UnsetStmt)(nil),
+		(*ast.UseStmt)(nil),
+		(*ast.DeclareStmt)(nil),
+		(*ast.DeclareDeclareStmt)(nil),
+		(*ast.NamespaceStmt)(nil),
+		(*ast.FunctionStmt)(nil),
+		(*ast.InterfaceStmt)(nil),
+		(*ast.ClassStmt)(nil),
+		(*ast.ClassConstStmt)(nil),
+		(*ast.PropertyStmt)(nil),
+		(*ast.ClassMethodStmt)(nil),
+		(*ast.TraitStmt)(nil),
+		(*ast.TraitUseStmt)(nil),
+		(*ast.TraitUseAdaptationAliasStmt)(nil),
+		(*ast.TraitUseAdaptationPrecedenceStmt)(nil),
+		(*ast.MatchExpr)(nil),
+	}
+	for _, n := range nodes {
+		interfaceTypes = append(interfaceTypes, reflect.TypeOf(n))
+	}
+	for i, typ := range interfaceTypes {
+		interfaceTypeIndex[typ] = i + 1
+	}
+}
+
+// Encode 将 ast 编码为二进制数据
+func Encode(file *ast.File) (data []byte, err error) {
+	defer func() {
+		if e := recover(); e != nil {
+			err = fmt.Errorf("astcache: encode failed: %v", e)
+		}
+	}()
+
+	e := &encoder{buf: slices.Clone(magic)}
+	e.uint(formatVersion)
+	e.value(reflect.ValueOf(file).Elem())
+	return e.buf, nil
+}
+
+// Decode 解码 Encode 生成的二进制数据
+func Decode(data []byte) (file *ast.File, err error) {
+	defer func() {
+		if e := recover(); e != nil {
+			err = fmt.Errorf("astcache: decode failed: %v", e)
+		}
+	}()
+
+	if len(data) < len(magic) || string(data[:len(magic)]) != string(magic) {
+		return nil, errors.New("astcache: bad magic")
+	}
+	d := &decoder{buf: data[len(magic):]}
+	if version := d.uint(); version != formatVersion {
+		return nil, fmt.Errorf("astcache: unsupported format version %d", version)
+	}
+	file = &ast.File{}
+	d.value(reflect.ValueOf(file).Elem())
+	if len(d.buf) != 0 {
+		return nil, errors.New("astcache: trailing data")
+	}
+	return file, nil
+}
+
+/* encoder */
+
+type encoder struct {
+	buf []byte
+}
+
+func (e *encoder) uint(v uint64) { e.buf = binary.AppendUvarint(e.buf, v) }
+func (e *encoder) int(v int64)   { e.buf = binary.AppendVarint(e.buf, v) }
+func (e *encoder) string(s string) {
+	e.uint(uint64(len(s)))
+	e.buf = append(e.buf, s...)
+}
+
+func (e *encoder) value(v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Bool:
+		if v.Bool() {
+			e.uint(1)
+		} else {
+			e.uint(0)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		e.int(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		e.uint(v.Uint())
+	case reflect.Float64:
+		e.buf = binary.LittleEndian.AppendUint64(e.buf, math.Float64bits(v.Float()))
+	case reflect.String:
+		e.string(v.String())
+	case reflect.Slice:
+		// 长度 + 1，0 表示 nil
+		if v.IsNil() {
+			e.uint(0)
+			return
+		}
+		e.uint(uint64(v.Len()) + 1)
+		for i := 0; i < v.Len(); i++ {
+			e.value(v.Index(i))
+		}
+	case reflect.Map:
+		keys := v.MapKeys()
+		slices.SortFunc(keys, func(a, b reflect.Value) int {
+			if a.String() < b.String() {
+				return -1
+			}
+			return 1
+		})
+		e.uint(uint64(len(keys)))
+		for _, key := range keys {
+			e.string(key.String())
+			e.value(v.MapIndex(key))
+		}
+	case reflect.Pointer:
+		if v.IsNil() {
+			e.uint(0)
+			return
+		}
+		e.uint(1)
+		e.value(v.Elem())
+		if v.Type().Implements(nodeType) {
+			e.value(reflect.ValueOf(v.Interface().(ast.Node).Meta()))
+		}
+	case reflect.Interface:
+		if v.IsNil() {
+			e.uint(0)
+			return
+		}
+		elem := v.Elem()
+		index, ok := interfaceTypeIndex[elem.Type()]
+		if !ok {
+			panic(fmt.Sprintf("unsupported type %s", elem.Type()))
+		}
+		e.uint(uint64(index))
+		e.value(elem)
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < v.NumField(); i++ {
+			if !t.Field(i).IsExported() {
+				continue
+			}
+			e.value(v.Field(i))
+		}
+	default:
+		panic(fmt.Sprintf("unsupported kind %s", v.Kind()))
+	}
+}
+
+/* decoder */
+
+type decoder struct {
+	buf []byte
+}
+
+func (d *decoder) uint() uint64 {
+	v, n := binary.Uvarint(d.buf)
+	if n <= 0 {
+		panic("bad uvarint")
+	}
+	d.buf = d.buf[n:]
+	return v
+}
+
+func (d *decoder) int() int64 {
+	v, n := binary.Varint(d.buf)
+	if n <= 0 {
+		panic("bad varint")
+	}
+	d.buf = d.buf[n:]
+	return v
+}
+
+func (d *decoder) bytes(n uint64) []byte {
+	if n > uint64(len(d.buf)) {
+		panic("unexpected end of data")
+	}
+	b := d.buf[:n]
+	d.buf = d.buf[n:]
+	return b
+}
+
+func (d *decoder) string() string {
+	return string(d.bytes(d.uint()))
+}
+
+func (d *decoder) value(v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Bool:
+		v.SetBool(d.uint() != 0)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		v.SetInt(d.int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		v.SetUint(d.uint())
+	case reflect.Float64:
+		v.SetFloat(math.Float64frombits(binary.LittleEndian.Uint64(d.bytes(8))))
+	case reflect.String:
+		v.SetString(d.string())
+	case reflect.Slice:
+		n := d.uint()
+		if n == 0 {
+			return
+		}
+		n--
+		if n > uint64(len(d.buf)) {
+			// 每个元素至少占用 1 字节
+			panic("unexpected end of data")
+		}
+		s := reflect.MakeSlice(v.Type(), int(n), int(n))
+		for i := 0; i < int(n); i++ {
+			d.value(s.Index(i))
+		}
+		v.Set(s)
+	case reflect.Map:
+		n := d.uint()
+		m := reflect.MakeMapWithSize(v.Type(), int(min(n, uint64(len(d.buf)))))
+		for i := uint64(0); i < n; i++ {
+			key := d.string()
+			elem := reflect.New(v.Type().Elem()).Elem()
+			d.value(elem)
+			m.SetMapIndex(reflect.ValueOf(key), elem)
+		}
+		v.Set(m)
+	case reflect.Pointer:
+		if d.uint() == 0 {
+			return
+		}
+		ptr := reflect.New(v.Type().Elem())
+		d.value(ptr.Elem())
+		if n, ok := ptr.Interface().(ast.Node); ok {
+			meta := reflect.New(nodeMetaType).Elem()
+			d.value(meta)
+			n.SetMeta(meta.Interface().(*ast.NodeMeta))
+		}
+		v.Set(ptr)
+	case reflect.Interface:
+		index := d.uint()
+		if index == 0 {
+			return
+		}
+		if index > uint64(len(interfaceTypes)) {
+			panic(fmt.Sprintf("bad type index %d", index))
+		}
+		typ := interfaceTypes[index-1]
+		if !typ.AssignableTo(v.Type()) {
+			panic(fmt.Sprintf("type %s is not assignable to %s", typ, v.Type()))
+		}
+		elem := reflect.New(typ).Elem()
+		d.value(elem)
+		v.Set(elem)
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < v.NumField(); i++ {
+			if !t.Field(i).IsExported() {
+				continue
+			}
+			d.value(v.Field(i))
+		}
+	default:
+		panic(fmt.Sprintf("unsupported kind %s", v.Kind()))
+	}
+}
@@ -0,0 +1,106 @@
+package diag
+
+import (
+	"fmt"
+	"github.com/heyuuu/gophp/compile/ast"
+	"strings"
+)
+
+// Severity 诊断级别
+type Severity uint8
+
+const (
+	SeverityError Severity = iota
+	SeverityWarning
+)
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityError:
+		return "error"
+	case SeverityWarning:
+		return "warning"
+	default:
+		return fmt.Sprintf("Severity(%d)", s)
+	}
+}
+
+// Code 诊断编码，便于工具按类型过滤
+type Code string
+
+const (
+	CodeLexError     Code = "lex-error"     // 词法错误
+	CodeSyntaxError  Code = "syntax-error"  // 语法错误
+	CodeUnsupported  Code = "unsupported"   // 暂不支持的语法
+	CodeCompileError Code = "compile-error" // 语法正确但无法编译，如全局代码与命名空间代码混用
+)
+
+// Diagnostic 单条诊断信息
+type Diagnostic struct {
+	Severity Severity
+	Code     Code
+	Message  string
+	Pos      ast.Position
+	End      ast.Position
+}
+
+// Error 返回与 PHP 一致的错误描述，如 `Syntax error, unexpected ';' on line 2`
+func (d *Diagnostic) Error() string {
+	if !d.Pos.IsValid() {
+		return d.Message
+	}
+	return fmt.Sprintf("%s on line %d", d.Message, d.Pos.Line)
+}
+
+// List 诊断列表
+type List []*Diagnostic
+
+func (l *List) Add(severity Severity, code Code, message string, pos, end ast.Position) {
+	*l = append(*l, &Diagnostic{Severity: severity, Code: code, Message: message, Pos: pos, End: end})
+}
+
+// Errorf 添加一条错误级别的诊断
+func (l *List) Errorf(code Code, pos ast.Position, format string, args ...any) {
+	l.Add(SeverityError, code, fmt.Sprintf(format, args...), pos, pos)
+}
+
+func (l List) HasErrors() bool {
+	for _, d := range l {
+		if d.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+// Errors 仅返回错误级别的诊断
+func (l List) Errors() List {
+	var errs List
+	for _, d := range l {
+		if d.Severity == SeverityError {
+			errs = append(errs, d)
+		}
+	}
+	return errs
+}
+
+func (l List) Error() string {
+	switch len(l) {
+	case 0:
+		return "no errors"
+	case 1:
+		return l[0].Error()
+	}
+	var buf strings.Builder
+	buf.WriteString(l[0].Error())
+	fmt.Fprintf(&buf, " (and %d more errors)", len(l)-1)
+	return buf.String()
+}
+
+// Err 存在错误级别的诊断时返回 error，否则返回 nil
+func (l List) Err() error {
+	if errs := l.Errors(); len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
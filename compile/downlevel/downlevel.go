@@ -0,0 +1,100 @@
+// Package downlevel 将 IR 中目标版本不支持的 PHP 语法改写为等价的旧版本语法，再由 ir.PrintFile 输出为 PHP 代码，
+// 使同一份源码可以部署到旧版本 PHP 环境。
+//
+// 支持的目标版本为 PHP 7.0 及以上，按目标版本进行以下改写：
+//   - 7.4 之前：箭头函数改为按值 use 外部变量的闭包；??= 改为 isset 三元表达式(语句中为 if)；
+//     数组展开改为 array_merge；属性类型改为 @var 文档注释；删除数字字面量中的分隔符 _
+//   - 8.0 之前：?-> 改为判断 null 的三元表达式，isset、empty 及 ?? 左侧的属性链直接改为 ->；
+//     联合类型、mixed、static 等参数及返回值类型被删除；
+//     表达式语句、return 语句及赋值语句中的 match 改为 switch (true) 语句，其它位置的 match 无法改写
+//   - 8.1 之前删除 readonly 属性修饰符、never 及交叉类型，8.2 之前删除 readonly 类修饰符，8.3 之前删除类常量的类型
+//   - 7.1 之前：多类型 catch 拆分为多个 catch；删除类常量的可见性、可空类型及 void、iterable 类型，
+//     默认值为 null 的可空参数改为 `T $x = null`；7.2 之前删除 object 类型；7.3 之前 heredoc 改为普通字符串
+//
+// 删除的类型声明只影响类型检查，对类型正确的代码行为一致。
+// 无法等价改写的语法(如表达式中的 throw、两次求值有副作用的 ??= 左侧)报告为诊断。
+// 枚举等语法 parser 尚不支持，不在降级范围内。
+package downlevel
+
+import (
+	"fmt"
+	"github.com/heyuuu/gophp/compile/diag"
+	"github.com/heyuuu/gophp/compile/ir"
+	"github.com/heyuuu/gophp/compile/parser"
+	"github.com/heyuuu/gophp/compile/transformer"
+	"strconv"
+	"strings"
+)
+
+// Version PHP 版本，格式同 PHP_VERSION_ID 去掉修订号，如 70400
+type Version int
+
+const (
+	PHP70 Version = 70000
+	PHP71 Version = 70100
+	PHP72 Version = 70200
+	PHP73 Version = 70300
+	PHP74 Version = 70400
+	PHP80 Version = 80000
+	PHP81 Version = 80100
+	PHP82 Version = 80200
+	PHP83 Version = 80300
+)
+
+// ParseVersion 解析 "7.4" 形式的版本号，修订号被忽略
+func ParseVersion(s string) (Version, error) {
+	parts := strings.SplitN(s, ".", 3)
+	if len(parts) < 2 {
+		return 0, fmt.Errorf("invalid PHP version %q, expected <major>.<minor>", s)
+	}
+	major, err1 := strconv.Atoi(parts[0])
+	minor, err2 := strconv.Atoi(parts[1])
+	if err1 != nil || err2 != nil || minor < 0 || minor > 99 {
+		return 0, fmt.Errorf("invalid PHP version %q, expected <major>.<minor>", s)
+	}
+	v := Version(major*10000 + minor*100)
+	if v < PHP70 {
+		return 0, fmt.Errorf("unsupported target PHP version %s, the lowest target is 7.0", s)
+	}
+	return v, nil
+}
+
+func (v Version) String() string {
+	return fmt.Sprintf("%d.%d", v/10000, v%10000/100)
+}
+
+// File 将文件中目标版本不支持的语法改写为等价语法，直接修改 f。返回无法改写的语法的诊断
+func File(f *ir.File, target Version) diag.List {
+	d := &downgrader{
+		target:    target,
+		stmtExprs: map[ir.Expr]bool{},
+		interior:  map[ir.Expr]bool{},
+		assigns:   map[*ir.TernaryExpr]*ir.AssignOpExpr{},
+		matches:   map[*ir.MatchExpr]bool{},
+	}
+	rewriter := ir.Rewriter{Pre: d.pre, Expr: d.expr, Stmts: d.stmts}
+	for _, ns := range f.Namespaces {
+		ir.Rewrite(ns, rewriter)
+	}
+	return d.diags
+}
+
+// Code 降级 PHP 代码，返回目标版本可以运行的代码。存在无法降级的语法时返回 diag.List 错误
+func Code(code string, target Version) (string, error) {
+	astFile, err := parser.ParseCode(code)
+	if err != nil {
+		return "", err
+	}
+	file, err := transformer.TransformFile(astFile)
+	if err != nil {
+		return "", err
+	}
+	if err = File(file, target).Err(); err != nil {
+		return "", err
+	}
+	result, err := ir.PrintFile(file)
+	if err != nil {
+		return "", err
+	}
+	return "<?php\n\n" + strings.TrimRight(result, "\n") + "\n", nil
+}
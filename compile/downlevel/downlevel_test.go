@@ -0,0 +1,194 @@
+package downlevel
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCode(t *testing.T) {
+	tests := []struct {
+		name   string
+		target Version
+		code   string
+		want   string
+	}{
+		{
+			name:   "arrow function",
+			target: PHP73,
+			code:   `$f = fn($x) => $x + $y + $this->z; $g = static fn() => throw new E();`,
+			want: `$f = function ($x) use ($y) {
+    return $x + $y + $this->z;
+};
+$g = static function () {
+    throw new \E();
+};`,
+		},
+		{
+			name:   "nested arrow function",
+			target: PHP73,
+			code:   `$x = fn($a) => function () use ($b) { return fn() => $a + $c + $_GET; };`,
+			want: `$x = function ($a) use ($b) {
+    return function () use ($b) {
+        return function () use ($a, $c) {
+            return $a + $c + $_GET;
+        };
+    };
+};`,
+		},
+		{
+			name:   "coalesce assign",
+			target: PHP73,
+			code: `// init
+$a ??= 1;
+$b = ($c['k'] ??= f());`,
+			want: `// init
+if (!isset($a)) {
+    $a = 1;
+}
+$b = isset($c['k']) ? $c['k'] : ($c['k'] = \f());`,
+		},
+		{
+			name:   "array unpacking",
+			target: PHP73,
+			code:   `$a = [1, ...$b, 'k' => 2, ...f()];`,
+			want:   `$a = \array_merge([1], $b, ['k' => 2], \f());`,
+		},
+		{
+			name:   "nullsafe",
+			target: PHP74,
+			code:   `echo $a?->b->c(), $a->b?->c?->d, isset($a?->b->c), $a?->b ?? 1, $a?->b()->c ?? 2, empty($a?->b);`,
+			want:   `echo $a === null ? null : $a->b->c(), $a->b === null ? null : ($a->b->c === null ? null : $a->b->c->d), isset($a->b->c), $a->b ?? 1, ($a === null ? null : $a->b()->c) ?? 2, empty($a->b);`,
+		},
+		{
+			name:   "typed properties",
+			target: PHP73,
+			code:   `class A { public int $a = 1; /** doc */ private ?string $b; readonly array $c; final public const X = 1; }`,
+			want: `class A
+{
+    /** @var int */
+    public $a = 1;
+    /** doc */
+    private $b;
+    /** @var array */
+    var $c;
+    public const X = 1;
+}`,
+		},
+		{
+			name:   "types",
+			target: PHP70,
+			code:   `function f(?int $a = null, ?int $b, int|string $c, object $d): static { try {} catch (A|B $e) {} echo 1_000; }`,
+			want: `function f(int $a = null, $b, $c, $d) {
+    try {
+    } catch (\A $e) {
+    } catch (\B $e) {
+    }
+    echo 1000;
+}`,
+		},
+		{
+			name:   "match",
+			target: PHP74,
+			code: `// pick
+$r = match ($x) { 1, 2 => 'a', 3 => match (f()) { 'k' => 'n', default => throw new E() }, default => 'b' };
+function g($y) { return match (true) { $y > 1 => 'big' }; }
+match ($x) { 1 => f() };`,
+			want: `// pick
+switch (true) {
+    case $x === 1:
+    case $x === 2:
+        $r = 'a';
+        break;
+    case $x === 3:
+        $__match = \f();
+        switch (true) {
+            case $__match === 'k':
+                $r = 'n';
+                break;
+            default:
+                throw new \E();
+        }
+        break;
+    default:
+        $r = 'b';
+        break;
+}
+function g($y) {
+    switch (true) {
+        case true === $y > 1:
+            return 'big';
+        default:
+            throw new \Error("Unhandled match case " . \var_export(true, true));
+    }
+}
+switch (true) {
+    case $x === 1:
+        \f();
+        break;
+    default:
+        throw new \Error("Unhandled match case " . \var_export($x, true));
+}`,
+		},
+		{
+			name:   "supported by target",
+			target: PHP80,
+			code:   `$f = fn(int|string $x): static => $x?->y ?? throw new E(); echo match ($x) { 1, 2 => 'a', default => 'b' };`,
+			want: `$f = fn(int|string $x): static => $x?->y ?? (throw new \E());
+echo match ($x) {
+    1, 2 => 'a',
+    default => 'b',
+};`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Code("<?php\n"+tt.code, tt.target)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got = strings.TrimSpace(strings.TrimPrefix(got, "<?php\n"))
+			if got != tt.want {
+				t.Errorf("Code() got:\n%s\nwant:\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCodeUnsupported(t *testing.T) {
+	tests := []struct {
+		name   string
+		target Version
+		code   string
+		want   string
+	}{
+		{"throw expression", PHP74, `$x = $a ?: throw new E();`, "throw expression"},
+		{"nullsafe side effects", PHP74, `echo f()?->a;`, "?-> on an operand with side effects"},
+		{"nullsafe in isset", PHP74, `echo isset($a?->b()->c);`, "?-> in isset()"},
+		{"coalesce assign side effects", PHP73, `f()->a ??= 1;`, "??= on an operand with side effects"},
+		{"array unpacking with int keys", PHP73, `$a = [1 => 1, ...$b];`, "array unpacking with integer or dynamic keys"},
+		{"keyed list", PHP70, `['a' => $a] = $b;`, "keyed list()"},
+		{"match in expression", PHP74, `echo match ($x) { 1 => 2 };`, "match expression inside another expression"},
+		{"match assigned to side effects", PHP74, `$a[f()] = match ($x) { 1 => 2 };`, "match expression inside another expression"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Code("<?php\n"+tt.code, tt.target)
+			if err == nil || !strings.Contains(err.Error(), "cannot downgrade "+tt.want) {
+				t.Errorf("Code() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseVersion(t *testing.T) {
+	for s, want := range map[string]Version{"7.0": PHP70, "7.4": PHP74, "8.1.2": PHP81} {
+		if v, err := ParseVersion(s); err != nil || v != want {
+			t.Errorf("ParseVersion(%q) = %v, %v, want %v", s, v, err, want)
+		}
+	}
+	for _, s := range []string{"5.6", "8", "a.b"} {
+		if _, err := ParseVersion(s); err == nil {
+			t.Errorf("ParseVersion(%q) expected error", s)
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package downlevel
+
+import (
+	"github.com/heyuuu/gophp/compile/ast"
+	"github.com/heyuuu/gophp/compile/ir"
+)
+
+// matchVar 保存 match 条件值的临时变量，条件有副作用时使用。
+// 嵌套的 match 位于外层分支的语句中，此时外层的比较已经结束，可以共用同一个变量
+const matchVar = "__match"
+
+// markMatches 记录语句中可以改写为 switch 的 match 表达式：表达式语句、return 语句及赋值给可重复求值的变量的 match，
+// 以及这些 match 分支中直接嵌套的 match。分支中的 throw 改写后为语句
+func (d *downgrader) markMatches(stmt ir.Stmt) {
+	var e ir.Expr
+	switch x := stmt.(type) {
+	case *ir.ExprStmt:
+		e = x.Expr
+		if assign, ok := e.(*ir.AssignExpr); ok && reEvaluable(assign.Var) {
+			e = assign.Expr
+		}
+	case *ir.ReturnStmt:
+		e = x.Expr
+	}
+	if m, ok := e.(*ir.MatchExpr); ok {
+		d.markMatch(m)
+	}
+}
+
+func (d *downgrader) markMatch(m *ir.MatchExpr) {
+	d.matches[m] = true
+	for _, arm := range m.Arms {
+		switch body := arm.Body.(type) {
+		case *ir.MatchExpr:
+			d.markMatch(body)
+		case *ir.ThrowExpr:
+			d.stmtExprs[body] = true
+		}
+	}
+}
+
+// matchStmt 语句中的 match 改写为 switch (true)，分支条件与条件值以 === 比较，与 match 的严格比较一致：
+//
+//	$r = match ($x) { 1, 2 => 'a', default => 'b' };
+//	// 改写为
+//	switch (true) {
+//	    case $x === 1:
+//	    case $x === 2:
+//	        $r = 'a';
+//	        break;
+//	    default:
+//	        $r = 'b';
+//	        break;
+//	}
+//
+// 没有 default 分支时添加抛出 \Error 的 default 分支(旧版本没有 UnhandledMatchError)。
+// 返回 nil 表示语句中没有需要改写的 match
+func (d *downgrader) matchStmt(stmt ir.Stmt) []ir.Stmt {
+	var m *ir.MatchExpr
+	var result func(body ir.Expr) ir.Stmt
+	switch x := stmt.(type) {
+	case *ir.ExprStmt:
+		if assign, ok := x.Expr.(*ir.AssignExpr); ok {
+			m, _ = assign.Expr.(*ir.MatchExpr)
+			result = func(body ir.Expr) ir.Stmt {
+				return withPos(&ir.ExprStmt{Expr: withPos(&ir.AssignExpr{Var: assign.Var, Expr: body}, assign)}, body)
+			}
+		} else {
+			m, _ = x.Expr.(*ir.MatchExpr)
+			result = func(body ir.Expr) ir.Stmt {
+				return withPos(&ir.ExprStmt{Expr: body}, body)
+			}
+		}
+	case *ir.ReturnStmt:
+		m, _ = x.Expr.(*ir.MatchExpr)
+		result = func(body ir.Expr) ir.Stmt {
+			return withPos(&ir.ReturnStmt{Expr: body}, body)
+		}
+	}
+	if m == nil || !d.matches[m] {
+		return nil
+	}
+
+	stmts := d.lowerMatch(m, result)
+	stmts[0].SetComments(stmt.Comments())
+	return stmts
+}
+
+func (d *downgrader) lowerMatch(m *ir.MatchExpr, result func(body ir.Expr) ir.Stmt) []ir.Stmt {
+	var stmts []ir.Stmt
+	subject := m.Cond
+	if !reEvaluable(subject) {
+		v := withPos(&ir.VariableExpr{Name: &ir.Ident{Name: matchVar}}, m.Cond)
+		stmts = append(stmts, withPos(&ir.ExprStmt{Expr: withPos(&ir.AssignExpr{Var: v, Expr: m.Cond}, m.Cond)}, m.Cond))
+		subject = v
+	}
+
+	body := func(e ir.Expr) []ir.Stmt {
+		var list []ir.Stmt
+		switch x := e.(type) {
+		case *ir.ThrowExpr:
+			return []ir.Stmt{withPos(&ir.ExprStmt{Expr: x}, x)}
+		case *ir.MatchExpr:
+			list = d.lowerMatch(x, result)
+		default:
+			list = []ir.Stmt{result(e)}
+			if _, ok := list[0].(*ir.ReturnStmt); ok {
+				return list
+			}
+		}
+		return append(list, withPos(&ir.BreakStmt{}, e))
+	}
+
+	switchStmt := withPos(&ir.SwitchStmt{Cond: withPos(&ir.BoolLit{Value: true}, m)}, m)
+	hasDefault := false
+	for _, arm := range m.Arms {
+		if arm.Conds == nil {
+			hasDefault = true
+			switchStmt.Cases = append(switchStmt.Cases, withPos(&ir.CaseStmt{Stmts: body(arm.Body)}, arm))
+			continue
+		}
+		for i, cond := range arm.Conds {
+			c := withPos(&ir.CaseStmt{Cond: withPos(&ir.BinaryOpExpr{Op: ast.BinaryOpIdentical, Left: subject, Right: cond}, cond)}, cond)
+			if i == len(arm.Conds)-1 {
+				c.Stmts = body(arm.Body)
+			}
+			switchStmt.Cases = append(switchStmt.Cases, c)
+		}
+	}
+	if !hasDefault {
+		switchStmt.Cases = append(switchStmt.Cases, withPos(&ir.CaseStmt{Stmts: []ir.Stmt{unhandledMatch(m, subject)}}, m))
+	}
+	return append(stmts, switchStmt)
+}
+
+// unhandledMatch throw new \Error('Unhandled match case ' . \var_export($x, true));
+func unhandledMatch(m *ir.MatchExpr, subject ir.Expr) ir.Stmt {
+	export := withPos(&ir.FuncCallExpr{
+		Name: &ir.Name{Kind: ast.NameFullyQualified, Parts: []string{"var_export"}},
+		Args: []*ir.Arg{withPos(&ir.Arg{Value: subject}, m), withPos(&ir.Arg{Value: withPos(&ir.BoolLit{Value: true}, m)}, m)},
+	}, m)
+	message := withPos(&ir.BinaryOpExpr{Op: ast.BinaryOpConcat, Left: withPos(&ir.StringLit{Value: "Unhandled match case "}, m), Right: export}, m)
+	newErr := withPos(&ir.NewExpr{
+		Class: &ir.Name{Kind: ast.NameFullyQualified, Parts: []string{"Error"}},
+		Args:  []*ir.Arg{withPos(&ir.Arg{Value: message}, m)},
+	}, m)
+	return withPos(&ir.ExprStmt{Expr: withPos(&ir.ThrowExpr{Expr: newErr}, m)}, m)
+}
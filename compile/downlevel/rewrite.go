@@ -0,0 +1,458 @@
+package downlevel
+
+import (
+	"github.com/heyuuu/gophp/compile/ast"
+	"github.com/heyuuu/gophp/compile/diag"
+	"github.com/heyuuu/gophp/compile/ir"
+	"strconv"
+)
+
+type downgrader struct {
+	target Version
+	diags  diag.List
+	// stmtExprs 作为语句的表达式(表达式语句、改写为闭包的箭头函数体)，其中的 throw 可以输出为语句
+	stmtExprs map[ir.Expr]bool
+	// interior 属性链内部的节点及不能改写的属性链，?-> 只在属性链的最外层改写
+	interior map[ir.Expr]bool
+	// assigns ??= 改写成的三元表达式，位于表达式语句中时改为 if 语句
+	assigns map[*ir.TernaryExpr]*ir.AssignOpExpr
+	// matches 可以改写为 switch 语句的 match 表达式
+	matches map[*ir.MatchExpr]bool
+}
+
+func (d *downgrader) unsupported(n ir.Node, feature string, since Version) {
+	d.diags.Errorf(diag.CodeUnsupported, n.Pos(), "cannot downgrade %s to PHP %s, it requires PHP %s", feature, d.target, since)
+}
+
+func withPos[T ir.Node](n T, pos ir.Node) T {
+	n.SetPos(pos.Pos(), pos.End())
+	return n
+}
+
+// pre 子节点改写前调用，改写不替换节点的语法(类型声明、修饰符、字面量等)，并记录改写表达式需要的上下文
+func (d *downgrader) pre(n ir.Node) bool {
+	switch x := n.(type) {
+	case *ir.ExprStmt:
+		d.stmtExprs[x.Expr] = true
+		if d.target < PHP80 {
+			d.markMatches(x)
+		}
+	case *ir.ReturnStmt:
+		if d.target < PHP80 {
+			d.markMatches(x)
+		}
+	case *ir.ArrowFunctionExpr:
+		if d.target < PHP74 {
+			d.stmtExprs[x.Expr] = true
+		}
+		d.returnType(&x.ReturnType)
+	case *ir.ClosureExpr:
+		d.returnType(&x.ReturnType)
+	case *ir.FunctionStmt:
+		d.returnType(&x.ReturnType)
+	case *ir.ClassMethodStmt:
+		d.returnType(&x.ReturnType)
+	case *ir.Param:
+		d.param(x)
+	case *ir.PropertyStmt:
+		d.property(x)
+	case *ir.ClassConstStmt:
+		d.classConst(x)
+	case *ir.ClassStmt:
+		if d.target < PHP82 {
+			x.Flags &^= ast.FlagReadonly
+		}
+	case *ir.TryCatchStmt:
+		if d.target < PHP71 {
+			x.Catches = splitCatches(x.Catches)
+		}
+	case *ir.ListExpr:
+		d.list(x)
+	case *ir.IssetExpr:
+		for _, v := range x.Vars {
+			d.plainOperand(v, "?-> in isset()")
+		}
+	case *ir.EmptyExpr:
+		d.plainOperand(x.Expr, "?-> in empty()")
+	case *ir.BinaryOpExpr:
+		if x.Op == ast.BinaryOpCoalesce && d.target < PHP80 {
+			plainChain(x.Left)
+		}
+	case *ir.PropertyFetchExpr:
+		d.markInterior(x.Var)
+	case *ir.MethodCallExpr:
+		d.markInterior(x.Var)
+	case *ir.IndexExpr:
+		d.markInterior(x.Var)
+	case *ir.IntLit:
+		if d.target < PHP74 {
+			x.Raw = removeSeparators(x.Raw)
+		}
+	case *ir.FloatLit:
+		if d.target < PHP74 {
+			x.Raw = removeSeparators(x.Raw)
+		}
+	case *ir.StringLit:
+		// heredoc 的结束标记在 7.3 之前不能缩进，改为按值输出的普通字符串
+		if d.target < PHP73 && len(x.Raw) >= 3 && x.Raw[:3] == "<<<" {
+			x.Raw = ""
+		}
+	}
+	return true
+}
+
+// expr 子节点改写后调用，替换目标版本不支持的表达式
+func (d *downgrader) expr(e ir.Expr) ir.Expr {
+	switch x := e.(type) {
+	case *ir.ArrowFunctionExpr:
+		if d.target < PHP74 {
+			return d.arrowFunction(x)
+		}
+	case *ir.AssignOpExpr:
+		if x.Op == ast.AssignOpCoalesce && d.target < PHP74 {
+			return d.coalesceAssign(x)
+		}
+	case *ir.ArrayExpr:
+		if d.target < PHP74 && hasUnpack(x) {
+			return d.arraySpread(x)
+		}
+	case *ir.ThrowExpr:
+		if d.target < PHP80 && !d.stmtExprs[e] {
+			d.unsupported(x, "throw expression", PHP80)
+		}
+	case *ir.MatchExpr:
+		if d.target < PHP80 && !d.matches[x] {
+			d.unsupported(x, "match expression inside another expression", PHP80)
+		}
+	case *ir.PropertyFetchExpr, *ir.MethodCallExpr, *ir.IndexExpr:
+		if d.target < PHP80 && !d.interior[e] {
+			return d.nullsafe(e)
+		}
+	}
+	return e
+}
+
+// stmts 表达式语句中的 ??= 改为 if (!isset($a)) { $a = $b; }，语句中的 match 改为 switch 语句
+func (d *downgrader) stmts(list []ir.Stmt) []ir.Stmt {
+	result := list[:0:0]
+	for _, stmt := range list {
+		if stmts := d.matchStmt(stmt); stmts != nil {
+			result = append(result, stmts...)
+			continue
+		}
+		result = append(result, stmt)
+
+		x, ok := stmt.(*ir.ExprStmt)
+		if !ok {
+			continue
+		}
+		ternary, ok := x.Expr.(*ir.TernaryExpr)
+		if !ok || d.assigns[ternary] == nil {
+			continue
+		}
+		cond := withPos(&ir.UnaryExpr{Op: ast.UnaryOpBooleanNot, Var: ternary.Cond}, x)
+		body := withPos(&ir.ExprStmt{Expr: ternary.Else}, x)
+		ifStmt := withPos(&ir.IfStmt{Cond: cond, Stmts: []ir.Stmt{body}}, x)
+		ifStmt.SetComments(x.Comments())
+		result[len(result)-1] = ifStmt
+	}
+	return result
+}
+
+// arrowFunction 箭头函数改为闭包，函数体内使用的外部变量按值 use，与箭头函数的自动绑定一致
+func (d *downgrader) arrowFunction(x *ir.ArrowFunctionExpr) ir.Expr {
+	var body ir.Stmt
+	if throw, ok := x.Expr.(*ir.ThrowExpr); ok {
+		body = &ir.ExprStmt{Expr: throw}
+	} else {
+		body = &ir.ReturnStmt{Expr: x.Expr}
+	}
+	body.SetPos(x.Expr.Pos(), x.Expr.End())
+
+	closure := withPos(&ir.ClosureExpr{
+		Static:     x.Static,
+		ByRef:      x.ByRef,
+		Params:     x.Params,
+		ReturnType: x.ReturnType,
+		Stmts:      []ir.Stmt{body},
+	}, x)
+	for _, name := range capturedVars(x) {
+		v := withPos(&ir.VariableExpr{Name: &ir.Ident{Name: name}}, x)
+		closure.Uses = append(closure.Uses, withPos(&ir.ClosureUseExpr{Var: v}, x))
+	}
+	return closure
+}
+
+// superGlobals 超全局变量在任意作用域可用，不能作为闭包的 use 变量
+var superGlobals = map[string]bool{
+	"GLOBALS": true, "_SERVER": true, "_GET": true, "_POST": true, "_FILES": true,
+	"_COOKIE": true, "_SESSION": true, "_REQUEST": true, "_ENV": true,
+}
+
+// capturedVars 箭头函数体内使用的外部变量名，按首次出现的顺序排列。
+// 嵌套的闭包只使用其 use 的变量，嵌套的箭头函数使用其自动绑定的变量
+func capturedVars(x *ir.ArrowFunctionExpr) []string {
+	local := map[string]bool{"this": true}
+	for _, param := range x.Params {
+		if ident, ok := param.Var.Name.(*ir.Ident); ok {
+			local[ident.Name] = true
+		}
+	}
+
+	var names []string
+	add := func(name string) {
+		if !local[name] && !superGlobals[name] {
+			local[name] = true
+			names = append(names, name)
+		}
+	}
+	ir.Inspect(x.Expr, func(n ir.Node) bool {
+		switch n := n.(type) {
+		case *ir.ClosureExpr:
+			for _, use := range n.Uses {
+				if ident, ok := use.Var.Name.(*ir.Ident); ok {
+					add(ident.Name)
+				}
+			}
+			return false
+		case *ir.ArrowFunctionExpr:
+			for _, name := range capturedVars(n) {
+				add(name)
+			}
+			return false
+		case *ir.VariableExpr:
+			if ident, ok := n.Name.(*ir.Ident); ok {
+				add(ident.Name)
+			}
+		}
+		return true
+	})
+	return names
+}
+
+// coalesceAssign $a ??= $b 改为 isset($a) ? $a : ($a = $b)，$a 会求值多次，不能有副作用
+func (d *downgrader) coalesceAssign(x *ir.AssignOpExpr) ir.Expr {
+	if !reEvaluable(x.Var) {
+		d.unsupported(x, "??= on an operand with side effects", PHP74)
+		return x
+	}
+	isset := withPos(&ir.IssetExpr{Vars: []ir.Expr{x.Var}}, x)
+	assign := withPos(&ir.AssignExpr{Var: x.Var, Expr: x.Expr}, x)
+	ternary := withPos(&ir.TernaryExpr{Cond: isset, If: x.Var, Else: assign}, x)
+	d.assigns[ternary] = x
+	return ternary
+}
+
+func hasUnpack(x *ir.ArrayExpr) bool {
+	for _, item := range x.Items {
+		if item != nil && item.Unpack {
+			return true
+		}
+	}
+	return false
+}
+
+// arraySpread 数组展开 [1, ...$a, 2] 改为 array_merge([1], $a, [2])。
+// array_merge 重新编号整数键，其他元素有整数键或动态键时结果与数组展开不同，无法改写
+func (d *downgrader) arraySpread(x *ir.ArrayExpr) ir.Expr {
+	var args []*ir.Arg
+	var group []*ir.ArrayItemExpr
+	flush := func() {
+		if len(group) > 0 {
+			args = append(args, withPos(&ir.Arg{Value: withPos(&ir.ArrayExpr{Items: group}, group[0])}, group[0]))
+			group = nil
+		}
+	}
+	for _, item := range x.Items {
+		if item.Unpack {
+			flush()
+			args = append(args, withPos(&ir.Arg{Value: item.Value}, item))
+			continue
+		}
+		if item.Key != nil && !isStringKey(item.Key) {
+			d.unsupported(item, "array unpacking with integer or dynamic keys", PHP74)
+			return x
+		}
+		group = append(group, item)
+	}
+	flush()
+
+	name := &ir.Name{Kind: ast.NameFullyQualified, Parts: []string{"array_merge"}}
+	return withPos(&ir.FuncCallExpr{Name: name, Args: args}, x)
+}
+
+// isStringKey 是否为字符串键，整数形式的字符串(如 "1")作为整数键
+func isStringKey(key ir.Expr) bool {
+	lit, ok := key.(*ir.StringLit)
+	if !ok {
+		return false
+	}
+	if i, err := strconv.Atoi(lit.Value); err == nil && strconv.Itoa(i) == lit.Value {
+		return false
+	}
+	return true
+}
+
+// markInterior 标记属性链内部的节点
+func (d *downgrader) markInterior(e ir.Expr) {
+	switch e.(type) {
+	case *ir.PropertyFetchExpr, *ir.MethodCallExpr, *ir.IndexExpr:
+		d.interior[e] = true
+	}
+}
+
+// plainChain isset、empty 及 ?? 左侧对 null 的属性访问不报错，属性链中的 ?-> 可以直接改为 ->。
+// 方法调用之下的 ?-> 仍需改写
+func plainChain(e ir.Expr) {
+	for {
+		switch x := e.(type) {
+		case *ir.PropertyFetchExpr:
+			x.Nullsafe = false
+			e = x.Var
+		case *ir.IndexExpr:
+			e = x.Var
+		default:
+			return
+		}
+	}
+}
+
+// plainOperand isset、empty 的操作数不能改写为三元表达式，去掉 ?-> 后仍有 ?-> 时报告
+func (d *downgrader) plainOperand(e ir.Expr, feature string) {
+	if d.target >= PHP80 {
+		return
+	}
+	plainChain(e)
+	if n := deepestNullsafe(e); n != nil {
+		d.unsupported(n, feature, PHP80)
+		d.interior[e] = true
+	}
+}
+
+// deepestNullsafe 属性链中最内层的 ?-> 节点
+func deepestNullsafe(e ir.Expr) ir.Expr {
+	var found ir.Expr
+	for {
+		switch x := e.(type) {
+		case *ir.PropertyFetchExpr:
+			if x.Nullsafe {
+				found = x
+			}
+			e = x.Var
+		case *ir.MethodCallExpr:
+			if x.Nullsafe {
+				found = x
+			}
+			e = x.Var
+		case *ir.IndexExpr:
+			e = x.Var
+		default:
+			return found
+		}
+	}
+}
+
+// nullsafe 属性链中的 ?-> 由内向外改写：$a?->b->c() 改为 $a === null ? null : $a->b->c()。
+// ?-> 左侧的表达式会求值两次，不能有副作用
+func (d *downgrader) nullsafe(top ir.Expr) ir.Expr {
+	n := deepestNullsafe(top)
+	if n == nil {
+		return top
+	}
+	var recv ir.Expr
+	switch x := n.(type) {
+	case *ir.PropertyFetchExpr:
+		recv = x.Var
+	case *ir.MethodCallExpr:
+		recv = x.Var
+	}
+	if !reEvaluable(recv) {
+		d.unsupported(n, "?-> on an operand with side effects", PHP80)
+		return top
+	}
+	switch x := n.(type) {
+	case *ir.PropertyFetchExpr:
+		x.Nullsafe = false
+	case *ir.MethodCallExpr:
+		x.Nullsafe = false
+	}
+
+	cond := withPos(&ir.BinaryOpExpr{Op: ast.BinaryOpIdentical, Left: recv, Right: withPos(&ir.NullLit{}, n)}, n)
+	return withPos(&ir.TernaryExpr{Cond: cond, If: withPos(&ir.NullLit{}, n), Else: d.nullsafe(top)}, top)
+}
+
+// reEvaluable 表达式是否可以重复求值：变量、属性(不含方法调用)及下标为变量或字面量的数组元素
+func reEvaluable(e ir.Expr) bool {
+	switch x := e.(type) {
+	case *ir.VariableExpr:
+		switch name := x.Name.(type) {
+		case *ir.Ident:
+			return true
+		case ir.Expr:
+			return reEvaluable(name)
+		}
+	case *ir.PropertyFetchExpr:
+		_, ok := x.Name.(*ir.Ident)
+		return ok && !x.Nullsafe && reEvaluable(x.Var)
+	case *ir.StaticPropertyFetchExpr:
+		_, isName := x.Class.(*ir.Name)
+		_, isIdent := x.Name.(*ir.Ident)
+		return isName && isIdent
+	case *ir.IndexExpr:
+		return x.Dim != nil && reEvaluable(x.Dim) && reEvaluable(x.Var)
+	case *ir.NullLit, *ir.BoolLit, *ir.IntLit, *ir.FloatLit, *ir.StringLit, *ir.ConstFetchExpr, *ir.ClassConstFetchExpr:
+		return true
+	}
+	return false
+}
+
+// splitCatches 多类型的 catch 拆分为多个 catch，共用变量及语句
+func splitCatches(catches []*ir.CatchStmt) []*ir.CatchStmt {
+	var result []*ir.CatchStmt
+	for _, c := range catches {
+		if len(c.Types) <= 1 {
+			result = append(result, c)
+			continue
+		}
+		for _, typ := range c.Types {
+			result = append(result, withPos(&ir.CatchStmt{Types: []*ir.Name{typ}, Var: c.Var, Stmts: c.Stmts}, c))
+		}
+	}
+	return result
+}
+
+func (d *downgrader) list(x *ir.ListExpr) {
+	for _, item := range x.Items {
+		if item == nil {
+			continue
+		}
+		if item.Key != nil && d.target < PHP71 {
+			d.unsupported(item, "keyed list()", PHP71)
+		}
+		if item.ByRef && d.target < PHP73 {
+			d.unsupported(item, "reference assignment in list()", PHP73)
+		}
+	}
+}
+
+func (d *downgrader) classConst(x *ir.ClassConstStmt) {
+	if d.target < PHP83 {
+		x.Type = nil
+	}
+	if d.target < PHP81 {
+		x.Flags &^= ast.FlagFinal
+	}
+	if d.target < PHP71 {
+		x.Flags &^= ast.VisibilityModifierMask
+	}
+}
+
+func removeSeparators(raw string) string {
+	var buf []byte
+	for i := 0; i < len(raw); i++ {
+		if raw[i] != '_' {
+			buf = append(buf, raw[i])
+		}
+	}
+	return string(buf)
+}
@@ -0,0 +1,150 @@
+package downlevel
+
+import (
+	"github.com/heyuuu/gophp/compile/ast"
+	"github.com/heyuuu/gophp/compile/ir"
+	"strings"
+)
+
+// typeSince 类型声明需要的最低 PHP 版本
+func typeSince(t ir.TypeHint) Version {
+	switch x := t.(type) {
+	case *ir.SimpleType:
+		switch simpleTypeName(x) {
+		case "iterable", "void":
+			return PHP71
+		case "object":
+			return PHP72
+		case "mixed", "static":
+			return PHP80
+		case "never":
+			return PHP81
+		case "null", "false", "true":
+			// 单独使用
+			return PHP82
+		}
+		return PHP70
+	case *ir.NullableType:
+		return max(PHP71, typeSince(x.Type))
+	case *ir.UnionType:
+		v := PHP80
+		for _, typ := range x.Types {
+			switch typ := typ.(type) {
+			case *ir.IntersectionType:
+				// DNF 类型
+				v = max(v, PHP82, typeSince(typ))
+			case *ir.SimpleType:
+				if name := simpleTypeName(typ); name == "null" || name == "false" {
+					continue
+				}
+				v = max(v, typeSince(typ))
+			}
+		}
+		return v
+	case *ir.IntersectionType:
+		v := PHP81
+		for _, typ := range x.Types {
+			v = max(v, typeSince(typ))
+		}
+		return v
+	}
+	return PHP70
+}
+
+// simpleTypeName 内置类型返回小写的类型名，类名返回空串
+func simpleTypeName(t *ir.SimpleType) string {
+	if !t.Name.IsUnqualified() {
+		return ""
+	}
+	return strings.ToLower(t.Name.ToString())
+}
+
+// typeString 文档注释中的类型
+func typeString(t ir.TypeHint) string {
+	switch x := t.(type) {
+	case *ir.SimpleType:
+		return x.Name.ToCodeString()
+	case *ir.NullableType:
+		return typeString(x.Type) + "|null"
+	case *ir.UnionType:
+		parts := make([]string, len(x.Types))
+		for i, typ := range x.Types {
+			if _, ok := typ.(*ir.IntersectionType); ok {
+				parts[i] = "(" + typeString(typ) + ")"
+			} else {
+				parts[i] = typeString(typ)
+			}
+		}
+		return strings.Join(parts, "|")
+	case *ir.IntersectionType:
+		parts := make([]string, len(x.Types))
+		for i, typ := range x.Types {
+			parts[i] = typeString(typ)
+		}
+		return strings.Join(parts, "&")
+	}
+	return "mixed"
+}
+
+func (d *downgrader) returnType(t *ir.TypeHint) {
+	if *t != nil && typeSince(*t) > d.target {
+		*t = nil
+	}
+}
+
+func (d *downgrader) param(x *ir.Param) {
+	if d.target < PHP81 && x.Default != nil && containsNew(x.Default) {
+		d.unsupported(x.Default, "new in initializer", PHP81)
+	}
+	if x.Type == nil || typeSince(x.Type) <= d.target {
+		return
+	}
+	// ?T $x = null 与 T $x = null 等价
+	if nullable, ok := x.Type.(*ir.NullableType); ok && isNull(x.Default) && typeSince(nullable.Type) <= d.target {
+		x.Type = nullable.Type
+		return
+	}
+	x.Type = nil
+}
+
+// property 属性类型改为 @var 文档注释，已有文档注释时保留原注释
+func (d *downgrader) property(x *ir.PropertyStmt) {
+	if d.target < PHP81 {
+		x.Flags &^= ast.FlagReadonly
+	}
+	if x.Type == nil || max(PHP74, typeSince(x.Type)) <= d.target {
+		return
+	}
+	hasDoc := false
+	for _, comment := range x.Comments() {
+		if comment.Type == ast.CommentDoc {
+			hasDoc = true
+		}
+	}
+	if !hasDoc {
+		doc := withPos(&ir.Comment{Type: ast.CommentDoc, Text: "/** @var " + typeString(x.Type) + " */"}, x)
+		x.SetComments(append(x.Comments(), doc))
+	}
+	x.Type = nil
+}
+
+func isNull(e ir.Expr) bool {
+	if _, ok := e.(*ir.NullLit); ok {
+		return true
+	}
+	if c, ok := e.(*ir.ConstFetchExpr); ok {
+		return strings.EqualFold(c.Name.ToString(), "null")
+	}
+	return false
+}
+
+func containsNew(e ir.Expr) bool {
+	found := false
+	ir.Inspect(e, func(n ir.Node) bool {
+		if _, ok := n.(*ir.NewExpr); ok {
+			found = true
+		}
+		return !found
+	})
+	return found
+}
@@ -0,0 +1,294 @@
+package flow
+
+// bitset 数据流分析中的集合
+type bitset []uint64
+
+func newBitset(n int) bitset { return make(bitset, (n+63)/64) }
+
+func (s bitset) has(i int) bool { return s[i/64]&(1<<(i%64)) != 0 }
+func (s bitset) set(i int)      { s[i/64] |= 1 << (i % 64) }
+func (s bitset) clear(i int)    { s[i/64] &^= 1 << (i % 64) }
+
+func (s bitset) copy() bitset { return append(bitset(nil), s...) }
+
+// union 将 other 并入 s，返回 s 是否改变
+func (s bitset) union(other bitset) bool {
+	changed := false
+	for i, v := range other {
+		if s[i]|v != s[i] {
+			s[i] |= v
+			changed = true
+		}
+	}
+	return changed
+}
+
+func (s bitset) equal(other bitset) bool {
+	for i, v := range other {
+		if s[i] != v {
+			return false
+		}
+	}
+	return true
+}
+
+// Reaching 到达定值分析：程序各点可能到达的变量写入。
+// 函数(箭头函数除外)及顶层语句入口处，每个变量有一个 Kind 为 Unset、Var 为 nil 的伪定值，表示变量尚未赋值
+type Reaching struct {
+	g      *Graph
+	defs   []*Ref
+	index  map[*Ref]int
+	byName map[string][]int
+	in     []bitset
+}
+
+// Reaching 计算到达定值
+func (g *Graph) Reaching() *Reaching {
+	r := &Reaching{g: g, index: map[*Ref]int{}, byName: map[string][]int{}}
+	if g.undefined {
+		for _, name := range g.names {
+			r.addDef(&Ref{Kind: Unset, Name: name, Block: g.Entry})
+		}
+	}
+	for _, block := range g.Blocks {
+		for _, n := range block.Nodes {
+			for _, ref := range g.refs[n] {
+				if ref.Kind.Writes() {
+					r.addDef(ref)
+				}
+			}
+		}
+	}
+
+	entry := newBitset(len(r.defs))
+	for i, def := range r.defs {
+		if def.Var == nil {
+			entry.set(i)
+		}
+	}
+	r.in = make([]bitset, len(g.Blocks))
+	out := make([]bitset, len(g.Blocks))
+	for i := range g.Blocks {
+		r.in[i] = newBitset(len(r.defs))
+		out[i] = newBitset(len(r.defs))
+	}
+	r.in[g.Entry.Index] = entry
+	for changed := true; changed; {
+		changed = false
+		for _, block := range g.Blocks {
+			if !block.reachable {
+				continue
+			}
+			in := r.in[block.Index]
+			for _, pred := range block.Preds {
+				if pred.reachable {
+					in.union(out[pred.Index])
+				}
+			}
+			result := r.transfer(block, in, nil)
+			if !result.equal(out[block.Index]) {
+				out[block.Index] = result
+				changed = true
+			}
+		}
+	}
+	return r
+}
+
+func (r *Reaching) addDef(def *Ref) {
+	r.index[def] = len(r.defs)
+	r.byName[def.Name] = append(r.byName[def.Name], len(r.defs))
+	r.defs = append(r.defs, def)
+}
+
+// transfer 依次执行块内的变量访问，执行到 stop 前停止
+func (r *Reaching) transfer(block *Block, in bitset, stop *Ref) bitset {
+	set := in.copy()
+	for _, n := range block.Nodes {
+		for _, ref := range r.g.refs[n] {
+			if ref == stop {
+				return set
+			}
+			if ref.Kind.Kills() {
+				for _, i := range r.byName[ref.Name] {
+					set.clear(i)
+				}
+			}
+			if ref.Kind.Writes() {
+				set.set(r.index[ref])
+			}
+		}
+	}
+	return set
+}
+
+func (r *Reaching) list(set bitset, name string) []*Ref {
+	var result []*Ref
+	for i, def := range r.defs {
+		if set.has(i) && (name == "" || def.Name == name) {
+			result = append(result, def)
+		}
+	}
+	return result
+}
+
+// In 到达基本块入口的定值
+func (r *Reaching) In(b *Block) []*Ref {
+	return r.list(r.in[b.Index], "")
+}
+
+// Before 在访问 ref 之前到达的同名变量的定值
+func (r *Reaching) Before(ref *Ref) []*Ref {
+	if ref.Block == nil || ref.Var == nil {
+		return nil
+	}
+	return r.list(r.transfer(ref.Block, r.in[ref.Block.Index], ref), ref.Name)
+}
+
+// Liveness 活跃变量分析：程序各点之后可能被读取的变量
+type Liveness struct {
+	g     *Graph
+	index map[string]int
+	in    []bitset
+	out   []bitset
+}
+
+// Liveness 计算活跃变量
+func (g *Graph) Liveness() *Liveness {
+	l := &Liveness{g: g, index: map[string]int{}}
+	for i, name := range g.names {
+		l.index[name] = i
+	}
+	l.in = make([]bitset, len(g.Blocks))
+	l.out = make([]bitset, len(g.Blocks))
+	for i := range g.Blocks {
+		l.in[i] = newBitset(len(g.names))
+		l.out[i] = newBitset(len(g.names))
+	}
+	for changed := true; changed; {
+		changed = false
+		for i := len(g.Blocks) - 1; i >= 0; i-- {
+			block := g.Blocks[i]
+			out := l.out[i]
+			for _, succ := range block.Succs {
+				out.union(l.in[succ.Index])
+			}
+			result := l.transfer(block, out, nil)
+			if !result.equal(l.in[i]) {
+				l.in[i] = result
+				changed = true
+			}
+		}
+	}
+	return l
+}
+
+// transfer 从块尾逆序执行块内的变量访问，执行到 stop 时停止
+func (l *Liveness) transfer(block *Block, out bitset, stop *Ref) bitset {
+	set := out.copy()
+	for i := len(block.Nodes) - 1; i >= 0; i-- {
+		refs := l.g.refs[block.Nodes[i]]
+		for j := len(refs) - 1; j >= 0; j-- {
+			ref := refs[j]
+			if ref == stop {
+				return set
+			}
+			if ref.Kind.Kills() {
+				set.clear(l.index[ref.Name])
+			}
+			if ref.Kind.Reads() {
+				set.set(l.index[ref.Name])
+			}
+		}
+	}
+	return set
+}
+
+func (l *Liveness) list(set bitset) []string {
+	var result []string
+	for i, name := range l.g.names {
+		if set.has(i) {
+			result = append(result, name)
+		}
+	}
+	return result
+}
+
+// In 基本块入口处活跃的变量
+func (l *Liveness) In(b *Block) []string {
+	return l.list(l.in[b.Index])
+}
+
+// Out 基本块出口处活跃的变量
+func (l *Liveness) Out(b *Block) []string {
+	return l.list(l.out[b.Index])
+}
+
+// LiveAfter 访问 ref 之后变量是否仍可能被读取
+func (l *Liveness) LiveAfter(ref *Ref) bool {
+	if ref.Block == nil {
+		return false
+	}
+	return l.transfer(ref.Block, l.out[ref.Block.Index], ref).has(l.index[ref.Name])
+}
+
+// Chains 定值-使用链
+type Chains struct {
+	defs      map[*Ref][]*Ref
+	uses      map[*Ref][]*Ref
+	undefined map[*Ref]bool
+}
+
+// DefUse 根据到达定值计算定值-使用链
+func (g *Graph) DefUse() *Chains {
+	r := g.Reaching()
+	c := &Chains{defs: map[*Ref][]*Ref{}, uses: map[*Ref][]*Ref{}, undefined: map[*Ref]bool{}}
+	for _, block := range g.Blocks {
+		if !block.reachable {
+			continue
+		}
+		set := r.in[block.Index].copy()
+		for _, n := range block.Nodes {
+			for _, ref := range g.refs[n] {
+				if ref.Kind.Reads() {
+					for _, i := range r.byName[ref.Name] {
+						if !set.has(i) {
+							continue
+						}
+						def := r.defs[i]
+						if def.Kind == Unset {
+							c.undefined[ref] = true
+							continue
+						}
+						c.defs[ref] = append(c.defs[ref], def)
+						c.uses[def] = append(c.uses[def], ref)
+					}
+				}
+				if ref.Kind.Kills() {
+					for _, i := range r.byName[ref.Name] {
+						set.clear(i)
+					}
+				}
+				if ref.Kind.Writes() {
+					set.set(r.index[ref])
+				}
+			}
+		}
+	}
+	return c
+}
+
+// Defs 读取 use 时可能读到的写入，不含 unset 及入口处的未定义
+func (c *Chains) Defs(use *Ref) []*Ref {
+	return c.defs[use]
+}
+
+// Uses 可能读到 def 写入的值的读取
+func (c *Chains) Uses(def *Ref) []*Ref {
+	return c.uses[def]
+}
+
+// MaybeUndefined 读取 use 时变量是否可能未定义(未赋值或已 unset)
+func (c *Chains) MaybeUndefined(use *Ref) bool {
+	return c.undefined[use]
+}
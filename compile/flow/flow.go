@@ -0,0 +1,570 @@
+// Package flow 在 IR 上构建函数体的控制流图(CFG)，并提供到达定值、活跃变量及定值-使用链分析。
+//
+// 控制流图以函数、方法、闭包、箭头函数或文件顶层语句为单位构建，嵌套的函数、闭包及类声明不展开。
+// 基本块中的节点按执行顺序排列，为以下几类：
+//   - 简单语句，如 *ir.ExprStmt、*ir.EchoStmt、*ir.ReturnStmt、*ir.BreakStmt、*ir.GotoStmt
+//   - 复合语句的条件表达式，如 if、while、switch、case 的条件及 for 的三组表达式
+//   - *ir.ForeachStmt 表示每次迭代对键、值变量的赋值，*ir.CatchStmt 表示对异常变量的赋值
+//   - 入口块中的 *ir.Param 及 *ir.ClosureUseExpr，表示调用时对参数及闭包捕获变量的赋值
+//
+// break/continue 按层数跳出循环或 switch，goto 跳转到同一函数体内的标签，switch 的 case 依次贯穿。
+// try 块内每个节点执行前都可能抛出异常，跳转到 catch 或 finally；经过 finally 的 return、break、
+// continue、goto 及未捕获的异常先进入 finally，finally 结束后可能继续到其中任意一个目标。
+// 不在 try 块内的异常及 exit 直接结束函数体，只有 throw 语句和 exit 语句会产生到出口块的边。
+package flow
+
+import (
+	"github.com/heyuuu/gophp/compile/ir"
+)
+
+// Graph 函数体的控制流图
+type Graph struct {
+	Entry  *Block   // 入口块，没有前驱
+	Exit   *Block   // 出口块，没有节点及后继
+	Blocks []*Block // 所有基本块，Block.Index 为其下标；包含不可达的块
+
+	// Dynamic 函数体内有可变变量、eval、include 或 extract、compact 等按名称访问符号表的操作，
+	// 此时变量的访问不完整，数据流分析的结果仅供参考
+	Dynamic bool
+
+	refs      map[ir.Node][]*Ref
+	stmts     map[ir.Stmt]*Block
+	escaped   map[string]bool
+	names     []string
+	undefined bool
+}
+
+// Block 基本块，块内的节点顺序执行
+type Block struct {
+	Index int
+	Nodes []ir.Node
+	Succs []*Block
+	Preds []*Block
+
+	reachable bool
+}
+
+// Reachable 是否能从入口块到达
+func (b *Block) Reachable() bool { return b.reachable }
+
+// Func 构建函数、方法、闭包或箭头函数的控制流图，抽象方法返回 nil
+func Func(fn ir.FunctionLike) *Graph {
+	b := newBuilder()
+	switch x := fn.(type) {
+	case *ir.FunctionStmt:
+		b.params(x.Params)
+		b.stmts(x.Stmts)
+	case *ir.ClassMethodStmt:
+		if x.Stmts == nil {
+			return nil
+		}
+		b.params(x.Params)
+		b.stmts(x.Stmts)
+	case *ir.ClosureExpr:
+		b.params(x.Params)
+		for _, use := range x.Uses {
+			b.add(use)
+		}
+		b.stmts(x.Stmts)
+	case *ir.ArrowFunctionExpr:
+		// 箭头函数自动捕获外部变量，未在函数体内赋值的变量不视为未定义
+		b.g.undefined = false
+		b.params(x.Params)
+		b.add(x.Expr)
+	}
+	return b.finish()
+}
+
+// Body 构建文件顶层语句(如命名空间内的语句)的控制流图
+func Body(stmts []ir.Stmt) *Graph {
+	b := newBuilder()
+	b.stmts(stmts)
+	return b.finish()
+}
+
+// Refs 节点内的变量访问，按执行顺序排列
+func (g *Graph) Refs(n ir.Node) []*Ref {
+	return g.refs[n]
+}
+
+// StmtBlock 语句开始执行时所在的基本块，不在控制流图中的语句(如嵌套函数内的语句)返回 nil
+func (g *Graph) StmtBlock(s ir.Stmt) *Block {
+	return g.stmts[s]
+}
+
+// Names 函数体内访问的变量名，按首次出现的顺序
+func (g *Graph) Names() []string {
+	return g.names
+}
+
+// Escaped 变量是否可能为引用(global、static、=&、按引用的参数、闭包捕获及 foreach)，
+// 此时对变量的写入可能在函数体外或通过其他变量读取
+func (g *Graph) Escaped(name string) bool {
+	return g.escaped[name]
+}
+
+// loop 可以被 break/continue 跳出的循环或 switch
+type loop struct {
+	brk      *Block
+	cont     *Block
+	finallys int // 循环外的 finally 层数
+}
+
+// finally try 语句的 finally 块
+type finally struct {
+	entry *Block
+	exit  *Block // finally 语句执行完后所在的块
+}
+
+// exitEdge finally 执行完后继续执行 target，仅当跳转的起点 from 可达时才有此边
+type exitEdge struct {
+	from   *Block
+	fin    *finally
+	target *Block
+}
+
+type label struct {
+	block    *Block
+	finallys []*finally
+}
+
+type gotoStmt struct {
+	from     *Block
+	name     string
+	finallys []*finally
+}
+
+type builder struct {
+	g        *Graph
+	cur      *Block
+	handler  *Block // 当前位置抛出异常时的跳转目标，不在 try 内时为 nil
+	loops    []loop
+	finallys []*finally
+	labels   map[string]*label
+	gotos    []gotoStmt
+	exits    []exitEdge
+}
+
+func newBuilder() *builder {
+	g := &Graph{
+		refs:      map[ir.Node][]*Ref{},
+		stmts:     map[ir.Stmt]*Block{},
+		escaped:   map[string]bool{},
+		undefined: true,
+	}
+	b := &builder{g: g, labels: map[string]*label{}}
+	g.Entry = b.newBlock()
+	g.Exit = b.newBlock()
+	b.cur = g.Entry
+	return b
+}
+
+func (b *builder) newBlock() *Block {
+	block := &Block{Index: len(b.g.Blocks)}
+	b.g.Blocks = append(b.g.Blocks, block)
+	return block
+}
+
+func (b *builder) edge(from, to *Block) {
+	for _, succ := range from.Succs {
+		if succ == to {
+			return
+		}
+	}
+	from.Succs = append(from.Succs, to)
+	to.Preds = append(to.Preds, from)
+}
+
+// next 从当前块进入新块 block
+func (b *builder) next(block *Block) {
+	b.edge(b.cur, block)
+	b.cur = block
+}
+
+// dead 跳转语句之后的代码不可达，放入没有前驱的新块
+func (b *builder) dead() {
+	b.cur = b.newBlock()
+}
+
+// add 将节点加入当前块。try 内的节点执行前可能抛出异常，每个节点单独成块，
+// 其前驱块(节点执行前的状态)连接到异常处理块
+func (b *builder) add(n ir.Node) {
+	if b.handler != nil {
+		b.edge(b.cur, b.handler)
+		b.next(b.newBlock())
+	}
+	b.cur.Nodes = append(b.cur.Nodes, n)
+	b.collect(n)
+}
+
+// jump 从当前块跳转到 target，依次经过 finallys 中从内到外的 finally 块，之后当前位置不可达
+func (b *builder) jump(target *Block, finallys []*finally) {
+	b.edge(b.cur, b.through(b.cur, target, finallys))
+	b.dead()
+}
+
+// through 从 from 经过 finallys 后到达 target 的跳转，返回第一个要进入的块
+func (b *builder) through(from *Block, target *Block, finallys []*finally) *Block {
+	for i := len(finallys) - 1; i >= 0; i-- {
+		b.exits = append(b.exits, exitEdge{from: from, fin: finallys[i], target: target})
+		target = finallys[i].entry
+	}
+	return target
+}
+
+// crossed 从当前位置跳出到外层 n 层 finally 之外时经过的 finally，从内到外
+func (b *builder) crossed(n int) []*finally {
+	var result []*finally
+	for i := len(b.finallys) - 1; i >= n; i-- {
+		result = append(result, b.finallys[i])
+	}
+	return result
+}
+
+func (b *builder) finish() *Graph {
+	// 函数体执行完毕
+	b.jump(b.g.Exit, b.crossed(0))
+
+	for _, g := range b.gotos {
+		target := b.g.Exit // 标签不存在时为编译错误
+		var finallys []*finally
+		if l := b.labels[g.name]; l != nil {
+			target = l.block
+			finallys = crossedTo(g.finallys, l.finallys)
+		}
+		b.edge(g.from, b.through(g.from, target, finallys))
+	}
+
+	// finally 的出边取决于经过它的跳转是否可达，逐步加入直到可达性不再变化
+	done := make([]bool, len(b.exits))
+	for changed := true; changed; {
+		b.g.markReachable()
+		changed = false
+		for i, e := range b.exits {
+			if !done[i] && e.from.reachable {
+				b.edge(e.fin.exit, e.target)
+				done[i], changed = true, true
+			}
+		}
+	}
+	return b.g
+}
+
+// crossedTo goto 从 from 跳转到 to 时经过的 finally，从内到外
+func crossedTo(from, to []*finally) []*finally {
+	common := 0
+	for common < len(from) && common < len(to) && from[common] == to[common] {
+		common++
+	}
+	var result []*finally
+	for i := len(from) - 1; i >= common; i-- {
+		result = append(result, from[i])
+	}
+	return result
+}
+
+func (g *Graph) markReachable() {
+	for _, block := range g.Blocks {
+		block.reachable = false
+	}
+	stack := []*Block{g.Entry}
+	g.Entry.reachable = true
+	for len(stack) > 0 {
+		block := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		for _, succ := range block.Succs {
+			if !succ.reachable {
+				succ.reachable = true
+				stack = append(stack, succ)
+			}
+		}
+	}
+}
+
+func (b *builder) params(params []*ir.Param) {
+	for _, p := range params {
+		b.add(p)
+	}
+}
+
+func (b *builder) stmts(list []ir.Stmt) {
+	for _, stmt := range list {
+		b.stmt(stmt)
+	}
+}
+
+func (b *builder) stmt(stmt ir.Stmt) {
+	if _, ok := stmt.(*ir.LabelStmt); !ok {
+		b.g.stmts[stmt] = b.cur
+	}
+
+	switch x := stmt.(type) {
+	case *ir.BlockStmt:
+		b.stmts(x.List)
+	case *ir.LabelStmt:
+		l := b.label(x.Name.Name)
+		l.finallys = append([]*finally(nil), b.finallys...)
+		b.next(l.block)
+		b.g.stmts[stmt] = b.cur
+	case *ir.GotoStmt:
+		b.add(x)
+		b.gotos = append(b.gotos, gotoStmt{
+			from:     b.cur,
+			name:     x.Name.Name,
+			finallys: append([]*finally(nil), b.finallys...),
+		})
+		b.dead()
+	case *ir.ReturnStmt:
+		b.add(x)
+		b.jump(b.g.Exit, b.crossed(0))
+	case *ir.BreakStmt:
+		b.add(x)
+		if l, ok := b.loop(x.Num); ok {
+			b.jump(l.brk, b.crossed(l.finallys))
+		} else {
+			b.jump(b.g.Exit, nil)
+		}
+	case *ir.ContinueStmt:
+		b.add(x)
+		if l, ok := b.loop(x.Num); ok {
+			b.jump(l.cont, b.crossed(l.finallys))
+		} else {
+			b.jump(b.g.Exit, nil)
+		}
+	case *ir.ExprStmt:
+		b.add(x)
+		switch x.Expr.(type) {
+		case *ir.ThrowExpr:
+			if b.handler != nil {
+				b.jump(b.handler, nil)
+			} else {
+				b.jump(b.g.Exit, nil)
+			}
+		case *ir.ExitExpr:
+			// exit 不执行 finally
+			b.jump(b.g.Exit, nil)
+		}
+	case *ir.IfStmt:
+		b.ifStmt(x)
+	case *ir.SwitchStmt:
+		b.switchStmt(x)
+	case *ir.WhileStmt:
+		head := b.newBlock()
+		b.next(head)
+		b.add(x.Cond)
+		b.loopBody(head, !isTrue(x.Cond), x.Stmts, nil)
+	case *ir.DoStmt:
+		body, cond, after := b.newBlock(), b.newBlock(), b.newBlock()
+		b.next(body)
+		b.loops = append(b.loops, loop{brk: after, cont: cond, finallys: len(b.finallys)})
+		b.stmts(x.Stmts)
+		b.loops = b.loops[:len(b.loops)-1]
+		b.next(cond)
+		b.add(x.Cond)
+		b.edge(b.cur, body)
+		if !isTrue(x.Cond) {
+			b.edge(b.cur, after)
+		}
+		b.cur = after
+	case *ir.ForStmt:
+		for _, e := range x.Init {
+			b.add(e)
+		}
+		head := b.newBlock()
+		b.next(head)
+		for _, e := range x.Cond {
+			b.add(e)
+		}
+		// 多个条件表达式以最后一个为准，没有条件时为无限循环
+		exit := len(x.Cond) > 0 && !isTrue(x.Cond[len(x.Cond)-1])
+		b.loopBody(head, exit, x.Stmts, x.Loop)
+	case *ir.ForeachStmt:
+		b.add(x.Expr)
+		head := b.newBlock()
+		b.next(head)
+		after := b.newBlock()
+		b.edge(head, after)
+		b.next(b.newBlock())
+		b.add(x)
+		b.loops = append(b.loops, loop{brk: after, cont: head, finallys: len(b.finallys)})
+		b.stmts(x.Stmts)
+		b.loops = b.loops[:len(b.loops)-1]
+		b.next(head)
+		b.cur = after
+	case *ir.TryCatchStmt:
+		b.tryStmt(x)
+	default:
+		b.add(stmt)
+	}
+}
+
+// loopBody 构建循环体。当前块为条件判断所在块，条件不成立时(exit 为 true)跳出循环；
+// 循环体执行完及 continue 时执行 for 的循环表达式 loopExprs 后回到 head
+func (b *builder) loopBody(head *Block, exit bool, stmts []ir.Stmt, loopExprs []ir.Expr) {
+	after := b.newBlock()
+	if exit {
+		b.edge(b.cur, after)
+	}
+	cont := head
+	if len(loopExprs) > 0 {
+		cont = b.newBlock()
+	}
+	b.next(b.newBlock())
+	b.loops = append(b.loops, loop{brk: after, cont: cont, finallys: len(b.finallys)})
+	b.stmts(stmts)
+	b.loops = b.loops[:len(b.loops)-1]
+	if cont != head {
+		b.next(cont)
+		for _, e := range loopExprs {
+			b.add(e)
+		}
+	}
+	b.next(head)
+	b.cur = after
+}
+
+func (b *builder) ifStmt(x *ir.IfStmt) {
+	var ends []*Block
+	branch := func(cond ir.Expr, stmts []ir.Stmt) {
+		b.add(cond)
+		test := b.cur
+		b.next(b.newBlock())
+		b.stmts(stmts)
+		ends = append(ends, b.cur)
+		b.cur = b.newBlock()
+		b.edge(test, b.cur)
+	}
+	branch(x.Cond, x.Stmts)
+	for _, elseif := range x.Elseifs {
+		b.g.stmts[elseif] = b.cur
+		branch(elseif.Cond, elseif.Stmts)
+	}
+	if x.Else != nil {
+		b.g.stmts[x.Else] = b.cur
+		b.stmts(x.Else.Stmts)
+	}
+	after := b.newBlock()
+	b.next(after)
+	for _, end := range ends {
+		b.edge(end, after)
+	}
+}
+
+func (b *builder) switchStmt(x *ir.SwitchStmt) {
+	b.add(x.Cond)
+	after := b.newBlock()
+	bodies := make([]*Block, len(x.Cases))
+	for i := range bodies {
+		bodies[i] = b.newBlock()
+	}
+
+	// 依次比较各 case 的条件，都不匹配时进入 default
+	var dflt *Block
+	for i, c := range x.Cases {
+		if c.Cond == nil {
+			dflt = bodies[i]
+			continue
+		}
+		b.next(b.newBlock())
+		b.add(c.Cond)
+		b.edge(b.cur, bodies[i])
+	}
+	if dflt != nil {
+		b.edge(b.cur, dflt)
+	} else {
+		b.edge(b.cur, after)
+	}
+
+	// case 依次贯穿，switch 对 continue 视为循环
+	b.loops = append(b.loops, loop{brk: after, cont: after, finallys: len(b.finallys)})
+	b.dead()
+	for i, c := range x.Cases {
+		b.next(bodies[i])
+		b.g.stmts[c] = b.cur
+		b.stmts(c.Stmts)
+	}
+	b.loops = b.loops[:len(b.loops)-1]
+	b.next(after)
+}
+
+func (b *builder) tryStmt(x *ir.TryCatchStmt) {
+	outer := b.handler
+	var fin *finally
+	if x.Finally != nil {
+		fin = &finally{entry: b.newBlock()}
+		b.finallys = append(b.finallys, fin)
+	}
+	after := b.newBlock()
+	// 执行完 try 或 catch 后进入 finally 或 try 之后的语句
+	done := func() {
+		if fin != nil {
+			b.jump(after, []*finally{fin})
+		} else {
+			b.jump(after, nil)
+		}
+	}
+
+	// 异常分发块：匹配的 catch，或都不匹配时进入 finally 或外层的异常处理
+	dispatch := b.newBlock()
+	b.handler = dispatch
+	b.stmts(x.Stmts)
+	done()
+
+	rethrow := outer
+	if rethrow == nil {
+		rethrow = b.g.Exit
+	}
+	b.handler = outer
+	if fin != nil {
+		b.handler = fin.entry
+		b.edge(dispatch, b.through(dispatch, rethrow, []*finally{fin}))
+	} else {
+		b.edge(dispatch, rethrow)
+	}
+	for _, c := range x.Catches {
+		b.cur = b.newBlock()
+		b.edge(dispatch, b.cur)
+		b.g.stmts[c] = b.cur
+		b.cur.Nodes = append(b.cur.Nodes, c)
+		b.collect(c)
+		b.stmts(c.Stmts)
+		done()
+	}
+	b.handler = outer
+
+	if fin != nil {
+		b.finallys = b.finallys[:len(b.finallys)-1]
+		b.cur = fin.entry
+		b.g.stmts[x.Finally] = b.cur
+		b.stmts(x.Finally.Stmts)
+		fin.exit = b.cur
+	}
+	b.cur = after
+}
+
+// loop break/continue 的目标循环，num 为 nil 时为最内层
+func (b *builder) loop(num ir.Expr) (loop, bool) {
+	level := 1
+	if lit, ok := num.(*ir.IntLit); ok {
+		level = lit.Value
+	}
+	if level < 1 || level > len(b.loops) {
+		return loop{}, false
+	}
+	return b.loops[len(b.loops)-level], true
+}
+
+func (b *builder) label(name string) *label {
+	l := b.labels[name]
+	if l == nil {
+		l = &label{block: b.newBlock()}
+		b.labels[name] = l
+	}
+	return l
+}
+
+// isTrue 条件是否恒为 true，恒为 true 的循环条件不产生跳出循环的边
+func isTrue(e ir.Expr) bool {
+	lit, ok := e.(*ir.BoolLit)
+	return ok && lit.Value
+}
@@ -0,0 +1,436 @@
+package flow
+
+import (
+	"github.com/heyuuu/gophp/compile/ir"
+	"github.com/heyuuu/gophp/compile/parser"
+	"github.com/heyuuu/gophp/compile/transformer"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// funcGraph 构建代码中函数 f 的控制流图，code 从第 2 行开始
+func funcGraph(t *testing.T, code string) (*ir.FunctionStmt, *Graph) {
+	astFile, err := parser.ParseCode("<?php\n" + code)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file, err := transformer.TransformFile(astFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, ns := range file.Namespaces {
+		for _, stmt := range ns.Stmts {
+			if fn, ok := stmt.(*ir.FunctionStmt); ok && fn.Name.Name == "f" {
+				return fn, Func(fn)
+			}
+		}
+	}
+	t.Fatal("function f not found")
+	return nil, nil
+}
+
+// findRefs 控制流图中指定类型的变量访问，按源码位置排序
+func findRefs(g *Graph, kind RefKind, name string) []*Ref {
+	var result []*Ref
+	for _, refs := range g.refs {
+		for _, ref := range refs {
+			if ref.Kind == kind && ref.Name == name {
+				result = append(result, ref)
+			}
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Var.Pos().Offset < result[j].Var.Pos().Offset
+	})
+	return result
+}
+
+func refLines(refs []*Ref) []int {
+	var result []int
+	for _, ref := range refs {
+		result = append(result, ref.Var.Pos().Line)
+	}
+	sort.Ints(result)
+	return result
+}
+
+// echoReachable 函数体内各 echo 语句所在行是否可达
+func echoReachable(fn *ir.FunctionStmt, g *Graph) map[int]bool {
+	result := map[int]bool{}
+	ir.InspectList(fn.Stmts, func(n ir.Node) bool {
+		if echo, ok := n.(*ir.EchoStmt); ok {
+			result[echo.Pos().Line] = g.StmtBlock(echo).Reachable()
+		}
+		return true
+	})
+	return result
+}
+
+func TestReachable(t *testing.T) {
+	tests := []struct {
+		name string
+		code string
+		want map[int]bool // echo 所在行是否可达
+	}{
+		{
+			name: "return",
+			code: `function f() {
+    echo 1;
+    return;
+    echo 2;
+}`,
+			want: map[int]bool{3: true, 5: false},
+		},
+		{
+			name: "break levels",
+			code: `function f() {
+    while (true) {
+        while (true) {
+            break 2;
+        }
+        echo 1;
+    }
+    echo 2;
+}`,
+			want: map[int]bool{7: false, 9: true},
+		},
+		{
+			name: "infinite loop",
+			code: `function f() {
+    for (;;) {
+        echo 1;
+    }
+    echo 2;
+}`,
+			want: map[int]bool{4: true, 6: false},
+		},
+		{
+			name: "continue in switch",
+			code: `function f($x) {
+    switch ($x) {
+        case 1:
+            continue;
+            echo 1;
+        default:
+            echo 2;
+    }
+    echo 3;
+}`,
+			want: map[int]bool{6: false, 8: true, 10: true},
+		},
+		{
+			name: "goto",
+			code: `function f() {
+    goto end;
+    echo 1;
+    end:
+    echo 2;
+}`,
+			want: map[int]bool{4: false, 6: true},
+		},
+		{
+			name: "finally",
+			code: `function f() {
+    try {
+        return;
+    } finally {
+        echo 1;
+    }
+    echo 2;
+}`,
+			want: map[int]bool{6: true, 8: false},
+		},
+		{
+			name: "throw and exit",
+			code: `function f($x) {
+    if ($x) {
+        throw new Exception();
+        echo 1;
+    } else {
+        exit(1);
+        echo 2;
+    }
+    echo 3;
+}`,
+			want: map[int]bool{5: false, 8: false, 10: false},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fn, g := funcGraph(t, tt.code)
+			if got := echoReachable(fn, g); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("reachable = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDefUse(t *testing.T) {
+	tests := []struct {
+		name      string
+		code      string
+		defs      []int // 最后一次读取 $a 时可能读到的写入所在行
+		undefined bool  // 最后一次读取 $a 时是否可能未定义
+	}{
+		{
+			name: "overwrite",
+			code: `function f() {
+    $a = 1;
+    $a = 2;
+    echo $a;
+}`,
+			defs: []int{4},
+		},
+		{
+			name: "branch",
+			code: `function f($x) {
+    if ($x) {
+        $a = 1;
+    } elseif ($x > 1) {
+        $a = 2;
+    }
+    echo $a;
+}`,
+			defs:      []int{4, 6},
+			undefined: true,
+		},
+		{
+			name: "loop",
+			code: `function f() {
+    $a = 0;
+    while ($a < 10) {
+        $a++;
+    }
+}`,
+			defs: []int{3, 5},
+		},
+		{
+			name: "switch fall through",
+			code: `function f($x) {
+    switch ($x) {
+        case 1:
+            $a = 1;
+        case 2:
+            echo $a;
+    }
+}`,
+			defs:      []int{5},
+			undefined: true,
+		},
+		{
+			name: "catch",
+			code: `function f() {
+    try {
+        $a = 1;
+        g();
+        $a = 2;
+    } catch (Exception $e) {
+        echo $a;
+    }
+}`,
+			defs:      []int{4},
+			undefined: true,
+		},
+		{
+			name: "finally after return",
+			code: `function f($x) {
+    $a = 1;
+    try {
+        if ($x) {
+            $a = 2;
+            return;
+        }
+        $a = 3;
+    } finally {
+        echo $a;
+    }
+}`,
+			defs: []int{3, 6, 9},
+		},
+		{
+			name: "break through finally",
+			code: `function f() {
+    while (true) {
+        try {
+            $a = 1;
+            break;
+        } finally {
+            $b = 1;
+        }
+    }
+    echo $a;
+}`,
+			// finally 合并了所有经过它的路径，$a = 1 前抛出异常的路径也会到达循环之后
+			defs:      []int{5},
+			undefined: true,
+		},
+		{
+			name: "partial and conditional writes",
+			code: `function f($x) {
+    $a = [];
+    $a[] = 1;
+    $x && $a = 2;
+    g($a);
+    echo $a;
+}`,
+			defs: []int{3, 4, 5, 6},
+		},
+		{
+			name: "unset",
+			code: `function f() {
+    $a = 1;
+    unset($a);
+    echo $a;
+}`,
+			undefined: true,
+		},
+		{
+			name: "isset and coalesce",
+			code: `function f() {
+    if (isset($a)) {
+        $a = 1;
+    }
+    $a ??= 2;
+    echo $a;
+}`,
+			defs:      []int{4, 6},
+			undefined: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, g := funcGraph(t, tt.code)
+			chains := g.DefUse()
+			var uses []*Ref
+			for _, kind := range []RefKind{Use, Arg} {
+				uses = append(uses, findRefs(g, kind, "a")...)
+			}
+			sort.Slice(uses, func(i, j int) bool { return uses[i].Var.Pos().Offset < uses[j].Var.Pos().Offset })
+			use := uses[len(uses)-1]
+			if got := refLines(chains.Defs(use)); !reflect.DeepEqual(got, tt.defs) {
+				t.Errorf("Defs() = %v, want %v", got, tt.defs)
+			}
+			if got := chains.MaybeUndefined(use); got != tt.undefined {
+				t.Errorf("MaybeUndefined() = %v, want %v", got, tt.undefined)
+			}
+		})
+	}
+}
+
+func TestLiveness(t *testing.T) {
+	fn, g := funcGraph(t, `function f($p, $q) {
+    $a = 1;
+    $a = $p;
+    $b = $a;
+    for ($i = 0; $i < 3; $i++) {
+        echo $b;
+    }
+    return $q;
+}`)
+	live := g.Liveness()
+	if got := live.In(g.Entry); len(got) != 0 {
+		t.Errorf("In(Entry) = %v, want none", got)
+	}
+
+	tests := []struct {
+		name string
+		line int
+		want bool
+	}{
+		{"a", 3, false},
+		{"a", 4, true},
+		{"b", 5, true},
+		{"i", 6, true},
+		{"p", 2, true},
+		{"q", 2, true},
+	}
+	for _, tt := range tests {
+		var def *Ref
+		for _, ref := range findRefs(g, Def, tt.name) {
+			if ref.Var.Pos().Line == tt.line {
+				def = ref
+			}
+		}
+		if def == nil {
+			t.Fatalf("def of $%s at line %d not found", tt.name, tt.line)
+		}
+		if got := live.LiveAfter(def); got != tt.want {
+			t.Errorf("LiveAfter($%s at line %d) = %v, want %v", tt.name, tt.line, got, tt.want)
+		}
+	}
+
+	chains := g.DefUse()
+	if uses := chains.Uses(findRefs(g, Def, "a")[0]); len(uses) != 0 {
+		t.Errorf("Uses(first $a) = %v, want none", refLines(uses))
+	}
+	if got := g.StmtBlock(fn.Stmts[0]); got == nil || !got.Reachable() {
+		t.Errorf("StmtBlock(first stmt) = %v, want reachable block", got)
+	}
+}
+
+func TestGraphFlags(t *testing.T) {
+	tests := []struct {
+		name    string
+		code    string
+		dynamic bool
+		escaped []string
+	}{
+		{
+			name: "plain",
+			code: `function f($a) { $b = $a; return $b; }`,
+		},
+		{
+			name:    "variable variable",
+			code:    `function f($a) { $$a = 1; }`,
+			dynamic: true,
+		},
+		{
+			name:    "extract",
+			code:    `function f($a) { extract($a); }`,
+			dynamic: true,
+		},
+		{
+			name:    "references",
+			code:    `function f(&$a, $b) { global $g; static $s = 0; $c = &$b; $fn = function () use (&$d) {}; foreach ($a as &$v) {} }`,
+			escaped: []string{"a", "b", "c", "d", "g", "s", "v"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, g := funcGraph(t, tt.code)
+			if g.Dynamic != tt.dynamic {
+				t.Errorf("Dynamic = %v, want %v", g.Dynamic, tt.dynamic)
+			}
+			var escaped []string
+			for _, name := range g.Names() {
+				if g.Escaped(name) {
+					escaped = append(escaped, name)
+				}
+			}
+			sort.Strings(escaped)
+			if !reflect.DeepEqual(escaped, tt.escaped) {
+				t.Errorf("Escaped = %v, want %v", escaped, tt.escaped)
+			}
+		})
+	}
+}
+
+func TestArrowFunction(t *testing.T) {
+	_, g := funcGraph(t, `function f($x) {
+    $a = 1;
+    return fn($y) => $a + $y + $x + (fn() => $a + $b)();
+}`)
+	var captured []string
+	for _, ref := range g.refs {
+		for _, r := range ref {
+			if r.Kind == Use {
+				captured = append(captured, r.Name)
+			}
+		}
+	}
+	sort.Strings(captured)
+	if want := []string{"a", "b", "x"}; !reflect.DeepEqual(captured, want) {
+		t.Errorf("captured = %v, want %v", captured, want)
+	}
+}
@@ -0,0 +1,459 @@
+package flow
+
+import (
+	"github.com/heyuuu/gophp/compile/ast"
+	"github.com/heyuuu/gophp/compile/ir"
+	"strings"
+)
+
+// RefKind 变量访问的类型
+type RefKind uint8
+
+const (
+	Use    RefKind = iota // 读取变量，变量未定义时产生 notice
+	Isset                 // isset、empty 及 ?? 左侧的读取，变量未定义时不产生 notice
+	Def                   // 赋值，覆盖变量原来的值
+	MayDef                // 可能写入或只修改变量的一部分，如数组元素赋值、=&、短路运算中的赋值
+	Arg                   // 作为函数或方法的实参，形参按引用传递时为写入，否则为读取
+	Unset                 // unset，变量变为未定义
+)
+
+// Reads 是否读取变量的值
+func (k RefKind) Reads() bool { return k == Use || k == Isset || k == Arg }
+
+// Writes 是否可能改变变量的值
+func (k RefKind) Writes() bool { return k == Def || k == MayDef || k == Arg || k == Unset }
+
+// Kills 是否一定覆盖变量原来的值
+func (k RefKind) Kills() bool { return k == Def || k == Unset }
+
+func (k RefKind) String() string {
+	switch k {
+	case Use:
+		return "use"
+	case Isset:
+		return "isset"
+	case Def:
+		return "def"
+	case MayDef:
+		return "maydef"
+	case Arg:
+		return "arg"
+	case Unset:
+		return "unset"
+	default:
+		return "unknown"
+	}
+}
+
+// Ref 节点内对变量的一次访问
+type Ref struct {
+	Kind  RefKind
+	Name  string           // 变量名，不含 $
+	Var   *ir.VariableExpr // 访问的变量表达式；函数入口处未定义的伪定值为 nil
+	Node  ir.Node          // 访问所在的基本块节点
+	Block *Block           // 访问所在的基本块
+}
+
+// dynamicFuncs 按名称访问当前符号表的函数
+var dynamicFuncs = map[string]bool{
+	"compact":          true,
+	"extract":          true,
+	"get_defined_vars": true,
+	"parse_str":        true,
+	"mb_parse_str":     true,
+}
+
+// collector 收集节点内的变量访问
+type collector struct {
+	g     *Graph
+	node  ir.Node
+	block *Block
+	refs  []*Ref
+	maybe int // 位于短路运算或三元运算的分支内，赋值不一定执行
+}
+
+// collect 收集基本块节点 n 内的变量访问
+func (b *builder) collect(n ir.Node) {
+	c := &collector{g: b.g, node: n, block: b.cur}
+	switch x := n.(type) {
+	case *ir.Param:
+		c.ref(x.Var, Def)
+		if x.ByRef {
+			c.escape(x.Var)
+		}
+	case *ir.ClosureUseExpr:
+		c.ref(x.Var, Def)
+		if x.ByRef {
+			c.escape(x.Var)
+		}
+	case *ir.ForeachStmt:
+		if x.KeyVar != nil {
+			c.write(x.KeyVar, Def)
+		}
+		c.write(x.ValueVar, Def)
+		if x.ByRef {
+			c.escapeTarget(x.ValueVar)
+		}
+	case *ir.CatchStmt:
+		if x.Var != nil {
+			c.ref(x.Var, Def)
+		}
+	case ir.Expr:
+		c.expr(x)
+	case ir.Stmt:
+		c.stmt(x)
+	}
+	if len(c.refs) > 0 {
+		b.g.refs[n] = c.refs
+	}
+}
+
+func (c *collector) stmt(stmt ir.Stmt) {
+	switch x := stmt.(type) {
+	case *ir.ExprStmt:
+		c.expr(x.Expr)
+	case *ir.ReturnStmt:
+		c.expr(x.Expr)
+	case *ir.EchoStmt:
+		for _, e := range x.Exprs {
+			c.expr(e)
+		}
+	case *ir.GlobalStmt:
+		c.write(x.Var, Def)
+		c.escapeTarget(x.Var)
+	case *ir.StaticStmt:
+		c.expr(x.Default)
+		c.ref(x.Var, Def)
+		c.escape(x.Var)
+	case *ir.UnsetStmt:
+		c.write(x.Var, Unset)
+	}
+}
+
+func (c *collector) ref(v *ir.VariableExpr, kind RefKind) {
+	name, ok := v.Name.(*ir.Ident)
+	if !ok {
+		// 可变变量
+		c.g.Dynamic = true
+		c.expr(v.Name.(ir.Expr))
+		return
+	}
+	if kind == Def && c.maybe > 0 {
+		kind = MayDef
+	}
+	if !c.g.hasName(name.Name) {
+		c.g.names = append(c.g.names, name.Name)
+	}
+	c.refs = append(c.refs, &Ref{Kind: kind, Name: name.Name, Var: v, Node: c.node, Block: c.block})
+}
+
+func (g *Graph) hasName(name string) bool {
+	for _, n := range g.names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *collector) escape(v *ir.VariableExpr) {
+	if name, ok := v.Name.(*ir.Ident); ok {
+		c.g.escaped[name.Name] = true
+	}
+}
+
+// escapeTarget 被引用的变量，数组元素或属性被引用时其所在的变量也视为被引用
+func (c *collector) escapeTarget(e ir.Expr) {
+	if v := rootVar(e); v != nil {
+		c.escape(v)
+	}
+}
+
+// rootVar 变量、数组元素或静态属性以外的属性所在的变量
+func rootVar(e ir.Expr) *ir.VariableExpr {
+	for {
+		switch x := e.(type) {
+		case *ir.VariableExpr:
+			return x
+		case *ir.IndexExpr:
+			e = x.Var
+		case *ir.PropertyFetchExpr:
+			e = x.Var
+		default:
+			return nil
+		}
+	}
+}
+
+// write 赋值目标。变量本身按 kind 记录；写入数组元素时变量只被部分修改，写入属性时读取变量中的对象
+func (c *collector) write(target ir.Expr, kind RefKind) {
+	switch x := target.(type) {
+	case *ir.VariableExpr:
+		c.ref(x, kind)
+	case *ir.IndexExpr:
+		c.expr(x.Dim)
+		if kind == Unset {
+			// unset($a[k]) 读取数组
+			c.expr(x.Var)
+		} else {
+			c.write(x.Var, MayDef)
+		}
+	case *ir.ListExpr:
+		c.list(x.Items, kind)
+	case *ir.ArrayExpr:
+		c.list(x.Items, kind)
+	default:
+		// 属性、静态属性等
+		c.expr(target)
+	}
+}
+
+// list 解构赋值 [$a, 'k' => $b] = ...
+func (c *collector) list(items []*ir.ArrayItemExpr, kind RefKind) {
+	for _, item := range items {
+		if item == nil {
+			continue
+		}
+		c.expr(item.Key)
+		c.write(item.Value, kind)
+		if item.ByRef {
+			c.escapeTarget(item.Value)
+		}
+	}
+}
+
+// silent isset、empty 及 ?? 左侧的读取，变量未定义时不产生 notice
+func (c *collector) silent(e ir.Expr) {
+	switch x := e.(type) {
+	case *ir.VariableExpr:
+		c.ref(x, Isset)
+	case *ir.IndexExpr:
+		c.silent(x.Var)
+		c.expr(x.Dim)
+	case *ir.PropertyFetchExpr:
+		c.silent(x.Var)
+		c.nameExpr(x.Name)
+	default:
+		c.expr(e)
+	}
+}
+
+// arg 实参，可以按引用传递的实参记录为 Arg
+func (c *collector) arg(e ir.Expr) {
+	switch x := e.(type) {
+	case *ir.VariableExpr:
+		c.ref(x, Arg)
+	case *ir.IndexExpr:
+		c.expr(x.Dim)
+		c.arg(x.Var)
+	default:
+		c.expr(e)
+	}
+}
+
+func (c *collector) args(args []*ir.Arg) {
+	for _, a := range args {
+		c.arg(a.Value)
+	}
+}
+
+// nameExpr 可能为表达式的位置，如 Name|Expr
+func (c *collector) nameExpr(n ir.Node) {
+	if e, ok := n.(ir.Expr); ok {
+		c.expr(e)
+	}
+}
+
+func (c *collector) maybeExpr(e ir.Expr) {
+	c.maybe++
+	c.expr(e)
+	c.maybe--
+}
+
+func (c *collector) expr(e ir.Expr) {
+	if e == nil {
+		return
+	}
+	switch x := e.(type) {
+	case *ir.VariableExpr:
+		c.ref(x, Use)
+	case *ir.AssignExpr:
+		c.expr(x.Expr)
+		c.write(x.Var, Def)
+	case *ir.AssignOpExpr:
+		if x.Op == ast.AssignOpCoalesce {
+			c.silent(x.Var)
+			c.maybe++
+			c.expr(x.Expr)
+			c.write(x.Var, Def)
+			c.maybe--
+			return
+		}
+		if v, ok := x.Var.(*ir.VariableExpr); ok {
+			c.expr(x.Expr)
+			c.ref(v, Use)
+			c.ref(v, Def)
+			return
+		}
+		c.expr(x.Expr)
+		c.write(x.Var, MayDef)
+	case *ir.AssignRefExpr:
+		switch x.Expr.(type) {
+		case *ir.VariableExpr, *ir.IndexExpr, *ir.PropertyFetchExpr:
+			c.write(x.Expr, MayDef)
+			c.escapeTarget(x.Expr)
+		default:
+			c.expr(x.Expr)
+		}
+		c.write(x.Var, Def)
+		c.escapeTarget(x.Var)
+	case *ir.UnaryExpr:
+		if isIncDec(x.Op) {
+			if v, ok := x.Var.(*ir.VariableExpr); ok {
+				c.ref(v, Use)
+				c.ref(v, Def)
+			} else {
+				c.write(x.Var, MayDef)
+			}
+			return
+		}
+		c.expr(x.Var)
+	case *ir.BinaryOpExpr:
+		switch x.Op {
+		case ast.BinaryOpBooleanAnd, ast.BinaryOpBooleanOr:
+			c.expr(x.Left)
+			c.maybeExpr(x.Right)
+		case ast.BinaryOpCoalesce:
+			c.silent(x.Left)
+			c.maybeExpr(x.Right)
+		default:
+			c.expr(x.Left)
+			c.expr(x.Right)
+		}
+	case *ir.TernaryExpr:
+		c.expr(x.Cond)
+		c.maybeExpr(x.If)
+		c.maybeExpr(x.Else)
+	case *ir.MatchExpr:
+		// 分支条件依次比较直到匹配，只有匹配的分支会执行
+		c.expr(x.Cond)
+		for _, arm := range x.Arms {
+			for _, cond := range arm.Conds {
+				c.maybeExpr(cond)
+			}
+			c.maybeExpr(arm.Body)
+		}
+	case *ir.IssetExpr:
+		for _, v := range x.Vars {
+			c.silent(v)
+		}
+	case *ir.EmptyExpr:
+		c.silent(x.Expr)
+	case *ir.EvalExpr:
+		c.g.Dynamic = true
+		c.expr(x.Expr)
+	case *ir.IncludeExpr:
+		c.g.Dynamic = true
+		c.expr(x.Expr)
+	case *ir.FuncCallExpr:
+		if name, ok := x.Name.(*ir.Name); ok {
+			if dynamicFuncs[strings.ToLower(name.Parts[len(name.Parts)-1])] {
+				c.g.Dynamic = true
+			}
+		} else {
+			c.nameExpr(x.Name)
+		}
+		c.args(x.Args)
+	case *ir.MethodCallExpr:
+		c.expr(x.Var)
+		c.nameExpr(x.Name)
+		if x.Nullsafe {
+			c.maybe++
+			defer func() { c.maybe-- }()
+		}
+		c.args(x.Args)
+	case *ir.StaticCallExpr:
+		c.nameExpr(x.Class)
+		c.nameExpr(x.Name)
+		c.args(x.Args)
+	case *ir.NewExpr:
+		// 匿名类的类体不展开
+		c.nameExpr(x.Class)
+		c.args(x.Args)
+	case *ir.ClosureExpr:
+		for _, use := range x.Uses {
+			if use.ByRef {
+				c.ref(use.Var, MayDef)
+				c.escape(use.Var)
+			} else {
+				c.ref(use.Var, Use)
+			}
+		}
+	case *ir.ArrowFunctionExpr:
+		c.arrowFunc(x, nil)
+	default:
+		// 其它表达式依次读取直接子表达式
+		ir.Inspect(e, func(n ir.Node) bool {
+			if n == e {
+				return true
+			}
+			if child, ok := n.(ir.Expr); ok {
+				c.expr(child)
+			}
+			return false
+		})
+	}
+}
+
+// arrowFunc 箭头函数按值捕获函数体内用到的外部变量，bound 为嵌套的箭头函数中外层箭头函数的参数
+func (c *collector) arrowFunc(fn *ir.ArrowFunctionExpr, bound map[string]bool) {
+	inner := map[string]bool{}
+	for name := range bound {
+		inner[name] = true
+	}
+	for _, p := range fn.Params {
+		if name, ok := p.Var.Name.(*ir.Ident); ok {
+			inner[name.Name] = true
+		}
+	}
+	ir.Inspect(fn.Expr, func(n ir.Node) bool {
+		switch x := n.(type) {
+		case *ir.ArrowFunctionExpr:
+			c.arrowFunc(x, inner)
+			return false
+		case *ir.ClosureExpr:
+			for _, use := range x.Uses {
+				c.captured(use.Var, inner)
+			}
+			return false
+		case *ir.ClassStmt:
+			return false
+		case *ir.VariableExpr:
+			c.captured(x, inner)
+		}
+		return true
+	})
+}
+
+// captured 箭头函数捕获的变量，同一变量只记录一次
+func (c *collector) captured(v *ir.VariableExpr, bound map[string]bool) {
+	name, ok := v.Name.(*ir.Ident)
+	if !ok {
+		c.g.Dynamic = true
+		return
+	}
+	if bound[name.Name] {
+		return
+	}
+	bound[name.Name] = true
+	c.ref(v, Use)
+}
+
+func isIncDec(op ast.UnaryOpKind) bool {
+	switch op {
+	case ast.UnaryOpPreInc, ast.UnaryOpPreDec, ast.UnaryOpPostInc, ast.UnaryOpPostDec:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,737 @@
+package infer
+
+import (
+	"github.com/heyuuu/gophp/compile/ast"
+	"github.com/heyuuu/gophp/compile/ir"
+	"slices"
+	"strings"
+)
+
+// analyzer 推导单个函数体
+type analyzer struct {
+	scope *fileScope
+	stmts []ir.Stmt
+
+	dynamic  bool                      // 函数体按名称访问符号表，所有变量都保留在符号表中
+	order    []string                  // 变量按首次出现的顺序
+	excluded map[string]bool           // 必须保留在符号表中的变量
+	reads    map[string]bool           // 被读取过的变量
+	writes   map[string][]ir.Node      // 变量的赋值，AssignExpr、AssignOpExpr、UnaryExpr(++/--) 或 ForeachStmt
+	targets  map[*ir.VariableExpr]bool // 作为赋值目标的变量，不计为读取
+	params   map[string]Type           // 参数声明的类型
+
+	guards map[*ir.UnaryExpr]ir.Expr // 循环条件保证不会溢出的 ++/--，值为循环条件中的边界
+	env    map[string]Type           // 变量的类型，推导完成前可能为 undefined
+}
+
+func analyze(scope *fileScope, params []*ir.Param, uses []*ir.ClosureUseExpr, stmts []ir.Stmt) *Func {
+	a := &analyzer{
+		scope:    scope,
+		stmts:    stmts,
+		excluded: map[string]bool{},
+		reads:    map[string]bool{},
+		writes:   map[string][]ir.Node{},
+		targets:  map[*ir.VariableExpr]bool{},
+		params:   map[string]Type{},
+		guards:   map[*ir.UnaryExpr]ir.Expr{},
+		env:      map[string]Type{},
+	}
+
+	for _, p := range params {
+		name, _ := varName(p.Var)
+		a.see(name)
+		a.params[name] = a.paramType(p)
+		if p.ByRef {
+			a.excluded[name] = true
+		}
+	}
+	// 闭包绑定的变量在调用时写入符号表
+	for _, use := range uses {
+		a.exclude(use.Var)
+	}
+
+	ir.InspectList(stmts, a.scan)
+	a.findGuards()
+	a.solve()
+	return a.result()
+}
+
+func (a *analyzer) paramType(p *ir.Param) Type {
+	if p.Variadic {
+		return Array
+	}
+	typ := scalarType(p.Type)
+	if p.Default != nil {
+		// 默认值为 null 时参数隐式可空；默认值类型不符时运行时不会转换
+		defaultType := a.exprType(p.Default)
+		if defaultType != typ && !(typ == Float && defaultType == Int) {
+			return Unknown
+		}
+	}
+	return typ
+}
+
+func (a *analyzer) see(name string) {
+	if _, ok := a.env[name]; !ok {
+		a.env[name] = undefined
+		a.order = append(a.order, name)
+	}
+}
+
+func (a *analyzer) exclude(v *ir.VariableExpr) {
+	if name, ok := varName(v); ok {
+		a.excluded[name] = true
+	}
+}
+
+// excludeRoot 排除下标或属性访问的根变量，如 $a[0]->b 中的 $a
+func (a *analyzer) excludeRoot(e ir.Expr) {
+	if v := rootVar(e); v != nil {
+		a.exclude(v)
+	}
+}
+
+func rootVar(e ir.Expr) *ir.VariableExpr {
+	for {
+		switch x := e.(type) {
+		case *ir.VariableExpr:
+			return x
+		case *ir.IndexExpr:
+			e = x.Var
+		case *ir.PropertyFetchExpr:
+			e = x.Var
+		default:
+			return nil
+		}
+	}
+}
+
+// write 记录赋值。赋值目标为下标或属性时根变量被修改，排除根变量
+func (a *analyzer) write(target ir.Expr, n ir.Node) {
+	v, ok := target.(*ir.VariableExpr)
+	if !ok {
+		a.excludeRoot(target)
+		return
+	}
+	a.targets[v] = true
+	if name, ok := varName(v); ok {
+		a.writes[name] = append(a.writes[name], n)
+	}
+}
+
+// scan 收集函数体内变量的读写，不进入嵌套的函数、类及闭包
+func (a *analyzer) scan(n ir.Node) bool {
+	switch x := n.(type) {
+	case *ir.FunctionStmt, *ir.ClassStmt, *ir.InterfaceStmt, *ir.TraitStmt:
+		return false
+	case *ir.ClosureExpr:
+		for _, use := range x.Uses {
+			a.exclude(use.Var)
+		}
+		return false
+	case *ir.ArrowFunctionExpr:
+		// 箭头函数按值绑定函数体内用到的所有外部变量
+		ir.Inspect(x.Expr, func(n ir.Node) bool {
+			if v, ok := n.(*ir.VariableExpr); ok {
+				a.exclude(v)
+			}
+			return true
+		})
+		return false
+	case *ir.VariableExpr:
+		name, ok := varName(x)
+		if !ok {
+			a.dynamic = true
+			return true
+		}
+		a.see(name)
+		if !a.targets[x] {
+			a.reads[name] = true
+		}
+	case *ir.EvalExpr, *ir.IncludeExpr, *ir.YieldExpr, *ir.YieldFromExpr, *ir.GotoStmt, *ir.LabelStmt:
+		a.dynamic = true
+	case *ir.FuncCallExpr:
+		if name, ok := x.Name.(*ir.Name); ok && dynamicFuncs[strings.ToLower(name.Parts[len(name.Parts)-1])] {
+			a.dynamic = true
+		}
+		fn, builtin := a.scope.callee(x)
+		a.checkArgs(x.Args, func(i int) bool {
+			switch {
+			case builtin != nil:
+				return false
+			case fn != nil:
+				return paramByRef(fn.Params, i)
+			default:
+				return true
+			}
+		})
+	case *ir.MethodCallExpr:
+		a.checkArgs(x.Args, nil)
+	case *ir.StaticCallExpr:
+		a.checkArgs(x.Args, nil)
+	case *ir.NewExpr:
+		a.checkArgs(x.Args, nil)
+	case *ir.AssignExpr:
+		a.write(x.Var, x)
+	case *ir.AssignOpExpr:
+		if x.Op == ast.AssignOpCoalesce {
+			a.excludeRoot(x.Var)
+		}
+		a.write(x.Var, x)
+	case *ir.UnaryExpr:
+		if isIncDec(x.Op) {
+			a.write(x.Var, x)
+		}
+	case *ir.AssignRefExpr:
+		a.excludeRoot(x.Var)
+		a.excludeRoot(x.Expr)
+	case *ir.ArrayItemExpr:
+		if x.ByRef {
+			a.excludeRoot(x.Value)
+		}
+	case *ir.ListExpr:
+		ir.Inspect(x, func(n ir.Node) bool {
+			if v, ok := n.(*ir.VariableExpr); ok {
+				a.exclude(v)
+			}
+			return true
+		})
+	case *ir.ForeachStmt:
+		if x.KeyVar != nil {
+			a.write(x.KeyVar, x)
+		}
+		a.write(x.ValueVar, x)
+		if x.ByRef {
+			a.excludeRoot(x.ValueVar)
+		}
+	case *ir.CatchStmt:
+		if x.Var != nil {
+			a.exclude(x.Var)
+		}
+	case *ir.GlobalStmt:
+		a.excludeRoot(x.Var)
+	case *ir.StaticStmt:
+		a.exclude(x.Var)
+	case *ir.UnsetStmt:
+		a.excludeRoot(x.Var)
+	case *ir.IssetExpr:
+		for _, v := range x.Vars {
+			a.excludeRoot(v)
+		}
+	case *ir.EmptyExpr:
+		a.excludeRoot(x.Expr)
+	case *ir.BinaryOpExpr:
+		if x.Op == ast.BinaryOpCoalesce {
+			a.excludeRoot(x.Left)
+		}
+	}
+	return true
+}
+
+// checkArgs 排除可能按引用传递的实参变量，byRef 为 nil 时所有参数都可能按引用传递
+func (a *analyzer) checkArgs(args []*ir.Arg, byRef func(i int) bool) {
+	for i, arg := range args {
+		v, ok := arg.Value.(*ir.VariableExpr)
+		if ok && (arg.Unpack || byRef == nil || byRef(i)) {
+			a.exclude(v)
+		}
+	}
+}
+
+func paramByRef(params []*ir.Param, i int) bool {
+	if i < len(params) {
+		return params[i].ByRef
+	}
+	return len(params) > 0 && params[len(params)-1].Variadic && params[len(params)-1].ByRef
+}
+
+func isIncDec(op ast.UnaryOpKind) bool {
+	switch op {
+	case ast.UnaryOpPreInc, ast.UnaryOpPreDec, ast.UnaryOpPostInc, ast.UnaryOpPostDec:
+		return true
+	}
+	return false
+}
+
+func isInc(op ast.UnaryOpKind) bool {
+	return op == ast.UnaryOpPreInc || op == ast.UnaryOpPostInc
+}
+
+// definitelyAssigned 变量在首次使用前必定已赋值
+func (a *analyzer) definitelyAssigned(name string) bool {
+	return assignedFirst(a.stmts, name)
+}
+
+// assignedFirst 语句列表中首个出现变量的语句对它赋值：语句为 $name = expr，或 for 语句的初始化表达式中首先对它赋值；
+// 或变量只出现在该语句的语句块(循环体、if 分支等)中，且出现变量的语句块均满足此条件。
+// 循环体每次执行都从头开始，首个语句赋值后的读取必定已赋值
+func assignedFirst(stmts []ir.Stmt, name string) bool {
+	for i, stmt := range stmts {
+		if !mentions(stmt, name) {
+			continue
+		}
+		switch x := stmt.(type) {
+		case *ir.ExprStmt:
+			return isInitAssign(x.Expr, name)
+		case *ir.ForStmt:
+			for _, init := range x.Init {
+				if mentions(init, name) {
+					return isInitAssign(init, name)
+				}
+			}
+		}
+
+		// 语句块可能不执行，之后的语句不能再使用变量
+		if slices.ContainsFunc(stmts[i+1:], func(s ir.Stmt) bool { return mentions(s, name) }) {
+			return false
+		}
+		blocks, ok := innerBlocks(stmt, name)
+		if !ok {
+			return false
+		}
+		for _, block := range blocks {
+			if slices.ContainsFunc(block, func(s ir.Stmt) bool { return mentions(s, name) }) && !assignedFirst(block, name) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// innerBlocks 语句的各个语句块，语句块以外的部分(条件等)出现变量时返回 false
+func innerBlocks(stmt ir.Stmt, name string) ([][]ir.Stmt, bool) {
+	anyMentions := func(exprs ...ir.Expr) bool {
+		return slices.ContainsFunc(exprs, func(e ir.Expr) bool { return mentions(e, name) })
+	}
+	switch x := stmt.(type) {
+	case *ir.BlockStmt:
+		return [][]ir.Stmt{x.List}, true
+	case *ir.IfStmt:
+		if anyMentions(x.Cond) {
+			return nil, false
+		}
+		blocks := [][]ir.Stmt{x.Stmts}
+		for _, elseif := range x.Elseifs {
+			if anyMentions(elseif.Cond) {
+				return nil, false
+			}
+			blocks = append(blocks, elseif.Stmts)
+		}
+		if x.Else != nil {
+			blocks = append(blocks, x.Else.Stmts)
+		}
+		return blocks, true
+	case *ir.ForStmt:
+		if anyMentions(x.Init...) || anyMentions(x.Cond...) || anyMentions(x.Loop...) {
+			return nil, false
+		}
+		return [][]ir.Stmt{x.Stmts}, true
+	case *ir.WhileStmt:
+		if anyMentions(x.Cond) {
+			return nil, false
+		}
+		return [][]ir.Stmt{x.Stmts}, true
+	case *ir.ForeachStmt:
+		if anyMentions(x.Expr, x.KeyVar, x.ValueVar) {
+			return nil, false
+		}
+		return [][]ir.Stmt{x.Stmts}, true
+	}
+	return nil, false
+}
+
+// isInitAssign e 为 $name = expr，且 expr 中不使用该变量
+func isInitAssign(e ir.Expr, name string) bool {
+	assign, ok := e.(*ir.AssignExpr)
+	if !ok || !isVar(assign.Var, name) {
+		return false
+	}
+	return !mentions(assign.Expr, name)
+}
+
+func isVar(e ir.Expr, name string) bool {
+	v, ok := e.(*ir.VariableExpr)
+	if !ok {
+		return false
+	}
+	vName, ok := varName(v)
+	return ok && vName == name
+}
+
+func mentions(n ir.Node, name string) bool {
+	found := false
+	ir.Inspect(n, func(n ir.Node) bool {
+		if v, ok := n.(*ir.VariableExpr); ok && isVar(v, name) {
+			found = true
+		}
+		return !found
+	})
+	return found
+}
+
+// findGuards 查找循环条件保证不会溢出的 ++/--。
+// 如 for ($i = 0; $i < $n; $i++)：条件成立时 $i 小于某个 int，循环体不修改 $i 时自增后仍不超过 PHP_INT_MAX。
+// while 循环中自增需为循环体的顶层语句，保证每次条件成立后至多执行一次
+func (a *analyzer) findGuards() {
+	ir.InspectList(a.stmts, func(n ir.Node) bool {
+		switch x := n.(type) {
+		case *ir.FunctionStmt, *ir.ClassStmt, *ir.InterfaceStmt, *ir.TraitStmt, *ir.ClosureExpr, *ir.ArrowFunctionExpr:
+			return false
+		case *ir.ForStmt:
+			if len(x.Cond) == 0 {
+				break
+			}
+			for _, e := range x.Loop {
+				a.addGuard(e, x.Cond[len(x.Cond)-1], x.Cond, x.Loop, x.Stmts)
+			}
+		case *ir.WhileStmt:
+			for _, stmt := range x.Stmts {
+				if s, ok := stmt.(*ir.ExprStmt); ok {
+					a.addGuard(s.Expr, x.Cond, x.Cond, x.Stmts)
+				}
+			}
+		}
+		return true
+	})
+}
+
+// addGuard e 为变量的 ++/-- 且 cond 限定了变量的边界，scopes 内没有其它对变量的赋值时记录边界
+func (a *analyzer) addGuard(e ir.Expr, cond ir.Expr, scopes ...any) {
+	u, ok := e.(*ir.UnaryExpr)
+	if !ok || !isIncDec(u.Op) {
+		return
+	}
+	v, ok := u.Var.(*ir.VariableExpr)
+	if !ok {
+		return
+	}
+	name, ok := varName(v)
+	if !ok {
+		return
+	}
+	bound := loopBound(cond, name, isInc(u.Op))
+	if bound == nil || mentions(bound, name) || a.writtenIn(name, u, scopes...) {
+		return
+	}
+	a.guards[u] = bound
+}
+
+// loopBound 条件 cond 成立时变量 name 的上界(inc)或下界，只查找 && 连接的 < 及 > 比较
+func loopBound(cond ir.Expr, name string, inc bool) ir.Expr {
+	x, ok := cond.(*ir.BinaryOpExpr)
+	if !ok {
+		return nil
+	}
+	switch x.Op {
+	case ast.BinaryOpBooleanAnd:
+		if bound := loopBound(x.Left, name, inc); bound != nil {
+			return bound
+		}
+		return loopBound(x.Right, name, inc)
+	case ast.BinaryOpSmaller:
+		if inc && isVar(x.Left, name) {
+			return x.Right
+		} else if !inc && isVar(x.Right, name) {
+			return x.Left
+		}
+	case ast.BinaryOpGreater:
+		if inc && isVar(x.Right, name) {
+			return x.Left
+		} else if !inc && isVar(x.Left, name) {
+			return x.Right
+		}
+	}
+	return nil
+}
+
+// writtenIn scopes(ir.Expr、[]ir.Expr 或 []ir.Stmt)内除 except 外是否有对变量的赋值
+func (a *analyzer) writtenIn(name string, except ir.Node, scopes ...any) bool {
+	found := false
+	visit := func(n ir.Node) bool {
+		if n != except && slices.Contains(a.writes[name], n) {
+			found = true
+		}
+		return !found
+	}
+	for _, scope := range scopes {
+		switch x := scope.(type) {
+		case ir.Expr:
+			ir.Inspect(x, visit)
+		case []ir.Expr:
+			ir.InspectList(x, visit)
+		case []ir.Stmt:
+			ir.InspectList(x, visit)
+		}
+	}
+	return found
+}
+
+// solve 迭代推导变量类型直到不再变化。变量初始为 undefined，类型只会向 Unknown 方向变化，因此必定收敛
+func (a *analyzer) solve() {
+	var names []string
+	for _, name := range a.order {
+		if a.dynamic || a.excluded[name] || !a.reads[name] || !isLocalName(name) {
+			a.env[name] = Unknown
+			continue
+		}
+		if typ, ok := a.params[name]; ok {
+			a.env[name] = typ
+		} else if !a.definitelyAssigned(name) {
+			a.env[name] = Unknown
+			continue
+		}
+		names = append(names, name)
+	}
+
+	for changed := true; changed; {
+		changed = false
+		for _, name := range names {
+			typ := undefined
+			if paramType, ok := a.params[name]; ok {
+				typ = paramType
+			}
+			for _, w := range a.writes[name] {
+				typ = join(typ, a.writeType(w))
+			}
+			if typ != a.env[name] {
+				a.env[name] = typ
+				changed = true
+			}
+		}
+	}
+
+	// 相互依赖而没有其它来源的变量
+	for name, typ := range a.env {
+		if typ == undefined {
+			a.env[name] = Unknown
+		}
+	}
+}
+
+func (a *analyzer) result() *Func {
+	f := &Func{
+		types:  map[string]Type{},
+		params: map[string]bool{},
+		exprs:  map[ir.Expr]Type{},
+	}
+	for _, name := range a.order {
+		if typ := a.env[name]; typ != Unknown {
+			f.vars = append(f.vars, name)
+			f.types[name] = typ
+			if _, ok := a.params[name]; ok {
+				f.params[name] = true
+			}
+		}
+	}
+	ir.InspectList(a.stmts, func(n ir.Node) bool {
+		switch x := n.(type) {
+		case *ir.FunctionStmt, *ir.ClassStmt, *ir.InterfaceStmt, *ir.TraitStmt, *ir.ClosureExpr, *ir.ArrowFunctionExpr:
+			return false
+		case ir.Expr:
+			if typ := a.exprType(x); typ != Unknown {
+				f.exprs[x] = typ
+			}
+		}
+		return true
+	})
+	return f
+}
+
+// writeType 赋值后变量的类型
+func (a *analyzer) writeType(n ir.Node) Type {
+	switch x := n.(type) {
+	case *ir.AssignExpr:
+		return a.exprType(x.Expr)
+	case *ir.AssignOpExpr:
+		return binaryType(assignBinaryOps[x.Op], a.exprType(x.Var), a.exprType(x.Expr))
+	case *ir.UnaryExpr:
+		return a.incDecType(x)
+	default:
+		return Unknown
+	}
+}
+
+func (a *analyzer) incDecType(u *ir.UnaryExpr) Type {
+	switch a.exprType(u.Var) {
+	case undefined:
+		return undefined
+	case Float:
+		return Float
+	case Int:
+		if bound, ok := a.guards[u]; ok {
+			if typ := a.exprType(bound); typ == Int || typ == undefined {
+				return Int
+			}
+		}
+	}
+	return Unknown
+}
+
+func (a *analyzer) exprType(e ir.Expr) Type {
+	switch x := e.(type) {
+	case *ir.IntLit:
+		return Int
+	case *ir.FloatLit:
+		return Float
+	case *ir.BoolLit:
+		return Bool
+	case *ir.StringLit:
+		return String
+	case *ir.ArrayExpr:
+		return Array
+	case *ir.VariableExpr:
+		if name, ok := varName(x); ok {
+			if typ, ok := a.env[name]; ok {
+				return typ
+			}
+		}
+	case *ir.BinaryOpExpr:
+		return binaryType(x.Op, a.exprType(x.Left), a.exprType(x.Right))
+	case *ir.UnaryExpr:
+		return a.unaryType(x)
+	case *ir.CastExpr:
+		switch x.Kind {
+		case ast.CastInt:
+			return Int
+		case ast.CastDouble:
+			return Float
+		case ast.CastBool:
+			return Bool
+		case ast.CastString:
+			return String
+		case ast.CastArray:
+			return Array
+		}
+	case *ir.AssignExpr:
+		// 赋值给下标或属性时结果可能被转换
+		if _, ok := x.Var.(*ir.VariableExpr); ok {
+			return a.exprType(x.Expr)
+		}
+	case *ir.AssignOpExpr:
+		if _, ok := x.Var.(*ir.VariableExpr); ok {
+			return a.writeType(x)
+		}
+	case *ir.IssetExpr, *ir.EmptyExpr, *ir.InstanceofExpr:
+		return Bool
+	case *ir.PrintExpr:
+		return Int
+	case *ir.MagicConstExpr:
+		if x.Kind == ast.MagicConstLine {
+			return Int
+		}
+		return String
+	case *ir.TernaryExpr:
+		if x.If == nil {
+			return join(a.exprType(x.Cond), a.exprType(x.Else))
+		}
+		return join(a.exprType(x.If), a.exprType(x.Else))
+	case *ir.FuncCallExpr:
+		return a.callType(x)
+	}
+	return Unknown
+}
+
+func (a *analyzer) unaryType(x *ir.UnaryExpr) Type {
+	typ := a.exprType(x.Var)
+	switch x.Op {
+	case ast.UnaryOpBooleanNot:
+		return Bool
+	case ast.UnaryOpPlus:
+		if isNumeric(typ) || typ == undefined {
+			return typ
+		}
+	case ast.UnaryOpMinus:
+		// -PHP_INT_MIN 溢出为 float
+		if typ == Float || typ == undefined {
+			return typ
+		}
+	case ast.UnaryOpBitwiseNot:
+		switch typ {
+		case Int, Float:
+			return Int
+		case String, undefined:
+			return typ
+		}
+	case ast.UnaryOpPreInc, ast.UnaryOpPreDec:
+		return a.incDecType(x)
+	case ast.UnaryOpPostInc, ast.UnaryOpPostDec:
+		return typ
+	}
+	return Unknown
+}
+
+// callType 同文件函数声明的返回值类型，或实参类型确定的内置函数的返回值类型
+func (a *analyzer) callType(x *ir.FuncCallExpr) Type {
+	fn, builtin := a.scope.callee(x)
+	if fn != nil {
+		return scalarType(fn.ReturnType)
+	}
+	if builtin == nil || len(x.Args) != len(builtin.params) {
+		return Unknown
+	}
+	for i, arg := range x.Args {
+		typ := a.exprType(arg.Value)
+		switch {
+		case arg.Unpack:
+			return Unknown
+		case typ == undefined, typ == builtin.params[i]:
+		case builtin.params[i] == Float && typ == Int:
+		default:
+			return Unknown
+		}
+	}
+	return builtin.ret
+}
+
+var assignBinaryOps = map[ast.AssignOpKind]ast.BinaryOpKind{
+	ast.AssignOpBitwiseAnd: ast.BinaryOpBitwiseAnd,
+	ast.AssignOpBitwiseOr:  ast.BinaryOpBitwiseOr,
+	ast.AssignOpBitwiseXor: ast.BinaryOpBitwiseXor,
+	ast.AssignOpCoalesce:   ast.BinaryOpCoalesce,
+	ast.AssignOpConcat:     ast.BinaryOpConcat,
+	ast.AssignOpDiv:        ast.BinaryOpDiv,
+	ast.AssignOpMinus:      ast.BinaryOpMinus,
+	ast.AssignOpMod:        ast.BinaryOpMod,
+	ast.AssignOpMul:        ast.BinaryOpMul,
+	ast.AssignOpPlus:       ast.BinaryOpPlus,
+	ast.AssignOpPow:        ast.BinaryOpPow,
+	ast.AssignOpShiftLeft:  ast.BinaryOpShiftLeft,
+	ast.AssignOpShiftRight: ast.BinaryOpShiftRight,
+}
+
+// binaryType 二元运算结果的类型。int 的加减乘及乘方可能溢出为 float，int 相除可能得到 float，均无法证明
+func binaryType(op ast.BinaryOpKind, t1, t2 Type) Type {
+	switch op {
+	case ast.BinaryOpConcat:
+		return String
+	case ast.BinaryOpEqual, ast.BinaryOpNotEqual, ast.BinaryOpIdentical, ast.BinaryOpNotIdentical,
+		ast.BinaryOpGreater, ast.BinaryOpGreaterOrEqual, ast.BinaryOpSmaller, ast.BinaryOpSmallerOrEqual,
+		ast.BinaryOpBooleanAnd, ast.BinaryOpBooleanOr, ast.BinaryOpBooleanXor:
+		return Bool
+	case ast.BinaryOpSpaceship, ast.BinaryOpMod, ast.BinaryOpShiftLeft, ast.BinaryOpShiftRight:
+		return Int
+	}
+
+	if t1 == undefined || t2 == undefined {
+		return undefined
+	}
+	switch op {
+	case ast.BinaryOpPlus, ast.BinaryOpMinus, ast.BinaryOpMul, ast.BinaryOpDiv, ast.BinaryOpPow:
+		if op == ast.BinaryOpPlus && t1 == Array && t2 == Array {
+			return Array
+		}
+		if isNumeric(t1) && isNumeric(t2) && (t1 == Float || t2 == Float) {
+			return Float
+		}
+	case ast.BinaryOpBitwiseAnd, ast.BinaryOpBitwiseOr, ast.BinaryOpBitwiseXor:
+		if t1 == String && t2 == String {
+			return String
+		} else if isNumeric(t1) && isNumeric(t2) {
+			return Int
+		}
+	case ast.BinaryOpCoalesce:
+		return join(t1, t2)
+	}
+	return Unknown
+}
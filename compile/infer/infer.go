@@ -0,0 +1,259 @@
+// Package infer 在 IR 上推导函数体内变量及表达式的类型，供 render 生成原生 go 局部变量及运算。
+//
+// 推导以函数体为单位，只在变量的所有读写都能在转译时看到时进行：函数体内有可变变量、eval、include、
+// yield、goto 或 compact/extract 等按名称访问符号表的调用时不推导。变量被引用、被闭包捕获、
+// 作为可能按引用传递的实参，或使用前可能未赋值时，仍保留在运行时符号表中。
+//
+// 类型来源为字面量、运算结果，以及声明为 int、float、string、bool 的参数和同文件函数的返回值。
+// 标量类型声明由运行时校验，strict_types 下不做转换、否则按弱类型规则转换，两种模式下都可以信任。
+// int 的加减乘可能溢出为 float，只有循环条件保证不会溢出的 ++/-- 能使变量保持 int。
+package infer
+
+import (
+	"github.com/heyuuu/gophp/compile/ir"
+	"strings"
+)
+
+// Type 推导出的类型
+type Type uint8
+
+const (
+	Unknown Type = iota // 无法证明，运行时可能为任意类型
+	Int
+	Float
+	Bool
+	String
+	Array
+
+	undefined // 推导过程中尚未确定类型的变量
+)
+
+func (t Type) String() string {
+	switch t {
+	case Int:
+		return "int"
+	case Float:
+		return "float"
+	case Bool:
+		return "bool"
+	case String:
+		return "string"
+	case Array:
+		return "array"
+	default:
+		return "mixed"
+	}
+}
+
+// join 合并变量多处赋值的类型
+func join(t1, t2 Type) Type {
+	switch {
+	case t1 == undefined:
+		return t2
+	case t2 == undefined, t1 == t2:
+		return t1
+	default:
+		return Unknown
+	}
+}
+
+func isNumeric(t Type) bool { return t == Int || t == Float }
+
+// Result 文件内各函数体的推导结果
+type Result struct {
+	funcs map[ir.FunctionLike]*Func
+}
+
+// Func 函数、方法或闭包的推导结果，未推导(如抽象方法、箭头函数)时返回 nil
+func (r *Result) Func(n ir.FunctionLike) *Func {
+	if r == nil {
+		return nil
+	}
+	return r.funcs[n]
+}
+
+// Func 函数体的推导结果。nil 表示未推导，所有查询返回 Unknown
+type Func struct {
+	vars   []string // 类型已证明的变量，按首次出现的顺序
+	types  map[string]Type
+	params map[string]bool
+	exprs  map[ir.Expr]Type
+}
+
+// Vars 类型已证明的变量，按首次出现的顺序。这些变量在函数体内至少被读取一次
+func (f *Func) Vars() []string {
+	if f == nil {
+		return nil
+	}
+	return f.vars
+}
+
+// Var 变量的类型，变量保留在符号表中时返回 Unknown
+func (f *Func) Var(name string) Type {
+	if f == nil {
+		return Unknown
+	}
+	return f.types[name]
+}
+
+// IsParam 变量是否为函数参数，参数在函数开始时已赋值
+func (f *Func) IsParam(name string) bool {
+	return f != nil && f.params[name]
+}
+
+// Expr 表达式的类型，仅函数体内(不含嵌套函数、闭包及箭头函数)的表达式有推导结果
+func (f *Func) Expr(e ir.Expr) Type {
+	if f == nil {
+		return Unknown
+	}
+	return f.exprs[e]
+}
+
+// File 推导文件内所有函数、方法及闭包的函数体
+func File(f *ir.File) *Result {
+	r := &Result{funcs: map[ir.FunctionLike]*Func{}}
+
+	// 命名空间顶层声明的函数，执行到命名空间时即已声明
+	decls := map[string]*ir.FunctionStmt{}
+	blocks := make([]map[string]*ir.FunctionStmt, len(f.Namespaces))
+	for i, ns := range f.Namespaces {
+		blocks[i] = map[string]*ir.FunctionStmt{}
+		for _, stmt := range ns.Stmts {
+			if fn, ok := stmt.(*ir.FunctionStmt); ok {
+				name := strings.ToLower(declName(fn))
+				decls[name] = fn
+				blocks[i][name] = fn
+			}
+		}
+	}
+
+	for i, ns := range f.Namespaces {
+		scope := &fileScope{decls: decls, block: blocks[i]}
+		ir.InspectList(ns.Stmts, func(n ir.Node) bool {
+			switch x := n.(type) {
+			case *ir.FunctionStmt:
+				r.funcs[x] = analyze(scope, x.Params, nil, x.Stmts)
+			case *ir.ClassMethodStmt:
+				if x.Stmts != nil {
+					r.funcs[x] = analyze(scope, x.Params, nil, x.Stmts)
+				}
+			case *ir.ClosureExpr:
+				r.funcs[x] = analyze(scope, x.Params, x.Uses, x.Stmts)
+			}
+			return true
+		})
+	}
+	return r
+}
+
+func declName(fn *ir.FunctionStmt) string {
+	if fn.NamespacedName != nil {
+		return fn.NamespacedName.ToString()
+	}
+	return fn.Name.Name
+}
+
+// fileScope 函数调用可以确定目标的同文件函数
+type fileScope struct {
+	decls map[string]*ir.FunctionStmt // 文件内所有命名空间顶层声明的函数
+	block map[string]*ir.FunctionStmt // 当前命名空间块顶层声明的函数，需要回退的非限定名称只在此查找
+}
+
+// callee 函数调用的目标，同文件函数或已知的内置函数，无法确定时均为 nil
+func (s *fileScope) callee(x *ir.FuncCallExpr) (*ir.FunctionStmt, *builtinFunc) {
+	name, ok := x.Name.(*ir.Name)
+	if !ok || name.Resolved == nil {
+		return nil, nil
+	}
+	lcName := strings.ToLower(name.Resolved.ToString())
+	if name.Fallback {
+		// 同一命名空间块内的函数在调用前必定已声明；否则运行时可能回退到其它文件声明的全局函数
+		return s.block[lcName], nil
+	}
+	if fn := s.decls[lcName]; fn != nil {
+		return fn, nil
+	}
+	return nil, builtinFuncs[lcName]
+}
+
+// scalarType 参数或返回值的类型声明，仅运行时会校验的 int、float、string、bool
+func scalarType(t ir.TypeHint) Type {
+	st, ok := t.(*ir.SimpleType)
+	if !ok || st.Name.Resolved != nil {
+		return Unknown
+	}
+	switch strings.ToLower(st.Name.ToString()) {
+	case "int":
+		return Int
+	case "float":
+		return Float
+	case "string":
+		return String
+	case "bool":
+		return Bool
+	}
+	return Unknown
+}
+
+// varName 变量名，可变变量($$name)返回 false
+func varName(v *ir.VariableExpr) (string, bool) {
+	if ident, ok := v.Name.(*ir.Ident); ok {
+		return ident.Name, true
+	}
+	return "", false
+}
+
+// isLocalName 可以作为局部变量的变量名：由 ascii 字母、数字及下划线组成，且不是 $this 或超全局变量
+func isLocalName(name string) bool {
+	if name == "" || name == "this" || superGlobals[name] {
+		return false
+	}
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		if !(c == '_' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || i > 0 && '0' <= c && c <= '9') {
+			return false
+		}
+	}
+	return true
+}
+
+var superGlobals = map[string]bool{
+	"GLOBALS": true, "_SERVER": true, "_GET": true, "_POST": true, "_FILES": true,
+	"_COOKIE": true, "_SESSION": true, "_REQUEST": true, "_ENV": true,
+}
+
+// dynamicFuncs 按名称读写调用方符号表的函数
+var dynamicFuncs = map[string]bool{
+	"compact":          true,
+	"extract":          true,
+	"get_defined_vars": true,
+	"func_get_args":    true,
+	"func_get_arg":     true,
+	"parse_str":        true,
+	"mb_parse_str":     true,
+}
+
+// builtinFunc 参数均按值传递、返回值类型固定的内置函数。实参类型与参数一致时不会解析失败，返回值类型可以信任
+type builtinFunc struct {
+	params []Type
+	ret    Type
+}
+
+var builtinFuncs = map[string]*builtinFunc{}
+
+func init() {
+	add := func(ret Type, params []Type, names ...string) {
+		for _, name := range names {
+			builtinFuncs[name] = &builtinFunc{params: params, ret: ret}
+		}
+	}
+	add(Float, nil, "pi")
+	add(Float, []Type{Float}, "sqrt", "exp", "expm1", "log10", "log1p", "deg2rad", "rad2deg",
+		"sin", "cos", "tan", "asin", "acos", "atan", "sinh", "cosh", "tanh", "asinh", "acosh", "atanh")
+	add(Float, []Type{Float, Float}, "atan2", "hypot", "fmod")
+	add(Bool, []Type{Float}, "is_nan", "is_finite", "is_infinite")
+	add(Int, []Type{Int, Int}, "intdiv")
+	add(Int, []Type{String}, "strlen", "ord")
+	add(String, []Type{Int}, "chr", "dechex", "decbin", "decoct")
+	add(String, []Type{String}, "strtoupper", "strtolower", "strrev")
+}
@@ -0,0 +1,195 @@
+package infer
+
+import (
+	"github.com/heyuuu/gophp/compile/ir"
+	"github.com/heyuuu/gophp/compile/parser"
+	"github.com/heyuuu/gophp/compile/transformer"
+	"maps"
+	"testing"
+)
+
+func inferCode(t *testing.T, code string) (*ir.File, *Result) {
+	astFile, err := parser.ParseCode(code)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file, err := transformer.TransformFile(astFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return file, File(file)
+}
+
+// findFunc 文件顶层名为 name 的函数
+func findFunc(file *ir.File, name string) *ir.FunctionStmt {
+	for _, ns := range file.Namespaces {
+		for _, stmt := range ns.Stmts {
+			if fn, ok := stmt.(*ir.FunctionStmt); ok && fn.Name.Name == name {
+				return fn
+			}
+		}
+	}
+	return nil
+}
+
+func TestFileVars(t *testing.T) {
+	tests := []struct {
+		name string
+		code string
+		want map[string]Type // 函数 f 中类型已证明的变量
+	}{
+		{
+			name: "literals and params",
+			code: `function f(int $n, float $x, string $s, bool $b, $m) {
+	$a = 1.5;
+	$c = "x";
+	echo $n, $x, $s, $b, $m, $a, $c;
+}`,
+			want: map[string]Type{"n": Int, "x": Float, "s": String, "b": Bool, "a": Float, "c": String},
+		},
+		{
+			name: "int arithmetic may overflow",
+			code: `function f(int $n) {
+	$a = $n + 1;
+	$b = $n * 2.0;
+	$c = $n % 3;
+	$d = $n / 2;
+	$e = $n . "";
+	$g = $n < 3;
+	echo $a, $b, $c, $d, $e, $g;
+}`,
+			want: map[string]Type{"n": Int, "b": Float, "c": Int, "e": String, "g": Bool},
+		},
+		{
+			name: "guarded loop counters",
+			code: `function f(int $n) {
+	for ($i = 0; $i < $n; $i++) {
+		echo $i;
+	}
+	$j = 0;
+	while ($j < 10 && $n > 0) {
+		$j++;
+	}
+	$k = 0;
+	while ($k < $n) {
+		if ($n) {
+			$k++;
+		}
+	}
+	for ($l = 0; $l < $n; $l++) {
+		$l = $l + 1;
+	}
+	echo $j, $k, $l;
+}`,
+			want: map[string]Type{"n": Int, "i": Int, "j": Int},
+		},
+		{
+			name: "conflicting assignments",
+			code: `function f($m) {
+	$a = 1;
+	$a = "x";
+	$b = 1.0;
+	$b = $b * 2;
+	echo $a, $b;
+}`,
+			want: map[string]Type{"b": Float},
+		},
+		{
+			name: "definite assignment",
+			code: `function f(int $n) {
+	if ($n) {
+		$a = 1.0;
+	}
+	echo $a;
+	for ($i = 0; $i < $n; $i++) {
+		$b = 2.0;
+		echo $b;
+	}
+	$c = $c . "x";
+	echo $c;
+}`,
+			want: map[string]Type{"n": Int, "i": Int, "b": Float},
+		},
+		{
+			name: "symbol table access",
+			code: `function f(int $n, float &$r) {
+	$a = 1.0;
+	$b = 2.0;
+	$c = 3.0;
+	$d = 4.0;
+	$e = 5.0;
+	$g = 6.0;
+	$x = &$a;
+	global $b;
+	sort($c);
+	$fn = function () use ($d) { return $d; };
+	$ar = fn() => $e;
+	unset($g);
+	echo $n, $r, $x, $fn, $ar;
+}`,
+			want: map[string]Type{"n": Int},
+		},
+		{
+			name: "dynamic function",
+			code: `function f(int $n) {
+	$a = 1.0;
+	echo $a, $$n;
+}`,
+			want: map[string]Type{},
+		},
+		{
+			name: "call results",
+			code: `function g(float $x): float { return $x; }
+function h(&$x): string { return ""; }
+function f(int $n) {
+	$a = g($n);
+	$b = sqrt(2.0);
+	$c = strlen("abc");
+	$d = h($n);
+	$e = g(1.0) + $a;
+	echo $a, $b, $c, $d, $e;
+}`,
+			want: map[string]Type{"a": Float, "b": Float, "c": Int, "d": String, "e": Float},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file, result := inferCode(t, "<?php\n"+tt.code)
+			fn := result.Func(findFunc(file, "f"))
+			got := map[string]Type{}
+			for _, name := range fn.Vars() {
+				got[name] = fn.Var(name)
+			}
+			if !maps.Equal(got, tt.want) {
+				t.Errorf("File() vars = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFileFallbackCall(t *testing.T) {
+	code := `<?php
+namespace A {
+	function f() {
+		$a = g(1.0);
+		$b = \A\g(1.0);
+		echo $a, $b;
+	}
+}
+namespace B {
+	function g(float $x): float { return $x; }
+}
+namespace A {
+	function g(float $x): float { return $x; }
+}
+`
+	file, result := inferCode(t, code)
+	fn := result.Func(findFunc(file, "f"))
+	// 非限定名称在其它命名空间块声明时，运行时可能回退到全局函数
+	if got := fn.Var("a"); got != Unknown {
+		t.Errorf("Var(a) = %v, want mixed", got)
+	}
+	if got := fn.Var("b"); got != Float {
+		t.Errorf("Var(b) = %v, want float", got)
+	}
+}
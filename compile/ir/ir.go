@@ -16,11 +16,16 @@ type Node interface {
 	node()
 	Comments() []*Comment
 	SetComments([]*Comment)
+	Pos() ast.Position
+	End() ast.Position
+	SetPos(start, end ast.Position)
 }
 
 // baseNode
 type baseNode struct {
 	comments []*Comment
+	start    ast.Position
+	end      ast.Position
 }
 
 func (*baseNode) node() {}
@@ -33,6 +38,20 @@ func (n *baseNode) SetComments(comments []*Comment) {
 	n.comments = comments
 }
 
+// Pos 节点在源码中的起始位置
+func (n *baseNode) Pos() ast.Position {
+	return n.start
+}
+
+// End 节点在源码中的结束位置
+func (n *baseNode) End() ast.Position {
+	return n.end
+}
+
+func (n *baseNode) SetPos(start, end ast.Position) {
+	n.start, n.end = start, end
+}
+
 // baseExpr
 type baseExpr struct {
 	baseNode
@@ -115,6 +134,12 @@ type (
 		Type ast.CommentType
 		Text string
 	}
+
+	MatchArm struct {
+		baseNode
+		Conds []Expr // @var Expr[]|null List of conditions, null for default arm
+		Body  Expr
+	}
 )
 
 // TypeHint 类型标识
@@ -153,6 +178,10 @@ type Name struct {
 	baseNode
 	Kind  ast.NameKind
 	Parts []string // parts of the name
+
+	// 名称解析结果，仅类、函数、常量的引用会设置
+	Resolved *Name // 完全限定名称；特殊类名(self/parent/static)及内置类型名为 nil
+	Fallback bool  // 命名空间内的非限定函数名或常量名，Resolved 不存在时需回退到全局名称(即 Parts)
 }
 
 func NewName(parts ...string) *Name {
@@ -164,6 +193,7 @@ func (n *Name) IsQualified() bool      { return n.Kind == ast.NameNormal && len(
 func (n *Name) IsFullyQualified() bool { return n.Kind == ast.NameFullyQualified }
 func (n *Name) IsRelative() bool       { return n.Kind == ast.NameRelative }
 func (n *Name) ToString() string       { return strings.Join(n.Parts, "\\") }
+
 func (n *Name) ToCodeString() string {
 	switch n.Kind {
 	case ast.NameFullyQualified:
@@ -175,6 +205,14 @@ func (n *Name) ToCodeString() string {
 	}
 }
 
+// ResolvedCodeString 优先返回解析后的名称，需要回退到全局或未解析时返回原名称
+func (n *Name) ResolvedCodeString() string {
+	if n.Resolved != nil && !n.Fallback {
+		return n.Resolved.ToCodeString()
+	}
+	return n.ToCodeString()
+}
+
 // Expr
 type (
 	// literal
@@ -384,6 +422,12 @@ type (
 		Expr Expr
 	}
 
+	MatchExpr struct {
+		baseExpr
+		Cond Expr
+		Arms []*MatchArm
+	}
+
 	VariableExpr struct {
 		baseExpr
 		Name Node // @var Ident|Expr Name
@@ -3,6 +3,7 @@ package ir
 import (
 	"fmt"
 	"github.com/heyuuu/gophp/compile/ast"
+	"math"
 	"reflect"
 	"strconv"
 	"strings"
@@ -61,6 +62,9 @@ func (p *printer) string(s string) {
 }
 
 func (p *printer) file(f *File) {
+	if f.StrictTypes {
+		p.print("declare(strict_types=1);\n\n")
+	}
 	p.multiNamespace = len(f.Namespaces) > 1
 	for _, namespace := range f.Namespaces {
 		p.namespace(namespace)
@@ -150,6 +154,8 @@ func (p *printer) node(node Node) {
 		p.param(x)
 	case *Arg:
 		p.arg(x)
+	case *MatchArm:
+		p.matchArm(x)
 	default:
 		p.unexpect(x)
 	}
@@ -249,6 +255,15 @@ func (p *printer) arg(n *Arg) {
 	p.print(n.Value)
 }
 
+func (p *printer) matchArm(n *MatchArm) {
+	if n.Conds == nil {
+		p.print("default")
+	} else {
+		p.print(n.Conds)
+	}
+	p.print(" => ", n.Body)
+}
+
 func (p *printer) param(n *Param) {
 	if n.Type != nil {
 		p.print(n.Type, " ")
@@ -307,6 +322,238 @@ func (p *printer) typeHint0(n TypeHint, wrap bool) {
 	}
 }
 
+// 表达式的优先级，数值越大结合越紧密。子表达式的优先级低于所在位置的要求时加括号
+const (
+	precLowest     = iota
+	precXor        // xor
+	precKeyword    // print、yield、include、throw 等作用于之后整个表达式的关键字
+	precAssign     // 赋值、闭包
+	precTernary    // ?:
+	precCoalesce   // ??
+	precOr         // ||
+	precAnd        // &&
+	precBitOr      // |
+	precBitXor     // ^
+	precBitAnd     // &
+	precEquality   // == != === !== <=>
+	precCompare    // < <= > >=
+	precConcat     // .
+	precShift      // << >>
+	precAdd        // + -
+	precMul        // * / %
+	precNot        // !
+	precInstanceof // instanceof
+	precUnary      // + - ~ ++ -- (cast) @
+	precPow        // **
+	precNew        // new clone
+	precPrimary    // 变量、字面量、调用等
+)
+
+func binaryPrec(op ast.BinaryOpKind) int {
+	switch op {
+	case ast.BinaryOpBooleanXor:
+		return precXor
+	case ast.BinaryOpCoalesce:
+		return precCoalesce
+	case ast.BinaryOpBooleanOr:
+		return precOr
+	case ast.BinaryOpBooleanAnd:
+		return precAnd
+	case ast.BinaryOpBitwiseOr:
+		return precBitOr
+	case ast.BinaryOpBitwiseXor:
+		return precBitXor
+	case ast.BinaryOpBitwiseAnd:
+		return precBitAnd
+	case ast.BinaryOpEqual, ast.BinaryOpNotEqual, ast.BinaryOpIdentical, ast.BinaryOpNotIdentical, ast.BinaryOpSpaceship:
+		return precEquality
+	case ast.BinaryOpSmaller, ast.BinaryOpSmallerOrEqual, ast.BinaryOpGreater, ast.BinaryOpGreaterOrEqual:
+		return precCompare
+	case ast.BinaryOpConcat:
+		return precConcat
+	case ast.BinaryOpShiftLeft, ast.BinaryOpShiftRight:
+		return precShift
+	case ast.BinaryOpPlus, ast.BinaryOpMinus:
+		return precAdd
+	case ast.BinaryOpMul, ast.BinaryOpDiv, ast.BinaryOpMod:
+		return precMul
+	case ast.BinaryOpPow:
+		return precPow
+	default:
+		return precLowest
+	}
+}
+
+// exprPrec 表达式的优先级
+func exprPrec(n Expr) int {
+	switch x := n.(type) {
+	case *IntLit:
+		if x.Value < 0 && x.Raw == "" {
+			return precUnary
+		}
+	case *FloatLit:
+		if (x.Value < 0 || math.Signbit(x.Value)) && x.Raw == "" {
+			return precUnary
+		}
+	case *BinaryOpExpr:
+		return binaryPrec(x.Op)
+	case *AssignExpr, *AssignOpExpr, *AssignRefExpr, *ClosureExpr, *ArrowFunctionExpr:
+		return precAssign
+	case *TernaryExpr:
+		return precTernary
+	case *UnaryExpr:
+		if x.Op == ast.UnaryOpBooleanNot {
+			return precNot
+		}
+		return precUnary
+	case *CastExpr, *ErrorSuppressExpr:
+		return precUnary
+	case *InstanceofExpr:
+		return precInstanceof
+	case *NewExpr, *CloneExpr:
+		return precNew
+	case *PrintExpr, *YieldExpr, *YieldFromExpr, *IncludeExpr, *ThrowExpr:
+		return precKeyword
+	}
+	return precPrimary
+}
+
+// operand 打印子表达式，优先级低于 min 时加括号
+func (p *printer) operand(n Expr, min int) {
+	if n != nil && exprPrec(n) < min {
+		p.print("(", n, ")")
+	} else {
+		p.print(n)
+	}
+}
+
+// binaryOperands 二元运算的左右操作数的最低优先级
+func binaryOperands(op ast.BinaryOpKind) (left, right int) {
+	prec := binaryPrec(op)
+	switch op {
+	case ast.BinaryOpPow, ast.BinaryOpCoalesce:
+		// 右结合
+		return prec + 1, prec
+	case ast.BinaryOpEqual, ast.BinaryOpNotEqual, ast.BinaryOpIdentical, ast.BinaryOpNotIdentical, ast.BinaryOpSpaceship,
+		ast.BinaryOpSmaller, ast.BinaryOpSmallerOrEqual, ast.BinaryOpGreater, ast.BinaryOpGreaterOrEqual:
+		// 不可结合
+		return prec + 1, prec + 1
+	default:
+		return prec, prec + 1
+	}
+}
+
+func (p *printer) binaryOperand(op ast.BinaryOpKind, n Expr, min int) {
+	// PHP 8 之前 . 与 + - 的优先级相同且高于 << >>，混用时总是加括号使结果在各版本中一致
+	if op == ast.BinaryOpConcat {
+		if x, ok := n.(*BinaryOpExpr); ok {
+			if prec := binaryPrec(x.Op); prec == precAdd || prec == precShift {
+				min = precPrimary
+			}
+		}
+	}
+	p.operand(n, min)
+}
+
+// signOperand 一元 + - 的操作数，以相同符号开头时加括号，避免打印为 ++、--
+func (p *printer) signOperand(op ast.UnaryOpKind, n Expr) {
+	first := ""
+	switch x := n.(type) {
+	case *UnaryExpr:
+		if x.Op != ast.UnaryOpPostInc && x.Op != ast.UnaryOpPostDec {
+			first = x.Op.String()[:1]
+		}
+	case *IntLit, *FloatLit:
+		if exprPrec(n) == precUnary {
+			first = "-"
+		}
+	}
+	if first == op.String() {
+		p.print("(", n, ")")
+		return
+	}
+	p.operand(n, precUnary)
+}
+
+// memberName 属性名或方法名，非标识符及变量的表达式用花括号包围
+func (p *printer) memberName(n Node) {
+	switch n.(type) {
+	case *Ident, *VariableExpr:
+		p.print(n)
+	default:
+		p.print("{", n, "}")
+	}
+}
+
+// className 类名，表达式作为类名时需为变量形式
+func (p *printer) className(n Node) {
+	if e, ok := n.(Expr); ok {
+		p.operand(e, precPrimary)
+	} else {
+		p.print(n)
+	}
+}
+
+func (p *printer) arrayItems(items []*ArrayItemExpr) {
+	for i, item := range items {
+		if i != 0 {
+			p.string(", ")
+		}
+		if item != nil {
+			p.expr(item)
+		}
+	}
+}
+
+// quoteString 字符串字面量，使用双引号并转义特殊字符
+func quoteString(s string) string {
+	var buf strings.Builder
+	buf.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch c {
+		case '\\', '"', '$':
+			buf.WriteByte('\\')
+			buf.WriteByte(c)
+		case '\n':
+			buf.WriteString(`\n`)
+		case '\r':
+			buf.WriteString(`\r`)
+		case '\t':
+			buf.WriteString(`\t`)
+		case '\v':
+			buf.WriteString(`\v`)
+		case '\f':
+			buf.WriteString(`\f`)
+		default:
+			if c < 0x20 || c == 0x7f {
+				fmt.Fprintf(&buf, `\x%02x`, c)
+			} else {
+				buf.WriteByte(c)
+			}
+		}
+	}
+	buf.WriteByte('"')
+	return buf.String()
+}
+
+// formatFloat 浮点数字面量，结果总是解析为浮点数
+func formatFloat(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "INF"
+	case math.IsInf(f, -1):
+		return "-INF"
+	case math.IsNaN(f):
+		return "NAN"
+	}
+	s := strconv.FormatFloat(f, 'g', -1, 64)
+	if !strings.ContainsAny(s, ".eIN") {
+		s += ".0"
+	}
+	return s
+}
+
 func (p *printer) expr(n Expr) {
 	switch x := n.(type) {
 	case *NullLit:
@@ -324,19 +571,16 @@ func (p *printer) expr(n Expr) {
 			p.print(x.Raw)
 			break
 		}
-
-		p.print(fmt.Sprintf("%f", x.Value))
+		p.print(formatFloat(x.Value))
 	case *StringLit:
 		if x.Raw != "" {
 			p.print(x.Raw)
 			break
 		}
-
-		// todo escape
-		p.print(`"`, x.Value, `"`)
+		p.print(quoteString(x.Value))
 	case *ArrayExpr:
 		p.print("[")
-		printList(p, x.Items, ", ")
+		p.arrayItems(x.Items)
 		p.print("]")
 	case *ArrayItemExpr:
 		if x.Key != nil {
@@ -382,26 +626,47 @@ func (p *printer) expr(n Expr) {
 		if x.ReturnType != nil {
 			p.print(": ", x.ReturnType)
 		}
-		p.print(" => ", x.Expr)
+		p.print(" => ")
+		p.operand(x.Expr, precAssign)
 	case *IndexExpr:
-		p.print(x.Var, "[", x.Dim, "]")
+		p.operand(x.Var, precPrimary)
+		if x.Dim != nil {
+			p.print("[", x.Dim, "]")
+		} else {
+			p.print("[]")
+		}
 	case *CastExpr:
-		p.print(x.Kind, x.Expr)
+		p.print(x.Kind)
+		p.operand(x.Expr, precUnary)
 	case *UnaryExpr:
 		switch x.Op {
 		case ast.UnaryOpPostInc, ast.UnaryOpPostDec:
-			p.print(x.Var, x.Op)
+			p.operand(x.Var, precPrimary)
+			p.print(x.Op)
+		case ast.UnaryOpPlus, ast.UnaryOpMinus:
+			p.print(x.Op)
+			p.signOperand(x.Op, x.Var)
+		case ast.UnaryOpBooleanNot:
+			p.print(x.Op)
+			p.operand(x.Var, precNot)
 		default:
-			p.print(x.Op, x.Var)
+			p.print(x.Op)
+			p.operand(x.Var, precUnary)
 		}
 	case *BinaryOpExpr:
-		p.print(x.Left, " ", x.Op, " ", x.Right)
+		left, right := binaryOperands(x.Op)
+		p.binaryOperand(x.Op, x.Left, left)
+		p.print(" ", x.Op, " ")
+		p.binaryOperand(x.Op, x.Right, right)
 	case *AssignExpr:
-		p.print(x.Var, " = ", x.Expr)
+		p.print(x.Var, " = ")
+		p.operand(x.Expr, precAssign)
 	case *AssignOpExpr:
-		p.print(x.Var, " ", x.Op, " ", x.Expr)
+		p.print(x.Var, " ", x.Op, " ")
+		p.operand(x.Expr, precAssign)
 	case *AssignRefExpr:
-		p.print(x.Var, " = &", x.Expr)
+		p.print(x.Var, " = &")
+		p.operand(x.Expr, precAssign)
 	case *IssetExpr:
 		p.print("isset(", x.Vars, ")")
 	case *EmptyExpr:
@@ -409,33 +674,45 @@ func (p *printer) expr(n Expr) {
 	case *EvalExpr:
 		p.print("eval(", x.Expr, ")")
 	case *IncludeExpr:
-		p.print(x.Kind, " ", x.Expr)
+		p.print(x.Kind, " ")
+		p.operand(x.Expr, precAssign)
 	case *CloneExpr:
-		p.print("clone ", x.Expr)
+		p.print("clone ")
+		p.operand(x.Expr, precNew)
 	case *ErrorSuppressExpr:
-		p.print("@", x.Expr)
+		p.print("@")
+		p.operand(x.Expr, precUnary)
 	case *ExitExpr:
 		p.print("exit(", x.Expr, ")")
 	case *ConstFetchExpr:
 		p.print(x.Name)
 	case *ClassConstFetchExpr:
-		p.print(x.Class, "::", x.Name)
+		p.className(x.Class)
+		p.print("::", x.Name)
 	case *MagicConstExpr:
 		p.print(x.Kind)
 	case *InstanceofExpr:
-		p.print(x.Expr, " instanceof ", x.Class)
+		p.operand(x.Expr, precUnary)
+		p.print(" instanceof ")
+		p.className(x.Class)
 	case *ListExpr:
-		p.print("list(", x.Items, ")")
+		p.print("list(")
+		p.arrayItems(x.Items)
+		p.print(")")
 	case *PrintExpr:
-		p.print("print ", x.Expr)
+		p.print("print ")
+		p.operand(x.Expr, precAssign)
 	case *PropertyFetchExpr:
+		p.operand(x.Var, precPrimary)
 		if !x.Nullsafe {
-			p.print(x.Var, "->", x.Name)
+			p.print("->")
 		} else {
-			p.print(x.Var, "?->", x.Name)
+			p.print("?->")
 		}
+		p.memberName(x.Name)
 	case *StaticPropertyFetchExpr:
-		p.print(x.Class, "::", x.Name)
+		p.className(x.Class)
+		p.print("::", x.Name)
 	case *ShellExecExpr:
 		p.print("`")
 		for _, part := range x.Parts {
@@ -448,13 +725,26 @@ func (p *printer) expr(n Expr) {
 		}
 		p.print("`")
 	case *TernaryExpr:
+		p.operand(x.Cond, precTernary+1)
 		if x.If == nil {
-			p.print(x.Cond, " ?: ", x.Else)
+			p.print(" ?: ")
 		} else {
-			p.print(x.Cond, " ? ", x.If, " : ", x.Else)
+			p.print(" ? ")
+			p.operand(x.If, precAssign)
+			p.print(" : ")
 		}
+		p.operand(x.Else, precTernary+1)
 	case *ThrowExpr:
-		p.print("throw ", x.Expr)
+		p.print("throw ")
+		p.operand(x.Expr, precAssign)
+	case *MatchExpr:
+		p.print("match (", x.Cond, ") {\n")
+		p.indent++
+		for _, arm := range x.Arms {
+			p.print(arm, ",\n")
+		}
+		p.indent--
+		p.print("}")
 	case *VariableExpr:
 		if nameIdent, ok := x.Name.(*Ident); ok {
 			p.print("$", nameIdent.Name)
@@ -462,30 +752,65 @@ func (p *printer) expr(n Expr) {
 			p.print("${", x.Name, "}")
 		}
 	case *YieldExpr:
-		if x.Key == nil {
-			p.print("yield ", x.Value)
-		} else {
-			p.print("yield ", x.Key, " => ", x.Value)
+		p.print("yield")
+		if x.Key != nil {
+			p.print(" ")
+			p.operand(x.Key, precAssign)
+			p.print(" =>")
+		}
+		if x.Value != nil {
+			p.print(" ")
+			p.operand(x.Value, precAssign)
 		}
 	case *YieldFromExpr:
-		p.print("yield from ", x.Expr)
+		p.print("yield from ")
+		p.operand(x.Expr, precAssign)
 	case *FuncCallExpr:
-		p.print(x.Name, "(", x.Args, ")")
+		p.className(x.Name)
+		p.print("(", x.Args, ")")
 	case *NewExpr:
-		p.print("new ", x.Class, "(", x.Args, ")")
+		p.print("new ")
+		if class, ok := x.Class.(*ClassStmt); ok {
+			// 匿名类的参数位于 class 关键字之后
+			p.print("class")
+			if len(x.Args) > 0 {
+				p.print("(", x.Args, ")")
+			}
+			p.classHeader(class)
+			break
+		}
+		p.className(x.Class)
+		p.print("(", x.Args, ")")
 	case *MethodCallExpr:
+		p.operand(x.Var, precPrimary)
 		if !x.Nullsafe {
-			p.print(x.Var, "->", x.Name, "(", x.Args, ")")
+			p.print("->")
 		} else {
-			p.print(x.Var, "?->", x.Name, "(", x.Args, ")")
+			p.print("?->")
 		}
+		p.memberName(x.Name)
+		p.print("(", x.Args, ")")
 	case *StaticCallExpr:
-		p.print(x.Class, "::", x.Name, "(", x.Args, ")")
+		p.className(x.Class)
+		p.print("::")
+		p.memberName(x.Name)
+		p.print("(", x.Args, ")")
 	default:
 		p.unexpect(n)
 	}
 }
 
+// classHeader 类名之后的 extends、implements 及类体
+func (p *printer) classHeader(x *ClassStmt) {
+	if x.Extends != nil {
+		p.print(" extends ", x.Extends)
+	}
+	if len(x.Implements) > 0 {
+		p.print(" implements ", x.Implements)
+	}
+	p.print("\n{\n", x.Stmts, "}")
+}
+
 func (p *printer) stmtComments(n Stmt) {
 	for _, comment := range n.Comments() {
 		p.print(comment.Text, "\n")
@@ -623,13 +948,7 @@ func (p *printer) stmt(n Stmt) {
 		if x.Name != nil {
 			p.print(" ", x.Name)
 		}
-		if x.Extends != nil {
-			p.print(" extends ", x.Extends)
-		}
-		if len(x.Implements) > 0 {
-			p.print(" implements ", x.Implements)
-		}
-		p.print("\n{\n", x.Stmts, "}")
+		p.classHeader(x)
 	case *ClassConstStmt:
 		if x.Flags != 0 {
 			p.flags(x.Flags)
@@ -644,6 +963,9 @@ func (p *printer) stmt(n Stmt) {
 		if x.Flags != 0 {
 			p.flags(x.Flags)
 			p.print(" ")
+		} else {
+			// 无修饰符的属性需要 var 声明
+			p.print("var ")
 		}
 		if x.Type != nil {
 			p.print(x.Type, " ")
@@ -0,0 +1,270 @@
+package ir
+
+// Rewriter 改写节点的回调，未设置的回调不改写
+type Rewriter struct {
+	// Pre 遍历节点前调用，返回 false 时不遍历也不改写其子节点
+	Pre func(n Node) bool
+	// Expr 子节点改写后调用，返回替换原位置的表达式。
+	// 仅用于类型为 Expr 的位置，*VariableExpr 等具体类型的位置只遍历不替换
+	Expr func(e Expr) Expr
+	// Stmts 语句列表中的语句改写后调用，返回替换的语句列表
+	Stmts func(list []Stmt) []Stmt
+}
+
+// Rewrite 深度优先改写节点 n 的子节点，n 本身不会被替换
+func Rewrite(n Node, r Rewriter) {
+	if n == nil || isNilNode(n) {
+		return
+	}
+	if r.Pre != nil && !r.Pre(n) {
+		return
+	}
+	r.children(n)
+}
+
+func (r Rewriter) expr(e Expr) Expr {
+	if e == nil || isNilNode(e) {
+		return e
+	}
+	Rewrite(e, r)
+	if r.Expr != nil {
+		return r.Expr(e)
+	}
+	return e
+}
+
+func (r Rewriter) exprs(list []Expr) {
+	for i, e := range list {
+		list[i] = r.expr(e)
+	}
+}
+
+// node 可能为 Expr 的位置(如 Name|Expr)
+func (r Rewriter) node(n Node) Node {
+	if e, ok := n.(Expr); ok {
+		return r.expr(e)
+	}
+	Rewrite(n, r)
+	return n
+}
+
+func (r Rewriter) stmts(list []Stmt) []Stmt {
+	for _, stmt := range list {
+		Rewrite(stmt, r)
+	}
+	if r.Stmts != nil {
+		return r.Stmts(list)
+	}
+	return list
+}
+
+func rewriteList[T Node](r Rewriter, nodes []T) {
+	for _, n := range nodes {
+		Rewrite(n, r)
+	}
+}
+
+func (r Rewriter) children(n Node) {
+	switch x := n.(type) {
+	// misc
+	case *Arg:
+		x.Value = r.expr(x.Value)
+	case *Param:
+		Rewrite(x.Var, r)
+		x.Default = r.expr(x.Default)
+	case *MatchArm:
+		r.exprs(x.Conds)
+		x.Body = r.expr(x.Body)
+	case *Name, *Ident, *Comment:
+		// leaf
+	// type
+	case *SimpleType, *IntersectionType, *UnionType, *NullableType:
+		// 类型声明中没有表达式
+	// expr
+	case *NullLit, *BoolLit, *IntLit, *FloatLit, *StringLit, *MagicConstExpr:
+		// leaf
+	case *ArrayExpr:
+		rewriteList(r, x.Items)
+	case *ArrayItemExpr:
+		x.Key = r.expr(x.Key)
+		x.Value = r.expr(x.Value)
+	case *ClosureExpr:
+		rewriteList(r, x.Params)
+		rewriteList(r, x.Uses)
+		x.Stmts = r.stmts(x.Stmts)
+	case *ClosureUseExpr:
+		Rewrite(x.Var, r)
+	case *ArrowFunctionExpr:
+		rewriteList(r, x.Params)
+		x.Expr = r.expr(x.Expr)
+	case *IndexExpr:
+		x.Var = r.expr(x.Var)
+		x.Dim = r.expr(x.Dim)
+	case *CastExpr:
+		x.Expr = r.expr(x.Expr)
+	case *UnaryExpr:
+		x.Var = r.expr(x.Var)
+	case *BinaryOpExpr:
+		x.Left = r.expr(x.Left)
+		x.Right = r.expr(x.Right)
+	case *AssignExpr:
+		x.Var = r.expr(x.Var)
+		x.Expr = r.expr(x.Expr)
+	case *AssignOpExpr:
+		x.Var = r.expr(x.Var)
+		x.Expr = r.expr(x.Expr)
+	case *AssignRefExpr:
+		x.Var = r.expr(x.Var)
+		x.Expr = r.expr(x.Expr)
+	case *IssetExpr:
+		r.exprs(x.Vars)
+	case *EmptyExpr:
+		x.Expr = r.expr(x.Expr)
+	case *EvalExpr:
+		x.Expr = r.expr(x.Expr)
+	case *IncludeExpr:
+		x.Expr = r.expr(x.Expr)
+	case *CloneExpr:
+		x.Expr = r.expr(x.Expr)
+	case *ErrorSuppressExpr:
+		x.Expr = r.expr(x.Expr)
+	case *ExitExpr:
+		x.Expr = r.expr(x.Expr)
+	case *ConstFetchExpr:
+		// leaf
+	case *ClassConstFetchExpr:
+		x.Class = r.node(x.Class)
+	case *InstanceofExpr:
+		x.Expr = r.expr(x.Expr)
+		x.Class = r.node(x.Class)
+	case *ListExpr:
+		rewriteList(r, x.Items)
+	case *PrintExpr:
+		x.Expr = r.expr(x.Expr)
+	case *PropertyFetchExpr:
+		x.Var = r.expr(x.Var)
+		x.Name = r.node(x.Name)
+	case *StaticPropertyFetchExpr:
+		x.Class = r.node(x.Class)
+		x.Name = r.node(x.Name)
+	case *ShellExecExpr:
+		r.exprs(x.Parts)
+	case *TernaryExpr:
+		x.Cond = r.expr(x.Cond)
+		x.If = r.expr(x.If)
+		x.Else = r.expr(x.Else)
+	case *ThrowExpr:
+		x.Expr = r.expr(x.Expr)
+	case *MatchExpr:
+		x.Cond = r.expr(x.Cond)
+		rewriteList(r, x.Arms)
+	case *VariableExpr:
+		x.Name = r.node(x.Name)
+	case *YieldExpr:
+		x.Key = r.expr(x.Key)
+		x.Value = r.expr(x.Value)
+	case *YieldFromExpr:
+		x.Expr = r.expr(x.Expr)
+	case *FuncCallExpr:
+		x.Name = r.node(x.Name)
+		rewriteList(r, x.Args)
+	case *NewExpr:
+		x.Class = r.node(x.Class)
+		rewriteList(r, x.Args)
+	case *MethodCallExpr:
+		x.Var = r.expr(x.Var)
+		x.Name = r.node(x.Name)
+		rewriteList(r, x.Args)
+	case *StaticCallExpr:
+		x.Class = r.node(x.Class)
+		x.Name = r.node(x.Name)
+		rewriteList(r, x.Args)
+	// stmt
+	case *EmptyStmt, *LabelStmt, *GotoStmt, *UseStmt:
+		// leaf
+	case *BlockStmt:
+		x.List = r.stmts(x.List)
+	case *ExprStmt:
+		x.Expr = r.expr(x.Expr)
+	case *ReturnStmt:
+		x.Expr = r.expr(x.Expr)
+	case *IfStmt:
+		x.Cond = r.expr(x.Cond)
+		x.Stmts = r.stmts(x.Stmts)
+		rewriteList(r, x.Elseifs)
+		Rewrite(x.Else, r)
+	case *ElseIfStmt:
+		x.Cond = r.expr(x.Cond)
+		x.Stmts = r.stmts(x.Stmts)
+	case *ElseStmt:
+		x.Stmts = r.stmts(x.Stmts)
+	case *SwitchStmt:
+		x.Cond = r.expr(x.Cond)
+		rewriteList(r, x.Cases)
+	case *CaseStmt:
+		x.Cond = r.expr(x.Cond)
+		x.Stmts = r.stmts(x.Stmts)
+	case *ForStmt:
+		r.exprs(x.Init)
+		r.exprs(x.Cond)
+		r.exprs(x.Loop)
+		x.Stmts = r.stmts(x.Stmts)
+	case *ForeachStmt:
+		x.Expr = r.expr(x.Expr)
+		x.KeyVar = r.expr(x.KeyVar)
+		x.ValueVar = r.expr(x.ValueVar)
+		x.Stmts = r.stmts(x.Stmts)
+	case *BreakStmt:
+		x.Num = r.expr(x.Num)
+	case *ContinueStmt:
+		x.Num = r.expr(x.Num)
+	case *WhileStmt:
+		x.Cond = r.expr(x.Cond)
+		x.Stmts = r.stmts(x.Stmts)
+	case *DoStmt:
+		x.Stmts = r.stmts(x.Stmts)
+		x.Cond = r.expr(x.Cond)
+	case *TryCatchStmt:
+		x.Stmts = r.stmts(x.Stmts)
+		rewriteList(r, x.Catches)
+		Rewrite(x.Finally, r)
+	case *CatchStmt:
+		Rewrite(x.Var, r)
+		x.Stmts = r.stmts(x.Stmts)
+	case *FinallyStmt:
+		x.Stmts = r.stmts(x.Stmts)
+	case *ConstStmt:
+		x.Value = r.expr(x.Value)
+	case *EchoStmt:
+		r.exprs(x.Exprs)
+	case *GlobalStmt:
+		x.Var = r.expr(x.Var)
+	case *StaticStmt:
+		Rewrite(x.Var, r)
+		x.Default = r.expr(x.Default)
+	case *UnsetStmt:
+		x.Var = r.expr(x.Var)
+	case *NamespaceStmt:
+		x.Stmts = r.stmts(x.Stmts)
+	case *FunctionStmt:
+		rewriteList(r, x.Params)
+		x.Stmts = r.stmts(x.Stmts)
+	case *InterfaceStmt:
+		x.Stmts = r.stmts(x.Stmts)
+	case *ClassStmt:
+		x.Stmts = r.stmts(x.Stmts)
+	case *ClassConstStmt:
+		x.Value = r.expr(x.Value)
+	case *PropertyStmt:
+		x.Default = r.expr(x.Default)
+	case *ClassMethodStmt:
+		rewriteList(r, x.Params)
+		if x.Stmts != nil {
+			x.Stmts = r.stmts(x.Stmts)
+		}
+	case *TraitStmt:
+		x.Stmts = r.stmts(x.Stmts)
+	case *TraitUseStmt, *TraitUseAdaptationAliasStmt, *TraitUseAdaptationPrecedenceStmt:
+		// 没有表达式
+	}
+}
@@ -7,6 +7,7 @@ type Visitor interface {
 	VisitName(n *Name)
 	VisitIdent(n *Ident)
 	VisitParam(n *Param)
+	VisitMatchArm(n *MatchArm)
 	// Type
 	VisitSimpleType(n *SimpleType)
 	VisitIntersectionType(n *IntersectionType)
@@ -48,6 +49,7 @@ type Visitor interface {
 	VisitShellExecExpr(n *ShellExecExpr)
 	VisitTernaryExpr(n *TernaryExpr)
 	VisitThrowExpr(n *ThrowExpr)
+	VisitMatchExpr(n *MatchExpr)
 	VisitVariableExpr(n *VariableExpr)
 	VisitYieldExpr(n *YieldExpr)
 	VisitYieldFromExpr(n *YieldFromExpr)
@@ -113,6 +115,8 @@ func Visit(v Visitor, n Node) error {
 		v.VisitParam(x)
 	case *Arg:
 		v.VisitArg(x)
+	case *MatchArm:
+		v.VisitMatchArm(x)
 	case Expr:
 		return VisitExpr(v, x)
 	case Stmt:
@@ -223,6 +227,8 @@ func VisitExpr(v Visitor, n Expr) error {
 		v.VisitTernaryExpr(x)
 	case *ThrowExpr:
 		v.VisitThrowExpr(x)
+	case *MatchExpr:
+		v.VisitMatchExpr(x)
 	case *VariableExpr:
 		v.VisitVariableExpr(x)
 	case *YieldExpr:
@@ -0,0 +1,274 @@
+package ir
+
+// Inspect 深度优先遍历节点 n 及其子节点，与 go/ast.Inspect 一致：
+// 对每个节点调用 f(node)，f 返回 true 时继续遍历其子节点，所有子节点遍历后调用 f(nil)
+func Inspect(n Node, f func(Node) bool) {
+	if n == nil || isNilNode(n) || !f(n) {
+		return
+	}
+	walkChildren(n, f)
+	f(nil)
+}
+
+// InspectList 依次遍历节点列表
+func InspectList[T Node](nodes []T, f func(Node) bool) {
+	for _, n := range nodes {
+		Inspect(n, f)
+	}
+}
+
+// isNilNode 接口中的 nil 指针(如未设置的可选子节点)视为不存在
+func isNilNode(n Node) bool {
+	switch x := n.(type) {
+	case *Name:
+		return x == nil
+	case *Ident:
+		return x == nil
+	case *VariableExpr:
+		return x == nil
+	case *ArrayItemExpr:
+		return x == nil
+	case *ElseStmt:
+		return x == nil
+	case *FinallyStmt:
+		return x == nil
+	case *SimpleType:
+		return x == nil
+	}
+	return false
+}
+
+func walkChildren(n Node, f func(Node) bool) {
+	switch x := n.(type) {
+	// misc
+	case *Arg:
+		Inspect(x.Value, f)
+	case *Param:
+		Inspect(x.Type, f)
+		Inspect(x.Var, f)
+		Inspect(x.Default, f)
+	case *MatchArm:
+		InspectList(x.Conds, f)
+		Inspect(x.Body, f)
+	case *Name, *Ident, *Comment:
+		// leaf
+	// type
+	case *SimpleType:
+		Inspect(x.Name, f)
+	case *IntersectionType:
+		InspectList(x.Types, f)
+	case *UnionType:
+		InspectList(x.Types, f)
+	case *NullableType:
+		Inspect(x.Type, f)
+	// expr
+	case *NullLit, *BoolLit, *IntLit, *FloatLit, *StringLit, *MagicConstExpr:
+		// leaf
+	case *ArrayExpr:
+		InspectList(x.Items, f)
+	case *ArrayItemExpr:
+		Inspect(x.Key, f)
+		Inspect(x.Value, f)
+	case *ClosureExpr:
+		InspectList(x.Params, f)
+		InspectList(x.Uses, f)
+		Inspect(x.ReturnType, f)
+		InspectList(x.Stmts, f)
+	case *ClosureUseExpr:
+		Inspect(x.Var, f)
+	case *ArrowFunctionExpr:
+		InspectList(x.Params, f)
+		Inspect(x.ReturnType, f)
+		Inspect(x.Expr, f)
+	case *IndexExpr:
+		Inspect(x.Var, f)
+		Inspect(x.Dim, f)
+	case *CastExpr:
+		Inspect(x.Expr, f)
+	case *UnaryExpr:
+		Inspect(x.Var, f)
+	case *BinaryOpExpr:
+		Inspect(x.Left, f)
+		Inspect(x.Right, f)
+	case *AssignExpr:
+		Inspect(x.Var, f)
+		Inspect(x.Expr, f)
+	case *AssignOpExpr:
+		Inspect(x.Var, f)
+		Inspect(x.Expr, f)
+	case *AssignRefExpr:
+		Inspect(x.Var, f)
+		Inspect(x.Expr, f)
+	case *IssetExpr:
+		InspectList(x.Vars, f)
+	case *EmptyExpr:
+		Inspect(x.Expr, f)
+	case *EvalExpr:
+		Inspect(x.Expr, f)
+	case *IncludeExpr:
+		Inspect(x.Expr, f)
+	case *CloneExpr:
+		Inspect(x.Expr, f)
+	case *ErrorSuppressExpr:
+		Inspect(x.Expr, f)
+	case *ExitExpr:
+		Inspect(x.Expr, f)
+	case *ConstFetchExpr:
+		Inspect(x.Name, f)
+	case *ClassConstFetchExpr:
+		Inspect(x.Class, f)
+		Inspect(x.Name, f)
+	case *InstanceofExpr:
+		Inspect(x.Expr, f)
+		Inspect(x.Class, f)
+	case *ListExpr:
+		InspectList(x.Items, f)
+	case *PrintExpr:
+		Inspect(x.Expr, f)
+	case *PropertyFetchExpr:
+		Inspect(x.Var, f)
+		Inspect(x.Name, f)
+	case *StaticPropertyFetchExpr:
+		Inspect(x.Class, f)
+		Inspect(x.Name, f)
+	case *ShellExecExpr:
+		InspectList(x.Parts, f)
+	case *TernaryExpr:
+		Inspect(x.Cond, f)
+		Inspect(x.If, f)
+		Inspect(x.Else, f)
+	case *ThrowExpr:
+		Inspect(x.Expr, f)
+	case *MatchExpr:
+		Inspect(x.Cond, f)
+		InspectList(x.Arms, f)
+	case *VariableExpr:
+		Inspect(x.Name, f)
+	case *YieldExpr:
+		Inspect(x.Key, f)
+		Inspect(x.Value, f)
+	case *YieldFromExpr:
+		Inspect(x.Expr, f)
+	case *FuncCallExpr:
+		Inspect(x.Name, f)
+		InspectList(x.Args, f)
+	case *NewExpr:
+		Inspect(x.Class, f)
+		InspectList(x.Args, f)
+	case *MethodCallExpr:
+		Inspect(x.Var, f)
+		Inspect(x.Name, f)
+		InspectList(x.Args, f)
+	case *StaticCallExpr:
+		Inspect(x.Class, f)
+		Inspect(x.Name, f)
+		InspectList(x.Args, f)
+	// stmt
+	case *EmptyStmt:
+		// leaf
+	case *BlockStmt:
+		InspectList(x.List, f)
+	case *ExprStmt:
+		Inspect(x.Expr, f)
+	case *ReturnStmt:
+		Inspect(x.Expr, f)
+	case *LabelStmt:
+		Inspect(x.Name, f)
+	case *GotoStmt:
+		Inspect(x.Name, f)
+	case *IfStmt:
+		Inspect(x.Cond, f)
+		InspectList(x.Stmts, f)
+		InspectList(x.Elseifs, f)
+		Inspect(x.Else, f)
+	case *ElseIfStmt:
+		Inspect(x.Cond, f)
+		InspectList(x.Stmts, f)
+	case *ElseStmt:
+		InspectList(x.Stmts, f)
+	case *SwitchStmt:
+		Inspect(x.Cond, f)
+		InspectList(x.Cases, f)
+	case *CaseStmt:
+		Inspect(x.Cond, f)
+		InspectList(x.Stmts, f)
+	case *ForStmt:
+		InspectList(x.Init, f)
+		InspectList(x.Cond, f)
+		InspectList(x.Loop, f)
+		InspectList(x.Stmts, f)
+	case *ForeachStmt:
+		Inspect(x.Expr, f)
+		Inspect(x.KeyVar, f)
+		Inspect(x.ValueVar, f)
+		InspectList(x.Stmts, f)
+	case *BreakStmt:
+		Inspect(x.Num, f)
+	case *ContinueStmt:
+		Inspect(x.Num, f)
+	case *WhileStmt:
+		Inspect(x.Cond, f)
+		InspectList(x.Stmts, f)
+	case *DoStmt:
+		InspectList(x.Stmts, f)
+		Inspect(x.Cond, f)
+	case *TryCatchStmt:
+		InspectList(x.Stmts, f)
+		InspectList(x.Catches, f)
+		Inspect(x.Finally, f)
+	case *CatchStmt:
+		InspectList(x.Types, f)
+		Inspect(x.Var, f)
+		InspectList(x.Stmts, f)
+	case *FinallyStmt:
+		InspectList(x.Stmts, f)
+	case *ConstStmt:
+		Inspect(x.Name, f)
+		Inspect(x.Value, f)
+	case *EchoStmt:
+		InspectList(x.Exprs, f)
+	case *GlobalStmt:
+		Inspect(x.Var, f)
+	case *StaticStmt:
+		Inspect(x.Var, f)
+		Inspect(x.Default, f)
+	case *UnsetStmt:
+		Inspect(x.Var, f)
+	case *UseStmt:
+		Inspect(x.Name, f)
+	case *NamespaceStmt:
+		InspectList(x.Stmts, f)
+	case *FunctionStmt:
+		Inspect(x.Name, f)
+		InspectList(x.Params, f)
+		Inspect(x.ReturnType, f)
+		InspectList(x.Stmts, f)
+	case *InterfaceStmt:
+		InspectList(x.Extends, f)
+		InspectList(x.Stmts, f)
+	case *ClassStmt:
+		Inspect(x.Extends, f)
+		InspectList(x.Implements, f)
+		InspectList(x.Stmts, f)
+	case *ClassConstStmt:
+		Inspect(x.Type, f)
+		Inspect(x.Value, f)
+	case *PropertyStmt:
+		Inspect(x.Type, f)
+		Inspect(x.Default, f)
+	case *ClassMethodStmt:
+		InspectList(x.Params, f)
+		Inspect(x.ReturnType, f)
+		InspectList(x.Stmts, f)
+	case *TraitStmt:
+		InspectList(x.Stmts, f)
+	case *TraitUseStmt:
+		InspectList(x.Traits, f)
+		InspectList(x.Adaptations, f)
+	case *TraitUseAdaptationAliasStmt:
+		Inspect(x.Trait, f)
+	case *TraitUseAdaptationPrecedenceStmt:
+		Inspect(x.Trait, f)
+		InspectList(x.Insteadof, f)
+	}
+}
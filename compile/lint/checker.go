@@ -0,0 +1,464 @@
+package lint
+
+import (
+	"fmt"
+	"github.com/heyuuu/gophp/compile/diag"
+	"github.com/heyuuu/gophp/compile/flow"
+	"github.com/heyuuu/gophp/compile/ir"
+	"github.com/heyuuu/gophp/php/lang"
+	"github.com/heyuuu/gophp/php/types"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// globalVars 任意作用域中都已定义的变量
+var globalVars = map[string]bool{
+	"this":                 true,
+	"GLOBALS":              true,
+	"_SERVER":              true,
+	"_GET":                 true,
+	"_POST":                true,
+	"_FILES":               true,
+	"_COOKIE":              true,
+	"_SESSION":             true,
+	"_REQUEST":             true,
+	"_ENV":                 true,
+	"http_response_header": true,
+	"php_errormsg":         true,
+}
+
+type checker struct {
+	cfg Config
+	// funcs 所有文件中声明的函数，键为小写的完全限定名称
+	funcs map[string]*ir.FunctionStmt
+	// guarded function_exists('name') 检查过的函数名，调用时不报告未知函数
+	guarded map[string]bool
+
+	diags   diag.List
+	byValue map[*ir.VariableExpr]bool
+}
+
+func newChecker(cfg Config) *checker {
+	return &checker{cfg: cfg, funcs: map[string]*ir.FunctionStmt{}, guarded: map[string]bool{}}
+}
+
+// declare 记录文件中声明的函数，包括条件声明的函数
+func (c *checker) declare(f *ir.File) {
+	for _, ns := range f.Namespaces {
+		ir.Inspect(ns, func(n ir.Node) bool {
+			switch x := n.(type) {
+			case *ir.FunctionStmt:
+				name := x.Name.Name
+				if x.NamespacedName != nil {
+					name = x.NamespacedName.ToString()
+				}
+				c.funcs[strings.ToLower(name)] = x
+			case *ir.FuncCallExpr:
+				if isFunc(x, "function_exists") && len(x.Args) > 0 {
+					if lit, ok := x.Args[0].Value.(*ir.StringLit); ok {
+						c.guarded[strings.ToLower(strings.TrimPrefix(lit.Value, "\\"))] = true
+					}
+				}
+			}
+			return true
+		})
+	}
+}
+
+func (c *checker) checkFile(f *ir.File) diag.List {
+	c.diags = nil
+	c.byValue = map[*ir.VariableExpr]bool{}
+
+	// 调用及数组字面量
+	for _, ns := range f.Namespaces {
+		ir.Inspect(ns, func(n ir.Node) bool {
+			switch x := n.(type) {
+			case *ir.FuncCallExpr:
+				c.call(x)
+			case *ir.ArrayExpr:
+				c.arrayKeys(x)
+			}
+			return true
+		})
+	}
+
+	// 顶层代码及各函数体
+	for _, ns := range f.Namespaces {
+		g := flow.Body(ns.Stmts)
+		c.unreachable(g, ns.Stmts, true)
+		ir.Inspect(ns, func(n ir.Node) bool {
+			if fn, ok := n.(ir.FunctionLike); ok {
+				c.function(fn)
+			}
+			return true
+		})
+	}
+
+	sort.SliceStable(c.diags, func(i, j int) bool {
+		return c.diags[i].Pos.Offset < c.diags[j].Pos.Offset
+	})
+	return c.diags
+}
+
+func (c *checker) warn(code diag.Code, n ir.Node, format string, args ...any) {
+	c.diags.Add(diag.SeverityWarning, code, fmt.Sprintf(format, args...), n.Pos(), n.End())
+}
+
+// lookup 调用的函数名对应的文件内函数或内置函数，命名空间内的非限定名称优先匹配命名空间下的函数
+func (c *checker) lookup(name *ir.Name) (user *ir.FunctionStmt, builtin *types.FunctionDecl, known bool) {
+	var names []string
+	if name.Resolved != nil {
+		names = append(names, name.Resolved.ToString())
+	}
+	if name.Resolved == nil || name.Fallback {
+		names = append(names, name.ToString())
+	}
+	for _, n := range names {
+		lcName := strings.ToLower(n)
+		if fn := c.funcs[lcName]; fn != nil {
+			return fn, nil, true
+		}
+		if fn, ok := c.cfg.Funcs[lcName]; ok {
+			return nil, &fn, true
+		}
+		if c.guarded[lcName] {
+			return nil, nil, true
+		}
+	}
+	return nil, nil, false
+}
+
+// call 检查函数调用：未知函数及内置函数的参数个数，并记录按值传递的变量实参
+func (c *checker) call(x *ir.FuncCallExpr) {
+	name, ok := x.Name.(*ir.Name)
+	if !ok {
+		return
+	}
+	if c.cfg.Funcs == nil {
+		// 不检查内置函数时，只能确定文件内函数的参数
+		if user, _, _ := c.lookup(name); user != nil {
+			c.markArgs(x.Args, func(i int) bool { return paramByRef(user.Params, i) })
+		}
+		return
+	}
+
+	user, builtin, known := c.lookup(name)
+	switch {
+	case !known:
+		fullName := name.ToString()
+		if name.Resolved != nil {
+			fullName = name.Resolved.ToString()
+		}
+		c.warn(CodeUnknownFunction, x, "Call to undefined function %s()", fullName)
+	case user != nil:
+		c.markArgs(x.Args, func(i int) bool { return paramByRef(user.Params, i) })
+	case builtin != nil:
+		c.markArgs(x.Args, func(i int) bool { return argByRef(builtin.ArgInfos(), i) })
+		c.argCount(x, builtin)
+	}
+}
+
+// argCount 检查内置函数的参数个数，提示与运行时一致
+func (c *checker) argCount(x *ir.FuncCallExpr, fn *types.FunctionDecl) {
+	for _, arg := range x.Args {
+		if arg.Unpack {
+			return
+		}
+	}
+	numArgs, minNumArgs, maxNumArgs := len(x.Args), fn.MinNumArgs(), fn.MaxNumArgs()
+	switch {
+	case numArgs >= minNumArgs && (maxNumArgs < 0 || numArgs <= maxNumArgs):
+		return
+	case minNumArgs == maxNumArgs:
+		c.warn(CodeArgumentCount, x, "%s() expects exactly %d parameter%s, %d given", fn.Name(), minNumArgs, lang.Cond(minNumArgs == 1, "", "s"), numArgs)
+	case numArgs < minNumArgs:
+		c.warn(CodeArgumentCount, x, "%s() expects at least %d parameter%s, %d given", fn.Name(), minNumArgs, lang.Cond(minNumArgs == 1, "", "s"), numArgs)
+	default:
+		c.warn(CodeArgumentCount, x, "%s() expects at most %d parameter%s, %d given", fn.Name(), maxNumArgs, lang.Cond(maxNumArgs == 1, "", "s"), numArgs)
+	}
+}
+
+// markArgs 记录确定按值传递的变量实参，其读取会检查是否未定义
+func (c *checker) markArgs(args []*ir.Arg, byRef func(i int) bool) {
+	for i, arg := range args {
+		if arg.Unpack {
+			return
+		}
+		e := arg.Value
+		for {
+			if index, ok := e.(*ir.IndexExpr); ok {
+				e = index.Var
+				continue
+			}
+			break
+		}
+		if v, ok := e.(*ir.VariableExpr); ok {
+			c.byValue[v] = !byRef(i)
+		}
+	}
+}
+
+func paramByRef(params []*ir.Param, i int) bool {
+	if i < len(params) {
+		return params[i].ByRef
+	}
+	return len(params) > 0 && params[len(params)-1].Variadic && params[len(params)-1].ByRef
+}
+
+func argByRef(infos []types.ArgInfo, i int) bool {
+	if i < len(infos) {
+		return infos[i].ByRef()
+	}
+	return len(infos) > 0 && infos[len(infos)-1].Variadic() && infos[len(infos)-1].ByRef()
+}
+
+func isFunc(x *ir.FuncCallExpr, lcName string) bool {
+	name, ok := x.Name.(*ir.Name)
+	return ok && len(name.Parts) > 0 && strings.ToLower(name.Parts[len(name.Parts)-1]) == lcName
+}
+
+// function 检查函数体内的变量及不可达代码
+func (c *checker) function(fn ir.FunctionLike) {
+	g := flow.Func(fn)
+	if g == nil {
+		return
+	}
+	switch x := fn.(type) {
+	case *ir.FunctionStmt:
+		c.unreachable(g, x.Stmts, true)
+		c.variables(g, x.Params, true)
+	case *ir.ClassMethodStmt:
+		c.unreachable(g, x.Stmts, true)
+		// 方法的参数由父类或接口决定，不检查未使用的参数
+		c.variables(g, x.Params, false)
+	case *ir.ClosureExpr:
+		c.unreachable(g, x.Stmts, true)
+		c.variables(g, x.Params, true)
+	case *ir.ArrowFunctionExpr:
+		c.variables(g, x.Params, true)
+	}
+}
+
+// variables 检查未定义的变量、未使用的变量及参数
+func (c *checker) variables(g *flow.Graph, params []*ir.Param, checkParams bool) {
+	if g.Dynamic {
+		return
+	}
+
+	refs := map[string][]*flow.Ref{}
+	chains := g.DefUse()
+	for _, block := range g.Blocks {
+		for _, n := range block.Nodes {
+			for _, ref := range g.Refs(n) {
+				refs[ref.Name] = append(refs[ref.Name], ref)
+				if block.Reachable() && c.isUndefined(chains, ref) {
+					c.warn(CodeUndefinedVariable, ref.Var, "Undefined variable: %s", ref.Name)
+				}
+			}
+		}
+	}
+
+	isParam := map[string]bool{}
+	for _, p := range params {
+		if name, ok := p.Var.Name.(*ir.Ident); ok {
+			isParam[name.Name] = true
+		}
+	}
+	if checkParams {
+		// 之后有被使用的参数时，未使用的参数用于占位，不报告
+		for i := len(params) - 1; i >= 0; i-- {
+			p := params[i]
+			name, ok := p.Var.Name.(*ir.Ident)
+			if !ok || p.ByRef || g.Escaped(name.Name) {
+				break
+			}
+			if isRead(refs[name.Name]) {
+				break
+			}
+			c.warn(CodeUnusedParameter, p.Var, "Parameter $%s is never used", name.Name)
+		}
+	}
+
+	for _, name := range g.Names() {
+		if isParam[name] || globalVars[name] || g.Escaped(name) || isRead(refs[name]) {
+			continue
+		}
+		for _, ref := range refs[name] {
+			if ref.Kind != flow.Def && ref.Kind != flow.MayDef {
+				continue
+			}
+			// foreach 的键值变量、catch 的异常变量及闭包捕获的变量常因语法需要而存在
+			switch ref.Node.(type) {
+			case *ir.ForeachStmt, *ir.CatchStmt, *ir.ClosureUseExpr:
+				continue
+			}
+			c.warn(CodeUnusedVariable, ref.Var, "Variable $%s is assigned but never used", name)
+			break
+		}
+	}
+}
+
+// isUndefined 读取时变量在所有路径上都未赋值
+func (c *checker) isUndefined(chains *flow.Chains, ref *flow.Ref) bool {
+	switch ref.Kind {
+	case flow.Use:
+	case flow.Arg:
+		if !c.byValue[ref.Var] {
+			return false
+		}
+	default:
+		return false
+	}
+	return !globalVars[ref.Name] && chains.MaybeUndefined(ref) && len(chains.Defs(ref)) == 0
+}
+
+func isRead(refs []*flow.Ref) bool {
+	for _, ref := range refs {
+		if ref.Kind.Reads() {
+			return true
+		}
+	}
+	return false
+}
+
+// unreachable 报告语句列表中第一条不可达的语句，reachable 为语句列表所在位置是否可达
+func (c *checker) unreachable(g *flow.Graph, stmts []ir.Stmt, reachable bool) {
+	prev := reachable
+	for _, stmt := range stmts {
+		switch stmt.(type) {
+		case *ir.EmptyStmt, *ir.UseStmt, *ir.FunctionStmt, *ir.ClassStmt, *ir.InterfaceStmt, *ir.TraitStmt:
+			// 声明语句不执行代码
+			continue
+		}
+		block := g.StmtBlock(stmt)
+		cur := block != nil && block.Reachable()
+		if _, isLabel := stmt.(*ir.LabelStmt); !isLabel && prev && !cur {
+			c.warn(CodeUnreachableCode, stmt, "Unreachable code")
+		}
+		prev = cur
+		for _, list := range childStmts(stmt) {
+			c.unreachable(g, list, cur)
+		}
+	}
+}
+
+// childStmts 复合语句中的语句列表
+func childStmts(stmt ir.Stmt) [][]ir.Stmt {
+	switch x := stmt.(type) {
+	case *ir.BlockStmt:
+		return [][]ir.Stmt{x.List}
+	case *ir.IfStmt:
+		lists := [][]ir.Stmt{x.Stmts}
+		for _, elseif := range x.Elseifs {
+			lists = append(lists, elseif.Stmts)
+		}
+		if x.Else != nil {
+			lists = append(lists, x.Else.Stmts)
+		}
+		return lists
+	case *ir.WhileStmt:
+		return [][]ir.Stmt{x.Stmts}
+	case *ir.DoStmt:
+		return [][]ir.Stmt{x.Stmts}
+	case *ir.ForStmt:
+		return [][]ir.Stmt{x.Stmts}
+	case *ir.ForeachStmt:
+		return [][]ir.Stmt{x.Stmts}
+	case *ir.SwitchStmt:
+		var lists [][]ir.Stmt
+		for _, c := range x.Cases {
+			lists = append(lists, c.Stmts)
+		}
+		return lists
+	case *ir.TryCatchStmt:
+		lists := [][]ir.Stmt{x.Stmts}
+		for _, catch := range x.Catches {
+			lists = append(lists, catch.Stmts)
+		}
+		if x.Finally != nil {
+			lists = append(lists, x.Finally.Stmts)
+		}
+		return lists
+	}
+	return nil
+}
+
+// arrayKeys 检查数组字面量中的重复键，键按运行时规则转换(如 "1"、1.5、true 均为整数键 1)
+func (c *checker) arrayKeys(x *ir.ArrayExpr) {
+	seen := map[any]bool{}
+	next, nextKnown := 0, true
+	for _, item := range x.Items {
+		if item == nil || item.Unpack {
+			nextKnown = false
+			continue
+		}
+		var key any
+		if item.Key == nil {
+			if !nextKnown {
+				continue
+			}
+			key = next
+		} else {
+			var ok bool
+			if key, ok = literalKey(item.Key); !ok {
+				// 键可能是更大的整数，之后的隐式键无法确定
+				nextKnown = false
+				continue
+			}
+		}
+		if i, isInt := key.(int); isInt && i >= next {
+			next = i + 1
+		}
+		if seen[key] {
+			c.warn(CodeDuplicateArrayKey, item, "Duplicate array key %s", formatKey(key))
+		}
+		seen[key] = true
+	}
+}
+
+// literalKey 字面量作为数组键时的值，为 int 或 string
+func literalKey(e ir.Expr) (any, bool) {
+	switch x := e.(type) {
+	case *ir.IntLit:
+		return x.Value, true
+	case *ir.StringLit:
+		if i, ok := integerString(x.Value); ok {
+			return i, true
+		}
+		return x.Value, true
+	case *ir.FloatLit:
+		if math.IsNaN(x.Value) || math.IsInf(x.Value, 0) {
+			return nil, false
+		}
+		return int(x.Value), true
+	case *ir.BoolLit:
+		return lang.Cond(x.Value, 1, 0), true
+	case *ir.NullLit:
+		return "", true
+	}
+	return nil, false
+}
+
+// integerString 十进制整数形式(无前导 0 及 +)的字符串作为数组键时转为整数
+func integerString(s string) (int, bool) {
+	digits := strings.TrimPrefix(s, "-")
+	if digits == "" || (digits[0] == '0' && len(digits) > 1) || (digits == "0" && s != digits) {
+		return 0, false
+	}
+	for _, ch := range digits {
+		if ch < '0' || ch > '9' {
+			return 0, false
+		}
+	}
+	i, err := strconv.Atoi(s)
+	return i, err == nil
+}
+
+func formatKey(key any) string {
+	if s, ok := key.(string); ok {
+		return "'" + s + "'"
+	}
+	return fmt.Sprint(key)
+}
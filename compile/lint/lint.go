@@ -0,0 +1,194 @@
+// Package lint 在 IR 上对 PHP 代码进行静态检查，检查项超出 php -l 的语法检查：
+//   - 未定义变量：所有路径上都没有赋值的读取
+//   - 未使用的变量及参数：赋值后从未读取的局部变量，函数及闭包中从未读取的参数
+//   - 不可达代码：return、exit、throw、break 等跳转之后不会执行的语句
+//   - 未知函数：既不是文件中声明的函数，也不是已注册模块的内置函数
+//   - 参数个数错误：内置函数调用的参数个数超出其声明的范围
+//   - 重复的数组键：数组字面量中键相同的元素，后者会覆盖前者
+//
+// 变量相关的检查基于 flow 的数据流分析，函数体内有可变变量、extract 等按名称访问符号表的操作时不检查。
+// 顶层代码中的变量可能由 include 所在的作用域定义或使用，只检查不可达代码。
+package lint
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/heyuuu/gophp/compile/ast"
+	"github.com/heyuuu/gophp/compile/diag"
+	"github.com/heyuuu/gophp/compile/ir"
+	"github.com/heyuuu/gophp/compile/parser"
+	"github.com/heyuuu/gophp/compile/transformer"
+	"github.com/heyuuu/gophp/php"
+	"github.com/heyuuu/gophp/php/types"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// 检查项的诊断编码
+const (
+	CodeUndefinedVariable diag.Code = "undefined-variable"
+	CodeUnusedVariable    diag.Code = "unused-variable"
+	CodeUnusedParameter   diag.Code = "unused-parameter"
+	CodeUnreachableCode   diag.Code = "unreachable-code"
+	CodeUnknownFunction   diag.Code = "unknown-function"
+	CodeArgumentCount     diag.Code = "argument-count"
+	CodeDuplicateArrayKey diag.Code = "duplicate-array-key"
+)
+
+// Config 检查配置
+type Config struct {
+	// Funcs 内置函数，键为小写函数名。为 nil 时不检查未知函数及参数个数
+	Funcs map[string]types.FunctionDecl
+}
+
+// EngineFuncs engine 中已注册模块的函数
+func EngineFuncs(engine *php.Engine) map[string]types.FunctionDecl {
+	funcs := map[string]types.FunctionDecl{}
+	for _, m := range engine.Modules() {
+		for _, fn := range m.Data().Functions {
+			funcs[strings.ToLower(fn.Name())] = fn
+		}
+	}
+	return funcs
+}
+
+// Source 待检查的源文件
+type Source struct {
+	Path string
+	Code string
+}
+
+// FileResult 单个文件的检查结果，诊断按位置排序
+type FileResult struct {
+	Path        string
+	Diagnostics diag.List
+}
+
+// LoadPaths 读取文件或目录下的 PHP 文件，目录中跳过隐藏目录，文件按路径排序
+func LoadPaths(paths []string) ([]Source, error) {
+	var files []string
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+		var dirFiles []string
+		err = filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() {
+				if p != path && strings.HasPrefix(d.Name(), ".") {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if filepath.Ext(p) == ".php" {
+				dirFiles = append(dirFiles, p)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		slices.Sort(dirFiles)
+		files = append(files, dirFiles...)
+	}
+
+	sources := make([]Source, 0, len(files))
+	for _, file := range files {
+		code, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		sources = append(sources, Source{Path: file, Code: string(code)})
+	}
+	return sources, nil
+}
+
+// Check 检查一组文件，文件中声明的函数在所有文件中可见。存在语法错误的文件只返回语法错误
+func Check(sources []Source, cfg Config) []*FileResult {
+	results := make([]*FileResult, len(sources))
+	c := newChecker(cfg)
+	var files []*fileUnit
+	for i, src := range sources {
+		results[i] = &FileResult{Path: src.Path}
+		astFile, diags := parser.ParseCodeWithDiagnostics(src.Code, false)
+		if diags.HasErrors() {
+			results[i].Diagnostics = diags.Errors()
+			continue
+		}
+		irFile, err := transformer.TransformFile(astFile)
+		if err != nil {
+			results[i].Diagnostics.Errorf(diag.CodeCompileError, ast.Position{}, "%s", err.Error())
+			continue
+		}
+		irFile.FilePath = src.Path
+		c.declare(irFile)
+		files = append(files, &fileUnit{file: irFile, result: results[i]})
+	}
+	for _, unit := range files {
+		unit.result.Diagnostics = c.checkFile(unit.file)
+	}
+	return results
+}
+
+type fileUnit struct {
+	file   *ir.File
+	result *FileResult
+}
+
+// WriteText 按 `path:line:column: severity: message (code)` 格式逐行输出诊断
+func WriteText(w io.Writer, results []*FileResult) error {
+	for _, result := range results {
+		for _, d := range result.Diagnostics {
+			_, err := fmt.Fprintf(w, "%s:%d:%d: %s: %s (%s)\n", result.Path, d.Pos.Line, d.Pos.Column, d.Severity, d.Message, d.Code)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// jsonDiagnostic JSON 输出中的单条诊断
+type jsonDiagnostic struct {
+	File      string `json:"file"`
+	Line      int    `json:"line"`
+	Column    int    `json:"column"`
+	EndLine   int    `json:"endLine"`
+	EndColumn int    `json:"endColumn"`
+	Severity  string `json:"severity"`
+	Code      string `json:"code"`
+	Message   string `json:"message"`
+}
+
+// WriteJSON 输出诊断的 JSON 数组，没有诊断时输出空数组
+func WriteJSON(w io.Writer, results []*FileResult) error {
+	list := []jsonDiagnostic{}
+	for _, result := range results {
+		for _, d := range result.Diagnostics {
+			list = append(list, jsonDiagnostic{
+				File:      result.Path,
+				Line:      d.Pos.Line,
+				Column:    d.Pos.Column,
+				EndLine:   d.End.Line,
+				EndColumn: d.End.Column,
+				Severity:  d.Severity.String(),
+				Code:      string(d.Code),
+				Message:   d.Message,
+			})
+		}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(list)
+}
@@ -0,0 +1,200 @@
+package lint
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/heyuuu/gophp/php/types"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var testFuncs = map[string]types.FunctionDecl{
+	"strlen":          types.DefFunc("strlen", nil, []types.ArgInfo{types.MakeArgInfo("str", false, false)}, 1, 1),
+	"preg_match":      types.DefFunc("preg_match", nil, []types.ArgInfo{types.MakeArgInfo("pattern", false, false), types.MakeArgInfo("subject", false, false), types.MakeArgInfo("matches", true, false)}, 2, 3),
+	"printf":          types.DefFunc("printf", nil, []types.ArgInfo{types.MakeArgInfo("format", false, false), types.MakeArgInfo("args", false, true)}, 1, -1),
+	"count":           types.DefFunc("count", nil, []types.ArgInfo{types.MakeArgInfo("var", false, false), types.MakeArgInfo("mode", false, false)}, 1, 2),
+	"extract":         types.DefFunc("extract", nil, []types.ArgInfo{types.MakeArgInfo("array", true, false)}, 1, 1),
+	"function_exists": types.DefFunc("function_exists", nil, []types.ArgInfo{types.MakeArgInfo("name", false, false)}, 1, 1),
+}
+
+// checkCode 检查代码，返回 "行号:编码" 形式的诊断，code 从第 2 行开始
+func checkCode(t *testing.T, code string) []string {
+	results := Check([]Source{{Path: "test.php", Code: "<?php\n" + code}}, Config{Funcs: testFuncs})
+	var got []string
+	for _, d := range results[0].Diagnostics {
+		got = append(got, fmt.Sprintf("%d:%s", d.Pos.Line, d.Code))
+	}
+	return got
+}
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		name string
+		code string
+		want []string
+	}{
+		{
+			name: "undefined variable",
+			code: `function f($x) {
+    if ($x) {
+        $a = 1;
+    }
+    echo $a, $b;
+    echo strlen($c);
+    preg_match('/a/', $x, $m);
+    echo $m[0], $this, $GLOBALS;
+}`,
+			want: []string{"6:undefined-variable", "7:undefined-variable"},
+		},
+		{
+			name: "unused variable and parameter",
+			code: `function f($a, $b, $c) {
+    $x = 1;
+    $y = $b;
+    foreach ($y as $k => $v) {}
+    $z = [];
+    $z[] = 1;
+    return $y;
+}`,
+			want: []string{"2:unused-parameter", "3:unused-variable", "6:unused-variable"},
+		},
+		{
+			name: "method parameters and dynamic functions",
+			code: `class A {
+    function m($a) { $b = 1; }
+}
+function f($a) { extract($a); $b = 1; }`,
+			want: []string{"3:unused-variable"},
+		},
+		{
+			name: "closures",
+			code: `function f() {
+    $a = 1;
+    $b = 2;
+    $fn = function ($x) use ($a) { return $a + $y; };
+    return [$fn, fn($p) => $b];
+}`,
+			want: []string{"5:unused-parameter", "5:undefined-variable", "6:unused-parameter"},
+		},
+		{
+			name: "unreachable code",
+			code: `function f($x) {
+    if ($x) {
+        return 1;
+        echo 1;
+        echo 2;
+    }
+    while (true) {
+        break;
+        $x++;
+    }
+    exit;
+    function g() {}
+    echo 3;
+}
+echo 4;
+throw new Exception();
+echo 5;`,
+			want: []string{"5:unreachable-code", "10:unreachable-code", "14:unreachable-code", "18:unreachable-code"},
+		},
+		{
+			name: "unknown function and argument count",
+			code: `namespace N;
+function g() {}
+g();
+\N\g();
+STRLEN('a');
+strlen();
+strlen('a', 'b');
+count();
+printf('%d %d', 1, 2);
+strlen(...['a']);
+h();
+if (function_exists('k')) { k(); }`,
+			want: []string{
+				"7:argument-count",
+				"8:argument-count",
+				"9:argument-count",
+				"12:unknown-function",
+			},
+		},
+		{
+			name: "duplicate array key",
+			code: `$a = ['a' => 1, 'a' => 2];
+$b = [1, 2, 1 => 3, '0' => 4, '01' => 5, true => 6, 1.5 => 7];
+$c = [5 => 1, 2, '6' => 3, null => 4, '' => 5];
+$d = [$k => 1, 2, ...$e, 3];`,
+			want: []string{
+				"2:duplicate-array-key",
+				"3:duplicate-array-key",
+				"3:duplicate-array-key",
+				"3:duplicate-array-key",
+				"3:duplicate-array-key",
+				"4:duplicate-array-key",
+				"4:duplicate-array-key",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := checkCode(t, tt.code); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Check() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckFiles(t *testing.T) {
+	sources := []Source{
+		{Path: "a.php", Code: "<?php\nb(1);\n"},
+		{Path: "b.php", Code: "<?php\nfunction b($x) { return $x; }\n"},
+		{Path: "c.php", Code: "<?php\necho 1 +;\n"},
+	}
+	results := Check(sources, Config{Funcs: testFuncs})
+	if len(results[0].Diagnostics) != 0 || len(results[1].Diagnostics) != 0 {
+		t.Errorf("functions should be visible across files, got %v %v", results[0].Diagnostics, results[1].Diagnostics)
+	}
+	if !results[2].Diagnostics.HasErrors() {
+		t.Errorf("syntax error not reported")
+	}
+
+	// 未配置内置函数时不检查未知函数
+	results = Check(sources[:1], Config{})
+	if len(results[0].Diagnostics) != 0 {
+		t.Errorf("Check() without funcs = %v, want none", results[0].Diagnostics)
+	}
+}
+
+func TestWrite(t *testing.T) {
+	results := Check([]Source{{Path: "a.php", Code: "<?php\nfunction f() {\n    return $a;\n}\n"}}, Config{})
+
+	var text bytes.Buffer
+	if err := WriteText(&text, results); err != nil {
+		t.Fatal(err)
+	}
+	if want := "a.php:3:12: warning: Undefined variable: a (undefined-variable)\n"; text.String() != want {
+		t.Errorf("WriteText() = %q, want %q", text.String(), want)
+	}
+
+	var buf bytes.Buffer
+	if err := WriteJSON(&buf, results); err != nil {
+		t.Fatal(err)
+	}
+	var list []map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &list); err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 || list[0]["code"] != "undefined-variable" || list[0]["line"] != 3.0 || list[0]["endColumn"] != 14.0 {
+		t.Errorf("WriteJSON() = %s", buf.String())
+	}
+
+	buf.Reset()
+	if err := WriteJSON(&buf, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.TrimSpace(buf.String()); got != "[]" {
+		t.Errorf("WriteJSON(nil) = %s, want []", got)
+	}
+}
@@ -0,0 +1,110 @@
+package optimizer
+
+import (
+	"github.com/heyuuu/gophp/compile/ir"
+	"github.com/heyuuu/gophp/php"
+	"github.com/heyuuu/gophp/php/types"
+	"strings"
+)
+
+// builtinConsts 值在编译时即确定的内置常量，不含 PHP_OS 等与运行环境相关的常量
+var builtinConsts = map[string]ir.Expr{
+	"PHP_VERSION":         &ir.StringLit{Value: php.PHP_VERSION},
+	"PHP_MAJOR_VERSION":   &ir.IntLit{Value: php.PHP_MAJOR_VERSION},
+	"PHP_MINOR_VERSION":   &ir.IntLit{Value: php.PHP_MINOR_VERSION},
+	"PHP_RELEASE_VERSION": &ir.IntLit{Value: php.PHP_RELEASE_VERSION},
+	"PHP_EXTRA_VERSION":   &ir.StringLit{Value: php.PHP_EXTRA_VERSION},
+	"PHP_VERSION_ID":      &ir.IntLit{Value: php.PHP_VERSION_ID},
+	"PHP_EOL":             &ir.StringLit{Value: php.PHP_EOL},
+	"PHP_INT_MAX":         &ir.IntLit{Value: types.MaxLong},
+	"PHP_INT_MIN":         &ir.IntLit{Value: types.MinLong},
+	"PHP_INT_SIZE":        &ir.IntLit{Value: types.SizeofLong},
+}
+
+// constant 常量名对应的字面量，无法确定时返回 nil。
+// 文件中定义的常量仅在定义后执行的顶层代码中替换；假设常量不会在其它文件中先定义。
+// 命名空间内的非限定名称，仅在文件中没有定义该命名空间下的同名常量时回退为全局常量
+func (o *optimizer) constant(name *ir.Name) ir.Expr {
+	if name.Resolved == nil {
+		return nil
+	}
+	fullName := name.Resolved.ToString()
+	if name.Fallback && !o.declared[fullName] {
+		fullName = name.ToString()
+	}
+	if lit, ok := o.consts[fullName]; ok {
+		if o.hoisted {
+			return nil
+		}
+		return lit
+	}
+	if o.declared[fullName] {
+		// 尚未执行到定义
+		return nil
+	}
+	return builtinConsts[fullName]
+}
+
+// declare 记录文件中所有顶层语句定义的常量名
+func (o *optimizer) declare(f *ir.File) {
+	for _, ns := range f.Namespaces {
+		for _, stmt := range ns.Stmts {
+			if name, _, ok := constDef(stmt); ok {
+				o.declared[name] = true
+			}
+		}
+	}
+}
+
+// define 记录顶层语句定义的常量：const NAME = 字面量; 或 define('NAME', 字面量);
+func (o *optimizer) define(stmt ir.Stmt) {
+	name, value, ok := constDef(stmt)
+	if !ok {
+		return
+	}
+	if _, ok := o.consts[name]; ok {
+		// 重复定义时运行时保留首次定义的值并产生警告，不再替换
+		o.consts[name] = nil
+		return
+	}
+	if !isLiteral(value) {
+		o.consts[name] = nil
+		return
+	}
+	o.consts[name] = value
+}
+
+// constDef 语句定义的常量名及值
+func constDef(stmt ir.Stmt) (name string, value ir.Expr, ok bool) {
+	switch x := stmt.(type) {
+	case *ir.ConstStmt:
+		name = x.Name.Name
+		if x.NamespacedName != nil {
+			name = x.NamespacedName.ToString()
+		}
+		return name, x.Value, true
+	case *ir.ExprStmt:
+		call, ok := x.Expr.(*ir.FuncCallExpr)
+		if !ok || !isDefineCall(call) {
+			return "", nil, false
+		}
+		nameLit, ok := call.Args[0].Value.(*ir.StringLit)
+		if !ok {
+			return "", nil, false
+		}
+		return strings.TrimPrefix(nameLit.Value, "\\"), call.Args[1].Value, true
+	}
+	return "", nil, false
+}
+
+// isDefineCall 是否为 define(name, value) 调用，带第三个参数(大小写不敏感)时不处理
+func isDefineCall(call *ir.FuncCallExpr) bool {
+	name, ok := call.Name.(*ir.Name)
+	if !ok || name.Resolved == nil || len(call.Args) != 2 || call.Args[0].Unpack || call.Args[1].Unpack {
+		return false
+	}
+	if name.Fallback {
+		return strings.EqualFold(name.ToString(), "define")
+	}
+	return strings.EqualFold(name.Resolved.ToString(), "define")
+}
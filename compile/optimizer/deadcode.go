@@ -0,0 +1,132 @@
+package optimizer
+
+import (
+	"github.com/heyuuu/gophp/compile/ir"
+)
+
+// stmts 删除语句列表中条件为字面量的 if、while 不会执行的分支
+func (o *optimizer) stmts(list []ir.Stmt) []ir.Stmt {
+	var result []ir.Stmt
+	changed := false
+	for i, stmt := range list {
+		var replaced []ir.Stmt
+		var ok bool
+		switch x := stmt.(type) {
+		case *ir.IfStmt:
+			if !hasLabel(x) {
+				replaced, ok = ifBranch(x)
+			}
+		case *ir.WhileStmt:
+			if cond, isLit := isTrue(x.Cond); isLit && !cond && !hasLabel(x) {
+				replaced, ok = nil, true
+			}
+		}
+		if !ok {
+			if changed {
+				result = append(result, stmt)
+			}
+			continue
+		}
+		if !changed {
+			// 非 nil 的空列表，避免方法体被当作抽象方法
+			changed = true
+			result = append(make([]ir.Stmt, 0, len(list)), list[:i]...)
+		}
+		result = append(result, replaced...)
+	}
+	if !changed {
+		return list
+	}
+	return result
+}
+
+// ifBranch 删除 if 语句中条件为 false 的分支。条件为 true 的分支之后的分支均不会执行。
+// 返回替换 if 语句的语句列表，没有可删除的分支时返回 false
+func ifBranch(x *ir.IfStmt) ([]ir.Stmt, bool) {
+	type branch struct {
+		cond  ir.Expr
+		stmts []ir.Stmt
+		pos   ir.Node
+	}
+	branches := []branch{{x.Cond, x.Stmts, x}}
+	for _, elseif := range x.Elseifs {
+		branches = append(branches, branch{elseif.Cond, elseif.Stmts, elseif})
+	}
+
+	changed := false
+	var kept []branch
+	var elseStmts []ir.Stmt
+	var elsePos ir.Node
+	hasElse := x.Else != nil
+	if hasElse {
+		elseStmts, elsePos = x.Else.Stmts, x.Else
+	}
+	for _, b := range branches {
+		cond, ok := isTrue(b.cond)
+		if !ok {
+			kept = append(kept, b)
+			continue
+		}
+		changed = true
+		if cond {
+			// 之后的分支不会执行，该分支成为 else
+			elseStmts, elsePos, hasElse = b.stmts, b.pos, true
+			break
+		}
+	}
+	if !changed {
+		return nil, false
+	}
+
+	if len(kept) == 0 {
+		if !hasElse {
+			return nil, true
+		}
+		if hasDecl(elseStmts) {
+			// 分支内的函数、类为条件声明，保留语句块以免被当作顶层声明提前
+			stmt := &ir.IfStmt{Cond: &ir.BoolLit{Value: true}, Stmts: elseStmts}
+			stmt.SetPos(x.Pos(), x.End())
+			return []ir.Stmt{stmt}, true
+		}
+		return elseStmts, true
+	}
+
+	stmt := &ir.IfStmt{Cond: kept[0].cond, Stmts: kept[0].stmts}
+	stmt.SetPos(x.Pos(), x.End())
+	for _, b := range kept[1:] {
+		elseif := &ir.ElseIfStmt{Cond: b.cond, Stmts: b.stmts}
+		elseif.SetPos(b.pos.Pos(), b.pos.End())
+		stmt.Elseifs = append(stmt.Elseifs, elseif)
+	}
+	if hasElse {
+		stmt.Else = &ir.ElseStmt{Stmts: elseStmts}
+		stmt.Else.SetPos(elsePos.Pos(), elsePos.End())
+	}
+	return []ir.Stmt{stmt}, true
+}
+
+// hasLabel 语句内是否有 goto 标签，分支可能由 goto 跳入，不能删除
+func hasLabel(stmt ir.Stmt) bool {
+	found := false
+	ir.Inspect(stmt, func(n ir.Node) bool {
+		switch n.(type) {
+		case *ir.FunctionStmt, *ir.ClassStmt, *ir.InterfaceStmt, *ir.TraitStmt, *ir.ClosureExpr:
+			return false
+		case *ir.LabelStmt:
+			found = true
+		}
+		return !found
+	})
+	return found
+}
+
+// hasDecl 语句列表中是否有函数、类等声明
+func hasDecl(list []ir.Stmt) bool {
+	for _, stmt := range list {
+		switch stmt.(type) {
+		case *ir.FunctionStmt, *ir.ClassStmt, *ir.InterfaceStmt, *ir.TraitStmt:
+			return true
+		}
+	}
+	return false
+}
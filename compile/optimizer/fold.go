@@ -0,0 +1,208 @@
+package optimizer
+
+import (
+	"github.com/heyuuu/gophp/compile/ast"
+	"github.com/heyuuu/gophp/compile/ir"
+	"github.com/heyuuu/gophp/php"
+	"github.com/heyuuu/gophp/php/types"
+	"math"
+)
+
+// isLiteral 是否为 null、bool、int、float 或 string 字面量
+func isLiteral(e ir.Expr) bool {
+	switch e.(type) {
+	case *ir.NullLit, *ir.BoolLit, *ir.IntLit, *ir.FloatLit, *ir.StringLit:
+		return true
+	}
+	return false
+}
+
+func copyLit(e ir.Expr) ir.Expr {
+	switch x := e.(type) {
+	case *ir.NullLit:
+		return &ir.NullLit{}
+	case *ir.BoolLit:
+		return &ir.BoolLit{Value: x.Value}
+	case *ir.IntLit:
+		return &ir.IntLit{Value: x.Value}
+	case *ir.FloatLit:
+		return &ir.FloatLit{Value: x.Value}
+	case *ir.StringLit:
+		return &ir.StringLit{Value: x.Value}
+	}
+	return nil
+}
+
+func litValue(e ir.Expr) (types.Zval, bool) {
+	switch x := e.(type) {
+	case *ir.NullLit:
+		return types.Null, true
+	case *ir.BoolLit:
+		return types.ZvalBool(x.Value), true
+	case *ir.IntLit:
+		return types.ZvalLong(x.Value), true
+	case *ir.FloatLit:
+		return types.ZvalDouble(x.Value), true
+	case *ir.StringLit:
+		return types.ZvalString(x.Value), true
+	}
+	return types.Undef, false
+}
+
+func valueLit(v types.Zval) ir.Expr {
+	switch v.Type() {
+	case types.IsNull:
+		return &ir.NullLit{}
+	case types.IsFalse:
+		return &ir.BoolLit{Value: false}
+	case types.IsTrue:
+		return &ir.BoolLit{Value: true}
+	case types.IsLong:
+		return &ir.IntLit{Value: v.Long()}
+	case types.IsDouble:
+		if math.IsNaN(v.Double()) {
+			return nil
+		}
+		return &ir.FloatLit{Value: v.Double()}
+	case types.IsString:
+		return &ir.StringLit{Value: v.String()}
+	}
+	return nil
+}
+
+// eval 以 nil 上下文执行运行时运算。运算需要产生警告、错误或读取运行时配置时会访问上下文而 panic，此时不折叠
+func eval(f func(ctx *php.Context) types.Zval) (result ir.Expr) {
+	defer func() {
+		if recover() != nil {
+			result = nil
+		}
+	}()
+	return valueLit(f(nil))
+}
+
+// isTrue 字面量的布尔值
+func isTrue(e ir.Expr) (result bool, ok bool) {
+	v, ok := litValue(e)
+	if !ok {
+		return false, false
+	}
+	defer func() {
+		if recover() != nil {
+			result, ok = false, false
+		}
+	}()
+	return php.ZvalIsTrue(nil, v), true
+}
+
+var binaryOps = map[ast.BinaryOpKind]func(ctx *php.Context, op1, op2 types.Zval) types.Zval{
+	ast.BinaryOpBitwiseAnd:     php.OpBitwiseAnd,
+	ast.BinaryOpBitwiseOr:      php.OpBitwiseOr,
+	ast.BinaryOpBitwiseXor:     php.OpBitwiseXor,
+	ast.BinaryOpBooleanXor:     php.OpBooleanXor,
+	ast.BinaryOpConcat:         php.OpConcat,
+	ast.BinaryOpDiv:            php.OpDiv,
+	ast.BinaryOpEqual:          php.OpEqual,
+	ast.BinaryOpGreater:        php.OpGreater,
+	ast.BinaryOpGreaterOrEqual: php.OpGreaterOrEqual,
+	ast.BinaryOpIdentical:      php.OpIdentical,
+	ast.BinaryOpMinus:          php.OpSub,
+	ast.BinaryOpMod:            php.OpMod,
+	ast.BinaryOpMul:            php.OpMul,
+	ast.BinaryOpNotEqual:       php.OpNotEqual,
+	ast.BinaryOpNotIdentical:   php.OpNotIdentical,
+	ast.BinaryOpPlus:           php.OpAdd,
+	ast.BinaryOpPow:            php.OpPow,
+	ast.BinaryOpShiftLeft:      php.OpSL,
+	ast.BinaryOpShiftRight:     php.OpSR,
+	ast.BinaryOpSmaller:        php.OpSmaller,
+	ast.BinaryOpSmallerOrEqual: php.OpSmallerOrEqual,
+	ast.BinaryOpSpaceship:      php.OpSpaceship,
+}
+
+// fold 折叠操作数均为字面量的表达式，无法折叠时返回 nil
+func fold(e ir.Expr) ir.Expr {
+	switch x := e.(type) {
+	case *ir.BinaryOpExpr:
+		return foldBinary(x)
+	case *ir.UnaryExpr:
+		v, ok := litValue(x.Var)
+		if !ok {
+			return nil
+		}
+		switch x.Op {
+		case ast.UnaryOpPlus:
+			return eval(func(ctx *php.Context) types.Zval { return php.OpMul(ctx, v, types.ZvalLong(1)) })
+		case ast.UnaryOpMinus:
+			return eval(func(ctx *php.Context) types.Zval { return php.OpMul(ctx, v, types.ZvalLong(-1)) })
+		case ast.UnaryOpBooleanNot:
+			return eval(func(ctx *php.Context) types.Zval { return php.OpBooleanNot(ctx, v) })
+		case ast.UnaryOpBitwiseNot:
+			return eval(func(ctx *php.Context) types.Zval { return php.OpBitwiseNot(ctx, v) })
+		}
+	case *ir.CastExpr:
+		v, ok := litValue(x.Expr)
+		if !ok {
+			return nil
+		}
+		switch x.Kind {
+		case ast.CastBool:
+			return eval(func(ctx *php.Context) types.Zval { return types.ZvalBool(php.ZvalIsTrue(ctx, v)) })
+		case ast.CastInt:
+			return eval(func(ctx *php.Context) types.Zval { return types.ZvalLong(php.ZvalGetLong(ctx, v)) })
+		case ast.CastDouble:
+			return eval(func(ctx *php.Context) types.Zval { return types.ZvalDouble(php.ZvalGetDouble(ctx, v)) })
+		case ast.CastString:
+			return eval(func(ctx *php.Context) types.Zval { return types.ZvalString(php.ZvalGetStrVal(ctx, v)) })
+		}
+	case *ir.TernaryExpr:
+		cond, ok := isTrue(x.Cond)
+		switch {
+		case !ok:
+			return nil
+		case cond && x.If == nil:
+			return x.Cond
+		case cond:
+			return x.If
+		default:
+			return x.Else
+		}
+	}
+	return nil
+}
+
+func foldBinary(x *ir.BinaryOpExpr) ir.Expr {
+	// 短路运算只需左值为字面量
+	switch x.Op {
+	case ast.BinaryOpBooleanAnd, ast.BinaryOpBooleanOr:
+		left, ok := isTrue(x.Left)
+		if !ok {
+			return nil
+		}
+		if left == (x.Op == ast.BinaryOpBooleanOr) {
+			return &ir.BoolLit{Value: left}
+		}
+		if right, ok := isTrue(x.Right); ok {
+			return &ir.BoolLit{Value: right}
+		}
+		return nil
+	case ast.BinaryOpCoalesce:
+		if !isLiteral(x.Left) {
+			return nil
+		}
+		if _, isNull := x.Left.(*ir.NullLit); isNull {
+			return x.Right
+		}
+		return x.Left
+	}
+
+	op, ok := binaryOps[x.Op]
+	if !ok {
+		return nil
+	}
+	v1, ok1 := litValue(x.Left)
+	v2, ok2 := litValue(x.Right)
+	if !ok1 || !ok2 {
+		return nil
+	}
+	return eval(func(ctx *php.Context) types.Zval { return op(ctx, v1, v2) })
+}
@@ -0,0 +1,94 @@
+// Package optimizer 在 transformer 生成的 IR 上进行优化，在 render 之前执行。
+//
+// 优化分为以下几项，可以分别关闭以便调试生成的代码：
+//   - 常量替换：以字面量定义的常量(const、define)及版本号等内置常量替换为字面量
+//   - 常量折叠：操作数均为字面量的运算按运行时的运算规则(php/operators.go)计算为字面量
+//   - 分支删除：条件为字面量的 if、while 只保留会执行的分支
+//
+// 运算会产生警告、错误或依赖运行时配置(如浮点数转字符串的 precision)时不折叠，保证结果与运行时一致。
+package optimizer
+
+import (
+	"github.com/heyuuu/gophp/compile/ir"
+)
+
+// Options 优化选项，零值为启用全部优化
+type Options struct {
+	NoConst    bool // 关闭常量替换
+	NoFold     bool // 关闭常量折叠
+	NoDeadCode bool // 关闭分支删除
+}
+
+// Disabled 是否关闭了全部优化
+func (o Options) Disabled() bool {
+	return o.NoConst && o.NoFold && o.NoDeadCode
+}
+
+// OptimizeFile 优化文件，直接修改 f
+func OptimizeFile(f *ir.File, opts Options) {
+	if opts.Disabled() {
+		return
+	}
+	o := &optimizer{opts: opts, consts: map[string]ir.Expr{}, declared: map[string]bool{}}
+	o.declare(f)
+	for _, ns := range f.Namespaces {
+		o.namespace(ns)
+	}
+}
+
+type optimizer struct {
+	opts Options
+	// consts 已定义的常量，键为完全限定名称(不含前导 \)
+	consts map[string]ir.Expr
+	// declared 文件中所有顶层语句定义的常量名
+	declared map[string]bool
+	// hoisted 当前位于顶层的函数或类声明中，函数体可能在文件中任意常量定义前执行
+	hoisted bool
+}
+
+// namespace 依次优化命名空间块的顶层语句，顶层语句执行后定义的常量可以在之后的语句中替换
+func (o *optimizer) namespace(ns *ir.NamespaceStmt) {
+	rewriter := ir.Rewriter{Expr: o.expr}
+	if !o.opts.NoDeadCode {
+		rewriter.Stmts = o.stmts
+	}
+
+	for _, stmt := range ns.Stmts {
+		switch stmt.(type) {
+		case *ir.FunctionStmt, *ir.ClassStmt, *ir.InterfaceStmt, *ir.TraitStmt:
+			o.hoisted = true
+		}
+		ir.Rewrite(stmt, rewriter)
+		o.hoisted = false
+
+		o.define(stmt)
+	}
+	if rewriter.Stmts != nil {
+		ns.Stmts = rewriter.Stmts(ns.Stmts)
+	}
+}
+
+func (o *optimizer) expr(e ir.Expr) ir.Expr {
+	if !o.opts.NoConst {
+		if x, ok := e.(*ir.ConstFetchExpr); ok {
+			if lit := o.constant(x.Name); lit != nil {
+				return withPos(copyLit(lit), e)
+			}
+		}
+	}
+	if !o.opts.NoFold {
+		if folded := fold(e); folded != nil {
+			// 三元运算等直接使用子表达式时保留其位置
+			if isLiteral(folded) {
+				folded = withPos(folded, e)
+			}
+			return folded
+		}
+	}
+	return e
+}
+
+func withPos(n ir.Expr, orig ir.Node) ir.Expr {
+	n.SetPos(orig.Pos(), orig.End())
+	return n
+}
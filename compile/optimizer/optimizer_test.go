@@ -0,0 +1,218 @@
+package optimizer
+
+import (
+	"github.com/heyuuu/gophp/compile/ir"
+	"github.com/heyuuu/gophp/compile/parser"
+	"github.com/heyuuu/gophp/compile/transformer"
+	"strings"
+	"testing"
+)
+
+func optimizeCode(t *testing.T, code string, opts Options) string {
+	astFile, err := parser.ParseCode("<?php\n" + code)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file, err := transformer.TransformFile(astFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	OptimizeFile(file, opts)
+	result, err := ir.PrintFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.TrimSpace(result)
+}
+
+func TestOptimizeFile(t *testing.T) {
+	tests := []struct {
+		name string
+		code string
+		want string
+	}{
+		{
+			name: "fold literals",
+			code: `echo 1 + 2, 'a' . 'b' . 1, 1 < 2, -3, !0, 2 ** 3, (int)'12abc', 1 ? "y" : "n", null ?? "d";`,
+			want: `echo 3, "ab1", true, -3, true, 8, 12, "y", "d";`,
+		},
+		{
+			name: "keep warnings and runtime config",
+			code: `echo 1 / 0, 1.5 . '', 'a' + 1, $a + 1;`,
+			want: `echo 1 / 0, 1.5 . '', 'a' + 1, $a + 1;`,
+		},
+		{
+			name: "short circuit",
+			code: `echo false && f(), true || f(), true && f();`,
+			want: `echo false, true, true && \f();`,
+		},
+		{
+			name: "user consts",
+			code: `echo A;
+const A = 2;
+define('B', 'x');
+echo A * 3, B;
+function f() {
+    return A;
+}`,
+			want: `echo \A;
+const A = 2;
+\define('B', 'x');
+echo 6, "x";
+function f() {
+    return \A;
+}`,
+		},
+		{
+			name: "redefined const",
+			code: `const A = 1;
+define('A', 2);
+echo A;`,
+			want: `const A = 1;
+\define('A', 2);
+echo \A;`,
+		},
+		{
+			name: "namespaced const",
+			code: `namespace N;
+echo PHP_INT_SIZE, PHP_VERSION_ID;
+const PHP_INT_SIZE = 3;
+echo PHP_INT_SIZE;`,
+			want: `namespace N;
+
+echo PHP_INT_SIZE, 70433;
+const PHP_INT_SIZE = 3;
+echo 3;`,
+		},
+		{
+			name: "version branch",
+			code: `if (PHP_VERSION_ID >= 70000) {
+    echo 1;
+} else {
+    echo 2;
+}
+if (false) {
+    echo 3;
+} elseif ($x) {
+    echo 4;
+} elseif (true) {
+    echo 5;
+} else {
+    echo 6;
+}
+while (0) {
+    echo 7;
+}`,
+			want: `echo 1;
+if ($x) {
+    echo 4;
+} else {
+    echo 5;
+}`,
+		},
+		{
+			name: "conditional declaration",
+			code: `if (true) {
+    function g() {
+    }
+}`,
+			want: `if (true) {
+    function g() {
+    }
+}`,
+		},
+		{
+			name: "goto label",
+			code: `goto a;
+if (false) {
+    a:
+    echo 1;
+}`,
+			want: `goto a;
+if (false) {
+    a:
+    echo 1;
+}`,
+		},
+		{
+			name: "method body",
+			code: `class C {
+    function m() {
+        if (false) {
+            echo 1;
+        }
+    }
+}`,
+			want: `class C
+{
+    function m()
+    {
+    }
+}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := optimizeCode(t, tt.code, Options{}); got != tt.want {
+				t.Errorf("OptimizeFile() = \n%s\nwant:\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOptimizeFileOptions(t *testing.T) {
+	code := `const A = 1;
+if (A + 1 > 1) {
+    echo 1;
+}`
+	tests := []struct {
+		name string
+		opts Options
+		want string
+	}{
+		{
+			name: "all",
+			want: `const A = 1;
+echo 1;`,
+		},
+		{
+			name: "no const",
+			opts: Options{NoConst: true},
+			want: `const A = 1;
+if (\A + 1 > 1) {
+    echo 1;
+}`,
+		},
+		{
+			name: "no fold",
+			opts: Options{NoFold: true},
+			want: `const A = 1;
+if (1 + 1 > 1) {
+    echo 1;
+}`,
+		},
+		{
+			name: "no dead code",
+			opts: Options{NoDeadCode: true},
+			want: `const A = 1;
+if (true) {
+    echo 1;
+}`,
+		},
+		{
+			name: "disabled",
+			opts: Options{NoConst: true, NoFold: true, NoDeadCode: true},
+			want: `const A = 1;
+if (\A + 1 > 1) {
+    echo 1;
+}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := optimizeCode(t, code, tt.opts); got != tt.want {
+				t.Errorf("OptimizeFile() = \n%s\nwant:\n%s", got, tt.want)
+			}
+		})
+	}
+}
@@ -38,10 +38,12 @@ func decodeAstData(binData string) (file *ast.File, err error) {
 	}
 
 	stmts := asStmtList(value)
-	return buildAstFile(stmts), nil
+	return buildAstFile(stmts)
 }
 
-func buildAstFile(stmts []ast.Stmt) *ast.File {
+var errGlobalCodeInNamespace = errors.New("Global code should be enclosed in global namespace declaration")
+
+func buildAstFile(stmts []ast.Stmt) (*ast.File, error) {
 	// 拆分 declare 语句、全局代码和命名空间代码
 	var declareStmts []*ast.DeclareStmt
 	var globalStmts []ast.Stmt
@@ -59,7 +61,7 @@ func buildAstFile(stmts []ast.Stmt) *ast.File {
 		}
 	}
 	if len(globalStmts) > 0 && len(namespaceStmts) > 0 {
-		panic("Global code should be enclosed in global namespace declaration")
+		return nil, errGlobalCodeInNamespace
 	}
 
 	if len(namespaceStmts) == 0 {
@@ -72,7 +74,7 @@ func buildAstFile(stmts []ast.Stmt) *ast.File {
 	return &ast.File{
 		Declares:   declareStmts,
 		Namespaces: namespaceStmts,
-	}
+	}, nil
 }
 
 func decodeData(data any) (any, error) {
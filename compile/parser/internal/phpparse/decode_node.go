@@ -927,8 +927,16 @@ func decodeNode(data map[string]any) (node ast.Node, err error) {
 		}
 	case "AttributeGroup", "Attribute":
 		err = unsupported("unsupported high version php feature: php8.0 attribute")
-	case "MatchExpr", "MatchArm":
-		err = unsupported("unsupported high version php feature: php8.0 match")
+	case "MatchExpr":
+		node = &ast.MatchExpr{
+			Cond: data["cond"].(ast.Expr),
+			Arms: asSlice[*ast.MatchArm](data["arms"]),
+		}
+	case "MatchArm":
+		node = &ast.MatchArm{
+			Conds: asSlice[ast.Expr](data["conds"]),
+			Body:  data["body"].(ast.Expr),
+		}
 	case "EnumStmt", "EnumCaseStmt":
 		err = unsupported("unsupported high version php feature: php8.0 match")
 	case "VariadicPlaceholder":
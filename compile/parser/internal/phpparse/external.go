@@ -0,0 +1,60 @@
+package phpparse
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/heyuuu/gophp/compile/ast"
+	"log"
+	"os"
+	"os/exec"
+)
+
+// 外部 PHP 解析脚本(基于 nikic/PHP-Parser)，仅用于与内置解析器做结果比对
+
+var errExternalParserNotFound = errors.New("php parser script not found")
+
+// ParseCodeExternal 使用外部解析脚本解析代码，返回原始 json 数据及 ast
+func ParseCodeExternal(code string) (raw string, astFile *ast.File, err error) {
+	raw, err = parseCodeRaw(code)
+	if err == nil {
+		astFile, err = decodeAstData(raw)
+	}
+	return
+}
+
+func parseCodeRaw(code string) (string, error) {
+	output, err := runParser("parse", "-c", code)
+	if err != nil {
+		return "", fmt.Errorf("php parse run failed: %w", err)
+	}
+
+	var res result
+	if err = json.Unmarshal(output, &res); err != nil {
+		return "", fmt.Errorf("php parse json Unmarshal failed: %s", res.Error)
+	}
+
+	if !res.Ok {
+		return "", fmt.Errorf("php parse error: %s", res.Error)
+	}
+
+	return res.Data, nil
+}
+
+/* Parser 脚本相关 */
+var parserScript = "/usr/local/bin/gophp-parser"
+
+func externalParserExists() bool {
+	_, err := os.Stat(parserScript)
+	return err == nil
+}
+
+func runParser(args ...string) ([]byte, error) {
+	if !externalParserExists() {
+		return nil, errExternalParserNotFound
+	}
+
+	command := exec.Command(parserScript, args...)
+	log.Printf("Run command: %s\n", command.String())
+	return command.CombinedOutput()
+}
@@ -0,0 +1,913 @@
+package phpparse
+
+import (
+	"fmt"
+	"github.com/heyuuu/gophp/compile/token"
+	"strings"
+)
+
+// tokenItem 词法单元
+type tokenItem struct {
+	tok   token.Token
+	value string // 源码中的原始文本
+	text  string // 仅用于 ENCAPSED_AND_WHITESPACE，已处理 heredoc 缩进后的文本
+	pos   int    // 起始字节偏移
+	line  int    // 起始行号(从 1 开始)
+}
+
+type lexerState uint8
+
+const (
+	stateInitial lexerState = iota
+	stateScripting
+	stateDoubleQuotes
+	stateBackquote
+	stateHeredoc
+	stateNowdoc
+	stateLookingForProperty
+	stateVarOffset
+	stateLookingForVarname
+)
+
+type heredocLabel struct {
+	label  string
+	indent int // 结束标记的缩进长度，body 每行会去除该长度的缩进
+}
+
+// lexer PHP 词法分析器，状态切换规则与 Zend 的 zend_language_scanner 保持一致
+type lexer struct {
+	src   string
+	pos   int
+	line  int
+	state lexerState
+	stack []lexerState
+
+	heredocs []heredocLabel
+	tokens   []tokenItem
+}
+
+func tokenize(src string) (tokens []tokenItem, err error) {
+	l := &lexer{src: src, line: 1, state: stateInitial}
+	defer func() {
+		if e := recover(); e != nil {
+			if lexErr, ok := e.(*lexError); ok {
+				err = lexErr
+				return
+			}
+			panic(e)
+		}
+	}()
+	l.run()
+	return l.tokens, nil
+}
+
+type lexError struct {
+	msg  string
+	pos  int
+	line int
+}
+
+func (e *lexError) Error() string {
+	return fmt.Sprintf("%s on line %d", e.msg, e.line)
+}
+
+func (l *lexer) errorf(format string, args ...any) {
+	panic(&lexError{msg: fmt.Sprintf(format, args...), pos: l.pos, line: l.line})
+}
+
+func (l *lexer) run() {
+	for l.pos < len(l.src) {
+		switch l.state {
+		case stateInitial:
+			l.scanInitial()
+		case stateScripting:
+			if l.scanScripting() {
+				return
+			}
+		case stateDoubleQuotes:
+			l.scanEncaps('"')
+		case stateBackquote:
+			l.scanEncaps('`')
+		case stateHeredoc:
+			l.scanHeredoc()
+		case stateNowdoc:
+			l.scanNowdoc()
+		case stateLookingForProperty:
+			l.scanLookingForProperty()
+		case stateVarOffset:
+			l.scanVarOffset()
+		case stateLookingForVarname:
+			l.scanLookingForVarname()
+		}
+	}
+	if l.state == stateDoubleQuotes || l.state == stateBackquote || l.state == stateHeredoc || l.state == stateNowdoc {
+		l.errorf("Syntax error, unexpected end of file")
+	}
+}
+
+/* state helpers */
+
+func (l *lexer) begin(state lexerState) { l.state = state }
+func (l *lexer) pushState(state lexerState) {
+	l.stack = append(l.stack, l.state)
+	l.state = state
+}
+func (l *lexer) popState() {
+	if n := len(l.stack); n > 0 {
+		l.state = l.stack[n-1]
+		l.stack = l.stack[:n-1]
+	}
+}
+
+/* char helpers */
+
+func (l *lexer) peek(offset int) byte {
+	if i := l.pos + offset; i < len(l.src) {
+		return l.src[i]
+	}
+	return 0
+}
+
+func (l *lexer) hasPrefix(s string) bool {
+	return strings.HasPrefix(l.src[l.pos:], s)
+}
+
+func (l *lexer) hasPrefixFold(s string) bool {
+	return len(l.src)-l.pos >= len(s) && strings.EqualFold(l.src[l.pos:l.pos+len(s)], s)
+}
+
+func isLabelStart(c byte) bool {
+	return c == '_' || ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') || c >= 0x80
+}
+
+func isLabelChar(c byte) bool {
+	return isLabelStart(c) || ('0' <= c && c <= '9')
+}
+
+func isWhitespace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
+
+func (l *lexer) labelEnd(pos int) int {
+	if pos >= len(l.src) || !isLabelStart(l.src[pos]) {
+		return pos
+	}
+	pos++
+	for pos < len(l.src) && isLabelChar(l.src[pos]) {
+		pos++
+	}
+	return pos
+}
+
+// emit 生成 token，内容为 [start, end) 区间，并推进位置
+func (l *lexer) emit(tok token.Token, end int) {
+	value := l.src[l.pos:end]
+	l.tokens = append(l.tokens, tokenItem{tok: tok, value: value, pos: l.pos, line: l.line})
+	l.line += strings.Count(value, "\n")
+	l.pos = end
+}
+
+func (l *lexer) emitText(tok token.Token, end int, text string) {
+	l.emit(tok, end)
+	l.tokens[len(l.tokens)-1].text = text
+}
+
+/* INITIAL */
+
+func (l *lexer) scanInitial() {
+	start := l.pos
+	for i := l.pos; i < len(l.src); i++ {
+		if l.src[i] != '<' || i+1 >= len(l.src) || l.src[i+1] != '?' {
+			continue
+		}
+		if strings.HasPrefix(l.src[i:], "<?=") {
+			if i > start {
+				l.emit(token.INLINE_HTML, i)
+			}
+			l.emit(token.OPEN_TAG_WITH_ECHO, i+3)
+			l.begin(stateScripting)
+			return
+		}
+		if len(l.src)-i >= 5 && strings.EqualFold(l.src[i:i+5], "<?php") {
+			end := i + 5
+			if end < len(l.src) {
+				if !isWhitespace(l.src[end]) {
+					continue
+				}
+				if l.src[end] == '\r' && end+1 < len(l.src) && l.src[end+1] == '\n' {
+					end += 2
+				} else {
+					end++
+				}
+			}
+			if i > start {
+				l.emit(token.INLINE_HTML, i)
+			}
+			l.emit(token.OPEN_TAG, end)
+			l.begin(stateScripting)
+			return
+		}
+	}
+	l.emit(token.INLINE_HTML, len(l.src))
+}
+
+/* IN_SCRIPTING */
+
+var castTypes = map[string]token.Token{
+	"int":     token.INT_CAST,
+	"integer": token.INT_CAST,
+	"bool":    token.BOOL_CAST,
+	"boolean": token.BOOL_CAST,
+	"float":   token.DOUBLE_CAST,
+	"double":  token.DOUBLE_CAST,
+	"real":    token.DOUBLE_CAST,
+	"string":  token.STRING_CAST,
+	"binary":  token.STRING_CAST,
+	"array":   token.ARRAY_CAST,
+	"object":  token.OBJECT_CAST,
+	"unset":   token.UNSET_CAST,
+}
+
+// 按最长匹配排列的运算符
+var operators = []struct {
+	text string
+	tok  token.Token
+}{
+	{"<<=", token.SL_EQUAL},
+	{">>=", token.SR_EQUAL},
+	{"**=", token.POW_EQUAL},
+	{"...", token.ELLIPSIS},
+	{"??=", token.COALESCE_EQUAL},
+	{"===", token.IS_IDENTICAL},
+	{"!==", token.IS_NOT_IDENTICAL},
+	{"<=>", token.SPACESHIP},
+	{"?->", token.NULLSAFE_OBJECT_OPERATOR},
+	{"::", token.PAAMAYIM_NEKUDOTAYIM},
+	{"=>", token.DOUBLE_ARROW},
+	{"++", token.INC},
+	{"--", token.DEC},
+	{"==", token.IS_EQUAL},
+	{"!=", token.IS_NOT_EQUAL},
+	{"<>", token.IS_NOT_EQUAL},
+	{"<=", token.IS_SMALLER_OR_EQUAL},
+	{">=", token.IS_GREATER_OR_EQUAL},
+	{"+=", token.PLUS_EQUAL},
+	{"-=", token.MINUS_EQUAL},
+	{"*=", token.MUL_EQUAL},
+	{"/=", token.DIV_EQUAL},
+	{".=", token.CONCAT_EQUAL},
+	{"%=", token.MOD_EQUAL},
+	{"&=", token.AND_EQUAL},
+	{"|=", token.OR_EQUAL},
+	{"^=", token.XOR_EQUAL},
+	{"||", token.BOOLEAN_OR},
+	{"&&", token.BOOLEAN_AND},
+	{"<<", token.SL},
+	{">>", token.SR},
+	{"??", token.COALESCE},
+	{"**", token.POW},
+	{"->", token.OBJECT_OPERATOR},
+}
+
+// scanScripting 扫描一个 token，遇到 __halt_compiler 后返回 true 表示停止扫描
+func (l *lexer) scanScripting() (halt bool) {
+	c := l.src[l.pos]
+	switch {
+	case isWhitespace(c):
+		end := l.pos
+		for end < len(l.src) && isWhitespace(l.src[end]) {
+			end++
+		}
+		l.emit(token.WHITESPACE, end)
+		return
+	case c == '#' && l.peek(1) == '[':
+		l.emit(token.ATTRIBUTE, l.pos+2)
+		return
+	case c == '#' || (c == '/' && l.peek(1) == '/'):
+		l.scanLineComment()
+		return
+	case c == '/' && l.peek(1) == '*':
+		l.scanBlockComment()
+		return
+	case c == '?' && l.peek(1) == '>':
+		end := l.pos + 2
+		if strings.HasPrefix(l.src[end:], "\r\n") {
+			end += 2
+		} else if end < len(l.src) && (l.src[end] == '\n' || l.src[end] == '\r') {
+			end++
+		}
+		l.emit(token.CLOSE_TAG, end)
+		l.begin(stateInitial)
+		return
+	case c == '$' && isLabelStart(l.peek(1)):
+		l.emit(token.VARIABLE, l.labelEnd(l.pos+1))
+		return
+	case (c == 'b' || c == 'B') && (l.peek(1) == '\'' || l.peek(1) == '"'):
+		l.scanQuoteString(l.pos + 1)
+		return
+	case (c == 'b' || c == 'B') && strings.HasPrefix(l.src[l.pos+1:], "<<<"):
+		if l.tryScanHeredocStart(l.pos + 1) {
+			return
+		}
+	case isLabelStart(c) || (c == '\\' && isLabelStart(l.peek(1))):
+		return l.scanName()
+	case '0' <= c && c <= '9', c == '.' && '0' <= l.peek(1) && l.peek(1) <= '9':
+		l.scanNumber()
+		return
+	case c == '\'' || c == '"':
+		l.scanQuoteString(l.pos)
+		return
+	case c == '`':
+		l.emit('`', l.pos+1)
+		l.begin(stateBackquote)
+		return
+	case c == '<' && l.hasPrefix("<<<"):
+		if l.tryScanHeredocStart(l.pos) {
+			return
+		}
+	case c == '(':
+		if l.tryScanCast() {
+			return
+		}
+	case c == '{':
+		l.emit('{', l.pos+1)
+		l.pushState(stateScripting)
+		return
+	case c == '}':
+		l.emit('}', l.pos+1)
+		// 弹出 `{`、`{$` 或 `${` 压入的状态
+		l.popState()
+		return
+	}
+
+	for _, op := range operators {
+		if l.hasPrefix(op.text) {
+			l.emit(op.tok, l.pos+len(op.text))
+			if op.tok == token.OBJECT_OPERATOR || op.tok == token.NULLSAFE_OBJECT_OPERATOR {
+				l.pushState(stateLookingForProperty)
+			}
+			return
+		}
+	}
+	if c == '\\' {
+		l.emit(token.NS_SEPARATOR, l.pos+1)
+		return
+	}
+	if strings.IndexByte(";:,.[]()|^&+-/*=%!~$<>?@", c) >= 0 {
+		l.emit(token.Token(c), l.pos+1)
+		return
+	}
+	l.emit(token.BAD_CHARACTER, l.pos+1)
+	return
+}
+
+func (l *lexer) scanLineComment() {
+	end := l.pos
+	for end < len(l.src) {
+		c := l.src[end]
+		if c == '\n' || c == '\r' {
+			break
+		}
+		if c == '?' && end+1 < len(l.src) && l.src[end+1] == '>' {
+			break
+		}
+		end++
+	}
+	l.emit(token.COMMENT, end)
+}
+
+func (l *lexer) scanBlockComment() {
+	tok := token.COMMENT
+	if strings.HasPrefix(l.src[l.pos:], "/**") && l.pos+3 < len(l.src) && isWhitespace(l.src[l.pos+3]) {
+		tok = token.DOC_COMMENT
+	}
+	idx := strings.Index(l.src[l.pos+2:], "*/")
+	if idx < 0 {
+		l.errorf("Unterminated comment starting line %d", l.line)
+	}
+	l.emit(tok, l.pos+2+idx+2)
+}
+
+func (l *lexer) scanName() (halt bool) {
+	start := l.pos
+	end := l.pos
+	fullyQualified := false
+	if l.src[end] == '\\' {
+		fullyQualified = true
+		end++
+	}
+	end = l.labelEnd(end)
+	first := l.src[l.pos:end]
+	parts := 1
+	for end+1 < len(l.src) && l.src[end] == '\\' && isLabelStart(l.src[end+1]) {
+		end = l.labelEnd(end + 1)
+		parts++
+	}
+
+	switch {
+	case fullyQualified:
+		l.emit(token.NAME_FULLY_QUALIFIED, end)
+	case parts > 1 && strings.EqualFold(first, "namespace"):
+		l.emit(token.NAME_RELATIVE, end)
+	case parts > 1:
+		l.emit(token.NAME_QUALIFIED, end)
+	default:
+		tok := token.Lookup(first)
+		switch tok {
+		case token.YIELD:
+			// yield from
+			i := end
+			for i < len(l.src) && isWhitespace(l.src[i]) {
+				i++
+			}
+			if i > end && len(l.src)-i >= 4 && strings.EqualFold(l.src[i:i+4], "from") && (i+4 >= len(l.src) || !isLabelChar(l.src[i+4])) {
+				l.emit(token.YIELD_FROM, i+4)
+				return
+			}
+		case token.ENUM:
+			// enum 只在后跟类名时作为关键字
+			i := end
+			for i < len(l.src) && isWhitespace(l.src[i]) {
+				i++
+			}
+			next := l.src[i:l.labelEnd(i)]
+			if i == end || next == "" || strings.EqualFold(next, "extends") || strings.EqualFold(next, "implements") {
+				tok = token.STRING
+			}
+		}
+		l.emit(tok, end)
+		if tok == token.HALT_COMPILER {
+			l.scanHaltCompiler()
+			return true
+		}
+	}
+	_ = start
+	return
+}
+
+// scanHaltCompiler 处理 `__halt_compiler();` 之后的内容，剩余部分整体作为 INLINE_HTML
+func (l *lexer) scanHaltCompiler() {
+	expects := []byte{'(', ')', ';'}
+	for len(expects) > 0 && l.pos < len(l.src) {
+		c := l.src[l.pos]
+		switch {
+		case isWhitespace(c):
+			l.scanScripting()
+		case c == '/' && (l.peek(1) == '/' || l.peek(1) == '*'), c == '#':
+			l.scanScripting()
+		case c == expects[0]:
+			l.emit(token.Token(c), l.pos+1)
+			expects = expects[1:]
+		case len(expects) == 1 && l.hasPrefix("?>"):
+			l.scanScripting()
+			expects = nil
+		default:
+			return
+		}
+	}
+	if l.pos < len(l.src) {
+		l.emit(token.INLINE_HTML, len(l.src))
+	}
+}
+
+func isDigitOf(c byte, base int) bool {
+	switch base {
+	case 2:
+		return c == '0' || c == '1'
+	case 8:
+		return '0' <= c && c <= '7'
+	case 16:
+		return ('0' <= c && c <= '9') || ('a' <= c && c <= 'f') || ('A' <= c && c <= 'F')
+	default:
+		return '0' <= c && c <= '9'
+	}
+}
+
+func (l *lexer) scanDigits(pos int, base int) int {
+	for pos < len(l.src) {
+		c := l.src[pos]
+		if isDigitOf(c, base) {
+			pos++
+		} else if c == '_' && pos+1 < len(l.src) && isDigitOf(l.src[pos+1], base) && pos > 0 && isDigitOf(l.src[pos-1], base) {
+			pos++
+		} else {
+			break
+		}
+	}
+	return pos
+}
+
+func (l *lexer) scanNumber() {
+	pos := l.pos
+	if l.src[pos] == '0' && pos+2 < len(l.src) {
+		var base int
+		switch l.src[pos+1] {
+		case 'x', 'X':
+			base = 16
+		case 'b', 'B':
+			base = 2
+		case 'o', 'O':
+			base = 8
+		}
+		if base != 0 && isDigitOf(l.src[pos+2], base) {
+			end := l.scanDigits(pos+2, base)
+			l.emitNumber(end, false)
+			return
+		}
+	}
+
+	isFloat := false
+	end := l.scanDigits(pos, 10)
+	if end < len(l.src) && l.src[end] == '.' {
+		if end+1 < len(l.src) && '0' <= l.src[end+1] && l.src[end+1] <= '9' {
+			isFloat = true
+			end = l.scanDigits(end+1, 10)
+		} else if end > pos {
+			// `1.` 形式
+			isFloat = true
+			end++
+		}
+	}
+	if end > pos && end < len(l.src) && (l.src[end] == 'e' || l.src[end] == 'E') {
+		i := end + 1
+		if i < len(l.src) && (l.src[i] == '+' || l.src[i] == '-') {
+			i++
+		}
+		if i < len(l.src) && '0' <= l.src[i] && l.src[i] <= '9' {
+			isFloat = true
+			end = l.scanDigits(i, 10)
+		}
+	}
+	l.emitNumber(end, isFloat)
+}
+
+func (l *lexer) emitNumber(end int, isFloat bool) {
+	if !isFloat {
+		if _, ok := parseIntLiteral(l.src[l.pos:end]); !ok {
+			isFloat = true
+		}
+	}
+	if isFloat {
+		l.emit(token.DNUMBER, end)
+	} else {
+		l.emit(token.LNUMBER, end)
+	}
+}
+
+func (l *lexer) tryScanCast() bool {
+	i := l.pos + 1
+	for i < len(l.src) && (l.src[i] == ' ' || l.src[i] == '\t') {
+		i++
+	}
+	nameEnd := i
+	for nameEnd < len(l.src) && ('a' <= l.src[nameEnd]|0x20 && l.src[nameEnd]|0x20 <= 'z') {
+		nameEnd++
+	}
+	tok, ok := castTypes[strings.ToLower(l.src[i:nameEnd])]
+	if !ok {
+		return false
+	}
+	j := nameEnd
+	for j < len(l.src) && (l.src[j] == ' ' || l.src[j] == '\t') {
+		j++
+	}
+	if j >= len(l.src) || l.src[j] != ')' {
+		return false
+	}
+	l.emit(tok, j+1)
+	return true
+}
+
+func (l *lexer) scanQuoteString(quotePos int) {
+	quote := l.src[quotePos]
+	i := quotePos + 1
+	for i < len(l.src) {
+		c := l.src[i]
+		if c == '\\' {
+			i += 2
+			continue
+		}
+		if c == quote {
+			l.emit(token.CONSTANT_ENCAPSED_STRING, i+1)
+			return
+		}
+		if quote == '"' && ((c == '$' && (isLabelStart(l.peekAt(i+1)) || l.peekAt(i+1) == '{')) || (c == '{' && l.peekAt(i+1) == '$')) {
+			// 含有变量插值
+			l.emit('"', quotePos+1)
+			l.begin(stateDoubleQuotes)
+			return
+		}
+		i++
+	}
+	if quote == '"' {
+		l.emit('"', quotePos+1)
+		l.begin(stateDoubleQuotes)
+		return
+	}
+	l.errorf("Syntax error, unexpected end of file, unterminated string")
+}
+
+func (l *lexer) peekAt(i int) byte {
+	if i < len(l.src) {
+		return l.src[i]
+	}
+	return 0
+}
+
+/* heredoc */
+
+func (l *lexer) tryScanHeredocStart(pos int) bool {
+	i := pos + 3
+	for i < len(l.src) && (l.src[i] == ' ' || l.src[i] == '\t') {
+		i++
+	}
+	var quote byte
+	if i < len(l.src) && (l.src[i] == '\'' || l.src[i] == '"') {
+		quote = l.src[i]
+		i++
+	}
+	labelEnd := l.labelEnd(i)
+	if labelEnd == i {
+		return false
+	}
+	label := l.src[i:labelEnd]
+	i = labelEnd
+	if quote != 0 {
+		if i >= len(l.src) || l.src[i] != quote {
+			return false
+		}
+		i++
+	}
+	if strings.HasPrefix(l.src[i:], "\r\n") {
+		i += 2
+	} else if i < len(l.src) && (l.src[i] == '\n' || l.src[i] == '\r') {
+		i++
+	} else {
+		return false
+	}
+
+	l.emit(token.START_HEREDOC, i)
+	l.heredocs = append(l.heredocs, heredocLabel{label: label, indent: l.findHeredocIndent(label)})
+	if quote == '\'' {
+		l.begin(stateNowdoc)
+	} else {
+		l.begin(stateHeredoc)
+	}
+	return true
+}
+
+// findHeredocIndent 查找结束标记，返回其缩进长度
+func (l *lexer) findHeredocIndent(label string) int {
+	pos := l.pos
+	for pos <= len(l.src) {
+		if indent, ok := l.matchHeredocEnd(pos, label); ok {
+			return indent
+		}
+		next := strings.IndexByte(l.src[pos:], '\n')
+		if next < 0 {
+			break
+		}
+		pos += next + 1
+	}
+	return 0
+}
+
+// matchHeredocEnd 判断 pos (行首) 处是否为结束标记
+func (l *lexer) matchHeredocEnd(pos int, label string) (indent int, ok bool) {
+	i := pos
+	for i < len(l.src) && (l.src[i] == ' ' || l.src[i] == '\t') {
+		i++
+	}
+	if !strings.HasPrefix(l.src[i:], label) {
+		return 0, false
+	}
+	if end := i + len(label); end < len(l.src) && isLabelChar(l.src[end]) {
+		return 0, false
+	}
+	return i - pos, true
+}
+
+func (l *lexer) currHeredoc() heredocLabel {
+	return l.heredocs[len(l.heredocs)-1]
+}
+
+func (l *lexer) atLineStart(pos int) bool {
+	if pos == 0 {
+		return true
+	}
+	c := l.src[pos-1]
+	return c == '\n' || c == '\r'
+}
+
+// tryScanHeredocEnd 若当前位置为结束标记则生成 END_HEREDOC
+func (l *lexer) tryScanHeredocEnd() bool {
+	if !l.atLineStart(l.pos) {
+		return false
+	}
+	h := l.currHeredoc()
+	indent, ok := l.matchHeredocEnd(l.pos, h.label)
+	if !ok {
+		return false
+	}
+	l.emit(token.END_HEREDOC, l.pos+indent+len(h.label))
+	l.heredocs = l.heredocs[:len(l.heredocs)-1]
+	l.begin(stateScripting)
+	return true
+}
+
+// heredocText 去除 heredoc 内容的缩进；若内容紧邻结束标记，去除最后的换行
+func (l *lexer) heredocText(start, end int, beforeEnd bool) string {
+	raw := l.src[start:end]
+	if beforeEnd {
+		if strings.HasSuffix(raw, "\r\n") {
+			raw = raw[:len(raw)-2]
+		} else if strings.HasSuffix(raw, "\n") || strings.HasSuffix(raw, "\r") {
+			raw = raw[:len(raw)-1]
+		}
+	}
+
+	indent := l.currHeredoc().indent
+	if indent == 0 {
+		return raw
+	}
+	var buf strings.Builder
+	lineStart := l.atLineStart(start)
+	for i := 0; i < len(raw); i++ {
+		if lineStart {
+			j := 0
+			for j < indent && i+j < len(raw) && (raw[i+j] == ' ' || raw[i+j] == '\t') {
+				j++
+			}
+			i += j
+			lineStart = false
+			if i >= len(raw) {
+				break
+			}
+		}
+		buf.WriteByte(raw[i])
+		if raw[i] == '\n' || (raw[i] == '\r' && (i+1 >= len(raw) || raw[i+1] != '\n')) {
+			lineStart = true
+		}
+	}
+	return buf.String()
+}
+
+func (l *lexer) scanNowdoc() {
+	if l.tryScanHeredocEnd() {
+		return
+	}
+	h := l.currHeredoc()
+	pos := l.pos
+	for pos < len(l.src) {
+		next := strings.IndexByte(l.src[pos:], '\n')
+		if next < 0 {
+			l.errorf("Syntax error, unexpected end of file")
+		}
+		pos += next + 1
+		if _, ok := l.matchHeredocEnd(pos, h.label); ok {
+			break
+		}
+	}
+	l.emitText(token.ENCAPSED_AND_WHITESPACE, pos, l.heredocText(l.pos, pos, true))
+}
+
+func (l *lexer) scanHeredoc() {
+	if l.tryScanHeredocEnd() {
+		return
+	}
+	if l.scanEncapsVar() {
+		return
+	}
+
+	h := l.currHeredoc()
+	pos := l.pos
+	for pos < len(l.src) {
+		c := l.src[pos]
+		if c == '\\' {
+			pos += 2
+			continue
+		}
+		if (c == '$' && (isLabelStart(l.peekAt(pos+1)) || l.peekAt(pos+1) == '{')) || (c == '{' && l.peekAt(pos+1) == '$') {
+			l.emitText(token.ENCAPSED_AND_WHITESPACE, pos, l.heredocText(l.pos, pos, false))
+			return
+		}
+		pos++
+		if c == '\n' || c == '\r' {
+			if c == '\r' && l.peekAt(pos) == '\n' {
+				pos++
+			}
+			if _, ok := l.matchHeredocEnd(pos, h.label); ok {
+				l.emitText(token.ENCAPSED_AND_WHITESPACE, pos, l.heredocText(l.pos, pos, true))
+				return
+			}
+		}
+	}
+	l.errorf("Syntax error, unexpected end of file")
+}
+
+/* double quotes & backquote */
+
+func (l *lexer) scanEncaps(quote byte) {
+	if l.src[l.pos] == quote {
+		l.emit(token.Token(quote), l.pos+1)
+		l.begin(stateScripting)
+		return
+	}
+	if l.scanEncapsVar() {
+		return
+	}
+
+	pos := l.pos
+	for pos < len(l.src) {
+		c := l.src[pos]
+		if c == '\\' {
+			pos += 2
+			continue
+		}
+		if c == quote || (c == '$' && (isLabelStart(l.peekAt(pos+1)) || l.peekAt(pos+1) == '{')) || (c == '{' && l.peekAt(pos+1) == '$') {
+			break
+		}
+		pos++
+	}
+	if pos > len(l.src) {
+		pos = len(l.src)
+	}
+	l.emitText(token.ENCAPSED_AND_WHITESPACE, pos, l.src[l.pos:pos])
+}
+
+// scanEncapsVar 处理字符串中的变量插值
+func (l *lexer) scanEncapsVar() bool {
+	c := l.src[l.pos]
+	switch {
+	case c == '$' && isLabelStart(l.peek(1)):
+		l.emit(token.VARIABLE, l.labelEnd(l.pos+1))
+		if l.peek(0) == '[' {
+			l.pushState(stateVarOffset)
+		} else if (l.hasPrefix("->") && isLabelStart(l.peek(2))) || (l.hasPrefix("?->") && isLabelStart(l.peek(3))) {
+			l.pushState(stateLookingForProperty)
+		}
+		return true
+	case c == '$' && l.peek(1) == '{':
+		l.emit(token.DOLLAR_OPEN_CURLY_BRACES, l.pos+2)
+		l.pushState(stateLookingForVarname)
+		return true
+	case c == '{' && l.peek(1) == '$':
+		l.emit(token.CURLY_OPEN, l.pos+1)
+		l.pushState(stateScripting)
+		return true
+	}
+	return false
+}
+
+func (l *lexer) scanLookingForProperty() {
+	c := l.src[l.pos]
+	switch {
+	case isWhitespace(c):
+		end := l.pos
+		for end < len(l.src) && isWhitespace(l.src[end]) {
+			end++
+		}
+		l.emit(token.WHITESPACE, end)
+	case l.hasPrefix("->"):
+		l.emit(token.OBJECT_OPERATOR, l.pos+2)
+	case l.hasPrefix("?->"):
+		l.emit(token.NULLSAFE_OBJECT_OPERATOR, l.pos+3)
+	case isLabelStart(c):
+		l.emit(token.STRING, l.labelEnd(l.pos))
+		l.popState()
+	default:
+		l.popState()
+	}
+}
+
+func (l *lexer) scanVarOffset() {
+	c := l.src[l.pos]
+	switch {
+	case '0' <= c && c <= '9':
+		end := l.pos
+		for end < len(l.src) && isLabelChar(l.src[end]) {
+			end++
+		}
+		l.emit(token.NUM_STRING, end)
+	case c == '$' && isLabelStart(l.peek(1)):
+		l.emit(token.VARIABLE, l.labelEnd(l.pos+1))
+	case isLabelStart(c):
+		l.emit(token.STRING, l.labelEnd(l.pos))
+	case c == ']':
+		l.emit(']', l.pos+1)
+		l.popState()
+	case c == '[' || c == '-':
+		l.emit(token.Token(c), l.pos+1)
+	default:
+		l.errorf("Syntax error, unexpected '%c', expecting ']'", c)
+	}
+}
+
+func (l *lexer) scanLookingForVarname() {
+	end := l.labelEnd(l.pos)
+	if end > l.pos && end < len(l.src) && (l.src[end] == '[' || l.src[end] == '}') {
+		l.emit(token.STRING_VARNAME, end)
+	}
+	l.popState()
+	l.pushState(stateScripting)
+}
@@ -0,0 +1,164 @@
+package phpparse
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// parseIntLiteral 解析整型字面量，溢出时返回 false
+func parseIntLiteral(raw string) (int, bool) {
+	s := strings.ReplaceAll(raw, "_", "")
+	base := 10
+	switch {
+	case len(s) > 1 && (s[1] == 'x' || s[1] == 'X'):
+		base, s = 16, s[2:]
+	case len(s) > 1 && (s[1] == 'b' || s[1] == 'B'):
+		base, s = 2, s[2:]
+	case len(s) > 1 && (s[1] == 'o' || s[1] == 'O'):
+		base, s = 8, s[2:]
+	case len(s) > 1 && s[0] == '0':
+		base, s = 8, s[1:]
+	}
+	i, err := strconv.ParseInt(s, base, 64)
+	if err != nil {
+		return 0, false
+	}
+	return int(i), true
+}
+
+// parseFloatLiteral 解析浮点字面量，包括溢出的整型字面量
+func parseFloatLiteral(raw string) float64 {
+	s := strings.ReplaceAll(raw, "_", "")
+	base := 0
+	switch {
+	case len(s) > 1 && (s[1] == 'x' || s[1] == 'X'):
+		base, s = 16, s[2:]
+	case len(s) > 1 && (s[1] == 'b' || s[1] == 'B'):
+		base, s = 2, s[2:]
+	case len(s) > 1 && (s[1] == 'o' || s[1] == 'O'):
+		base, s = 8, s[2:]
+	case len(s) > 1 && s[0] == '0' && strings.IndexAny(s, ".eE") < 0:
+		base, s = 8, s[1:]
+	}
+	if base == 0 {
+		f, _ := strconv.ParseFloat(s, 64)
+		return f
+	}
+	var f float64
+	for i := 0; i < len(s); i++ {
+		d, _ := strconv.ParseInt(s[i:i+1], base, 64)
+		f = f*float64(base) + float64(d)
+	}
+	if math.IsInf(f, 0) {
+		return math.Inf(1)
+	}
+	return f
+}
+
+// parseSingleQuoteString 解析单引号字符串，只处理 `\\` 和 `\'` 转义
+func parseSingleQuoteString(raw string) string {
+	if raw[0] == 'b' || raw[0] == 'B' {
+		raw = raw[1:]
+	}
+	s := raw[1 : len(raw)-1]
+	if strings.IndexByte(s, '\\') < 0 {
+		return s
+	}
+	var buf strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) && (s[i+1] == '\\' || s[i+1] == '\'') {
+			i++
+		}
+		buf.WriteByte(s[i])
+	}
+	return buf.String()
+}
+
+// parseEscapeSequences 处理双引号字符串、heredoc 及反引号字符串中的转义。quote 为 0 时表示 heredoc
+func parseEscapeSequences(s string, quote byte) string {
+	if strings.IndexByte(s, '\\') < 0 {
+		return s
+	}
+
+	var buf strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c != '\\' || i+1 >= len(s) {
+			buf.WriteByte(c)
+			continue
+		}
+
+		next := s[i+1]
+		switch next {
+		case 'n':
+			buf.WriteByte('\n')
+		case 't':
+			buf.WriteByte('\t')
+		case 'r':
+			buf.WriteByte('\r')
+		case 'v':
+			buf.WriteByte('\v')
+		case 'e':
+			buf.WriteByte(0x1b)
+		case 'f':
+			buf.WriteByte('\f')
+		case '\\', '$':
+			buf.WriteByte(next)
+		case '0', '1', '2', '3', '4', '5', '6', '7':
+			j := i + 1
+			for j < len(s) && j < i+4 && '0' <= s[j] && s[j] <= '7' {
+				j++
+			}
+			v, _ := strconv.ParseUint(s[i+1:j], 8, 16)
+			buf.WriteByte(byte(v))
+			i = j - 1
+			continue
+		case 'x', 'X':
+			j := i + 2
+			for j < len(s) && j < i+4 && isDigitOf(s[j], 16) {
+				j++
+			}
+			if j == i+2 {
+				buf.WriteByte(c)
+				continue
+			}
+			v, _ := strconv.ParseUint(s[i+2:j], 16, 8)
+			buf.WriteByte(byte(v))
+			i = j - 1
+			continue
+		case 'u':
+			if i+2 < len(s) && s[i+2] == '{' {
+				if end := strings.IndexByte(s[i+3:], '}'); end > 0 {
+					if v, err := strconv.ParseUint(s[i+3:i+3+end], 16, 32); err == nil {
+						buf.WriteString(encodeCodepoint(rune(v)))
+						i = i + 3 + end
+						continue
+					}
+				}
+			}
+			buf.WriteByte(c)
+			continue
+		default:
+			if quote != 0 && next == quote {
+				buf.WriteByte(next)
+			} else {
+				buf.WriteByte(c)
+				continue
+			}
+		}
+		i++
+	}
+	return buf.String()
+}
+
+// encodeCodepoint 与 PHP 一致，允许编码代理区码点
+func encodeCodepoint(r rune) string {
+	if r >= 0xD800 && r <= 0xDFFF {
+		return string([]byte{byte(0xE0 | r>>12), byte(0x80 | (r>>6)&0x3F), byte(0x80 | r&0x3F)})
+	}
+	var b [utf8.UTFMax]byte
+	n := utf8.EncodeRune(b[:], r)
+	return string(b[:n])
+}
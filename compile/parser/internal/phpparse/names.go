@@ -0,0 +1,121 @@
+package phpparse
+
+import (
+	"github.com/heyuuu/gophp/compile/ast"
+	"slices"
+	"strings"
+)
+
+// nameContext 名称解析上下文，解析规则与 PHP-Parser 的 NameResolver 保持一致
+type nameContext struct {
+	namespace *ast.Name // 当前命名空间，全局命名空间时为 nil
+	aliases   map[ast.UseType]map[string]*ast.Name
+}
+
+func (c *nameContext) startNamespace(namespace *ast.Name) {
+	c.namespace = namespace
+	c.aliases = map[ast.UseType]map[string]*ast.Name{
+		ast.UseNormal:   {},
+		ast.UseFunction: {},
+		ast.UseConstant: {},
+	}
+}
+
+func (c *nameContext) addAlias(typ ast.UseType, name *ast.Name, alias string) {
+	var key string
+	if typ == ast.UseConstant {
+		// 常量别名大小写敏感
+		key = alias
+	} else {
+		key = strings.ToLower(alias)
+	}
+	c.aliases[typ][key] = name
+}
+
+func (c *nameContext) addUse(use *ast.UseStmt) {
+	alias := use.Name.Parts[len(use.Name.Parts)-1]
+	if use.Alias != nil {
+		alias = use.Alias.Name
+	}
+	c.addAlias(use.Type, use.Name, alias)
+}
+
+func fullyQualified(parts ...[]string) *ast.Name {
+	return &ast.Name{Kind: ast.NameFullyQualified, Parts: slices.Concat(parts...)}
+}
+
+func (c *nameContext) namespaceParts() []string {
+	if c.namespace == nil {
+		return nil
+	}
+	return c.namespace.Parts
+}
+
+// namespacedName 声明语句(函数、类、常量等)在当前命名空间下的名称
+func (c *nameContext) namespacedName(name string) *ast.Name {
+	return ast.NewName(slices.Concat(c.namespaceParts(), []string{name})...)
+}
+
+func isSpecialClassName(name string) bool {
+	switch strings.ToLower(name) {
+	case "self", "parent", "static":
+		return true
+	}
+	return false
+}
+
+// resolveAlias 尝试通过 use 别名解析名称
+func (c *nameContext) resolveAlias(name *ast.Name, typ ast.UseType) *ast.Name {
+	if name.IsQualified() {
+		// 限定名称只解析第一段
+		if alias, ok := c.aliases[ast.UseNormal][strings.ToLower(name.Parts[0])]; ok {
+			return fullyQualified(alias.Parts, name.Parts[1:])
+		}
+	} else if name.IsUnqualified() {
+		key := name.Parts[0]
+		if typ != ast.UseConstant {
+			key = strings.ToLower(key)
+		}
+		if alias, ok := c.aliases[typ][key]; ok {
+			return fullyQualified(alias.Parts)
+		}
+	}
+	return nil
+}
+
+// resolveClassName 解析类名
+func (c *nameContext) resolveClassName(name *ast.Name) *ast.Name {
+	if name.IsFullyQualified() {
+		return name
+	}
+	if name.IsUnqualified() && isSpecialClassName(name.Parts[0]) {
+		return name
+	}
+	if resolved := c.resolveAlias(name, ast.UseNormal); resolved != nil {
+		return resolved
+	}
+	return fullyQualified(c.namespaceParts(), name.Parts)
+}
+
+// resolveOtherName 解析函数名或常量名。命名空间内的非限定名称需要运行时回退到全局，此时保留原名称并在元数据中记录命名空间名称
+func (c *nameContext) resolveOtherName(name *ast.Name, typ ast.UseType) *ast.Name {
+	if name.IsFullyQualified() {
+		return name
+	}
+	if resolved := c.resolveAlias(name, typ); resolved != nil {
+		return resolved
+	}
+	if name.IsUnqualified() {
+		if c.namespace == nil {
+			return fullyQualified(name.Parts)
+		}
+		meta := name.Meta()
+		if meta == nil {
+			meta = ast.NewNodeMeta()
+			name.SetMeta(meta)
+		}
+		meta.Others["namespacedName"] = fullyQualified(c.namespaceParts(), name.Parts)
+		return name
+	}
+	return fullyQualified(c.namespaceParts(), name.Parts)
+}
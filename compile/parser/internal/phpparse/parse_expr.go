@@ -0,0 +1,854 @@
+package phpparse
+
+import (
+	"github.com/heyuuu/gophp/compile/ast"
+	"github.com/heyuuu/gophp/compile/token"
+	"regexp"
+	"strings"
+)
+
+// 运算符优先级，由低到高
+const (
+	precLowest = iota
+	precLogicalOr
+	precLogicalXor
+	precLogicalAnd
+	precPrint
+	precYield
+	precAssign
+	precTernary
+	precCoalesce
+	precBooleanOr
+	precBooleanAnd
+	precBitwiseOr
+	precBitwiseXor
+	precBitwiseAnd
+	precEquality
+	precComparison
+	precConcat
+	precShift
+	precAdditive
+	precMultiplicative
+	precBooleanNot
+	precInstanceof
+	precUnary
+	precPow
+	precClone
+)
+
+type binaryOpInfo struct {
+	prec  int
+	right bool // 是否右结合
+	op    ast.BinaryOpKind
+}
+
+var binaryOps = map[token.Token]binaryOpInfo{
+	token.LOGICAL_OR:          {precLogicalOr, false, ast.BinaryOpBooleanOr}, // 使用 `||` 代替 `or`
+	token.LOGICAL_XOR:         {precLogicalXor, false, ast.BinaryOpBooleanXor},
+	token.LOGICAL_AND:         {precLogicalAnd, false, ast.BinaryOpBooleanAnd}, // 使用 `&&` 代替 `and`
+	token.COALESCE:            {precCoalesce, true, ast.BinaryOpCoalesce},
+	token.BOOLEAN_OR:          {precBooleanOr, false, ast.BinaryOpBooleanOr},
+	token.BOOLEAN_AND:         {precBooleanAnd, false, ast.BinaryOpBooleanAnd},
+	'|':                       {precBitwiseOr, false, ast.BinaryOpBitwiseOr},
+	'^':                       {precBitwiseXor, false, ast.BinaryOpBitwiseXor},
+	'&':                       {precBitwiseAnd, false, ast.BinaryOpBitwiseAnd},
+	token.IS_EQUAL:            {precEquality, false, ast.BinaryOpEqual},
+	token.IS_NOT_EQUAL:        {precEquality, false, ast.BinaryOpNotEqual},
+	token.IS_IDENTICAL:        {precEquality, false, ast.BinaryOpIdentical},
+	token.IS_NOT_IDENTICAL:    {precEquality, false, ast.BinaryOpNotIdentical},
+	token.SPACESHIP:           {precEquality, false, ast.BinaryOpSpaceship},
+	'<':                       {precComparison, false, ast.BinaryOpSmaller},
+	token.IS_SMALLER_OR_EQUAL: {precComparison, false, ast.BinaryOpSmallerOrEqual},
+	'>':                       {precComparison, false, ast.BinaryOpGreater},
+	token.IS_GREATER_OR_EQUAL: {precComparison, false, ast.BinaryOpGreaterOrEqual},
+	'.':                       {precConcat, false, ast.BinaryOpConcat},
+	token.SL:                  {precShift, false, ast.BinaryOpShiftLeft},
+	token.SR:                  {precShift, false, ast.BinaryOpShiftRight},
+	'+':                       {precAdditive, false, ast.BinaryOpPlus},
+	'-':                       {precAdditive, false, ast.BinaryOpMinus},
+	'*':                       {precMultiplicative, false, ast.BinaryOpMul},
+	'/':                       {precMultiplicative, false, ast.BinaryOpDiv},
+	'%':                       {precMultiplicative, false, ast.BinaryOpMod},
+	token.POW:                 {precPow, true, ast.BinaryOpPow},
+}
+
+var assignOps = map[token.Token]ast.AssignOpKind{
+	token.PLUS_EQUAL:     ast.AssignOpPlus,
+	token.MINUS_EQUAL:    ast.AssignOpMinus,
+	token.MUL_EQUAL:      ast.AssignOpMul,
+	token.DIV_EQUAL:      ast.AssignOpDiv,
+	token.CONCAT_EQUAL:   ast.AssignOpConcat,
+	token.MOD_EQUAL:      ast.AssignOpMod,
+	token.AND_EQUAL:      ast.AssignOpBitwiseAnd,
+	token.OR_EQUAL:       ast.AssignOpBitwiseOr,
+	token.XOR_EQUAL:      ast.AssignOpBitwiseXor,
+	token.SL_EQUAL:       ast.AssignOpShiftLeft,
+	token.SR_EQUAL:       ast.AssignOpShiftRight,
+	token.POW_EQUAL:      ast.AssignOpPow,
+	token.COALESCE_EQUAL: ast.AssignOpCoalesce,
+}
+
+var castKinds = map[token.Token]ast.CastKind{
+	token.ARRAY_CAST:  ast.CastArray,
+	token.BOOL_CAST:   ast.CastBool,
+	token.DOUBLE_CAST: ast.CastDouble,
+	token.INT_CAST:    ast.CastInt,
+	token.OBJECT_CAST: ast.CastObject,
+	token.STRING_CAST: ast.CastString,
+	token.UNSET_CAST:  ast.CastUnset,
+}
+
+var includeKinds = map[token.Token]ast.IncludeKind{
+	token.INCLUDE:      ast.KindInclude,
+	token.INCLUDE_ONCE: ast.KindIncludeOnce,
+	token.REQUIRE:      ast.KindRequire,
+	token.REQUIRE_ONCE: ast.KindRequireOnce,
+}
+
+var magicConstKinds = map[token.Token]ast.MagicConstKind{
+	token.CLASS_C:  ast.MagicConstClass,
+	token.DIR:      ast.MagicConstDir,
+	token.FILE:     ast.MagicConstFile,
+	token.FUNC_C:   ast.MagicConstFunction,
+	token.LINE:     ast.MagicConstLine,
+	token.METHOD_C: ast.MagicConstMethod,
+	token.NS_C:     ast.MagicConstNamespace,
+	token.TRAIT_C:  ast.MagicConstTrait,
+}
+
+func (p *parser) expr() ast.Expr {
+	return p.exprPrec(precLowest)
+}
+
+func (p *parser) exprList() []ast.Expr {
+	var exprs []ast.Expr
+	for {
+		exprs = append(exprs, p.expr())
+		if !p.accept(',') {
+			return exprs
+		}
+	}
+}
+
+// exprPrec 解析优先级高于 minPrec 的表达式
+func (p *parser) exprPrec(minPrec int) ast.Expr {
+	start := p.startPos()
+	left := p.unaryExpr()
+	for {
+		p.finish(left, start)
+		tok := p.tok()
+
+		// 与 PHP 一致，赋值表达式的左值不受优先级限制，如 `!$a = 1` 解析为 `!($a = 1)`
+		if tok == '=' {
+			if !isAssignable(left, true) {
+				return left
+			}
+			left = p.assignExpr(left)
+			continue
+		}
+		if op, ok := assignOps[tok]; ok {
+			if !isAssignable(left, false) {
+				return left
+			}
+			p.next()
+			left = &ast.AssignOpExpr{Op: op, Var: left, Expr: p.exprPrec(precAssign - 1)}
+			continue
+		}
+
+		switch tok {
+		case '?':
+			if precTernary <= minPrec {
+				return left
+			}
+			p.next()
+			ternary := &ast.TernaryExpr{Cond: left}
+			if !p.accept(':') {
+				ternary.If = p.expr()
+				p.expect(':')
+			}
+			ternary.Else = p.exprPrec(precTernary)
+			left = ternary
+			continue
+		case token.INSTANCEOF:
+			if precInstanceof <= minPrec {
+				return left
+			}
+			p.next()
+			left = &ast.InstanceofExpr{Expr: left, Class: p.classNameReference()}
+			continue
+		}
+
+		info, ok := binaryOps[tok]
+		if !ok || info.prec <= minPrec {
+			return left
+		}
+		p.next()
+		var right ast.Expr
+		if info.right {
+			right = p.exprPrec(info.prec - 1)
+		} else {
+			right = p.exprPrec(info.prec)
+		}
+		left = &ast.BinaryOpExpr{Op: info.op, Left: left, Right: right}
+	}
+}
+
+func isAssignable(expr ast.Expr, allowArray bool) bool {
+	switch expr.(type) {
+	case *ast.VariableExpr, *ast.IndexExpr, *ast.PropertyFetchExpr, *ast.StaticPropertyFetchExpr, *ast.ListExpr:
+		return true
+	case *ast.ArrayExpr:
+		return allowArray
+	}
+	return false
+}
+
+func (p *parser) assignExpr(left ast.Expr) ast.Expr {
+	p.expect('=')
+	if arr, ok := left.(*ast.ArrayExpr); ok {
+		left = fixupArrayDestructuring(arr)
+	}
+	if p.accept('&') {
+		return &ast.AssignRefExpr{Var: left, Expr: p.exprPrec(precAssign - 1)}
+	}
+	return &ast.AssignExpr{Var: left, Expr: p.exprPrec(precAssign - 1)}
+}
+
+// fixupArrayDestructuring 将用于解构赋值的数组转为 list
+func fixupArrayDestructuring(arr *ast.ArrayExpr) *ast.ListExpr {
+	items := make([]*ast.ArrayItemExpr, len(arr.Items))
+	for i, item := range arr.Items {
+		if item != nil {
+			if inner, ok := item.Value.(*ast.ArrayExpr); ok {
+				item.Value = fixupArrayDestructuring(inner)
+			}
+		}
+		items[i] = item
+	}
+	list := &ast.ListExpr{Items: items}
+	list.SetMeta(arr.Meta())
+	return list
+}
+
+func (p *parser) unaryExpr() ast.Expr {
+	tok := p.tok()
+	if kind, ok := castKinds[tok]; ok {
+		p.next()
+		return &ast.CastExpr{Kind: kind, Expr: p.exprPrec(precUnary)}
+	}
+	if kind, ok := includeKinds[tok]; ok {
+		p.next()
+		return &ast.IncludeExpr{Kind: kind, Expr: p.exprPrec(precLowest)}
+	}
+
+	switch tok {
+	case '!':
+		p.next()
+		return &ast.UnaryExpr{Op: ast.UnaryOpBooleanNot, Var: p.exprPrec(precBooleanNot)}
+	case '~':
+		p.next()
+		return &ast.UnaryExpr{Op: ast.UnaryOpBitwiseNot, Var: p.exprPrec(precUnary)}
+	case '-':
+		p.next()
+		return &ast.UnaryExpr{Op: ast.UnaryOpMinus, Var: p.exprPrec(precUnary)}
+	case '+':
+		p.next()
+		return &ast.UnaryExpr{Op: ast.UnaryOpPlus, Var: p.exprPrec(precUnary)}
+	case token.INC:
+		p.next()
+		return &ast.UnaryExpr{Op: ast.UnaryOpPreInc, Var: p.exprPrec(precUnary)}
+	case token.DEC:
+		p.next()
+		return &ast.UnaryExpr{Op: ast.UnaryOpPreDec, Var: p.exprPrec(precUnary)}
+	case '@':
+		p.next()
+		return &ast.ErrorSuppressExpr{Expr: p.exprPrec(precUnary)}
+	case token.PRINT:
+		p.next()
+		return &ast.PrintExpr{Expr: p.exprPrec(precPrint)}
+	case token.YIELD:
+		p.next()
+		yield := &ast.YieldExpr{}
+		if p.at(';', ')', ',', ']', '}', token.CLOSE_TAG, token.EOF) {
+			return yield
+		}
+		yield.Value = p.exprPrec(precYield)
+		if p.accept(token.DOUBLE_ARROW) {
+			yield.Key = yield.Value
+			yield.Value = p.exprPrec(precYield)
+		}
+		return yield
+	case token.YIELD_FROM:
+		p.next()
+		return &ast.YieldFromExpr{Expr: p.exprPrec(precYield)}
+	case token.THROW:
+		p.next()
+		return &ast.ThrowExpr{Expr: p.exprPrec(precLowest)}
+	case token.CLONE:
+		p.next()
+		return &ast.CloneExpr{Expr: p.exprPrec(precClone)}
+	case token.NEW:
+		return p.newExpr()
+	}
+
+	start := p.startPos()
+	expr, dereferencable := p.primaryExpr()
+	p.finish(expr, start)
+	if dereferencable {
+		expr = p.postfixExpr(expr)
+	}
+	return expr
+}
+
+// primaryExpr 解析基本表达式，并返回其是否可以继续接 `[]`、`->` 等后缀
+func (p *parser) primaryExpr() (expr ast.Expr, dereferencable bool) {
+	tok := p.tok()
+	if kind, ok := magicConstKinds[tok]; ok {
+		p.next()
+		return &ast.MagicConstExpr{Kind: kind}, false
+	}
+
+	switch tok {
+	case token.VARIABLE, '$':
+		return p.simpleVariable(), true
+	case token.LNUMBER:
+		t := p.next()
+		value, _ := parseIntLiteral(t.value)
+		lit := &ast.IntLit{Value: value}
+		setRawValue(lit, t.value)
+		return lit, false
+	case token.DNUMBER:
+		t := p.next()
+		lit := &ast.FloatLit{Value: parseFloatLiteral(t.value)}
+		setRawValue(lit, t.value)
+		return lit, false
+	case token.CONSTANT_ENCAPSED_STRING:
+		t := p.next()
+		var lit *ast.StringLit
+		if raw := strings.TrimLeft(t.value, "bB"); raw[0] == '\'' {
+			lit = &ast.StringLit{Value: parseSingleQuoteString(t.value)}
+		} else {
+			lit = &ast.StringLit{Value: parseEscapeSequences(raw[1:len(raw)-1], '"')}
+		}
+		setRawValue(lit, t.value)
+		return lit, true
+	case '"':
+		p.next()
+		parts := p.encapsList('"', '"')
+		p.expect('"')
+		return concatParts(parts), true
+	case token.START_HEREDOC:
+		return p.heredoc(), true
+	case '`':
+		p.next()
+		parts := p.encapsList('`', '`')
+		p.expect('`')
+		return &ast.ShellExecExpr{Parts: parts}, false
+	case token.STRING, token.NAME_QUALIFIED, token.NAME_FULLY_QUALIFIED, token.NAME_RELATIVE:
+		name := p.name()
+		switch p.tok() {
+		case '(':
+			return &ast.FuncCallExpr{Name: p.resolveOtherName(name, ast.UseFunction), Args: p.arguments()}, true
+		case token.PAAMAYIM_NEKUDOTAYIM:
+			return p.staticMember(p.resolveClassName(name)), true
+		default:
+			return &ast.ConstFetchExpr{Name: p.resolveOtherName(name, ast.UseConstant)}, true
+		}
+	case token.STATIC:
+		switch p.peek(1) {
+		case token.FUNCTION:
+			p.next()
+			return p.closureExpr(true), false
+		case token.FN:
+			p.next()
+			return p.arrowFunctionExpr(true), false
+		}
+		p.next()
+		return p.staticMember(ast.NewName("static")), true
+	case token.FUNCTION:
+		return p.closureExpr(false), false
+	case token.FN:
+		return p.arrowFunctionExpr(false), false
+	case '(':
+		p.next()
+		expr = p.expr()
+		p.expect(')')
+		return expr, true
+	case '[':
+		p.next()
+		return &ast.ArrayExpr{Items: p.arrayItems(']')}, true
+	case token.ARRAY:
+		p.next()
+		p.expect('(')
+		return &ast.ArrayExpr{Items: p.arrayItems(')')}, true
+	case token.LIST:
+		p.next()
+		p.expect('(')
+		items := p.arrayItems(')')
+		for _, item := range items {
+			if item != nil {
+				if inner, ok := item.Value.(*ast.ArrayExpr); ok {
+					item.Value = fixupArrayDestructuring(inner)
+				}
+			}
+		}
+		return &ast.ListExpr{Items: items}, false
+	case token.ISSET:
+		p.next()
+		p.expect('(')
+		var vars []ast.Expr
+		for !p.at(')') {
+			vars = append(vars, p.expr())
+			if !p.accept(',') {
+				break
+			}
+		}
+		p.expect(')')
+		return &ast.IssetExpr{Vars: vars}, false
+	case token.EMPTY:
+		p.next()
+		return &ast.EmptyExpr{Expr: p.parenExpr()}, false
+	case token.EVAL:
+		p.next()
+		return &ast.EvalExpr{Expr: p.parenExpr()}, false
+	case token.EXIT:
+		p.next()
+		exit := &ast.ExitExpr{}
+		if p.accept('(') {
+			if !p.at(')') {
+				exit.Expr = p.expr()
+			}
+			p.expect(')')
+		}
+		return exit, false
+	case token.MATCH:
+		return p.matchExpr(), false
+	case token.ATTRIBUTE:
+		p.unsupported("unsupported high version php feature: php8.0 attribute")
+	}
+
+	p.unexpected()
+	return nil, false
+}
+
+// matchExpr 解析 match 表达式，分支列表可以为空，允许尾部逗号
+func (p *parser) matchExpr() *ast.MatchExpr {
+	p.expect(token.MATCH)
+	match := &ast.MatchExpr{Cond: p.parenExpr()}
+	p.expect('{')
+	for !p.at('}') {
+		start := p.startPos()
+		arm := &ast.MatchArm{}
+		if p.accept(token.DEFAULT) {
+			p.accept(',')
+		} else {
+			for {
+				arm.Conds = append(arm.Conds, p.expr())
+				if !p.accept(',') || p.at(token.DOUBLE_ARROW) {
+					break
+				}
+			}
+		}
+		p.expect(token.DOUBLE_ARROW)
+		arm.Body = p.expr()
+		p.finish(arm, start)
+		match.Arms = append(match.Arms, arm)
+		if !p.accept(',') {
+			break
+		}
+	}
+	p.expect('}')
+	return match
+}
+
+func (p *parser) postfixExpr(expr ast.Expr) ast.Expr {
+	start := ast.MetaStart(expr)
+	for {
+		p.finish(expr, start)
+		switch p.tok() {
+		case '[':
+			p.next()
+			index := &ast.IndexExpr{Var: expr}
+			if !p.at(']') {
+				index.Dim = p.expr()
+			}
+			p.expect(']')
+			expr = index
+		case token.OBJECT_OPERATOR, token.NULLSAFE_OBJECT_OPERATOR:
+			nullsafe := p.next().tok == token.NULLSAFE_OBJECT_OPERATOR
+			name := p.propertyName()
+			if p.at('(') {
+				expr = &ast.MethodCallExpr{Var: expr, Name: name, Args: p.arguments(), Nullsafe: nullsafe}
+			} else {
+				expr = &ast.PropertyFetchExpr{Var: expr, Name: name, Nullsafe: nullsafe}
+			}
+		case token.PAAMAYIM_NEKUDOTAYIM:
+			expr = p.staticMember(expr)
+		case '(':
+			expr = &ast.FuncCallExpr{Name: expr, Args: p.arguments()}
+		case token.INC:
+			p.next()
+			expr = &ast.UnaryExpr{Op: ast.UnaryOpPostInc, Var: expr}
+			p.finish(expr, start)
+			return expr
+		case token.DEC:
+			p.next()
+			expr = &ast.UnaryExpr{Op: ast.UnaryOpPostDec, Var: expr}
+			p.finish(expr, start)
+			return expr
+		default:
+			return expr
+		}
+	}
+}
+
+// variable 解析 `$name` 形式的变量
+func (p *parser) variable() *ast.VariableExpr {
+	start := p.startPos()
+	t := p.expect(token.VARIABLE)
+	expr := &ast.VariableExpr{Name: &ast.Ident{Name: t.value[1:]}}
+	p.finish(expr.Name, start)
+	p.finish(expr, start)
+	return expr
+}
+
+// simpleVariable 解析 `$name`、`$$name` 或 `${expr}` 形式的变量
+func (p *parser) simpleVariable() ast.Expr {
+	if p.at(token.VARIABLE) {
+		return p.variable()
+	}
+	p.expect('$')
+	if p.accept('{') {
+		name := p.expr()
+		p.expect('}')
+		return &ast.VariableExpr{Name: name}
+	}
+	return &ast.VariableExpr{Name: p.simpleVariable()}
+}
+
+// propertyName `->` 之后的属性名或方法名
+func (p *parser) propertyName() ast.Node {
+	switch p.tok() {
+	case token.VARIABLE, '$':
+		return p.simpleVariable()
+	case '{':
+		p.next()
+		name := p.expr()
+		p.expect('}')
+		return name
+	}
+	return p.identifierMaybeReserved()
+}
+
+// staticMember 解析 `::` 之后的静态属性、类常量或静态方法调用
+func (p *parser) staticMember(class ast.Node) ast.Expr {
+	p.expect(token.PAAMAYIM_NEKUDOTAYIM)
+	switch p.tok() {
+	case token.VARIABLE:
+		if p.peek(1) == '(' {
+			return &ast.StaticCallExpr{Class: class, Name: p.variable(), Args: p.arguments()}
+		}
+		name := p.next().value[1:]
+		return &ast.StaticPropertyFetchExpr{Class: class, Name: &ast.Ident{Name: name, VarLike: true}}
+	case '$':
+		p.next()
+		return &ast.StaticPropertyFetchExpr{Class: class, Name: p.simpleVariable()}
+	case '{':
+		p.next()
+		name := p.expr()
+		p.expect('}')
+		return &ast.StaticCallExpr{Class: class, Name: name, Args: p.arguments()}
+	}
+
+	name := p.identifierMaybeReserved()
+	if p.at('(') {
+		return &ast.StaticCallExpr{Class: class, Name: name, Args: p.arguments()}
+	}
+	return &ast.ClassConstFetchExpr{Class: class, Name: name}
+}
+
+// classNameReference 解析 new 及 instanceof 之后的类名
+func (p *parser) classNameReference() ast.Node {
+	switch p.tok() {
+	case token.STATIC:
+		p.next()
+		return ast.NewName("static")
+	case token.STRING, token.NAME_QUALIFIED, token.NAME_FULLY_QUALIFIED, token.NAME_RELATIVE:
+		return p.className()
+	case '(':
+		return p.parenExpr()
+	}
+	return p.newVariable()
+}
+
+// newVariable new 之后的变量表达式，不能包含函数调用
+func (p *parser) newVariable() ast.Expr {
+	var expr ast.Expr
+	if p.atName() {
+		expr = p.staticMemberOnly(p.className())
+	} else {
+		expr = p.simpleVariable()
+	}
+	for {
+		switch p.tok() {
+		case '[':
+			p.next()
+			index := &ast.IndexExpr{Var: expr}
+			if !p.at(']') {
+				index.Dim = p.expr()
+			}
+			p.expect(']')
+			expr = index
+		case token.OBJECT_OPERATOR, token.NULLSAFE_OBJECT_OPERATOR:
+			nullsafe := p.next().tok == token.NULLSAFE_OBJECT_OPERATOR
+			expr = &ast.PropertyFetchExpr{Var: expr, Name: p.propertyName(), Nullsafe: nullsafe}
+		case token.PAAMAYIM_NEKUDOTAYIM:
+			expr = p.staticMemberOnly(expr)
+		default:
+			return expr
+		}
+	}
+}
+
+func (p *parser) staticMemberOnly(class ast.Node) ast.Expr {
+	p.expect(token.PAAMAYIM_NEKUDOTAYIM)
+	if p.at('$') {
+		p.next()
+		return &ast.StaticPropertyFetchExpr{Class: class, Name: p.simpleVariable()}
+	}
+	name := p.expect(token.VARIABLE).value[1:]
+	return &ast.StaticPropertyFetchExpr{Class: class, Name: &ast.Ident{Name: name, VarLike: true}}
+}
+
+func (p *parser) newExpr() ast.Expr {
+	p.expect(token.NEW)
+	if p.at(token.CLASS) {
+		// 匿名类
+		p.next()
+		var args []*ast.Arg
+		if p.at('(') {
+			args = p.arguments()
+		}
+		class := &ast.ClassStmt{}
+		p.classExtendsAndBody(class)
+		return &ast.NewExpr{Class: class, Args: args}
+	}
+
+	expr := &ast.NewExpr{Class: p.classNameReference()}
+	if p.at('(') {
+		expr.Args = p.arguments()
+	}
+	return expr
+}
+
+func (p *parser) arguments() []*ast.Arg {
+	p.expect('(')
+	var args []*ast.Arg
+	for !p.at(')') {
+		switch {
+		case p.at(token.ELLIPSIS) && p.peek(1) == ')' && len(args) == 0:
+			p.unsupported("unsupported high version php feature: php8.2 first class callable syntax")
+		case (p.at(token.STRING) || p.tok().IsKeyword()) && p.peek(1) == ':':
+			p.unsupported("unsupported high version php feature: php8.0 named arguments")
+		case p.at('&'):
+			p.unsupported("lower version php feature: Call-time pass-by-reference has been removed in PHP 5.4")
+		}
+
+		start := p.startPos()
+		unpack := p.accept(token.ELLIPSIS)
+		arg := &ast.Arg{Value: p.expr(), Unpack: unpack}
+		p.finish(arg, start)
+		args = append(args, arg)
+		if !p.accept(',') {
+			break
+		}
+	}
+	p.expect(')')
+	return args
+}
+
+// arrayItems 解析数组元素直到 end，空元素(仅用于 list)为 nil，末尾的空元素会被忽略
+func (p *parser) arrayItems(end token.Token) []*ast.ArrayItemExpr {
+	var items []*ast.ArrayItemExpr
+	for !p.at(end) {
+		if p.accept(',') {
+			items = append(items, nil)
+			continue
+		}
+		items = append(items, p.arrayItem())
+		if !p.accept(',') {
+			break
+		}
+	}
+	p.expect(end)
+	return items
+}
+
+func (p *parser) arrayItem() *ast.ArrayItemExpr {
+	start := p.startPos()
+	item := &ast.ArrayItemExpr{}
+	switch {
+	case p.accept(token.ELLIPSIS):
+		item.Unpack = true
+		item.Value = p.expr()
+	case p.accept('&'):
+		item.ByRef = true
+		item.Value = p.expr()
+	default:
+		item.Value = p.expr()
+		if p.accept(token.DOUBLE_ARROW) {
+			item.Key = item.Value
+			item.ByRef = p.accept('&')
+			item.Value = p.expr()
+		}
+	}
+	p.finish(item, start)
+	return item
+}
+
+func (p *parser) closureExpr(static bool) ast.Expr {
+	p.expect(token.FUNCTION)
+	expr := &ast.ClosureExpr{Static: static}
+	expr.ByRef = p.accept('&')
+	expr.Params = p.params()
+	if p.accept(token.USE) {
+		p.expect('(')
+		for !p.at(')') {
+			use := &ast.ClosureUseExpr{}
+			use.ByRef = p.accept('&')
+			use.Var = p.variable()
+			expr.Uses = append(expr.Uses, use)
+			if !p.accept(',') {
+				break
+			}
+		}
+		p.expect(')')
+	}
+	expr.ReturnType = p.returnType()
+	expr.Stmts = p.block()
+	return expr
+}
+
+func (p *parser) arrowFunctionExpr(static bool) ast.Expr {
+	p.expect(token.FN)
+	expr := &ast.ArrowFunctionExpr{Static: static}
+	expr.ByRef = p.accept('&')
+	expr.Params = p.params()
+	expr.ReturnType = p.returnType()
+	p.expect(token.DOUBLE_ARROW)
+	expr.Expr = p.exprPrec(precLowest)
+	return expr
+}
+
+/* 字符串插值 */
+
+// concatParts 插值字符串的各部分转为连接表达式
+func concatParts(parts []ast.Expr) ast.Expr {
+	if len(parts) == 0 {
+		return &ast.StringLit{Value: ""}
+	}
+	expr := parts[0]
+	for _, next := range parts[1:] {
+		expr = &ast.BinaryOpExpr{Op: ast.BinaryOpConcat, Left: expr, Right: next}
+	}
+	return expr
+}
+
+// encapsList 解析插值字符串中的各部分，quote 用于处理转义(heredoc 为 0)
+func (p *parser) encapsList(end token.Token, quote byte) []ast.Expr {
+	var parts []ast.Expr
+	for !p.at(end) {
+		switch p.tok() {
+		case token.ENCAPSED_AND_WHITESPACE:
+			t := p.next()
+			value := parseEscapeSequences(t.text, quote)
+			if value == "" && quote == 0 {
+				continue
+			}
+			parts = append(parts, &ast.StringLit{Value: value})
+		case token.VARIABLE:
+			var expr ast.Expr = p.variable()
+			switch p.tok() {
+			case '[':
+				p.next()
+				expr = &ast.IndexExpr{Var: expr, Dim: p.encapsOffset()}
+				p.expect(']')
+			case token.OBJECT_OPERATOR, token.NULLSAFE_OBJECT_OPERATOR:
+				nullsafe := p.next().tok == token.NULLSAFE_OBJECT_OPERATOR
+				expr = &ast.PropertyFetchExpr{Var: expr, Name: p.ident(), Nullsafe: nullsafe}
+			}
+			parts = append(parts, expr)
+		case token.CURLY_OPEN:
+			p.next()
+			parts = append(parts, p.expr())
+			p.expect('}')
+		case token.DOLLAR_OPEN_CURLY_BRACES:
+			p.next()
+			var expr ast.Expr
+			if p.at(token.STRING_VARNAME) {
+				expr = &ast.VariableExpr{Name: &ast.Ident{Name: p.next().value}}
+				if p.accept('[') {
+					expr = &ast.IndexExpr{Var: expr, Dim: p.expr()}
+					p.expect(']')
+				}
+			} else {
+				expr = &ast.VariableExpr{Name: p.expr()}
+			}
+			p.expect('}')
+			parts = append(parts, expr)
+		default:
+			p.unexpected(end)
+		}
+	}
+	return parts
+}
+
+var numStringRegexp = regexp.MustCompile(`^(?:0|-?[1-9][0-9]*)$`)
+
+// encapsOffset 解析字符串插值 "$a[...]" 中的下标
+func (p *parser) encapsOffset() ast.Expr {
+	switch p.tok() {
+	case token.STRING:
+		return &ast.StringLit{Value: p.next().value}
+	case token.VARIABLE:
+		return p.variable()
+	case token.NUM_STRING:
+		return numString(p.next().value)
+	case '-':
+		p.next()
+		return numString("-" + p.expect(token.NUM_STRING).value)
+	}
+	p.unexpected(token.STRING, token.VARIABLE, token.NUM_STRING)
+	return nil
+}
+
+func numString(s string) ast.Expr {
+	if numStringRegexp.MatchString(s) {
+		if value, ok := parseIntLiteral(s); ok {
+			return &ast.IntLit{Value: value}
+		}
+	}
+	return &ast.StringLit{Value: s}
+}
+
+func (p *parser) heredoc() ast.Expr {
+	start := p.expect(token.START_HEREDOC)
+	if strings.Contains(start.value, "'") {
+		// nowdoc
+		var value string
+		if p.at(token.ENCAPSED_AND_WHITESPACE) {
+			value = p.next().text
+		}
+		p.expect(token.END_HEREDOC)
+		return &ast.StringLit{Value: value}
+	}
+
+	parts := p.encapsList(token.END_HEREDOC, 0)
+	p.expect(token.END_HEREDOC)
+	if len(parts) == 1 {
+		if _, ok := parts[0].(*ast.StringLit); ok {
+			return parts[0]
+		}
+	}
+	return concatParts(parts)
+}
@@ -0,0 +1,950 @@
+package phpparse
+
+import (
+	"github.com/heyuuu/gophp/compile/ast"
+	"github.com/heyuuu/gophp/compile/token"
+	"slices"
+	"strings"
+)
+
+func (p *parser) topStmtList() []ast.Stmt {
+	var stmts []ast.Stmt
+	for !p.at(token.EOF) {
+		if p.at(token.NAMESPACE) {
+			comments := p.takeComments()
+			start := p.startPos()
+			stmt := p.guard(func() ast.Stmt { return p.namespaceStmt() })
+			if stmt != nil {
+				p.finish(stmt, start)
+				setComments(stmt, comments)
+				stmts = append(stmts, stmt)
+			}
+			continue
+		}
+		stmts = appendStmt(stmts, p.statement(true))
+	}
+	return p.appendTrailingComments(stmts)
+}
+
+// stmtList 解析语句列表，直到遇到 terminators 中的 token
+func (p *parser) stmtList(top bool, terminators ...token.Token) []ast.Stmt {
+	var stmts []ast.Stmt
+	for !p.at(terminators...) && !p.at(token.EOF) {
+		stmts = appendStmt(stmts, p.statement(top))
+	}
+	return p.appendTrailingComments(stmts)
+}
+
+// appendTrailingComments 语句列表结尾处的注释以 EmptyStmt 形式保留
+func (p *parser) appendTrailingComments(stmts []ast.Stmt) []ast.Stmt {
+	switch p.tok() {
+	case token.NAMESPACE, token.CASE, token.DEFAULT, token.ELSEIF, token.ELSE:
+		// 这些注释归属于后续节点
+		return stmts
+	}
+	if comments := p.takeComments(); len(comments) > 0 {
+		stmt := &ast.EmptyStmt{}
+		setComments(stmt, comments)
+		stmts = append(stmts, stmt)
+	}
+	return stmts
+}
+
+// blockOrStatement 解析控制结构的语句体，`{}` 块会被展开
+func (p *parser) blockOrStatement() []ast.Stmt {
+	return appendStmt(nil, p.statement(false))
+}
+
+// block 解析 `{ ... }` 语句块
+func (p *parser) block() []ast.Stmt {
+	p.expect('{')
+	stmts := p.stmtList(false, '}')
+	p.expect('}')
+	return stmts
+}
+
+// semicolon 语句结束符，`?>` 等同于 `;`
+func (p *parser) semicolon() {
+	if p.accept(';') || p.accept(token.CLOSE_TAG) {
+		return
+	}
+	p.unexpected(';')
+}
+
+func (p *parser) statement(top bool) ast.Stmt {
+	comments := p.takeComments()
+	start := p.startPos()
+	stmt := p.guard(func() ast.Stmt { return p.statementInner(top) })
+	p.finish(stmt, start)
+	if len(comments) == 0 {
+		return stmt
+	}
+
+	switch s := stmt.(type) {
+	case nil:
+		stmt = &ast.EmptyStmt{}
+	case *ast.BlockStmt:
+		if len(s.List) == 0 {
+			stmt = &ast.EmptyStmt{}
+		} else {
+			setComments(s.List[0], comments)
+			return s
+		}
+	}
+	setComments(stmt, comments)
+	return stmt
+}
+
+func (p *parser) statementInner(top bool) ast.Stmt {
+	switch p.tok() {
+	case '{':
+		return &ast.BlockStmt{List: p.block()}
+	case ';', token.CLOSE_TAG:
+		p.next()
+		return nil
+	case token.IF:
+		return p.ifStmt()
+	case token.WHILE:
+		return p.whileStmt()
+	case token.DO:
+		return p.doStmt()
+	case token.FOR:
+		return p.forStmt()
+	case token.FOREACH:
+		return p.foreachStmt()
+	case token.SWITCH:
+		return p.switchStmt()
+	case token.BREAK, token.CONTINUE:
+		isBreak := p.next().tok == token.BREAK
+		var num ast.Expr
+		if !p.at(';', token.CLOSE_TAG) {
+			num = p.expr()
+		}
+		p.semicolon()
+		if isBreak {
+			return &ast.BreakStmt{Num: num}
+		}
+		return &ast.ContinueStmt{Num: num}
+	case token.RETURN:
+		p.next()
+		var expr ast.Expr
+		if !p.at(';', token.CLOSE_TAG) {
+			expr = p.expr()
+		}
+		p.semicolon()
+		return &ast.ReturnStmt{Expr: expr}
+	case token.GLOBAL:
+		p.next()
+		var stmts []*ast.GlobalStmt
+		for {
+			stmts = append(stmts, &ast.GlobalStmt{Var: p.simpleVariable()})
+			if !p.accept(',') {
+				break
+			}
+		}
+		p.semicolon()
+		return blockOf(stmts)
+	case token.STATIC:
+		if p.peek(1) != token.VARIABLE {
+			break
+		}
+		p.next()
+		var stmts []*ast.StaticStmt
+		for {
+			stmt := &ast.StaticStmt{Var: p.variable()}
+			if p.accept('=') {
+				stmt.Default = p.expr()
+			}
+			stmts = append(stmts, stmt)
+			if !p.accept(',') {
+				break
+			}
+		}
+		p.semicolon()
+		return blockOf(stmts)
+	case token.ECHO, token.OPEN_TAG_WITH_ECHO:
+		p.next()
+		exprs := p.exprList()
+		p.semicolon()
+		return &ast.EchoStmt{Exprs: exprs}
+	case token.INLINE_HTML:
+		return &ast.InlineHTMLStmt{Value: p.next().value}
+	case token.UNSET:
+		p.next()
+		p.expect('(')
+		var stmts []*ast.UnsetStmt
+		for !p.at(')') {
+			stmts = append(stmts, &ast.UnsetStmt{Var: p.expr()})
+			if !p.accept(',') {
+				break
+			}
+		}
+		p.expect(')')
+		p.semicolon()
+		return blockOf(stmts)
+	case token.TRY:
+		return p.tryStmt()
+	case token.GOTO:
+		p.next()
+		name := p.ident()
+		p.semicolon()
+		return &ast.GotoStmt{Name: name}
+	case token.STRING:
+		if p.peek(1) == ':' {
+			name := p.ident()
+			p.next()
+			return &ast.LabelStmt{Name: name}
+		}
+	case token.FUNCTION:
+		if p.peek(1) == token.STRING || (p.peek(1) == '&' && p.peek(2) == token.STRING) {
+			return p.functionStmt()
+		}
+	case token.ABSTRACT, token.FINAL, token.CLASS:
+		return p.classStmt()
+	case token.READONLY:
+		if p.peek(1) != '(' {
+			return p.classStmt()
+		}
+	case token.INTERFACE:
+		return p.interfaceStmt()
+	case token.TRAIT:
+		return p.traitStmt()
+	case token.ENUM:
+		p.unsupported("unsupported high version php feature: php8.1 enum")
+	case token.ATTRIBUTE:
+		p.unsupported("unsupported high version php feature: php8.0 attribute")
+	case token.DECLARE:
+		return p.declareStmt()
+	case token.HALT_COMPILER:
+		return p.haltCompilerStmt()
+	case token.NAMESPACE:
+		p.errorf("Namespace declarations cannot be nested")
+	case token.USE:
+		if top {
+			return p.useStmt()
+		}
+	case token.CONST:
+		if top {
+			return p.constStmt()
+		}
+	}
+
+	expr := p.expr()
+	p.semicolon()
+	return &ast.ExprStmt{Expr: expr}
+}
+
+func (p *parser) parenExpr() ast.Expr {
+	p.expect('(')
+	expr := p.expr()
+	p.expect(')')
+	return expr
+}
+
+// altBody 解析替代语法的语句体，如 `while (...): ... endwhile;`
+func (p *parser) altBody(end token.Token) []ast.Stmt {
+	stmts := p.stmtList(false, end)
+	p.expect(end)
+	p.semicolon()
+	return stmts
+}
+
+func (p *parser) ifStmt() *ast.IfStmt {
+	p.expect(token.IF)
+	stmt := &ast.IfStmt{Cond: p.parenExpr()}
+	if p.accept(':') {
+		stmt.Stmts = p.stmtList(false, token.ELSEIF, token.ELSE, token.ENDIF)
+		for p.at(token.ELSEIF) {
+			comments := p.takeComments()
+			start := p.startPos()
+			p.next()
+			elseif := &ast.ElseIfStmt{Cond: p.parenExpr()}
+			p.expect(':')
+			elseif.Stmts = p.stmtList(false, token.ELSEIF, token.ELSE, token.ENDIF)
+			p.finish(elseif, start)
+			setComments(elseif, comments)
+			stmt.Elseifs = append(stmt.Elseifs, elseif)
+		}
+		if p.at(token.ELSE) {
+			comments := p.takeComments()
+			start := p.startPos()
+			p.next()
+			p.expect(':')
+			stmt.Else = &ast.ElseStmt{Stmts: p.stmtList(false, token.ENDIF)}
+			p.finish(stmt.Else, start)
+			setComments(stmt.Else, comments)
+		}
+		p.expect(token.ENDIF)
+		p.semicolon()
+		return stmt
+	}
+
+	stmt.Stmts = p.blockOrStatement()
+	for p.at(token.ELSEIF) {
+		comments := p.takeComments()
+		start := p.startPos()
+		p.next()
+		elseif := &ast.ElseIfStmt{Cond: p.parenExpr()}
+		elseif.Stmts = p.blockOrStatement()
+		p.finish(elseif, start)
+		setComments(elseif, comments)
+		stmt.Elseifs = append(stmt.Elseifs, elseif)
+	}
+	if p.at(token.ELSE) {
+		comments := p.takeComments()
+		start := p.startPos()
+		p.next()
+		stmt.Else = &ast.ElseStmt{Stmts: p.blockOrStatement()}
+		p.finish(stmt.Else, start)
+		setComments(stmt.Else, comments)
+	}
+	return stmt
+}
+
+func (p *parser) whileStmt() *ast.WhileStmt {
+	p.expect(token.WHILE)
+	stmt := &ast.WhileStmt{Cond: p.parenExpr()}
+	if p.accept(':') {
+		stmt.Stmts = p.altBody(token.ENDWHILE)
+	} else {
+		stmt.Stmts = p.blockOrStatement()
+	}
+	return stmt
+}
+
+func (p *parser) doStmt() *ast.DoStmt {
+	p.expect(token.DO)
+	stmts := p.blockOrStatement()
+	p.expect(token.WHILE)
+	cond := p.parenExpr()
+	p.semicolon()
+	return &ast.DoStmt{Stmts: stmts, Cond: cond}
+}
+
+func (p *parser) forStmt() *ast.ForStmt {
+	p.expect(token.FOR)
+	p.expect('(')
+	stmt := &ast.ForStmt{}
+	stmt.Init = p.forExprs(';')
+	stmt.Cond = p.forExprs(';')
+	stmt.Loop = p.forExprs(')')
+	if p.accept(':') {
+		stmt.Stmts = p.altBody(token.ENDFOR)
+	} else {
+		stmt.Stmts = p.blockOrStatement()
+	}
+	return stmt
+}
+
+func (p *parser) forExprs(end token.Token) []ast.Expr {
+	var exprs []ast.Expr
+	if !p.at(end) {
+		exprs = p.exprList()
+	}
+	p.expect(end)
+	return exprs
+}
+
+func (p *parser) foreachStmt() *ast.ForeachStmt {
+	p.expect(token.FOREACH)
+	p.expect('(')
+	stmt := &ast.ForeachStmt{Expr: p.expr()}
+	p.expect(token.AS)
+
+	byRef, value := p.foreachVariable()
+	if p.accept(token.DOUBLE_ARROW) {
+		if byRef {
+			p.errorf("Key element cannot be a reference")
+		}
+		stmt.KeyVar = value
+		byRef, value = p.foreachVariable()
+	}
+	stmt.ByRef = byRef
+	stmt.ValueVar = value
+	p.expect(')')
+
+	if p.accept(':') {
+		stmt.Stmts = p.altBody(token.ENDFOREACH)
+	} else {
+		stmt.Stmts = p.blockOrStatement()
+	}
+	return stmt
+}
+
+func (p *parser) foreachVariable() (byRef bool, expr ast.Expr) {
+	byRef = p.accept('&')
+	expr = p.exprPrec(precAssign)
+	if arr, ok := expr.(*ast.ArrayExpr); ok {
+		expr = fixupArrayDestructuring(arr)
+	}
+	return
+}
+
+func (p *parser) switchStmt() *ast.SwitchStmt {
+	p.expect(token.SWITCH)
+	stmt := &ast.SwitchStmt{Cond: p.parenExpr()}
+
+	var end token.Token = '}'
+	if p.accept(':') {
+		end = token.ENDSWITCH
+	} else {
+		p.expect('{')
+	}
+	p.accept(';')
+
+	for !p.at(end) {
+		comments := p.takeComments()
+		start := p.startPos()
+		caseStmt := &ast.CaseStmt{}
+		if p.accept(token.DEFAULT) {
+			caseStmt.Cond = nil
+		} else {
+			p.expect(token.CASE)
+			caseStmt.Cond = p.expr()
+		}
+		if !p.accept(':') {
+			p.expect(';')
+		}
+		caseStmt.Stmts = p.stmtList(false, token.CASE, token.DEFAULT, end)
+		p.finish(caseStmt, start)
+		setComments(caseStmt, comments)
+		stmt.Cases = append(stmt.Cases, caseStmt)
+	}
+	p.expect(end)
+	if end == token.ENDSWITCH {
+		p.semicolon()
+	}
+	return stmt
+}
+
+func (p *parser) tryStmt() *ast.TryCatchStmt {
+	p.expect(token.TRY)
+	stmt := &ast.TryCatchStmt{Stmts: p.block()}
+	for p.at(token.CATCH) {
+		comments := p.takeComments()
+		start := p.startPos()
+		p.next()
+		p.expect('(')
+		catch := &ast.CatchStmt{}
+		for {
+			catch.Types = append(catch.Types, p.className())
+			if !p.accept('|') {
+				break
+			}
+		}
+		if !p.at(token.VARIABLE) {
+			p.unsupported("php8.0 catch an exception without storing it in a variable.")
+		}
+		catch.Var = p.variable()
+		p.expect(')')
+		catch.Stmts = p.block()
+		p.finish(catch, start)
+		setComments(catch, comments)
+		stmt.Catches = append(stmt.Catches, catch)
+	}
+	if p.at(token.FINALLY) {
+		comments := p.takeComments()
+		start := p.startPos()
+		p.next()
+		stmt.Finally = &ast.FinallyStmt{Stmts: p.block()}
+		p.finish(stmt.Finally, start)
+		setComments(stmt.Finally, comments)
+	}
+	if len(stmt.Catches) == 0 && stmt.Finally == nil {
+		p.errorf("Cannot use try without catch or finally")
+	}
+	return stmt
+}
+
+func (p *parser) declareStmt() *ast.DeclareStmt {
+	p.expect(token.DECLARE)
+	p.expect('(')
+	stmt := &ast.DeclareStmt{}
+	for {
+		key := p.ident()
+		p.expect('=')
+		stmt.Declares = append(stmt.Declares, &ast.DeclareDeclareStmt{Key: key, Value: p.expr()})
+		if !p.accept(',') {
+			break
+		}
+	}
+	p.expect(')')
+
+	switch {
+	case p.at(';', token.CLOSE_TAG):
+		p.next()
+	case p.accept(':'):
+		stmt.Stmts = p.altBody(token.ENDDECLARE)
+	default:
+		stmt.Stmts = p.blockOrStatement()
+	}
+	return stmt
+}
+
+func (p *parser) haltCompilerStmt() *ast.HaltCompilerStmt {
+	p.expect(token.HALT_COMPILER)
+	p.expect('(')
+	p.expect(')')
+	p.semicolon()
+
+	stmt := &ast.HaltCompilerStmt{}
+	if p.at(token.INLINE_HTML) {
+		stmt.Remaining = p.next().value
+	}
+	// 之后的内容不再解析
+	p.pos = len(p.items) - 1
+	return stmt
+}
+
+func (p *parser) namespaceStmt() *ast.NamespaceStmt {
+	start := p.startPos()
+	p.expect(token.NAMESPACE)
+	var name *ast.Name
+	if p.at(token.STRING, token.NAME_QUALIFIED) {
+		name = p.name()
+	}
+	p.names.startNamespace(name)
+
+	if p.at('{') {
+		p.bracketedNamespace = true
+		p.checkNamespaceMix(start)
+		return &ast.NamespaceStmt{Name: name, Stmts: p.block()}
+	}
+	if name == nil {
+		p.unexpected('{')
+	}
+	p.semicolon()
+	p.unbracketedNamespace = true
+	p.checkNamespaceMix(start)
+
+	var stmts []ast.Stmt
+	for !p.at(token.NAMESPACE, token.EOF) {
+		stmts = appendStmt(stmts, p.statement(true))
+	}
+	return &ast.NamespaceStmt{Name: name, Stmts: p.appendTrailingComments(stmts)}
+}
+
+func (p *parser) useType() ast.UseType {
+	switch {
+	case p.accept(token.FUNCTION):
+		return ast.UseFunction
+	case p.accept(token.CONST):
+		return ast.UseConstant
+	default:
+		return ast.UseNormal
+	}
+}
+
+// useName use 语句中的名称，忽略开头的 `\`
+func (p *parser) useName() *ast.Name {
+	name := p.name()
+	if name.Kind == ast.NameRelative {
+		p.errorf("Cannot use namespace\\ in use statements")
+	}
+	return ast.NewName(name.Parts...)
+}
+
+func (p *parser) useAlias() *ast.Ident {
+	if p.accept(token.AS) {
+		return p.ident()
+	}
+	return nil
+}
+
+func (p *parser) useStmt() ast.Stmt {
+	p.expect(token.USE)
+	typ := p.useType()
+
+	var stmts []*ast.UseStmt
+	prefix := p.useName()
+	if p.accept(token.NS_SEPARATOR) {
+		// group use
+		p.expect('{')
+		for !p.at('}') {
+			itemType := typ
+			if typ == ast.UseNormal {
+				itemType = p.useType()
+			}
+			name := p.useName()
+			stmts = append(stmts, &ast.UseStmt{
+				Type:  itemType,
+				Name:  ast.NewName(slices.Concat(prefix.Parts, name.Parts)...),
+				Alias: p.useAlias(),
+			})
+			if !p.accept(',') {
+				break
+			}
+		}
+		p.expect('}')
+	} else {
+		stmts = append(stmts, &ast.UseStmt{Type: typ, Name: prefix, Alias: p.useAlias()})
+		for p.accept(',') {
+			stmts = append(stmts, &ast.UseStmt{Type: typ, Name: p.useName(), Alias: p.useAlias()})
+		}
+	}
+	p.semicolon()
+
+	for _, stmt := range stmts {
+		p.names.addUse(stmt)
+	}
+	return blockOf(stmts)
+}
+
+func (p *parser) constStmt() ast.Stmt {
+	p.expect(token.CONST)
+	var stmts []*ast.ConstStmt
+	for {
+		name := p.ident()
+		p.expect('=')
+		stmts = append(stmts, &ast.ConstStmt{
+			Name:           name,
+			Value:          p.expr(),
+			NamespacedName: p.names.namespacedName(name.Name),
+		})
+		if !p.accept(',') {
+			break
+		}
+	}
+	p.semicolon()
+	return blockOf(stmts)
+}
+
+/* function & class */
+
+func (p *parser) functionStmt() *ast.FunctionStmt {
+	p.expect(token.FUNCTION)
+	stmt := &ast.FunctionStmt{}
+	stmt.ByRef = p.accept('&')
+	stmt.Name = p.ident()
+	stmt.NamespacedName = p.names.namespacedName(stmt.Name.Name)
+	stmt.Params = p.params()
+	stmt.ReturnType = p.returnType()
+	stmt.Stmts = p.block()
+	return stmt
+}
+
+func (p *parser) params() []*ast.Param {
+	p.expect('(')
+	var params []*ast.Param
+	for !p.at(')') {
+		switch p.tok() {
+		case token.ATTRIBUTE:
+			p.unsupported("unsupported high version php feature: php8.0 attribute")
+		case token.PUBLIC, token.PROTECTED, token.PRIVATE, token.READONLY:
+			p.unsupported("unsupported high version php feature: php8.0 constructor promotion")
+		}
+
+		start := p.startPos()
+		param := &ast.Param{}
+		if !p.at('&', token.ELLIPSIS, token.VARIABLE) {
+			param.Type = p.typeHint()
+		}
+		param.ByRef = p.accept('&')
+		param.Variadic = p.accept(token.ELLIPSIS)
+		param.Var = p.variable()
+		if p.accept('=') {
+			param.Default = p.expr()
+		}
+		p.finish(param, start)
+		params = append(params, param)
+		if !p.accept(',') {
+			break
+		}
+	}
+	p.expect(')')
+	return params
+}
+
+func (p *parser) returnType() ast.TypeHint {
+	if p.accept(':') {
+		return p.typeHint()
+	}
+	return nil
+}
+
+// 内置类型名，不参与名称解析
+var builtinTypes = map[string]bool{
+	"bool": true, "int": true, "float": true, "string": true, "iterable": true, "void": true,
+	"object": true, "null": true, "false": true, "true": true, "mixed": true, "never": true,
+}
+
+func (p *parser) typeHint() ast.TypeHint {
+	start := p.startPos()
+	var typ ast.TypeHint
+	if p.accept('?') {
+		typ = &ast.NullableType{Type: p.simpleType()}
+	} else {
+		typ = p.unionTypeItem()
+		if p.at('|') {
+			union := &ast.UnionType{Types: []ast.TypeHint{typ}}
+			for p.accept('|') {
+				union.Types = append(union.Types, p.unionTypeItem())
+			}
+			typ = union
+		}
+	}
+	p.finish(typ, start)
+	return typ
+}
+
+func (p *parser) unionTypeItem() ast.TypeHint {
+	if p.accept('(') {
+		typ := p.intersectionType(p.simpleType())
+		p.expect(')')
+		return typ
+	}
+	return p.intersectionType(p.simpleType())
+}
+
+func (p *parser) intersectionType(first *ast.SimpleType) ast.TypeHint {
+	if !p.atIntersectionAmpersand() {
+		return first
+	}
+	typ := &ast.IntersectionType{Types: []ast.TypeHint{first}}
+	for p.atIntersectionAmpersand() {
+		p.next()
+		typ.Types = append(typ.Types, p.simpleType())
+	}
+	return typ
+}
+
+// atIntersectionAmpersand 判断 `&` 是交集类型还是参数引用标记
+func (p *parser) atIntersectionAmpersand() bool {
+	return p.at('&') && p.peek(1) != token.VARIABLE && p.peek(1) != token.ELLIPSIS && p.peek(1) != '&'
+}
+
+func (p *parser) simpleType() *ast.SimpleType {
+	start := p.startPos()
+	var name *ast.Name
+	switch p.tok() {
+	case token.ARRAY, token.CALLABLE, token.STATIC:
+		name = ast.NewName(strings.ToLower(p.next().value))
+		p.finish(name, start)
+	default:
+		name = p.name()
+		if lcName := strings.ToLower(name.Parts[0]); name.IsUnqualified() && builtinTypes[lcName] {
+			builtin := ast.NewName(lcName)
+			copyPos(builtin, name)
+			name = builtin
+		} else {
+			name = p.resolveClassName(name)
+		}
+	}
+	typ := &ast.SimpleType{Name: name}
+	p.finish(typ, start)
+	return typ
+}
+
+func (p *parser) classModifiers() ast.Flags {
+	var flags ast.Flags
+	for {
+		switch p.tok() {
+		case token.ABSTRACT:
+			flags |= ast.FlagAbstract
+		case token.FINAL:
+			flags |= ast.FlagFinal
+		case token.READONLY:
+			flags |= ast.FlagReadonly
+		default:
+			return flags
+		}
+		p.next()
+	}
+}
+
+func (p *parser) classStmt() *ast.ClassStmt {
+	flags := p.classModifiers()
+	p.expect(token.CLASS)
+	stmt := &ast.ClassStmt{Flags: flags}
+	stmt.Name = p.ident()
+	stmt.NamespacedName = p.names.namespacedName(stmt.Name.Name)
+	p.classExtendsAndBody(stmt)
+	return stmt
+}
+
+func (p *parser) classExtendsAndBody(stmt *ast.ClassStmt) {
+	if p.accept(token.EXTENDS) {
+		stmt.Extends = p.className()
+	}
+	if p.accept(token.IMPLEMENTS) {
+		stmt.Implements = p.classNameList()
+	}
+	stmt.Stmts = p.classBody()
+}
+
+func (p *parser) interfaceStmt() *ast.InterfaceStmt {
+	p.expect(token.INTERFACE)
+	stmt := &ast.InterfaceStmt{}
+	stmt.Name = p.ident()
+	stmt.NamespacedName = p.names.namespacedName(stmt.Name.Name)
+	if p.accept(token.EXTENDS) {
+		stmt.Extends = p.classNameList()
+	}
+	stmt.Stmts = p.classBody()
+	return stmt
+}
+
+func (p *parser) traitStmt() *ast.TraitStmt {
+	p.expect(token.TRAIT)
+	stmt := &ast.TraitStmt{}
+	stmt.Name = p.ident()
+	stmt.NamespacedName = p.names.namespacedName(stmt.Name.Name)
+	stmt.Stmts = p.classBody()
+	return stmt
+}
+
+func (p *parser) classBody() []ast.Stmt {
+	p.expect('{')
+	var stmts []ast.Stmt
+	for !p.at('}', token.EOF) {
+		comments := p.takeComments()
+		start := p.startPos()
+		stmt := p.guard(p.classMember)
+		if stmt == nil {
+			continue
+		}
+		p.finish(stmt, start)
+		if block, ok := stmt.(*ast.BlockStmt); ok {
+			setComments(block.List[0], comments)
+		} else {
+			setComments(stmt, comments)
+		}
+		stmts = appendStmt(stmts, stmt)
+	}
+	stmts = p.appendTrailingComments(stmts)
+	p.expect('}')
+	return stmts
+}
+
+func (p *parser) memberModifiers() (flags ast.Flags, ok bool) {
+	for {
+		switch p.tok() {
+		case token.PUBLIC:
+			flags |= ast.FlagPublic
+		case token.PROTECTED:
+			flags |= ast.FlagProtected
+		case token.PRIVATE:
+			flags |= ast.FlagPrivate
+		case token.STATIC:
+			flags |= ast.FlagStatic
+		case token.ABSTRACT:
+			flags |= ast.FlagAbstract
+		case token.FINAL:
+			flags |= ast.FlagFinal
+		case token.READONLY:
+			flags |= ast.FlagReadonly
+		case token.VAR:
+		default:
+			return
+		}
+		ok = true
+		p.next()
+	}
+}
+
+func (p *parser) classMember() ast.Stmt {
+	switch p.tok() {
+	case token.USE:
+		return p.traitUseStmt()
+	case token.ATTRIBUTE:
+		p.unsupported("unsupported high version php feature: php8.0 attribute")
+	case token.CASE:
+		p.unsupported("unsupported high version php feature: php8.1 enum")
+	}
+
+	flags, hasModifiers := p.memberModifiers()
+	switch p.tok() {
+	case token.CONST:
+		p.next()
+		var stmts []*ast.ClassConstStmt
+		for {
+			name := p.identifierMaybeReserved()
+			p.expect('=')
+			stmts = append(stmts, &ast.ClassConstStmt{Flags: flags, Name: name, Value: p.expr()})
+			if !p.accept(',') {
+				break
+			}
+		}
+		p.semicolon()
+		return blockOf(stmts)
+	case token.FUNCTION:
+		p.next()
+		stmt := &ast.ClassMethodStmt{Flags: flags}
+		stmt.ByRef = p.accept('&')
+		stmt.Name = p.identifierMaybeReserved()
+		stmt.Params = p.params()
+		stmt.ReturnType = p.returnType()
+		if !p.accept(';') {
+			stmt.Stmts = p.block()
+		}
+		return stmt
+	}
+
+	if !hasModifiers {
+		p.unexpected(token.FUNCTION)
+	}
+	var typ ast.TypeHint
+	if !p.at(token.VARIABLE) {
+		typ = p.typeHint()
+	}
+	var stmts []*ast.PropertyStmt
+	for {
+		stmt := &ast.PropertyStmt{Flags: flags, Type: typ}
+		stmt.Name = &ast.Ident{Name: p.expect(token.VARIABLE).value[1:], VarLike: true}
+		if p.accept('=') {
+			stmt.Default = p.expr()
+		}
+		stmts = append(stmts, stmt)
+		if !p.accept(',') {
+			break
+		}
+	}
+	p.semicolon()
+	return blockOf(stmts)
+}
+
+func (p *parser) traitUseStmt() *ast.TraitUseStmt {
+	p.expect(token.USE)
+	stmt := &ast.TraitUseStmt{Traits: p.classNameList()}
+	if p.accept(';') {
+		return stmt
+	}
+
+	p.expect('{')
+	for !p.accept('}') {
+		var trait *ast.Name
+		if p.atName() && p.peek(1) == token.PAAMAYIM_NEKUDOTAYIM {
+			trait = p.className()
+			p.next()
+		}
+		method := p.identifierMaybeReserved()
+
+		if p.accept(token.INSTEADOF) {
+			stmt.Adaptations = append(stmt.Adaptations, &ast.TraitUseAdaptationPrecedenceStmt{
+				Insteadof: p.classNameList(),
+				Trait:     trait,
+				Method:    method,
+			})
+		} else {
+			p.expect(token.AS)
+			alias := &ast.TraitUseAdaptationAliasStmt{Trait: trait, Method: method}
+			switch p.tok() {
+			case token.PUBLIC:
+				alias.NewModifier = ast.FlagPublic
+			case token.PROTECTED:
+				alias.NewModifier = ast.FlagProtected
+			case token.PRIVATE:
+				alias.NewModifier = ast.FlagPrivate
+			}
+			if alias.NewModifier != 0 {
+				p.next()
+			}
+			if !p.at(';') {
+				alias.NewName = p.identifierMaybeReserved()
+			}
+			stmt.Adaptations = append(stmt.Adaptations, alias)
+		}
+		p.expect(';')
+	}
+	return stmt
+}
@@ -1,54 +1,439 @@
 package phpparse
 
 import (
-	"encoding/json"
 	"fmt"
 	"github.com/heyuuu/gophp/compile/ast"
-	"log"
-	"os"
-	"os/exec"
+	"github.com/heyuuu/gophp/compile/diag"
+	"github.com/heyuuu/gophp/compile/token"
+	"slices"
+	"strings"
 )
 
 func ParseCode(code string) (*ast.File, error) {
-	_, astFile, err := ParseCodeVerbose(code)
-	return astFile, err
+	file, diags := ParseCodeWithDiagnostics(code)
+	if err := diags.Err(); err != nil {
+		return nil, fmt.Errorf("php parse error: %w", err)
+	}
+	return file, nil
 }
 
+// ParseCodeWithDiagnostics 解析代码并返回诊断列表。语法错误会跳过出错的语句继续解析，此时返回部分 ast；词法错误时 ast 为 nil
+func ParseCodeWithDiagnostics(code string) (*ast.File, diag.List) {
+	tokens, err := tokenize(code)
+	if err != nil {
+		lexErr := err.(*lexError)
+		pos := offsetPosition(code, lexErr.pos, lexErr.line)
+		var diags diag.List
+		diags.Errorf(diag.CodeLexError, pos, "%s", lexErr.msg)
+		return nil, diags
+	}
+	p := newParser(tokens)
+	file := p.parseFile()
+	return file, p.diags
+}
+
+// ParseCodeVerbose 同时使用内置解析器和外部解析脚本解析代码，用于比对两者结果
 func ParseCodeVerbose(code string) (raw string, astFile *ast.File, err error) {
-	raw, err = parseCodeRaw(code)
-	if err == nil {
-		astFile, err = decodeAstData(raw)
+	raw, _, err = ParseCodeExternal(code)
+	if err != nil {
+		return
 	}
+	astFile, err = ParseCode(code)
 	return
 }
 
-func parseCodeRaw(code string) (string, error) {
-	output, err := runParser("parse", "-c", code)
-	if err != nil {
-		return "", fmt.Errorf("php parse run failed: %w", err)
+// parseError 语法错误，在语句级别被捕获并转为诊断信息
+type parseError struct {
+	code diag.Code
+	msg  string
+	pos  ast.Position
+}
+
+func (e *parseError) Error() string {
+	return fmt.Sprintf("%s on line %d", e.msg, e.pos.Line)
+}
+
+// offsetPosition 计算偏移处的位置
+func offsetPosition(src string, offset int, line int) ast.Position {
+	offset = min(offset, len(src))
+	return ast.Position{Offset: offset, Line: line, Column: offset - strings.LastIndexByte(src[:offset], '\n')}
+}
+
+// item 语法分析使用的 token，附带其前面的注释及起止位置
+type item struct {
+	tokenItem
+	comments []*ast.Comment
+	start    ast.Position
+	end      ast.Position
+}
+
+type parser struct {
+	items []item
+	pos   int
+	names nameContext
+	diags diag.List
+
+	// 已出现的带花括号、不带花括号的命名空间声明，两种声明不能混用
+	bracketedNamespace   bool
+	unbracketedNamespace bool
+}
+
+func newParser(tokens []tokenItem) *parser {
+	p := &parser{}
+	var comments []*ast.Comment
+	var lineStart int // 当前行的起始偏移
+	end := ast.Position{Offset: 0, Line: 1, Column: 1}
+	for _, t := range tokens {
+		start := ast.Position{Offset: t.pos, Line: t.line, Column: t.pos - lineStart + 1}
+		if i := strings.LastIndexByte(t.value, '\n'); i >= 0 {
+			lineStart = t.pos + i + 1
+		}
+		end = ast.Position{
+			Offset: t.pos + len(t.value),
+			Line:   t.line + strings.Count(t.value, "\n"),
+			Column: t.pos + len(t.value) - lineStart + 1,
+		}
+
+		switch t.tok {
+		case token.WHITESPACE, token.OPEN_TAG:
+			continue
+		case token.COMMENT:
+			comments = append(comments, &ast.Comment{Type: ast.CommentLine, Text: t.value})
+			continue
+		case token.DOC_COMMENT:
+			comments = append(comments, &ast.Comment{Type: ast.CommentDoc, Text: t.value})
+			continue
+		}
+		p.items = append(p.items, item{tokenItem: t, comments: comments, start: start, end: end})
+		comments = nil
+	}
+	p.items = append(p.items, item{
+		tokenItem: tokenItem{tok: token.EOF, pos: end.Offset, line: end.Line},
+		comments:  comments,
+		start:     end,
+		end:       end,
+	})
+	p.names.startNamespace(nil)
+	return p
+}
+
+func (p *parser) parseFile() *ast.File {
+	stmts := p.checkGlobalCode(p.topStmtList())
+	file, _ := buildAstFile(stmts)
+	fillPositions(file)
+	return file
+}
+
+// checkGlobalCode 全局代码与命名空间代码不能混用，出现时报错并丢弃全局代码
+func (p *parser) checkGlobalCode(stmts []ast.Stmt) []ast.Stmt {
+	hasNamespace := slices.ContainsFunc(stmts, func(stmt ast.Stmt) bool {
+		_, ok := stmt.(*ast.NamespaceStmt)
+		return ok
+	})
+	if !hasNamespace {
+		return stmts
+	}
+
+	var result []ast.Stmt
+	reported := false
+	for _, stmt := range stmts {
+		switch stmt.(type) {
+		case *ast.NamespaceStmt, *ast.DeclareStmt, *ast.HaltCompilerStmt:
+			result = append(result, stmt)
+		case *ast.EmptyStmt:
+			// 仅包含注释的空语句
+		default:
+			if !reported {
+				reported = true
+				p.diags.Errorf(diag.CodeCompileError, ast.MetaStart(stmt), "Global code should be enclosed in global namespace declaration")
+			}
+		}
 	}
+	return result
+}
 
-	var res result
-	if err = json.Unmarshal(output, &res); err != nil {
-		return "", fmt.Errorf("php parse json Unmarshal failed: %s", res.Error)
+// checkNamespaceMix 带花括号与不带花括号的命名空间声明混用时报错
+func (p *parser) checkNamespaceMix(pos ast.Position) {
+	if p.bracketedNamespace && p.unbracketedNamespace {
+		p.diags.Errorf(diag.CodeCompileError, pos, "Cannot mix bracketed namespace declarations with unbracketed namespace declarations")
 	}
+}
 
-	if !res.Ok {
-		return "", fmt.Errorf("php parse error: %s", res.Error)
+// guard 在语句级别捕获语法错误：记录诊断并跳过出错的语句，使后续代码可以继续解析。出错时返回 nil
+func (p *parser) guard(fn func() ast.Stmt) (stmt ast.Stmt) {
+	start := p.pos
+	defer func() {
+		if e := recover(); e != nil {
+			err, ok := e.(*parseError)
+			if !ok {
+				panic(e)
+			}
+			p.diags.Add(diag.SeverityError, err.code, err.msg, err.pos, p.items[p.pos].end)
+			p.synchronize(start)
+			stmt = nil
+		}
+	}()
+	return fn()
+}
+
+// synchronize 跳过出错语句的剩余 token：到 `;` 或与之匹配的 `}` 为止，不会越过外层语句块的 `}`
+func (p *parser) synchronize(start int) {
+	depth := 0
+	for !p.at(token.EOF) {
+		switch p.tok() {
+		case ';':
+			if depth == 0 {
+				p.next()
+				return
+			}
+		case '{', token.CURLY_OPEN, token.DOLLAR_OPEN_CURLY_BRACES:
+			depth++
+		case '}':
+			if depth == 0 {
+				if p.pos == start {
+					// 保证解析可以前进
+					p.next()
+				}
+				return
+			}
+			depth--
+			if depth == 0 {
+				p.next()
+				return
+			}
+		}
+		p.next()
 	}
+}
+
+/* token helpers */
 
-	return res.Data, nil
+func (p *parser) tok() token.Token {
+	return p.items[p.pos].tok
 }
 
-/* Parser 脚本相关 */
-var parser = "/usr/local/bin/gophp-parser"
+func (p *parser) peek(n int) token.Token {
+	if i := p.pos + n; i < len(p.items) {
+		return p.items[i].tok
+	}
+	return token.EOF
+}
+
+func (p *parser) at(toks ...token.Token) bool {
+	return slices.Contains(toks, p.tok())
+}
 
-func runParser(args ...string) ([]byte, error) {
-	if _, err := os.Stat(parser); err != nil {
-		log.Panicln("PHP Parser 脚本路径不存在或不可读")
+func (p *parser) next() tokenItem {
+	t := p.items[p.pos].tokenItem
+	if p.pos < len(p.items)-1 {
+		p.pos++
 	}
+	return t
+}
 
-	command := exec.Command(parser, args...)
-	log.Printf("Run command: %s\n", command.String())
-	return command.CombinedOutput()
+func (p *parser) accept(tok token.Token) bool {
+	if p.tok() == tok {
+		p.next()
+		return true
+	}
+	return false
+}
+
+func (p *parser) expect(tok token.Token) tokenItem {
+	if p.tok() != tok {
+		p.unexpected(tok)
+	}
+	return p.next()
+}
+
+// takeComments 获取当前 token 前的注释，每段注释只会被获取一次
+func (p *parser) takeComments() []*ast.Comment {
+	comments := p.items[p.pos].comments
+	p.items[p.pos].comments = nil
+	return comments
+}
+
+func (p *parser) errorf(format string, args ...any) {
+	panic(&parseError{code: diag.CodeSyntaxError, msg: fmt.Sprintf(format, args...), pos: p.startPos()})
+}
+
+func (p *parser) unexpected(expected ...token.Token) {
+	curr := p.items[p.pos]
+	var desc string
+	switch {
+	case curr.tok == token.EOF:
+		desc = "EOF"
+	case curr.tok < 256:
+		desc = curr.tok.String()
+	default:
+		desc = fmt.Sprintf("'%s' (%s)", curr.value, curr.tok)
+	}
+	msg := "Syntax error, unexpected " + desc
+	if len(expected) > 0 {
+		var names []string
+		for _, t := range expected {
+			names = append(names, t.String())
+		}
+		msg += ", expecting " + strings.Join(names, " or ")
+	}
+	p.errorf("%s", msg)
+}
+
+func (p *parser) unsupported(message string) {
+	panic(&parseError{code: diag.CodeUnsupported, msg: message, pos: p.startPos()})
+}
+
+/* position helpers */
+
+// startPos 当前 token 的起始位置
+func (p *parser) startPos() ast.Position {
+	return p.items[p.pos].start
+}
+
+// endPos 上一个已读取 token 的结束位置
+func (p *parser) endPos() ast.Position {
+	if p.pos == 0 {
+		return p.items[0].start
+	}
+	return p.items[p.pos-1].end
+}
+
+// finish 记录节点从 start 到上一个已读取 token 的位置。已有位置的节点保持不变，如括号内的表达式
+func (p *parser) finish(n ast.Node, start ast.Position) {
+	if n == nil {
+		return
+	}
+	meta := nodeMeta(n)
+	if meta.Start.IsValid() {
+		return
+	}
+	meta.Start = start
+	meta.End = p.endPos()
+}
+
+// copyPos 复制节点位置，用于名称解析等生成新节点的场景
+func copyPos(dst ast.Node, src ast.Node) {
+	if dst == src || !ast.MetaStart(src).IsValid() {
+		return
+	}
+	meta := nodeMeta(dst)
+	meta.Start = ast.MetaStart(src)
+	meta.End = ast.MetaEnd(src)
+}
+
+/* node helpers */
+
+// nodeMeta 获取节点元数据，不存在时创建
+func nodeMeta(n ast.Node) *ast.NodeMeta {
+	meta := n.Meta()
+	if meta == nil {
+		meta = ast.NewNodeMeta()
+		n.SetMeta(meta)
+	}
+	return meta
+}
+
+// setComments 为节点附加注释
+func setComments(n ast.Node, comments []*ast.Comment) {
+	if len(comments) == 0 {
+		return
+	}
+	meta := nodeMeta(n)
+	meta.Comments = append(comments, meta.Comments...)
+}
+
+func setRawValue(n ast.Node, raw string) {
+	nodeMeta(n).RawValue = raw
+}
+
+// appendStmt 追加语句，BlockStmt 会被展开
+func appendStmt(stmts []ast.Stmt, stmt ast.Stmt) []ast.Stmt {
+	switch s := stmt.(type) {
+	case nil:
+		return stmts
+	case *ast.BlockStmt:
+		return append(stmts, s.List...)
+	default:
+		return append(stmts, stmt)
+	}
+}
+
+func blockOf[T ast.Stmt](stmts []T) ast.Stmt {
+	block := &ast.BlockStmt{}
+	for _, stmt := range stmts {
+		block.List = append(block.List, stmt)
+	}
+	return block
+}
+
+func (p *parser) ident() *ast.Ident {
+	start := p.startPos()
+	ident := &ast.Ident{Name: p.expect(token.STRING).value}
+	p.finish(ident, start)
+	return ident
+}
+
+// identifierMaybeReserved 可为保留字的标识符，如方法名、类常量名等
+func (p *parser) identifierMaybeReserved() *ast.Ident {
+	if p.tok() != token.STRING && !p.tok().IsKeyword() {
+		p.unexpected(token.STRING)
+	}
+	start := p.startPos()
+	ident := &ast.Ident{Name: p.next().value}
+	p.finish(ident, start)
+	return ident
+}
+
+func (p *parser) atName() bool {
+	return p.at(token.STRING, token.NAME_QUALIFIED, token.NAME_FULLY_QUALIFIED, token.NAME_RELATIVE)
+}
+
+// name 解析名称 token，不做名称解析
+func (p *parser) name() *ast.Name {
+	if !p.atName() {
+		p.unexpected(token.STRING)
+	}
+	start := p.startPos()
+	t := p.next()
+	var name *ast.Name
+	switch t.tok {
+	case token.STRING:
+		name = ast.NewName(t.value)
+	case token.NAME_QUALIFIED:
+		name = ast.NewName(strings.Split(t.value, "\\")...)
+	case token.NAME_FULLY_QUALIFIED:
+		name = &ast.Name{Kind: ast.NameFullyQualified, Parts: strings.Split(t.value[1:], "\\")}
+	default: // token.NAME_RELATIVE
+		name = &ast.Name{Kind: ast.NameRelative, Parts: strings.Split(t.value, "\\")[1:]}
+	}
+	p.finish(name, start)
+	return name
+}
+
+func (p *parser) className() *ast.Name {
+	return p.resolveClassName(p.name())
+}
+
+// resolveClassName 解析类名，解析结果保留原名称的位置
+func (p *parser) resolveClassName(name *ast.Name) *ast.Name {
+	resolved := p.names.resolveClassName(name)
+	copyPos(resolved, name)
+	return resolved
+}
+
+// resolveOtherName 解析函数名或常量名，解析结果保留原名称的位置
+func (p *parser) resolveOtherName(name *ast.Name, typ ast.UseType) *ast.Name {
+	resolved := p.names.resolveOtherName(name, typ)
+	copyPos(resolved, name)
+	return resolved
+}
+
+func (p *parser) classNameList() []*ast.Name {
+	var names []*ast.Name
+	for {
+		names = append(names, p.className())
+		if !p.accept(',') {
+			return names
+		}
+	}
 }
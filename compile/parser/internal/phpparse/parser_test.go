@@ -0,0 +1,367 @@
+package phpparse
+
+import (
+	"fmt"
+	"github.com/heyuuu/gophp/compile/ast"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// dumpExpr 以带括号的形式输出表达式结构，便于检查优先级及结合性
+func dumpExpr(n ast.Node) string {
+	switch x := n.(type) {
+	case nil:
+		return "nil"
+	case *ast.IntLit:
+		return fmt.Sprint(x.Value)
+	case *ast.FloatLit:
+		return fmt.Sprint(x.Value)
+	case *ast.StringLit:
+		return fmt.Sprintf("%q", x.Value)
+	case *ast.Ident:
+		return x.Name
+	case *ast.Name:
+		return x.ToCodeString()
+	case *ast.VariableExpr:
+		return "$" + dumpExpr(x.Name)
+	case *ast.ConstFetchExpr:
+		return dumpExpr(x.Name)
+	case *ast.UnaryExpr:
+		return fmt.Sprintf("(%s %s)", x.Op, dumpExpr(x.Var))
+	case *ast.BinaryOpExpr:
+		return fmt.Sprintf("(%s %s %s)", dumpExpr(x.Left), x.Op, dumpExpr(x.Right))
+	case *ast.AssignExpr:
+		return fmt.Sprintf("(%s = %s)", dumpExpr(x.Var), dumpExpr(x.Expr))
+	case *ast.AssignRefExpr:
+		return fmt.Sprintf("(%s =& %s)", dumpExpr(x.Var), dumpExpr(x.Expr))
+	case *ast.AssignOpExpr:
+		return fmt.Sprintf("(%s %s %s)", dumpExpr(x.Var), x.Op, dumpExpr(x.Expr))
+	case *ast.TernaryExpr:
+		return fmt.Sprintf("(%s ? %s : %s)", dumpExpr(x.Cond), dumpExpr(x.If), dumpExpr(x.Else))
+	case *ast.InstanceofExpr:
+		return fmt.Sprintf("(%s instanceof %s)", dumpExpr(x.Expr), dumpExpr(x.Class))
+	case *ast.CastExpr:
+		return fmt.Sprintf("(%s %s)", x.Kind, dumpExpr(x.Expr))
+	case *ast.PrintExpr:
+		return fmt.Sprintf("(print %s)", dumpExpr(x.Expr))
+	case *ast.IndexExpr:
+		return fmt.Sprintf("%s[%s]", dumpExpr(x.Var), dumpExpr(x.Dim))
+	case *ast.PropertyFetchExpr:
+		return fmt.Sprintf("%s->%s", dumpExpr(x.Var), dumpExpr(x.Name))
+	case *ast.StaticPropertyFetchExpr:
+		return fmt.Sprintf("%s::$%s", dumpExpr(x.Class), dumpExpr(x.Name))
+	case *ast.ClassConstFetchExpr:
+		return fmt.Sprintf("%s::%s", dumpExpr(x.Class), dumpExpr(x.Name))
+	case *ast.FuncCallExpr:
+		return fmt.Sprintf("%s(%s)", dumpExpr(x.Name), dumpArgs(x.Args))
+	case *ast.MethodCallExpr:
+		return fmt.Sprintf("%s->%s(%s)", dumpExpr(x.Var), dumpExpr(x.Name), dumpArgs(x.Args))
+	case *ast.StaticCallExpr:
+		return fmt.Sprintf("%s::%s(%s)", dumpExpr(x.Class), dumpExpr(x.Name), dumpArgs(x.Args))
+	case *ast.NewExpr:
+		return fmt.Sprintf("new %s(%s)", dumpExpr(x.Class), dumpArgs(x.Args))
+	case *ast.ArrayExpr:
+		return "[" + dumpItems(x.Items) + "]"
+	case *ast.ListExpr:
+		return "list(" + dumpItems(x.Items) + ")"
+	case *ast.ThrowExpr:
+		return fmt.Sprintf("(throw %s)", dumpExpr(x.Expr))
+	case *ast.MatchExpr:
+		var arms []string
+		for _, arm := range x.Arms {
+			cond := "default"
+			if arm.Conds != nil {
+				var conds []string
+				for _, c := range arm.Conds {
+					conds = append(conds, dumpExpr(c))
+				}
+				cond = strings.Join(conds, ", ")
+			}
+			arms = append(arms, cond+" => "+dumpExpr(arm.Body))
+		}
+		return fmt.Sprintf("match(%s){%s}", dumpExpr(x.Cond), strings.Join(arms, "; "))
+	default:
+		return fmt.Sprintf("%T", n)
+	}
+}
+
+func dumpArgs(args []*ast.Arg) string {
+	var items []string
+	for _, arg := range args {
+		s := dumpExpr(arg.Value)
+		if arg.Unpack {
+			s = "..." + s
+		}
+		items = append(items, s)
+	}
+	return strings.Join(items, ", ")
+}
+
+func dumpItems(items []*ast.ArrayItemExpr) string {
+	var strs []string
+	for _, item := range items {
+		if item == nil {
+			strs = append(strs, "")
+			continue
+		}
+		s := dumpExpr(item.Value)
+		if item.ByRef {
+			s = "&" + s
+		}
+		if item.Unpack {
+			s = "..." + s
+		}
+		if item.Key != nil {
+			s = dumpExpr(item.Key) + " => " + s
+		}
+		strs = append(strs, s)
+	}
+	return strings.Join(strs, ", ")
+}
+
+func TestParseExpr(t *testing.T) {
+	tests := []struct {
+		code string
+		want string
+	}{
+		{`1 + 2 * 3`, `(1 + (2 * 3))`},
+		{`1 - 2 - 3`, `((1 - 2) - 3)`},
+		{`-2 ** 2`, `(- (2 ** 2))`},
+		{`2 ** 3 ** 2`, `(2 ** (3 ** 2))`},
+		{`$a . $b + $c`, `($a . ($b + $c))`},
+		{`$a << 1 . $b`, `(($a << 1) . $b)`},
+		{`!$a instanceof B`, `(! ($a instanceof \B))`},
+		{`!$a = 1`, `(! ($a = 1))`},
+		{`$a = $b and $c`, `(($a = $b) && $c)`},
+		{`$a ?? $b ?? $c`, `($a ?? ($b ?? $c))`},
+		{`$a ? $b : $c ? $d : $e`, `(($a ? $b : $c) ? $d : $e)`},
+		{`$a ?: $b`, `($a ? nil : $b)`},
+		{`$a = $b = 1`, `($a = ($b = 1))`},
+		{`$a =& $b`, `($a =& $b)`},
+		{`$a .= 'x' . 'y'`, `($a .= ("x" . "y"))`},
+		{`(int) $a + 1`, `(((int) $a) + 1)`},
+		{`print $a and $b`, `((print $a) && $b)`},
+		{`$a->b()->c[0]`, `$a->b()->c[0]`},
+		{`A::$b[0]`, `\A::$b[0]`},
+		{`static::C`, `static::C`},
+		{`new $cls->name`, `new $cls->name()`},
+		{`[$a, [$b, , $c]] = $d`, `(list($a, list($b, , $c)) = $d)`},
+		{`['k' => &$v, ...$rest]`, `["k" => &$v, ...$rest]`},
+		{`"a{$b}c$d[0]"`, `((("a" . $b) . "c") . $d[0])`},
+		{`'it\'s' . "\x41\u{263A}\n"`, `("it's" . "A☺\n")`},
+		{`0x1F + 0b11 + 017 + 1_000`, `(((31 + 3) + 15) + 1000)`},
+		{`9223372036854775808`, `9.223372036854776e+18`},
+		{`$a = match ($b) { 1, 2, => 'x', default => throw new E, }`, `($a = match($b){1, 2 => "x"; default => (throw new \E())})`},
+		{`match (true) {} . $c`, `(match(\true){} . $c)`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.code, func(t *testing.T) {
+			file, err := ParseCode("<?php " + tt.code + ";")
+			if err != nil {
+				t.Fatalf("ParseCode() error = %v", err)
+			}
+			stmt := file.Namespaces[0].Stmts[0].(*ast.ExprStmt)
+			if got := dumpExpr(stmt.Expr); got != tt.want {
+				t.Errorf("ParseCode() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseNames(t *testing.T) {
+	code := `<?php
+namespace Foo;
+use A\B as C;
+use function F\g;
+new C\D;
+g();
+h();
+\i();
+namespace\j();
+`
+	file, err := ParseCode(code)
+	if err != nil {
+		t.Fatalf("ParseCode() error = %v", err)
+	}
+	stmts := file.Namespaces[0].Stmts
+	want := []string{`new \A\B\D()`, `\F\g()`, `h()`, `\i()`, `\Foo\j()`}
+	for i, w := range want {
+		if got := dumpExpr(stmts[i+2].(*ast.ExprStmt).Expr); got != w {
+			t.Errorf("stmt %d = %v, want %v", i, got, w)
+		}
+	}
+	call := stmts[4].(*ast.ExprStmt).Expr.(*ast.FuncCallExpr)
+	if nsName := call.Name.Meta().Others["namespacedName"].(*ast.Name); nsName.ToCodeString() != `\Foo\h` {
+		t.Errorf("namespacedName = %v, want \\Foo\\h", nsName.ToCodeString())
+	}
+}
+
+func TestParseCodeError(t *testing.T) {
+	tests := []struct {
+		code string
+		want string
+	}{
+		{"<?php\n$a = ;", "Syntax error, unexpected ';' on line 2"},
+		{"<?php\nif ($a) {\n", "Syntax error, unexpected EOF, expecting '}' on line 3"},
+		{"<?php\nmatch($a) { => 1 };", "Syntax error, unexpected '=>' (T_DOUBLE_ARROW) on line 2"},
+		{"<?php\n#[A] function f() {}", "unsupported high version php feature: php8.0 attribute"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.code, func(t *testing.T) {
+			_, err := ParseCode(tt.code)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("ParseCode() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+// TestParseCodeParity 与外部解析脚本的结果做比对，脚本不存在时跳过
+func TestParseCodeParity(t *testing.T) {
+	if !externalParserExists() {
+		t.Skip("external php parser not found")
+	}
+	files, _ := filepath.Glob("../../../../testdata/compile/gammar/*.php")
+	for _, file := range files {
+		t.Run(filepath.Base(file), func(t *testing.T) {
+			code, err := os.ReadFile(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, want, err := ParseCodeExternal(string(code))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := ParseCode(string(code))
+			if err != nil {
+				t.Fatal(err)
+			}
+			gotStr, _ := ast.PrintFile(got)
+			wantStr, _ := ast.PrintFile(want)
+			if gotStr != wantStr {
+				t.Errorf("ParseCode() = %v, want %v", gotStr, wantStr)
+			}
+		})
+	}
+}
+
+func TestParseTestdata(t *testing.T) {
+	files, _ := filepath.Glob("../../../../testdata/compile/gammar/*.php")
+	if len(files) == 0 {
+		t.Fatal("testdata not found")
+	}
+	for _, file := range files {
+		t.Run(filepath.Base(file), func(t *testing.T) {
+			code, err := os.ReadFile(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err = ParseCode(string(code)); err != nil {
+				t.Errorf("ParseCode() error = %v", err)
+			}
+		})
+	}
+}
+
+func TestParsePositions(t *testing.T) {
+	code := "<?php\nfunction f($a) {\n    return $a->b[0] + (2 * 3);\n}\n"
+	file, err := ParseCode(code)
+	if err != nil {
+		t.Fatalf("ParseCode() error = %v", err)
+	}
+	fn := file.Namespaces[0].Stmts[0].(*ast.FunctionStmt)
+	ret := fn.Stmts[0].(*ast.ReturnStmt)
+	binary := ret.Expr.(*ast.BinaryOpExpr)
+	tests := []struct {
+		name  string
+		node  ast.Node
+		start string
+		end   string
+	}{
+		{"function", fn, "2:1", "4:2"},
+		{"function name", fn.Name, "2:10", "2:11"},
+		{"param", fn.Params[0], "2:12", "2:14"},
+		{"return", ret, "3:5", "3:31"},
+		{"binary", binary, "3:12", "3:30"},
+		{"index", binary.Left, "3:12", "3:20"},
+		{"paren", binary.Right, "3:24", "3:29"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start, end := ast.MetaStart(tt.node), ast.MetaEnd(tt.node)
+			if start.String() != tt.start || end.String() != tt.end {
+				t.Errorf("position = %s-%s, want %s-%s", start, end, tt.start, tt.end)
+			}
+			if got := code[start.Offset:end.Offset]; start.Line == end.Line && len(got) != end.Column-start.Column {
+				t.Errorf("offset mismatch: %q", got)
+			}
+		})
+	}
+}
+
+func TestParseCodeWithDiagnostics(t *testing.T) {
+	tests := []struct {
+		name      string
+		code      string
+		wantStmts int
+		wantDiags []string
+	}{
+		{
+			"skip broken statements",
+			"<?php\n$a = ;\n$b = 1;\nfunction f() {\n    $c = );\n    return 1;\n}\necho 2;",
+			3,
+			[]string{"syntax-error 2:6 Syntax error, unexpected ';'", "syntax-error 5:10 Syntax error, unexpected ')'"},
+		},
+		{
+			"skip broken class member",
+			"<?php\nclass A {\n    public $x = ;\n    const B = 1;\n}\n$y = 1;",
+			2,
+			[]string{"syntax-error 3:17 Syntax error, unexpected ';'"},
+		},
+		{
+			"unexpected close brace",
+			"<?php\n}\necho 1;",
+			1,
+			[]string{"syntax-error 2:1 Syntax error, unexpected '}'"},
+		},
+		{
+			"global code with namespace",
+			"<?php\necho 1;\nnamespace B;\necho 2;",
+			1,
+			[]string{"compile-error 2:1 Global code should be enclosed in global namespace declaration"},
+		},
+		{
+			"mixed namespace declarations",
+			"<?php\nnamespace A;\necho 1;\nnamespace B {\n    echo 2;\n}",
+			1,
+			[]string{"compile-error 4:1 Cannot mix bracketed namespace declarations with unbracketed namespace declarations"},
+		},
+		{
+			"lex error",
+			"<?php\n$a = 'abc",
+			-1,
+			[]string{"lex-error 2:6 Syntax error, unexpected end of file, unterminated string"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file, diags := ParseCodeWithDiagnostics(tt.code)
+			gotStmts := -1
+			if file != nil {
+				gotStmts = len(file.Namespaces[0].Stmts)
+			}
+			if gotStmts != tt.wantStmts {
+				t.Errorf("ParseCodeWithDiagnostics() stmts = %d, want %d", gotStmts, tt.wantStmts)
+			}
+			var gotDiags []string
+			for _, d := range diags {
+				gotDiags = append(gotDiags, fmt.Sprintf("%s %s %s", d.Code, d.Pos, d.Message))
+			}
+			if strings.Join(gotDiags, "\n") != strings.Join(tt.wantDiags, "\n") {
+				t.Errorf("ParseCodeWithDiagnostics() diags = %v, want %v", gotDiags, tt.wantDiags)
+			}
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package phpparse
+
+import (
+	"github.com/heyuuu/gophp/compile/ast"
+	"reflect"
+)
+
+var nodeType = reflect.TypeFor[ast.Node]()
+
+// fillPositions 为解析过程中未记录位置的节点补全位置。
+// 优先使用子节点的位置范围，无子节点位置可用时(如名称解析生成的 NamespacedName)沿用父节点位置
+func fillPositions(file *ast.File) {
+	for _, stmt := range file.Declares {
+		fillFromChildren(stmt)
+		fillFromParent(stmt, nil)
+	}
+	for _, ns := range file.Namespaces {
+		fillFromChildren(ns)
+		fillFromParent(ns, nil)
+	}
+}
+
+func fillFromChildren(n ast.Node) {
+	var start, end ast.Position
+	for _, child := range childNodes(n) {
+		fillFromChildren(child)
+		childStart, childEnd := ast.MetaStart(child), ast.MetaEnd(child)
+		if !childStart.IsValid() {
+			continue
+		}
+		if !start.IsValid() || childStart.Offset < start.Offset {
+			start = childStart
+		}
+		if childEnd.Offset > end.Offset {
+			end = childEnd
+		}
+	}
+	if start.IsValid() && !ast.MetaStart(n).IsValid() {
+		meta := nodeMeta(n)
+		meta.Start, meta.End = start, end
+	}
+}
+
+func fillFromParent(n ast.Node, parent *ast.NodeMeta) {
+	if !ast.MetaStart(n).IsValid() && parent != nil {
+		meta := nodeMeta(n)
+		meta.Start, meta.End = parent.Start, parent.End
+	}
+	for _, child := range childNodes(n) {
+		fillFromParent(child, n.Meta())
+	}
+}
+
+// childNodes 通过反射获取节点的直接子节点
+func childNodes(n ast.Node) []ast.Node {
+	v := reflect.ValueOf(n)
+	if v.Kind() != reflect.Pointer || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return nil
+	}
+
+	var children []ast.Node
+	add := func(f reflect.Value) {
+		if f.Type().Implements(nodeType) && !f.IsNil() {
+			children = append(children, f.Interface().(ast.Node))
+		}
+	}
+	v = v.Elem()
+	for i := 0; i < v.NumField(); i++ {
+		if v.Type().Field(i).Anonymous {
+			continue
+		}
+		f := v.Field(i)
+		switch f.Kind() {
+		case reflect.Pointer, reflect.Interface:
+			add(f)
+		case reflect.Slice:
+			for j := 0; j < f.Len(); j++ {
+				if e := f.Index(j); e.Kind() == reflect.Pointer || e.Kind() == reflect.Interface {
+					add(e)
+				}
+			}
+		}
+	}
+	return children
+}
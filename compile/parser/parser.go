@@ -2,9 +2,13 @@ package parser
 
 import (
 	"github.com/heyuuu/gophp/compile/ast"
+	"github.com/heyuuu/gophp/compile/diag"
 	"github.com/heyuuu/gophp/compile/parser/internal/phpparse"
 )
 
+// Version 解析器版本，解析结果(ast 结构或内容)发生变化时需要更新，用于使 ast 缓存失效
+const Version = "1"
+
 func ParseCode(code string) (*ast.File, error) {
 	return ParseCodeEx(code, false)
 }
@@ -16,6 +20,14 @@ func ParseCodeEx(code string, skipShebang bool) (*ast.File, error) {
 	return phpparse.ParseCode(code)
 }
 
+// ParseCodeWithDiagnostics 解析代码并返回全部诊断信息，存在语法错误时尽可能返回部分 ast
+func ParseCodeWithDiagnostics(code string, skipShebang bool) (*ast.File, diag.List) {
+	if skipShebang {
+		code = "<?php " + code
+	}
+	return phpparse.ParseCodeWithDiagnostics(code)
+}
+
 func ParseCodeVerbose(code string) (string, *ast.File, error) {
 	return phpparse.ParseCodeVerbose(code)
 }
@@ -0,0 +1,424 @@
+package render
+
+import (
+	"github.com/heyuuu/gophp/compile/ast"
+	"github.com/heyuuu/gophp/compile/infer"
+	"github.com/heyuuu/gophp/compile/ir"
+	goast "go/ast"
+	gotoken "go/token"
+	"strconv"
+)
+
+// 类型已证明为 int、float、bool 或 string 的变量渲染为原生 go 局部变量 v_<name>，不写入符号表；
+// 只含原生值的运算直接渲染为 go 运算。原生值传给 def 时通过 d.Int 等装箱，反之通过 d.IntVal 等拆箱
+
+func isNativeType(t infer.Type) bool {
+	switch t {
+	case infer.Int, infer.Float, infer.Bool, infer.String:
+		return true
+	}
+	return false
+}
+
+func goType(t infer.Type) goast.Expr {
+	switch t {
+	case infer.Int:
+		return ident("int")
+	case infer.Float:
+		return ident("float64")
+	case infer.Bool:
+		return ident("bool")
+	default:
+		return ident("string")
+	}
+}
+
+func localName(name string) string {
+	return "v_" + name
+}
+
+// locals 在函数体开头声明原生局部变量，参数从符号表中读取
+func (r *render) locals() []goast.Stmt {
+	var stmts []goast.Stmt
+	for _, name := range r.fn.Vars() {
+		typ := r.fn.Var(name)
+		if !isNativeType(typ) {
+			continue
+		}
+		if r.fn.IsParam(name) {
+			stmts = append(stmts, define(localName(name), r.unbox(typ, r.defCall("Var", stringLit(name)))))
+		} else {
+			spec := &goast.ValueSpec{Names: []*goast.Ident{ident(localName(name))}, Type: goType(typ)}
+			stmts = append(stmts, &goast.DeclStmt{Decl: &goast.GenDecl{Tok: gotoken.VAR, Specs: []goast.Spec{spec}}})
+		}
+	}
+	return stmts
+}
+
+// nativeVar 表达式为原生局部变量时返回变量名及类型
+func (r *render) nativeVar(e ir.Expr) (string, infer.Type, bool) {
+	v, ok := e.(*ir.VariableExpr)
+	if !ok {
+		return "", infer.Unknown, false
+	}
+	ident, ok := v.Name.(*ir.Ident)
+	if !ok {
+		return "", infer.Unknown, false
+	}
+	typ := r.fn.Var(ident.Name)
+	return localName(ident.Name), typ, isNativeType(typ)
+}
+
+// hasNative 表达式内是否读写原生局部变量
+func (r *render) hasNative(e ir.Expr) bool {
+	found := false
+	ir.Inspect(e, func(n ir.Node) bool {
+		switch x := n.(type) {
+		case *ir.ClosureExpr, *ir.ArrowFunctionExpr:
+			return false
+		case ir.Expr:
+			if _, _, ok := r.nativeVar(x); ok {
+				found = true
+			}
+		}
+		return !found
+	})
+	return found
+}
+
+// isNative 表达式是否渲染为原生 go 表达式。不含原生变量的运算仍由 def 计算，避免 go 对常量表达式做精确计算
+func (r *render) isNative(e ir.Expr) bool {
+	if r.fn == nil || !isNativeType(r.fn.Expr(e)) || !r.hasNative(e) {
+		return false
+	}
+	switch x := e.(type) {
+	case *ir.VariableExpr:
+		return true
+	case *ir.BinaryOpExpr:
+		return r.hasNativeBinary(x.Op, r.fn.Expr(x), r.fn.Expr(x.Left), r.fn.Expr(x.Right))
+	case *ir.UnaryExpr:
+		return r.hasNativeUnary(x)
+	case *ir.CastExpr:
+		return r.hasNativeCast(x)
+	case *ir.AssignExpr:
+		_, _, ok := r.nativeVar(x.Var)
+		return ok
+	case *ir.AssignOpExpr:
+		_, _, ok := r.nativeVar(x.Var)
+		return ok
+	}
+	return false
+}
+
+func (r *render) hasNativeBinary(op ast.BinaryOpKind, typ, t1, t2 infer.Type) bool {
+	switch op {
+	case ast.BinaryOpPlus, ast.BinaryOpMinus, ast.BinaryOpMul, ast.BinaryOpDiv, ast.BinaryOpPow:
+		return typ == infer.Float
+	case ast.BinaryOpMod, ast.BinaryOpBitwiseAnd, ast.BinaryOpBitwiseOr, ast.BinaryOpBitwiseXor:
+		return t1 == infer.Int && t2 == infer.Int
+	case ast.BinaryOpConcat:
+		return t1 == infer.String && t2 == infer.String
+	case ast.BinaryOpSmaller, ast.BinaryOpSmallerOrEqual, ast.BinaryOpGreater, ast.BinaryOpGreaterOrEqual:
+		// 字符串按数字字符串规则比较，不能直接比较
+		return isNumeric(t1) && isNumeric(t2)
+	case ast.BinaryOpEqual, ast.BinaryOpNotEqual:
+		return isNumeric(t1) && isNumeric(t2) || t1 == infer.Bool && t2 == infer.Bool
+	case ast.BinaryOpIdentical, ast.BinaryOpNotIdentical:
+		return t1 == t2 && isNativeType(t1)
+	case ast.BinaryOpBooleanAnd, ast.BinaryOpBooleanOr, ast.BinaryOpBooleanXor:
+		return true
+	}
+	return false
+}
+
+func (r *render) hasNativeUnary(x *ir.UnaryExpr) bool {
+	typ := r.fn.Expr(x.Var)
+	switch x.Op {
+	case ast.UnaryOpBooleanNot:
+		return true
+	case ast.UnaryOpPlus:
+		return isNumeric(typ)
+	case ast.UnaryOpMinus:
+		return typ == infer.Float
+	case ast.UnaryOpBitwiseNot:
+		return typ == infer.Int
+	default: // ++/--
+		_, _, ok := r.nativeVar(x.Var)
+		return ok
+	}
+}
+
+func (r *render) hasNativeCast(x *ir.CastExpr) bool {
+	typ := r.fn.Expr(x.Expr)
+	switch x.Kind {
+	case ast.CastDouble:
+		return isNumeric(typ)
+	case ast.CastInt:
+		return typ == infer.Int
+	case ast.CastString:
+		return typ == infer.String
+	case ast.CastBool:
+		return typ == infer.Bool || typ == infer.Int
+	}
+	return false
+}
+
+func isNumeric(t infer.Type) bool {
+	return t == infer.Int || t == infer.Float
+}
+
+// box 将原生值装箱为 def.Val
+func (r *render) box(typ infer.Type, v goast.Expr) goast.Expr {
+	switch typ {
+	case infer.Int:
+		return r.defCall("Int", v)
+	case infer.Float:
+		return r.defCall("Float", v)
+	case infer.Bool:
+		return r.defCall("Bool", v)
+	default:
+		return r.defCall("String", v)
+	}
+}
+
+// unbox 将类型已证明的 def.Val 拆箱为原生值
+func (r *render) unbox(typ infer.Type, v any) goast.Expr {
+	switch typ {
+	case infer.Int:
+		return r.defCall("IntVal", v)
+	case infer.Float:
+		return r.defCall("FloatVal", v)
+	case infer.Bool:
+		return r.defCall("BoolVal", v)
+	default:
+		return r.defCall("StrVal", v)
+	}
+}
+
+// nativeBoxed 在需要 def.Val 的位置渲染原生表达式，返回 false 时由调用方按 def 调用渲染
+func (r *render) nativeBoxed(e ir.Expr) bool {
+	if !r.isNative(e) {
+		return false
+	}
+	typ := r.fn.Expr(e)
+	r.result = r.box(typ, r.native(e, typ))
+	return true
+}
+
+// native 渲染 go 类型为 goType(want) 的原生表达式，e 的类型为 want 或 int(want 为 float 时)
+func (r *render) native(e ir.Expr, want infer.Type) goast.Expr {
+	typ := r.fn.Expr(e)
+	switch x := e.(type) {
+	case *ir.IntLit:
+		if want == infer.Float {
+			return r.float(float64(x.Value))
+		}
+		return intLit(x.Value)
+	case *ir.FloatLit:
+		return r.float(x.Value)
+	case *ir.BoolLit:
+		return ident(strconv.FormatBool(x.Value))
+	case *ir.StringLit:
+		return strLit(x.Value)
+	}
+
+	if typ == infer.Int && want == infer.Float {
+		return call(ident("float64"), r.native(e, infer.Int))
+	}
+	if !r.isNative(e) {
+		return r.unbox(want, e)
+	}
+
+	switch x := e.(type) {
+	case *ir.VariableExpr:
+		name, _, _ := r.nativeVar(x)
+		return ident(name)
+	case *ir.BinaryOpExpr:
+		return r.nativeBinary(x.Op, typ, x.Left, x.Right)
+	case *ir.UnaryExpr:
+		return r.nativeUnary(x, typ)
+	case *ir.CastExpr:
+		return r.nativeCast(x)
+	default: // *ir.AssignExpr, *ir.AssignOpExpr
+		// 赋值表达式的值即赋值后的变量
+		name, varType, _ := r.nativeVar(assignTarget(x))
+		body := &goast.BlockStmt{List: []goast.Stmt{r.nativeAssign(x), returnStmt(ident(name))}}
+		return call(funcLit(nil, goType(varType), body))
+	}
+}
+
+func assignTarget(e ir.Expr) ir.Expr {
+	switch x := e.(type) {
+	case *ir.AssignExpr:
+		return x.Var
+	case *ir.AssignOpExpr:
+		return x.Var
+	case *ir.UnaryExpr:
+		return x.Var
+	}
+	return nil
+}
+
+// cond 渲染 go bool 表达式，用于条件判断
+func (r *render) cond(e ir.Expr) goast.Expr {
+	if r.isNative(e) && r.fn.Expr(e) == infer.Bool {
+		return r.native(e, infer.Bool)
+	}
+	return r.asBool(e)
+}
+
+var nativeBinaryOps = map[ast.BinaryOpKind]gotoken.Token{
+	ast.BinaryOpPlus:           gotoken.ADD,
+	ast.BinaryOpMinus:          gotoken.SUB,
+	ast.BinaryOpMul:            gotoken.MUL,
+	ast.BinaryOpBitwiseAnd:     gotoken.AND,
+	ast.BinaryOpBitwiseOr:      gotoken.OR,
+	ast.BinaryOpBitwiseXor:     gotoken.XOR,
+	ast.BinaryOpConcat:         gotoken.ADD,
+	ast.BinaryOpSmaller:        gotoken.LSS,
+	ast.BinaryOpSmallerOrEqual: gotoken.LEQ,
+	ast.BinaryOpGreater:        gotoken.GTR,
+	ast.BinaryOpGreaterOrEqual: gotoken.GEQ,
+	ast.BinaryOpEqual:          gotoken.EQL,
+	ast.BinaryOpNotEqual:       gotoken.NEQ,
+	ast.BinaryOpIdentical:      gotoken.EQL,
+	ast.BinaryOpNotIdentical:   gotoken.NEQ,
+	ast.BinaryOpBooleanAnd:     gotoken.LAND,
+	ast.BinaryOpBooleanOr:      gotoken.LOR,
+	ast.BinaryOpBooleanXor:     gotoken.NEQ,
+}
+
+// nativeBinary 渲染原生二元运算，typ 为运算结果的类型
+func (r *render) nativeBinary(op ast.BinaryOpKind, typ infer.Type, left, right ir.Expr) goast.Expr {
+	// 操作数的类型：比较运算中 int 与 float 按 float 比较
+	operandType := typ
+	switch op {
+	case ast.BinaryOpSmaller, ast.BinaryOpSmallerOrEqual, ast.BinaryOpGreater, ast.BinaryOpGreaterOrEqual,
+		ast.BinaryOpEqual, ast.BinaryOpNotEqual, ast.BinaryOpIdentical, ast.BinaryOpNotIdentical:
+		operandType = r.fn.Expr(left)
+		if t := r.fn.Expr(right); t != operandType {
+			operandType = infer.Float
+		}
+	}
+
+	switch op {
+	case ast.BinaryOpDiv:
+		return r.defCall("DivFloat", r.native(left, infer.Float), r.native(right, infer.Float))
+	case ast.BinaryOpPow:
+		return call(r.pkgSel(pkgMath, "Pow"), r.native(left, infer.Float), r.native(right, infer.Float))
+	case ast.BinaryOpMod:
+		return r.defCall("ModInt", r.native(left, infer.Int), r.native(right, infer.Int))
+	case ast.BinaryOpBooleanAnd, ast.BinaryOpBooleanOr, ast.BinaryOpBooleanXor:
+		x := r.cond(left)
+		return paren(&goast.BinaryExpr{X: x, Op: nativeBinaryOps[op], Y: r.cond(right)})
+	default:
+		x := r.native(left, operandType)
+		return paren(&goast.BinaryExpr{X: x, Op: nativeBinaryOps[op], Y: r.native(right, operandType)})
+	}
+}
+
+func (r *render) nativeUnary(x *ir.UnaryExpr, typ infer.Type) goast.Expr {
+	switch x.Op {
+	case ast.UnaryOpBooleanNot:
+		return &goast.UnaryExpr{Op: gotoken.NOT, X: r.cond(x.Var)}
+	case ast.UnaryOpPlus:
+		return r.native(x.Var, typ)
+	case ast.UnaryOpMinus:
+		return paren(&goast.UnaryExpr{Op: gotoken.SUB, X: r.native(x.Var, typ)})
+	case ast.UnaryOpBitwiseNot:
+		return paren(&goast.UnaryExpr{Op: gotoken.XOR, X: r.native(x.Var, typ)})
+	default: // ++/--
+		name, varType, _ := r.nativeVar(x.Var)
+		body := &goast.BlockStmt{}
+		if x.Op == ast.UnaryOpPostInc || x.Op == ast.UnaryOpPostDec {
+			body.List = []goast.Stmt{define("old", ident(name)), r.nativeAssign(x), returnStmt(ident("old"))}
+		} else {
+			body.List = []goast.Stmt{r.nativeAssign(x), returnStmt(ident(name))}
+		}
+		return call(funcLit(nil, goType(varType), body))
+	}
+}
+
+func (r *render) nativeCast(x *ir.CastExpr) goast.Expr {
+	typ := r.fn.Expr(x.Expr)
+	switch {
+	case x.Kind == ast.CastDouble:
+		return r.native(x.Expr, infer.Float)
+	case x.Kind == ast.CastBool && typ == infer.Int:
+		return paren(&goast.BinaryExpr{X: r.native(x.Expr, infer.Int), Op: gotoken.NEQ, Y: intLit(0)})
+	default:
+		return r.native(x.Expr, typ)
+	}
+}
+
+// nativeStmt 语句位置的原生变量赋值及 ++/--，渲染为 go 赋值语句。返回 nil 时由调用方按表达式渲染
+func (r *render) nativeStmt(e ir.Expr) goast.Stmt {
+	if r.fn == nil {
+		return nil
+	}
+	switch x := e.(type) {
+	case *ir.AssignExpr, *ir.AssignOpExpr:
+	case *ir.UnaryExpr:
+		if !isIncDec(x.Op) {
+			return nil
+		}
+	default:
+		return nil
+	}
+	if _, _, ok := r.nativeVar(assignTarget(e)); !ok {
+		return nil
+	}
+	return r.nativeAssign(e)
+}
+
+// nativeAssign 对原生变量的赋值语句
+func (r *render) nativeAssign(e ir.Expr) goast.Stmt {
+	name, typ, _ := r.nativeVar(assignTarget(e))
+	switch x := e.(type) {
+	case *ir.AssignExpr:
+		return assign(ident(name), r.native(x.Expr, typ))
+	case *ir.AssignOpExpr:
+		op := assignBinaryOps[x.Op]
+		if r.hasNativeBinary(op, typ, typ, r.fn.Expr(x.Expr)) {
+			return assign(ident(name), r.nativeBinary(op, typ, x.Var, x.Expr))
+		}
+		return assign(ident(name), r.unbox(typ, r.defCall(binaryOpMethods[op], x.Var, x.Expr)))
+	default: // ++/--
+		tok := gotoken.DEC
+		if op := e.(*ir.UnaryExpr).Op; op == ast.UnaryOpPreInc || op == ast.UnaryOpPostInc {
+			tok = gotoken.INC
+		}
+		return &goast.IncDecStmt{X: ident(name), Tok: tok}
+	}
+}
+
+// stmtExpr 渲染语句位置的表达式，如表达式语句及 for 语句的初始化、循环表达式
+func (r *render) stmtExpr(e ir.Expr) goast.Stmt {
+	if stmt := r.nativeStmt(e); stmt != nil {
+		return stmt
+	}
+	return exprStmt(r.expr(e))
+}
+
+func isIncDec(op ast.UnaryOpKind) bool {
+	switch op {
+	case ast.UnaryOpPreInc, ast.UnaryOpPreDec, ast.UnaryOpPostInc, ast.UnaryOpPostDec:
+		return true
+	}
+	return false
+}
+
+var assignBinaryOps = map[ast.AssignOpKind]ast.BinaryOpKind{
+	ast.AssignOpBitwiseAnd: ast.BinaryOpBitwiseAnd,
+	ast.AssignOpBitwiseOr:  ast.BinaryOpBitwiseOr,
+	ast.AssignOpBitwiseXor: ast.BinaryOpBitwiseXor,
+	ast.AssignOpConcat:     ast.BinaryOpConcat,
+	ast.AssignOpDiv:        ast.BinaryOpDiv,
+	ast.AssignOpMinus:      ast.BinaryOpMinus,
+	ast.AssignOpMod:        ast.BinaryOpMod,
+	ast.AssignOpMul:        ast.BinaryOpMul,
+	ast.AssignOpPlus:       ast.BinaryOpPlus,
+	ast.AssignOpPow:        ast.BinaryOpPow,
+	ast.AssignOpShiftLeft:  ast.BinaryOpShiftLeft,
+	ast.AssignOpShiftRight: ast.BinaryOpShiftRight,
+}
@@ -1,122 +1,193 @@
 package render
 
 import (
-	"fmt"
 	"github.com/heyuuu/gophp/compile/ast"
 	"github.com/heyuuu/gophp/compile/ir"
 	"github.com/heyuuu/gophp/php/def"
-	"strconv"
+	"github.com/heyuuu/gophp/php/lang"
+	goast "go/ast"
+	gotoken "go/token"
 )
 
 func (r *render) VisitArg(n *ir.Arg) {
-	r.pDefCall("Arg", n.Value, n.Unpack)
+	r.result = r.defCall("Arg", n.Value, n.Unpack)
 }
 
 func (r *render) VisitName(n *ir.Name) {
-	r.print(stringLit(n.ToCodeString()))
+	r.result = strLit(n.ToCodeString())
 }
 
 func (r *render) VisitIdent(n *ir.Ident) {
-	r.print(stringLit(n.Name))
+	r.result = strLit(n.Name)
 }
 
 func (r *render) VisitParam(n *ir.Param) {
-	r.pDefCall("Param", n.Type, n.Var, n.Default, n.ByRef, n.Variadic)
+	r.result = r.defCall("Param", n.Type,
+		r.identOrExprAsStr(n.Var.Name, def.StageVariableName, "Param.Var.Name"),
+		n.Default, n.ByRef, n.Variadic)
 }
 
 // type
 
 func (r *render) VisitSimpleType(n *ir.SimpleType) {
-	r.pDefCall("SimpleType", n.Name.ToCodeString())
+	r.result = r.defCall("SimpleType", stringLit(n.Name.ResolvedCodeString()))
 }
 
 func (r *render) VisitIntersectionType(n *ir.IntersectionType) {
-	r.pDefCall("IntersectionType", n.Types)
+	r.result = r.defCall("IntersectionType", typeHints(n.Types)...)
 }
 
 func (r *render) VisitUnionType(n *ir.UnionType) {
-	r.pDefCall("UnionType", n.Types)
+	r.result = r.defCall("UnionType", typeHints(n.Types)...)
+}
+
+func typeHints(types []ir.TypeHint) []any {
+	args := make([]any, len(types))
+	for i, typ := range types {
+		args[i] = typ
+	}
+	return args
 }
 
 func (r *render) VisitNullableType(n *ir.NullableType) {
-	r.pDefCall("NullableType", n.Type)
+	r.result = r.defCall("NullableType", n.Type)
 }
 
 // Expr
 func (r *render) VisitNullLit(n *ir.NullLit) {
-	r.pDefCall("Null")
+	r.result = r.defCall("Null")
 }
 
 func (r *render) VisitBoolLit(n *ir.BoolLit) {
-	r.pDefCall("Bool", n.Value)
+	r.result = r.defCall("Bool", n.Value)
 }
 
 func (r *render) VisitIntLit(n *ir.IntLit) {
-	r.pDefCall("Int", strconv.Itoa(n.Value))
+	r.result = r.defCall("Int", n.Value)
 }
 
 func (r *render) VisitFloatLit(n *ir.FloatLit) {
-	r.pDefCall("Float", fmt.Sprintf("%f", n.Value))
+	r.result = r.defCall("Float", r.float(n.Value))
 }
 
 func (r *render) VisitStringLit(n *ir.StringLit) {
-	r.pDefCall("String", stringLit(n.Value))
+	r.result = r.defCall("String", stringLit(n.Value))
 }
 
 func (r *render) VisitArrayExpr(n *ir.ArrayExpr) {
-	r.pDefCall("Array", func() {
-		pList(r, n.Items, ", ")
-	})
-
+	r.result = r.defCall("Array", r.arrayItems(n.Items))
 }
 
 func (r *render) VisitArrayItemExpr(n *ir.ArrayItemExpr) {
-	r.pDefCall("ArrayItem", n.Key, n.Value, n.ByRef, n.Unpack)
+	r.result = r.defCall("ArrayItem", n.Key, n.Value, n.ByRef, n.Unpack)
 }
 
 func (r *render) VisitClosureExpr(n *ir.ClosureExpr) {
-	//TODO implement me
-	panic("implement me")
+	// 闭包内的 __METHOD__ 为 {closure}，由运行时计算
+	prevMethod := r.currMethod
+	r.currMethod = ""
+	defer func() { r.currMethod = prevMethod }()
+
+	returnType := r.goExpr(n.ReturnType)
+	params := r.params(n.Params)
+	var uses goast.Expr = ident("nil")
+	if len(n.Uses) > 0 {
+		var elts []goast.Expr
+		for _, use := range n.Uses {
+			elts = append(elts, r.node(use))
+		}
+		uses = sliceLit(&goast.StarExpr{X: r.pkgSel(pkgDef, "ClosureUse")}, elts)
+	}
+	r.result = r.defCall("Closure", n.Static, n.ByRef, returnType, params, uses, r.funcBody(n, n.Stmts))
 }
 
 func (r *render) VisitClosureUseExpr(n *ir.ClosureUseExpr) {
-	//TODO implement me
-	panic("implement me")
+	// 箭头函数内的闭包使用的变量同样需要由箭头函数绑定
+	if ident, ok := n.Var.Name.(*ir.Ident); ok && len(r.arrows) > 0 {
+		r.arrows[len(r.arrows)-1].add(ident.Name)
+	}
+	r.result = r.defCall("ClosureUse", r.identOrExprAsStr(n.Var.Name, def.StageVariableName, "ClosureUseExpr.Var.Name"), n.ByRef)
 }
 
+// VisitArrowFunctionExpr 箭头函数按值绑定函数体内使用的外部变量，变量列表在渲染函数体后生成
 func (r *render) VisitArrowFunctionExpr(n *ir.ArrowFunctionExpr) {
-	//TODO implement me
-	panic("implement me")
+	// 箭头函数按值绑定外部变量，函数体内不使用原生局部变量
+	prevMethod, prevFn := r.currMethod, r.fn
+	r.currMethod, r.fn = "", nil
+	defer func() { r.currMethod, r.fn = prevMethod, prevFn }()
+
+	returnType := r.goExpr(n.ReturnType)
+	params := r.params(n.Params)
+
+	frame := &arrowFrame{seen: map[string]bool{}}
+	r.arrows = append(r.arrows, frame)
+	body := r.generatorBody(func() goast.Expr {
+		block := &goast.BlockStmt{Lbrace: r.currPos()}
+		ret := &goast.ReturnStmt{Return: r.mapLine(n.Expr.Pos())}
+		ret.Results = []goast.Expr{r.expr(n.Expr)}
+		block.List = []goast.Stmt{ret}
+		block.Rbrace = r.newLine()
+		return funcLit(r.definer("Definer"), r.pkgSel(pkgDef, "Val"), block)
+	})
+	r.arrows = r.arrows[:len(r.arrows)-1]
+
+	paramNames := map[string]bool{"this": true}
+	for _, param := range n.Params {
+		if ident, ok := param.Var.Name.(*ir.Ident); ok {
+			paramNames[ident.Name] = true
+		}
+	}
+	var uses []goast.Expr
+	for _, name := range frame.names {
+		if !paramNames[name] {
+			uses = append(uses, strLit(name))
+			// 嵌套的箭头函数使用的变量同样需要由外层绑定
+			if len(r.arrows) > 0 {
+				r.arrows[len(r.arrows)-1].add(name)
+			}
+		}
+	}
+	var usesExpr goast.Expr = ident("nil")
+	if len(uses) > 0 {
+		usesExpr = sliceLit(ident("string"), uses)
+	}
+
+	r.result = r.defCall("ArrowFunction", n.Static, n.ByRef, returnType, params, usesExpr, body)
 }
 
 func (r *render) VisitIndexExpr(n *ir.IndexExpr) {
-	r.pDefCall("Index", n.Var, n.Dim)
+	r.result = r.defCall("Index", n.Var, n.Dim)
 }
 
 func (r *render) VisitCastExpr(n *ir.CastExpr) {
+	if r.nativeBoxed(n) {
+		return
+	}
 	var castType string
 	switch n.Kind {
 	case ast.CastArray:
-		castType = "ast.CastArray"
+		castType = "CastArray"
 	case ast.CastBool:
-		castType = "ast.CastBool"
+		castType = "CastBool"
 	case ast.CastDouble:
-		castType = "ast.CastDouble"
+		castType = "CastDouble"
 	case ast.CastInt:
-		castType = "ast.CastInt"
+		castType = "CastInt"
 	case ast.CastObject:
-		castType = "ast.CastObject"
+		castType = "CastObject"
 	case ast.CastString:
-		castType = "ast.CastString"
+		castType = "CastString"
 	case ast.CastUnset:
-		castType = "ast.CastUnset"
+		castType = "CastUnset"
 	}
 
-	r.usePkg(pkgAst)
-	r.pDefCall("Cast", castType, n.Expr)
+	r.result = r.defCall("Cast", r.pkgSel(pkgAst, castType), n.Expr)
 }
 
 func (r *render) VisitUnaryExpr(n *ir.UnaryExpr) {
+	if r.nativeBoxed(n) {
+		return
+	}
 	var method string
 
 	switch n.Op {
@@ -129,7 +200,7 @@ func (r *render) VisitUnaryExpr(n *ir.UnaryExpr) {
 	case ast.UnaryOpBitwiseNot:
 		method = "BitwiseNot"
 	case ast.UnaryOpPreInc:
-		method = "PrePlus"
+		method = "PreInc"
 	case ast.UnaryOpPreDec:
 		method = "PreDec"
 	case ast.UnaryOpPostInc:
@@ -138,45 +209,110 @@ func (r *render) VisitUnaryExpr(n *ir.UnaryExpr) {
 		method = "PostDec"
 	}
 
-	r.pDefCall(method, n.Var)
+	r.result = r.defCall(method, n.Var)
+}
+
+var binaryOpMethods = map[ast.BinaryOpKind]string{
+	ast.BinaryOpBitwiseAnd:     "BitwiseAnd",
+	ast.BinaryOpBitwiseOr:      "BitwiseOr",
+	ast.BinaryOpBitwiseXor:     "BitwiseXor",
+	ast.BinaryOpBooleanAnd:     "BooleanAnd",
+	ast.BinaryOpBooleanOr:      "BooleanOr",
+	ast.BinaryOpBooleanXor:     "BooleanXor",
+	ast.BinaryOpCoalesce:       "Coalesce",
+	ast.BinaryOpConcat:         "Concat",
+	ast.BinaryOpDiv:            "Div",
+	ast.BinaryOpEqual:          "Equal",
+	ast.BinaryOpGreater:        "Greater",
+	ast.BinaryOpGreaterOrEqual: "GreaterOrEqual",
+	ast.BinaryOpIdentical:      "Identical",
+	ast.BinaryOpMinus:          "Minus",
+	ast.BinaryOpMod:            "Mod",
+	ast.BinaryOpMul:            "Mul",
+	ast.BinaryOpNotEqual:       "NotEqual",
+	ast.BinaryOpNotIdentical:   "NotIdentical",
+	ast.BinaryOpPlus:           "Plus",
+	ast.BinaryOpPow:            "Pow",
+	ast.BinaryOpShiftLeft:      "ShiftLeft",
+	ast.BinaryOpShiftRight:     "ShiftRight",
+	ast.BinaryOpSmaller:        "Smaller",
+	ast.BinaryOpSmallerOrEqual: "SmallerOrEqual",
+	ast.BinaryOpSpaceship:      "Spaceship",
 }
 
 func (r *render) VisitBinaryOpExpr(n *ir.BinaryOpExpr) {
-	var method string
-	switch n.Op {
-	// todo
+	if r.nativeBoxed(n) {
+		return
+	}
+	method, ok := binaryOpMethods[n.Op]
+	if !ok {
+		r.unexpected("BinaryOpExpr.Op", n.Op)
 	}
 
-	r.pDefCall(method, n.Left, n.Right)
+	switch n.Op {
+	case ast.BinaryOpBooleanAnd, ast.BinaryOpBooleanOr, ast.BinaryOpCoalesce:
+		// 短路运算，右值延迟求值
+		left := r.expr(n.Left)
+		r.result = r.defCall(method, left, r.lazy(n.Right))
+	default:
+		r.result = r.defCall(method, n.Left, n.Right)
+	}
 }
 
 func (r *render) VisitAssignExpr(n *ir.AssignExpr) {
-	r.pDefCall("Assign", n.Var, n.Expr)
+	if r.nativeBoxed(n) {
+		return
+	}
+	r.result = r.defCall("Assign", n.Var, n.Expr)
+}
+
+var assignOpMethods = map[ast.AssignOpKind]string{
+	ast.AssignOpBitwiseAnd: "AssignBitwiseAnd",
+	ast.AssignOpBitwiseOr:  "AssignBitwiseOr",
+	ast.AssignOpBitwiseXor: "AssignBitwiseXor",
+	ast.AssignOpCoalesce:   "AssignCoalesce",
+	ast.AssignOpConcat:     "AssignConcat",
+	ast.AssignOpDiv:        "AssignDiv",
+	ast.AssignOpMinus:      "AssignMinus",
+	ast.AssignOpMod:        "AssignMod",
+	ast.AssignOpMul:        "AssignMul",
+	ast.AssignOpPlus:       "AssignPlus",
+	ast.AssignOpPow:        "AssignPow",
+	ast.AssignOpShiftLeft:  "AssignShiftLeft",
+	ast.AssignOpShiftRight: "AssignShiftRight",
 }
 
 func (r *render) VisitAssignOpExpr(n *ir.AssignOpExpr) {
-	var method string
-	switch n.Op {
-	// todo
+	if r.nativeBoxed(n) {
+		return
+	}
+	method, ok := assignOpMethods[n.Op]
+	if !ok {
+		r.unexpected("AssignOpExpr.Op", n.Op)
 	}
 
-	r.pDefCall(method, n.Var, n.Expr)
+	if n.Op == ast.AssignOpCoalesce {
+		variable := r.expr(n.Var)
+		r.result = r.defCall(method, variable, r.lazy(n.Expr))
+	} else {
+		r.result = r.defCall(method, n.Var, n.Expr)
+	}
 }
 
 func (r *render) VisitAssignRefExpr(n *ir.AssignRefExpr) {
-	r.pDefCall("AssignRef", n.Var, n.Expr)
+	r.result = r.defCall("AssignRef", n.Var, n.Expr)
 }
 
 func (r *render) VisitIssetExpr(n *ir.IssetExpr) {
-	r.pDefCall("Isset", n.Vars)
+	r.result = r.defCall("Isset", n.Vars)
 }
 
 func (r *render) VisitEmptyExpr(n *ir.EmptyExpr) {
-	r.pDefCall("Empty", n.Expr)
+	r.result = r.defCall("Empty", n.Expr)
 }
 
 func (r *render) VisitEvalExpr(n *ir.EvalExpr) {
-	r.pDefCall("Eval", n.Expr)
+	r.result = r.defCall("Eval", n.Expr)
 }
 
 func (r *render) VisitIncludeExpr(n *ir.IncludeExpr) {
@@ -192,37 +328,39 @@ func (r *render) VisitIncludeExpr(n *ir.IncludeExpr) {
 		kind = "KindRequireOnce"
 	}
 
-	r.pDefCall("Include", func() { r.print(pkgAst, kind) }, n.Expr)
+	r.result = r.defCall("Include", r.pkgSel(pkgAst, kind), n.Expr)
 }
 
 func (r *render) VisitCloneExpr(n *ir.CloneExpr) {
-	r.pDefCall("Clone", n.Expr)
+	r.result = r.defCall("Clone", n.Expr)
 }
 
 func (r *render) VisitErrorSuppressExpr(n *ir.ErrorSuppressExpr) {
-	r.pDefCall("ErrorSuppress", n.Expr)
+	r.result = r.defCall("ErrorSuppress", r.lazy(n.Expr))
 }
 
 func (r *render) VisitExitExpr(n *ir.ExitExpr) {
-	r.pDefCall("ErrorSuppress", n.Expr)
+	r.result = r.defCall("Exit", n.Expr)
 }
 
 func (r *render) VisitConstFetchExpr(n *ir.ConstFetchExpr) {
-	r.pDefCall("Const", n.Name.ToString())
+	r.result = r.defCall("Const", stringLit(n.Name.ResolvedCodeString()))
 }
 
 func (r *render) VisitClassConstFetchExpr(n *ir.ClassConstFetchExpr) {
-	r.pDefCall("ClassConst", func() {
-		r.pClassName(n)
-	}, n.Name)
+	r.result = r.defCall("ClassConst", r.className(n.Class), n.Name)
 }
 
 func (r *render) VisitMagicConstExpr(n *ir.MagicConstExpr) {
 	var kind string
-	var value ir.Expr
+	var value goast.Expr // 可在转译时确定的值，nil 时由运行时计算
 	switch n.Kind {
 	case ast.MagicConstClass:
 		kind = "MagicConstClass"
+		// trait 中为使用 trait 的类，由运行时计算
+		if r.currClassKind != "" && r.currClassKind != classTrait {
+			value = r.defCall("String", stringLit(r.currClass))
+		}
 	case ast.MagicConstDir:
 		kind = "MagicConstDir"
 	case ast.MagicConstFile:
@@ -231,42 +369,51 @@ func (r *render) VisitMagicConstExpr(n *ir.MagicConstExpr) {
 		kind = "MagicConstFunction"
 	case ast.MagicConstLine:
 		kind = "MagicConstLine"
+		value = r.defCall("Int", n.Pos().Line)
 	case ast.MagicConstMethod:
 		kind = "MagicConstMethod"
+		if r.currMethod != "" {
+			value = r.defCall("String", stringLit(r.currClass+"::"+r.currMethod))
+		}
 	case ast.MagicConstNamespace:
 		kind = "MagicConstNamespace"
+		value = r.defCall("String", stringLit(r.currNamespace))
 	case ast.MagicConstTrait:
 		kind = "MagicConstTrait"
+		value = r.defCall("String", stringLit(lang.Cond(r.currClassKind == classTrait, r.currClass, "")))
 	}
 
-	r.pDefCall("MagicConst", func() {
-		r.print(pkgAst, kind)
-	}, value)
+	if value == nil {
+		value = ident("nil")
+	}
+	r.result = r.defCall("MagicConst", r.pkgSel(pkgAst, kind), value)
 }
 
 func (r *render) VisitInstanceofExpr(n *ir.InstanceofExpr) {
-	r.pDefCall("Instanceof", n.Expr, func() { r.pClassName(n.Class) })
+	expr := r.expr(n.Expr)
+	r.result = r.defCall("Instanceof", expr, r.className(n.Class))
 }
 
 func (r *render) VisitListExpr(n *ir.ListExpr) {
-	//TODO implement me
-	panic("implement me")
+	r.result = r.defCall("List", r.arrayItems(n.Items))
 }
 
 func (r *render) VisitPrintExpr(n *ir.PrintExpr) {
-	r.pDefCall("Print", n.Expr)
+	r.result = r.defCall("Print", n.Expr)
 }
 
 func (r *render) VisitPropertyFetchExpr(n *ir.PropertyFetchExpr) {
+	method := "Prop"
 	if n.Nullsafe {
-		r.pDefCall("NullsafeProp", n.Var, n.Name)
-	} else {
-		r.pDefCall("Prop", n.Var, n.Name)
+		method = "NullsafeProp"
 	}
+	variable := r.expr(n.Var)
+	r.result = r.defCall(method, variable, r.identOrExprAsStr(n.Name, def.StagePropertyName, "PropertyFetchExpr.Name"))
 }
 
 func (r *render) VisitStaticPropertyFetchExpr(n *ir.StaticPropertyFetchExpr) {
-	r.pDefCall("StaticProperty", func() { r.pClassName(n.Class) }, n.Name)
+	class := r.className(n.Class)
+	r.result = r.defCall("StaticProp", class, r.identOrExprAsStr(n.Name, def.StagePropertyName, "StaticPropertyFetchExpr.Name"))
 }
 
 func (r *render) VisitShellExecExpr(n *ir.ShellExecExpr) {
@@ -274,53 +421,65 @@ func (r *render) VisitShellExecExpr(n *ir.ShellExecExpr) {
 }
 
 func (r *render) VisitTernaryExpr(n *ir.TernaryExpr) {
-	//TODO implement me
-	panic("implement me")
+	cond := r.expr(n.Cond)
+	var ifVal goast.Expr = ident("nil")
+	if n.If != nil {
+		ifVal = r.lazy(n.If)
+	}
+	r.result = r.defCall("Ternary", cond, ifVal, r.lazy(n.Else))
 }
 
 func (r *render) VisitThrowExpr(n *ir.ThrowExpr) {
-	r.pDefCall("Throw", n.Expr)
+	r.result = r.defCall("Throw", n.Expr)
+}
+
+func (r *render) VisitMatchExpr(n *ir.MatchExpr) {
+	r.todo("VisitMatchExpr")
+}
+
+func (r *render) VisitMatchArm(n *ir.MatchArm) {
+	r.todo("VisitMatchArm")
 }
 
 func (r *render) VisitVariableExpr(n *ir.VariableExpr) {
-	r.pDefCall("Var", func() {
-		r.pIdentOrExprAsStr(n.Name, def.StageVariableName, "VariableExpr.Name")
-	})
+	if r.nativeBoxed(n) {
+		return
+	}
+	if ident, ok := n.Name.(*ir.Ident); ok && len(r.arrows) > 0 {
+		r.arrows[len(r.arrows)-1].add(ident.Name)
+	}
+	r.result = r.defCall("Var", r.identOrExprAsStr(n.Name, def.StageVariableName, "VariableExpr.Name"))
 }
 
 func (r *render) VisitYieldExpr(n *ir.YieldExpr) {
-	r.todo("YieldExpr")
+	r.generator = true
+	r.result = r.defCall("Yield", n.Key, n.Value)
 }
 
 func (r *render) VisitYieldFromExpr(n *ir.YieldFromExpr) {
-	r.todo("YieldFromExpr")
+	r.generator = true
+	r.result = r.defCall("YieldFrom", n.Expr)
 }
 
 func (r *render) VisitFuncCallExpr(n *ir.FuncCallExpr) {
-	r.pDefCall("FuncCall", func() {
-		r.pNameOrExprAsStr(n.Name, def.StageFuncName, "FuncCallExpr.Name")
+	method := "FuncCall"
+	var name goast.Expr
+	if x, ok := n.Name.(ir.Expr); ok {
+		// $fn()、$closure() 等动态调用
+		method = "FuncCallEx"
+		name = r.expr(x)
+	} else {
+		name = r.nameOrExprAsStr(n.Name, def.StageFuncName, "FuncCallExpr.Name")
+	}
 
-		// args
-		if len(n.Args) > 0 {
-			r.print(", ")
-			pList(r, n.Args, ", ")
-		}
-	})
+	r.result = r.defCall(method, name, n.Args)
 }
 
 func (r *render) VisitNewExpr(n *ir.NewExpr) {
-	r.pDefCall("New", func() {
-		if _, ok := n.Class.(*ir.ClassStmt); ok {
-			r.todo("NewExpr.Class is ClassStmt")
-		}
-
-		r.pClassName(n.Class)
-		// args
-		if len(n.Args) > 0 {
-			r.print(", ")
-			pList(r, n.Args, ", ")
-		}
-	})
+	if _, ok := n.Class.(*ir.ClassStmt); ok {
+		r.todo("NewExpr.Class is ClassStmt")
+	}
+	r.result = r.defCall("New", r.className(n.Class), n.Args)
 }
 
 func (r *render) VisitMethodCallExpr(n *ir.MethodCallExpr) {
@@ -329,29 +488,15 @@ func (r *render) VisitMethodCallExpr(n *ir.MethodCallExpr) {
 		method = "NullsafeMethodCall"
 	}
 
-	r.pDefCall(method, n.Var, func() {
-		r.pIdentOrExprAsStr(n.Name, def.StageMethodName, "MethodCallExpr.Name")
-		// args
-		if len(n.Args) > 0 {
-			r.print(", ")
-			pList(r, n.Args, ", ")
-		}
-	})
+	variable := r.expr(n.Var)
+	name := r.identOrExprAsStr(n.Name, def.StageMethodName, "MethodCallExpr.Name")
+	r.result = r.defCall(method, variable, name, n.Args)
 }
 
 func (r *render) VisitStaticCallExpr(n *ir.StaticCallExpr) {
-	r.pDefCall("StaticMethodCall", func() {
-		// class
-		r.pClassName(n.Class)
-		// method
-		r.print(", ")
-		r.pIdentOrExprAsStr(n.Name, def.StageMethodName, "StaticCallExpr.Name")
-		// args
-		if len(n.Args) > 0 {
-			r.print(", ")
-			pList(r, n.Args, ", ")
-		}
-	})
+	class := r.className(n.Class)
+	name := r.identOrExprAsStr(n.Name, def.StageMethodName, "StaticCallExpr.Name")
+	r.result = r.defCall("StaticMethodCall", class, name, n.Args)
 }
 
 func (r *render) VisitEmptyStmt(n *ir.EmptyStmt) {
@@ -359,130 +504,329 @@ func (r *render) VisitEmptyStmt(n *ir.EmptyStmt) {
 }
 
 func (r *render) VisitExprStmt(n *ir.ExprStmt) {
-	r.expr(n.Expr)
+	r.emit(r.stmtExpr(n.Expr))
 }
 
 func (r *render) VisitReturnStmt(n *ir.ReturnStmt) {
-	r.print("return ", n.Expr)
+	if n.Expr == nil {
+		r.emit(r.ret(r.defCall("Null")))
+		return
+	}
+	r.emit(r.ret(r.expr(n.Expr)))
+}
+
+// ret 渲染 return 语句，try 语句块内由语句块返回 d.Return(v)
+func (r *render) ret(v goast.Expr) goast.Stmt {
+	if n := len(r.tries); n > 0 {
+		r.tries[n-1].hasReturn = true
+		return returnStmt(r.defCall("Return", v))
+	}
+	return returnStmt(v)
 }
 
 func (r *render) VisitLabelStmt(n *ir.LabelStmt) {
-	r.print(n.Name.Name, ":")
+	if !r.gotoLabels[n.Name.Name] {
+		return
+	}
+	r.emit(&goast.LabeledStmt{Label: ident(n.Name.Name), Stmt: &goast.EmptyStmt{Implicit: true}})
 }
 
 func (r *render) VisitGotoStmt(n *ir.GotoStmt) {
-	r.print("goto ", n.Name.Name)
+	if len(r.tries) > 0 {
+		r.todo("goto inside try")
+	}
+	r.emit(&goast.BranchStmt{Tok: gotoken.GOTO, Label: ident(n.Name.Name)})
 }
 
 func (r *render) VisitIfStmt(n *ir.IfStmt) {
-	r.print("if ", n.Cond, " {\n", n.Stmts, "}")
-
+	// elseif 的条件在上一分支的右花括号所在行求值，该行映射到 elseif 所在行
+	var nexts []ast.Position
 	for _, elseif := range n.Elseifs {
-		r.VisitElseIfStmt(elseif)
+		nexts = append(nexts, elseif.Pos())
+	}
+	body := func(stmts []ir.Stmt, i int) *goast.BlockStmt {
+		if i < len(nexts) {
+			return r.blockBefore(stmts, nexts[i])
+		}
+		return r.block(stmts)
 	}
 
+	cond := r.cond(n.Cond)
+	stmt := &goast.IfStmt{Cond: cond, Body: body(n.Stmts, 0)}
+	last := stmt
+	for i, elseif := range n.Elseifs {
+		branch := &goast.IfStmt{Cond: r.cond(elseif.Cond), Body: body(elseif.Stmts, i+1)}
+		last.Else = branch
+		last = branch
+	}
 	if n.Else != nil {
-		r.VisitElseStmt(n.Else)
+		last.Else = r.block(n.Else.Stmts)
 	}
+	r.emit(stmt)
 }
 
 func (r *render) VisitElseIfStmt(n *ir.ElseIfStmt) {
-	r.print(" else if ", n.Cond, " {\n", n.Stmts, "}")
+	cond := r.cond(n.Cond)
+	r.emit(&goast.IfStmt{Cond: cond, Body: r.block(n.Stmts)})
 }
 
 func (r *render) VisitElseStmt(n *ir.ElseStmt) {
-	r.print(" else {\n", n.Stmts, "}")
+	r.emit(r.block(n.Stmts))
 }
 
 func (r *render) VisitSwitchStmt(n *ir.SwitchStmt) {
-	r.print("switch ", varSwitch, " := ", n.Cond, "; {\n")
-	pList(r, n.Cases, "\n")
-	r.print("\n}")
+	// 没有 case 条件时 switchVal 未被使用，仅对条件求值
+	hasCond := false
+	for _, c := range n.Cases {
+		if c.Cond != nil {
+			hasCond = true
+			break
+		}
+	}
+
+	r.emit(r.loop(true, func() goast.Stmt {
+		var init goast.Stmt
+		if hasCond {
+			init = define(varSwitch, r.expr(n.Cond))
+		} else {
+			init = assign(ident("_"), r.expr(n.Cond))
+		}
+		body := &goast.BlockStmt{Lbrace: r.currPos()}
+		for i, c := range n.Cases {
+			clause := r.caseClause(c)
+			// PHP 的 case 默认贯穿执行到下一个 case
+			if i < len(n.Cases)-1 && !endsWithJump(c.Stmts) {
+				clause.Body = append(clause.Body, &goast.BranchStmt{Tok: gotoken.FALLTHROUGH})
+			}
+			body.List = append(body.List, clause)
+		}
+		body.Rbrace = r.newLine()
+		return &goast.SwitchStmt{Init: init, Body: body}
+	}))
 }
 
 func (r *render) VisitCaseStmt(n *ir.CaseStmt) {
+	r.emit(r.caseClause(n))
+}
+
+func (r *render) caseClause(n *ir.CaseStmt) *goast.CaseClause {
+	clause := &goast.CaseClause{Case: r.mapLine(n.Pos())}
+	r.phpLine = n.Pos().Line
 	if n.Cond != nil {
-		r.print("case ")
-		r.pAsBool(func() {
-			r.pDefCall("Equal", varSwitch, n.Cond)
-		})
-		r.print(":\n")
-	} else {
-		r.print("default:\n")
+		clause.List = []goast.Expr{r.asBool(r.defCall("Equal", ident(varSwitch), n.Cond))}
 	}
-	r.stmtList(n.Stmts, true)
+	clause.Body = r.stmtList(n.Stmts)
+	return clause
 }
 
 func (r *render) VisitForStmt(n *ir.ForStmt) {
-	if len(n.Init) > 1 || len(n.Cond) > 1 || len(n.Loop) > 1 {
-		r.todo(`ForStmt with multiple init, cond or loop`)
+	// 多个初始化表达式在循环前依次执行
+	var init goast.Stmt
+	if len(n.Init) == 1 {
+		init = r.stmtExpr(n.Init[0])
+	} else {
+		for _, expr := range n.Init {
+			r.emit(r.stmtExpr(expr))
+		}
+	}
+
+	// 多个条件表达式以最后一个的值作为循环条件
+	var cond goast.Expr
+	if len(n.Cond) == 1 {
+		cond = r.cond(n.Cond[0])
+	} else if len(n.Cond) > 1 {
+		body := &goast.BlockStmt{}
+		for _, expr := range n.Cond[:len(n.Cond)-1] {
+			body.List = append(body.List, r.stmtExpr(expr))
+		}
+		body.List = append(body.List, returnStmt(r.cond(n.Cond[len(n.Cond)-1])))
+		cond = call(funcLit(nil, ident("bool"), body))
+	}
+
+	var post goast.Stmt
+	if len(n.Loop) == 1 {
+		post = r.stmtExpr(n.Loop[0])
+	} else if len(n.Loop) > 1 {
+		body := &goast.BlockStmt{}
+		for _, expr := range n.Loop {
+			body.List = append(body.List, r.stmtExpr(expr))
+		}
+		post = exprStmt(call(funcLit(nil, nil, body)))
 	}
 
-	r.print("for ", n.Init, "; ", n.Cond, "; ", n.Loop, " {\n", n.Stmts, "}")
+	r.emit(r.loop(false, func() goast.Stmt {
+		return &goast.ForStmt{Init: init, Cond: cond, Post: post, Body: r.block(n.Stmts)}
+	}))
 }
 
 func (r *render) VisitForeachStmt(n *ir.ForeachStmt) {
-	//TODO implement me
-	panic("implement me")
+	it := func(method string) goast.Expr { return call(sel(ident("it"), method)) }
+
+	r.emit(r.loop(false, func() goast.Stmt {
+		method := "ForeachIterator"
+		if n.ByRef {
+			method = "ForeachIteratorRef"
+		}
+		init := define("it", r.defCall(method, n.Expr))
+		var assigns []goast.Stmt
+		if n.KeyVar != nil {
+			assigns = append(assigns, exprStmt(r.defCall("Assign", n.KeyVar, it("Key"))))
+		}
+		if n.ByRef {
+			assigns = append(assigns, exprStmt(r.defCall("AssignRef", n.ValueVar, it("ValueRef"))))
+		} else {
+			assigns = append(assigns, exprStmt(r.defCall("Assign", n.ValueVar, it("Value"))))
+		}
+		body := r.block(n.Stmts)
+		body.List = append(assigns, body.List...)
+		return &goast.ForStmt{Init: init, Cond: it("Valid"), Post: exprStmt(it("Next")), Body: body}
+	}))
 }
 
 func (r *render) VisitBreakStmt(n *ir.BreakStmt) {
-	if n.Num != nil {
-		r.todo("BreakStmt.Num is not nil")
-	}
-	r.print("break")
+	r.emit(r.jump("break", n.Num))
 }
 
 func (r *render) VisitContinueStmt(n *ir.ContinueStmt) {
-	if n.Num != nil {
-		r.todo("ContinueStmt.Num is not nil")
-	}
-	r.print("continue")
+	r.emit(r.jump("continue", n.Num))
 }
 
 func (r *render) VisitWhileStmt(n *ir.WhileStmt) {
-	//TODO implement me
-	panic("implement me")
+	r.emit(r.loop(false, func() goast.Stmt {
+		cond := r.cond(n.Cond)
+		return &goast.ForStmt{Cond: cond, Body: r.block(n.Stmts)}
+	}))
 }
 
 func (r *render) VisitDoStmt(n *ir.DoStmt) {
-	//TODO implement me
-	panic("implement me")
+	r.emit(r.loop(false, func() goast.Stmt {
+		// 条件打印在循环体之前，先渲染条件使节点位置与输出顺序一致
+		cond := &goast.BinaryExpr{X: ident("first"), Op: gotoken.LOR, Y: r.cond(n.Cond)}
+		body := r.block(n.Stmts)
+		return &goast.ForStmt{Init: define("first", ident("true")), Cond: cond, Post: assign(ident("first"), ident("false")), Body: body}
+	}))
 }
 
+// VisitTryCatchStmt 语句块转译为闭包，由 d.Try 执行。
+// 语句块内离开语句块的 return/break/continue 由闭包返回，在 try 语句后按跳转编号执行
 func (r *render) VisitTryCatchStmt(n *ir.TryCatchStmt) {
-	//TODO implement me
-	panic("implement me")
+	frame := &tryFrame{loopDepth: len(r.loops)}
+	r.tries = append(r.tries, frame)
+	try := r.tryBlock(n.Stmts)
+	var catches goast.Expr = ident("nil")
+	if len(n.Catches) > 0 {
+		var elts []goast.Expr
+		for _, c := range n.Catches {
+			elts = append(elts, r.node(c))
+		}
+		catches = sliceLit(&goast.StarExpr{X: r.pkgSel(pkgDef, "Catch")}, elts)
+	}
+	var finally goast.Expr = ident("nil")
+	if n.Finally != nil {
+		finally = r.node(n.Finally)
+	}
+	tryCall := r.defCall("Try", try, catches, finally)
+	r.tries = r.tries[:len(r.tries)-1]
+
+	if !frame.hasReturn && len(frame.jumps) == 0 {
+		r.emit(exprStmt(tryCall))
+		return
+	}
+
+	// 按跳转编号执行 return 或 break/continue，编号 0 为 return
+	type branch struct {
+		jump int
+		stmt goast.Stmt
+	}
+	var branches []branch
+	if frame.hasReturn {
+		branches = append(branches, branch{0, r.ret(sel(ident("flow"), "Ret"))})
+	}
+	for i, jump := range frame.jumps {
+		branches = append(branches, branch{i + 1, r.jumpLevel(jump.kind, jump.level)})
+	}
+	var chain goast.Stmt
+	for i := len(branches) - 1; i >= 0; i-- {
+		chain = &goast.IfStmt{
+			Cond: &goast.BinaryExpr{X: sel(ident("flow"), "Jump"), Op: gotoken.EQL, Y: intLit(branches[i].jump)},
+			Body: &goast.BlockStmt{List: []goast.Stmt{branches[i].stmt}},
+			Else: chain,
+		}
+	}
+	r.emit(&goast.IfStmt{
+		Init: define("flow", tryCall),
+		Cond: &goast.BinaryExpr{X: ident("flow"), Op: gotoken.NEQ, Y: ident("nil")},
+		Body: &goast.BlockStmt{List: []goast.Stmt{chain}},
+	})
 }
 
 func (r *render) VisitCatchStmt(n *ir.CatchStmt) {
-	//TODO implement me
-	panic("implement me")
+	var varName goast.Expr = strLit("")
+	if n.Var != nil {
+		varName = r.identOrExprAsStr(n.Var.Name, def.StageVariableName, "CatchStmt.Var.Name")
+	}
+	r.result = r.defCall("Catch", r.names(n.Types), varName, r.tryBlock(n.Stmts))
 }
 
 func (r *render) VisitFinallyStmt(n *ir.FinallyStmt) {
-	//TODO implement me
-	panic("implement me")
+	frame := r.tries[len(r.tries)-1]
+	frame.inFinally = true
+	r.result = r.tryBlock(n.Stmts)
+	frame.inFinally = false
+}
+
+// tryBlock try/catch/finally 的语句块 func() *def.Flow {...}
+func (r *render) tryBlock(stmts []ir.Stmt) goast.Expr {
+	body := r.block(stmts)
+	if !endsWithJump(stmts) {
+		r.returnNil(body)
+	}
+	return funcLit(nil, &goast.StarExpr{X: r.pkgSel(pkgDef, "Flow")}, body)
 }
 
 func (r *render) VisitConstStmt(n *ir.ConstStmt) {
-	r.pDefCall("DeclConst", n.Name, n.Value)
+	name := n.Name.Name
+	if n.NamespacedName != nil {
+		name = n.NamespacedName.ToString()
+	}
+	r.emit(exprStmt(r.defCall("DeclConst", stringLit(name), n.Value)))
 }
 
 func (r *render) VisitEchoStmt(n *ir.EchoStmt) {
-	r.pDefCall("EchoVal", n.Exprs)
+	// 按顺序逐个求值并输出，后面的表达式的副作用(如输出)发生在前面的值输出之后。连续的字符串字面量合并输出
+	var values []any
+	flush := func() {
+		if len(values) > 0 {
+			r.emit(exprStmt(r.defCall("Echo", values...)))
+			values = nil
+		}
+	}
+	for _, expr := range n.Exprs {
+		if lit, ok := expr.(*ir.StringLit); ok {
+			values = append(values, stringLit(lit.Value))
+			continue
+		}
+		flush()
+		r.emit(exprStmt(r.defCall("EchoVal", expr)))
+	}
+	flush()
 }
 
 func (r *render) VisitGlobalStmt(n *ir.GlobalStmt) {
-	r.pDefCall("DeclGlobal", n.Var) // todo global
+	v, ok := n.Var.(*ir.VariableExpr)
+	if !ok {
+		r.unexpected("GlobalStmt.Var", n.Var)
+	}
+	r.emit(exprStmt(r.defCall("DeclGlobal", r.identOrExprAsStr(v.Name, def.StageVariableName, "GlobalStmt.Var.Name"))))
 }
 
 func (r *render) VisitStaticStmt(n *ir.StaticStmt) {
-	r.pDefCall("DeclGlobal", n.Var, n.Default)
+	name := r.identOrExprAsStr(n.Var.Name, def.StageVariableName, "StaticStmt.Var.Name")
+	r.emit(exprStmt(r.defCall("DeclStatic", name, n.Default)))
 }
 
 func (r *render) VisitUnsetStmt(n *ir.UnsetStmt) {
-	r.pDefCall("Unset", n.Var)
+	r.emit(exprStmt(r.defCall("Unset", n.Var)))
 }
 
 func (r *render) VisitUseStmt(n *ir.UseStmt) {
@@ -501,59 +845,89 @@ func (r *render) VisitUseStmt(n *ir.UseStmt) {
 		alias = n.Alias.Name
 	}
 
-	r.pDefCall("Use",
-		func() { r.print(pkgAst, ".", useType) },
-		n.Name,
-		stringLit(alias),
-	)
+	r.emit(exprStmt(r.defCall("Use", r.pkgSel(pkgAst, useType), n.Name, stringLit(alias))))
 }
 
 func (r *render) VisitFunctionStmt(n *ir.FunctionStmt) {
-	//TODO implement me
-	panic("implement me")
+	name := declName(n.Name, n.NamespacedName)
+
+	// 方法内声明的函数不属于类
+	prevClass, prevKind, prevMethod := r.currClass, r.currClassKind, r.currMethod
+	r.currClass, r.currClassKind, r.currMethod = "", "", ""
+	defer func() { r.currClass, r.currClassKind, r.currMethod = prevClass, prevKind, prevMethod }()
+
+	returnType := r.goExpr(n.ReturnType)
+	params := r.params(n.Params)
+	r.emit(exprStmt(r.defCall("DeclFunc", stringLit(name), n.ByRef, returnType, params, r.funcBody(n, n.Stmts))))
 }
 
 func (r *render) VisitInterfaceStmt(n *ir.InterfaceStmt) {
-	//TODO implement me
-	panic("implement me")
+	name := declName(n.Name, n.NamespacedName)
+	body := r.classBody(classInterface, name, n.Stmts)
+	r.emit(exprStmt(r.defCall("DeclInterface", stringLit(name), r.names(n.Extends), body)))
 }
 
 func (r *render) VisitClassStmt(n *ir.ClassStmt) {
-	//TODO implement me
-	panic("implement me")
+	name := declName(n.Name, n.NamespacedName)
+	var parent string
+	if n.Extends != nil {
+		parent = n.Extends.ResolvedCodeString()
+	}
+	body := r.classBody(classClass, name, n.Stmts)
+	r.emit(exprStmt(r.defCall("DeclClass", stringLit(name), r.flags(n.Flags), stringLit(parent), r.names(n.Implements), body)))
 }
 
 func (r *render) VisitClassConstStmt(n *ir.ClassConstStmt) {
-	//TODO implement me
-	panic("implement me")
+	r.emit(exprStmt(r.defCall("DeclClassConst", r.flags(n.Flags), n.Name, n.Value)))
 }
 
 func (r *render) VisitPropertyStmt(n *ir.PropertyStmt) {
-	//TODO implement me
-	panic("implement me")
+	r.emit(exprStmt(r.defCall("DeclProperty", r.flags(n.Flags), n.Type, n.Name, n.Default)))
 }
 
 func (r *render) VisitClassMethodStmt(n *ir.ClassMethodStmt) {
-	//TODO implement me
-	panic("implement me")
+	r.currMethod = n.Name.Name
+	defer func() { r.currMethod = "" }()
+
+	returnType := r.goExpr(n.ReturnType)
+	params := r.params(n.Params)
+	// 抽象方法及接口方法没有方法体
+	var body goast.Expr = ident("nil")
+	if n.Flags&ast.FlagAbstract == 0 && r.currClassKind != classInterface {
+		body = r.funcBody(n, n.Stmts)
+	}
+	r.emit(exprStmt(r.defCall("DeclMethod", r.flags(n.Flags), n.Name, n.ByRef, returnType, params, body)))
 }
 
 func (r *render) VisitTraitStmt(n *ir.TraitStmt) {
-	//TODO implement me
-	panic("implement me")
+	name := declName(n.Name, n.NamespacedName)
+	body := r.classBody(classTrait, name, n.Stmts)
+	r.emit(exprStmt(r.defCall("DeclTrait", stringLit(name), body)))
 }
 
 func (r *render) VisitTraitUseStmt(n *ir.TraitUseStmt) {
-	//TODO implement me
-	panic("implement me")
+	args := []any{r.names(n.Traits)}
+	for _, adaptation := range n.Adaptations {
+		args = append(args, adaptation)
+	}
+	r.emit(exprStmt(r.defCall("UseTraits", args...)))
 }
 
 func (r *render) VisitTraitUseAdaptationAliasStmt(n *ir.TraitUseAdaptationAliasStmt) {
-	//TODO implement me
-	panic("implement me")
+	var trait, newName string
+	if n.Trait != nil {
+		trait = n.Trait.ResolvedCodeString()
+	}
+	if n.NewName != nil {
+		newName = n.NewName.Name
+	}
+	r.result = r.defCall("TraitAlias", stringLit(trait), n.Method, r.flags(n.NewModifier), stringLit(newName))
 }
 
 func (r *render) VisitTraitUseAdaptationPrecedenceStmt(n *ir.TraitUseAdaptationPrecedenceStmt) {
-	//TODO implement me
-	panic("implement me")
+	args := []any{stringLit(n.Trait.ResolvedCodeString()), n.Method}
+	for _, name := range n.Insteadof {
+		args = append(args, stringLit(name.ResolvedCodeString()))
+	}
+	r.result = r.defCall("TraitInsteadof", args...)
 }
@@ -1,24 +1,61 @@
 package render
 
 import (
+	"bytes"
 	"fmt"
+	"github.com/heyuuu/gophp/compile/ast"
+	"github.com/heyuuu/gophp/compile/infer"
 	"github.com/heyuuu/gophp/compile/ir"
 	"github.com/heyuuu/gophp/php/def"
+	"github.com/heyuuu/gophp/php/lang"
+	goast "go/ast"
+	"go/format"
+	"go/parser"
+	"go/printer"
+	gotoken "go/token"
 	"log"
+	"math"
 	"runtime"
 	"strconv"
 	"strings"
 )
 
 func RenderFile(f *ir.File) (string, error) {
-	r := &render{}
+	return RenderFileEx(f, "main")
+}
+
+// RenderFileEx 渲染文件，生成代码的包名为 packageName
+func RenderFileEx(f *ir.File, packageName string) (string, error) {
+	return RenderFileOptions(f, Options{Package: packageName})
+}
+
+// Options 渲染选项
+type Options struct {
+	Package string // 生成代码的包名，为空时为 main
+	// LineFile 生成的 //line 指令中的 PHP 文件路径，为空时为 ir.File.FilePath。
+	// 相对路径由 go 编译器按生成的 go 文件所在目录解析
+	LineFile string
+}
+
+// RenderFileOptions 按选项渲染文件。代码构造为 go/ast 语法树，语句的位置为其 PHP 代码所在的行，
+// 打印时在行首生成 //line 指令，go 调用栈及运行时错误中的位置指向原始 PHP 文件的行。
+// 打印结果经 go/parser 校验并由 go/format 格式化，返回的代码总是合法的 go 代码
+func RenderFileOptions(f *ir.File, opts Options) (string, error) {
+	r := &render{packageName: opts.Package, lineFile: opts.LineFile}
+	if r.packageName == "" {
+		r.packageName = "main"
+	}
+	if r.lineFile == "" {
+		r.lineFile = f.FilePath
+	}
 	return r.RenderFile(f)
 }
 
 const (
 	// packages
-	pkgDef pkgName = "github.com/heyuuu/gophp/php/def"
-	pkgAst pkgName = `github.com/heyuuu/gophp/compile/ast`
+	pkgDef  pkgName = "github.com/heyuuu/gophp/php/def"
+	pkgAst  pkgName = `github.com/heyuuu/gophp/compile/ast`
+	pkgMath pkgName = "math"
 
 	// variables
 	varFile   = "f"
@@ -29,11 +66,82 @@ const (
 type stringLit string
 
 type render struct {
-	buf       strings.Builder
-	imports   *imports
-	indent    int
-	indentStr string
-	isNewLine bool
+	imports *imports
+
+	packageName string // 生成代码的包名
+	lineFile    string // //line 指令中的 PHP 文件路径，为空时不生成
+
+	line     int                   // 已分配的虚拟行数
+	phpLine  int                   // 最近渲染的语句所在的 PHP 行号，用于保留空行
+	comments []*goast.CommentGroup // 语句前的注释及 //line 注释，按位置排序
+	result   goast.Expr            // 最近访问的表达式节点的渲染结果
+	stmts    []goast.Stmt          // 当前语句渲染生成的 go 语句
+
+	currNamespace string          // 当前命名空间
+	currClass     string          // 当前类、接口或 trait 的名称
+	currClassKind string          // 当前类声明的种类，不在类声明内时为空
+	currMethod    string          // 当前方法名
+	loops         []*loopFrame    // 当前函数内嵌套的循环及 switch，用于 break/continue
+	arrows        []*arrowFrame   // 当前函数内嵌套的箭头函数，用于收集需要绑定的外部变量
+	generator     bool            // 当前函数体内含有 yield
+	tries         []*tryFrame     // 当前函数内嵌套的 try 语句，语句块转译为闭包，离开语句块的跳转需要转换
+	gotoLabels    map[string]bool // 当前函数内 goto 语句的目标标签，未被引用的标签不生成(go 不允许未使用的标签)
+	labelNum      int
+
+	infer *infer.Result // 文件内函数体的类型推导结果
+	fn    *infer.Func   // 当前函数体的类型推导结果，顶层代码及箭头函数内为 nil
+}
+
+// 类声明的种类
+const (
+	classClass     = "class"
+	classInterface = "interface"
+	classTrait     = "trait"
+)
+
+// loopFrame 循环或 switch 语句，仅在 break/continue 跨层跳转时添加标签
+type loopFrame struct {
+	isSwitch bool
+	label    string // 标签名，未使用时为空
+}
+
+// tryFrame try 语句，记录语句块内离开语句块的 return 及 break/continue
+type tryFrame struct {
+	loopDepth int       // try 语句外层的循环及 switch 层数
+	hasReturn bool      // 语句块内有 return
+	inFinally bool      // 正在渲染 finally 语句块
+	jumps     []tryJump // 跳出语句块的 break/continue，跳转编号为下标加 1
+}
+
+// tryJump 跳出 try 语句块的 break/continue，level 为相对 try 语句所在位置的层数
+type tryJump struct {
+	kind  string
+	level int
+}
+
+// jump 返回跳转编号，相同的跳转共用编号
+func (f *tryFrame) jump(kind string, level int) int {
+	j := tryJump{kind: kind, level: level}
+	for i, exists := range f.jumps {
+		if exists == j {
+			return i + 1
+		}
+	}
+	f.jumps = append(f.jumps, j)
+	return len(f.jumps)
+}
+
+// arrowFrame 箭头函数内使用的变量，按首次出现的顺序记录
+type arrowFrame struct {
+	names []string
+	seen  map[string]bool
+}
+
+func (f *arrowFrame) add(name string) {
+	if !f.seen[name] {
+		f.seen[name] = true
+		f.names = append(f.names, name)
+	}
 }
 
 var _ ir.Visitor = (*render)(nil)
@@ -56,17 +164,23 @@ func (r *render) RenderFile(f *ir.File) (result string, resultErr error) {
 	}()
 
 	// reset
-	r.buf.Reset()
 	r.imports = newImports()
-	r.indent = 0
+	r.line = 0
+	r.phpLine = 0
+	r.comments = nil
+	r.result = nil
+	r.stmts = nil
+	r.loops = nil
+	r.arrows = nil
+	r.generator = false
+	r.tries = nil
+	r.gotoLabels = nil
+	r.labelNum = 0
+	r.infer = infer.File(f)
+	r.fn = nil
 
 	// render
-	r.file(f)
-
-	// header (header 必须执行在 file 后，因为要收集 imports 信息)
-	header := r.fileHeader()
-
-	return header + r.buf.String(), nil
+	return r.format(r.file(f))
 }
 
 func (r *render) unexpected(kind string, v any) {
@@ -78,89 +192,230 @@ func (r *render) todo(msg string) {
 	panic("todo: " + msg)
 }
 
-func (r *render) incIndent() {
-	r.indent++
-	r.indentStr = strings.Repeat("\t", r.indent)
+// format 打印语法树，打印结果由 go/parser 校验后经 go/format 格式化
+func (r *render) format(file *goast.File) (string, error) {
+	fset := gotoken.NewFileSet()
+	size := (r.line + 2) * lineWidth
+	offsets := make([]int, r.line+2)
+	for i := range offsets {
+		offsets[i] = i * lineWidth
+	}
+	fset.AddFile("", -1, size).SetLines(offsets)
+
+	var buf bytes.Buffer
+	cfg := printer.Config{Mode: printer.UseSpaces | printer.TabIndent, Tabwidth: 8}
+	if err := cfg.Fprint(&buf, fset, file); err != nil {
+		return "", fmt.Errorf("render.render.format() print error: %w", err)
+	}
+
+	fset = gotoken.NewFileSet()
+	parsed, err := parser.ParseFile(fset, "", buf.Bytes(), parser.ParseComments)
+	if err != nil {
+		return "", fmt.Errorf("render.render.format() invalid go code: %w", err)
+	}
+	buf.Reset()
+	if err := format.Node(&buf, fset, parsed); err != nil {
+		return "", fmt.Errorf("render.render.format() format error: %w", err)
+	}
+	return buf.String(), nil
 }
 
-func (r *render) decIndent() {
-	r.indent--
-	r.indentStr = strings.Repeat("\t", r.indent)
+// positions
+
+// lineWidth 每个虚拟行占用的位置数。
+// 位置间隔远大于实际输出的长度，go/printer 对无位置节点的估算不会越过下一行
+const lineWidth = 1 << 16
+
+// 生成代码的位置按输出顺序分配为虚拟行，仅用于 go/printer 排版(换行、空行)及放置注释，与 PHP 代码的行号无关。
+// 语句对应的 PHP 行号由语句前的 //line 注释记录
+func (r *render) lineStart(line int) gotoken.Pos {
+	return gotoken.Pos(1 + line*lineWidth)
 }
 
-func (r *render) indentBlock(f func()) {
-	r.incIndent()
-	f()
-	r.decIndent()
+// newLine 分配新的虚拟行，返回行首位置
+func (r *render) newLine() gotoken.Pos {
+	r.line++
+	return r.lineStart(r.line)
 }
 
-func (r *render) string(s string) {
-	for s != "" {
-		if r.isNewLine {
-			r.buf.WriteString(r.indentStr)
-			r.isNewLine = false
-		}
-		if idx := strings.IndexByte(s, '\n'); idx < 0 {
-			r.buf.WriteString(s)
-			break
-		} else {
-			r.buf.WriteString(s[:idx+1])
-			r.isNewLine = true
-			s = s[idx+1:]
-		}
+// currPos 当前虚拟行内的位置，用于不换行的节点(如左花括号、单行闭包)
+func (r *render) currPos() gotoken.Pos {
+	return r.lineStart(r.line) + lineWidth/2
+}
+
+// mapLine 分配新的虚拟行，行首添加 PHP 代码所在行的 //line 注释，返回行内代码的位置
+func (r *render) mapLine(p ast.Position) gotoken.Pos {
+	pos := r.newLine()
+	if r.lineFile != "" && p.IsValid() {
+		text := fmt.Sprintf("//line %s:%d", r.lineFile, p.Line)
+		r.comments = append(r.comments, &goast.CommentGroup{List: []*goast.Comment{{Slash: pos, Text: text}}})
 	}
+	return pos + 1
 }
 
-func (r *render) print(args ...any) {
-	for _, arg := range args {
-		switch v := arg.(type) {
-		// lit
-		case nil:
-			r.print("nil")
-		case bool:
-			if v {
-				r.string("true")
-			} else {
-				r.string("false")
+// stmtPos 分配语句的位置：保留 PHP 代码中语句间的空行，语句前的注释转换为 go 注释
+func (r *render) stmtPos(n ir.Stmt) gotoken.Pos {
+	line := n.Pos().Line
+	if r.phpLine > 0 && line > r.phpLine+1 {
+		r.line++
+	}
+	r.phpLine = line
+
+	if comments := n.Comments(); len(comments) > 0 {
+		group := &goast.CommentGroup{}
+		for _, comment := range comments {
+			text := strings.TrimRight(comment.Text, "\r\n")
+			if strings.HasPrefix(text, "#") {
+				text = "//" + text[1:]
 			}
-		case int:
-			r.string(strconv.Itoa(v))
-		case string:
-			r.string(v)
-		case pkgName:
-			r.string(r.usePkg(v))
-		case stringLit:
-			r.string(`"` + string(v) + `"`) // todo escape
-		// node
-		case ir.Node:
-			r.node(v)
-		case []ir.Stmt:
-			r.stmtList(v, true)
-		case []ir.Expr:
-			r.exprList(v)
-		case func():
-			v()
-		default:
-			r.unexpected("print", arg)
+			group.List = append(group.List, &goast.Comment{Slash: r.newLine(), Text: text})
+			// 多行注释占用多个虚拟行
+			r.line += strings.Count(text, "\n")
 		}
+		r.comments = append(r.comments, group)
+	}
+	return r.mapLine(n.Pos())
+}
+
+// setStmtPos 设置语句首个 token 的位置
+func setStmtPos(stmt goast.Stmt, pos gotoken.Pos) {
+	switch x := stmt.(type) {
+	case *goast.ExprStmt:
+		setExprPos(x.X, pos)
+	case *goast.AssignStmt:
+		setExprPos(x.Lhs[0], pos)
+	case *goast.IncDecStmt:
+		setExprPos(x.X, pos)
+	case *goast.DeclStmt:
+		x.Decl.(*goast.GenDecl).TokPos = pos
+	case *goast.ReturnStmt:
+		x.Return = pos
+	case *goast.BranchStmt:
+		x.TokPos = pos
+	case *goast.LabeledStmt:
+		x.Label.NamePos = pos
+	case *goast.IfStmt:
+		x.If = pos
+	case *goast.ForStmt:
+		x.For = pos
+	case *goast.SwitchStmt:
+		x.Switch = pos
+	case *goast.BlockStmt:
+		x.Lbrace = pos
 	}
 }
 
-func (r *render) printLine(args ...any) {
-	r.print(args...)
-	r.print("\n")
+// setExprPos 设置表达式首个 token 的位置
+func setExprPos(expr goast.Expr, pos gotoken.Pos) {
+	switch x := expr.(type) {
+	case *goast.Ident:
+		x.NamePos = pos
+	case *goast.BasicLit:
+		x.ValuePos = pos
+	case *goast.SelectorExpr:
+		setExprPos(x.X, pos)
+	case *goast.CallExpr:
+		setExprPos(x.Fun, pos)
+	case *goast.IndexExpr:
+		setExprPos(x.X, pos)
+	case *goast.BinaryExpr:
+		setExprPos(x.X, pos)
+	case *goast.ParenExpr:
+		x.Lparen = pos
+	case *goast.UnaryExpr:
+		x.OpPos = pos
+	case *goast.StarExpr:
+		x.Star = pos
+	case *goast.FuncLit:
+		x.Type.Func = pos
+	case *goast.CompositeLit:
+		if x.Type != nil {
+			setExprPos(x.Type, pos)
+		} else {
+			x.Lbrace = pos
+		}
+	}
 }
 
-func (r *render) printf(s string, args ...any) {
-	if len(args) != 0 {
-		s = fmt.Sprintf(s, args...)
+// go ast helpers
+
+func ident(name string) *goast.Ident {
+	return goast.NewIdent(name)
+}
+
+func sel(x goast.Expr, name string) goast.Expr {
+	return &goast.SelectorExpr{X: x, Sel: ident(name)}
+}
+
+func call(fun goast.Expr, args ...goast.Expr) goast.Expr {
+	return &goast.CallExpr{Fun: fun, Args: args}
+}
+
+func paren(x goast.Expr) goast.Expr {
+	return &goast.ParenExpr{X: x}
+}
+
+func exprStmt(x goast.Expr) goast.Stmt {
+	return &goast.ExprStmt{X: x}
+}
+
+func define(name string, value goast.Expr) goast.Stmt {
+	return &goast.AssignStmt{Lhs: []goast.Expr{ident(name)}, Tok: gotoken.DEFINE, Rhs: []goast.Expr{value}}
+}
+
+func assign(lhs goast.Expr, value goast.Expr) goast.Stmt {
+	return &goast.AssignStmt{Lhs: []goast.Expr{lhs}, Tok: gotoken.ASSIGN, Rhs: []goast.Expr{value}}
+}
+
+func returnStmt(results ...goast.Expr) goast.Stmt {
+	return &goast.ReturnStmt{Results: results}
+}
+
+func strLit(s string) goast.Expr {
+	return &goast.BasicLit{Kind: gotoken.STRING, Value: strconv.Quote(s)}
+}
+
+func intLit(v int) goast.Expr {
+	if v < 0 {
+		return &goast.UnaryExpr{Op: gotoken.SUB, X: &goast.BasicLit{Kind: gotoken.INT, Value: strconv.FormatUint(-uint64(v), 10)}}
+	}
+	return &goast.BasicLit{Kind: gotoken.INT, Value: strconv.Itoa(v)}
+}
+
+// float 浮点数字面量，无穷大及 NaN 由 math 包生成
+func (r *render) float(f float64) goast.Expr {
+	switch {
+	case math.IsInf(f, 1):
+		return call(r.pkgSel(pkgMath, "Inf"), intLit(1))
+	case math.IsInf(f, -1):
+		return call(r.pkgSel(pkgMath, "Inf"), intLit(-1))
+	case math.IsNaN(f):
+		return call(r.pkgSel(pkgMath, "NaN"))
+	case f == 0 && math.Signbit(f):
+		// go 常量 -0 等于 0
+		return call(r.pkgSel(pkgMath, "Copysign"), intLit(0), intLit(-1))
+	case f < 0:
+		return &goast.UnaryExpr{Op: gotoken.SUB, X: r.float(-f)}
+	default:
+		return &goast.BasicLit{Kind: gotoken.FLOAT, Value: strconv.FormatFloat(f, 'g', -1, 64)}
+	}
+}
+
+// funcLit 函数字面量，result 为 nil 时没有返回值
+func funcLit(params *goast.FieldList, result goast.Expr, body *goast.BlockStmt) *goast.FuncLit {
+	if params == nil {
+		params = &goast.FieldList{}
+	}
+	typ := &goast.FuncType{Params: params}
+	if result != nil {
+		typ.Results = &goast.FieldList{List: []*goast.Field{{Type: result}}}
 	}
-	r.string(s)
+	return &goast.FuncLit{Type: typ, Body: body}
 }
 
-func (r *render) linef(format string, args ...any) {
-	r.printf(format, args...)
-	r.printf("\n")
+// definer 参数列表 (d def.<kind>)
+func (r *render) definer(kind string) *goast.FieldList {
+	return &goast.FieldList{List: []*goast.Field{{Names: []*goast.Ident{ident(varDef)}, Type: r.pkgSel(pkgDef, kind)}}}
 }
 
 // imports
@@ -169,182 +424,537 @@ func (r *render) usePkg(pkg pkgName) string {
 	return r.imports.getOrAdd(pkg)
 }
 
-func (r *render) fileHeader() string {
-	var buf strings.Builder
-
-	buf.WriteString("package main\n\n")
+// pkgSel 引用包内的成员，如 def.Val
+func (r *render) pkgSel(pkg pkgName, name string) goast.Expr {
+	return sel(ident(r.usePkg(pkg)), name)
+}
 
-	if r.imports.Len() > 0 {
-		buf.WriteString("import (\n")
-		r.imports.SortedEach(func(pkg pkgName, alias string) {
-			if alias == pkg.DefaultAlias() {
-				_, _ = fmt.Fprintf(&buf, "\t\"%s\"\n", pkg)
-			} else {
-				_, _ = fmt.Fprintf(&buf, "\t%s \"%s\"\n", pkg, alias)
-			}
-		})
-		buf.WriteString(")\n\n")
-	}
+func (r *render) pkgCall(pkg pkgName, method string, args ...any) goast.Expr {
+	return &goast.CallExpr{Fun: r.pkgSel(pkg, method), Args: r.goExprs(args)}
+}
 
-	return buf.String()
+// importDecl 导入声明，须在渲染后调用以收集使用的包
+func (r *render) importDecl() *goast.GenDecl {
+	decl := &goast.GenDecl{Tok: gotoken.IMPORT}
+	r.imports.SortedEach(func(pkg pkgName, alias string) {
+		spec := &goast.ImportSpec{Path: strLit(string(pkg)).(*goast.BasicLit)}
+		if alias != pkg.DefaultAlias() {
+			spec.Name = ident(alias)
+		}
+		decl.Specs = append(decl.Specs, spec)
+	})
+	return decl
 }
 
 // file
-func (r *render) file(f *ir.File) {
-	// check empty
-	if len(f.Namespaces) == 0 {
-		return
-	}
+func (r *render) file(f *ir.File) *goast.File {
+	file := &goast.File{Name: ident(r.packageName)}
 
-	r.print("func init() {\n")
-	r.indentBlock(func() {
-		r.print(varFile, ` := `)
-		r.pPkgCall(pkgDef, "NewFile", stringLit(f.FilePath), f.StrictTypes)
+	// check empty
+	if len(f.Namespaces) > 0 {
+		body := []goast.Stmt{define(varFile, r.pkgCall(pkgDef, "NewFile", stringLit(f.FilePath), f.StrictTypes))}
+		if r.lineFile != "" {
+			// 位于所有 //line 指令之前，运行时按生成的 go 文件所在目录解析 //line 指令中的路径
+			body = append(body, exprStmt(call(sel(ident(varFile), "SetLineFile"), strLit(r.lineFile))))
+		}
 		for _, namespace := range f.Namespaces {
-			r.namespace(namespace)
+			body = append(body, r.namespace(namespace))
 		}
-	})
-	r.print("}\n")
-}
+		body = append(body, exprStmt(call(sel(ident(varFile), "Register"))))
 
-func (r *render) namespace(n *ir.NamespaceStmt) {
-	r.printf("\n")
+		file.Decls = append(file.Decls, &goast.FuncDecl{
+			Name: ident("init"),
+			Type: &goast.FuncType{Params: &goast.FieldList{}},
+			Body: &goast.BlockStmt{List: body},
+		})
+	}
 
+	// imports 须在渲染后生成
+	if r.imports.Len() > 0 {
+		file.Decls = append([]goast.Decl{r.importDecl()}, file.Decls...)
+	}
+	file.Comments = r.comments
+	return file
+}
+
+func (r *render) namespace(n *ir.NamespaceStmt) goast.Stmt {
 	var nsName string
 	if n.Name != nil {
 		nsName = n.Name.ToString()
 	}
+	r.currNamespace = nsName
 
-	r.print(varFile, ".TopFn(", stringLit(nsName), ", func(", varDef, " ", pkgDef, ".TopDefiner) ", pkgDef, ".Val {\n")
-	r.stmtList(n.Stmts, true)
-	r.print("\treturn nil\n")
-	r.print("})\n")
+	r.gotoLabels = gotoLabels(n.Stmts)
+	pos := r.newLine()
+	body := r.block(hoistDecls(n.Stmts))
+	r.returnNil(body)
+	stmt := exprStmt(call(sel(ident(varFile), "TopFn"), strLit(nsName), funcLit(r.definer("TopDefiner"), r.pkgSel(pkgDef, "Val"), body)))
+	setStmtPos(stmt, pos)
+	return stmt
 }
 
 // nodes
 
-func (r *render) node(n ir.Node) {
+// node 渲染节点为 go 表达式，nil 渲染为 nil
+func (r *render) node(n ir.Node) goast.Expr {
 	if n == nil {
-		r.print("nil")
-		return
+		return ident("nil")
 	}
 
 	err := ir.Visit(r, n)
 	if ir.IsUnexpectedError(err) {
 		r.unexpected("node", n)
 	}
+	return r.takeResult(n)
 }
 
-func (r *render) typeHint(n ir.TypeHint) {
-	ir.VisitTypeHint(r, n)
-}
-
-func (r *render) stmtComments(n ir.Stmt) {
-	for _, comment := range n.Comments() {
-		r.print(comment.Text, "\n")
+func (r *render) expr(n ir.Expr) goast.Expr {
+	if n == nil {
+		return ident("nil")
 	}
-}
 
-func (r *render) stmt(n ir.Stmt) {
-	r.stmtComments(n)
-	err := ir.VisitStmt(r, n)
+	err := ir.VisitExpr(r, n)
 	if ir.IsUnexpectedError(err) {
 		r.unexpected("expr", n)
 	}
+	return r.takeResult(n)
 }
 
-func (r *render) stmtList(stmts []ir.Stmt, indent bool) {
-	if indent {
-		r.incIndent()
+func (r *render) takeResult(n ir.Node) goast.Expr {
+	result := r.result
+	if result == nil {
+		r.unexpected("expr", n)
 	}
+	r.result = nil
+	return result
+}
+
+// hoistDecls 顶层函数声明及无依赖的类声明提前，与 PHP 一致可在声明前使用。
+// 有父类、接口或使用 trait 的类依赖其它声明(可能来自之后 include 的文件)，保留在原位置
+func hoistDecls(stmts []ir.Stmt) []ir.Stmt {
+	var decls, others []ir.Stmt
 	for _, stmt := range stmts {
-		r.stmt(stmt)
-		r.printf("\n")
+		if isHoistable(stmt) {
+			decls = append(decls, stmt)
+		} else {
+			others = append(others, stmt)
+		}
 	}
-	if indent {
-		r.decIndent()
+	return append(decls, others...)
+}
+
+func isHoistable(stmt ir.Stmt) bool {
+	switch x := stmt.(type) {
+	case *ir.FunctionStmt:
+		return true
+	case *ir.ClassStmt:
+		return x.Extends == nil && len(x.Implements) == 0 && !usesTraits(x.Stmts)
+	case *ir.InterfaceStmt:
+		return len(x.Extends) == 0
+	case *ir.TraitStmt:
+		return !usesTraits(x.Stmts)
+	default:
+		return false
 	}
 }
 
-func (r *render) expr(n ir.Expr) {
-	if n == nil {
-		r.print("nil")
-		return
+func usesTraits(stmts []ir.Stmt) bool {
+	for _, stmt := range stmts {
+		if _, ok := stmt.(*ir.TraitUseStmt); ok {
+			return true
+		}
 	}
+	return false
+}
 
-	err := ir.VisitExpr(r, n)
+// stmt 渲染语句，生成的 go 语句位于新的一行，行首为 PHP 语句所在行的 //line 注释
+func (r *render) stmt(n ir.Stmt) []goast.Stmt {
+	pos := r.stmtPos(n)
+
+	prev := r.stmts
+	r.stmts = nil
+	err := ir.VisitStmt(r, n)
 	if ir.IsUnexpectedError(err) {
-		r.unexpected("expr", n)
+		r.unexpected("stmt", n)
+	}
+	stmts := r.stmts
+	r.stmts = prev
+	if len(stmts) == 0 && len(r.comments) > 0 && r.comments[len(r.comments)-1].Pos() == pos-1 {
+		// 未生成代码的语句(如仅有注释的空语句)不需要 //line 注释
+		r.comments = r.comments[:len(r.comments)-1]
+	}
+	if end := n.End(); end.IsValid() {
+		r.phpLine = end.Line
+	}
+
+	for _, stmt := range stmts {
+		setStmtPos(stmt, pos)
+	}
+	return stmts
+}
+
+// emit 添加当前语句生成的 go 语句
+func (r *render) emit(stmts ...goast.Stmt) {
+	r.stmts = append(r.stmts, stmts...)
+}
+
+// stmtList 渲染语句列表，标签语句与其后的语句合并为带标签的语句
+func (r *render) stmtList(stmts []ir.Stmt) []goast.Stmt {
+	var list []goast.Stmt
+	for _, stmt := range stmts {
+		for _, s := range r.stmt(stmt) {
+			if n := len(list); n > 0 {
+				if labeled, ok := list[n-1].(*goast.LabeledStmt); ok && isImplicitEmpty(labeled.Stmt) {
+					labeled.Stmt = s
+					continue
+				}
+			}
+			list = append(list, s)
+		}
 	}
+	return list
+}
+
+func isImplicitEmpty(stmt goast.Stmt) bool {
+	empty, ok := stmt.(*goast.EmptyStmt)
+	return ok && empty.Implicit
 }
 
-func (r *render) exprList(exprs []ir.Expr) {
-	pList(r, exprs, ", ")
+// returnNil 语句块末尾添加 return nil，位于语句块内的注释之后
+func (r *render) returnNil(body *goast.BlockStmt) {
+	ret := &goast.ReturnStmt{Return: body.Rbrace, Results: []goast.Expr{ident("nil")}}
+	body.List = append(body.List, ret)
+	body.Rbrace = r.newLine()
+}
+
+// block 渲染语句块，左花括号位于当前行，右花括号位于新的一行
+func (r *render) block(stmts []ir.Stmt) *goast.BlockStmt {
+	lbrace := r.currPos()
+	list := r.stmtList(stmts)
+	return &goast.BlockStmt{Lbrace: lbrace, List: list, Rbrace: r.newLine()}
+}
+
+// blockBefore 渲染语句块，右花括号所在行映射到之后的 PHP 代码 next(如 elseif 的条件)
+func (r *render) blockBefore(stmts []ir.Stmt, next ast.Position) *goast.BlockStmt {
+	lbrace := r.currPos()
+	list := r.stmtList(stmts)
+	return &goast.BlockStmt{Lbrace: lbrace, List: list, Rbrace: r.mapLine(next)}
 }
 
 // misc helpers
-func (r *render) pArgs(args ...any) {
-	r.print("(")
-	pList(r, args, ", ")
-	r.print(")")
+
+// goExpr 将参数转换为 go 表达式，参数为 go 字面量、PHP 节点或 go 表达式
+func (r *render) goExpr(v any) goast.Expr {
+	switch x := v.(type) {
+	case nil:
+		return ident("nil")
+	case bool:
+		return ident(strconv.FormatBool(x))
+	case int:
+		return intLit(x)
+	case stringLit:
+		return strLit(string(x))
+	case goast.Expr:
+		return x
+	case ir.Node:
+		return r.node(x)
+	}
+	r.unexpected("goExpr", v)
+	return nil
+}
+
+// goExprs 将参数列表转换为 go 表达式列表，节点列表展开为多个参数
+func (r *render) goExprs(args []any) []goast.Expr {
+	var exprs []goast.Expr
+	for _, arg := range args {
+		switch x := arg.(type) {
+		case []ir.Expr:
+			for _, e := range x {
+				exprs = append(exprs, r.expr(e))
+			}
+		case []*ir.Arg:
+			for _, a := range x {
+				exprs = append(exprs, r.node(a))
+			}
+		case []goast.Expr:
+			exprs = append(exprs, x...)
+		default:
+			exprs = append(exprs, r.goExpr(arg))
+		}
+	}
+	return exprs
+}
+
+// defCall 调用 d 的方法，如 d.Var("a")
+func (r *render) defCall(method string, args ...any) goast.Expr {
+	return &goast.CallExpr{Fun: sel(ident(varDef), method), Args: r.goExprs(args)}
+}
+
+func (r *render) asBool(arg any) goast.Expr {
+	return r.defCall("IsTrue", arg)
+}
+
+// lazy 延迟求值的表达式 func() def.Val { return n }
+func (r *render) lazy(n ir.Expr) goast.Expr {
+	// 起始位置有效时 go/printer 才会将较短的闭包打印为一行
+	pos := r.currPos()
+	lit := funcLit(nil, r.pkgSel(pkgDef, "Val"), &goast.BlockStmt{List: []goast.Stmt{returnStmt(r.expr(n))}})
+	lit.Type.Func = pos
+	return lit
+}
+
+func (r *render) arrayItems(items []*ir.ArrayItemExpr) []goast.Expr {
+	var exprs []goast.Expr
+	for _, item := range items {
+		if item == nil {
+			exprs = append(exprs, ident("nil"))
+		} else {
+			exprs = append(exprs, r.node(item))
+		}
+	}
+	return exprs
+}
+
+// sliceLit 切片字面量 []elt{...}
+func sliceLit(elt goast.Expr, elts []goast.Expr) goast.Expr {
+	return &goast.CompositeLit{Type: &goast.ArrayType{Elt: elt}, Elts: elts}
+}
+
+// params 参数列表 []def.Param{...}，为空时为 nil
+func (r *render) params(params []*ir.Param) goast.Expr {
+	if len(params) == 0 {
+		return ident("nil")
+	}
+	var elts []goast.Expr
+	for _, param := range params {
+		elts = append(elts, r.node(param))
+	}
+	return sliceLit(r.pkgSel(pkgDef, "Param"), elts)
 }
 
-func (r *render) pPkgCall(pkg pkgName, method string, args ...any) {
-	r.print(pkg, ".", method)
-	r.pArgs(args...)
+// declName 声明语句的完整名称
+func declName(name *ir.Ident, namespacedName *ir.Name) string {
+	if namespacedName != nil {
+		return namespacedName.ToString()
+	}
+	return name.Name
+}
+
+// flags 修饰符，如 ast.FlagPublic|ast.FlagStatic
+func (r *render) flags(flags ast.Flags) goast.Expr {
+	names := []struct {
+		flag ast.Flags
+		name string
+	}{
+		{ast.FlagPublic, "FlagPublic"},
+		{ast.FlagProtected, "FlagProtected"},
+		{ast.FlagPrivate, "FlagPrivate"},
+		{ast.FlagStatic, "FlagStatic"},
+		{ast.FlagAbstract, "FlagAbstract"},
+		{ast.FlagFinal, "FlagFinal"},
+		{ast.FlagReadonly, "FlagReadonly"},
+	}
+
+	var expr goast.Expr
+	for _, item := range names {
+		if flags&item.flag == 0 {
+			continue
+		}
+		flag := r.pkgSel(pkgAst, item.name)
+		if expr == nil {
+			expr = flag
+		} else {
+			expr = &goast.BinaryExpr{X: expr, Op: gotoken.OR, Y: flag}
+		}
+	}
+	if expr == nil {
+		return intLit(0)
+	}
+	return expr
 }
 
-func (r *render) pVarCall(variable string, method string, args ...any) {
-	r.print(variable, ".", method)
-	r.pArgs(args...)
+// names 解析后的类名列表 []string{...}，为空时为 nil
+func (r *render) names(names []*ir.Name) goast.Expr {
+	if len(names) == 0 {
+		return ident("nil")
+	}
+	var elts []goast.Expr
+	for _, name := range names {
+		elts = append(elts, strLit(name.ResolvedCodeString()))
+	}
+	return sliceLit(ident("string"), elts)
 }
 
-func (r *render) pDefCall(method string, args ...any) {
-	r.print(varDef, ".", method)
-	r.pArgs(args...)
+// classBody 类声明体 func(d def.ClassDefiner) {...}
+func (r *render) classBody(kind string, name string, stmts []ir.Stmt) goast.Expr {
+	prevClass, prevKind := r.currClass, r.currClassKind
+	prevFn := r.fn
+	r.currClass, r.currClassKind, r.fn = name, kind, nil
+	defer func() { r.currClass, r.currClassKind, r.fn = prevClass, prevKind, prevFn }()
+
+	return funcLit(r.definer("ClassDefiner"), nil, r.block(stmts))
 }
 
-func (r *render) pAsBool(arg any) {
-	r.pDefCall("IsTrue", arg)
+// funcBody 函数体，break/continue 不能跨越函数。类型已证明的变量在函数体开头声明为原生局部变量
+func (r *render) funcBody(fn ir.FunctionLike, stmts []ir.Stmt) goast.Expr {
+	loops, arrows, tries, labels, prevFn := r.loops, r.arrows, r.tries, r.gotoLabels, r.fn
+	r.loops, r.arrows, r.tries, r.gotoLabels, r.fn = nil, nil, nil, gotoLabels(stmts), r.infer.Func(fn)
+	defer func() { r.loops, r.arrows, r.tries, r.gotoLabels, r.fn = loops, arrows, tries, labels, prevFn }()
+
+	return r.generatorBody(func() goast.Expr {
+		locals := r.locals()
+		body := r.block(stmts)
+		body.List = append(locals, body.List...)
+		if len(stmts) == 0 {
+			r.returnNil(body)
+		} else if _, ok := stmts[len(stmts)-1].(*ir.ReturnStmt); !ok {
+			r.returnNil(body)
+		}
+		return funcLit(r.definer("Definer"), r.pkgSel(pkgDef, "Val"), body)
+	})
 }
 
-func (r *render) pAsStr(arg any, stage def.Stage) {
-	r.pDefCall("AsStr", arg, func() {
-		r.print(pkgDef, ".", stage.String())
+// gotoLabels 收集函数体内 goto 语句的目标标签，不含嵌套的函数及闭包
+func gotoLabels(stmts []ir.Stmt) map[string]bool {
+	labels := map[string]bool{}
+	ir.InspectList(stmts, func(n ir.Node) bool {
+		switch x := n.(type) {
+		case ir.FunctionLike:
+			return false
+		case *ir.GotoStmt:
+			labels[x.Name.Name] = true
+		}
+		return true
 	})
+	return labels
+}
+
+// generatorBody 渲染函数体，函数体内含有 yield 时为 d.Generator(body)
+func (r *render) generatorBody(f func() goast.Expr) goast.Expr {
+	generator := r.generator
+	r.generator = false
+	defer func() { r.generator = generator }()
+
+	body := f()
+	if r.generator {
+		return r.defCall("Generator", body)
+	}
+	return body
 }
 
-func (r *render) pIdentOrExprAsStr(n ir.Node, stage def.Stage, expectedType string) {
+// loop 渲染循环或 switch 语句，语句内跨层的 break/continue 使用时为语句添加标签
+func (r *render) loop(isSwitch bool, f func() goast.Stmt) goast.Stmt {
+	frame := &loopFrame{isSwitch: isSwitch}
+	r.loops = append(r.loops, frame)
+	stmt := f()
+	r.loops = r.loops[:len(r.loops)-1]
+
+	if frame.label != "" {
+		return &goast.LabeledStmt{Label: ident(frame.label), Stmt: stmt}
+	}
+	return stmt
+}
+
+// jump 渲染 break/continue 语句，num 为跳出的层数
+func (r *render) jump(kind string, num ir.Expr) goast.Stmt {
+	level := 1
+	if num != nil {
+		lit, ok := num.(*ir.IntLit)
+		if !ok || lit.Value < 1 {
+			panic(fmt.Errorf("'%s' operator accepts only positive integers", kind))
+		}
+		level = lit.Value
+	}
+	if level > len(r.loops) {
+		panic(fmt.Errorf("Cannot '%s' %d level%s", kind, level, lang.Cond(level > 1, "s", "")))
+	}
+	return r.jumpLevel(kind, level)
+}
+
+func (r *render) jumpLevel(kind string, level int) goast.Stmt {
+	// 跳出 try 语句块时由语句块返回跳转编号，在 try 语句后执行实际的跳转
+	if n := len(r.tries); n > 0 {
+		frame := r.tries[n-1]
+		if outer := len(r.loops) - frame.loopDepth; level > outer {
+			if frame.inFinally {
+				panic(fmt.Errorf("jump out of a finally block is disallowed"))
+			}
+			return returnStmt(r.defCall("Jump", frame.jump(kind, level-outer)))
+		}
+	}
+
+	target := r.loops[len(r.loops)-level]
+	// switch 中的 continue 等同于 break
+	if target.isSwitch {
+		kind = "break"
+	}
+	tok := gotoken.BREAK
+	if kind == "continue" {
+		tok = gotoken.CONTINUE
+	}
+
+	inner := level == 1
+	if kind == "continue" && !inner {
+		// go 的 continue 作用于最内层循环，中间仅有 switch 时无需标签
+		inner = true
+		for _, frame := range r.loops[len(r.loops)-level+1:] {
+			if !frame.isSwitch {
+				inner = false
+				break
+			}
+		}
+	}
+	if inner {
+		return &goast.BranchStmt{Tok: tok}
+	}
+
+	if target.label == "" {
+		r.labelNum++
+		target.label = "loop" + strconv.Itoa(r.labelNum)
+	}
+	return &goast.BranchStmt{Tok: tok, Label: ident(target.label)}
+}
+
+// endsWithJump 语句列表是否以跳转语句结束，用于判断 case 是否需要 fallthrough
+func endsWithJump(stmts []ir.Stmt) bool {
+	if len(stmts) == 0 {
+		return false
+	}
+	switch stmts[len(stmts)-1].(type) {
+	case *ir.BreakStmt, *ir.ContinueStmt, *ir.ReturnStmt, *ir.GotoStmt:
+		return true
+	}
+	return false
+}
+
+func (r *render) asStr(arg any, stage def.Stage) goast.Expr {
+	return r.defCall("AsStr", arg, r.pkgSel(pkgDef, stage.String()))
+}
+
+func (r *render) identOrExprAsStr(n ir.Node, stage def.Stage, expectedType string) goast.Expr {
 	switch x := n.(type) {
 	case *ir.Ident:
-		r.print(stringLit(x.Name))
+		return strLit(x.Name)
 	case ir.Expr:
-		r.pAsStr(x, stage)
+		return r.asStr(x, stage)
 	default:
 		r.unexpected(expectedType, n)
+		return nil
 	}
 }
 
-func (r *render) pNameOrExprAsStr(n ir.Node, stage def.Stage, expectedType string) {
+func (r *render) nameOrExprAsStr(n ir.Node, stage def.Stage, expectedType string) goast.Expr {
 	switch x := n.(type) {
 	case *ir.Name:
-		r.print(stringLit(x.ToCodeString()))
+		return strLit(x.ResolvedCodeString())
 	case ir.Expr:
-		r.pAsStr(x, stage)
+		return r.asStr(x, stage)
 	default:
 		r.unexpected(expectedType, n)
+		return nil
 	}
 }
 
-func (r *render) pClassName(n ir.Node) {
-	r.pNameOrExprAsStr(n, def.StageClassName, "className")
-}
-
-func pList[T any](r *render, nodes []T, sep string) {
-	for i, node := range nodes {
-		if i != 0 {
-			r.print(sep)
-		}
-		r.print(node)
-	}
+func (r *render) className(n ir.Node) goast.Expr {
+	return r.nameOrExprAsStr(n, def.StageClassName, "className")
 }
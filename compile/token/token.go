@@ -0,0 +1,412 @@
+package token
+
+import "strings"
+
+// Token PHP 词法单元类型。
+// 单字符 token (如 `;`、`(`) 直接使用其字符值，其他 token 从 256 开始编号，命名与 PHP 的 T_* 常量对齐(去掉 `T_` 前缀)。
+type Token int
+
+const (
+	EOF Token = 0
+
+	LNUMBER              Token = iota + 255 // 123, 012, 0x1ac ...
+	DNUMBER                                 // 1.0, 1e3 ...
+	STRING                                  // identifier
+	NAME_FULLY_QUALIFIED                    // \Foo\Bar
+	NAME_RELATIVE                           // namespace\Foo
+	NAME_QUALIFIED                          // Foo\Bar
+	VARIABLE                                // $foo
+	INLINE_HTML
+	ENCAPSED_AND_WHITESPACE  // " $a"
+	CONSTANT_ENCAPSED_STRING // "foo" or 'bar'
+	STRING_VARNAME           // "${a"
+	NUM_STRING               // "$a[0]"
+
+	INCLUDE       // include
+	INCLUDE_ONCE  // include_once
+	EVAL          // eval
+	REQUIRE       // require
+	REQUIRE_ONCE  // require_once
+	LOGICAL_OR    // or
+	LOGICAL_XOR   // xor
+	LOGICAL_AND   // and
+	PRINT         // print
+	YIELD         // yield
+	YIELD_FROM    // yield from
+	INSTANCEOF    // instanceof
+	NEW           // new
+	CLONE         // clone
+	EXIT          // exit or die
+	IF            // if
+	ELSEIF        // elseif
+	ELSE          // else
+	ENDIF         // endif
+	ECHO          // echo
+	DO            // do
+	WHILE         // while
+	ENDWHILE      // endwhile
+	FOR           // for
+	ENDFOR        // endfor
+	FOREACH       // foreach
+	ENDFOREACH    // endforeach
+	DECLARE       // declare
+	ENDDECLARE    // enddeclare
+	AS            // as
+	SWITCH        // switch
+	ENDSWITCH     // endswitch
+	CASE          // case
+	DEFAULT       // default
+	MATCH         // match
+	BREAK         // break
+	CONTINUE      // continue
+	GOTO          // goto
+	FUNCTION      // function
+	FN            // fn
+	CONST         // const
+	RETURN        // return
+	TRY           // try
+	CATCH         // catch
+	FINALLY       // finally
+	THROW         // throw
+	USE           // use
+	INSTEADOF     // insteadof
+	GLOBAL        // global
+	STATIC        // static
+	ABSTRACT      // abstract
+	FINAL         // final
+	PRIVATE       // private
+	PROTECTED     // protected
+	PUBLIC        // public
+	READONLY      // readonly
+	VAR           // var
+	UNSET         // unset
+	ISSET         // isset
+	EMPTY         // empty
+	HALT_COMPILER // __halt_compiler
+	CLASS         // class
+	TRAIT         // trait
+	INTERFACE     // interface
+	ENUM          // enum
+	EXTENDS       // extends
+	IMPLEMENTS    // implements
+	NAMESPACE     // namespace
+	LIST          // list
+	ARRAY         // array
+	CALLABLE      // callable
+	LINE          // __LINE__
+	FILE          // __FILE__
+	DIR           // __DIR__
+	CLASS_C       // __CLASS__
+	TRAIT_C       // __TRAIT__
+	METHOD_C      // __METHOD__
+	FUNC_C        // __FUNCTION__
+	NS_C          // __NAMESPACE__
+
+	ATTRIBUTE                // #[
+	PLUS_EQUAL               // +=
+	MINUS_EQUAL              // -=
+	MUL_EQUAL                // *=
+	DIV_EQUAL                // /=
+	CONCAT_EQUAL             // .=
+	MOD_EQUAL                // %=
+	AND_EQUAL                // &=
+	OR_EQUAL                 // |=
+	XOR_EQUAL                // ^=
+	SL_EQUAL                 // <<=
+	SR_EQUAL                 // >>=
+	COALESCE_EQUAL           // ??=
+	BOOLEAN_OR               // ||
+	BOOLEAN_AND              // &&
+	IS_EQUAL                 // ==
+	IS_NOT_EQUAL             // != or <>
+	IS_IDENTICAL             // ===
+	IS_NOT_IDENTICAL         // !==
+	IS_SMALLER_OR_EQUAL      // <=
+	IS_GREATER_OR_EQUAL      // >=
+	SPACESHIP                // <=>
+	SL                       // <<
+	SR                       // >>
+	INC                      // ++
+	DEC                      // --
+	INT_CAST                 // (int)
+	DOUBLE_CAST              // (double)
+	STRING_CAST              // (string)
+	ARRAY_CAST               // (array)
+	OBJECT_CAST              // (object)
+	BOOL_CAST                // (bool)
+	UNSET_CAST               // (unset)
+	OBJECT_OPERATOR          // ->
+	NULLSAFE_OBJECT_OPERATOR // ?->
+	DOUBLE_ARROW             // =>
+	COMMENT                  // // or # or /* */
+	DOC_COMMENT              // /** */
+	OPEN_TAG                 // <?php
+	OPEN_TAG_WITH_ECHO       // <?=
+	CLOSE_TAG                // ?>
+	WHITESPACE
+	START_HEREDOC            // <<<"XX"
+	END_HEREDOC              // XX
+	DOLLAR_OPEN_CURLY_BRACES // ${
+	CURLY_OPEN               // {$
+	PAAMAYIM_NEKUDOTAYIM     // ::
+	NS_SEPARATOR             // \
+	ELLIPSIS                 // ...
+	COALESCE                 // ??
+	POW                      // **
+	POW_EQUAL                // **=
+	BAD_CHARACTER
+)
+
+var tokenNames = map[Token]string{
+	EOF:                      "EOF",
+	LNUMBER:                  "T_LNUMBER",
+	DNUMBER:                  "T_DNUMBER",
+	STRING:                   "T_STRING",
+	NAME_FULLY_QUALIFIED:     "T_NAME_FULLY_QUALIFIED",
+	NAME_RELATIVE:            "T_NAME_RELATIVE",
+	NAME_QUALIFIED:           "T_NAME_QUALIFIED",
+	VARIABLE:                 "T_VARIABLE",
+	INLINE_HTML:              "T_INLINE_HTML",
+	ENCAPSED_AND_WHITESPACE:  "T_ENCAPSED_AND_WHITESPACE",
+	CONSTANT_ENCAPSED_STRING: "T_CONSTANT_ENCAPSED_STRING",
+	STRING_VARNAME:           "T_STRING_VARNAME",
+	NUM_STRING:               "T_NUM_STRING",
+	INCLUDE:                  "T_INCLUDE",
+	INCLUDE_ONCE:             "T_INCLUDE_ONCE",
+	EVAL:                     "T_EVAL",
+	REQUIRE:                  "T_REQUIRE",
+	REQUIRE_ONCE:             "T_REQUIRE_ONCE",
+	LOGICAL_OR:               "T_LOGICAL_OR",
+	LOGICAL_XOR:              "T_LOGICAL_XOR",
+	LOGICAL_AND:              "T_LOGICAL_AND",
+	PRINT:                    "T_PRINT",
+	YIELD:                    "T_YIELD",
+	YIELD_FROM:               "T_YIELD_FROM",
+	INSTANCEOF:               "T_INSTANCEOF",
+	NEW:                      "T_NEW",
+	CLONE:                    "T_CLONE",
+	EXIT:                     "T_EXIT",
+	IF:                       "T_IF",
+	ELSEIF:                   "T_ELSEIF",
+	ELSE:                     "T_ELSE",
+	ENDIF:                    "T_ENDIF",
+	ECHO:                     "T_ECHO",
+	DO:                       "T_DO",
+	WHILE:                    "T_WHILE",
+	ENDWHILE:                 "T_ENDWHILE",
+	FOR:                      "T_FOR",
+	ENDFOR:                   "T_ENDFOR",
+	FOREACH:                  "T_FOREACH",
+	ENDFOREACH:               "T_ENDFOREACH",
+	DECLARE:                  "T_DECLARE",
+	ENDDECLARE:               "T_ENDDECLARE",
+	AS:                       "T_AS",
+	SWITCH:                   "T_SWITCH",
+	ENDSWITCH:                "T_ENDSWITCH",
+	CASE:                     "T_CASE",
+	DEFAULT:                  "T_DEFAULT",
+	MATCH:                    "T_MATCH",
+	BREAK:                    "T_BREAK",
+	CONTINUE:                 "T_CONTINUE",
+	GOTO:                     "T_GOTO",
+	FUNCTION:                 "T_FUNCTION",
+	FN:                       "T_FN",
+	CONST:                    "T_CONST",
+	RETURN:                   "T_RETURN",
+	TRY:                      "T_TRY",
+	CATCH:                    "T_CATCH",
+	FINALLY:                  "T_FINALLY",
+	THROW:                    "T_THROW",
+	USE:                      "T_USE",
+	INSTEADOF:                "T_INSTEADOF",
+	GLOBAL:                   "T_GLOBAL",
+	STATIC:                   "T_STATIC",
+	ABSTRACT:                 "T_ABSTRACT",
+	FINAL:                    "T_FINAL",
+	PRIVATE:                  "T_PRIVATE",
+	PROTECTED:                "T_PROTECTED",
+	PUBLIC:                   "T_PUBLIC",
+	READONLY:                 "T_READONLY",
+	VAR:                      "T_VAR",
+	UNSET:                    "T_UNSET",
+	ISSET:                    "T_ISSET",
+	EMPTY:                    "T_EMPTY",
+	HALT_COMPILER:            "T_HALT_COMPILER",
+	CLASS:                    "T_CLASS",
+	TRAIT:                    "T_TRAIT",
+	INTERFACE:                "T_INTERFACE",
+	ENUM:                     "T_ENUM",
+	EXTENDS:                  "T_EXTENDS",
+	IMPLEMENTS:               "T_IMPLEMENTS",
+	NAMESPACE:                "T_NAMESPACE",
+	LIST:                     "T_LIST",
+	ARRAY:                    "T_ARRAY",
+	CALLABLE:                 "T_CALLABLE",
+	LINE:                     "T_LINE",
+	FILE:                     "T_FILE",
+	DIR:                      "T_DIR",
+	CLASS_C:                  "T_CLASS_C",
+	TRAIT_C:                  "T_TRAIT_C",
+	METHOD_C:                 "T_METHOD_C",
+	FUNC_C:                   "T_FUNC_C",
+	NS_C:                     "T_NS_C",
+	ATTRIBUTE:                "T_ATTRIBUTE",
+	PLUS_EQUAL:               "T_PLUS_EQUAL",
+	MINUS_EQUAL:              "T_MINUS_EQUAL",
+	MUL_EQUAL:                "T_MUL_EQUAL",
+	DIV_EQUAL:                "T_DIV_EQUAL",
+	CONCAT_EQUAL:             "T_CONCAT_EQUAL",
+	MOD_EQUAL:                "T_MOD_EQUAL",
+	AND_EQUAL:                "T_AND_EQUAL",
+	OR_EQUAL:                 "T_OR_EQUAL",
+	XOR_EQUAL:                "T_XOR_EQUAL",
+	SL_EQUAL:                 "T_SL_EQUAL",
+	SR_EQUAL:                 "T_SR_EQUAL",
+	COALESCE_EQUAL:           "T_COALESCE_EQUAL",
+	BOOLEAN_OR:               "T_BOOLEAN_OR",
+	BOOLEAN_AND:              "T_BOOLEAN_AND",
+	IS_EQUAL:                 "T_IS_EQUAL",
+	IS_NOT_EQUAL:             "T_IS_NOT_EQUAL",
+	IS_IDENTICAL:             "T_IS_IDENTICAL",
+	IS_NOT_IDENTICAL:         "T_IS_NOT_IDENTICAL",
+	IS_SMALLER_OR_EQUAL:      "T_IS_SMALLER_OR_EQUAL",
+	IS_GREATER_OR_EQUAL:      "T_IS_GREATER_OR_EQUAL",
+	SPACESHIP:                "T_SPACESHIP",
+	SL:                       "T_SL",
+	SR:                       "T_SR",
+	INC:                      "T_INC",
+	DEC:                      "T_DEC",
+	INT_CAST:                 "T_INT_CAST",
+	DOUBLE_CAST:              "T_DOUBLE_CAST",
+	STRING_CAST:              "T_STRING_CAST",
+	ARRAY_CAST:               "T_ARRAY_CAST",
+	OBJECT_CAST:              "T_OBJECT_CAST",
+	BOOL_CAST:                "T_BOOL_CAST",
+	UNSET_CAST:               "T_UNSET_CAST",
+	OBJECT_OPERATOR:          "T_OBJECT_OPERATOR",
+	NULLSAFE_OBJECT_OPERATOR: "T_NULLSAFE_OBJECT_OPERATOR",
+	DOUBLE_ARROW:             "T_DOUBLE_ARROW",
+	COMMENT:                  "T_COMMENT",
+	DOC_COMMENT:              "T_DOC_COMMENT",
+	OPEN_TAG:                 "T_OPEN_TAG",
+	OPEN_TAG_WITH_ECHO:       "T_OPEN_TAG_WITH_ECHO",
+	CLOSE_TAG:                "T_CLOSE_TAG",
+	WHITESPACE:               "T_WHITESPACE",
+	START_HEREDOC:            "T_START_HEREDOC",
+	END_HEREDOC:              "T_END_HEREDOC",
+	DOLLAR_OPEN_CURLY_BRACES: "T_DOLLAR_OPEN_CURLY_BRACES",
+	CURLY_OPEN:               "T_CURLY_OPEN",
+	PAAMAYIM_NEKUDOTAYIM:     "T_PAAMAYIM_NEKUDOTAYIM",
+	NS_SEPARATOR:             "T_NS_SEPARATOR",
+	ELLIPSIS:                 "T_ELLIPSIS",
+	COALESCE:                 "T_COALESCE",
+	POW:                      "T_POW",
+	POW_EQUAL:                "T_POW_EQUAL",
+	BAD_CHARACTER:            "T_BAD_CHARACTER",
+}
+
+// String 返回与 PHP token_name() 一致的名称，单字符 token 返回字符本身
+func (t Token) String() string {
+	if name, ok := tokenNames[t]; ok {
+		return name
+	}
+	if t > 0 && t < 256 {
+		return "'" + string(rune(t)) + "'"
+	}
+	return "UNKNOWN"
+}
+
+var keywords = map[string]Token{
+	"include":         INCLUDE,
+	"include_once":    INCLUDE_ONCE,
+	"eval":            EVAL,
+	"require":         REQUIRE,
+	"require_once":    REQUIRE_ONCE,
+	"or":              LOGICAL_OR,
+	"xor":             LOGICAL_XOR,
+	"and":             LOGICAL_AND,
+	"print":           PRINT,
+	"yield":           YIELD,
+	"instanceof":      INSTANCEOF,
+	"new":             NEW,
+	"clone":           CLONE,
+	"exit":            EXIT,
+	"die":             EXIT,
+	"if":              IF,
+	"elseif":          ELSEIF,
+	"else":            ELSE,
+	"endif":           ENDIF,
+	"echo":            ECHO,
+	"do":              DO,
+	"while":           WHILE,
+	"endwhile":        ENDWHILE,
+	"for":             FOR,
+	"endfor":          ENDFOR,
+	"foreach":         FOREACH,
+	"endforeach":      ENDFOREACH,
+	"declare":         DECLARE,
+	"enddeclare":      ENDDECLARE,
+	"as":              AS,
+	"switch":          SWITCH,
+	"endswitch":       ENDSWITCH,
+	"case":            CASE,
+	"default":         DEFAULT,
+	"match":           MATCH,
+	"break":           BREAK,
+	"continue":        CONTINUE,
+	"goto":            GOTO,
+	"function":        FUNCTION,
+	"fn":              FN,
+	"const":           CONST,
+	"return":          RETURN,
+	"try":             TRY,
+	"catch":           CATCH,
+	"finally":         FINALLY,
+	"throw":           THROW,
+	"use":             USE,
+	"insteadof":       INSTEADOF,
+	"global":          GLOBAL,
+	"static":          STATIC,
+	"abstract":        ABSTRACT,
+	"final":           FINAL,
+	"private":         PRIVATE,
+	"protected":       PROTECTED,
+	"public":          PUBLIC,
+	"readonly":        READONLY,
+	"var":             VAR,
+	"unset":           UNSET,
+	"isset":           ISSET,
+	"empty":           EMPTY,
+	"__halt_compiler": HALT_COMPILER,
+	"class":           CLASS,
+	"trait":           TRAIT,
+	"interface":       INTERFACE,
+	"enum":            ENUM,
+	"extends":         EXTENDS,
+	"implements":      IMPLEMENTS,
+	"namespace":       NAMESPACE,
+	"list":            LIST,
+	"array":           ARRAY,
+	"callable":        CALLABLE,
+	"__line__":        LINE,
+	"__file__":        FILE,
+	"__dir__":         DIR,
+	"__class__":       CLASS_C,
+	"__trait__":       TRAIT_C,
+	"__method__":      METHOD_C,
+	"__function__":    FUNC_C,
+	"__namespace__":   NS_C,
+}
+
+// Lookup 查找标识符对应的关键字 token (大小写不敏感)，非关键字返回 STRING
+func Lookup(ident string) Token {
+	if tok, ok := keywords[strings.ToLower(ident)]; ok {
+		return tok
+	}
+	return STRING
+}
+
+// IsKeyword 判断是否为关键字(包括 semi-reserved 关键字)
+func (t Token) IsKeyword() bool {
+	return t >= INCLUDE && t <= NS_C
+}
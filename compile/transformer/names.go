@@ -0,0 +1,92 @@
+package transformer
+
+import (
+	"github.com/heyuuu/gophp/compile/ast"
+	"github.com/heyuuu/gophp/compile/ir"
+	"github.com/heyuuu/gophp/kits/slicekit"
+	"slices"
+	"strings"
+)
+
+// isBuiltinTypeName 类型声明中的内置类型名，不作为类名解析
+func isBuiltinTypeName(name string) bool {
+	switch strings.ToLower(name) {
+	case "array", "callable", "bool", "int", "float", "string", "iterable", "object",
+		"mixed", "void", "null", "false", "true", "never":
+		return true
+	}
+	return false
+}
+
+func resolvedName(parts []string) *ir.Name {
+	return &ir.Name{Kind: ast.NameFullyQualified, Parts: slices.Clone(parts)}
+}
+
+// className 转换类名引用并附加解析结果。解析器已将类名解析为完全限定名称，只有特殊类名(self/parent/static)保持原样
+func (t *transformer) className(x *ast.Name) *ir.Name {
+	if x == nil {
+		return nil
+	}
+	name := t.name(x)
+	if x.IsFullyQualified() {
+		name.Resolved = withPos(resolvedName(x.Parts), x)
+	}
+	return name
+}
+
+// otherName 转换函数名或常量名引用并附加解析结果。解析器已将名称解析为完全限定名称，
+// 只有命名空间内的非限定名称保持原样，其命名空间名称记录在元数据 namespacedName 中，运行时需回退到全局名称
+func (t *transformer) otherName(x *ast.Name) *ir.Name {
+	name := t.name(x)
+	if nsName := namespacedName(x); nsName != nil {
+		name.Resolved = withPos(resolvedName(nsName.Parts), x)
+		name.Fallback = true
+	} else {
+		name.Resolved = withPos(resolvedName(x.Parts), x)
+	}
+	return name
+}
+
+func namespacedName(x *ast.Name) *ast.Name {
+	if meta := x.Meta(); meta != nil {
+		nsName, _ := meta.Others["namespacedName"].(*ast.Name)
+		return nsName
+	}
+	return nil
+}
+
+// typeName 转换类型声明中的名称，内置类型名不做解析
+func (t *transformer) typeName(x *ast.Name) *ir.Name {
+	if x.IsUnqualified() && isBuiltinTypeName(x.Parts[0]) {
+		return t.name(x)
+	}
+	return t.className(x)
+}
+
+// classRef 转换 Name|Expr 形式的类引用
+func (t *transformer) classRef(n ast.Node) ir.Node {
+	if x, ok := n.(*ast.Name); ok {
+		return t.className(x)
+	}
+	return t.node(n)
+}
+
+// funcRef 转换 Name|Expr 形式的函数引用
+func (t *transformer) funcRef(n ast.Node) ir.Node {
+	if x, ok := n.(*ast.Name); ok {
+		return t.otherName(x)
+	}
+	return t.node(n)
+}
+
+func (t *transformer) classNameList(n []*ast.Name) []*ir.Name {
+	return slicekit.Map(n, t.className)
+}
+
+// declName 声明语句的命名空间名称，由解析器设置，匿名类时为 nil
+func (t *transformer) declName(namespacedName *ast.Name) *ir.Name {
+	if namespacedName == nil {
+		return nil
+	}
+	return t.name(namespacedName)
+}
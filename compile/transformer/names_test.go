@@ -0,0 +1,142 @@
+package transformer
+
+import (
+	"github.com/heyuuu/gophp/compile/ir"
+	"github.com/heyuuu/gophp/compile/parser"
+	"testing"
+)
+
+func TestNameResolution(t *testing.T) {
+	code := `<?php
+namespace App\Http;
+
+use Lib\Util;
+use Lib\{Model, View as V};
+use function Lib\helper;
+use const Lib\VERSION;
+
+new Util\Str();
+new V();
+Model::create();
+helper();
+strlen();
+\strlen();
+VERSION;
+PHP_EOL;
+namespace\Sub\run();
+self::$x;
+`
+	tests := []struct {
+		name         string
+		wantOrig     string
+		wantResolved string
+		wantFallback bool
+	}{
+		{"qualified class", `\Lib\Util\Str`, `\Lib\Util\Str`, false},
+		{"aliased class", `\Lib\View`, `\Lib\View`, false},
+		{"grouped use", `\Lib\Model`, `\Lib\Model`, false},
+		{"use function", `\Lib\helper`, `\Lib\helper`, false},
+		{"unqualified function", `strlen`, `\App\Http\strlen`, true},
+		{"fully qualified function", `\strlen`, `\strlen`, false},
+		{"use const", `\Lib\VERSION`, `\Lib\VERSION`, false},
+		{"unqualified const", `PHP_EOL`, `\App\Http\PHP_EOL`, true},
+		{"relative function", `\App\Http\Sub\run`, `\App\Http\Sub\run`, false},
+		{"special class", `self`, ``, false},
+	}
+
+	names := parseNames(t, code)
+	if len(names) != len(tests) {
+		t.Fatalf("got %d names, want %d", len(names), len(tests))
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			name := names[i]
+			if got := name.ToCodeString(); got != tt.wantOrig {
+				t.Errorf("name = %v, want %v", got, tt.wantOrig)
+			}
+			var resolved string
+			if name.Resolved != nil {
+				resolved = name.Resolved.ToCodeString()
+			}
+			if resolved != tt.wantResolved || name.Fallback != tt.wantFallback {
+				t.Errorf("resolved = %v, %v, want %v, %v", resolved, name.Fallback, tt.wantResolved, tt.wantFallback)
+			}
+		})
+	}
+}
+
+func TestNameAliases(t *testing.T) {
+	code := `<?php
+namespace A;
+
+use B\C;
+use function B\f as g;
+use const B\X;
+
+new c();
+new C\D();
+new D();
+G();
+strlen();
+X;
+x;
+`
+	tests := []struct {
+		name         string
+		wantResolved string
+		wantFallback bool
+	}{
+		{"class alias", `\B\C`, false},
+		{"class qualified alias", `\B\C\D`, false},
+		{"class in namespace", `\A\D`, false},
+		{"function alias", `\B\f`, false},
+		{"function fallback", `\A\strlen`, true},
+		{"const alias", `\B\X`, false},
+		{"const alias case sensitive", `\A\x`, true},
+	}
+	names := parseNames(t, code)
+	if len(names) != len(tests) {
+		t.Fatalf("got %d names, want %d", len(names), len(tests))
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			name := names[i]
+			if got := name.Resolved.ToCodeString(); got != tt.wantResolved || name.Fallback != tt.wantFallback {
+				t.Errorf("resolved = %v, %v, want %v, %v", got, name.Fallback, tt.wantResolved, tt.wantFallback)
+			}
+		})
+	}
+}
+
+// parseNames 转换代码，返回第一个命名空间中各表达式语句引用的名称
+func parseNames(t *testing.T, code string) []*ir.Name {
+	astFile, err := parser.ParseCode(code)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file, err := TransformFile(astFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []*ir.Name
+	for _, stmt := range file.Namespaces[0].Stmts {
+		exprStmt, ok := stmt.(*ir.ExprStmt)
+		if !ok {
+			continue
+		}
+		switch x := exprStmt.Expr.(type) {
+		case *ir.NewExpr:
+			names = append(names, x.Class.(*ir.Name))
+		case *ir.StaticCallExpr:
+			names = append(names, x.Class.(*ir.Name))
+		case *ir.FuncCallExpr:
+			names = append(names, x.Name.(*ir.Name))
+		case *ir.ConstFetchExpr:
+			names = append(names, x.Name)
+		case *ir.StaticPropertyFetchExpr:
+			names = append(names, x.Class.(*ir.Name))
+		}
+	}
+	return names
+}
@@ -96,6 +96,9 @@ func (t *transformer) node(n ast.Node) ir.Node {
 		return t.param(x)
 	case *ast.VariableExpr:
 		return t.variableExpr(x)
+	case ast.Expr:
+		// Name|Expr 形式的引用，如 $fn()() 或 (function () {})()
+		return t.expr(x)
 	}
 
 	// unreachable
@@ -104,45 +107,47 @@ func (t *transformer) node(n ast.Node) ir.Node {
 }
 
 func (t *transformer) arg(x *ast.Arg) *ir.Arg {
-	return &ir.Arg{
+	return withPos(&ir.Arg{
 		Value:  t.expr(x.Value),
 		Unpack: x.Unpack,
-	}
+	}, x)
 }
 
 func (t *transformer) name(x *ast.Name) *ir.Name {
 	if x == nil {
 		return nil
 	}
-	return &ir.Name{
+	return withPos(&ir.Name{
 		Kind:  x.Kind,
 		Parts: slices.Clone(x.Parts),
-	}
+	}, x)
 }
 
 func (t *transformer) ident(x *ast.Ident) *ir.Ident {
 	if x == nil {
 		return nil
 	}
-	return &ir.Ident{
+	return withPos(&ir.Ident{
 		Name:    x.Name,
 		VarLike: x.VarLike,
-	}
+	}, x)
 }
 
 func (t *transformer) param(x *ast.Param) *ir.Param {
-	return &ir.Param{
+	return withPos(&ir.Param{
 		Type:     t.typeHint(x.Type),
 		ByRef:    x.ByRef,
 		Variadic: x.Variadic,
 		Var:      t.variableExpr(x.Var),
 		Default:  t.expr(x.Default),
-	}
+	}, x)
 }
 
 // typeHint
 func (t *transformer) typeHint(n ast.TypeHint) ir.TypeHint {
 	switch x := n.(type) {
+	case nil:
+		return nil
 	case *ast.SimpleType:
 		return t.simpleType(x)
 	case *ast.IntersectionType:
@@ -160,27 +165,27 @@ func (t *transformer) typeHint(n ast.TypeHint) ir.TypeHint {
 }
 
 func (t *transformer) simpleType(x *ast.SimpleType) *ir.SimpleType {
-	return &ir.SimpleType{
-		Name: t.name(x.Name),
-	}
+	return withPos(&ir.SimpleType{
+		Name: t.typeName(x.Name),
+	}, x)
 }
 
 func (t *transformer) intersectionType(x *ast.IntersectionType) *ir.IntersectionType {
-	return &ir.IntersectionType{
+	return withPos(&ir.IntersectionType{
 		Types: t.typeHintList(x.Types),
-	}
+	}, x)
 }
 
 func (t *transformer) unionType(x *ast.UnionType) *ir.UnionType {
-	return &ir.UnionType{
+	return withPos(&ir.UnionType{
 		Types: t.typeHintList(x.Types),
-	}
+	}, x)
 }
 
 func (t *transformer) nullableType(x *ast.NullableType) *ir.NullableType {
-	return &ir.NullableType{
+	return withPos(&ir.NullableType{
 		Type: t.simpleType(x.Type),
-	}
+	}, x)
 }
 
 // expr
@@ -254,6 +259,8 @@ func (t *transformer) expr(n ast.Expr) ir.Expr {
 		return t.ternaryExpr(x)
 	case *ast.ThrowExpr:
 		return t.throwExpr(x)
+	case *ast.MatchExpr:
+		return t.matchExpr(x)
 	case *ast.VariableExpr:
 		return t.variableExpr(x)
 	case *ast.YieldExpr:
@@ -276,293 +283,314 @@ func (t *transformer) expr(n ast.Expr) ir.Expr {
 }
 
 func (t *transformer) intLit(x *ast.IntLit) *ir.IntLit {
-	return &ir.IntLit{
+	return withPos(&ir.IntLit{
 		Raw:   ast.MetaRawValue(x),
 		Value: x.Value,
-	}
+	}, x)
 }
 
 func (t *transformer) floatLit(x *ast.FloatLit) *ir.FloatLit {
-	return &ir.FloatLit{
+	return withPos(&ir.FloatLit{
 		Raw:   ast.MetaRawValue(x),
 		Value: x.Value,
-	}
+	}, x)
 }
 
 func (t *transformer) stringLit(x *ast.StringLit) *ir.StringLit {
-	return &ir.StringLit{
+	return withPos(&ir.StringLit{
 		Raw:   ast.MetaRawValue(x),
 		Value: x.Value,
-	}
+	}, x)
 }
 
 func (t *transformer) arrayExpr(x *ast.ArrayExpr) *ir.ArrayExpr {
-	return &ir.ArrayExpr{
+	return withPos(&ir.ArrayExpr{
 		Items: slicekit.Map(x.Items, t.arrayItemExpr),
-	}
+	}, x)
 }
 
 func (t *transformer) arrayItemExpr(x *ast.ArrayItemExpr) *ir.ArrayItemExpr {
-	return &ir.ArrayItemExpr{
+	if x == nil {
+		// list() 中跳过的元素
+		return nil
+	}
+	return withPos(&ir.ArrayItemExpr{
 		Key:    t.expr(x.Key),
 		Value:  t.expr(x.Value),
 		ByRef:  x.ByRef,
 		Unpack: x.Unpack,
-	}
+	}, x)
 }
 
 func (t *transformer) closureExpr(x *ast.ClosureExpr) *ir.ClosureExpr {
-	return &ir.ClosureExpr{
+	return withPos(&ir.ClosureExpr{
 		Static:     x.Static,
 		ByRef:      x.ByRef,
 		Params:     t.paramList(x.Params),
 		Uses:       slicekit.Map(x.Uses, t.closureUseExpr),
 		ReturnType: t.typeHint(x.ReturnType),
 		Stmts:      t.stmtList(x.Stmts),
-	}
+	}, x)
 }
 
 func (t *transformer) closureUseExpr(x *ast.ClosureUseExpr) *ir.ClosureUseExpr {
-	return &ir.ClosureUseExpr{
+	return withPos(&ir.ClosureUseExpr{
 		Var:   t.variableExpr(x.Var),
 		ByRef: x.ByRef,
-	}
+	}, x)
 }
 
 func (t *transformer) arrowFunctionExpr(x *ast.ArrowFunctionExpr) *ir.ArrowFunctionExpr {
-	return &ir.ArrowFunctionExpr{
+	return withPos(&ir.ArrowFunctionExpr{
 		Static:     x.Static,
 		ByRef:      x.ByRef,
 		Params:     t.paramList(x.Params),
 		ReturnType: t.typeHint(x.ReturnType),
 		Expr:       t.expr(x.Expr),
-	}
+	}, x)
 }
 
 func (t *transformer) indexExpr(x *ast.IndexExpr) *ir.IndexExpr {
-	return &ir.IndexExpr{
+	return withPos(&ir.IndexExpr{
 		Var: t.expr(x.Var),
 		Dim: t.expr(x.Dim),
-	}
+	}, x)
 }
 
 func (t *transformer) castExpr(x *ast.CastExpr) *ir.CastExpr {
-	return &ir.CastExpr{
+	return withPos(&ir.CastExpr{
 		Kind: x.Kind,
 		Expr: t.expr(x.Expr),
-	}
+	}, x)
 }
 
 func (t *transformer) unaryExpr(x *ast.UnaryExpr) *ir.UnaryExpr {
-	return &ir.UnaryExpr{
+	return withPos(&ir.UnaryExpr{
 		Op:  x.Op,
 		Var: t.expr(x.Var),
-	}
+	}, x)
 }
 
 func (t *transformer) binaryOpExpr(x *ast.BinaryOpExpr) *ir.BinaryOpExpr {
-	return &ir.BinaryOpExpr{
+	return withPos(&ir.BinaryOpExpr{
 		Op:    x.Op,
 		Left:  t.expr(x.Left),
 		Right: t.expr(x.Right),
-	}
+	}, x)
 }
 
 func (t *transformer) assignExpr(x *ast.AssignExpr) *ir.AssignExpr {
-	return &ir.AssignExpr{
+	return withPos(&ir.AssignExpr{
 		Var:  t.expr(x.Var),
 		Expr: t.expr(x.Expr),
-	}
+	}, x)
 }
 
 func (t *transformer) assignOpExpr(x *ast.AssignOpExpr) *ir.AssignOpExpr {
-	return &ir.AssignOpExpr{
+	return withPos(&ir.AssignOpExpr{
 		Op:   x.Op,
 		Var:  t.expr(x.Var),
 		Expr: t.expr(x.Expr),
-	}
+	}, x)
 }
 
 func (t *transformer) assignRefExpr(x *ast.AssignRefExpr) *ir.AssignRefExpr {
-	return &ir.AssignRefExpr{
+	return withPos(&ir.AssignRefExpr{
 		Var:  t.expr(x.Var),
 		Expr: t.expr(x.Expr),
-	}
+	}, x)
 }
 
 func (t *transformer) issetExpr(x *ast.IssetExpr) *ir.IssetExpr {
-	return &ir.IssetExpr{
+	return withPos(&ir.IssetExpr{
 		Vars: t.exprList(x.Vars),
-	}
+	}, x)
 }
 
 func (t *transformer) emptyExpr(x *ast.EmptyExpr) *ir.EmptyExpr {
-	return &ir.EmptyExpr{
+	return withPos(&ir.EmptyExpr{
 		Expr: t.expr(x.Expr),
-	}
+	}, x)
 }
 
 func (t *transformer) evalExpr(x *ast.EvalExpr) *ir.EvalExpr {
-	return &ir.EvalExpr{
+	return withPos(&ir.EvalExpr{
 		Expr: t.expr(x.Expr),
-	}
+	}, x)
 }
 
 func (t *transformer) includeExpr(x *ast.IncludeExpr) *ir.IncludeExpr {
-	return &ir.IncludeExpr{
+	return withPos(&ir.IncludeExpr{
 		Kind: x.Kind,
 		Expr: t.expr(x.Expr),
-	}
+	}, x)
 }
 
 func (t *transformer) cloneExpr(x *ast.CloneExpr) *ir.CloneExpr {
-	return &ir.CloneExpr{
+	return withPos(&ir.CloneExpr{
 		Expr: t.expr(x.Expr),
-	}
+	}, x)
 }
 
 func (t *transformer) errorSuppressExpr(x *ast.ErrorSuppressExpr) *ir.ErrorSuppressExpr {
-	return &ir.ErrorSuppressExpr{
+	return withPos(&ir.ErrorSuppressExpr{
 		Expr: t.expr(x.Expr),
-	}
+	}, x)
 }
 
 func (t *transformer) exitExpr(x *ast.ExitExpr) *ir.ExitExpr {
-	return &ir.ExitExpr{
+	return withPos(&ir.ExitExpr{
 		Expr: t.expr(x.Expr),
-	}
+	}, x)
 }
 
 func (t *transformer) constFetchExpr(x *ast.ConstFetchExpr) ir.Expr {
 	switch strings.ToLower(x.Name.ToString()) {
 	case "null":
-		return &ir.NullLit{}
+		return withPos(&ir.NullLit{}, x)
 	case "true":
-		return &ir.BoolLit{Value: true}
+		return withPos(&ir.BoolLit{Value: true}, x)
 	case "false":
-		return &ir.BoolLit{Value: false}
+		return withPos(&ir.BoolLit{Value: false}, x)
 	}
 
-	return &ir.ConstFetchExpr{
-		Name: t.name(x.Name),
-	}
+	return withPos(&ir.ConstFetchExpr{
+		Name: t.otherName(x.Name),
+	}, x)
 }
 
 func (t *transformer) classConstFetchExpr(x *ast.ClassConstFetchExpr) *ir.ClassConstFetchExpr {
-	return &ir.ClassConstFetchExpr{
-		Class: t.node(x.Class),
+	return withPos(&ir.ClassConstFetchExpr{
+		Class: t.classRef(x.Class),
 		Name:  t.ident(x.Name),
-	}
+	}, x)
 }
 
 func (t *transformer) magicConstExpr(x *ast.MagicConstExpr) *ir.MagicConstExpr {
-	return &ir.MagicConstExpr{
+	return withPos(&ir.MagicConstExpr{
 		Kind: x.Kind,
-	}
+	}, x)
 }
 
 func (t *transformer) instanceofExpr(x *ast.InstanceofExpr) *ir.InstanceofExpr {
-	return &ir.InstanceofExpr{
+	return withPos(&ir.InstanceofExpr{
 		Expr:  t.expr(x.Expr),
-		Class: t.node(x.Class),
-	}
+		Class: t.classRef(x.Class),
+	}, x)
 }
 
 func (t *transformer) listExpr(x *ast.ListExpr) *ir.ListExpr {
-	return &ir.ListExpr{
+	return withPos(&ir.ListExpr{
 		Items: slicekit.Map(x.Items, t.arrayItemExpr),
-	}
+	}, x)
 }
 
 func (t *transformer) printExpr(x *ast.PrintExpr) *ir.PrintExpr {
-	return &ir.PrintExpr{
+	return withPos(&ir.PrintExpr{
 		Expr: t.expr(x.Expr),
-	}
+	}, x)
 }
 
 func (t *transformer) propertyFetchExpr(x *ast.PropertyFetchExpr) *ir.PropertyFetchExpr {
-	return &ir.PropertyFetchExpr{
+	return withPos(&ir.PropertyFetchExpr{
 		Var:      t.expr(x.Var),
 		Name:     t.node(x.Name),
 		Nullsafe: x.Nullsafe,
-	}
+	}, x)
 }
 
 func (t *transformer) staticPropertyFetchExpr(x *ast.StaticPropertyFetchExpr) *ir.StaticPropertyFetchExpr {
-	return &ir.StaticPropertyFetchExpr{
-		Class: t.node(x.Class),
+	return withPos(&ir.StaticPropertyFetchExpr{
+		Class: t.classRef(x.Class),
 		Name:  t.node(x.Name),
-	}
+	}, x)
 }
 
 func (t *transformer) shellExecExpr(x *ast.ShellExecExpr) *ir.ShellExecExpr {
-	return &ir.ShellExecExpr{
+	return withPos(&ir.ShellExecExpr{
 		Parts: t.exprList(x.Parts),
-	}
+	}, x)
 }
 
 func (t *transformer) ternaryExpr(x *ast.TernaryExpr) *ir.TernaryExpr {
-	return &ir.TernaryExpr{
+	return withPos(&ir.TernaryExpr{
 		Cond: t.expr(x.Cond),
 		If:   t.expr(x.If),
 		Else: t.expr(x.Else),
-	}
+	}, x)
 }
 
 func (t *transformer) throwExpr(x *ast.ThrowExpr) *ir.ThrowExpr {
-	return &ir.ThrowExpr{
+	return withPos(&ir.ThrowExpr{
 		Expr: t.expr(x.Expr),
-	}
+	}, x)
+}
+
+func (t *transformer) matchExpr(x *ast.MatchExpr) *ir.MatchExpr {
+	return withPos(&ir.MatchExpr{
+		Cond: t.expr(x.Cond),
+		Arms: slicekit.Map(x.Arms, t.matchArm),
+	}, x)
+}
+
+func (t *transformer) matchArm(x *ast.MatchArm) *ir.MatchArm {
+	return withPos(&ir.MatchArm{
+		Conds: t.exprList(x.Conds),
+		Body:  t.expr(x.Body),
+	}, x)
 }
 
 func (t *transformer) variableExpr(x *ast.VariableExpr) *ir.VariableExpr {
-	return &ir.VariableExpr{
-		Name: t.node(x.Name),
+	if x == nil {
+		return nil
 	}
+	return withPos(&ir.VariableExpr{
+		Name: t.node(x.Name),
+	}, x)
 }
 
 func (t *transformer) yieldExpr(x *ast.YieldExpr) *ir.YieldExpr {
-	return &ir.YieldExpr{
+	return withPos(&ir.YieldExpr{
 		Key:   t.expr(x.Key),
 		Value: t.expr(x.Value),
-	}
+	}, x)
 }
 
 func (t *transformer) yieldFromExpr(x *ast.YieldFromExpr) *ir.YieldFromExpr {
-	return &ir.YieldFromExpr{
+	return withPos(&ir.YieldFromExpr{
 		Expr: t.expr(x.Expr),
-	}
+	}, x)
 }
 
 func (t *transformer) funcCallExpr(x *ast.FuncCallExpr) *ir.FuncCallExpr {
-	return &ir.FuncCallExpr{
-		Name: t.node(x.Name),
+	return withPos(&ir.FuncCallExpr{
+		Name: t.funcRef(x.Name),
 		Args: t.argList(x.Args),
-	}
+	}, x)
 }
 
 func (t *transformer) newExpr(x *ast.NewExpr) *ir.NewExpr {
-	return &ir.NewExpr{
-		Class: t.node(x.Class),
+	return withPos(&ir.NewExpr{
+		Class: t.classRef(x.Class),
 		Args:  t.argList(x.Args),
-	}
+	}, x)
 }
 
 func (t *transformer) methodCallExpr(x *ast.MethodCallExpr) *ir.MethodCallExpr {
-	return &ir.MethodCallExpr{
+	return withPos(&ir.MethodCallExpr{
 		Var:      t.expr(x.Var),
 		Name:     t.node(x.Name),
 		Args:     t.argList(x.Args),
 		Nullsafe: x.Nullsafe,
-	}
+	}, x)
 }
 
 func (t *transformer) staticCallExpr(x *ast.StaticCallExpr) *ir.StaticCallExpr {
-	return &ir.StaticCallExpr{
-		Class: t.node(x.Class),
+	return withPos(&ir.StaticCallExpr{
+		Class: t.classRef(x.Class),
 		Name:  t.node(x.Name),
 		Args:  t.argList(x.Args),
-	}
+	}, x)
 }
 
 // stmt
@@ -646,186 +674,189 @@ func (t *transformer) stmt(n ast.Stmt) (stmt ir.Stmt) {
 }
 
 func (t *transformer) emptyStmt(x *ast.EmptyStmt) *ir.EmptyStmt {
-	return &ir.EmptyStmt{}
+	return withPos(&ir.EmptyStmt{}, x)
 }
 
 func (t *transformer) exprStmt(x *ast.ExprStmt) *ir.ExprStmt {
-	return &ir.ExprStmt{
+	return withPos(&ir.ExprStmt{
 		Expr: t.expr(x.Expr),
-	}
+	}, x)
 }
 
 func (t *transformer) returnStmt(x *ast.ReturnStmt) *ir.ReturnStmt {
-	return &ir.ReturnStmt{
+	return withPos(&ir.ReturnStmt{
 		Expr: t.expr(x.Expr),
-	}
+	}, x)
 }
 
 func (t *transformer) labelStmt(x *ast.LabelStmt) *ir.LabelStmt {
-	return &ir.LabelStmt{
+	return withPos(&ir.LabelStmt{
 		Name: t.ident(x.Name),
-	}
+	}, x)
 }
 
 func (t *transformer) gotoStmt(x *ast.GotoStmt) *ir.GotoStmt {
-	return &ir.GotoStmt{
+	return withPos(&ir.GotoStmt{
 		Name: t.ident(x.Name),
-	}
+	}, x)
 }
 
 func (t *transformer) ifStmt(x *ast.IfStmt) *ir.IfStmt {
-	return &ir.IfStmt{
+	return withPos(&ir.IfStmt{
 		Cond:    t.expr(x.Cond),
 		Stmts:   t.stmtList(x.Stmts),
 		Elseifs: slicekit.Map(x.Elseifs, t.elseIfStmt),
 		Else:    t.elseStmt(x.Else),
-	}
+	}, x)
 }
 
 func (t *transformer) elseIfStmt(x *ast.ElseIfStmt) *ir.ElseIfStmt {
-	return &ir.ElseIfStmt{
+	return withPos(&ir.ElseIfStmt{
 		Cond:  t.expr(x.Cond),
 		Stmts: t.stmtList(x.Stmts),
-	}
+	}, x)
 }
 
 func (t *transformer) elseStmt(x *ast.ElseStmt) *ir.ElseStmt {
 	if x == nil {
 		return nil
 	}
-	return &ir.ElseStmt{
+	return withPos(&ir.ElseStmt{
 		Stmts: t.stmtList(x.Stmts),
-	}
+	}, x)
 }
 
 func (t *transformer) switchStmt(x *ast.SwitchStmt) *ir.SwitchStmt {
-	return &ir.SwitchStmt{
+	return withPos(&ir.SwitchStmt{
 		Cond:  t.expr(x.Cond),
 		Cases: slicekit.Map(x.Cases, t.caseStmt),
-	}
+	}, x)
 }
 
 func (t *transformer) caseStmt(x *ast.CaseStmt) *ir.CaseStmt {
-	return &ir.CaseStmt{
+	return withPos(&ir.CaseStmt{
 		Cond:  t.expr(x.Cond),
 		Stmts: t.stmtList(x.Stmts),
-	}
+	}, x)
 }
 
 func (t *transformer) forStmt(x *ast.ForStmt) *ir.ForStmt {
-	return &ir.ForStmt{
+	return withPos(&ir.ForStmt{
 		Init:  t.exprList(x.Init),
 		Cond:  t.exprList(x.Cond),
 		Loop:  t.exprList(x.Loop),
 		Stmts: t.stmtList(x.Stmts),
-	}
+	}, x)
 }
 
 func (t *transformer) foreachStmt(x *ast.ForeachStmt) *ir.ForeachStmt {
-	return &ir.ForeachStmt{
+	return withPos(&ir.ForeachStmt{
 		Expr:     t.expr(x.Expr),
 		KeyVar:   t.expr(x.KeyVar),
 		ByRef:    x.ByRef,
 		ValueVar: t.expr(x.ValueVar),
 		Stmts:    t.stmtList(x.Stmts),
-	}
+	}, x)
 }
 
 func (t *transformer) breakStmt(x *ast.BreakStmt) *ir.BreakStmt {
-	return &ir.BreakStmt{
+	return withPos(&ir.BreakStmt{
 		Num: t.expr(x.Num),
-	}
+	}, x)
 }
 
 func (t *transformer) continueStmt(x *ast.ContinueStmt) *ir.ContinueStmt {
-	return &ir.ContinueStmt{
+	return withPos(&ir.ContinueStmt{
 		Num: t.expr(x.Num),
-	}
+	}, x)
 }
 
 func (t *transformer) whileStmt(x *ast.WhileStmt) *ir.WhileStmt {
-	return &ir.WhileStmt{
+	return withPos(&ir.WhileStmt{
 		Cond:  t.expr(x.Cond),
 		Stmts: t.stmtList(x.Stmts),
-	}
+	}, x)
 }
 
 func (t *transformer) doStmt(x *ast.DoStmt) *ir.DoStmt {
-	return &ir.DoStmt{
+	return withPos(&ir.DoStmt{
 		Stmts: t.stmtList(x.Stmts),
 		Cond:  t.expr(x.Cond),
-	}
+	}, x)
 }
 
 func (t *transformer) tryCatchStmt(x *ast.TryCatchStmt) *ir.TryCatchStmt {
-	return &ir.TryCatchStmt{
+	return withPos(&ir.TryCatchStmt{
 		Stmts:   t.stmtList(x.Stmts),
 		Catches: slicekit.Map(x.Catches, t.catchStmt),
 		Finally: t.finallyStmt(x.Finally),
-	}
+	}, x)
 }
 
 func (t *transformer) catchStmt(x *ast.CatchStmt) *ir.CatchStmt {
-	return &ir.CatchStmt{
-		Types: t.nameList(x.Types),
+	return withPos(&ir.CatchStmt{
+		Types: t.classNameList(x.Types),
 		Var:   t.variableExpr(x.Var),
 		Stmts: t.stmtList(x.Stmts),
-	}
+	}, x)
 }
 
 func (t *transformer) finallyStmt(x *ast.FinallyStmt) *ir.FinallyStmt {
-	return &ir.FinallyStmt{
-		Stmts: t.stmtList(x.Stmts),
+	if x == nil {
+		return nil
 	}
+	return withPos(&ir.FinallyStmt{
+		Stmts: t.stmtList(x.Stmts),
+	}, x)
 }
 
 func (t *transformer) constStmt(x *ast.ConstStmt) *ir.ConstStmt {
-	return &ir.ConstStmt{
+	return withPos(&ir.ConstStmt{
 		Name:           t.ident(x.Name),
 		Value:          t.expr(x.Value),
-		NamespacedName: t.name(x.NamespacedName),
-	}
+		NamespacedName: t.declName(x.NamespacedName),
+	}, x)
 }
 
 func (t *transformer) echoStmt(x *ast.EchoStmt) *ir.EchoStmt {
-	return &ir.EchoStmt{
+	return withPos(&ir.EchoStmt{
 		Exprs: t.exprList(x.Exprs),
-	}
+	}, x)
 }
 
 func (t *transformer) globalStmt(x *ast.GlobalStmt) *ir.GlobalStmt {
-	return &ir.GlobalStmt{
+	return withPos(&ir.GlobalStmt{
 		Var: t.expr(x.Var),
-	}
+	}, x)
 }
 
 func (t *transformer) inlineHTMLStmt(x *ast.InlineHTMLStmt) ir.Stmt {
-	return &ir.EchoStmt{
+	return withPos(&ir.EchoStmt{
 		Exprs: []ir.Expr{
 			&ir.StringLit{Value: x.Value},
 		},
-	}
+	}, x)
 }
 
 func (t *transformer) staticStmt(x *ast.StaticStmt) *ir.StaticStmt {
-	return &ir.StaticStmt{
+	return withPos(&ir.StaticStmt{
 		Var:     t.variableExpr(x.Var),
 		Default: t.expr(x.Default),
-	}
+	}, x)
 }
 
 func (t *transformer) unsetStmt(x *ast.UnsetStmt) *ir.UnsetStmt {
-	return &ir.UnsetStmt{
+	return withPos(&ir.UnsetStmt{
 		Var: t.expr(x.Var),
-	}
+	}, x)
 }
 
 func (t *transformer) useStmt(x *ast.UseStmt) *ir.UseStmt {
-	return &ir.UseStmt{
+	return withPos(&ir.UseStmt{
 		Type:  x.Type,
 		Name:  t.name(x.Name),
 		Alias: t.ident(x.Alias),
-	}
+	}, x)
 }
 
 func (t *transformer) namespaceStmt(x *ast.NamespaceStmt) *ir.NamespaceStmt {
@@ -833,85 +864,85 @@ func (t *transformer) namespaceStmt(x *ast.NamespaceStmt) *ir.NamespaceStmt {
 	if x.Name != nil {
 		name = t.name(x.Name)
 	}
-	return &ir.NamespaceStmt{
+	return withPos(&ir.NamespaceStmt{
 		Name:  name,
 		Stmts: t.stmtList(x.Stmts),
-	}
+	}, x)
 }
 
 func (t *transformer) functionStmt(x *ast.FunctionStmt) *ir.FunctionStmt {
-	return &ir.FunctionStmt{
+	return withPos(&ir.FunctionStmt{
 		ByRef:          x.ByRef,
 		Name:           t.ident(x.Name),
 		Params:         t.paramList(x.Params),
 		ReturnType:     t.typeHint(x.ReturnType),
 		Stmts:          t.stmtList(x.Stmts),
-		NamespacedName: t.name(x.NamespacedName),
-	}
+		NamespacedName: t.declName(x.NamespacedName),
+	}, x)
 }
 
 func (t *transformer) interfaceStmt(x *ast.InterfaceStmt) *ir.InterfaceStmt {
-	return &ir.InterfaceStmt{
-		Extends:        t.nameList(x.Extends),
+	return withPos(&ir.InterfaceStmt{
+		Extends:        t.classNameList(x.Extends),
 		Name:           t.ident(x.Name),
 		Stmts:          t.stmtList(x.Stmts),
-		NamespacedName: t.name(x.NamespacedName),
-	}
+		NamespacedName: t.declName(x.NamespacedName),
+	}, x)
 }
 
 func (t *transformer) classStmt(x *ast.ClassStmt) *ir.ClassStmt {
-	return &ir.ClassStmt{
+	return withPos(&ir.ClassStmt{
 		Flags:          x.Flags,
-		Extends:        t.name(x.Extends),
-		Implements:     t.nameList(x.Implements),
+		Extends:        t.className(x.Extends),
+		Implements:     t.classNameList(x.Implements),
 		Name:           t.ident(x.Name),
 		Stmts:          t.stmtList(x.Stmts),
-		NamespacedName: t.name(x.NamespacedName),
-	}
+		NamespacedName: t.declName(x.NamespacedName),
+	}, x)
 }
 
 func (t *transformer) classConstStmt(x *ast.ClassConstStmt) *ir.ClassConstStmt {
-	return &ir.ClassConstStmt{
+	return withPos(&ir.ClassConstStmt{
 		Flags: x.Flags,
 		Type:  t.typeHint(x.Type),
 		Name:  t.ident(x.Name),
 		Value: t.expr(x.Value),
-	}
+	}, x)
 }
 
 func (t *transformer) propertyStmt(x *ast.PropertyStmt) *ir.PropertyStmt {
-	return &ir.PropertyStmt{
+	return withPos(&ir.PropertyStmt{
 		Flags:   x.Flags,
 		Type:    t.typeHint(x.Type),
 		Name:    t.ident(x.Name),
 		Default: t.expr(x.Default),
-	}
+	}, x)
 }
 
 func (t *transformer) classMethodStmt(x *ast.ClassMethodStmt) *ir.ClassMethodStmt {
-	return &ir.ClassMethodStmt{
+	return withPos(&ir.ClassMethodStmt{
 		Flags:      x.Flags,
 		ByRef:      x.ByRef,
 		Name:       t.ident(x.Name),
 		Params:     t.paramList(x.Params),
 		ReturnType: t.typeHint(x.ReturnType),
 		Stmts:      t.stmtList(x.Stmts),
-	}
+	}, x)
 }
 
 func (t *transformer) traitStmt(x *ast.TraitStmt) *ir.TraitStmt {
-	return &ir.TraitStmt{
+	return withPos(&ir.TraitStmt{
 		Name:           t.ident(x.Name),
 		Stmts:          t.stmtList(x.Stmts),
-		NamespacedName: t.name(x.NamespacedName),
-	}
+		NamespacedName: t.declName(x.NamespacedName),
+	}, x)
 }
 
 func (t *transformer) traitUseStmt(x *ast.TraitUseStmt) *ir.TraitUseStmt {
-	return &ir.TraitUseStmt{
-		Traits:      t.nameList(x.Traits),
+	return withPos(&ir.TraitUseStmt{
+		Traits:      t.classNameList(x.Traits),
 		Adaptations: slicekit.Map(x.Adaptations, t.traitUseAdaptation),
-	}
+	}, x)
 }
 
 func (t *transformer) traitUseAdaptation(n ast.TraitUseAdaptationStmt) ir.TraitUseAdaptationStmt {
@@ -928,20 +959,26 @@ func (t *transformer) traitUseAdaptation(n ast.TraitUseAdaptationStmt) ir.TraitU
 }
 
 func (t *transformer) traitUseAdaptationAliasStmt(x *ast.TraitUseAdaptationAliasStmt) *ir.TraitUseAdaptationAliasStmt {
-	return &ir.TraitUseAdaptationAliasStmt{
+	return withPos(&ir.TraitUseAdaptationAliasStmt{
 		NewModifier: x.NewModifier,
 		NewName:     t.ident(x.NewName),
-		Trait:       t.name(x.Trait),
+		Trait:       t.className(x.Trait),
 		Method:      t.ident(x.Method),
-	}
+	}, x)
 }
 
 func (t *transformer) traitUseAdaptationPrecedenceStmt(x *ast.TraitUseAdaptationPrecedenceStmt) *ir.TraitUseAdaptationPrecedenceStmt {
-	return &ir.TraitUseAdaptationPrecedenceStmt{
-		Insteadof: t.nameList(x.Insteadof),
-		Trait:     t.name(x.Trait),
+	return withPos(&ir.TraitUseAdaptationPrecedenceStmt{
+		Insteadof: t.classNameList(x.Insteadof),
+		Trait:     t.className(x.Trait),
 		Method:    t.ident(x.Method),
-	}
+	}, x)
+}
+
+// withPos 复制 ast 节点的源码位置到 ir 节点
+func withPos[T ir.Node](n T, x ast.Node) T {
+	n.SetPos(ast.MetaStart(x), ast.MetaEnd(x))
+	return n
 }
 
 // list
@@ -0,0 +1,71 @@
+package transpile
+
+import (
+	"fmt"
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// checkFiles 对生成的 go 文件做类型检查，返回各文件的第一个错误，错误行号为 //line 指令映射后的 PHP 代码行号。
+// 依赖包的导出数据由 go list -export 获取，需在能解析 gophp 模块的目录下执行，无法导入依赖包时返回 error
+func checkFiles(pkg string, files []*GeneratedFile) (map[*GeneratedFile]error, error) {
+	fset := token.NewFileSet()
+	imp := importer.ForCompiler(fset, "gc", exportData)
+
+	var astFiles []*ast.File
+	byName := make(map[string]*GeneratedFile, len(files))
+	for _, file := range files {
+		f, err := parser.ParseFile(fset, file.GoFile, file.Content, parser.SkipObjectResolution)
+		if err != nil {
+			return nil, err
+		}
+		for _, spec := range f.Imports {
+			path := strings.Trim(spec.Path.Value, `"`)
+			if _, err := imp.Import(path); err != nil {
+				return nil, fmt.Errorf("cannot import %s: %w", path, err)
+			}
+		}
+		astFiles = append(astFiles, f)
+		byName[file.GoFile] = file
+	}
+
+	errs := map[*GeneratedFile]error{}
+	conf := types.Config{
+		Importer: imp,
+		Error: func(err error) {
+			typeErr, ok := err.(types.Error)
+			if !ok {
+				return
+			}
+			file := byName[fset.PositionFor(typeErr.Pos, false).Filename]
+			if file != nil && errs[file] == nil {
+				errs[file] = fmt.Errorf("line %d: %s", fset.Position(typeErr.Pos).Line, typeErr.Msg)
+			}
+		},
+	}
+	_, _ = conf.Check(pkg, fset, astFiles, nil)
+	return errs, nil
+}
+
+// exportData 通过 go list -export 查找包的导出数据文件
+func exportData(path string) (io.ReadCloser, error) {
+	out, err := exec.Command("go", "list", "-export", "-f", "{{.Export}}", path).Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok && len(exitErr.Stderr) > 0 {
+			return nil, fmt.Errorf("go list: %s", strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return nil, err
+	}
+	file := strings.TrimSpace(string(out))
+	if file == "" {
+		return nil, fmt.Errorf("no export data for %s", path)
+	}
+	return os.Open(file)
+}
@@ -0,0 +1,285 @@
+package transpile
+
+import (
+	"errors"
+	"fmt"
+	"github.com/heyuuu/gophp/compile/optimizer"
+	"github.com/heyuuu/gophp/compile/parser"
+	"github.com/heyuuu/gophp/compile/render"
+	"github.com/heyuuu/gophp/compile/transformer"
+	"go/format"
+	"go/token"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// RegisterFileName 生成的注册文件名
+const RegisterFileName = "gophp_register.go"
+
+// Config 转译配置
+type Config struct {
+	SrcDir  string // PHP 源码目录
+	OutDir  string // 生成的 go 包目录
+	Package string // 生成的 go 包名，为空时取 OutDir 目录名
+
+	Optimize optimizer.Options // IR 优化选项，零值为启用全部优化
+	NoCheck  bool              // 不对生成的 go 代码做类型检查
+}
+
+// Stage 转译阶段，用于标识错误发生的位置
+type Stage string
+
+const (
+	StageRead      Stage = "read"
+	StageParse     Stage = "parse"
+	StageTransform Stage = "transform"
+	StageRender    Stage = "render"
+	StageFormat    Stage = "format"
+	StageCheck     Stage = "check"
+	StageWrite     Stage = "write"
+)
+
+// ErrCheckUnavailable 无法导入生成代码依赖的包(如不在依赖 gophp 的模块内执行)，不能进行类型检查
+var ErrCheckUnavailable = errors.New("cannot type-check generated code")
+
+// FileError 单个文件的转译错误，不影响其他文件的转译
+type FileError struct {
+	Path  string // 相对 SrcDir 的路径
+	Stage Stage
+	Err   error
+}
+
+func (e *FileError) Error() string {
+	return fmt.Sprintf("%s: %s error: %v", e.Path, e.Stage, e.Err)
+}
+
+func (e *FileError) Unwrap() error { return e.Err }
+
+// GeneratedFile 转译成功的文件
+type GeneratedFile struct {
+	Path    string // 相对 SrcDir 的 PHP 文件路径
+	GoFile  string // 相对 OutDir 的 go 文件路径
+	Content []byte
+}
+
+// Result 转译结果
+type Result struct {
+	Files  []*GeneratedFile
+	Errors []*FileError
+}
+
+// Dir 转译目录下所有 PHP 文件到 go 包。单个文件的错误记录在 Result.Errors 中，类型检查未通过的文件不生成。
+// 仅目录读写失败或无法进行类型检查时返回 error
+func Dir(cfg Config) (*Result, error) {
+	if cfg.Package == "" {
+		abs, err := filepath.Abs(cfg.OutDir)
+		if err != nil {
+			return nil, err
+		}
+		cfg.Package = packageName(filepath.Base(abs))
+	}
+	if !token.IsIdentifier(cfg.Package) {
+		return nil, fmt.Errorf("invalid package name %q", cfg.Package)
+	}
+
+	paths, err := findPhpFiles(cfg.SrcDir, cfg.OutDir)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &Result{}
+	goFiles := newGoFileNames()
+	for _, path := range paths {
+		content, stage, err := transpileFile(filepath.Join(cfg.SrcDir, path), filepath.ToSlash(path), cfg)
+		if err != nil {
+			result.Errors = append(result.Errors, &FileError{Path: path, Stage: stage, Err: err})
+			continue
+		}
+		result.Files = append(result.Files, &GeneratedFile{
+			Path:    path,
+			GoFile:  goFiles.add(path),
+			Content: content,
+		})
+	}
+
+	if !cfg.NoCheck && len(result.Files) > 0 {
+		checkErrs, err := checkFiles(cfg.Package, result.Files)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrCheckUnavailable, err)
+		}
+		files := result.Files[:0]
+		for _, file := range result.Files {
+			if err := checkErrs[file]; err != nil {
+				result.Errors = append(result.Errors, &FileError{Path: file.Path, Stage: StageCheck, Err: err})
+			} else {
+				files = append(files, file)
+			}
+		}
+		result.Files = files
+		slices.SortStableFunc(result.Errors, func(a, b *FileError) int { return strings.Compare(a.Path, b.Path) })
+	}
+
+	if err = os.MkdirAll(cfg.OutDir, 0o755); err != nil {
+		return nil, err
+	}
+	for _, file := range result.Files {
+		if err = os.WriteFile(filepath.Join(cfg.OutDir, file.GoFile), file.Content, 0o644); err != nil {
+			result.Errors = append(result.Errors, &FileError{Path: file.Path, Stage: StageWrite, Err: err})
+		}
+	}
+	register, err := renderRegisterFile(cfg.Package, result.Files)
+	if err != nil {
+		return nil, err
+	}
+	if err = os.WriteFile(filepath.Join(cfg.OutDir, RegisterFileName), register, 0o644); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// findPhpFiles 查找目录下的 PHP 文件，返回排序后的相对路径。跳过隐藏目录及输出目录
+func findPhpFiles(srcDir string, outDir string) ([]string, error) {
+	outAbs, _ := filepath.Abs(outDir)
+
+	var paths []string
+	err := filepath.WalkDir(srcDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if path != srcDir && strings.HasPrefix(d.Name(), ".") {
+				return filepath.SkipDir
+			}
+			if abs, _ := filepath.Abs(path); abs == outAbs {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if filepath.Ext(path) != ".php" {
+			return nil
+		}
+		rel, err := filepath.Rel(srcDir, path)
+		if err != nil {
+			return err
+		}
+		paths = append(paths, rel)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	slices.Sort(paths)
+	return paths, nil
+}
+
+// transpileFile 转译单个文件，filePath 为生成代码中记录的原始文件路径
+func transpileFile(path string, filePath string, cfg Config) ([]byte, Stage, error) {
+	code, err := os.ReadFile(path)
+	if err != nil {
+		return nil, StageRead, err
+	}
+
+	astFile, err := parser.ParseCode(string(code))
+	if err != nil {
+		return nil, StageParse, err
+	}
+
+	irFile, err := transformer.TransformFile(astFile)
+	if err != nil {
+		return nil, StageTransform, err
+	}
+	irFile.FilePath = filePath
+	optimizer.OptimizeFile(irFile, cfg.Optimize)
+
+	source, err := render.RenderFileOptions(irFile, render.Options{Package: cfg.Package, LineFile: lineFile(path, cfg.OutDir)})
+	if err != nil {
+		return nil, StageRender, err
+	}
+
+	source = "// Code generated by gophp transpile from " + filePath + ". DO NOT EDIT.\n\n" + source
+	content, err := format.Source([]byte(source))
+	if err != nil {
+		return nil, StageFormat, err
+	}
+	return content, "", nil
+}
+
+// lineFile //line 指令中的 PHP 文件路径，为相对生成的 go 文件所在目录(即 OutDir)的路径，
+// go 调用栈中显示原始 PHP 文件的实际位置。无法计算相对路径时使用绝对路径
+func lineFile(path string, outDir string) string {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return filepath.ToSlash(path)
+	}
+	if absOut, err := filepath.Abs(outDir); err == nil {
+		if rel, err := filepath.Rel(absOut, absPath); err == nil {
+			return filepath.ToSlash(rel)
+		}
+	}
+	return filepath.ToSlash(absPath)
+}
+
+func renderRegisterFile(pkg string, files []*GeneratedFile) ([]byte, error) {
+	var buf strings.Builder
+	buf.WriteString("// Code generated by gophp transpile. DO NOT EDIT.\n\n")
+	buf.WriteString("package " + pkg + "\n\n")
+	buf.WriteString("// SourceFiles 已转译的 PHP 文件(相对源码目录)，引入本包时各文件通过 init 注册\n")
+	buf.WriteString("var SourceFiles = []string{\n")
+	for _, file := range files {
+		buf.WriteString("\t" + strconv.Quote(filepath.ToSlash(file.Path)) + ",\n")
+	}
+	buf.WriteString("}\n")
+	return format.Source([]byte(buf.String()))
+}
+
+// packageName 由目录名生成合法的包名
+func packageName(dir string) string {
+	name := identifier(strings.ToLower(dir))
+	if name == "" || name == "_" {
+		return "php"
+	}
+	return name
+}
+
+// identifier 将任意字符串转为由字母、数字及下划线组成的标识符
+func identifier(s string) string {
+	var buf strings.Builder
+	for _, c := range s {
+		if c == '_' || ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') || ('0' <= c && c <= '9') {
+			buf.WriteRune(c)
+		} else {
+			buf.WriteByte('_')
+		}
+	}
+	name := buf.String()
+	if name != "" && '0' <= name[0] && name[0] <= '9' {
+		name = "_" + name
+	}
+	return name
+}
+
+// goFileNames 为 PHP 文件分配包内唯一的 go 文件名
+type goFileNames struct {
+	used map[string]bool
+}
+
+func newGoFileNames() *goFileNames {
+	return &goFileNames{used: map[string]bool{RegisterFileName: true}}
+}
+
+func (n *goFileNames) add(path string) string {
+	// 添加 _php 后缀，避免被识别为测试文件(_test)或平台相关文件(_linux 等)；去除前导 _，避免被 go 工具忽略
+	base := identifier(strings.TrimSuffix(filepath.ToSlash(path), ".php")) + "_php"
+	base = strings.TrimLeft(base, "_")
+
+	name := base + ".go"
+	for num := 2; n.used[strings.ToLower(name)]; num++ {
+		name = base + "_" + strconv.Itoa(num) + ".go"
+	}
+	n.used[strings.ToLower(name)] = true
+	return name
+}
@@ -0,0 +1,146 @@
+package transpile
+
+import (
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDir(t *testing.T) {
+	srcDir := t.TempDir()
+	files := map[string]string{
+		"index.php":        "<?php\necho \"hello \\\"world\\\"\\n\";\n",
+		"lib/util.php":     "<?php\necho 'util';\n",
+		"lib/bad.php":      "<?php\necho (;\n",
+		".hidden/skip.php": "<?php\necho 1;\n",
+		"readme.txt":       "not php",
+	}
+	for path, code := range files {
+		path = filepath.Join(srcDir, path)
+		_ = os.MkdirAll(filepath.Dir(path), 0o755)
+		if err := os.WriteFile(path, []byte(code), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	outDir := filepath.Join(t.TempDir(), "out")
+	result, err := Dir(Config{SrcDir: srcDir, OutDir: outDir})
+	if err != nil {
+		t.Fatalf("Dir() error = %v", err)
+	}
+
+	if len(result.Errors) != 1 || result.Errors[0].Path != filepath.Join("lib", "bad.php") || result.Errors[0].Stage != StageParse {
+		t.Errorf("Dir() errors = %v, want parse error of lib/bad.php", result.Errors)
+	}
+
+	wantGoFiles := []string{"index_php.go", "lib_util_php.go", RegisterFileName}
+	if len(result.Files) != len(wantGoFiles)-1 {
+		t.Fatalf("Dir() generated %d files, want %d", len(result.Files), len(wantGoFiles)-1)
+	}
+	fset := token.NewFileSet()
+	for _, name := range wantGoFiles {
+		f, err := parser.ParseFile(fset, filepath.Join(outDir, name), nil, parser.ParseComments)
+		if err != nil {
+			t.Errorf("generated file %s is invalid: %v", name, err)
+			continue
+		}
+		if f.Name.Name != "out" {
+			t.Errorf("generated file %s package = %s, want out", name, f.Name.Name)
+		}
+	}
+
+	// //line 指令指向原始 PHP 文件，相对路径按 go 文件所在目录解析
+	index, _ := os.ReadFile(filepath.Join(outDir, "index_php.go"))
+	rel, _ := filepath.Rel(outDir, filepath.Join(srcDir, "index.php"))
+	if want := "\n//line " + filepath.ToSlash(rel) + ":2\n"; !strings.Contains(string(index), want) {
+		t.Errorf("generated file index_php.go has no line directive %q:\n%s", want, index)
+	}
+
+	register, _ := os.ReadFile(filepath.Join(outDir, RegisterFileName))
+	if !strings.Contains(string(register), `"lib/util.php"`) || strings.Contains(string(register), "bad.php") {
+		t.Errorf("register file = %s", register)
+	}
+}
+
+func TestDirLabels(t *testing.T) {
+	srcDir := t.TempDir()
+	code := `<?php
+outer:
+$i = 0;
+loop:
+$i++;
+if ($i < 3) goto loop;
+function f() { unused: return 1; }
+$f = function () { done: echo 1; };
+echo $i;
+`
+	if err := os.WriteFile(filepath.Join(srcDir, "labels.php"), []byte(code), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	outDir := filepath.Join(t.TempDir(), "out")
+	result, err := Dir(Config{SrcDir: srcDir, OutDir: outDir})
+	if err != nil {
+		t.Fatalf("Dir() error = %v", err)
+	}
+	if len(result.Errors) != 0 || len(result.Files) != 1 {
+		t.Fatalf("Dir() errors = %v, want generated code of labels.php to type-check", result.Errors)
+	}
+	content := string(result.Files[0].Content)
+	if !strings.Contains(content, "loop:") || strings.Contains(content, "outer:") || strings.Contains(content, "unused:") || strings.Contains(content, "done:") {
+		t.Errorf("generated labels.php:\n%s", content)
+	}
+}
+
+func TestGoFileNames(t *testing.T) {
+	names := newGoFileNames()
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"a.php", "a_php.go"},
+		{"a_test.php", "a_test_php.go"},
+		{"_a.php", "a_php_2.go"},
+		{"lib/a-b.php", "lib_a_b_php.go"},
+		{"lib_a-b.php", "lib_a_b_php_2.go"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			if got := names.add(tt.path); got != tt.want {
+				t.Errorf("add() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckFiles(t *testing.T) {
+	good := &GeneratedFile{Path: "good.php", GoFile: "good_php.go", Content: []byte(`package out
+
+import "github.com/heyuuu/gophp/php/def"
+
+func init() {
+	f := def.NewFile("good.php", false)
+	f.Register()
+}
+`)}
+	bad := &GeneratedFile{Path: "bad.php", GoFile: "bad_php.go", Content: []byte(`package out
+
+func init() {
+//line bad.php:3
+	unused := 1
+}
+`)}
+	errs, err := checkFiles("out", []*GeneratedFile{good, bad})
+	if err != nil {
+		t.Fatalf("checkFiles() error = %v", err)
+	}
+	if errs[good] != nil {
+		t.Errorf("checkFiles() error of good.php = %v, want nil", errs[good])
+	}
+	if e := errs[bad]; e == nil || e.Error() != "line 3: declared and not used: unused" {
+		t.Errorf("checkFiles() error of bad.php = %v", e)
+	}
+}
@@ -27,5 +27,9 @@ func RegisterStdClass(ctx *php.Context, moduleNumber int) {
 }
 
 func RegisterCoreClasses(ctx *php.Context, moduleNumber int) {
-
+	RegisterThrowableClasses(ctx, moduleNumber)
+	php.RegisterIteratorInterfaces(ctx, moduleNumber)
+	php.RegisterClosureClass(ctx, moduleNumber)
+	php.RegisterGeneratorClass(ctx, moduleNumber)
+	php.RegisterFiberClass(ctx, moduleNumber)
 }
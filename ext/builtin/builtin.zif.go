@@ -6,7 +6,7 @@ import (
 	"github.com/heyuuu/gophp/php/zpp"
 )
 
-var zifFunctions = []def.FuncType{DefZifGcMemCaches, DefZifGcCollectCycles, DefZifGcEnabled, DefZifGcStatus, DefZifFuncNumArgs, DefZifFuncGetArg, DefZifFuncGetArgs, DefZifStrlen, DefZifStrcmp, DefZifStrncmp, DefZifStrcasecmp, DefZifStrncasecmp, DefZifEach, DefZifErrorReporting, DefZifDefine, DefZifDefined, DefZifGetClass, DefZifFunctionExists, DefZifSetTimeLimit}
+var zifFunctions = []def.FuncType{DefZifGcMemCaches, DefZifGcCollectCycles, DefZifGcEnabled, DefZifGcStatus, DefZifFuncNumArgs, DefZifFuncGetArg, DefZifFuncGetArgs, DefZifStrlen, DefZifStrcmp, DefZifStrncmp, DefZifStrcasecmp, DefZifStrncasecmp, DefZifEach, DefZifErrorReporting, DefZifDefine, DefZifDefined, DefZifGetClass, DefZifFunctionExists, DefZifSetTimeLimit, DefZifOpcacheGetStatus}
 
 // generate by ZifGcMemCaches
 var DefZifGcMemCaches = def.DefFunc("gc_mem_caches", 0, 0, []def.ArgInfo{}, func(executeData *php.ExecuteData, returnValue zpp.Ret) {
@@ -260,3 +260,20 @@ var DefZifSetTimeLimit = def.DefFunc("set_time_limit", 1, 1, []def.ArgInfo{{Name
 	ret := ZifSetTimeLimit(executeData.Ctx(), seconds)
 	returnValue.SetBool(ret)
 })
+
+// generate by ZifOpcacheGetStatus
+var DefZifOpcacheGetStatus = def.DefFunc("opcache_get_status", 0, 1, []def.ArgInfo{{Name: "include_scripts"}}, func(executeData *php.ExecuteData, returnValue zpp.Ret) {
+	fp := php.NewParamParser(executeData, 0, 1, 0)
+	fp.CheckNumArgs()
+	fp.StartOptional()
+	include_scripts_ := fp.ParseBoolNullable()
+	if fp.HasError() {
+		return
+	}
+	ret, ok := ZifOpcacheGetStatus(executeData.Ctx(), nil, include_scripts_)
+	if ok {
+		returnValue.SetArray(ret)
+	} else {
+		returnValue.SetFalse()
+	}
+})
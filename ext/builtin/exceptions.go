@@ -0,0 +1,161 @@
+package builtin
+
+import (
+	"fmt"
+	"github.com/heyuuu/gophp/php"
+	"github.com/heyuuu/gophp/php/perr"
+	"github.com/heyuuu/gophp/php/types"
+)
+
+// throwableClasses 内置的异常类，按继承顺序注册。parent 为空的类直接实现 Throwable
+var throwableClasses = []struct {
+	name   string
+	parent string
+}{
+	{php.ExceptionClassName, ""},
+	{php.ErrorExceptionClassName, php.ExceptionClassName},
+	{"JsonException", php.ExceptionClassName},
+
+	{php.ErrorClassName, ""},
+	{php.CompileErrorClassName, php.ErrorClassName},
+	{php.ParseErrorClassName, php.CompileErrorClassName},
+	{php.TypeErrorClassName, php.ErrorClassName},
+	{php.ArgumentCountErrorClassName, php.TypeErrorClassName},
+	{php.ValueErrorClassName, php.ErrorClassName},
+	{php.ArithmeticErrorClassName, php.ErrorClassName},
+	{php.DivisionByZeroErrorClassName, php.ArithmeticErrorClassName},
+	{php.UnhandledMatchErrorClassName, php.ErrorClassName},
+
+	// SPL
+	{"LogicException", php.ExceptionClassName},
+	{"BadFunctionCallException", "LogicException"},
+	{"BadMethodCallException", "BadFunctionCallException"},
+	{"DomainException", "LogicException"},
+	{"InvalidArgumentException", "LogicException"},
+	{"LengthException", "LogicException"},
+	{"OutOfRangeException", "LogicException"},
+	{"RuntimeException", php.ExceptionClassName},
+	{"OutOfBoundsException", "RuntimeException"},
+	{"OverflowException", "RuntimeException"},
+	{"RangeException", "RuntimeException"},
+	{"UnderflowException", "RuntimeException"},
+	{"UnexpectedValueException", "RuntimeException"},
+}
+
+// RegisterThrowableClasses 注册 Throwable 接口、基础实现 Exception 和 Error 及其子类
+func RegisterThrowableClasses(ctx *php.Context, moduleNumber int) {
+	php.RegisterInternalInterface(ctx, moduleNumber, &types.InternalClassDecl{
+		Name: php.ThrowableClassName,
+	})
+	for _, c := range throwableClasses {
+		var ce *types.Class
+		if c.parent == "" {
+			ce = registerBaseThrowable(ctx, moduleNumber, c.name)
+		} else {
+			ce = php.RegisterInternalClass(ctx, moduleNumber, &types.InternalClassDecl{
+				Name:   c.name,
+				Parent: c.parent,
+			})
+		}
+		if c.name == php.ErrorExceptionClassName {
+			php.DeclPropertyInfo(ce, types.AccProtected, "severity", nil, types.ZvalLong(int(perr.E_ERROR)))
+			declMethod(ce, moduleNumber, "__construct", types.AccPublic, errorExceptionConstruct)
+			declMethod(ce, moduleNumber, "getSeverity", types.AccPublic|types.AccFinal, throwablePropGetter("severity"))
+		}
+		php.DoLinkClass(ctx, ce)
+	}
+}
+
+// registerBaseThrowable 注册 Exception 或 Error，两者的属性及方法相同
+func registerBaseThrowable(ctx *php.Context, moduleNumber int, name string) *types.Class {
+	ce := php.RegisterInternalClass(ctx, moduleNumber, &types.InternalClassDecl{
+		Name:       name,
+		Interfaces: []string{php.ThrowableClassName},
+	})
+	php.DeclPropertyInfo(ce, types.AccProtected, "message", nil, types.ZvalString(""))
+	php.DeclPropertyInfo(ce, types.AccPrivate, "string", nil, types.ZvalString(""))
+	php.DeclPropertyInfo(ce, types.AccProtected, "code", nil, types.ZvalLong(0))
+	php.DeclPropertyInfo(ce, types.AccProtected, "file", nil, types.ZvalString(""))
+	php.DeclPropertyInfo(ce, types.AccProtected, "line", nil, types.ZvalLong(0))
+	php.DeclPropertyInfo(ce, types.AccPrivate, "trace", nil, types.ZvalArrayInit())
+	php.DeclPropertyInfo(ce, types.AccPrivate, "previous", nil, types.Null)
+
+	declMethod(ce, moduleNumber, "__construct", types.AccPublic, throwableConstruct)
+	declMethod(ce, moduleNumber, "getMessage", types.AccPublic|types.AccFinal, throwablePropGetter("message"))
+	declMethod(ce, moduleNumber, "getCode", types.AccPublic|types.AccFinal, throwablePropGetter("code"))
+	declMethod(ce, moduleNumber, "getFile", types.AccPublic|types.AccFinal, throwablePropGetter("file"))
+	declMethod(ce, moduleNumber, "getLine", types.AccPublic|types.AccFinal, throwablePropGetter("line"))
+	declMethod(ce, moduleNumber, "getTrace", types.AccPublic|types.AccFinal, throwablePropGetter("trace"))
+	declMethod(ce, moduleNumber, "getPrevious", types.AccPublic|types.AccFinal, throwablePropGetter("previous"))
+	declMethod(ce, moduleNumber, "getTraceAsString", types.AccPublic|types.AccFinal, throwableGetTraceAsString)
+	declMethod(ce, moduleNumber, "__toString", types.AccPublic, throwableToString)
+	return ce
+}
+
+func declMethod(ce *types.Class, moduleNumber int, name string, flags uint32, handler php.ZifHandler) {
+	fn := types.NewInternalFunction(name, handler, moduleNumber)
+	fn.AddFnFlags(flags)
+	php.DeclMethod(ce, fn)
+}
+
+// throwableConstruct Exception::__construct($message = "", $code = 0, $previous = null)
+func throwableConstruct(ex *php.ExecuteData, returnValue *types.Zval) {
+	ctx, obj := ex.Ctx(), ex.ThisObject()
+	if message := ex.Arg(0).DeRef(); !message.IsUndef() {
+		php.SetThrowableProp(obj, "message", types.ZvalString(php.ZvalGetStrVal(ctx, message)))
+	}
+	if code := ex.Arg(1).DeRef(); !code.IsUndef() {
+		php.SetThrowableProp(obj, "code", types.ZvalLong(php.ZvalGetLong(ctx, code)))
+	}
+	setPrevious(ex, obj, 2)
+}
+
+// errorExceptionConstruct ErrorException::__construct($message = "", $code = 0, $severity = E_ERROR, $filename = null, $line = null, $previous = null)
+func errorExceptionConstruct(ex *php.ExecuteData, returnValue *types.Zval) {
+	ctx, obj := ex.Ctx(), ex.ThisObject()
+	if message := ex.Arg(0).DeRef(); !message.IsUndef() {
+		php.SetThrowableProp(obj, "message", types.ZvalString(php.ZvalGetStrVal(ctx, message)))
+	}
+	if code := ex.Arg(1).DeRef(); !code.IsUndef() {
+		php.SetThrowableProp(obj, "code", types.ZvalLong(php.ZvalGetLong(ctx, code)))
+	}
+	if severity := ex.Arg(2).DeRef(); !severity.IsUndef() {
+		php.SetThrowableProp(obj, "severity", types.ZvalLong(php.ZvalGetLong(ctx, severity)))
+	}
+	if filename := ex.Arg(3).DeRef(); !filename.IsUndef() && !filename.IsNull() {
+		php.SetThrowableProp(obj, "file", types.ZvalString(php.ZvalGetStrVal(ctx, filename)))
+	}
+	if line := ex.Arg(4).DeRef(); !line.IsUndef() && !line.IsNull() {
+		php.SetThrowableProp(obj, "line", types.ZvalLong(php.ZvalGetLong(ctx, line)))
+	}
+	setPrevious(ex, obj, 5)
+}
+
+// setPrevious 设置第 n 个参数传入的 previous 异常，只接受 null 或 Throwable
+func setPrevious(ex *php.ExecuteData, obj *types.Object, n int) {
+	ctx := ex.Ctx()
+	previous := ex.Arg(n).DeRef()
+	if previous.IsUndef() || previous.IsNull() {
+		return
+	}
+	if !previous.IsObject() || !php.IsThrowable(ctx, previous.Object()) {
+		php.ThrowError(ctx, php.ThrowableClass(ctx, php.TypeErrorClassName), fmt.Sprintf("%s::__construct(): Argument #%d ($previous) must be of type ?Throwable, %s given", obj.ClassName(), n+1, types.ZendZvalTypeName(previous)))
+		return
+	}
+	php.SetThrowableProp(obj, "previous", previous)
+}
+
+func throwablePropGetter(name string) php.ZifHandler {
+	return func(ex *php.ExecuteData, returnValue *types.Zval) {
+		*returnValue = php.ThrowableProp(ex.ThisObject(), name)
+	}
+}
+
+func throwableGetTraceAsString(ex *php.ExecuteData, returnValue *types.Zval) {
+	trace := php.ThrowableProp(ex.ThisObject(), "trace")
+	*returnValue = types.ZvalString(php.TraceAsString(ex.Ctx(), trace))
+}
+
+func throwableToString(ex *php.ExecuteData, returnValue *types.Zval) {
+	*returnValue = types.ZvalString(php.ThrowableString(ex.Ctx(), ex.ThisObject()))
+}
@@ -296,3 +296,42 @@ func ZifFunctionExists(ctx *php.Context, functionName string) bool {
 func ZifSetTimeLimit(ctx *php.Context, seconds int) bool {
 	return true
 }
+
+func ZifOpcacheGetStatus(ctx *php.Context, _ zpp.Opt, includeScripts_ *bool) (*types.Array, bool) {
+	var includeScripts = lang.Option(includeScripts_, true)
+	cache := ctx.Engine().ScriptCache()
+	if !php.OpcacheEnabled(ctx) || cache == nil {
+		return nil, false
+	}
+
+	status := cache.Status(includeScripts)
+	statistics := types.NewArrayCap(6)
+	statistics.AddAssocLong("num_cached_scripts", status.NumCachedScripts)
+	statistics.AddAssocLong("hits", int(status.Hits))
+	statistics.AddAssocLong("misses", int(status.Misses))
+	statistics.AddAssocDouble("opcache_hit_rate", status.HitRate())
+	statistics.AddAssocLong("start_time", int(status.StartTime.Unix()))
+	if status.LastRestartTime.IsZero() {
+		statistics.AddAssocLong("last_restart_time", 0)
+	} else {
+		statistics.AddAssocLong("last_restart_time", int(status.LastRestartTime.Unix()))
+	}
+
+	arr := types.NewArrayCap(3)
+	arr.AddAssocBool("opcache_enabled", true)
+	arr.AddAssocZval("opcache_statistics", types.ZvalArray(statistics))
+	if includeScripts {
+		scripts := types.NewArrayCap(len(status.Scripts))
+		for _, script := range status.Scripts {
+			item := types.NewArrayCap(5)
+			item.AddAssocStr("full_path", script.FullPath)
+			item.AddAssocLong("hits", int(script.Hits))
+			item.AddAssocLong("memory_consumption", int(script.Size))
+			item.AddAssocLong("timestamp", int(script.Timestamp.Unix()))
+			item.AddAssocLong("last_used_timestamp", int(script.LastUsed.Unix()))
+			scripts.AddAssocZval(script.FullPath, types.ZvalArray(item))
+		}
+		arr.AddAssocZval("scripts", types.ZvalArray(scripts))
+	}
+	return arr, true
+}
@@ -399,12 +399,12 @@ func ZifFmod(x float64, y float64) float64 {
 }
 func ZifIntdiv(ctx *php.Context, dividend int, divisor int) int {
 	if divisor == 0 {
-		php.ThrowException(ctx, nil, "Division by zero", 0)
+		php.ThrowException(ctx, php.ThrowableClass(ctx, php.DivisionByZeroErrorClassName), "Division by zero", 0)
 		return 0
 	} else if divisor == -1 && dividend == types.MinLong {
 		/* Prevent overflow error/crash ... really should not happen:
 		   We don't return a float here as that violates function contract */
-		php.ThrowException(ctx, nil, "Division of PHP_INT_MIN by -1 is not an integer", 0)
+		php.ThrowException(ctx, php.ThrowableClass(ctx, php.ArithmeticErrorClassName), "Division of PHP_INT_MIN by -1 is not an integer", 0)
 		return 0
 	}
 	return dividend / divisor
@@ -109,7 +109,7 @@ func PhpRandomIntSafe(min int, max int) (int, bool) {
 
 func ZifRandomBytes(ctx *php.Context, length int) string {
 	if length < 1 {
-		php.ThrowException(ctx, nil, "Length must be greater than 0", 0)
+		php.ThrowError(ctx, nil, "Length must be greater than 0")
 		return ""
 	}
 
@@ -121,7 +121,7 @@ func ZifRandomBytes(ctx *php.Context, length int) string {
 }
 func ZifRandomInt(ctx *php.Context, min int, max int) int {
 	if min > max {
-		php.ThrowException(ctx, nil, "Minimum value must be less than or equal to the maximum value", 0)
+		php.ThrowError(ctx, nil, "Minimum value must be less than or equal to the maximum value")
 		return 0
 	}
 	num, ok := PhpRandomIntSafe(min, max)
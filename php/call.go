@@ -3,7 +3,9 @@ package php
 import "github.com/heyuuu/gophp/php/types"
 
 func CallMethod(ctx *Context, object *types.Object, ce *types.Class, fn *types.Function, args []types.Zval) types.Zval {
-	// todo
+	if object != nil {
+		return ctx.executor.doCall(fn, args, object)
+	}
 	return ctx.executor.doCall(fn, args, ce)
 }
 
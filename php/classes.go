@@ -1,12 +1,94 @@
 package php
 
 import (
+	"github.com/heyuuu/gophp/compile/ast"
 	"github.com/heyuuu/gophp/kits/ascii"
 	"github.com/heyuuu/gophp/php/types"
 )
 
+// MemberFlags 类成员(常量、属性、方法)的修饰符，未指定访问级别时为 public
+func MemberFlags(astFlags ast.Flags) uint32 {
+	var flags uint32
+	if astFlags&ast.FlagProtected != 0 {
+		flags |= types.AccProtected
+	} else if astFlags&ast.FlagPrivate != 0 {
+		flags |= types.AccPrivate
+	} else {
+		flags |= types.AccPublic
+	}
+	if astFlags&ast.FlagStatic != 0 {
+		flags |= types.AccStatic
+	}
+	if astFlags&ast.FlagAbstract != 0 {
+		flags |= types.AccAbstract
+	}
+	if astFlags&ast.FlagFinal != 0 {
+		flags |= types.AccFinal
+	}
+	return flags
+}
+
+// ClassFlags 类声明的修饰符，类的 flags 与成员的 flags 含义不同
+func ClassFlags(astFlags ast.Flags) uint32 {
+	var flags uint32
+	if astFlags&ast.FlagAbstract != 0 {
+		flags |= types.AccExplicitAbstractClass
+	}
+	if astFlags&ast.FlagFinal != 0 {
+		flags |= types.AccFinal
+	}
+	return flags
+}
+
+// CurrentScope 当前执行代码所属的类，即正在执行的方法声明所在的类。include 的文件使用调用方的作用域，
+// 内部函数(如 array_map 解析回调时)使用调用方的作用域
+func CurrentScope(ctx *Context) *types.Class {
+	ex := ctx.CurrEX()
+	for ex != nil && ex.Fn() != nil && ex.Fn().IsInternalFunction() {
+		ex = ex.Prev()
+	}
+	if ex == nil {
+		return nil
+	}
+	if ex.Fn() != nil && ex.Fn().Scope() != nil {
+		return ex.Fn().Scope()
+	}
+	return ex.ThisClass()
+}
+
+// memberAccessible 当前作用域是否可访问 ce 中声明的成员
+func memberAccessible(ctx *Context, flags uint32, ce *types.Class) bool {
+	if flags&types.AccPublic != 0 {
+		return true
+	}
+	scope := CurrentScope(ctx)
+	if scope == nil {
+		return false
+	}
+	if flags&types.AccPrivate != 0 {
+		return scope == ce
+	}
+	return InstanceofFunction(scope, ce) || InstanceofFunction(ce, scope)
+}
+
+// PropertyAccessible 当前作用域是否可访问属性
+func PropertyAccessible(ctx *Context, info *types.PropertyInfo) bool {
+	return memberAccessible(ctx, info.Flags(), info.Ce())
+}
+
+// ClassConstantAccessible 当前作用域是否可访问类常量
+func ClassConstantAccessible(ctx *Context, c *types.ClassConstant) bool {
+	return memberAccessible(ctx, c.Flags(), c.Ce())
+}
+
+// MethodAccessible 当前作用域是否可调用方法
+func MethodAccessible(ctx *Context, fn *types.Function) bool {
+	return memberAccessible(ctx, fn.Flags(), fn.Scope())
+}
+
 func DeclClassConst(ce *types.Class, flags uint32, name string, value types.Zval) {
 	constant := types.NewClassConstant(name, value, "", flags)
+	constant.SetCe(ce)
 	ce.ConstantTable().Add(ascii.StrToLower(name), constant)
 }
 
@@ -19,8 +101,10 @@ func DeclPropertyInfo(ce *types.Class, flags uint32, name string, typ *types.Typ
 	}
 
 	propInfo := types.NewPropertyInfo(flags, propName, typ, defaultValue)
+	propInfo.SetCe(ce)
 
-	ce.PropertyTable().Add(ascii.StrToLower(propName), propInfo)
+	// 属性表以未修饰的属性名为 key，与对象读写属性时的查找一致
+	ce.PropertyTable().Add(ascii.StrToLower(name), propInfo)
 	return propInfo
 }
 
@@ -28,11 +112,14 @@ func DeclMethod(ce *types.Class, fn *types.Function) {
 	fn.SetScope(ce)
 	lcName := ascii.StrToLower(fn.Name())
 	ce.FunctionTable().Add(lcName, fn)
+	setMagicMethod(ce, lcName, fn)
+}
 
+func setMagicMethod(ce *types.Class, lcName string, fn *types.Function) {
 	switch lcName {
-	case "__constructor":
+	case "__construct":
 		ce.SetConstructor(fn)
-	case "__destructor":
+	case "__destruct":
 		ce.SetDestructor(fn)
 	case "__clone":
 		ce.SetClone(fn)
@@ -50,7 +137,7 @@ func DeclMethod(ce *types.Class, fn *types.Function) {
 		ce.SetCallstatic(fn)
 	case "__tostring":
 		ce.SetTostring(fn)
-	case "__debugInfo":
+	case "__debuginfo":
 		ce.SetDebugInfo(fn)
 	case "__serialize":
 		ce.SetSerializeFunc(fn)
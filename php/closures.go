@@ -0,0 +1,324 @@
+package php
+
+import (
+	"fmt"
+	"github.com/heyuuu/gophp/kits/ascii"
+	"github.com/heyuuu/gophp/php/perr"
+	"github.com/heyuuu/gophp/php/types"
+	"strings"
+)
+
+const ClosureClassName = "Closure"
+
+// RegisterClosureClass 注册 Closure 类，其实例只能通过闭包表达式创建
+func RegisterClosureClass(ctx *Context, moduleNumber int) {
+	ce := RegisterInternalClass(ctx, moduleNumber, &types.InternalClassDecl{
+		Name:  ClosureClassName,
+		Flags: types.AccFinal,
+	})
+	ce.SetCreateObject(func(classType *types.Class) *types.Object {
+		ThrowError(ctx, nil, "Instantiation of class Closure is not allowed")
+		return nil
+	})
+
+	// 方法表中的 __invoke 不含参数信息，解释器通过 closureObject.GetMethod 获取带有闭包函数签名的 __invoke
+	methods := []struct {
+		name    string
+		flags   uint32
+		handler ZifHandler
+	}{
+		{"bind", types.AccPublic | types.AccStatic, closureBind},
+		{"bindTo", types.AccPublic, closureBindTo},
+		{"call", types.AccPublic, closureCall},
+		{"fromCallable", types.AccPublic | types.AccStatic, closureFromCallable},
+		{"__invoke", types.AccPublic, closureInvoke},
+	}
+	for _, m := range methods {
+		fn := types.NewInternalFunction(m.name, m.handler, moduleNumber)
+		fn.AddFnFlags(m.flags)
+		DeclMethod(ce, fn)
+	}
+}
+
+// closureInvoke Closure::__invoke(mixed ...$args)，以闭包绑定的 $this 或类调用闭包函数
+func closureInvoke(ex *ExecuteData, returnValue *types.Zval) {
+	c, ok := ex.ThisObject().Intern().(*closureObject)
+	if !ok {
+		return
+	}
+	closure, _ := c.GetClosure()
+	*returnValue = CallFunction(ex.Ctx(), c.fn, ex.Args(), closureScope(closure))
+}
+
+// closureBind Closure::bind(Closure $closure, ?object $newthis, $newscope = "static")
+func closureBind(ex *ExecuteData, returnValue *types.Zval) {
+	fp := NewParamParser(ex, 2, 3, 0)
+	closure := fp.ParseObject()
+	newThis := fp.ParseObjectNullable()
+	fp.StartOptional()
+	newScope := fp.ParseZval()
+	if fp.HasError() {
+		return
+	}
+	c, ok := closure.Intern().(*closureObject)
+	if !ok {
+		Error(ex.Ctx(), perr.E_WARNING, "Closure::bind() expects parameter 1 to be Closure, object given")
+		return
+	}
+	*returnValue = bindClosure(ex.Ctx(), c, newThis, newScope)
+}
+
+// closureBindTo Closure::bindTo(?object $newthis, $newscope = "static")
+func closureBindTo(ex *ExecuteData, returnValue *types.Zval) {
+	fp := NewParamParser(ex, 1, 2, 0)
+	newThis := fp.ParseObjectNullable()
+	fp.StartOptional()
+	newScope := fp.ParseZval()
+	if fp.HasError() {
+		return
+	}
+	*returnValue = bindClosure(ex.Ctx(), ex.ThisObject().Intern().(*closureObject), newThis, newScope)
+}
+
+// closureCall Closure::call(object $newthis, mixed ...$args)，临时绑定 $this 及其所属类后调用闭包
+func closureCall(ex *ExecuteData, returnValue *types.Zval) {
+	ctx := ex.Ctx()
+	fp := NewParamParser(ex, 1, -1, 0)
+	newThis := fp.ParseObject()
+	args := fp.ParseVariadic(0)
+	if fp.HasError() {
+		return
+	}
+	c := ex.ThisObject().Intern().(*closureObject)
+	if !validClosureBinding(ctx, c, newThis, newThis.Class()) {
+		return
+	}
+	fn := *c.fn
+	fn.SetScope(newThis.Class())
+	*returnValue = CallFunction(ctx, &fn, args, newThis)
+}
+
+// closureFromCallable Closure::fromCallable(callable $callable)
+func closureFromCallable(ex *ExecuteData, returnValue *types.Zval) {
+	ctx := ex.Ctx()
+	fp := NewParamParser(ex, 1, 1, 0)
+	callable := fp.ParseZval()
+	if fp.HasError() {
+		return
+	}
+	if callable.IsObject() {
+		if _, ok := callable.Object().Intern().(*closureObject); ok {
+			*returnValue = callable
+			return
+		}
+	}
+
+	f, scope, errMsg := ResolveCallable(ctx, callable)
+	if f == nil {
+		ThrowError(ctx, ThrowableClass(ctx, TypeErrorClassName), "Failed to create closure from callable: "+errMsg)
+		return
+	}
+	fn := *f
+	fn.SetIsFakeClosure(true)
+	var this *types.Object
+	var calledScope *types.Class
+	switch s := scope.(type) {
+	case *types.Object:
+		this = s
+	case *types.Class:
+		calledScope = s
+	}
+	*returnValue = types.ZvalObject(NewClosure(ctx, &fn, calledScope, this))
+}
+
+// validClosureBinding 闭包能否绑定到 newThis 及作用域 scope，不能时给出警告，对应 zend_valid_closure_binding
+func validClosureBinding(ctx *Context, c *closureObject, newThis *types.Object, scope *types.Class) bool {
+	fn := c.fn
+	switch {
+	case newThis != nil && fn.IsStatic():
+		Error(ctx, perr.E_WARNING, "Cannot bind an instance to a static closure")
+	case newThis != nil && fn.IsFakeClosure() && fn.Scope() != nil && !InstanceofFunction(newThis.Class(), fn.Scope()):
+		Error(ctx, perr.E_WARNING, fmt.Sprintf("Cannot bind method %s::%s() to object of class %s", fn.Scope().Name(), fn.Name(), newThis.ClassName()))
+	case newThis == nil && fn.IsFakeClosure() && fn.Scope() != nil && !fn.IsStatic():
+		Error(ctx, perr.E_WARNING, "Cannot unbind $this of method")
+	case scope != nil && scope != fn.Scope() && scope.IsInternalClass():
+		Error(ctx, perr.E_WARNING, fmt.Sprintf("Cannot bind closure to scope of internal class %s", scope.Name()))
+	case fn.IsFakeClosure() && scope != fn.Scope() && fn.Scope() == nil:
+		Error(ctx, perr.E_WARNING, "Cannot rebind scope of closure created from function")
+	case fn.IsFakeClosure() && scope != fn.Scope():
+		Error(ctx, perr.E_WARNING, "Cannot rebind scope of closure created from method")
+	default:
+		return true
+	}
+	return false
+}
+
+// bindClosure 复制闭包并绑定新的 $this 及作用域，对应 zend_closure_bind。
+// newScope 为对象时使用其所属类，为 Undef 或 "static" 时保留原作用域；无法绑定时给出警告并返回 null
+func bindClosure(ctx *Context, c *closureObject, newThis *types.Object, newScope types.Zval) types.Zval {
+	scope := c.fn.Scope()
+	if newScope.IsObject() {
+		scope = newScope.Object().Class()
+	} else if !newScope.IsUndef() {
+		if name := ZvalGetStrVal(ctx, newScope); name != "static" {
+			if scope = ZendLookupClassEx(ctx, name, "", 0); scope == nil {
+				Error(ctx, perr.E_WARNING, fmt.Sprintf("Class '%s' not found", name))
+				return types.Null
+			}
+		}
+	}
+
+	if !validClosureBinding(ctx, c, newThis, scope) {
+		return types.Null
+	}
+
+	fn := *c.fn
+	if scope == nil && newThis != nil {
+		// 绑定对象而没有指定作用域时，使用 Closure 类作为作用域，不能访问对象的私有成员
+		scope = ctx.EG().ClassTable().Get(ascii.StrToLower(ClosureClassName))
+	}
+	fn.SetScope(scope)
+	if vars := c.fn.StaticVariables(); vars != nil {
+		fn.SetStaticVariables(vars.Dup())
+	}
+	return types.ZvalObject(NewClosure(ctx, &fn, scope, newThis))
+}
+
+// closureObject Closure 实例，绑定闭包函数及其 $this 和调用时的类
+type closureObject struct {
+	*StdInternObject
+	fn          *types.Function
+	this        *types.Object
+	calledScope *types.Class
+}
+
+// NewClosure 创建闭包对象。fn 的 scope 为闭包声明所在的类，this 为绑定的 $this (静态闭包为 nil)
+func NewClosure(ctx *Context, fn *types.Function, calledScope *types.Class, this *types.Object) *types.Object {
+	ce := ctx.EG().ClassTable().Get(ascii.StrToLower(ClosureClassName))
+	fn.SetIsClosure(true)
+	if this != nil {
+		calledScope = this.Class()
+	}
+	intern := &closureObject{
+		StdInternObject: NewStdInternObject(ctx, ce),
+		fn:              fn,
+		this:            this,
+		calledScope:     calledScope,
+	}
+	return types.NewObjectSkipPropertiesInit(ctx.EG().NextObjectHandle(), intern)
+}
+
+func (o *closureObject) GetClosure() (*types.ClosureData, bool) {
+	return types.NewClosureData(o.calledScope, o.fn, o.this), true
+}
+
+// GetMethod 闭包的 __invoke 方法与闭包函数的参数相同，调用时转发给闭包函数
+func (o *closureObject) GetMethod(method string) *types.Function {
+	lcName := ascii.StrToLower(method)
+	if lcName != "__invoke" {
+		return o.StdInternObject.GetMethod(method)
+	}
+	invoke := types.NewCompiledFunction("__invoke", o.fn.ArgInfos(), ZifHandler(func(ex *ExecuteData, returnValue *types.Zval) {
+		closure, _ := o.GetClosure()
+		*returnValue = CallFunction(o.ctx, o.fn, ex.Args(), closureScope(closure))
+	}))
+	invoke.SetFlags(types.AccPublic | types.AccCallViaTrampoline)
+	invoke.SetScope(o.class)
+	return invoke
+}
+
+func (o *closureObject) ReadProperty(member types.Zval, typ int) types.Zval {
+	ThrowError(o.ctx, nil, "Closure object cannot have properties")
+	return UninitializedZval()
+}
+
+func (o *closureObject) WriteProperty(member types.Zval, value types.Zval) {
+	ThrowError(o.ctx, nil, "Closure object cannot have properties")
+}
+
+// closureScope 调用闭包时的 $this 或类
+func closureScope(closure *types.ClosureData) any {
+	if closure.Obj() != nil {
+		return closure.Obj()
+	}
+	if closure.Ce() != nil {
+		return closure.Ce()
+	}
+	return nil
+}
+
+// ResolveCallable 解析回调: 函数名、"类名::方法名"、[对象或类名, 方法名]、闭包及实现了 __invoke 的对象。
+// 返回被调用的函数及调用时的 $this 或类，无法调用时返回说明原因的错误信息
+func ResolveCallable(ctx *Context, callable types.Zval) (fn *types.Function, scope any, errMsg string) {
+	callable = callable.DeRef()
+	switch {
+	case callable.IsString():
+		name := callable.String()
+		if idx := strings.Index(name, "::"); idx > 0 {
+			return resolveMethodCallable(ctx, types.ZvalString(name[:idx]), name[idx+2:])
+		}
+		name = strings.TrimPrefix(name, "\\")
+		if fn = ctx.EG().FindFunction(name); fn == nil {
+			return nil, nil, fmt.Sprintf("function '%s' not found or invalid function name", name)
+		}
+		return fn, nil, ""
+	case callable.IsArray():
+		arr := callable.Array()
+		if arr.Len() != 2 {
+			return nil, nil, "array must have exactly two members"
+		}
+		target, method := arr.IndexFind(0).DeRef(), arr.IndexFind(1).DeRef()
+		if !method.IsString() || !(target.IsString() || target.IsObject()) {
+			return nil, nil, "first array member is not a valid class name or object"
+		}
+		return resolveMethodCallable(ctx, target, method.String())
+	case callable.IsObject():
+		obj := callable.Object()
+		if closure, ok := obj.GetClosure(); ok {
+			return closure.Fn(), closureScope(closure), ""
+		}
+		if fn = obj.Class().FunctionTable().Get("__invoke"); fn != nil {
+			return fn, obj, ""
+		}
+	}
+	return nil, nil, "no array or string given"
+}
+
+// resolveMethodCallable 解析方法回调，target 为对象或类名
+func resolveMethodCallable(ctx *Context, target types.Zval, method string) (*types.Function, any, string) {
+	var ce *types.Class
+	var this *types.Object
+	if target.IsObject() {
+		this = target.Object()
+		ce = this.Class()
+	} else if ce = ZendLookupClassEx(ctx, target.String(), "", 0); ce == nil {
+		return nil, nil, fmt.Sprintf("class '%s' not found", target.String())
+	}
+
+	fn := ce.FunctionTable().Get(ascii.StrToLower(method))
+	if fn == nil {
+		return nil, nil, fmt.Sprintf("class '%s' does not have a method '%s'", ce.Name(), method)
+	}
+	if !MethodAccessible(ctx, fn) {
+		return nil, nil, fmt.Sprintf("cannot access %s method %s::%s()", visibilityString(fn.Flags()), ce.Name(), fn.Name())
+	}
+	if fn.IsStatic() {
+		return fn, ce, ""
+	}
+	if this == nil {
+		return nil, nil, fmt.Sprintf("non-static method %s::%s() cannot be called statically", ce.Name(), fn.Name())
+	}
+	return fn, this, ""
+}
+
+// NewUserCallable 解析回调并封装为 types.UserCallable，无法调用时返回 nil 及错误信息
+func NewUserCallable(ctx *Context, callable types.Zval) (*types.UserCallable, string) {
+	fn, scope, errMsg := ResolveCallable(ctx, callable)
+	if fn == nil {
+		return nil, errMsg
+	}
+	return types.NewUserCallable(fn, func(args []types.Zval) (types.Zval, bool) {
+		retval := CallFunction(ctx, fn, args, scope)
+		return retval, !ctx.EG().HasException()
+	}), ""
+}
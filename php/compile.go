@@ -2,17 +2,54 @@ package php
 
 import (
 	"github.com/heyuuu/gophp/compile/ast"
+	"github.com/heyuuu/gophp/compile/astcache"
+	"github.com/heyuuu/gophp/compile/diag"
 	"github.com/heyuuu/gophp/compile/parser"
+	"github.com/heyuuu/gophp/php/perr"
 	"github.com/heyuuu/gophp/php/types"
+	"time"
 )
 
 func CompileFile(ctx *Context, fileHandle *FileHandle, skipShebang bool) (*types.Function, error) {
+	// 已转译为 go 代码的脚本无需解析
+	if compile, ok := lookupCompiledScript(fileHandle.OpenedPath()); ok {
+		return compile(), nil
+	}
+
+	cache := ctx.Engine().ScriptCache()
+	if !OpcacheEnabled(ctx) || cache == nil {
+		return compileFile(ctx, fileHandle, skipShebang)
+	}
+
+	path, info, ok := scriptCachePath(fileHandle)
+	if !ok {
+		return compileFile(ctx, fileHandle, skipShebang)
+	}
+	revalidateFreq := time.Duration(ctx.PG().OpcacheRevalidateFreq()) * time.Second
+	if topFn, ok := cache.Lookup(path, skipShebang, ctx.PG().OpcacheValidateTimestamps(), revalidateFreq); ok {
+		return topFn, nil
+	}
+
+	topFn, err := compileFile(ctx, fileHandle, skipShebang)
+	if err != nil {
+		return nil, err
+	}
+	cache.Store(path, skipShebang, topFn, info, ctx.PG().OpcacheMaxAcceleratedFiles())
+	return topFn, nil
+}
+
+// OpcacheEnabled 是否启用已编译脚本缓存，命令行执行时还需开启 opcache.enable_cli
+func OpcacheEnabled(ctx *Context) bool {
+	return ctx.PG().OpcacheEnable() && (!ctx.IsCli() || ctx.PG().OpcacheEnableCli())
+}
+
+func compileFile(ctx *Context, fileHandle *FileHandle, skipShebang bool) (*types.Function, error) {
 	code, err := fileHandle.ReadAllEx()
 	if err != nil {
 		return nil, err
 	}
 
-	astFile, err := parser.ParseCodeEx(code, skipShebang)
+	astFile, err := parseCode(ctx, fileHandle.OpenedPath(), code, skipShebang)
 	if err != nil {
 		return nil, err
 	}
@@ -24,6 +61,42 @@ func CompileFile(ctx *Context, fileHandle *FileHandle, skipShebang bool) (*types
 	return topFn, nil
 }
 
+// parseCode 解析代码，配置了 ast.file_cache 时优先读取缓存
+func parseCode(ctx *Context, filename string, code string, skipShebang bool) (*ast.File, error) {
+	if skipShebang {
+		code = "<?php " + code
+	}
+
+	var cache *astcache.Cache
+	if dir := ctx.PG().AstFileCache(); dir != "" {
+		cache = astcache.New(dir)
+		if astFile, ok := cache.Load(code); ok {
+			return astFile, nil
+		}
+	}
+
+	astFile, diags := parser.ParseCodeWithDiagnostics(code, false)
+	if errs := diags.Errors(); len(errs) > 0 {
+		// 与 PHP 一致，仅报告第一个错误
+		reportCompileDiagnostic(ctx, filename, errs[0])
+		return nil, errs
+	}
+
+	if cache != nil {
+		// 缓存写入失败不影响编译
+		_ = cache.Store(code, astFile)
+	}
+	return astFile, nil
+}
+
+func reportCompileDiagnostic(ctx *Context, filename string, d *diag.Diagnostic) {
+	typ := perr.E_PARSE
+	if d.Code == diag.CodeCompileError {
+		typ = perr.E_COMPILE_ERROR
+	}
+	ErrorTrigger(ctx, perr.NewAt(typ, d.Message, filename, uint32(d.Pos.Line)))
+}
+
 func sortTopStmts(file *ast.File) []ast.Stmt {
 	var preloads []ast.Stmt
 	var stmts []ast.Stmt
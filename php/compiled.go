@@ -0,0 +1,159 @@
+package php
+
+import (
+	"github.com/heyuuu/gophp/php/types"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"sync"
+)
+
+// 转译为 go 代码的脚本注册表(见 php/def.File)，执行或 include 已注册的路径时优先使用转译后的代码而不解析源码。
+// 注册的路径为相对路径时，相对于当前工作目录匹配
+var compiledScripts = struct {
+	mu sync.RWMutex
+	m  map[string]func() *types.Function
+}{m: map[string]func() *types.Function{}}
+
+// RegisterCompiledScript 注册转译后的脚本，compile 返回执行整个文件的顶层函数。通常在生成代码的 init 中调用
+func RegisterCompiledScript(path string, compile func() *types.Function) {
+	compiledScripts.mu.Lock()
+	defer compiledScripts.mu.Unlock()
+	compiledScripts.m[compiledScriptKey(path)] = compile
+}
+
+// HasCompiledScript 路径是否有已注册的转译脚本
+func HasCompiledScript(path string) bool {
+	_, ok := lookupCompiledScript(path)
+	return ok
+}
+
+func lookupCompiledScript(path string) (func() *types.Function, bool) {
+	if path == "" {
+		return nil, false
+	}
+
+	compiledScripts.mu.RLock()
+	defer compiledScripts.mu.RUnlock()
+	if len(compiledScripts.m) == 0 {
+		return nil, false
+	}
+
+	if compile, ok := compiledScripts.m[compiledScriptKey(path)]; ok {
+		return compile, true
+	}
+	if filepath.IsAbs(path) {
+		if wd, err := os.Getwd(); err == nil {
+			if rel, err := filepath.Rel(wd, path); err == nil {
+				compile, ok := compiledScripts.m[compiledScriptKey(rel)]
+				return compile, ok
+			}
+		}
+	}
+	return nil, false
+}
+
+func compiledScriptKey(path string) string {
+	return filepath.ToSlash(filepath.Clean(path))
+}
+
+// compiledLineno 转译函数当前执行到的 PHP 行号，没有行号信息时返回 0。
+// 生成代码中的 //line 指令将 go 代码位置映射到 PHP 文件(见 compile/render)，调用栈中最内层位于该文件的帧即当前执行的语句，
+// 不需要在每条语句执行时记录行号
+func compiledLineno(filename string) int {
+	if filename == "" {
+		return 0
+	}
+	match := compiledFileMatcher(filename)
+	frames := runtime.CallersFrames(callerPCs(2))
+	for {
+		frame, more := frames.Next()
+		if match(frame.File) {
+			return frame.Line
+		}
+		if !more {
+			return 0
+		}
+	}
+}
+
+// executeFuncName Executor.execute 在 go 调用栈中的函数名，每个 execute 帧对应执行栈中的一帧
+var executeFuncName string
+
+func init() {
+	executeFuncName = runtime.FuncForPC(reflect.ValueOf((*Executor).execute).Pointer()).Name()
+}
+
+// compiledFrameLines 执行栈中各转译函数执行到的 PHP 行号，用于调用栈(backtrace)中每一帧的行号。
+// go 调用栈中相邻两个 Executor.execute 帧之间为外层执行帧正在执行的代码，其中最内层位于该函数文件的帧即其执行到的语句。
+// 只能解析当前 goroutine 上的执行帧，生成器及 Fiber 的调用方位于其他 goroutine，没有行号信息
+func compiledFrameLines(ctx *Context) map[*ExecuteData]int {
+	hasCompiled := false
+	for ex := ctx.CurrEX(); ex != nil && !hasCompiled; ex = ex.Prev() {
+		hasCompiled = isCompiledFrame(ex)
+	}
+	if !hasCompiled {
+		return nil
+	}
+
+	lines := map[*ExecuteData]int{}
+	ex := ctx.CurrEX()
+	var match func(file string) bool
+	frames := runtime.CallersFrames(callerPCs(2))
+	for ex != nil {
+		frame, more := frames.Next()
+		if frame.Function == executeFuncName {
+			ex, match = ex.Prev(), nil
+		} else if _, ok := lines[ex]; !ok && isCompiledFrame(ex) {
+			if match == nil {
+				match = compiledFileMatcher(ex.Fn().Filename())
+			}
+			if match(frame.File) {
+				lines[ex] = frame.Line
+			}
+		}
+		if !more {
+			break
+		}
+	}
+	return lines
+}
+
+// isCompiledFrame 是否为转译函数的执行帧
+func isCompiledFrame(ex *ExecuteData) bool {
+	fn := ex.Fn()
+	return fn != nil && fn.IsUserFunction() && fn.Handler() != nil && fn.Filename() != ""
+}
+
+// compiledLineFiles 转译脚本的文件路径到生成代码中 //line 指令完整路径的映射
+var compiledLineFiles sync.Map // map[string]string
+
+// RegisterCompiledLineFile 记录转译脚本 filename 的生成代码中 //line 指令的完整路径(见 def.File.SetLineFile)
+func RegisterCompiledLineFile(filename string, lineFile string) {
+	compiledLineFiles.Store(filename, compiledScriptKey(lineFile))
+}
+
+// compiledFileMatcher 判断 go 调用栈中的文件是否为 filename 的生成代码，按 //line 指令中的完整路径匹配。
+// 没有记录时 //line 指令中即为 filename 本身
+func compiledFileMatcher(filename string) func(file string) bool {
+	lineFile := compiledScriptKey(filename)
+	if v, ok := compiledLineFiles.Load(filename); ok {
+		lineFile = v.(string)
+	}
+	return func(file string) bool {
+		return file == lineFile
+	}
+}
+
+// callerPCs 当前 goroutine 的完整调用栈，skip 与 runtime.Callers 一致
+func callerPCs(skip int) []uintptr {
+	pcs := make([]uintptr, 64)
+	for {
+		n := runtime.Callers(skip+1, pcs)
+		if n < len(pcs) {
+			return pcs[:n]
+		}
+		pcs = make([]uintptr, len(pcs)*2)
+	}
+}
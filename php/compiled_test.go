@@ -0,0 +1,25 @@
+package php
+
+import "testing"
+
+func TestCompiledFileMatcher(t *testing.T) {
+	RegisterCompiledLineFile("matcher/a.php", "/src/matcher/a.php")
+	tests := []struct {
+		filename string
+		file     string
+		want     bool
+	}{
+		{"matcher/a.php", "/src/matcher/a.php", true},
+		// 不同文件的路径后缀相同时不匹配
+		{"matcher/a.php", "/src/other/matcher/a.php", false},
+		{"matcher/a.php", "matcher/a.php", false},
+		// 没有记录 //line 路径时按文件名本身匹配
+		{"matcher/b.php", "matcher/b.php", true},
+		{"matcher/b.php", "/src/matcher/b.php", false},
+	}
+	for _, tt := range tests {
+		if got := compiledFileMatcher(tt.filename)(tt.file); got != tt.want {
+			t.Errorf("compiledFileMatcher(%q)(%q) = %v, want %v", tt.filename, tt.file, got, tt.want)
+		}
+	}
+}
@@ -35,6 +35,12 @@ func RegisterUserConstant(ctx *Context, name string, value types.Zval, flags typ
 func GetConstant(ctx *Context, name string) *types.Constant {
 	name = CleanNsName(name)
 	c := ctx.EG().ConstantTable().Get(name)
+	if c == nil {
+		// 带命名空间的常量名，命名空间部分大小写无关
+		if idx := strings.LastIndexByte(name, NsSeparator); idx >= 0 {
+			c = ctx.EG().ConstantTable().Get(ascii.StrToLower(name[:idx]) + name[idx:])
+		}
+	}
 	if c == nil {
 		c = ctx.EG().ConstantTable().Get(ascii.StrToLower(name))
 		if c != nil && c.IsCaseSensitive() {
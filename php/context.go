@@ -20,6 +20,8 @@ type Context struct {
 
 	eh ErrorHandling
 
+	cli bool // 命令行执行，没有对应的 http 请求
+
 	executor *Executor `get:""`
 }
 
@@ -40,7 +42,7 @@ func initBaseContext(e *Engine) *Context {
 func initContext(e *Engine, baseCtx *Context, request *http.Request, response http.ResponseWriter) *Context {
 	assert.Assert(e != nil && baseCtx != nil)
 
-	ctx := &Context{engine: e, values: map[string]any{}}
+	ctx := &Context{engine: e, values: map[string]any{}, cli: request == nil}
 
 	ctx.cg.Init()
 	ctx.eg.Init(ctx, baseCtx.EG())
@@ -54,11 +56,17 @@ func initContext(e *Engine, baseCtx *Context, request *http.Request, response ht
 }
 
 /* lifecycle */
-func (c *Context) Start()  {}
-func (c *Context) Finish() {}
+func (c *Context) Start() {}
+func (c *Context) Finish() {
+	c.eg.DestroyFibers()
+	c.eg.DestroyGenerators()
+}
 
 func (c *Context) Engine() *Engine { return c.engine }
 
+// IsCli 是否为命令行执行(没有对应的 http 请求)
+func (c *Context) IsCli() bool { return c.cli }
+
 func (c *Context) CG() *CompilerGlobals { return &c.cg }
 func (c *Context) EG() *ExecutorGlobals { return &c.eg }
 func (c *Context) OG() *OutputGlobals   { return &c.og }
@@ -12,7 +12,7 @@ type Definer interface {
 	AsStr(v Val, stage Stage) string
 	/* mics */
 	Arg(v Val, unpack bool) Arg
-	Param(t TypeHint, name string, defaultVal Val, byRef bool, variadic bool)
+	Param(t TypeHint, name string, defaultVal Val, byRef bool, variadic bool) Param
 	/* type */
 	SimpleType(name string) TypeHint
 	IntersectionType(types ...TypeHint) TypeHint
@@ -27,77 +27,94 @@ type Definer interface {
 	String(s string) Val
 	Array(v ...*ArrayItem) Val
 	ArrayItem(key Val, val Val, byRef bool, unpack bool) *ArrayItem
+	List(items ...*ArrayItem) Variable
 	/* variable */
 	Var(name string) Variable
 	VarEx(name Val) Variable
 	VarValue(name string) Val
-	VarValueEx(name Val) Variable
+	VarValueEx(name Val) Val
 	/* index */
-	Index(arr Val, key Val) Val
+	Index(arr Val, key Val) Variable
+	/* property */
+	Prop(obj Val, name string) Variable
+	NullsafeProp(obj Val, name string) Val
+	StaticProp(className string, name string) Variable
 	/* unary op */
 	PrePlus(v Val) Val
 	PreMinus(v Val) Val
 	BooleanNot(v Val) Val
 	BitwiseNot(v Val) Val
-	PreInc(v Variable)
-	PreDec(v Variable)
-	PostInc(v Variable)
-	PostDec(v Variable)
+	PreInc(v Variable) Val
+	PreDec(v Variable) Val
+	PostInc(v Variable) Val
+	PostDec(v Variable) Val
 	/* binary op */
-	BitwiseAnd(v1 Val, v2 Val) Val     // &
-	BitwiseOr(v1 Val, v2 Val) Val      // |
-	BitwiseXor(v1 Val, v2 Val) Val     // ^
-	BooleanAnd(v1 Val, v2 Val) Val     // &&
-	BooleanOr(v1 Val, v2 Val) Val      // ||
-	BooleanXor(v1 Val, v2 Val) Val     // xor
-	Coalesce(v1 Val, v2 Val) Val       // ??
-	Concat(v1 Val, v2 Val) Val         // .
-	Div(v1 Val, v2 Val) Val            // /
-	Equal(v1 Val, v2 Val) Val          // ==
-	Greater(v1 Val, v2 Val) Val        // >
-	GreaterOrEqual(v1 Val, v2 Val) Val // >=
-	Identical(v1 Val, v2 Val) Val      // ===
-	Minus(v1 Val, v2 Val) Val          // -
-	Mod(v1 Val, v2 Val) Val            // %
-	Mul(v1 Val, v2 Val) Val            // *
-	NotEqual(v1 Val, v2 Val) Val       // !=
-	NotIdentical(v1 Val, v2 Val) Val   // !==
-	Plus(v1 Val, v2 Val) Val           // +
-	Pow(v1 Val, v2 Val) Val            // **
-	ShiftLeft(v1 Val, v2 Val) Val      // <<
-	ShiftRight(v1 Val, v2 Val) Val     // >>
-	Smaller(v1 Val, v2 Val) Val        // <
-	SmallerOrEqual(v1 Val, v2 Val) Val // <=
-	Spaceship(v1 Val, v2 Val) Val      // <=>
+	BitwiseAnd(v1 Val, v2 Val) Val        // &
+	BitwiseOr(v1 Val, v2 Val) Val         // |
+	BitwiseXor(v1 Val, v2 Val) Val        // ^
+	BooleanAnd(v1 Val, v2 func() Val) Val // &&
+	BooleanOr(v1 Val, v2 func() Val) Val  // ||
+	BooleanXor(v1 Val, v2 Val) Val        // xor
+	Coalesce(v1 Val, v2 func() Val) Val   // ??
+	Concat(v1 Val, v2 Val) Val            // .
+	Div(v1 Val, v2 Val) Val               // /
+	Equal(v1 Val, v2 Val) Val             // ==
+	Greater(v1 Val, v2 Val) Val           // >
+	GreaterOrEqual(v1 Val, v2 Val) Val    // >=
+	Identical(v1 Val, v2 Val) Val         // ===
+	Minus(v1 Val, v2 Val) Val             // -
+	Mod(v1 Val, v2 Val) Val               // %
+	Mul(v1 Val, v2 Val) Val               // *
+	NotEqual(v1 Val, v2 Val) Val          // !=
+	NotIdentical(v1 Val, v2 Val) Val      // !==
+	Plus(v1 Val, v2 Val) Val              // +
+	Pow(v1 Val, v2 Val) Val               // **
+	ShiftLeft(v1 Val, v2 Val) Val         // <<
+	ShiftRight(v1 Val, v2 Val) Val        // >>
+	Smaller(v1 Val, v2 Val) Val           // <
+	SmallerOrEqual(v1 Val, v2 Val) Val    // <=
+	Spaceship(v1 Val, v2 Val) Val         // <=>
+	/* ternary */
+	Ternary(cond Val, ifVal func() Val, elseVal func() Val) Val // ifVal 为 nil 时即 ?:
 	/* assign op */
 	Assign(v Variable, value Val) Val
 	AssignRef(v Variable, value Variable) Val
 	Unset(v Variable)
-	AssignBitwiseAnd(v Variable, value Val) Val // &=
-	AssignBitwiseOr(v Variable, value Val) Val  // |=
-	AssignBitwiseXor(v Variable, value Val) Val // ^=
-	AssignCoalesce(v Variable, value Val) Val   // ??=
-	AssignConcat(v Variable, value Val) Val     // .=
-	AssignDiv(v Variable, value Val) Val        // /=
-	AssignMinus(v Variable, value Val) Val      // -=
-	AssignMod(v Variable, value Val) Val        // %=
-	AssignMul(v Variable, value Val) Val        // *=
-	AssignPlus(v Variable, value Val) Val       // +=
-	AssignPow(v Variable, value Val) Val        // **=
-	AssignShiftLeft(v Variable, value Val) Val  // <<=
-	AssignShiftRight(v Variable, value Val) Val // >>=
+	AssignBitwiseAnd(v Variable, value Val) Val      // &=
+	AssignBitwiseOr(v Variable, value Val) Val       // |=
+	AssignBitwiseXor(v Variable, value Val) Val      // ^=
+	AssignCoalesce(v Variable, value func() Val) Val // ??=
+	AssignConcat(v Variable, value Val) Val          // .=
+	AssignDiv(v Variable, value Val) Val             // /=
+	AssignMinus(v Variable, value Val) Val           // -=
+	AssignMod(v Variable, value Val) Val             // %=
+	AssignMul(v Variable, value Val) Val             // *=
+	AssignPlus(v Variable, value Val) Val            // +=
+	AssignPow(v Variable, value Val) Val             // **=
+	AssignShiftLeft(v Variable, value Val) Val       // <<=
+	AssignShiftRight(v Variable, value Val) Val      // >>=
+	/* native */
+	IntVal(v Val) int
+	FloatVal(v Val) float64
+	BoolVal(v Val) bool
+	StrVal(v Val) string
+	DivFloat(v1 float64, v2 float64) float64 // 原生 float 的 /
+	ModInt(v1 int, v2 int) int               // 原生 int 的 %
 
 	/* system call */
 	Echo(v ...string)
 	EchoVal(v ...Val)
+	Print(v Val) Val
 	Cast(kind ast.CastKind, v Val) Val
 	Isset(vars ...Variable) Val
 	Empty(v Val) Val
 	Eval(v Val) Val
 	Include(kind ast.IncludeKind, v Val) Val
 	Clone(v Val) Val
-	ErrorSuppress(v Val) Val
-	Exit(v Val)
+	ErrorSuppress(v func() Val) Val
+	Exit(v Val) Val
+	Throw(v Val) Val
+	Instanceof(v Val, className string) Val
 
 	/* const */
 	Const(name string) Val
@@ -108,14 +125,47 @@ type Definer interface {
 	DeclConst(name string, v Val)
 	DeclGlobal(name string)
 	DeclStatic(name string, v Val)
+	DeclFunc(name string, byRef bool, returnType TypeHint, params []Param, body func(d Definer) Val)
+	DeclClass(name string, flags ast.Flags, parent string, interfaces []string, body func(d ClassDefiner))
+	DeclInterface(name string, parents []string, body func(d ClassDefiner))
+	DeclTrait(name string, body func(d ClassDefiner))
+
+	/* closure */
+	Closure(static bool, byRef bool, returnType TypeHint, params []Param, uses []*ClosureUse, body func(d Definer) Val) Val
+	ClosureUse(name string, byRef bool) *ClosureUse
+	ArrowFunction(static bool, byRef bool, returnType TypeHint, params []Param, uses []string, body func(d Definer) Val) Val // uses 为转译时收集的外部变量，创建时按值绑定
+
+	/* generator */
+	Generator(body func(d Definer) Val) func(d Definer) Val // 包装含有 yield 的函数体，调用函数时返回 Generator 对象
+	Yield(key Val, value Val) Val                           // key、value 为 nil 时即省略
+	YieldFrom(v Val) Val
+
+	/* try */
+	Try(body func() *Flow, catches []*Catch, finally func() *Flow) *Flow // 语句块正常结束时返回 nil
+	Catch(types []string, varName string, body func() *Flow) *Catch
+	Return(v Val) *Flow // try 语句块内的 return
+	Jump(n int) *Flow   // try 语句块内跳出语句块的 break/continue
 
 	/* foreach */
 	ForeachIterator(v Val) ForeachIterator
+	ForeachIteratorRef(v Val) ForeachIterator // foreach 按引用遍历，生成器需按引用产出
 
 	/* call like */
-	FuncCall(fnName string, args ...Val) Val
-	New(className string, args ...Val) Val
-	MethodCall(inst Val, methodName string, args ...Val)
-	NullsafeMethodCall(inst Val, methodName string, args ...Val)
-	StaticMethodCall(className string, methodName string, args ...Val)
+	FuncCall(fnName string, args ...Arg) Val
+	FuncCallEx(fn Val, args ...Arg) Val // 动态调用，如 $fn()、$closure()、[$obj, 'method']()
+	New(className string, args ...Arg) Val
+	MethodCall(inst Val, methodName string, args ...Arg) Val
+	NullsafeMethodCall(inst Val, methodName string, args ...Arg) Val
+	StaticMethodCall(className string, methodName string, args ...Arg) Val
+}
+
+// ClassDefiner 类、接口及 trait 的声明体，成员按声明顺序定义
+type ClassDefiner interface {
+	Definer
+	UseTraits(traits []string, adaptations ...*TraitAdaptation)
+	TraitAlias(trait string, method string, flags ast.Flags, newName string) *TraitAdaptation // trait 为空时在所有 trait 中查找
+	TraitInsteadof(trait string, method string, insteadof ...string) *TraitAdaptation
+	DeclClassConst(flags ast.Flags, name string, v Val)
+	DeclProperty(flags ast.Flags, t TypeHint, name string, defaultVal Val)
+	DeclMethod(flags ast.Flags, name string, byRef bool, returnType TypeHint, params []Param, body func(d Definer) Val) // 抽象方法 body 为 nil
 }
@@ -10,4 +10,5 @@ const (
 	StageFuncName
 	StageMethodName
 	StageClassName
+	StagePropertyName
 )
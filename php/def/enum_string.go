@@ -12,11 +12,12 @@ func _() {
 	_ = x[StageFuncName-2]
 	_ = x[StageMethodName-3]
 	_ = x[StageClassName-4]
+	_ = x[StagePropertyName-5]
 }
 
-const _Stage_name = "StageVariableNameStageFuncNameStageMethodNameStageClassName"
+const _Stage_name = "StageVariableNameStageFuncNameStageMethodNameStageClassNameStagePropertyName"
 
-var _Stage_index = [...]uint8{0, 17, 30, 45, 59}
+var _Stage_index = [...]uint8{0, 17, 30, 45, 59, 76}
 
 func (i Stage) String() string {
 	i -= 1
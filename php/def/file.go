@@ -1,8 +1,22 @@
 package def
 
+import (
+	"github.com/heyuuu/gophp/php"
+	"github.com/heyuuu/gophp/php/types"
+	"path/filepath"
+	"runtime"
+)
+
 type File struct {
 	FilePath    string
 	StrictTypes bool
+
+	topFns []topFn
+}
+
+type topFn struct {
+	namespace string
+	fn        func(d TopDefiner) Val
 }
 
 func NewFile(filePath string, strictTypes bool) *File {
@@ -12,6 +26,49 @@ func NewFile(filePath string, strictTypes bool) *File {
 	}
 }
 
+// SetLineFile 设置生成代码中 //line 指令的文件路径，go 调用栈中位于该路径的帧为本文件的代码，据此得到执行到的 PHP 行号。
+// 相对路径与 go 编译器一致，按调用处(即生成的 go 文件)所在目录解析为完整路径，因此须在 //line 指令之前调用
+func (f *File) SetLineFile(path string) {
+	if !filepath.IsAbs(path) {
+		if _, goFile, _, ok := runtime.Caller(1); ok {
+			path = filepath.Join(filepath.Dir(goFile), path)
+		}
+	}
+	php.RegisterCompiledLineFile(f.FilePath, path)
+}
+
+// TopFn 添加命名空间内的顶层代码，按添加顺序执行
 func (f *File) TopFn(namespace string, fn func(d TopDefiner) Val) {
-	// TODO
+	f.topFns = append(f.topFns, topFn{namespace: namespace, fn: fn})
+}
+
+// Function 生成执行整个文件的顶层函数。每次调用生成新的函数，静态变量不在多次执行间共享
+func (f *File) Function() *types.Function {
+	statics := map[string]*types.Reference{}
+	handler := func(ex *php.ExecuteData, ret *types.Zval) {
+		for _, top := range f.topFns {
+			d := newDefiner(ex, f, top.namespace, statics)
+			// 顶层代码中的 return 终止整个文件的执行
+			if v := top.fn(d); v != nil {
+				*ret = d.zval(v)
+				return
+			}
+		}
+	}
+
+	fn := types.NewCompiledFunction("", nil, php.ZifHandler(handler))
+	fn.SetFilename(f.FilePath)
+	fn.SetIsStrictTypes(f.StrictTypes)
+	return fn
+}
+
+// Register 按原始 PHP 文件路径注册到引擎，执行或 include 该路径时使用转译后的代码
+func (f *File) Register() {
+	php.RegisterCompiledScript(f.FilePath, f.Function)
+}
+
+// Execute 执行文件，对应 php.ExecuteScript
+func (f *File) Execute(ctx *php.Context) types.Zval {
+	retval, _ := ctx.Executor().Execute(f.Function())
+	return retval
 }
@@ -0,0 +1,64 @@
+package def_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/heyuuu/gophp/compile/ast"
+	"github.com/heyuuu/gophp/php"
+	"github.com/heyuuu/gophp/php/def"
+)
+
+// 解释执行的脚本与转译的脚本相互 include 及调用函数
+func TestFileRegister(t *testing.T) {
+	dir := t.TempDir()
+	mainPath := filepath.Join(dir, "main.php")
+	code := `<?php
+function hello($name) { return "hello " . $name; }
+$x = 1;
+$r = include 'lib.php';
+echo $r, ",", libfn("go"), ",", $y, ",", $z, ",";
+var_dump(include_once 'lib.php');
+`
+	if err := os.WriteFile(mainPath, []byte(code), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "inc.php"), []byte(`<?php $z = $y * 10;`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// lib.php 仅有转译后的版本
+	lib := def.NewFile(filepath.Join(dir, "lib.php"), false)
+	lib.TopFn("", func(d def.TopDefiner) def.Val {
+		d.DeclFunc("libfn", false, nil, []def.Param{d.Param(nil, "name", nil, false, false)}, func(d def.Definer) def.Val {
+			return d.FuncCall("hello", d.Arg(d.Var("name"), false))
+		})
+		d.Assign(d.Var("y"), d.Plus(d.Var("x"), d.Int(1)))
+		d.Include(ast.KindRequire, d.String("inc.php"))
+		return d.String("lib")
+	})
+	lib.Register()
+
+	engine := php.NewEngine()
+	if err := engine.Start(); err != nil {
+		t.Fatal(err)
+	}
+	var buf strings.Builder
+	ctx := engine.NewContext(nil, nil)
+	engine.HandleContext(ctx, func(ctx *php.Context) {
+		ctx.OG().PushHandler(&buf)
+		fileHandle, err := php.NewFileHandleByFilename(mainPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = php.ExecuteScript(ctx, fileHandle, false); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	if got, want := buf.String(), "lib,hello go,2,20,bool(true)\n"; got != want {
+		t.Errorf("output = %q, want %q", got, want)
+	}
+}
@@ -23,5 +23,5 @@ func DefFunc(name string, minNumArgs uint32, maxNumArgs int32, argInfos []ArgInf
 		}
 	}
 
-	return types.DefFunc(name, handler, realArgInfos)
+	return types.DefFunc(name, handler, realArgInfos, int(minNumArgs), int(maxNumArgs))
 }
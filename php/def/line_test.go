@@ -0,0 +1,71 @@
+package def_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/heyuuu/gophp/php/def"
+)
+
+// 生成代码中的 //line 指令将 go 代码位置映射到 PHP 文件，异常及错误信息中为 PHP 的行号
+func TestFileExecuteLine(t *testing.T) {
+	f := def.NewFile("lib/line.php", false)
+	f.SetLineFile("lib/line.php")
+	f.TopFn("", func(d def.TopDefiner) def.Val {
+		d.DeclFunc("make", false, nil, nil, func(d def.Definer) def.Val {
+//line lib/line.php:3
+			return d.New("Exception")
+		})
+//line lib/line.php:5
+		d.Assign(d.Var("e"), d.FuncCall("make"))
+//line lib/line.php:6
+		d.EchoVal(d.MethodCall(d.Var("e"), "getLine"), d.String(","), d.MethodCall(d.Var("e"), "getFile"), d.String(","))
+//line lib/line.php:7
+		d.EchoVal(d.MethodCall(d.New("Exception"), "getLine"))
+		return nil
+	})
+	if got, want := runFile(t, f), "3,lib/line.php,7"; got != want {
+		t.Errorf("Execute() output = %q, want %q", got, want)
+	}
+}
+
+// 调用栈中每一帧的行号为该帧执行到的语句，而不只是最内层的转译函数
+func TestFileExecuteTraceLine(t *testing.T) {
+	// 上一个函数中的 //line 指令仍然生效，此处的调用位置已被映射，使用绝对路径
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := def.NewFile("lib/trace.php", false)
+	f.SetLineFile(filepath.Join(wd, "lib/trace.php"))
+	f.TopFn("", func(d def.TopDefiner) def.Val {
+//line lib/trace.php:2
+		d.DeclFunc("inner", false, nil, []def.Param{d.Param(nil, "x", nil, false, false)}, func(d def.Definer) def.Val {
+//line lib/trace.php:3
+			return d.FuncCall("intdiv", d.Arg(d.Var("x"), false), d.Arg(d.Int(0), false))
+		})
+//line lib/trace.php:5
+		d.DeclFunc("outer", false, nil, []def.Param{d.Param(nil, "x", nil, false, false)}, func(d def.Definer) def.Val {
+//line lib/trace.php:6
+			d.Assign(d.Var("y"), d.Plus(d.Var("x"), d.Int(1)))
+//line lib/trace.php:7
+			return d.FuncCall("inner", d.Arg(d.Var("y"), false))
+		})
+//line lib/trace.php:9
+		d.Try(func() *def.Flow {
+//line lib/trace.php:10
+			d.FuncCall("outer", d.Arg(d.Int(1), false))
+			return nil
+		}, []*def.Catch{d.Catch([]string{"\\Error"}, "e", func() *def.Flow {
+//line lib/trace.php:12
+			d.EchoVal(d.MethodCall(d.Var("e"), "getTraceAsString"))
+			return nil
+		})}, nil)
+		return nil
+	})
+	want := "#0 lib/trace.php(3): intdiv(2, 0)\n#1 lib/trace.php(7): inner(2)\n#2 lib/trace.php(10): outer(1)\n#3 {main}"
+	if got := runFile(t, f); got != want {
+		t.Errorf("Execute() output = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,895 @@
+package def
+
+import (
+	"fmt"
+	"github.com/heyuuu/gophp/compile/ast"
+	"github.com/heyuuu/gophp/kits/ascii"
+	"github.com/heyuuu/gophp/php"
+	"github.com/heyuuu/gophp/php/perr"
+	"github.com/heyuuu/gophp/php/types"
+	"path/filepath"
+	"strings"
+)
+
+// definer Definer 的运行时实现，每次函数调用对应一个 definer
+type definer struct {
+	ctx       *php.Context
+	ex        *php.ExecuteData
+	file      *File
+	namespace string
+	statics   map[string]*types.Reference // 当前函数的静态变量
+	class     *types.Class                // 类声明体内为声明中的类
+	generator *php.Generator              // 生成器函数体内为当前的生成器
+}
+
+var _ TopDefiner = (*definer)(nil)
+
+func newDefiner(ex *php.ExecuteData, file *File, namespace string, statics map[string]*types.Reference) *definer {
+	return &definer{
+		ctx:       ex.Ctx(),
+		ex:        ex,
+		file:      file,
+		namespace: namespace,
+		statics:   statics,
+	}
+}
+
+func (d *definer) symbols() php.ISymtable { return d.ex.Symbols() }
+
+// globalSymbols 全局符号表，即最外层调用的符号表
+func (d *definer) globalSymbols() php.ISymtable {
+	ex := d.ex
+	for ex.Prev() != nil {
+		ex = ex.Prev()
+	}
+	return ex.Symbols()
+}
+
+// zval 读取 Val 的值，变量在此时求值
+func (d *definer) zval(v Val) types.Zval {
+	switch x := v.(type) {
+	case nil:
+		return types.Null
+	case value:
+		return x.zv
+	case place:
+		return x.fetch(false)
+	default:
+		panic(perr.Internalf("unexpected def.Val type: %T", v))
+	}
+}
+
+// quietZval 读取 Val 的值，变量不存在时返回 Undef 且不报错
+func (d *definer) quietZval(v Val) types.Zval {
+	if p, ok := v.(place); ok {
+		return p.fetch(true)
+	}
+	return d.zval(v)
+}
+
+func (d *definer) place(v Variable) place {
+	if p, ok := v.(place); ok {
+		return p
+	}
+	panic(perr.Internalf("unexpected def.Variable type: %T", v))
+}
+
+func (d *definer) assign(p place, zv types.Zval) {
+	if list, ok := p.(*listPlace); ok {
+		list.destructure(zv)
+		return
+	}
+	if r := p.ref(); r != nil {
+		r.Set(zv)
+	}
+}
+
+// container 获取可写入下标的容器，变量不存在或为 null 时初始化为空数组
+func (d *definer) container(v Val) types.Zval {
+	p, ok := v.(place)
+	if !ok {
+		return d.zval(v)
+	}
+	r := p.ref()
+	if r == nil {
+		return types.Null
+	}
+	zv := r.Get().DeRef()
+	if zv.IsUndef() || zv.IsNull() {
+		zv = types.ZvalArrayInit()
+		r.Set(zv)
+	}
+	return zv
+}
+
+// lookupName 命名空间内的非限定名称优先查找命名空间下的名称，再回退到全局名称
+func (d *definer) lookupName(name string, lookup func(name string) bool) bool {
+	if d.namespace != "" && !strings.ContainsRune(name, '\\') {
+		if lookup(d.namespace + "\\" + name) {
+			return true
+		}
+	}
+	return lookup(php.CleanNsName(name))
+}
+
+func (d *definer) IsTrue(v Val) bool {
+	return php.ZvalIsTrue(d.ctx, d.zval(v))
+}
+
+func (d *definer) AsStr(v Val, stage Stage) string {
+	return php.ZvalGetStrVal(d.ctx, d.zval(v))
+}
+
+// -- misc
+
+func (d *definer) Use(useType ast.UseType, name string, alias string) {
+	// 名称已在转译时解析，运行时无需处理
+}
+
+func (d *definer) Arg(v Val, unpack bool) Arg {
+	return arg{value: v, unpack: unpack}
+}
+
+func (d *definer) Param(t TypeHint, name string, defaultVal Val, byRef bool, variadic bool) Param {
+	return &param{name: name, typ: t, defaultVal: defaultVal, byRef: byRef, variadic: variadic}
+}
+
+// -- type
+
+func (d *definer) SimpleType(name string) TypeHint            { return simpleType{name: name} }
+func (d *definer) IntersectionType(typs ...TypeHint) TypeHint { return intersectionType{types: typs} }
+func (d *definer) UnionType(typs ...TypeHint) TypeHint        { return unionType{types: typs} }
+func (d *definer) NullableType(typ TypeHint) TypeHint         { return nullableType{typ: typ} }
+
+// -- lit
+
+func (d *definer) Undef() Val          { return value{types.Undef} }
+func (d *definer) Null() Val           { return value{types.Null} }
+func (d *definer) Bool(v bool) Val     { return value{types.ZvalBool(v)} }
+func (d *definer) Int(v int) Val       { return value{types.ZvalLong(v)} }
+func (d *definer) Float(v float64) Val { return value{types.ZvalDouble(v)} }
+func (d *definer) String(s string) Val { return value{types.ZvalString(s)} }
+
+func (d *definer) Array(items ...*ArrayItem) Val {
+	arr := types.NewArrayCap(len(items))
+	for _, item := range items {
+		if item == nil {
+			continue
+		}
+
+		if item.Unpack {
+			v := d.zval(item.Val)
+			if !v.IsArray() {
+				php.ThrowError(d.ctx, nil, "Only arrays and Traversables can be unpacked")
+				continue
+			}
+			v.Array().Each(func(key types.ArrayKey, value types.Zval) {
+				if key.IsStrKey() {
+					arr.Update(key, value.DeRef())
+				} else {
+					arr.Append(value.DeRef())
+				}
+			})
+			continue
+		}
+
+		var val types.Zval
+		if item.ByRef {
+			r := d.place(item.Val.(Variable)).ref()
+			if r == nil {
+				continue
+			}
+			val = types.ZvalRef(r.MakeRef())
+		} else {
+			val = d.zval(item.Val)
+		}
+
+		if item.Key != nil {
+			if key, ok := php.ZvalToArrayKey(d.ctx, d.zval(item.Key)); ok {
+				arr.Update(key, val)
+			}
+		} else {
+			arr.Append(val)
+		}
+	}
+	return value{types.ZvalArray(arr)}
+}
+
+func (d *definer) ArrayItem(key Val, val Val, byRef bool, unpack bool) *ArrayItem {
+	return &ArrayItem{Key: key, Val: val, ByRef: byRef, Unpack: unpack}
+}
+
+func (d *definer) List(items ...*ArrayItem) Variable {
+	return &listPlace{d: d, items: items}
+}
+
+// -- variable
+
+func (d *definer) Var(name string) Variable { return &varPlace{d: d, name: name} }
+func (d *definer) VarEx(name Val) Variable {
+	return d.Var(d.AsStr(name, StageVariableName))
+}
+func (d *definer) VarValue(name string) Val { return value{d.zval(d.Var(name))} }
+func (d *definer) VarValueEx(name Val) Val  { return value{d.zval(d.VarEx(name))} }
+
+func (d *definer) Index(arr Val, key Val) Variable {
+	return &indexPlace{d: d, arr: arr, key: key}
+}
+
+// -- property
+
+func (d *definer) Prop(obj Val, name string) Variable {
+	return &propPlace{d: d, obj: obj, name: name}
+}
+
+func (d *definer) NullsafeProp(obj Val, name string) Val {
+	objVal := d.zval(obj)
+	if objVal.IsNull() {
+		return d.Null()
+	}
+	return value{d.zval(d.Prop(value{objVal}, name))}
+}
+
+func (d *definer) StaticProp(className string, name string) Variable {
+	return &staticPropPlace{d: d, className: className, name: name}
+}
+
+// -- unary op
+
+func (d *definer) PrePlus(v Val) Val {
+	return value{php.OpMul(d.ctx, d.zval(v), types.ZvalLong(1))}
+}
+func (d *definer) PreMinus(v Val) Val {
+	return value{php.OpMul(d.ctx, d.zval(v), types.ZvalLong(-1))}
+}
+func (d *definer) BooleanNot(v Val) Val { return value{php.OpBooleanNot(d.ctx, d.zval(v))} }
+func (d *definer) BitwiseNot(v Val) Val { return value{php.OpBitwiseNot(d.ctx, d.zval(v))} }
+
+// incDec 自增/自减，post 为 true 时返回原值
+func (d *definer) incDec(v Variable, inc bool, post bool) Val {
+	p := d.place(v)
+	oldValue := p.fetch(false)
+	var newValue types.Zval
+	if inc {
+		newValue = php.OpAdd(d.ctx, oldValue, types.ZvalLong(1))
+	} else {
+		newValue = php.OpSub(d.ctx, oldValue, types.ZvalLong(1))
+	}
+	d.assign(p, newValue)
+	if post {
+		return value{oldValue}
+	}
+	return value{newValue}
+}
+
+func (d *definer) PreInc(v Variable) Val  { return d.incDec(v, true, false) }
+func (d *definer) PreDec(v Variable) Val  { return d.incDec(v, false, false) }
+func (d *definer) PostInc(v Variable) Val { return d.incDec(v, true, true) }
+func (d *definer) PostDec(v Variable) Val { return d.incDec(v, false, true) }
+
+// -- binary op
+
+type binaryOp func(ctx *php.Context, op1, op2 types.Zval) types.Zval
+
+func (d *definer) binaryOp(op binaryOp, v1 Val, v2 Val) Val {
+	return value{op(d.ctx, d.zval(v1), d.zval(v2))}
+}
+
+func (d *definer) lazy(v func() Val) func() types.Zval {
+	return func() types.Zval { return d.zval(v()) }
+}
+
+func (d *definer) BitwiseAnd(v1 Val, v2 Val) Val { return d.binaryOp(php.OpBitwiseAnd, v1, v2) }
+func (d *definer) BitwiseOr(v1 Val, v2 Val) Val  { return d.binaryOp(php.OpBitwiseOr, v1, v2) }
+func (d *definer) BitwiseXor(v1 Val, v2 Val) Val { return d.binaryOp(php.OpBitwiseXor, v1, v2) }
+func (d *definer) BooleanAnd(v1 Val, v2 func() Val) Val {
+	return value{php.OpBooleanAnd(d.ctx, d.zval(v1), d.lazy(v2))}
+}
+func (d *definer) BooleanOr(v1 Val, v2 func() Val) Val {
+	return value{php.OpBooleanOr(d.ctx, d.zval(v1), d.lazy(v2))}
+}
+func (d *definer) BooleanXor(v1 Val, v2 Val) Val { return d.binaryOp(php.OpBooleanXor, v1, v2) }
+func (d *definer) Coalesce(v1 Val, v2 func() Val) Val {
+	return value{php.OpCoalesce(d.ctx, d.quietZval(v1), d.lazy(v2))}
+}
+func (d *definer) Concat(v1 Val, v2 Val) Val  { return d.binaryOp(php.OpConcat, v1, v2) }
+func (d *definer) Div(v1 Val, v2 Val) Val     { return d.binaryOp(php.OpDiv, v1, v2) }
+func (d *definer) Equal(v1 Val, v2 Val) Val   { return d.binaryOp(php.OpEqual, v1, v2) }
+func (d *definer) Greater(v1 Val, v2 Val) Val { return d.binaryOp(php.OpGreater, v1, v2) }
+func (d *definer) GreaterOrEqual(v1 Val, v2 Val) Val {
+	return d.binaryOp(php.OpGreaterOrEqual, v1, v2)
+}
+func (d *definer) Identical(v1 Val, v2 Val) Val    { return d.binaryOp(php.OpIdentical, v1, v2) }
+func (d *definer) Minus(v1 Val, v2 Val) Val        { return d.binaryOp(php.OpSub, v1, v2) }
+func (d *definer) Mod(v1 Val, v2 Val) Val          { return d.binaryOp(php.OpMod, v1, v2) }
+func (d *definer) Mul(v1 Val, v2 Val) Val          { return d.binaryOp(php.OpMul, v1, v2) }
+func (d *definer) NotEqual(v1 Val, v2 Val) Val     { return d.binaryOp(php.OpNotEqual, v1, v2) }
+func (d *definer) NotIdentical(v1 Val, v2 Val) Val { return d.binaryOp(php.OpNotIdentical, v1, v2) }
+func (d *definer) Plus(v1 Val, v2 Val) Val         { return d.binaryOp(php.OpAdd, v1, v2) }
+func (d *definer) Pow(v1 Val, v2 Val) Val          { return d.binaryOp(php.OpPow, v1, v2) }
+func (d *definer) ShiftLeft(v1 Val, v2 Val) Val    { return d.binaryOp(php.OpSL, v1, v2) }
+func (d *definer) ShiftRight(v1 Val, v2 Val) Val   { return d.binaryOp(php.OpSR, v1, v2) }
+func (d *definer) Smaller(v1 Val, v2 Val) Val      { return d.binaryOp(php.OpSmaller, v1, v2) }
+func (d *definer) SmallerOrEqual(v1 Val, v2 Val) Val {
+	return d.binaryOp(php.OpSmallerOrEqual, v1, v2)
+}
+func (d *definer) Spaceship(v1 Val, v2 Val) Val { return d.binaryOp(php.OpSpaceship, v1, v2) }
+
+func (d *definer) Ternary(cond Val, ifVal func() Val, elseVal func() Val) Val {
+	condVal := d.zval(cond)
+	if php.ZvalIsTrue(d.ctx, condVal) {
+		if ifVal == nil {
+			return value{condVal}
+		}
+		return value{d.zval(ifVal())}
+	}
+	return value{d.zval(elseVal())}
+}
+
+// -- assign op
+
+func (d *definer) Assign(v Variable, val Val) Val {
+	zv := d.zval(val)
+	d.assign(d.place(v), zv)
+	return value{zv}
+}
+
+func (d *definer) AssignRef(v Variable, val Variable) Val {
+	r := d.place(val).ref()
+	if r == nil {
+		return d.Null()
+	}
+	zv := types.ZvalRef(r.MakeRef())
+	if target := d.place(v).ref(); target != nil {
+		target.Set(zv)
+	}
+	return value{zv.DeRef()}
+}
+
+func (d *definer) Unset(v Variable) {
+	d.place(v).unset()
+}
+
+func (d *definer) assignOp(op binaryOp, v Variable, val Val) Val {
+	p := d.place(v)
+	zv := op(d.ctx, p.fetch(false), d.zval(val))
+	d.assign(p, zv)
+	return value{zv}
+}
+
+func (d *definer) AssignBitwiseAnd(v Variable, val Val) Val {
+	return d.assignOp(php.OpBitwiseAnd, v, val)
+}
+func (d *definer) AssignBitwiseOr(v Variable, val Val) Val {
+	return d.assignOp(php.OpBitwiseOr, v, val)
+}
+func (d *definer) AssignBitwiseXor(v Variable, val Val) Val {
+	return d.assignOp(php.OpBitwiseXor, v, val)
+}
+func (d *definer) AssignCoalesce(v Variable, val func() Val) Val {
+	p := d.place(v)
+	if zv := p.fetch(true); !zv.IsUndef() && !zv.IsNull() {
+		return value{zv}
+	}
+	return d.Assign(v, val())
+}
+func (d *definer) AssignConcat(v Variable, val Val) Val { return d.assignOp(php.OpConcat, v, val) }
+func (d *definer) AssignDiv(v Variable, val Val) Val    { return d.assignOp(php.OpDiv, v, val) }
+func (d *definer) AssignMinus(v Variable, val Val) Val  { return d.assignOp(php.OpSub, v, val) }
+func (d *definer) AssignMod(v Variable, val Val) Val    { return d.assignOp(php.OpMod, v, val) }
+func (d *definer) AssignMul(v Variable, val Val) Val    { return d.assignOp(php.OpMul, v, val) }
+func (d *definer) AssignPlus(v Variable, val Val) Val   { return d.assignOp(php.OpAdd, v, val) }
+func (d *definer) AssignPow(v Variable, val Val) Val    { return d.assignOp(php.OpPow, v, val) }
+func (d *definer) AssignShiftLeft(v Variable, val Val) Val {
+	return d.assignOp(php.OpSL, v, val)
+}
+func (d *definer) AssignShiftRight(v Variable, val Val) Val {
+	return d.assignOp(php.OpSR, v, val)
+}
+
+// -- system call
+
+func (d *definer) Echo(v ...string) {
+	for _, s := range v {
+		d.ctx.WriteString(s)
+	}
+}
+
+func (d *definer) EchoVal(v ...Val) {
+	for _, val := range v {
+		php.PrintZval(d.ctx, d.zval(val))
+	}
+}
+
+func (d *definer) Print(v Val) Val {
+	php.PrintZval(d.ctx, d.zval(v))
+	return d.Int(1)
+}
+
+func (d *definer) Cast(kind ast.CastKind, v Val) Val {
+	zv := d.zval(v)
+	switch kind {
+	case ast.CastArray:
+		return value{types.ZvalArray(php.ZvalGetArray(d.ctx, zv))}
+	case ast.CastBool:
+		return d.Bool(php.ZvalIsTrue(d.ctx, zv))
+	case ast.CastDouble:
+		return d.Float(php.ZvalGetDouble(d.ctx, zv))
+	case ast.CastInt:
+		return d.Int(php.ZvalGetLong(d.ctx, zv))
+	case ast.CastObject:
+		return value{types.ZvalObject(php.ZvalGetObject(d.ctx, zv))}
+	case ast.CastString:
+		return d.String(php.ZvalGetStrVal(d.ctx, zv))
+	case ast.CastUnset:
+		return d.Null()
+	default:
+		panic(perr.Internalf("unexpected cast kind: %v", kind))
+	}
+}
+
+func (d *definer) Isset(vars ...Variable) Val {
+	for _, v := range vars {
+		if zv := d.place(v).fetch(true); zv.IsUndef() || zv.IsNull() {
+			return d.Bool(false)
+		}
+	}
+	return d.Bool(true)
+}
+
+func (d *definer) Empty(v Val) Val {
+	zv := d.quietZval(v)
+	return d.Bool(zv.IsUndef() || !php.ZvalIsTrue(d.ctx, zv))
+}
+
+func (d *definer) Eval(v Val) Val {
+	panic(perr.Todof("def.Eval"))
+}
+
+func (d *definer) Include(kind ast.IncludeKind, v Val) Val {
+	path := php.ZvalGetStrVal(d.ctx, d.zval(v))
+	return value{php.IncludeFile(d.ctx, kind, path)}
+}
+
+func (d *definer) Clone(v Val) Val {
+	panic(perr.Todof("def.Clone"))
+}
+
+func (d *definer) ErrorSuppress(v func() Val) Val {
+	var result types.Zval
+	d.ctx.EG().ErrorSuppressScope(func() {
+		result = d.zval(v())
+	})
+	return value{result}
+}
+
+func (d *definer) Exit(v Val) Val {
+	if v == nil {
+		php.Exit(d.ctx)
+	} else if exitVal := d.zval(v); exitVal.IsLong() {
+		php.ExitWithCode(d.ctx, exitVal.Long())
+	} else {
+		d.ctx.WriteString(php.ZvalGetStrVal(d.ctx, exitVal))
+		php.Exit(d.ctx)
+	}
+	return d.Null()
+}
+
+func (d *definer) Instanceof(v Val, className string) Val {
+	zv := d.zval(v)
+	if !zv.IsObject() {
+		return d.Bool(false)
+	}
+
+	var ce *types.Class
+	if isForwardingName(className) {
+		ce = d.fetchClass(className)
+	} else {
+		// 类不存在时结果为 false，不触发错误
+		ce = php.ZendLookupClassEx(d.ctx, php.CleanNsName(className), "", 0)
+	}
+	return d.Bool(ce != nil && php.InstanceofFunction(zv.Object().Class(), ce))
+}
+
+// -- const
+
+func (d *definer) Const(name string) Val {
+	var c *types.Constant
+	d.lookupName(name, func(name string) bool {
+		c = php.GetConstant(d.ctx, name)
+		return c != nil
+	})
+	if c == nil {
+		php.ThrowError(d.ctx, nil, fmt.Sprintf(`Undefined constant "%s"`, php.CleanNsName(name)))
+		return d.Null()
+	}
+	return value{c.Value()}
+}
+
+func (d *definer) ClassConst(className string, name string) Val {
+	ce := d.fetchClass(className)
+	if ce == nil {
+		return d.Null()
+	}
+	if ascii.StrToLower(name) == "class" {
+		return d.String(ce.Name())
+	}
+
+	cc := ce.ConstantTable().Get(ascii.StrToLower(name))
+	if cc == nil {
+		php.ThrowError(d.ctx, nil, fmt.Sprintf("Undefined class constant '%s'", name))
+		return d.Null()
+	}
+	if !php.ClassConstantAccessible(d.ctx, cc) && !(d.class != nil && d.class == cc.Ce()) {
+		php.ThrowError(d.ctx, nil, fmt.Sprintf("Cannot access %s const %s::%s", visibilityName(cc.Flags()), ce.Name(), name))
+		return d.Null()
+	}
+	return value{cc.Value()}
+}
+
+func (d *definer) MagicConst(kind ast.MagicConstKind, val Val) Val {
+	if val != nil {
+		return val
+	}
+
+	switch kind {
+	case ast.MagicConstDir:
+		return d.String(filepath.Dir(php.GetExecutedFilenameVal(d.ctx)))
+	case ast.MagicConstFile:
+		return d.String(php.GetExecutedFilenameVal(d.ctx))
+	case ast.MagicConstFunction:
+		return d.String(d.ex.Fn().Name())
+	case ast.MagicConstNamespace:
+		return d.String(d.namespace)
+	case ast.MagicConstClass:
+		if scope := d.scope(); scope != nil {
+			return d.String(scope.Name())
+		}
+		return d.String("")
+	case ast.MagicConstMethod:
+		name := d.ex.Fn().Name()
+		if scope := d.ex.Fn().Scope(); scope != nil {
+			name = scope.Name() + "::" + name
+		}
+		return d.String(name)
+	default:
+		panic(perr.Todof("def.MagicConst, kind=%d", kind))
+	}
+}
+
+// -- decl
+
+func (d *definer) DeclConst(name string, v Val) {
+	php.RegisterConstant(d.ctx, 0, php.CleanNsName(name), d.zval(v))
+}
+
+func (d *definer) DeclGlobal(name string) {
+	globals := d.globalSymbols()
+	if globals == d.symbols() {
+		return
+	}
+	ref := php.NewSymbolVariable(globals, name).MakeRef()
+	d.symbols().Set(name, types.ZvalRef(ref))
+}
+
+func (d *definer) DeclStatic(name string, v Val) {
+	ref, ok := d.statics[name]
+	if !ok {
+		ref = types.NewReference(d.zval(v))
+		d.statics[name] = ref
+	}
+	d.symbols().Set(name, types.ZvalRef(ref))
+}
+
+func (d *definer) DeclFunc(name string, byRef bool, returnType TypeHint, params []Param, body func(d Definer) Val) {
+	name = php.CleanNsName(name)
+	fn := d.compiledFunction(name, name, byRef, returnType, params, body)
+	if !php.RegisterFunction(d.ctx, name, fn) {
+		php.Error(d.ctx, perr.E_COMPILE_ERROR, fmt.Sprintf("Cannot redeclare %s()", name))
+	}
+}
+
+// compiledFunction 创建转译后的用户函数或方法，fullName 用于错误信息
+func (d *definer) compiledFunction(name string, fullName string, byRef bool, returnType TypeHint, params []Param, body func(d Definer) Val) *types.Function {
+	f := &function{
+		name:       fullName,
+		file:       d.file,
+		namespace:  d.namespace,
+		params:     params,
+		returnType: returnType,
+		body:       body,
+		statics:    map[*types.Function]map[string]*types.Reference{},
+	}
+
+	var argInfos []types.ArgInfo
+	if len(params) > 0 {
+		argInfos = make([]types.ArgInfo, len(params))
+		for i, p := range params {
+			argInfos[i] = types.MakeArgInfo(p.Name(), p.ByRef(), p.Variadic())
+		}
+	}
+
+	fn := types.NewCompiledFunction(name, argInfos, php.ZifHandler(f.handle))
+	fn.SetFilename(d.file.FilePath)
+	fn.SetIsStrictTypes(d.file.StrictTypes)
+	fn.SetIsReturnReference(byRef)
+	fn.SetIsVariadic(len(params) > 0 && params[len(params)-1].Variadic())
+	return fn
+}
+
+// function 转译后的用户函数
+type function struct {
+	name       string
+	file       *File
+	namespace  string
+	params     []Param
+	returnType TypeHint
+	body       func(d Definer) Val
+	statics    map[*types.Function]map[string]*types.Reference // 静态变量按执行的函数区分，trait 方法复制到各个类后互不共享
+}
+
+func (f *function) handle(ex *php.ExecuteData, ret *types.Zval) {
+	statics := f.statics[ex.Fn()]
+	if statics == nil {
+		statics = map[string]*types.Reference{}
+		f.statics[ex.Fn()] = statics
+	}
+
+	d := newDefiner(ex, f.file, f.namespace, statics)
+	if !d.bindParams(f.name, f.params, ex.Args()) {
+		return
+	}
+	v := f.body(d)
+	if v != nil {
+		*ret = d.zval(v)
+	}
+
+	// 标量返回值类型由被调用函数所在文件的 strict_types 决定
+	if typeName := scalarTypeName(f.returnType); typeName != "" {
+		if v == nil {
+			php.ThrowError(d.ctx, php.ThrowableClass(d.ctx, php.TypeErrorClassName), fmt.Sprintf("Return value of %s() must be of the type %s, none returned", f.name, typeName))
+			return
+		}
+		if zv, ok := php.CoerceScalarArg(d.ctx, typeName, *ret, ex.IsRetUseStrictTypes()); !ok {
+			php.ThrowError(d.ctx, php.ThrowableClass(d.ctx, php.TypeErrorClassName), fmt.Sprintf("Return value of %s() must be of the type %s, %s returned", f.name, typeName, types.ZendZvalTypeName(*ret)))
+		} else if zv.Type() != ret.DeRef().Type() {
+			*ret = zv
+		}
+	}
+}
+
+// bindParams 将参数写入符号表，未传入的参数使用默认值。
+// 声明为 int、float、string 或 bool 的参数按调用方的 strict_types 校验并转换
+func (d *definer) bindParams(fnName string, params []Param, args []types.Zval) bool {
+	symbols := d.symbols()
+	for i, p := range params {
+		switch {
+		case p.Variadic():
+			arr := types.NewArray()
+			for j := i; j < len(args); j++ {
+				arr.Append(args[j])
+			}
+			symbols.Set(p.Name(), types.ZvalArray(arr))
+		case i < len(args):
+			arg := args[i]
+			if typeName := scalarTypeName(p.(*param).typ); typeName != "" && !p.ByRef() {
+				zv, ok := php.CoerceScalarArg(d.ctx, typeName, arg, d.ex.IsArgUseStrictTypes())
+				if !ok {
+					php.ThrowError(d.ctx, php.ThrowableClass(d.ctx, php.TypeErrorClassName), fmt.Sprintf("Argument %d passed to %s() must be of the type %s, %s given", i+1, fnName, typeName, types.ZendZvalTypeName(arg)))
+					return false
+				}
+				arg = zv
+			}
+			symbols.Set(p.Name(), arg)
+		case p.Default() != nil:
+			defaultVal := d.zval(p.Default())
+			// 默认值为 null 时参数隐式可空，int 默认值传给 float 参数时转换
+			if typeName := scalarTypeName(p.(*param).typ); typeName != "" && !defaultVal.IsNull() {
+				if zv, ok := php.CoerceScalarArg(d.ctx, typeName, defaultVal, true); ok {
+					defaultVal = zv
+				}
+			}
+			symbols.Set(p.Name(), defaultVal)
+		default:
+			required := i
+			for _, p := range params[i:] {
+				if p.Default() == nil && !p.Variadic() {
+					required++
+				}
+			}
+			php.ThrowError(d.ctx, php.ThrowableClass(d.ctx, php.ArgumentCountErrorClassName), fmt.Sprintf("Too few arguments to function %s(), %d passed and at least %d expected", fnName, len(args), required))
+			return false
+		}
+	}
+	return true
+}
+
+// -- foreach
+
+func (d *definer) ForeachIterator(v Val) ForeachIterator {
+	return d.foreachIterator(v, false)
+}
+
+func (d *definer) ForeachIteratorRef(v Val) ForeachIterator {
+	return d.foreachIterator(v, true)
+}
+
+func (d *definer) foreachIterator(v Val, byRef bool) ForeachIterator {
+	zv := d.zval(v)
+	switch {
+	case zv.IsArray():
+		return &arrayIterator{d: d, arr: zv, pairs: zv.Array().Pairs()}
+	case zv.IsObject():
+		if g, ok := zv.Object().Intern().(*php.Generator); ok {
+			return newGeneratorIterator(d, g, byRef)
+		}
+		panic(perr.Todof("暂未支持非数组的 foreach 操作"))
+	default:
+		php.Error(d.ctx, perr.E_WARNING, fmt.Sprintf("foreach() argument must be of type array|object, %s given", types.ZendZvalTypeName(zv)))
+		return &arrayIterator{d: d}
+	}
+}
+
+// -- call like
+
+// callArgs 计算调用参数，按引用传递的参数传入引用
+func (d *definer) callArgs(fn *types.Function, args []Arg) []types.Zval {
+	if len(args) == 0 {
+		return nil
+	}
+
+	argInfos := fn.ArgInfos()
+	isByRef := func(i int) bool {
+		if i < len(argInfos) {
+			return argInfos[i].ByRef()
+		}
+		if n := len(argInfos); n > 0 && argInfos[n-1].Variadic() {
+			return argInfos[n-1].ByRef()
+		}
+		return false
+	}
+
+	values := make([]types.Zval, 0, len(args))
+	for _, a := range args {
+		if a.Unpack() {
+			v := d.zval(a.Value())
+			if !v.IsArray() {
+				php.ThrowError(d.ctx, nil, "Only arrays and Traversables can be unpacked")
+				return values
+			}
+			v.Array().Each(func(key types.ArrayKey, value types.Zval) {
+				if isByRef(len(values)) {
+					values = append(values, types.ZvalRef(php.NewArrayDimVariable(d.ctx, v, key).MakeRef()))
+				} else {
+					values = append(values, value.DeRef())
+				}
+			})
+			continue
+		}
+
+		if p, ok := a.Value().(place); ok && isByRef(len(values)) {
+			if r := p.ref(); r != nil {
+				values = append(values, types.ZvalRef(r.MakeRef()))
+				continue
+			}
+		}
+		values = append(values, d.zval(a.Value()))
+	}
+	return values
+}
+
+func (d *definer) FuncCall(fnName string, args ...Arg) Val {
+	var fn *types.Function
+	d.lookupName(fnName, func(name string) bool {
+		fn = d.ctx.EG().FindFunction(name)
+		return fn != nil
+	})
+	if fn == nil {
+		php.ThrowError(d.ctx, nil, fmt.Sprintf("Call to undefined function %s()", php.CleanNsName(fnName)))
+		return d.Null()
+	}
+	return value{php.CallFunction(d.ctx, fn, d.callArgs(fn, args), nil)}
+}
+
+func (d *definer) New(className string, args ...Arg) Val {
+	ce := d.fetchClass(className)
+	if ce == nil {
+		return d.Null()
+	}
+	obj := php.ObjectInit(d.ctx, ce)
+	if obj == nil {
+		return d.Null()
+	}
+	if ctor := ce.GetConstructor(); ctor != nil {
+		if !php.MethodAccessible(d.ctx, ctor) {
+			php.ThrowError(d.ctx, nil, fmt.Sprintf("Call to %s %s::%s() from %s", visibilityName(ctor.Flags()), ce.Name(), ctor.Name(), d.scopeDesc()))
+			return d.Null()
+		}
+		php.CallFunction(d.ctx, ctor, d.callArgs(ctor, args), obj)
+	}
+	return value{types.ZvalObject(obj)}
+}
+
+// method 查找可调用的方法，不存在或不可访问时报错并返回 nil
+func (d *definer) method(ce *types.Class, methodName string) *types.Function {
+	method := ce.FunctionTable().Get(ascii.StrToLower(methodName))
+	if method == nil {
+		php.ThrowError(d.ctx, nil, fmt.Sprintf("Call to undefined method %s::%s()", ce.Name(), methodName))
+		return nil
+	}
+	if !php.MethodAccessible(d.ctx, method) {
+		php.ThrowError(d.ctx, nil, fmt.Sprintf("Call to %s method %s::%s() from %s", visibilityName(method.Flags()), ce.Name(), methodName, d.scopeDesc()))
+		return nil
+	}
+	if method.IsAbstract() {
+		php.ThrowError(d.ctx, nil, fmt.Sprintf("Cannot call abstract method %s::%s()", method.Scope().Name(), method.Name()))
+		return nil
+	}
+	return method
+}
+
+func (d *definer) MethodCall(inst Val, methodName string, args ...Arg) Val {
+	obj := d.zval(inst)
+	if !obj.IsObject() {
+		php.ThrowError(d.ctx, nil, fmt.Sprintf("Call to a member function %s() on %s", methodName, types.ZendZvalTypeName(obj)))
+		return d.Null()
+	}
+
+	method := d.method(obj.Object().Class(), methodName)
+	if method == nil {
+		return d.Null()
+	}
+	if method.IsStatic() {
+		return value{php.CallFunction(d.ctx, method, d.callArgs(method, args), obj.Object().Class())}
+	}
+	return value{php.CallFunction(d.ctx, method, d.callArgs(method, args), obj.Object())}
+}
+
+func (d *definer) NullsafeMethodCall(inst Val, methodName string, args ...Arg) Val {
+	obj := d.zval(inst)
+	if obj.IsNull() {
+		return d.Null()
+	}
+	return d.MethodCall(value{obj}, methodName, args...)
+}
+
+func (d *definer) StaticMethodCall(className string, methodName string, args ...Arg) Val {
+	ce := d.fetchClass(className)
+	if ce == nil {
+		return d.Null()
+	}
+	method := d.method(ce, methodName)
+	if method == nil {
+		return d.Null()
+	}
+
+	if !method.IsStatic() {
+		// parent::foo() 等形式在实例方法内调用非静态方法时传递 $this
+		this := d.ex.ThisObject()
+		if this == nil || !php.InstanceofFunction(this.Class(), ce) {
+			php.ThrowError(d.ctx, nil, fmt.Sprintf("Non-static method %s::%s() cannot be called statically", ce.Name(), method.Name()))
+			return d.Null()
+		}
+		return value{php.CallFunction(d.ctx, method, d.callArgs(method, args), this)}
+	}
+
+	// self::、parent::、static:: 保留后期静态绑定的类
+	called := ce
+	if isForwardingName(className) {
+		if c := d.ex.ThisClass(); c != nil && php.InstanceofFunction(c, ce) {
+			called = c
+		}
+	}
+	return value{php.CallFunction(d.ctx, method, d.callArgs(method, args), called)}
+}
+
+func visibilityName(flags uint32) string {
+	switch {
+	case flags&types.AccPrivate != 0:
+		return "private"
+	case flags&types.AccProtected != 0:
+		return "protected"
+	default:
+		return "public"
+	}
+}
@@ -0,0 +1,314 @@
+package def
+
+import (
+	"fmt"
+	"github.com/heyuuu/gophp/compile/ast"
+	"github.com/heyuuu/gophp/kits/ascii"
+	"github.com/heyuuu/gophp/kits/slicekit"
+	"github.com/heyuuu/gophp/php"
+	"github.com/heyuuu/gophp/php/lang"
+	"github.com/heyuuu/gophp/php/perr"
+	"github.com/heyuuu/gophp/php/types"
+)
+
+// scope 当前代码所属的类: 类声明体内为声明中的类，方法内为方法声明所在的类
+func (d *definer) scope() *types.Class {
+	if d.class != nil {
+		return d.class
+	}
+	if fn := d.ex.Fn(); fn != nil && fn.Scope() != nil {
+		return fn.Scope()
+	}
+	return d.ex.ThisClass()
+}
+
+// fetchClass 查找类，支持 self、parent 及 static。类不存在时报错并返回 nil
+func (d *definer) fetchClass(name string) *types.Class {
+	switch ascii.StrToLower(name) {
+	case "self":
+		scope := d.scope()
+		if scope == nil {
+			php.ThrowError(d.ctx, nil, `Cannot use "self" when no class scope is active`)
+		}
+		return scope
+	case "parent":
+		scope := d.scope()
+		if scope == nil {
+			php.ThrowError(d.ctx, nil, `Cannot use "parent" when no class scope is active`)
+			return nil
+		}
+		parent := scope.Parent()
+		if parent == nil && scope.ParentName() != "" {
+			// 类声明体内父类尚未链接
+			parent = php.ZendFetchClassByName(d.ctx, scope.ParentName(), "", 0)
+		}
+		if parent == nil {
+			php.ThrowError(d.ctx, nil, `Cannot use "parent" when current class scope has no parent`)
+		}
+		return parent
+	case "static":
+		// 后期静态绑定，即调用时的类
+		called := d.ex.ThisClass()
+		if called == nil {
+			called = d.class
+		}
+		if called == nil {
+			php.ThrowError(d.ctx, nil, `Cannot use "static" when no class scope is active`)
+		}
+		return called
+	default:
+		return php.ZendFetchClassByName(d.ctx, php.CleanNsName(name), "", 0)
+	}
+}
+
+// isForwardingName self、parent 及 static 调用静态方法时保留调用时的类
+func isForwardingName(name string) bool {
+	switch ascii.StrToLower(name) {
+	case "self", "parent", "static":
+		return true
+	}
+	return false
+}
+
+// scopeDesc 错误信息中的当前作用域
+func (d *definer) scopeDesc() string {
+	if scope := d.scope(); scope != nil {
+		return "scope " + scope.Name()
+	}
+	return "global scope"
+}
+
+// -- class decl
+
+func (d *definer) DeclClass(name string, flags ast.Flags, parent string, interfaces []string, body func(d ClassDefiner)) {
+	d.declClass("class", &types.ClassDecl{
+		Name:       php.CleanNsName(name),
+		Flags:      php.ClassFlags(flags),
+		Parent:     php.CleanNsName(parent),
+		Interfaces: slicekit.Map(interfaces, php.CleanNsName),
+	}, body)
+}
+
+func (d *definer) DeclInterface(name string, parents []string, body func(d ClassDefiner)) {
+	d.declClass("interface", &types.ClassDecl{
+		Name:       php.CleanNsName(name),
+		Flags:      types.AccInterface,
+		Interfaces: slicekit.Map(parents, php.CleanNsName),
+	}, body)
+}
+
+func (d *definer) DeclTrait(name string, body func(d ClassDefiner)) {
+	d.declClass("trait", &types.ClassDecl{
+		Name:  php.CleanNsName(name),
+		Flags: types.AccTrait,
+	}, body)
+}
+
+// declClass 声明类并在链接成功后注册到类表
+func (d *definer) declClass(kind string, decl *types.ClassDecl, body func(d ClassDefiner)) {
+	lcName := ascii.StrToLower(decl.Name)
+	if d.ctx.EG().ClassTable().Get(lcName) != nil {
+		php.Error(d.ctx, perr.E_COMPILE_ERROR, fmt.Sprintf("Cannot declare %s %s, because the name is already in use", kind, decl.Name))
+		return
+	}
+
+	decl.Filename = d.file.FilePath
+	ce := types.NewUserClass(decl)
+
+	inner := *d
+	inner.class = ce
+	c := &classDefiner{definer: &inner, ce: ce}
+	body(c)
+	if c.failed || !c.bindTraits() || !php.DoLinkClass(d.ctx, ce) {
+		return
+	}
+	d.ctx.EG().ClassTable().Set(lcName, ce)
+}
+
+// classDefiner 类声明体，成员直接写入 ce，trait 在声明体结束后合并
+type classDefiner struct {
+	*definer
+	ce     *types.Class
+	uses   []traitUse
+	failed bool
+}
+
+type traitUse struct {
+	traits      []string
+	adaptations []*TraitAdaptation
+}
+
+var _ ClassDefiner = (*classDefiner)(nil)
+
+func (c *classDefiner) compileError(message string) {
+	php.Error(c.ctx, perr.E_COMPILE_ERROR, message)
+	c.failed = true
+}
+
+func (c *classDefiner) UseTraits(traits []string, adaptations ...*TraitAdaptation) {
+	c.uses = append(c.uses, traitUse{traits: slicekit.Map(traits, php.CleanNsName), adaptations: adaptations})
+}
+
+func (c *classDefiner) TraitAlias(trait string, method string, flags ast.Flags, newName string) *TraitAdaptation {
+	return &TraitAdaptation{Trait: php.CleanNsName(trait), Method: method, Flags: flags, NewName: newName}
+}
+
+func (c *classDefiner) TraitInsteadof(trait string, method string, insteadof ...string) *TraitAdaptation {
+	return &TraitAdaptation{Trait: php.CleanNsName(trait), Method: method, Insteadof: slicekit.Map(insteadof, php.CleanNsName)}
+}
+
+func (c *classDefiner) DeclClassConst(flags ast.Flags, name string, v Val) {
+	if c.ce.ConstantTable().Exists(ascii.StrToLower(name)) {
+		c.compileError(fmt.Sprintf("Cannot redefine class constant %s::%s", c.ce.Name(), name))
+		return
+	}
+	php.DeclClassConst(c.ce, php.MemberFlags(flags), name, c.zval(v))
+}
+
+func (c *classDefiner) DeclProperty(flags ast.Flags, t TypeHint, name string, defaultVal Val) {
+	if c.ce.IsInterface() {
+		c.compileError("Interfaces may not include properties")
+		return
+	}
+	if c.ce.PropertyTable().Exists(ascii.StrToLower(name)) {
+		c.compileError(fmt.Sprintf("Cannot redeclare %s::$%s", c.ce.Name(), name))
+		return
+	}
+
+	// 无默认值的类型化属性为未初始化状态
+	zv := types.Null
+	if defaultVal != nil {
+		zv = c.zval(defaultVal)
+	} else if t != nil {
+		zv = types.Undef
+	}
+	php.DeclPropertyInfo(c.ce, php.MemberFlags(flags), name, nil, zv)
+}
+
+func (c *classDefiner) DeclMethod(flags ast.Flags, name string, byRef bool, returnType TypeHint, params []Param, body func(d Definer) Val) {
+	fullName := c.ce.Name() + "::" + name
+	if c.ce.FunctionTable().Exists(ascii.StrToLower(name)) {
+		c.compileError(fmt.Sprintf("Cannot redeclare %s()", fullName))
+		return
+	}
+
+	accFlags := php.MemberFlags(flags)
+	if c.ce.IsInterface() {
+		accFlags |= types.AccAbstract
+	}
+	isAbstract := accFlags&types.AccAbstract != 0
+	if isAbstract && body != nil {
+		c.compileError(fmt.Sprintf("%s function %s() cannot contain body", lang.Cond(c.ce.IsInterface(), "Interface", "Abstract"), fullName))
+		return
+	} else if !isAbstract && body == nil {
+		c.compileError(fmt.Sprintf("Non-abstract method %s() must contain body", fullName))
+		return
+	}
+	if body == nil {
+		body = func(d Definer) Val { return nil }
+	}
+
+	fn := c.compiledFunction(name, fullName, byRef, returnType, params, body)
+	fn.AddFnFlags(accFlags)
+	php.DeclMethod(c.ce, fn)
+}
+
+// traitMethod trait 合并到类中的方法，用于检测不同 trait 间的同名方法冲突
+type traitMethod struct {
+	trait *types.Class
+	fn    *types.Function
+}
+
+// bindTraits 合并 trait 的方法、属性及常量。类自身声明的成员优先于 trait，trait 方法优先于继承的方法
+func (c *classDefiner) bindTraits() bool {
+	if len(c.uses) == 0 {
+		return true
+	}
+
+	var traits []*types.Class
+	var adaptations []*TraitAdaptation
+	for _, use := range c.uses {
+		for _, name := range use.traits {
+			trait := php.ZendFetchClassByName(c.ctx, name, "", 0)
+			if trait == nil {
+				return false
+			}
+			if !trait.IsTrait() {
+				c.compileError(fmt.Sprintf("%s cannot use %s - it is not a trait", c.ce.Name(), trait.Name()))
+				return false
+			}
+			traits = append(traits, trait)
+		}
+		adaptations = append(adaptations, use.adaptations...)
+	}
+	c.ce.SetIsImplementTraits(true)
+
+	// insteadof 排除的 trait 方法，key 为 "trait::method"
+	excluded := map[string]bool{}
+	for _, a := range adaptations {
+		for _, name := range a.Insteadof {
+			excluded[ascii.StrToLower(name)+"::"+ascii.StrToLower(a.Method)] = true
+		}
+	}
+	matches := func(a *TraitAdaptation, trait *types.Class, lcName string) bool {
+		return (a.Trait == "" || ascii.StrToLower(a.Trait) == trait.LcName()) && ascii.StrToLower(a.Method) == lcName
+	}
+
+	added := map[string]traitMethod{}
+	for _, trait := range traits {
+		trait.FunctionTable().Each(func(lcName string, fn *types.Function) {
+			var flags ast.Flags
+			for _, a := range adaptations {
+				if a.Insteadof != nil || !matches(a, trait, lcName) {
+					continue
+				}
+				if a.NewName != "" {
+					c.addTraitMethod(added, trait, fn, a.NewName, a.Flags)
+				} else {
+					flags = a.Flags
+				}
+			}
+			if !excluded[trait.LcName()+"::"+lcName] {
+				c.addTraitMethod(added, trait, fn, fn.Name(), flags)
+			}
+		})
+
+		trait.PropertyTable().Each(func(key string, info *types.PropertyInfo) {
+			if !c.ce.PropertyTable().Exists(key) {
+				_, name, _ := php.UnmanglePropertyName(c.ctx, info.Name())
+				php.DeclPropertyInfo(c.ce, info.Flags(), name, info.Type(), info.DefaultVal())
+			}
+		})
+		trait.ConstantTable().Each(func(key string, cc *types.ClassConstant) {
+			if !c.ce.ConstantTable().Exists(key) {
+				php.DeclClassConst(c.ce, cc.Flags(), cc.Name(), cc.Value())
+			}
+		})
+	}
+	return !c.failed
+}
+
+// addTraitMethod 复制 trait 方法到类中，flags 可修改方法的访问级别
+func (c *classDefiner) addTraitMethod(added map[string]traitMethod, trait *types.Class, fn *types.Function, name string, flags ast.Flags) {
+	lcName := ascii.StrToLower(name)
+	if exists, ok := added[lcName]; ok {
+		if fn.IsAbstract() {
+			return
+		}
+		if !exists.fn.IsAbstract() {
+			c.compileError(fmt.Sprintf("Trait method %s::%s has not been applied as %s::%s, because of collision with %s::%s", trait.Name(), name, c.ce.Name(), name, exists.trait.Name(), name))
+			return
+		}
+		c.ce.FunctionTable().Del(lcName)
+	} else if c.ce.FunctionTable().Exists(lcName) {
+		return
+	}
+
+	method := *fn
+	method.SetName(name)
+	if flags&ast.VisibilityModifierMask != 0 {
+		method.SetFlags(method.Flags()&^types.AccPppMask | php.MemberFlags(flags)&types.AccPppMask)
+	}
+	php.DeclMethod(c.ce, &method)
+	added[lcName] = traitMethod{trait: trait, fn: &method}
+}
@@ -0,0 +1,115 @@
+package def
+
+import (
+	"fmt"
+	"github.com/heyuuu/gophp/php"
+	"github.com/heyuuu/gophp/php/types"
+	"strings"
+)
+
+// boundVar 闭包创建时绑定的外部变量，按引用绑定时 zv 为引用
+type boundVar struct {
+	name string
+	zv   types.Zval
+}
+
+func (d *definer) ClosureUse(name string, byRef bool) *ClosureUse {
+	return &ClosureUse{Name: name, ByRef: byRef}
+}
+
+func (d *definer) Closure(static bool, byRef bool, returnType TypeHint, params []Param, uses []*ClosureUse, body func(d Definer) Val) Val {
+	bound := make([]boundVar, 0, len(uses))
+	for _, use := range uses {
+		if use.ByRef {
+			ref := php.NewSymbolVariable(d.symbols(), use.Name).MakeRef()
+			bound = append(bound, boundVar{name: use.Name, zv: types.ZvalRef(ref)})
+		} else {
+			zv := d.zval(d.Var(use.Name))
+			types.SeparateZval(&zv)
+			bound = append(bound, boundVar{name: use.Name, zv: zv})
+		}
+	}
+	return d.closure(static, byRef, returnType, params, bound, body)
+}
+
+func (d *definer) ArrowFunction(static bool, byRef bool, returnType TypeHint, params []Param, uses []string, body func(d Definer) Val) Val {
+	// 外部不存在的变量不绑定，在箭头函数内读取时报错
+	bound := make([]boundVar, 0, len(uses))
+	for _, name := range uses {
+		if zv := d.quietZval(d.Var(name)); !zv.IsUndef() {
+			types.SeparateZval(&zv)
+			bound = append(bound, boundVar{name: name, zv: zv})
+		}
+	}
+	return d.closure(static, byRef, returnType, params, bound, body)
+}
+
+// closure 创建闭包对象。每个闭包对象有独立的函数，静态变量互不共享；按值绑定的变量每次调用时重新复制写入
+func (d *definer) closure(static bool, byRef bool, returnType TypeHint, params []Param, bound []boundVar, body func(d Definer) Val) Val {
+	fn := d.compiledFunction("{closure}", "{closure}", byRef, returnType, params, func(inner Definer) Val {
+		symbols := inner.(*definer).symbols()
+		for _, v := range bound {
+			zv := v.zv
+			if !zv.IsRef() {
+				types.SeparateZval(&zv)
+			}
+			symbols.Set(v.name, zv)
+		}
+		return body(inner)
+	})
+	fn.SetScope(d.scope())
+
+	// 非静态闭包自动绑定当前的 $this
+	var this *types.Object
+	if static {
+		fn.AddFnFlags(types.AccStatic)
+	} else {
+		this = d.ex.ThisObject()
+	}
+	calledScope := d.ex.ThisClass()
+	if calledScope == nil {
+		calledScope = fn.Scope()
+	}
+	return value{types.ZvalObject(php.NewClosure(d.ctx, fn, calledScope, this))}
+}
+
+func (d *definer) FuncCallEx(fn Val, args ...Arg) Val {
+	callable := d.zval(fn)
+	switch {
+	case callable.IsString():
+		// 动态调用的函数名总是完全限定名称
+		name := callable.String()
+		if idx := strings.Index(name, "::"); idx > 0 {
+			return d.StaticMethodCall(name[:idx], name[idx+2:], args...)
+		}
+		return d.FuncCall("\\"+php.CleanNsName(name), args...)
+	case callable.IsArray():
+		arr := callable.Array()
+		target, method := arr.IndexFind(0).DeRef(), arr.IndexFind(1).DeRef()
+		if arr.Len() != 2 || target.IsUndef() || method.IsUndef() {
+			php.ThrowError(d.ctx, nil, "Array callback must have exactly two elements")
+			return d.Null()
+		}
+		if !method.IsString() {
+			php.ThrowError(d.ctx, nil, "Second array member is not a valid method")
+			return d.Null()
+		}
+		if target.IsObject() {
+			return d.MethodCall(value{target}, method.String(), args...)
+		} else if target.IsString() {
+			return d.StaticMethodCall(target.String(), method.String(), args...)
+		}
+		php.ThrowError(d.ctx, nil, "First array member is not a valid class name or object")
+		return d.Null()
+	case callable.IsObject():
+		f, scope, _ := php.ResolveCallable(d.ctx, callable)
+		if f == nil {
+			php.ThrowError(d.ctx, nil, fmt.Sprintf("Object of type %s is not callable", callable.Object().Class().Name()))
+			return d.Null()
+		}
+		return value{php.CallFunction(d.ctx, f, d.callArgs(f, args), scope)}
+	default:
+		php.ThrowError(d.ctx, nil, "Value not callable")
+		return d.Null()
+	}
+}
@@ -0,0 +1,100 @@
+package def
+
+import (
+	"github.com/heyuuu/gophp/php"
+	"github.com/heyuuu/gophp/php/perr"
+	"github.com/heyuuu/gophp/php/types"
+)
+
+// Generator 返回生成器函数的函数体: 调用时不执行 body，而是返回在首次使用时才开始执行的 Generator 对象
+func (d *definer) Generator(body func(d Definer) Val) func(d Definer) Val {
+	return func(inner Definer) Val {
+		gd := inner.(*definer)
+		obj := php.NewGenerator(gd.ctx, gd.ex, func(g *php.Generator) types.Zval {
+			gd.generator = g
+			if v := body(gd); v != nil {
+				return gd.zval(v)
+			}
+			return types.Null
+		})
+		return value{types.ZvalObject(obj)}
+	}
+}
+
+func (d *definer) Yield(key Val, v Val) Val {
+	k, zv := types.Undef, types.Null
+	if key != nil {
+		k = d.zval(key)
+	}
+	g := d.currGenerator()
+	if v != nil {
+		zv = d.yieldValue(g, v)
+	}
+	sent, thrown := g.Yield(k, zv)
+	if !thrown.IsUndef() {
+		return d.Throw(value{thrown})
+	}
+	return value{sent}
+}
+
+// yieldValue 产出的值。按引用产出(function &gen())时变量产出其引用，其他表达式按值产出并提示
+func (d *definer) yieldValue(g *php.Generator, v Val) types.Zval {
+	if g.ByRef() {
+		if p, ok := v.(place); ok {
+			if _, isList := p.(*listPlace); !isList {
+				if r := p.ref(); r != nil {
+					return types.ZvalRef(r.MakeRef())
+				}
+				return types.Null
+			}
+		}
+		php.Error(d.ctx, perr.E_NOTICE, "Only variable references should be yielded by reference")
+	}
+	return d.zval(v)
+}
+
+func (d *definer) YieldFrom(v Val) Val {
+	result, thrown := d.currGenerator().YieldFrom(d.zval(v))
+	if !thrown.IsUndef() {
+		return d.Throw(value{thrown})
+	}
+	return value{result}
+}
+
+func (d *definer) currGenerator() *php.Generator {
+	if d.generator == nil {
+		panic(perr.Internalf("yield 只能用于生成器函数体内"))
+	}
+	return d.generator
+}
+
+// generatorIterator foreach 遍历生成器，遍历前先 rewind
+type generatorIterator struct {
+	d *definer
+	g *php.Generator
+}
+
+func newGeneratorIterator(d *definer, g *php.Generator, byRef bool) ForeachIterator {
+	if g.Finished() {
+		php.ThrowException(d.ctx, nil, "Cannot traverse an already closed generator", 0)
+		return &arrayIterator{d: d}
+	}
+	if byRef && !g.ByRef() {
+		php.ThrowException(d.ctx, nil, "You can only iterate a generator by-reference if it declared that it yields by-reference", 0)
+		return &arrayIterator{d: d}
+	}
+	g.Rewind()
+	return &generatorIterator{d: d, g: g}
+}
+
+func (it *generatorIterator) Valid() bool { return it.g.Valid() }
+func (it *generatorIterator) Next()       { it.g.Next() }
+func (it *generatorIterator) Key() Val    { return value{it.g.Key()} }
+func (it *generatorIterator) Value() Val  { return value{it.g.Current()} }
+func (it *generatorIterator) ValueRef() Variable {
+	v := it.g.CurrentRef()
+	if v.IsRef() {
+		return &refPlace{d: it.d, r: v.Ref()}
+	}
+	return &refPlace{d: it.d, r: types.NewReference(v)}
+}
@@ -0,0 +1,30 @@
+package def
+
+import (
+	"github.com/heyuuu/gophp/php"
+)
+
+// -- native
+// 转译时类型已证明的变量及运算使用原生 go 值，以下方法用于与 Val 之间的转换及需要运行时错误处理的运算。
+// 拆箱按 php 的类型转换规则进行，仅在参数或返回值校验失败后继续执行等异常情况下才会实际转换
+
+func (d *definer) IntVal(v Val) int       { return php.ZvalGetLong(d.ctx, d.zval(v)) }
+func (d *definer) FloatVal(v Val) float64 { return php.ZvalGetDouble(d.ctx, d.zval(v)) }
+func (d *definer) BoolVal(v Val) bool     { return php.ZvalIsTrue(d.ctx, d.zval(v)) }
+func (d *definer) StrVal(v Val) string    { return php.ZvalGetStrVal(d.ctx, d.zval(v)) }
+
+// DivFloat 除数为 0 时由 Div 处理错误
+func (d *definer) DivFloat(v1 float64, v2 float64) float64 {
+	if v2 == 0 {
+		return d.FloatVal(d.Div(d.Float(v1), d.Float(v2)))
+	}
+	return v1 / v2
+}
+
+// ModInt 除数为 0 时由 Mod 处理错误。go 的 % 与 php 一致，结果符号与被除数相同
+func (d *definer) ModInt(v1 int, v2 int) int {
+	if v2 == 0 {
+		return d.IntVal(d.Mod(d.Int(v1), d.Int(v2)))
+	}
+	return v1 % v2
+}
@@ -0,0 +1,439 @@
+package def_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/heyuuu/gophp/compile/ast"
+	"github.com/heyuuu/gophp/php"
+	_ "github.com/heyuuu/gophp/php/boot"
+	"github.com/heyuuu/gophp/php/def"
+)
+
+func runFile(t *testing.T, f *def.File) string {
+	engine := php.NewEngine()
+	if err := engine.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf strings.Builder
+	ctx := engine.NewContext(nil, nil)
+	engine.HandleContext(ctx, func(ctx *php.Context) {
+		ctx.OG().PushHandler(&buf)
+		f.Execute(ctx)
+	})
+	return buf.String()
+}
+
+// 测试用例的代码与 render 生成的代码形式一致
+func TestFileExecute(t *testing.T) {
+	tests := []struct {
+		name string
+		php  string
+		fn   func(d def.TopDefiner) def.Val
+		want string
+	}{
+		{
+			name: "function",
+			php: `function add($a, $b = 10) { static $n = 0; $n++; return $a + $b + $n; }
+echo add(1), ",", add(1, 2), ",", strlen("abc");`,
+			fn: func(d def.TopDefiner) def.Val {
+				d.DeclFunc("add", false, nil, []def.Param{d.Param(nil, "a", nil, false, false), d.Param(nil, "b", d.Int(10), false, false)}, func(d def.Definer) def.Val {
+					d.DeclStatic("n", d.Int(0))
+					d.PostInc(d.Var("n"))
+					return d.Plus(d.Plus(d.Var("a"), d.Var("b")), d.Var("n"))
+				})
+				d.EchoVal(d.FuncCall("add", d.Arg(d.Int(1), false)), d.String(","), d.FuncCall("add", d.Arg(d.Int(1), false), d.Arg(d.Int(2), false)), d.String(","), d.FuncCall("strlen", d.Arg(d.String("abc"), false)))
+				return nil
+			},
+			want: "12,5,3",
+		},
+		{
+			name: "echo order",
+			php: `function f($s) { echo "[$s]"; return $s; }
+echo "a", f("b"), "c", f("d");`,
+			fn: func(d def.TopDefiner) def.Val {
+				d.DeclFunc("f", false, nil, []def.Param{d.Param(nil, "s", nil, false, false)}, func(d def.Definer) def.Val {
+					d.EchoVal(d.Concat(d.Concat(d.String("["), d.Var("s")), d.String("]")))
+					return d.Var("s")
+				})
+				d.Echo("a")
+				d.EchoVal(d.FuncCall("f", d.Arg(d.String("b"), false)))
+				d.Echo("c")
+				d.EchoVal(d.FuncCall("f", d.Arg(d.String("d"), false)))
+				return nil
+			},
+			want: "a[b]bc[d]d",
+		},
+		{
+			name: "reference",
+			php: `function inc(&$v) { $v++; }
+$a = [1, 2]; inc($a[0]);
+foreach ($a as &$v) { $v *= 10; }
+echo $a[0], ",", $a[1];`,
+			fn: func(d def.TopDefiner) def.Val {
+				d.DeclFunc("inc", false, nil, []def.Param{d.Param(nil, "v", nil, true, false)}, func(d def.Definer) def.Val {
+					d.PostInc(d.Var("v"))
+					return nil
+				})
+				d.Assign(d.Var("a"), d.Array(d.ArrayItem(nil, d.Int(1), false, false), d.ArrayItem(nil, d.Int(2), false, false)))
+				d.FuncCall("inc", d.Arg(d.Index(d.Var("a"), d.Int(0)), false))
+				for it := d.ForeachIteratorRef(d.Var("a")); it.Valid(); it.Next() {
+					d.AssignRef(d.Var("v"), it.ValueRef())
+					d.AssignMul(d.Var("v"), d.Int(10))
+				}
+				d.EchoVal(d.Index(d.Var("a"), d.Int(0)), d.String(","), d.Index(d.Var("a"), d.Int(1)))
+				return nil
+			},
+			want: "20,20",
+		},
+		{
+			name: "global and list",
+			php: `function g() { global $x; $x = 42; }
+g(); [$p, $q] = [$x, $y ?? 1];
+echo $p + $q;`,
+			fn: func(d def.TopDefiner) def.Val {
+				d.DeclFunc("g", false, nil, nil, func(d def.Definer) def.Val {
+					d.DeclGlobal("x")
+					d.Assign(d.Var("x"), d.Int(42))
+					return nil
+				})
+				d.FuncCall("g")
+				d.Assign(d.List(d.ArrayItem(nil, d.Var("p"), false, false), d.ArrayItem(nil, d.Var("q"), false, false)), d.Array(d.ArrayItem(nil, d.Var("x"), false, false), d.ArrayItem(nil, d.Coalesce(d.Var("y"), func() def.Val { return d.Int(1) }), false, false)))
+				d.EchoVal(d.Plus(d.Var("p"), d.Var("q")))
+				return nil
+			},
+			want: "43",
+		},
+		{
+			name: "class",
+			php: `interface Shape { const SIDES = 0; function area(); }
+trait Counter { private static $count = 0; static function inc() { return ++self::$count; } }
+abstract class Base implements Shape {
+	protected $label = "base";
+	function name() { return static::class . ":" . $this->label; }
+	static function create(...$args) { return new static(...$args); }
+}
+class Rect extends Base {
+	use Counter;
+	const SIDES = 4;
+	private $w;
+	private $h;
+	function __construct($w = 1, $h = 1) { $this->w = $w; $this->h = $h; }
+	function area() { return $this->w * $this->h; }
+}
+$r = Rect::create(3, 4);
+echo $r->name(), ",", $r->area(), ",", Rect::SIDES, ",", Rect::inc(), Rect::inc(), ",", $r instanceof Shape;`,
+			fn: func(d def.TopDefiner) def.Val {
+				d.DeclInterface("Shape", nil, func(d def.ClassDefiner) {
+					d.DeclClassConst(0, "SIDES", d.Int(0))
+					d.DeclMethod(0, "area", false, nil, nil, nil)
+				})
+				d.DeclTrait("Counter", func(d def.ClassDefiner) {
+					d.DeclProperty(ast.FlagPrivate|ast.FlagStatic, nil, "count", d.Int(0))
+					d.DeclMethod(ast.FlagStatic, "inc", false, nil, nil, func(d def.Definer) def.Val {
+						return d.PreInc(d.StaticProp("self", "count"))
+					})
+				})
+				d.DeclClass("Base", ast.FlagAbstract, "", []string{"\\Shape"}, func(d def.ClassDefiner) {
+					d.DeclProperty(ast.FlagProtected, nil, "label", d.String("base"))
+					d.DeclMethod(0, "name", false, nil, nil, func(d def.Definer) def.Val {
+						return d.Concat(d.Concat(d.ClassConst("static", "class"), d.String(":")), d.Prop(d.Var("this"), "label"))
+					})
+					d.DeclMethod(ast.FlagStatic, "create", false, nil, []def.Param{d.Param(nil, "args", nil, false, true)}, func(d def.Definer) def.Val {
+						return d.New("static", d.Arg(d.Var("args"), true))
+					})
+				})
+				d.DeclClass("Rect", 0, "\\Base", nil, func(d def.ClassDefiner) {
+					d.UseTraits([]string{"\\Counter"})
+					d.DeclClassConst(0, "SIDES", d.Int(4))
+					d.DeclProperty(ast.FlagPrivate, nil, "w", nil)
+					d.DeclProperty(ast.FlagPrivate, nil, "h", nil)
+					d.DeclMethod(0, "__construct", false, nil, []def.Param{d.Param(nil, "w", d.Int(1), false, false), d.Param(nil, "h", d.Int(1), false, false)}, func(d def.Definer) def.Val {
+						d.Assign(d.Prop(d.Var("this"), "w"), d.Var("w"))
+						d.Assign(d.Prop(d.Var("this"), "h"), d.Var("h"))
+						return nil
+					})
+					d.DeclMethod(0, "area", false, nil, nil, func(d def.Definer) def.Val {
+						return d.Mul(d.Prop(d.Var("this"), "w"), d.Prop(d.Var("this"), "h"))
+					})
+				})
+				d.Assign(d.Var("r"), d.StaticMethodCall("\\Rect", "create", d.Arg(d.Int(3), false), d.Arg(d.Int(4), false)))
+				d.EchoVal(d.MethodCall(d.Var("r"), "name"), d.String(","), d.MethodCall(d.Var("r"), "area"), d.String(","), d.ClassConst("\\Rect", "SIDES"), d.String(","), d.StaticMethodCall("\\Rect", "inc"), d.StaticMethodCall("\\Rect", "inc"), d.String(","), d.Instanceof(d.Var("r"), "\\Shape"))
+				return nil
+			},
+			want: "Rect:base,12,4,12,1",
+		},
+		{
+			name: "closure",
+			php: `$a = 1; $b = 2;
+$f = function ($x) use ($a, &$b) { $a++; $b++; return $x + $a + $b; };
+$g = fn($x) => $x * $a;
+$a = 10;
+$arr = [3, 1, 2];
+usort($arr, function ($p, $q) { return $p <=> $q; });
+echo $f(1), ",", $f(1), ",", $a, ",", $b, ",", $g(5), ",", implode(" ", array_map(fn($v) => $v + $a, $arr));`,
+			fn: func(d def.TopDefiner) def.Val {
+				d.Assign(d.Var("a"), d.Int(1))
+				d.Assign(d.Var("b"), d.Int(2))
+				d.Assign(d.Var("f"), d.Closure(false, false, nil, []def.Param{d.Param(nil, "x", nil, false, false)}, []*def.ClosureUse{d.ClosureUse("a", false), d.ClosureUse("b", true)}, func(d def.Definer) def.Val {
+					d.PostInc(d.Var("a"))
+					d.PostInc(d.Var("b"))
+					return d.Plus(d.Plus(d.Var("x"), d.Var("a")), d.Var("b"))
+				}))
+				d.Assign(d.Var("g"), d.ArrowFunction(false, false, nil, []def.Param{d.Param(nil, "x", nil, false, false)}, []string{"a"}, func(d def.Definer) def.Val {
+					return d.Mul(d.Var("x"), d.Var("a"))
+				}))
+				d.Assign(d.Var("a"), d.Int(10))
+				d.Assign(d.Var("arr"), d.Array(d.ArrayItem(nil, d.Int(3), false, false), d.ArrayItem(nil, d.Int(1), false, false), d.ArrayItem(nil, d.Int(2), false, false)))
+				d.FuncCall("usort", d.Arg(d.Var("arr"), false), d.Arg(d.Closure(false, false, nil, []def.Param{d.Param(nil, "p", nil, false, false), d.Param(nil, "q", nil, false, false)}, nil, func(d def.Definer) def.Val {
+					return d.Spaceship(d.Var("p"), d.Var("q"))
+				}), false))
+				d.EchoVal(d.FuncCallEx(d.Var("f"), d.Arg(d.Int(1), false)), d.String(","), d.FuncCallEx(d.Var("f"), d.Arg(d.Int(1), false)), d.String(","), d.Var("a"), d.String(","), d.Var("b"), d.String(","), d.FuncCallEx(d.Var("g"), d.Arg(d.Int(5), false)), d.String(","), d.FuncCall("implode", d.Arg(d.String(" "), false), d.Arg(d.FuncCall("array_map", d.Arg(d.ArrowFunction(false, false, nil, []def.Param{d.Param(nil, "v", nil, false, false)}, []string{"a"}, func(d def.Definer) def.Val {
+					return d.Plus(d.Var("v"), d.Var("a"))
+				}), false), d.Arg(d.Var("arr"), false)), false)))
+				return nil
+			},
+			want: "6,7,10,4,5,11 12 13",
+		},
+		{
+			name: "closure invoke",
+			php: `$k = 2;
+$f = function ($x) use ($k) { return $x * $k; };
+echo $f->__invoke(3), ",", $f->__INVOKE(4);`,
+			fn: func(d def.TopDefiner) def.Val {
+				d.Assign(d.Var("k"), d.Int(2))
+				d.Assign(d.Var("f"), d.Closure(false, false, nil, []def.Param{d.Param(nil, "x", nil, false, false)}, []*def.ClosureUse{d.ClosureUse("k", false)}, func(d def.Definer) def.Val {
+					return d.Mul(d.Var("x"), d.Var("k"))
+				}))
+				d.EchoVal(d.MethodCall(d.Var("f"), "__invoke", d.Arg(d.Int(3), false)))
+				d.Echo(",")
+				d.EchoVal(d.MethodCall(d.Var("f"), "__INVOKE", d.Arg(d.Int(4), false)))
+				return nil
+			},
+			want: "6,8",
+		},
+		{
+			name: "closure captured array",
+			php: `$a = [1, 2];
+$f = function () use ($a) { $a[] = 3; return count($a); };
+$g = fn() => array_push($a, 3);
+echo $f(), $f(), count($a), $g(), $g(), count($a);`,
+			fn: func(d def.TopDefiner) def.Val {
+				d.Assign(d.Var("a"), d.Array(d.ArrayItem(nil, d.Int(1), false, false), d.ArrayItem(nil, d.Int(2), false, false)))
+				d.Assign(d.Var("f"), d.Closure(false, false, nil, nil, []*def.ClosureUse{d.ClosureUse("a", false)}, func(d def.Definer) def.Val {
+					d.Assign(d.Index(d.Var("a"), nil), d.Int(3))
+					return d.FuncCall("\\count", d.Arg(d.Var("a"), false))
+				}))
+				d.Assign(d.Var("g"), d.ArrowFunction(false, false, nil, nil, []string{"a"}, func(d def.Definer) def.Val {
+					return d.FuncCall("\\array_push", d.Arg(d.Var("a"), false), d.Arg(d.Int(3), false))
+				}))
+				d.EchoVal(d.FuncCallEx(d.Var("f")), d.FuncCallEx(d.Var("f")), d.FuncCall("\\count", d.Arg(d.Var("a"), false)), d.FuncCallEx(d.Var("g")), d.FuncCallEx(d.Var("g")), d.FuncCall("\\count", d.Arg(d.Var("a"), false)))
+				return nil
+			},
+			want: "332332",
+		},
+		{
+			name: "generator",
+			php: `function inner() { $x = yield 1; yield $x; return 3; }
+function outer() { $r = yield from inner(); yield "k" => $r; }
+foreach (outer() as $k => $v) { echo "$k=$v,"; }
+$g = outer();
+echo $g->current(), $g->send(2), $g->key(), ",", $g->valid();`,
+			fn: func(d def.TopDefiner) def.Val {
+				d.DeclFunc("inner", false, nil, nil, d.Generator(func(d def.Definer) def.Val {
+					d.Assign(d.Var("x"), d.Yield(nil, d.Int(1)))
+					d.Yield(nil, d.Var("x"))
+					return d.Int(3)
+				}))
+				d.DeclFunc("outer", false, nil, nil, d.Generator(func(d def.Definer) def.Val {
+					d.Assign(d.Var("r"), d.YieldFrom(d.FuncCall("inner")))
+					d.Yield(d.String("k"), d.Var("r"))
+					return nil
+				}))
+				for it := d.ForeachIterator(d.FuncCall("outer")); it.Valid(); it.Next() {
+					d.Assign(d.Var("k"), it.Key())
+					d.Assign(d.Var("v"), it.Value())
+					d.EchoVal(d.Concat(d.Concat(d.Concat(d.Var("k"), d.String("=")), d.Var("v")), d.String(",")))
+				}
+				d.Assign(d.Var("g"), d.FuncCall("outer"))
+				d.EchoVal(d.MethodCall(d.Var("g"), "current"), d.MethodCall(d.Var("g"), "send", d.Arg(d.Int(2), false)), d.MethodCall(d.Var("g"), "key"), d.String(","), d.MethodCall(d.Var("g"), "valid"))
+				return nil
+			},
+			want: "0=1,1=,k=3,121,1",
+		},
+		{
+			name: "generator by ref",
+			php: `function &refs(array &$arr) { foreach ([0, 1] as $i) { yield $arr[$i]; } }
+$data = [1, 2];
+foreach (refs($data) as &$v) { $v *= 10; }
+unset($v);
+echo implode(",", $data);
+function plain() { yield 1; }
+try { foreach (plain() as &$v) {} } catch (Exception $e) { echo ",", $e->getMessage(); }`,
+			fn: func(d def.TopDefiner) def.Val {
+				d.DeclFunc("refs", true, nil, []def.Param{d.Param(d.SimpleType("array"), "arr", nil, true, false)}, d.Generator(func(d def.Definer) def.Val {
+					for it := d.ForeachIterator(d.Array(d.ArrayItem(nil, d.Int(0), false, false), d.ArrayItem(nil, d.Int(1), false, false))); it.Valid(); it.Next() {
+						d.Assign(d.Var("i"), it.Value())
+						d.Yield(nil, d.Index(d.Var("arr"), d.Var("i")))
+					}
+					return nil
+				}))
+				d.DeclFunc("plain", false, nil, nil, d.Generator(func(d def.Definer) def.Val {
+					d.Yield(nil, d.Int(1))
+					return nil
+				}))
+				d.Assign(d.Var("data"), d.Array(d.ArrayItem(nil, d.Int(1), false, false), d.ArrayItem(nil, d.Int(2), false, false)))
+				for it := d.ForeachIteratorRef(d.FuncCall("refs", d.Arg(d.Var("data"), false))); it.Valid(); it.Next() {
+					d.AssignRef(d.Var("v"), it.ValueRef())
+					d.AssignMul(d.Var("v"), d.Int(10))
+				}
+				d.Unset(d.Var("v"))
+				d.EchoVal(d.FuncCall("implode", d.Arg(d.String(","), false), d.Arg(d.Var("data"), false)))
+				d.Try(func() *def.Flow {
+					for it := d.ForeachIteratorRef(d.FuncCall("plain")); it.Valid(); it.Next() {
+						d.AssignRef(d.Var("v"), it.ValueRef())
+					}
+					return nil
+				}, []*def.Catch{d.Catch([]string{"\\Exception"}, "e", func() *def.Flow {
+					d.Echo(",")
+					d.EchoVal(d.MethodCall(d.Var("e"), "getMessage"))
+					return nil
+				})}, nil)
+				return nil
+			},
+			want: "10,20,You can only iterate a generator by-reference if it declared that it yields by-reference",
+		},
+		{
+			name: "generator destroy",
+			php: `function g() { try { yield 1; } finally { echo "cleanup"; } }
+$g = g(); $g->current(); echo "end,";`,
+			fn: func(d def.TopDefiner) def.Val {
+				d.DeclFunc("g", false, nil, nil, d.Generator(func(d def.Definer) def.Val {
+					d.Try(func() *def.Flow {
+						d.Yield(nil, d.Int(1))
+						return nil
+					}, nil, func() *def.Flow {
+						d.Echo("cleanup")
+						return nil
+					})
+					return nil
+				}))
+				d.Assign(d.Var("g"), d.FuncCall("\\g"))
+				d.MethodCall(d.Var("g"), "current")
+				d.Echo("end,")
+				return nil
+			},
+			want: "end,cleanup",
+		},
+		{
+			name: "try",
+			php: `class MyEx extends Exception {}
+function f($x) {
+	try { if ($x) throw new MyEx("m", 3); return "ok"; }
+	catch (LogicException | MyEx $e) { return $e->getMessage() . $e->getCode(); }
+	finally { if ($x == 2) return "fin"; }
+}
+for ($i = 0; $i < 3; $i++) {
+	try { if ($i == 1) break; } finally { echo "f$i,"; }
+}
+echo f(0), f(1), f(2);`,
+			fn: func(d def.TopDefiner) def.Val {
+				d.DeclClass("MyEx", 0, "\\Exception", nil, func(d def.ClassDefiner) {})
+				d.DeclFunc("f", false, nil, []def.Param{d.Param(nil, "x", nil, false, false)}, func(d def.Definer) def.Val {
+					if flow := d.Try(func() *def.Flow {
+						if d.IsTrue(d.Var("x")) {
+							d.Throw(d.New("\\MyEx", d.Arg(d.String("m"), false), d.Arg(d.Int(3), false)))
+						}
+						return d.Return(d.String("ok"))
+					}, []*def.Catch{d.Catch([]string{"\\LogicException", "\\MyEx"}, "e", func() *def.Flow {
+						return d.Return(d.Concat(d.MethodCall(d.Var("e"), "getMessage"), d.MethodCall(d.Var("e"), "getCode")))
+					})}, func() *def.Flow {
+						if d.IsTrue(d.Equal(d.Var("x"), d.Int(2))) {
+							return d.Return(d.String("fin"))
+						}
+						return nil
+					}); flow != nil {
+						if flow.Jump == 0 {
+							return flow.Ret
+						}
+					}
+					return nil
+				})
+				for d.Assign(d.Var("i"), d.Int(0)); d.IsTrue(d.Smaller(d.Var("i"), d.Int(3))); d.PostInc(d.Var("i")) {
+					if flow := d.Try(func() *def.Flow {
+						if d.IsTrue(d.Equal(d.Var("i"), d.Int(1))) {
+							return d.Jump(1)
+						}
+						return nil
+					}, nil, func() *def.Flow {
+						d.EchoVal(d.Concat(d.Concat(d.String("f"), d.Var("i")), d.String(",")))
+						return nil
+					}); flow != nil {
+						if flow.Jump == 1 {
+							break
+						}
+					}
+				}
+				d.EchoVal(d.FuncCall("f", d.Arg(d.Int(0), false)), d.FuncCall("f", d.Arg(d.Int(1), false)), d.FuncCall("f", d.Arg(d.Int(2), false)))
+				return nil
+			},
+			want: "f0,f1,okm3fin",
+		},
+		{
+			name: "native",
+			php: `function scale(float $x, int $n): float {
+	$y = $x * 2;
+	for ($i = 0; $i < $n; $i++) { $y = $y / 2; }
+	return $y + $i % 3;
+}
+function size(string $s): int { return strlen($s) . ""; }
+echo scale("3", 2), ",", size("abcd"), ",", gettype(size("ab"));`,
+			fn: func(d def.TopDefiner) def.Val {
+				d.DeclFunc("scale", false, d.SimpleType("float"), []def.Param{d.Param(d.SimpleType("float"), "x", nil, false, false), d.Param(d.SimpleType("int"), "n", nil, false, false)}, func(d def.Definer) def.Val {
+					v_x := d.FloatVal(d.Var("x"))
+					v_n := d.IntVal(d.Var("n"))
+					var v_y float64
+					var v_i int
+					v_y = (v_x * 2)
+					for v_i = 0; v_i < v_n; v_i++ {
+						v_y = d.DivFloat(v_y, 2)
+					}
+					return d.Float((v_y + float64(d.ModInt(v_i, 3))))
+				})
+				d.DeclFunc("size", false, d.SimpleType("int"), []def.Param{d.Param(d.SimpleType("string"), "s", nil, false, false)}, func(d def.Definer) def.Val {
+					v_s := d.StrVal(d.Var("s"))
+					return d.Concat(d.FuncCall("strlen", d.Arg(d.String(v_s), false)), d.String(""))
+				})
+				d.EchoVal(d.FuncCall("scale", d.Arg(d.String("3"), false), d.Arg(d.Int(2), false)), d.String(","), d.FuncCall("size", d.Arg(d.String("abcd"), false)), d.String(","), d.FuncCall("gettype", d.Arg(d.FuncCall("size", d.Arg(d.String("ab"), false)), false)))
+				return nil
+			},
+			want: "3.5,4,integer",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := def.NewFile("test.php", false)
+			f.TopFn("", tt.fn)
+			if got := runFile(t, f); got != tt.want {
+				t.Errorf("Execute() output = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFileExecuteNamespace(t *testing.T) {
+	f := def.NewFile("test.php", false)
+	f.TopFn("App", func(d def.TopDefiner) def.Val {
+		d.DeclConst("App\\NAME", d.String("app"))
+		d.DeclFunc("App\\strlen", false, nil, nil, func(d def.Definer) def.Val {
+			return d.Int(-1)
+		})
+		// 非限定名称优先查找命名空间内的定义，不存在时回退到全局
+		d.EchoVal(d.Const("NAME"), d.String(","), d.FuncCall("strlen"), d.String(","), d.FuncCall("\\strlen", d.Arg(d.String("ab"), false)), d.String(","), d.FuncCall("strtoupper", d.Arg(d.String("x"), false)))
+		return nil
+	})
+	if got, want := runFile(t, f), "app,-1,2,X"; got != want {
+		t.Errorf("Execute() output = %q, want %q", got, want)
+	}
+}
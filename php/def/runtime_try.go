@@ -0,0 +1,93 @@
+package def
+
+import (
+	"github.com/heyuuu/gophp/php"
+	"github.com/heyuuu/gophp/php/types"
+)
+
+func (d *definer) Throw(v Val) Val {
+	php.ThrowObject(d.ctx, d.zval(v))
+	return d.Null()
+}
+
+func (d *definer) Catch(types []string, varName string, body func() *Flow) *Catch {
+	return &Catch{Types: types, VarName: varName, Body: body}
+}
+
+func (d *definer) Return(v Val) *Flow { return &Flow{Ret: v} }
+func (d *definer) Jump(n int) *Flow   { return &Flow{Jump: n} }
+
+// Try 执行 try 语句。异常以 panic 传播，catch 按类层次匹配；
+// finally 在语句块正常结束、离开语句块或抛出异常时执行，其中的 return/break/continue 覆盖之前的结果及异常
+func (d *definer) Try(body func() *Flow, catches []*Catch, finally func() *Flow) (flow *Flow) {
+	if finally != nil {
+		defer func() {
+			e := recover()
+			pending := php.CatchException(e)
+			if e != nil && pending == nil {
+				if php.IsDestroySignal(e) {
+					// 销毁生成器或 Fiber 时执行 finally，之后继续结束执行
+					php.RunFinally(d.ctx, nil, func() { finally() })
+				}
+				// exit 等非异常的 panic 不执行 finally
+				panic(e)
+			}
+			var f *Flow
+			php.RunFinally(d.ctx, pending, func() { f = finally() })
+			if f != nil {
+				flow = f
+				return
+			}
+			if e != nil {
+				php.Rethrow(d.ctx, e)
+			}
+		}()
+	}
+	return d.tryCatch(body, catches)
+}
+
+func (d *definer) tryCatch(body func() *Flow, catches []*Catch) *Flow {
+	if len(catches) == 0 {
+		return body()
+	}
+
+	flow, exception, c := d.catchException(body, catches)
+	if c == nil {
+		return flow
+	}
+	if c.VarName != "" {
+		d.Var(c.VarName).Set(value{types.ZvalObject(exception)})
+	}
+	return c.Body()
+}
+
+// catchException 执行语句块，捕获与 catches 匹配的异常。catch 子句在 recover 之外执行，其中抛出的异常继续向外传播
+func (d *definer) catchException(body func() *Flow, catches []*Catch) (flow *Flow, exception *types.Object, caught *Catch) {
+	defer func() {
+		e := recover()
+		if e == nil {
+			return
+		}
+		if exception = php.CatchException(e); exception != nil {
+			if caught = d.matchCatch(exception, catches); caught != nil {
+				d.ctx.EG().ClearException()
+				return
+			}
+		}
+		panic(e)
+	}()
+	return body(), nil, nil
+}
+
+func (d *definer) matchCatch(exception *types.Object, catches []*Catch) *Catch {
+	for _, c := range catches {
+		for _, name := range c.Types {
+			// 类不存在时不匹配，不触发错误
+			ce := php.ZendLookupClassEx(d.ctx, php.CleanNsName(name), "", 0)
+			if ce != nil && php.InstanceofFunction(exception.Class(), ce) {
+				return c
+			}
+		}
+	}
+	return nil
+}
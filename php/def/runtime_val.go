@@ -0,0 +1,360 @@
+package def
+
+import (
+	"fmt"
+	"github.com/heyuuu/gophp/php"
+	"github.com/heyuuu/gophp/php/lang"
+	"github.com/heyuuu/gophp/php/perr"
+	"github.com/heyuuu/gophp/php/types"
+	"strings"
+)
+
+// value 右值
+type value struct {
+	zv types.Zval
+}
+
+func (value) val() {}
+
+// place 左值(变量位置)，读取时才求值
+type place interface {
+	Variable
+	// fetch 读取当前值(已解引用)。quiet 时用于 isset/empty/??，值不存在时返回 Undef 且不报错
+	fetch(quiet bool) types.Zval
+	// ref 获取可写的变量位置，必要时将容器初始化为数组。无法写入时返回 nil
+	ref() php.Variable
+	unset()
+}
+
+// varPlace $name
+type varPlace struct {
+	d    *definer
+	name string
+}
+
+func (p *varPlace) val()      {}
+func (p *varPlace) Set(v Val) { p.d.assign(p, p.d.zval(v)) }
+
+func (p *varPlace) fetch(quiet bool) types.Zval {
+	if p.name == "this" {
+		if this := p.d.ex.ThisObject(); this != nil {
+			return types.ZvalObject(this)
+		}
+		if !quiet {
+			php.ThrowError(p.d.ctx, nil, "Using $this when not in object context")
+		}
+		return types.Undef
+	}
+
+	zv := p.d.symbols().Get(p.name)
+	if zv.IsUndef() {
+		if quiet {
+			return types.Undef
+		}
+		php.Error(p.d.ctx, perr.E_NOTICE, fmt.Sprintf("Undefined variable: %s", p.name))
+		return types.Null
+	}
+	return zv.DeRef()
+}
+
+func (p *varPlace) ref() php.Variable {
+	return php.NewSymbolVariable(p.d.symbols(), p.name)
+}
+
+func (p *varPlace) unset() {
+	p.d.symbols().Unset(p.name)
+}
+
+// indexPlace $arr[$key]，key 为 nil 时即 $arr[]
+type indexPlace struct {
+	d    *definer
+	arr  Val
+	key  Val
+	zkey types.Zval // 已求值的 key，避免多次读写时重复求值
+}
+
+func (p *indexPlace) val()      {}
+func (p *indexPlace) Set(v Val) { p.d.assign(p, p.d.zval(v)) }
+
+func (p *indexPlace) arrayKey() (types.ArrayKey, bool) {
+	if p.zkey.IsUndef() {
+		p.zkey = p.d.zval(p.key)
+	}
+	return php.ZvalToArrayKey(p.d.ctx, p.zkey)
+}
+
+func (p *indexPlace) fetch(quiet bool) types.Zval {
+	if p.key == nil {
+		php.ThrowError(p.d.ctx, nil, "Cannot use [] for reading")
+		return types.Undef
+	}
+
+	var arr types.Zval
+	if quiet {
+		arr = p.d.quietZval(p.arr)
+	} else {
+		arr = p.d.zval(p.arr)
+	}
+	key, ok := p.arrayKey()
+	if !ok {
+		return lang.Cond(quiet, types.Undef, types.Null)
+	}
+
+	if quiet {
+		switch {
+		case arr.IsArray():
+			return arr.Array().Find(key).DeRef()
+		case arr.IsString() && key.IsIdxKey() && key.IdxKey() < len(arr.String()) && key.IdxKey() >= -len(arr.String()):
+			return php.ArrayGet(p.d.ctx, arr, key)
+		default:
+			return types.Undef
+		}
+	}
+
+	zv := php.ArrayGet(p.d.ctx, arr, key)
+	if zv.IsUndef() {
+		if key.IsStrKey() {
+			php.Error(p.d.ctx, perr.E_WARNING, fmt.Sprintf(`Undefined array key "%s"`, key.StrKey()))
+		} else {
+			php.Error(p.d.ctx, perr.E_WARNING, fmt.Sprintf(`Undefined array key %d`, key.IdxKey()))
+		}
+		return types.Null
+	}
+	return zv.DeRef()
+}
+
+func (p *indexPlace) ref() php.Variable {
+	arr := p.d.container(p.arr)
+	if p.key == nil {
+		return php.NewArrayAppendVariable(p.d.ctx, arr)
+	}
+	key, ok := p.arrayKey()
+	if !ok {
+		return nil
+	}
+	return php.NewArrayDimVariable(p.d.ctx, arr, key)
+}
+
+func (p *indexPlace) unset() {
+	arr := p.d.quietZval(p.arr)
+	if !arr.IsArray() || p.key == nil {
+		return
+	}
+	if key, ok := p.arrayKey(); ok {
+		arr.Array().Delete(key)
+	}
+}
+
+// propPlace $obj->name
+type propPlace struct {
+	d    *definer
+	obj  Val
+	name string
+}
+
+func (p *propPlace) val()      {}
+func (p *propPlace) Set(v Val) { p.d.assign(p, p.d.zval(v)) }
+
+func (p *propPlace) fetch(quiet bool) types.Zval {
+	var obj types.Zval
+	if quiet {
+		obj = p.d.quietZval(p.obj)
+	} else {
+		obj = p.d.zval(p.obj)
+	}
+	if !obj.IsObject() {
+		if quiet {
+			return types.Undef
+		}
+		php.Error(p.d.ctx, perr.E_WARNING, fmt.Sprintf(`Attempt to read property "%s" on %s`, p.name, types.ZendZvalTypeName(obj)))
+		return types.Null
+	}
+
+	member := types.ZvalString(p.name)
+	if quiet {
+		if !obj.Object().HasProperty(member, 0) {
+			return types.Undef
+		}
+		return obj.Object().ReadProperty(member, php.BP_VAR_IS).DeRef()
+	}
+	return obj.Object().ReadProperty(member, php.BP_VAR_R).DeRef()
+}
+
+func (p *propPlace) ref() php.Variable {
+	obj := p.d.zval(p.obj)
+	if !obj.IsObject() {
+		php.ThrowError(p.d.ctx, nil, fmt.Sprintf(`Attempt to assign property "%s" on %s`, p.name, types.ZendZvalTypeName(obj)))
+		return nil
+	}
+	return php.NewPropertyVariable(obj.Object(), types.ZvalString(p.name))
+}
+
+func (p *propPlace) unset() {
+	if obj := p.d.quietZval(p.obj); obj.IsObject() {
+		obj.Object().UnsetProperty(types.ZvalString(p.name))
+	}
+}
+
+// listPlace [$a, $b] 或 list($a, $b)，仅可作为赋值目标
+type listPlace struct {
+	d     *definer
+	items []*ArrayItem
+}
+
+func (p *listPlace) val()      {}
+func (p *listPlace) Set(v Val) { p.destructure(p.d.zval(v)) }
+
+func (p *listPlace) fetch(quiet bool) types.Zval {
+	php.ThrowError(p.d.ctx, nil, "Cannot use list() for reading")
+	return types.Undef
+}
+
+func (p *listPlace) ref() php.Variable {
+	php.ThrowError(p.d.ctx, nil, "Cannot assign reference to list()")
+	return nil
+}
+
+func (p *listPlace) unset() {
+	php.ThrowError(p.d.ctx, nil, "Cannot use list() in unset()")
+}
+
+func (p *listPlace) destructure(zv types.Zval) {
+	for i, item := range p.items {
+		if item == nil {
+			continue
+		}
+		if item.ByRef || item.Unpack {
+			panic(perr.Todof("list() with reference or unpack"))
+		}
+
+		elem := types.Null
+		if zv.IsArray() {
+			key := types.IdxKey(i)
+			if item.Key != nil {
+				var ok bool
+				if key, ok = php.ZvalToArrayKey(p.d.ctx, p.d.zval(item.Key)); !ok {
+					continue
+				}
+			}
+			elem = (&indexPlace{d: p.d, arr: value{zv}, key: value{key.ToZval()}}).fetch(false)
+		}
+		p.d.assign(item.Val.(place), elem)
+	}
+}
+
+// staticPropPlace Class::$name
+type staticPropPlace struct {
+	d         *definer
+	className string
+	name      string
+}
+
+func (p *staticPropPlace) val()      {}
+func (p *staticPropPlace) Set(v Val) { p.d.assign(p, p.d.zval(v)) }
+func (p *staticPropPlace) unset()    { php.ThrowError(p.d.ctx, nil, "Attempt to unset static property") }
+
+func (p *staticPropPlace) fetch(quiet bool) types.Zval {
+	var v php.Variable
+	if quiet {
+		p.d.ctx.EG().ErrorSuppressScope(func() { v = p.ref() })
+	} else {
+		v = p.ref()
+	}
+	if v == nil {
+		return lang.Cond(quiet, types.Undef, types.Null)
+	}
+	return v.Get().DeRef()
+}
+
+func (p *staticPropPlace) ref() php.Variable {
+	ce := p.d.fetchClass(p.className)
+	if ce == nil {
+		return nil
+	}
+	return php.StaticPropertyVariable(p.d.ctx, ce, p.name)
+}
+
+// refPlace 已有的引用，如按引用产出的生成器的当前值
+type refPlace struct {
+	d *definer
+	r *types.Reference
+}
+
+func (p *refPlace) val()                        {}
+func (p *refPlace) Set(v Val)                   { p.d.assign(p, p.d.zval(v)) }
+func (p *refPlace) fetch(quiet bool) types.Zval { return p.r.Val() }
+func (p *refPlace) ref() php.Variable           { return referenceVariable{p.r} }
+func (p *refPlace) unset()                      {}
+
+// referenceVariable 以引用作为变量位置，MakeRef 返回引用本身
+type referenceVariable struct {
+	r *types.Reference
+}
+
+func (v referenceVariable) Get() types.Zval           { return types.ZvalRef(v.r) }
+func (v referenceVariable) Set(value types.Zval)      { v.r.SetVal(value) }
+func (v referenceVariable) Unset()                    {}
+func (v referenceVariable) MakeRef() *types.Reference { return v.r }
+
+// arg
+type arg struct {
+	value  Val
+	unpack bool
+}
+
+func (a arg) Value() Val   { return a.value }
+func (a arg) Unpack() bool { return a.unpack }
+
+// param
+type param struct {
+	name       string
+	typ        TypeHint
+	defaultVal Val
+	byRef      bool
+	variadic   bool
+}
+
+func (p *param) Name() string   { return p.name }
+func (p *param) ByRef() bool    { return p.byRef }
+func (p *param) Variadic() bool { return p.variadic }
+func (p *param) Default() Val   { return p.defaultVal }
+
+// type hint，仅校验 int、float、string、bool 标量类型，其它类型暂仅记录
+type (
+	simpleType       struct{ name string }
+	intersectionType struct{ types []TypeHint }
+	unionType        struct{ types []TypeHint }
+	nullableType     struct{ typ TypeHint }
+)
+
+func (simpleType) typeHint()       {}
+func (intersectionType) typeHint() {}
+func (unionType) typeHint()        {}
+func (nullableType) typeHint()     {}
+
+// scalarTypeName 标量类型声明的类型名，其它类型返回空
+func scalarTypeName(t TypeHint) string {
+	if st, ok := t.(simpleType); ok {
+		switch name := strings.ToLower(st.name); name {
+		case "int", "float", "string", "bool":
+			return name
+		}
+	}
+	return ""
+}
+
+// arrayIterator foreach 遍历数组，遍历开始时的数组快照
+type arrayIterator struct {
+	d     *definer
+	arr   types.Zval
+	pairs []types.ArrayPair
+	pos   int
+}
+
+func (it *arrayIterator) Valid() bool { return it.pos < len(it.pairs) }
+func (it *arrayIterator) Next()       { it.pos++ }
+func (it *arrayIterator) Key() Val    { return value{it.pairs[it.pos].Key.ToZval()} }
+func (it *arrayIterator) Value() Val  { return value{it.pairs[it.pos].Val.DeRef()} }
+func (it *arrayIterator) ValueRef() Variable {
+	return &indexPlace{d: it.d, arr: value{it.arr}, key: value{it.pairs[it.pos].Key.ToZval()}}
+}
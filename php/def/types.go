@@ -1,9 +1,12 @@
 package def
 
+import "github.com/heyuuu/gophp/compile/ast"
+
 type Val interface {
 	val()
 }
 
+// Variable 左值，作为 Val 使用时在读取时才求值
 type Variable interface {
 	Val
 	Set(v Val)
@@ -15,6 +18,7 @@ type Arg interface {
 }
 
 type Param interface {
+	Name() string
 	ByRef() bool
 	Variadic() bool
 	Default() Val
@@ -25,8 +29,25 @@ type TypeHint interface {
 }
 
 type ArrayItem struct {
-	Key Val
-	Val Val
+	Key    Val
+	Val    Val
+	ByRef  bool
+	Unpack bool
+}
+
+// ClosureUse 闭包 use 的外部变量
+type ClosureUse struct {
+	Name  string
+	ByRef bool
+}
+
+// TraitAdaptation trait 方法的别名(as)或冲突解决(insteadof)
+type TraitAdaptation struct {
+	Trait     string
+	Method    string
+	Insteadof []string  // insteadof 排除的 trait
+	Flags     ast.Flags // as 修改的访问级别
+	NewName   string    // as 的别名
 }
 
 type ForeachIterator interface {
@@ -34,4 +55,19 @@ type ForeachIterator interface {
 	Next()
 	Key() Val
 	Value() Val
+	ValueRef() Variable // foreach 按引用遍历时的当前元素
+}
+
+// Catch try 语句的 catch 子句，Types 为完全限定的类名，VarName 为空时不保存异常
+type Catch struct {
+	Types   []string
+	VarName string
+	Body    func() *Flow
+}
+
+// Flow try/catch/finally 语句块内离开语句块的 return 或 break/continue。
+// Jump 为转译时分配的跳转编号，return 时为 0 且 Ret 为返回值
+type Flow struct {
+	Jump int
+	Ret  Val
 }
@@ -14,6 +14,8 @@ type Engine struct {
 	host    string   `get:""`
 	port    int      `get:""`
 	baseCtx *Context `get:""`
+
+	scriptCache *ScriptCache `get:""` // 已编译脚本缓存，跨请求共享
 }
 
 func NewEngine() *Engine {
@@ -26,6 +28,7 @@ func NewEngine() *Engine {
 func (engine *Engine) init() {
 	engine.modules = types.NewTable[*Module]()
 	engine.baseCtx = initBaseContext(engine)
+	engine.scriptCache = NewScriptCache()
 
 	moduleStartupRegisterConstants(engine.baseCtx)
 	for _, entry := range builtinModuleEntries {
@@ -99,6 +102,11 @@ func (engine *Engine) RegisterModule(entry ModuleEntry) *Module {
 	return module
 }
 
+// Modules 已注册的模块，按注册顺序
+func (engine *Engine) Modules() []*Module {
+	return engine.modules.Values()
+}
+
 func (engine *Engine) RegisterResourceType(stream types.ResourceType) {
 	// todo
 }
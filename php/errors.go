@@ -19,10 +19,11 @@ func Error(ctx *Context, typ perr.ErrorType, message string) {
 	filename, lineno := errorGetFilenameLineno(ctx, typ)
 	ErrorTrigger(ctx, perr.NewAt(typ, message, filename, lineno))
 }
+
+// ErrorNoreturn 报告致命错误并结束执行，对应 zend_error_noreturn
 func ErrorNoreturn(ctx *Context, typ perr.ErrorType, message string) {
 	Error(ctx, typ, message)
-	/* Should never reach this. */
-	panic(perr.Unreachable())
+	bailout(ctx)
 }
 func ErrorAt(ctx *Context, typ perr.ErrorType, lineno uint32, message string) {
 	filename, _ := errorGetFilenameLineno(ctx, typ)
@@ -30,8 +31,16 @@ func ErrorAt(ctx *Context, typ perr.ErrorType, lineno uint32, message string) {
 }
 func ErrorAtNoreturn(ctx *Context, typ perr.ErrorType, lineno uint32, message string) {
 	ErrorAt(ctx, typ, lineno, message)
-	/* Should never reach this. */
-	panic(perr.Unreachable())
+	bailout(ctx)
+}
+
+// fatalError 致命错误结束执行时的 panic。不是异常，不能被 catch 捕获，也不执行 finally，由 Executor.Execute 停止传播
+type fatalError struct{}
+
+// bailout 致命错误后结束执行，对应 zend_bailout
+func bailout(ctx *Context) {
+	ctx.EG().SetExitStatus(255)
+	panic(fatalError{})
 }
 
 func ErrorDocRef(ctx *Context, docRef string, typ perr.ErrorType, buffer string, params ...string) {
@@ -49,14 +58,26 @@ func ErrorDocRef(ctx *Context, docRef string, typ perr.ErrorType, buffer string,
 	Error(ctx, typ, message)
 }
 
+// ThrowError 抛出 exceptionCe 类的异常，exceptionCe 为 nil 时为 Error，对应 zend_throw_error
 func ThrowError(ctx *Context, exceptionCe *types.Class, message string) {
-	// todo
-	Error(ctx, perr.E_ERROR, message)
+	if ctx.eh != nil {
+		Error(ctx, perr.E_ERROR, message)
+		return
+	}
+	if exceptionCe == nil {
+		exceptionCe = ThrowableClass(ctx, ErrorClassName)
+	}
+	throwNew(ctx, exceptionCe, message, 0)
 }
 
 func errorGetFilenameLineno(ctx *Context, typ perr.ErrorType) (string, uint32) {
-	// todo
-	return GetExecutedFilenameVal(ctx), 0
+	if typ&perr.E_CORE != 0 {
+		return "Unknown", 0
+	}
+	if !IsExecuting(ctx) {
+		return "", 0
+	}
+	return GetExecutedFilenameVal(ctx), uint32(GetExecutedLineno(ctx))
 }
 
 func ErrorTrigger(ctx *Context, err perr.Error) {
@@ -126,14 +147,14 @@ func PhpLogErrWithSeverity(ctx *Context, logMessage string, syslogTypeInt plog.L
 
 func InternalTypeError(ctx *Context, throwException bool, message string) {
 	if throwException {
-		ThrowException(ctx, nil, message, 0)
+		ThrowException(ctx, ThrowableClass(ctx, TypeErrorClassName), message, 0)
 	} else {
 		Error(ctx, perr.E_WARNING, message)
 	}
 }
 func InternalArgumentCountError(ctx *Context, throwException bool, message string) {
 	if throwException {
-		ThrowException(ctx, nil, message, 0)
+		ThrowException(ctx, ThrowableClass(ctx, ArgumentCountErrorClassName), message, 0)
 	} else {
 		Error(ctx, perr.E_WARNING, message)
 	}
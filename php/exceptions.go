@@ -1 +1,345 @@
 package php
+
+import (
+	"fmt"
+	"github.com/heyuuu/gophp/kits/ascii"
+	"github.com/heyuuu/gophp/php/perr"
+	"github.com/heyuuu/gophp/php/types"
+	"strings"
+)
+
+// 引擎内部抛出的异常类，由 builtin 模块注册
+const (
+	ThrowableClassName           = "Throwable"
+	ExceptionClassName           = "Exception"
+	ErrorExceptionClassName      = "ErrorException"
+	ErrorClassName               = "Error"
+	CompileErrorClassName        = "CompileError"
+	ParseErrorClassName          = "ParseError"
+	TypeErrorClassName           = "TypeError"
+	ArgumentCountErrorClassName  = "ArgumentCountError"
+	ValueErrorClassName          = "ValueError"
+	ArithmeticErrorClassName     = "ArithmeticError"
+	DivisionByZeroErrorClassName = "DivisionByZeroError"
+	UnhandledMatchErrorClassName = "UnhandledMatchError"
+)
+
+// throwablePropSlot 异常对象的内置属性，不经过可访问性检查(private 属性由内部方法读写)
+func throwablePropSlot(obj *types.Object, name string) *types.Zval {
+	std, ok := obj.Intern().(interface{ StdInternObject() *StdInternObject })
+	info := obj.Class().PropertyTable().Get(name)
+	if !ok || info == nil || info.IsStatic() {
+		return nil
+	}
+	return &std.StdInternObject().PropertiesTable()[info.Offset()]
+}
+
+// ThrowableProp 读取异常对象的 message、code、file、line、trace 或 previous 属性
+func ThrowableProp(obj *types.Object, name string) types.Zval {
+	if slot := throwablePropSlot(obj, name); slot != nil {
+		return slot.DeRef()
+	}
+	return types.Null
+}
+
+func SetThrowableProp(obj *types.Object, name string, value types.Zval) {
+	if slot := throwablePropSlot(obj, name); slot != nil {
+		*slot = value
+	}
+}
+
+// IsThrowable obj 是否实现了 Throwable 接口
+func IsThrowable(ctx *Context, obj *types.Object) bool {
+	ce := ctx.EG().ClassTable().Get(ascii.StrToLower(ThrowableClassName))
+	return ce != nil && InstanceofFunction(obj.Class(), ce)
+}
+
+// initThrowableObject 记录异常对象创建时的文件、行号及调用栈，对应 zend_default_exception_new
+func initThrowableObject(ctx *Context, obj *types.Object) {
+	SetThrowableProp(obj, "file", types.ZvalString(GetExecutedFilenameVal(ctx)))
+	SetThrowableProp(obj, "line", types.ZvalLong(GetExecutedLineno(ctx)))
+	SetThrowableProp(obj, "trace", types.ZvalArray(backtrace(ctx)))
+}
+
+// throwNew 创建 ce 类的异常对象并抛出。ce 为 nil 时(异常类尚未注册)退化为 E_ERROR 错误
+func throwNew(ctx *Context, ce *types.Class, message string, code int) {
+	if ce == nil {
+		Error(ctx, perr.E_ERROR, message)
+		return
+	}
+	obj := ObjectInit(ctx, ce)
+	if obj == nil {
+		return
+	}
+	SetThrowableProp(obj, "message", types.ZvalString(message))
+	SetThrowableProp(obj, "code", types.ZvalLong(code))
+	ThrowObject(ctx, types.ZvalObject(obj))
+}
+
+// ThrowableClass 查找内置的异常类，未注册时返回 nil
+func ThrowableClass(ctx *Context, name string) *types.Class {
+	classTable := ctx.EG().ClassTable()
+	if classTable == nil {
+		return nil
+	}
+	return classTable.Get(ascii.StrToLower(name))
+}
+
+// ThrownException 抛出中的异常。以 panic 的方式沿 Go 调用栈传播，直到被 catch 捕获或到达顶层
+type ThrownException struct {
+	obj *types.Object
+}
+
+func (e *ThrownException) Object() *types.Object { return e.obj }
+func (e *ThrownException) Error() string {
+	return "Uncaught " + e.obj.ClassName() + ": " + ThrowableProp(e.obj, "message").String()
+}
+
+// ThrowObject 抛出异常对象。只能抛出实现了 Throwable 的对象
+func ThrowObject(ctx *Context, v types.Zval) {
+	v = v.DeRef()
+	if !v.IsObject() {
+		ThrowError(ctx, nil, "Can only throw objects")
+		return
+	}
+	if !IsThrowable(ctx, v.Object()) {
+		ThrowError(ctx, nil, "Cannot throw objects that do not implement Throwable")
+		return
+	}
+	ctx.EG().exception = v.Object()
+	panic(&ThrownException{obj: v.Object()})
+}
+
+// CatchException 从 recover() 的结果中取出抛出中的异常，不是异常时返回 nil。
+// 异常被 catch 捕获后需调用 ExecutorGlobals.ClearException
+func CatchException(e any) *types.Object {
+	if thrown, ok := e.(*ThrownException); ok {
+		return thrown.obj
+	}
+	return nil
+}
+
+// IsDestroySignal e 是否为销毁生成器或 Fiber 时用于结束其执行的 panic。
+// 与 exit 不同，此时 finally 语句块在没有异常的情况下执行，之后继续结束执行
+func IsDestroySignal(e any) bool {
+	switch e.(type) {
+	case generatorDestroyed, fiberDestroyed:
+		return true
+	}
+	return false
+}
+
+// Rethrow 继续传播 recover() 得到的 panic，为异常时恢复当前异常
+func Rethrow(ctx *Context, e any) {
+	if obj := CatchException(e); obj != nil {
+		ctx.EG().exception = obj
+	}
+	panic(e)
+}
+
+// RunFinally 执行 finally 语句块。pending 为抛出中的异常(没有时为 nil)，执行期间暂时清除；
+// 语句块中抛出的新异常以 pending 作为 previous 链的末端，对应 zend_exception_set_previous
+func RunFinally(ctx *Context, pending *types.Object, block func()) {
+	ctx.EG().exception = nil
+	if pending != nil {
+		defer func() {
+			if e := recover(); e != nil {
+				if obj := CatchException(e); obj != nil {
+					chainPrevious(obj, pending)
+				}
+				panic(e)
+			}
+		}()
+	}
+	block()
+}
+
+// chainPrevious 将 previous 添加到 obj 的 previous 链末端，已在链中时不重复添加
+func chainPrevious(obj *types.Object, previous *types.Object) {
+	for cur := obj; cur != previous; {
+		next := ThrowableProp(cur, "previous")
+		if !next.IsObject() {
+			SetThrowableProp(cur, "previous", types.ZvalObject(previous))
+			return
+		}
+		cur = next.Object()
+		if cur == obj {
+			return
+		}
+	}
+}
+
+// backtrace 当前调用栈，对应 zend_fetch_debug_backtrace。每层记录调用位置的文件、行号及被调用的函数、类和参数
+func backtrace(ctx *Context) *types.Array {
+	trace := types.NewArray()
+	// 转译函数不记录行号，由 go 调用栈得到各帧执行到的行号
+	compiledLines := compiledFrameLines(ctx)
+	for ex := ctx.CurrEX(); ex != nil && ex.Prev() != nil; ex = ex.Prev() {
+		fn, caller := ex.Fn(), ex.Prev()
+		frame := types.NewArray()
+		if caller.Fn() != nil && caller.Fn().IsUserFunction() {
+			lineno := caller.Lineno()
+			if line, ok := compiledLines[caller]; ok {
+				lineno = line
+			}
+			frame.KeyUpdate("file", types.ZvalString(caller.Fn().Filename()))
+			frame.KeyUpdate("line", types.ZvalLong(lineno))
+		}
+		args := make([]types.Zval, len(ex.Args()))
+		for i, arg := range ex.Args() {
+			args[i] = arg.DeRef()
+		}
+		if fn.Name() == "" {
+			// 被包含文件的顶层代码
+			frame.KeyUpdate("function", types.ZvalString("include"))
+			args = []types.Zval{types.ZvalString(fn.Filename())}
+		} else {
+			frame.KeyUpdate("function", types.ZvalString(fn.Name()))
+		}
+		if scope := fn.Scope(); scope != nil {
+			frame.KeyUpdate("class", types.ZvalString(scope.Name()))
+			if ex.ThisObject() != nil {
+				frame.KeyUpdate("type", types.ZvalString("->"))
+			} else {
+				frame.KeyUpdate("type", types.ZvalString("::"))
+			}
+		}
+		frame.KeyUpdate("args", types.ZvalArray(types.NewArrayOfZval(args)))
+		trace.Append(types.ZvalArray(frame))
+	}
+	return trace
+}
+
+// TraceAsString 调用栈的字符串形式，对应 Exception::getTraceAsString
+func TraceAsString(ctx *Context, trace types.Zval) string {
+	var buf strings.Builder
+	num := 0
+	if trace.IsArray() {
+		trace.Array().Each(func(_ types.ArrayKey, frame types.Zval) {
+			if frame = frame.DeRef(); !frame.IsArray() {
+				return
+			}
+			buf.WriteString(traceFrameString(ctx, num, frame.Array()))
+			num++
+		})
+	}
+	fmt.Fprintf(&buf, "#%d {main}", num)
+	return buf.String()
+}
+
+func traceFrameString(ctx *Context, num int, frame *types.Array) string {
+	var buf strings.Builder
+	fmt.Fprintf(&buf, "#%d ", num)
+	if file := frame.KeyFind("file"); file.IsString() {
+		fmt.Fprintf(&buf, "%s(%d): ", file.String(), ZvalGetLong(ctx, frame.KeyFind("line")))
+	} else {
+		buf.WriteString("[internal function]: ")
+	}
+	if class := frame.KeyFind("class"); class.IsString() {
+		buf.WriteString(class.String())
+		buf.WriteString(frame.KeyFind("type").String())
+	}
+	buf.WriteString(frame.KeyFind("function").String())
+	buf.WriteByte('(')
+	if args := frame.KeyFind("args"); args.IsArray() {
+		i := 0
+		args.Array().Each(func(_ types.ArrayKey, arg types.Zval) {
+			if i > 0 {
+				buf.WriteString(", ")
+			}
+			buf.WriteString(traceArgString(ctx, arg.DeRef()))
+			i++
+		})
+	}
+	buf.WriteString(")\n")
+	return buf.String()
+}
+
+// traceArgString 调用栈中的参数，字符串超过 15 个字节时截断
+func traceArgString(ctx *Context, arg types.Zval) string {
+	switch arg.Type() {
+	case types.IsNull, types.IsUndef:
+		return "NULL"
+	case types.IsFalse:
+		return "false"
+	case types.IsTrue:
+		return "true"
+	case types.IsLong, types.IsDouble:
+		return ZvalGetStrVal(ctx, arg)
+	case types.IsString:
+		if s := arg.String(); len(s) > 15 {
+			return "'" + s[:15] + "...'"
+		} else {
+			return "'" + s + "'"
+		}
+	case types.IsArray:
+		return "Array"
+	case types.IsObject:
+		return "Object(" + arg.Object().ClassName() + ")"
+	default:
+		return ZvalGetStrVal(ctx, arg)
+	}
+}
+
+// ThrowableString 异常的字符串形式，previous 链中的异常在前，对应 Exception::__toString
+func ThrowableString(ctx *Context, obj *types.Object) string {
+	var str string
+	for seen := map[*types.Object]bool{}; obj != nil && !seen[obj]; {
+		seen[obj] = true
+		var cur string
+		message := ThrowableProp(obj, "message").String()
+		file, line := ThrowableProp(obj, "file").String(), ThrowableProp(obj, "line").Long()
+		trace := TraceAsString(ctx, ThrowableProp(obj, "trace"))
+		if message != "" {
+			cur = fmt.Sprintf("%s: %s in %s:%d\nStack trace:\n%s", obj.ClassName(), message, file, line, trace)
+		} else {
+			cur = fmt.Sprintf("%s in %s:%d\nStack trace:\n%s", obj.ClassName(), file, line, trace)
+		}
+		if str != "" {
+			str = cur + "\n\nNext " + str
+		} else {
+			str = cur
+		}
+
+		if previous := ThrowableProp(obj, "previous"); previous.IsObject() {
+			obj = previous.Object()
+		} else {
+			obj = nil
+		}
+	}
+	return str
+}
+
+// throwableToString 调用异常对象的 __toString 方法，子类可以覆盖
+func throwableToString(ctx *Context, obj *types.Object) string {
+	if method := obj.Class().FunctionTable().Get("__tostring"); method != nil {
+		if str := CallFunction(ctx, method, nil, obj).DeRef(); str.IsString() {
+			return str.String()
+		}
+	}
+	return ThrowableString(ctx, obj)
+}
+
+// callReportingUncaught 执行 fn，其中未捕获的异常直接报告，用于请求结束时的清理
+func callReportingUncaught(ctx *Context, fn func()) {
+	defer func() {
+		if r := recover(); r != nil {
+			obj := CatchException(r)
+			if obj == nil {
+				panic(r)
+			}
+			reportUncaughtException(ctx, obj)
+		}
+	}()
+	fn()
+}
+
+// reportUncaughtException 报告未捕获的异常，对应 zend_exception_error
+func reportUncaughtException(ctx *Context, obj *types.Object) {
+	ctx.EG().ClearException()
+	file := ThrowableProp(obj, "file").String()
+	line := ThrowableProp(obj, "line").Long()
+	message := "Uncaught " + throwableToString(ctx, obj) + "\n  thrown"
+	ErrorTrigger(ctx, perr.NewAt(perr.E_ERROR, message, file, uint32(line)))
+	ctx.EG().SetExitStatus(255)
+}
@@ -18,6 +18,7 @@ func ExecuteScript(ctx *Context, fileHandle *FileHandle, skipShebang bool) (retv
 	if topFunc == nil {
 		return types.Undef, errors.New("compile code failed")
 	}
+	addIncludedFile(ctx, fileHandle.OpenedPath())
 
 	executor := ctx.executor
 	return executor.Execute(topFunc)
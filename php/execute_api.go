@@ -37,7 +37,20 @@ func GetExecutedFilenameVal(ctx *Context) string {
 	return name
 }
 func GetExecutedLineno(ctx *Context) int {
-	return 0
+	ex := ctx.CurrEX()
+	for ex != nil && (ex.Fn() == nil || !ex.Fn().IsUserFunction()) {
+		ex = ex.Prev()
+	}
+	if ex == nil {
+		return 0
+	}
+	// 转译的函数不记录行号，由 go 调用栈中的位置得到
+	if ex.Fn().Handler() != nil {
+		if lineno := compiledLineno(ex.Fn().Filename()); lineno > 0 {
+			return lineno
+		}
+	}
+	return ex.Lineno()
 }
 func GetExecutedScope(ctx *Context) *types.Class {
 	var ex = ctx.CurrEX()
@@ -10,9 +10,12 @@ type ExecuteData struct {
 	args    []types.Zval
 	symbols ISymtable
 	prev    *ExecuteData
+	lineno  int // 当前执行到的行号
 
 	thisClass *types.Class
 	thisObj   *types.Object
+
+	generator *Generator // 生成器函数的执行帧所属的生成器
 }
 
 func NewExecuteData(ctx *Context, fn *types.Function, args []types.Zval) *ExecuteData {
@@ -44,6 +47,8 @@ func (ex *ExecuteData) Prev() *ExecuteData           { return ex.prev }
 func (ex *ExecuteData) SetPrev(prev *ExecuteData)    { ex.prev = prev }
 func (ex *ExecuteData) Fn() *types.Function          { return ex.fn }
 func (ex *ExecuteData) SetFn(fn *types.Function)     { ex.fn = fn }
+func (ex *ExecuteData) Lineno() int                  { return ex.lineno }
+func (ex *ExecuteData) SetLineno(lineno int)         { ex.lineno = lineno }
 
 func (ex *ExecuteData) CalleeName() string {
 	//TODO implement me
@@ -9,6 +9,7 @@ import (
 	"github.com/heyuuu/gophp/php/perr"
 	"github.com/heyuuu/gophp/php/types"
 	"path/filepath"
+	"strings"
 )
 
 type (
@@ -31,6 +32,8 @@ func (r gotoResult) execResult()     {}
 type Executor struct {
 	ctx         *Context
 	executeData *ExecuteData
+
+	callGenerator *Generator // 最近一次调用直接返回的、由所调用的生成器函数创建的生成器
 }
 
 func NewExecutor(ctx *Context) *Executor {
@@ -38,6 +41,20 @@ func NewExecutor(ctx *Context) *Executor {
 }
 
 func (e *Executor) Execute(fn *types.Function) (retVal types.Zval, ret error) {
+	defer func() {
+		if r := recover(); r != nil {
+			if _, ok := r.(fatalError); ok {
+				retVal = types.Null
+				return
+			}
+			obj := CatchException(r)
+			if obj == nil {
+				panic(r)
+			}
+			reportUncaughtException(e.ctx, obj)
+			retVal = types.Null
+		}
+	}()
 	return e.doCall(fn, nil, nil), nil
 }
 
@@ -52,13 +69,31 @@ func (e *Executor) initStringCall(name string) *types.Function {
 }
 
 func (e *Executor) userFunction(fn *types.Function, args []types.Zval) types.Zval {
+	e.bindArgs(fn, args)
+	return e.runFunction(fn)
+}
+
+// bindArgs 将参数及闭包绑定的外部变量写入当前的符号表
+func (e *Executor) bindArgs(fn *types.Function, args []types.Zval) {
 	// todo 初始化 args
 	for i, argInfo := range fn.ArgInfos() {
 		if i < len(args) {
 			e.currSymbols().Set(argInfo.Name(), args[i])
 		}
 	}
+	if vars := fn.StaticVariables(); vars != nil {
+		// 闭包绑定的外部变量，按值绑定的变量每次调用时重新复制
+		vars.Each(func(key types.ArrayKey, value types.Zval) {
+			if !value.IsRef() {
+				types.SeparateZval(&value)
+			}
+			e.currSymbols().Set(key.StrKey(), value)
+		})
+	}
+}
 
+// runFunction 执行函数体，没有 return 时返回 Undef
+func (e *Executor) runFunction(fn *types.Function) types.Zval {
 	res := e.stmtList(fn.Stmts())
 	switch r := res.(type) {
 	case returnResult:
@@ -66,7 +101,7 @@ func (e *Executor) userFunction(fn *types.Function, args []types.Zval) types.Zva
 	case continueResult, breakResult, gotoResult:
 		panic(perr.Unreachable())
 	}
-	return types.Null
+	return types.Undef
 }
 
 func (e *Executor) globalSymbols() ISymtable {
@@ -108,6 +143,12 @@ func (e *Executor) stmtList(stmts []ast.Stmt) execResult {
 }
 
 func (e *Executor) stmt(stmt ast.Stmt) execResult {
+	if pos := ast.MetaStart(stmt); pos.IsValid() {
+		if ex := e.ctx.CurrEX(); ex != nil {
+			ex.SetLineno(pos.Line)
+		}
+	}
+
 	switch x := stmt.(type) {
 	case *ast.EmptyStmt:
 		// pass
@@ -116,6 +157,9 @@ func (e *Executor) stmt(stmt ast.Stmt) execResult {
 	case *ast.ExprStmt:
 		_ = e.expr(x.Expr)
 	case *ast.ReturnStmt:
+		if x.Expr == nil {
+			return returnResult{retVal: types.Null}
+		}
 		retVal := e.expr(x.Expr)
 		return returnResult{retVal: retVal}
 	case *ast.LabelStmt:
@@ -302,11 +346,64 @@ func (e *Executor) foreachStmt(x *ast.ForeachStmt) execResult {
 			return nil
 		})
 		return result
+	} else if g, ok := e.foreachGenerator(variable); ok {
+		if isCallExpr(x.Expr) && g == e.callGenerator {
+			// 遍历生成器函数调用返回的临时生成器时，循环结束后生成器不再被引用，立即销毁以执行其中的 finally
+			defer g.destroy()
+		}
+		return e.foreachGeneratorStmt(x, g)
 	} else {
 		panic(perr.Todof("暂未支持非数组的 foreach 操作"))
 	}
 }
 
+func isCallExpr(expr ast.Expr) bool {
+	switch expr.(type) {
+	case *ast.FuncCallExpr, *ast.MethodCallExpr, *ast.StaticCallExpr:
+		return true
+	}
+	return false
+}
+
+func (e *Executor) foreachGenerator(v types.Zval) (*Generator, bool) {
+	if !v.IsObject() {
+		return nil, false
+	}
+	g, ok := v.Object().Intern().(*Generator)
+	return g, ok
+}
+
+// foreachGeneratorStmt 遍历生成器，遍历前先 rewind。按引用遍历要求生成器按引用产出
+func (e *Executor) foreachGeneratorStmt(x *ast.ForeachStmt, g *Generator) execResult {
+	if g.Finished() {
+		ThrowException(e.ctx, nil, "Cannot traverse an already closed generator", 0)
+		return nil
+	}
+	if x.ByRef && !g.ByRef() {
+		ThrowException(e.ctx, nil, "You can only iterate a generator by-reference if it declared that it yields by-reference", 0)
+		return nil
+	}
+	for g.Rewind(); g.Valid(); g.Next() {
+		if x.KeyVar != nil {
+			e.variableSet(x.KeyVar, g.Key())
+		}
+		if value := g.CurrentRef(); x.ByRef {
+			if !value.IsRef() {
+				value = types.ZvalRef(types.NewReference(value))
+			}
+			e.variableRef(x.ValueVar).Set(value)
+		} else {
+			e.variableSet(x.ValueVar, value.DeRef())
+		}
+
+		result, stop := e.handleLoopStmts(x.Stmts)
+		if stop {
+			return result
+		}
+	}
+	return nil
+}
+
 func (e *Executor) whileStmt(x *ast.WhileStmt) execResult {
 	for {
 		if !ZvalIsTrue(e.ctx, e.expr(x.Cond)) {
@@ -356,8 +453,77 @@ func (e *Executor) handleLoopStmts(stmts []ast.Stmt) (result execResult, stop bo
 	}
 }
 
-func (e *Executor) tryCatchStmt(x *ast.TryCatchStmt) execResult {
-	panic(perr.Todof("e.tryCatchStmt"))
+// tryCatchStmt 执行 try 语句。异常以 panic 传播(见 ThrowObject)，catch 按类层次匹配；
+// finally 在语句块正常结束、离开语句块或抛出异常时执行，其中的 return/break/continue 覆盖之前的结果及异常
+func (e *Executor) tryCatchStmt(x *ast.TryCatchStmt) (result execResult) {
+	if x.Finally != nil {
+		defer func() {
+			r := recover()
+			pending := CatchException(r)
+			if r != nil && pending == nil {
+				if IsDestroySignal(r) {
+					// 销毁生成器或 Fiber 时执行 finally，之后继续结束执行
+					RunFinally(e.ctx, nil, func() { e.stmtList(x.Finally.Stmts) })
+				}
+				// exit 等非异常的 panic 不执行 finally
+				panic(r)
+			}
+			var finallyResult execResult
+			RunFinally(e.ctx, pending, func() {
+				finallyResult = e.stmtList(x.Finally.Stmts)
+			})
+			if finallyResult != nil {
+				result = finallyResult
+				return
+			}
+			if r != nil {
+				Rethrow(e.ctx, r)
+			}
+		}()
+	}
+
+	if len(x.Catches) == 0 {
+		return e.stmtList(x.Stmts)
+	}
+	result, exception, c := e.catchException(x)
+	if c == nil {
+		return result
+	}
+	if c.Var != nil {
+		e.variableSet(c.Var, types.ZvalObject(exception))
+	}
+	return e.stmtList(c.Stmts)
+}
+
+// catchException 执行 try 语句块，捕获与 catch 子句匹配的异常。catch 子句在 recover 之外执行，其中抛出的异常继续向外传播
+func (e *Executor) catchException(x *ast.TryCatchStmt) (result execResult, exception *types.Object, caught *ast.CatchStmt) {
+	defer func() {
+		r := recover()
+		if r == nil {
+			return
+		}
+		if exception = CatchException(r); exception != nil {
+			if caught = e.matchCatch(exception, x.Catches); caught != nil {
+				e.ctx.EG().ClearException()
+				return
+			}
+		}
+		panic(r)
+	}()
+	return e.stmtList(x.Stmts), nil, nil
+}
+
+func (e *Executor) matchCatch(exception *types.Object, catches []*ast.CatchStmt) *ast.CatchStmt {
+	for _, c := range catches {
+		for _, name := range c.Types {
+			// 类不存在时不匹配，不触发错误
+			ce := ZendLookupClassEx(e.ctx, name.ToCodeString(), "", 0)
+			if ce != nil && InstanceofFunction(exception.Class(), ce) {
+				return c
+			}
+		}
+	}
+	return nil
 }
 
 func (e *Executor) constStmt(x *ast.ConstStmt) execResult {
@@ -415,53 +581,59 @@ func (e *Executor) functionStmt(x *ast.FunctionStmt) execResult {
 		name = x.Name.Name
 	}
 
-	var argInfos []types.ArgInfo
-	if len(x.Params) > 0 {
-		argInfos = make([]types.ArgInfo, len(x.Params))
-		for i, param := range x.Params {
-			argInfos[i] = types.MakeArgInfo(
-				param.Var.Name.(*ast.Ident).Name,
-				param.ByRef,
-				param.Variadic,
-			)
-		}
-	}
-
-	fn := types.NewAstFunction(name, argInfos, x.Stmts)
-	fn.SetFilename(GetExecutedFilenameVal(e.ctx))
+	fn := e.newUserFunction(name, x.Params, x.ByRef, x.Stmts)
 	RegisterFunction(e.ctx, name, fn)
 
 	return nil
 }
 
-func (e *Executor) interfaceStmt(x *ast.InterfaceStmt) execResult {
-	panic(perr.Todof("e.interfaceStmt"))
+// newUserFunction 创建用户函数，函数体内含有 yield 时为生成器函数
+func (e *Executor) newUserFunction(name string, params []*ast.Param, byRef bool, stmts []ast.Stmt) *types.Function {
+	fn := types.NewAstFunction(name, paramArgInfos(params), stmts)
+	fn.SetFilename(GetExecutedFilenameVal(e.ctx))
+	fn.SetIsReturnReference(byRef)
+	fn.SetIsGenerator(containsYield(stmts))
+	return fn
 }
 
-func (e *Executor) parseFlags(astFlags ast.Flags) uint32 {
-	var flags uint32
-	if astFlags&ast.FlagProtected != 0 {
-		flags |= types.AccProtected
-	} else if astFlags&ast.FlagPrivate != 0 {
-		flags |= types.AccPrivate
-	} else {
-		flags |= types.AccPublic
-	}
-	if astFlags&ast.FlagStatic != 0 {
-		flags |= types.AccStatic
+// containsYield 函数体内是否含有 yield，不包括嵌套声明的函数、类及闭包
+func containsYield(stmts []ast.Stmt) (found bool) {
+	for _, stmt := range stmts {
+		ast.Inspect(stmt, func(n ast.Node) bool {
+			switch n.(type) {
+			case *ast.YieldExpr, *ast.YieldFromExpr:
+				found = true
+			case *ast.FunctionStmt, *ast.ClassStmt, *ast.ClosureExpr, *ast.ArrowFunctionExpr:
+				return false
+			}
+			return !found
+		})
 	}
-	if astFlags&ast.FlagAbstract != 0 {
-		flags |= types.AccAbstract
+	return found
+}
+
+func paramArgInfos(params []*ast.Param) []types.ArgInfo {
+	if len(params) == 0 {
+		return nil
 	}
-	if astFlags&ast.FlagFinal != 0 {
-		flags |= types.AccFinal
+	argInfos := make([]types.ArgInfo, len(params))
+	for i, param := range params {
+		argInfos[i] = types.MakeArgInfo(
+			param.Var.Name.(*ast.Ident).Name,
+			param.ByRef,
+			param.Variadic,
+		)
 	}
-	return flags
+	return argInfos
+}
+
+func (e *Executor) interfaceStmt(x *ast.InterfaceStmt) execResult {
+	panic(perr.Todof("e.interfaceStmt"))
 }
 
 func (e *Executor) classStmt(x *ast.ClassStmt) execResult {
 	var decl = types.UserClassDecl{
-		Flags: e.parseFlags(x.Flags),
+		Flags: ClassFlags(x.Flags),
 		Name:  x.NamespacedName.ToString(),
 	}
 
@@ -477,7 +649,7 @@ func (e *Executor) classStmt(x *ast.ClassStmt) execResult {
 	for _, stmt := range x.Stmts {
 		switch s := stmt.(type) {
 		case *ast.ClassConstStmt:
-			flags := e.parseFlags(s.Flags)
+			flags := MemberFlags(s.Flags)
 			DeclClassConst(ce, flags, s.Name.Name, e.expr(s.Value))
 		case *ast.PropertyStmt:
 			var defaultValue types.Zval
@@ -486,14 +658,11 @@ func (e *Executor) classStmt(x *ast.ClassStmt) execResult {
 			} else {
 				defaultValue = UninitializedZval()
 			}
-			flags := e.parseFlags(s.Flags)
+			flags := MemberFlags(s.Flags)
 			DeclPropertyInfo(ce, flags, s.Name.Name, nil, defaultValue)
 		case *ast.ClassMethodStmt:
-			method := types.NewAstFunction(
-				s.Name.Name,
-				nil,
-				s.Stmts,
-			)
+			method := e.newUserFunction(s.Name.Name, s.Params, s.ByRef, s.Stmts)
+			method.AddFnFlags(MemberFlags(s.Flags))
 			DeclMethod(ce, method)
 		default:
 			panic(perr.Todof("class stmt type: %T", s))
@@ -597,6 +766,8 @@ func (e *Executor) expr(expr ast.Expr) types.Zval {
 		return e.ternaryExpr(x)
 	case *ast.ThrowExpr:
 		return e.throwExpr(x)
+	case *ast.MatchExpr:
+		return e.matchExpr(x)
 	case *ast.VariableExpr:
 		return e.variableExpr(x)
 	case *ast.YieldExpr:
@@ -644,15 +815,101 @@ func (e *Executor) arrayExpr(expr *ast.ArrayExpr) types.Zval {
 }
 
 func (e *Executor) closureExpr(expr *ast.ClosureExpr) types.Zval {
-	panic(perr.Todof("e.closureExpr"))
+	var vars *types.Array
+	if len(expr.Uses) > 0 {
+		vars = types.NewArrayCap(len(expr.Uses))
+		for _, use := range expr.Uses {
+			vars.KeyUpdate(e.variableName(use.Var.Name), e.closureUseExpr(use))
+		}
+	}
+	fn := e.newUserFunction("{closure}", expr.Params, expr.ByRef, expr.Stmts)
+	return e.newClosure(fn, expr.Static, vars)
 }
 
+// closureUseExpr 闭包 use 绑定的外部变量，按引用绑定时返回引用
 func (e *Executor) closureUseExpr(expr *ast.ClosureUseExpr) types.Zval {
-	panic(perr.Todof("e.closureUseExpr"))
+	if expr.ByRef {
+		return e.variableRefZval(expr.Var)
+	}
+	return e.exprDeref(expr.Var)
 }
 
+// arrowFunctionExpr 箭头函数按值绑定函数体内用到的外部变量，外部不存在的变量不绑定，在函数体内读取时报错
 func (e *Executor) arrowFunctionExpr(expr *ast.ArrowFunctionExpr) types.Zval {
-	panic(perr.Todof("e.arrowFunctionExpr"))
+	vars := types.NewArray()
+	for _, name := range arrowFunctionUses(expr) {
+		if value := e.currSymbols().Get(name).DeRef(); !value.IsUndef() {
+			vars.KeyUpdate(name, value)
+		}
+	}
+	ret := &ast.ReturnStmt{Expr: expr.Expr}
+	ret.SetMeta(expr.Expr.Meta())
+	fn := e.newUserFunction("{closure}", expr.Params, expr.ByRef, []ast.Stmt{ret})
+	return e.newClosure(fn, expr.Static, vars)
+}
+
+// newClosure 创建闭包对象。闭包的作用域为声明所在的类，非静态闭包自动绑定当前的 $this
+func (e *Executor) newClosure(fn *types.Function, static bool, vars *types.Array) types.Zval {
+	ex := e.ctx.CurrEX()
+	fn.SetScope(ex.Fn().Scope())
+	fn.SetStaticVariables(vars)
+
+	var this *types.Object
+	if static {
+		fn.AddFnFlags(types.AccStatic)
+	} else {
+		this = ex.ThisObject()
+	}
+	calledScope := ex.ThisClass()
+	if calledScope == nil {
+		calledScope = fn.Scope()
+	}
+	return types.ZvalObject(NewClosure(e.ctx, fn, calledScope, this))
+}
+
+// arrowFunctionUses 箭头函数体内用到的外部变量，包括嵌套的箭头函数及闭包 use 的变量，按首次出现的顺序排列
+func arrowFunctionUses(fn *ast.ArrowFunctionExpr) []string {
+	var names []string
+	seen := map[string]bool{"this": true}
+	use := func(v *ast.VariableExpr, bound map[string]bool) {
+		if ident, ok := v.Name.(*ast.Ident); ok && !bound[ident.Name] && !seen[ident.Name] {
+			seen[ident.Name] = true
+			names = append(names, ident.Name)
+		}
+	}
+
+	// bound 为箭头函数(及外层箭头函数)的参数，不是外部变量
+	var visit func(fn *ast.ArrowFunctionExpr, outer map[string]bool)
+	visit = func(fn *ast.ArrowFunctionExpr, outer map[string]bool) {
+		bound := make(map[string]bool, len(outer)+len(fn.Params))
+		for name := range outer {
+			bound[name] = true
+		}
+		for _, param := range fn.Params {
+			if ident, ok := param.Var.Name.(*ast.Ident); ok {
+				bound[ident.Name] = true
+			}
+		}
+		ast.Inspect(fn.Expr, func(n ast.Node) bool {
+			switch x := n.(type) {
+			case *ast.ArrowFunctionExpr:
+				visit(x, bound)
+				return false
+			case *ast.ClosureExpr:
+				for _, u := range x.Uses {
+					use(u.Var, bound)
+				}
+				return false
+			case *ast.ClassStmt:
+				return false
+			case *ast.VariableExpr:
+				use(x, bound)
+			}
+			return true
+		})
+	}
+	visit(fn, nil)
+	return names
 }
 
 func (e *Executor) indexExpr(expr *ast.IndexExpr) types.Zval {
@@ -749,7 +1006,7 @@ func (e *Executor) binaryOpExpr(expr *ast.BinaryOpExpr) (val types.Zval) {
 	case ast.BinaryOpBooleanOr: // ||
 		left := e.expr(expr.Left)
 		right := func() types.Zval { return e.expr(expr.Right) }
-		return OpBooleanAnd(e.ctx, left, right)
+		return OpBooleanOr(e.ctx, left, right)
 	case ast.BinaryOpCoalesce: // ??
 		left := e.expr(expr.Left)
 		right := func() types.Zval { return e.expr(expr.Right) }
@@ -888,7 +1145,8 @@ func (e *Executor) evalExpr(expr *ast.EvalExpr) types.Zval {
 }
 
 func (e *Executor) includeExpr(expr *ast.IncludeExpr) types.Zval {
-	panic(perr.Todof("e.includeExpr"))
+	path := ZvalGetStrVal(e.ctx, e.expr(expr.Expr))
+	return IncludeFile(e.ctx, expr.Kind, path)
 }
 
 func (e *Executor) cloneExpr(expr *ast.CloneExpr) types.Zval {
@@ -917,15 +1175,34 @@ func (e *Executor) exitExpr(expr *ast.ExitExpr) types.Zval {
 }
 
 func (e *Executor) constFetchExpr(expr *ast.ConstFetchExpr) types.Zval {
-	// todo 在命名空间内，使用非限定名时的常量获取逻辑
 	name := expr.Name.ToCodeString()
-	c := GetConstant(e.ctx, name)
+	var c *types.Constant
+	if nsName := namespacedName(expr.Name); nsName != "" {
+		// 命名空间内的非限定名称，先查找命名空间下的常量，不存在时回退到全局常量
+		c = GetConstant(e.ctx, nsName)
+	}
+	if c == nil {
+		c = GetConstant(e.ctx, name)
+	}
 	if c == nil {
 		panic(perr.Todof("const not defined: " + name))
 	}
 	return c.Value()
 }
 
+// namespacedName 命名空间内的非限定函数名或常量名在当前命名空间下的名称，由解析器记录在元数据中。
+// 其他名称返回空字符串
+func namespacedName(n ast.Node) string {
+	name, ok := n.(*ast.Name)
+	if !ok || name.Meta() == nil {
+		return ""
+	}
+	if nsName, ok := name.Meta().Others["namespacedName"].(*ast.Name); ok {
+		return nsName.ToString()
+	}
+	return ""
+}
+
 func (e *Executor) classConstFetchExpr(expr *ast.ClassConstFetchExpr) types.Zval {
 	className := e.nameOrExprAsString(expr.Class)
 	constName := expr.Name.Name
@@ -955,7 +1232,8 @@ func (e *Executor) magicConstExpr(expr *ast.MagicConstExpr) types.Zval {
 		file := GetExecutedFilenameVal(e.ctx)
 		return types.ZvalString(file)
 	//case ast.MagicConstFunction: // __FUNCTION__
-	//case ast.MagicConstLine: // __LINE__
+	case ast.MagicConstLine: // __LINE__
+		return Long(ast.MetaStart(expr).Line)
 	//case ast.MagicConstMethod: // __METHOD__
 	//case ast.MagicConstNamespace: // __NAMESPACE__
 	//case ast.MagicConstTrait: // __TRAIT__
@@ -965,17 +1243,28 @@ func (e *Executor) magicConstExpr(expr *ast.MagicConstExpr) types.Zval {
 }
 
 func (e *Executor) instanceofExpr(expr *ast.InstanceofExpr) types.Zval {
-	//val := e.expr(expr)
-	//var className string
-	//switch c := expr.Class.(type) {
-	//case *ast.Name:
-	//	className = c.ToCodeString()
-	//case ast.Expr:
-	//	className = ZvalGetStrVal(e.ctx, e.expr(c))
-	//default:
-	//	panic(perr.Internalf("预期外的 InstanceofExpr.Class 类型: %+v", c))
-	//}
-	panic(perr.Todof("e.instanceofExpr"))
+	val := e.exprDeref(expr.Expr)
+
+	var ce *types.Class
+	switch c := expr.Class.(type) {
+	case *ast.Name:
+		// 类不存在时结果为 false，不触发错误
+		ce = ZendLookupClassEx(e.ctx, c.ToCodeString(), "", 0)
+	case ast.Expr:
+		switch class := e.exprDeref(c); {
+		case class.IsObject():
+			ce = class.Object().Class()
+		case class.IsString():
+			ce = ZendLookupClassEx(e.ctx, class.String(), "", 0)
+		default:
+			ThrowError(e.ctx, nil, "Class name must be a valid object or a string")
+			return UninitializedZval()
+		}
+	default:
+		panic(perr.Internalf("预期外的 InstanceofExpr.Class 类型: %+v", c))
+	}
+
+	return types.ZvalBool(val.IsObject() && ce != nil && InstanceofFunction(val.Object().Class(), ce))
 }
 
 func (e *Executor) listExpr(expr *ast.ListExpr) types.Zval {
@@ -1020,7 +1309,40 @@ func (e *Executor) ternaryExpr(expr *ast.TernaryExpr) types.Zval {
 }
 
 func (e *Executor) throwExpr(expr *ast.ThrowExpr) types.Zval {
-	panic(perr.Todof("e.throwExpr"))
+	ThrowObject(e.ctx, e.expr(expr.Expr))
+	return UninitializedZval()
+}
+
+// matchExpr 依次以 === 比较各分支的条件，执行第一个匹配的分支；没有匹配且没有 default 分支时抛出 UnhandledMatchError
+func (e *Executor) matchExpr(expr *ast.MatchExpr) types.Zval {
+	value := e.expr(expr.Cond)
+	var defaultArm *ast.MatchArm
+	for _, arm := range expr.Arms {
+		if arm.Conds == nil {
+			defaultArm = arm
+			continue
+		}
+		for _, cond := range arm.Conds {
+			if ZvalIsIdentical(e.ctx, value, e.expr(cond)) {
+				return e.expr(arm.Body)
+			}
+		}
+	}
+	if defaultArm != nil {
+		return e.expr(defaultArm.Body)
+	}
+
+	var message string
+	switch value.Type() {
+	case types.IsLong:
+		message = fmt.Sprintf("Unhandled match case %d", value.Long())
+	case types.IsString:
+		message = "Unhandled match case '" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(value.String()) + "'"
+	default:
+		message = "Unhandled match case of type " + types.ZendZvalTypeName(value)
+	}
+	ThrowError(e.ctx, ThrowableClass(e.ctx, UnhandledMatchErrorClassName), message)
+	return UninitializedZval()
 }
 
 func (e *Executor) variableExpr(expr *ast.VariableExpr) types.Zval {
@@ -1086,12 +1408,49 @@ func (e *Executor) nameOrExprAsString(node ast.Node) string {
 	}
 }
 
+// yieldExpr 产出键值并挂起生成器，恢复后返回 send() 传入的值，或在此处抛出 throw() 传入的异常
 func (e *Executor) yieldExpr(expr *ast.YieldExpr) types.Zval {
-	panic(perr.Todof("e.yieldExpr"))
+	g := e.currGenerator()
+	key, value := types.Undef, types.Null
+	if expr.Key != nil {
+		key = e.exprDeref(expr.Key)
+	}
+	if expr.Value != nil {
+		value = e.yieldValue(g, expr.Value)
+	}
+	sent, thrown := g.Yield(key, value)
+	if !thrown.IsUndef() {
+		ThrowObject(e.ctx, thrown)
+	}
+	return sent
+}
+
+// yieldValue 产出的值，按引用产出的生成器产出变量的引用
+func (e *Executor) yieldValue(g *Generator, expr ast.Expr) types.Zval {
+	if g.ByRef() {
+		switch expr.(type) {
+		case *ast.VariableExpr, *ast.IndexExpr, *ast.PropertyFetchExpr:
+			return e.variableRefZval(expr)
+		}
+		Error(e.ctx, perr.E_NOTICE, "Only variable references should be yielded by reference")
+	}
+	return e.exprDeref(expr)
 }
 
 func (e *Executor) yieldFromExpr(expr *ast.YieldFromExpr) types.Zval {
-	panic(perr.Todof("e.yieldFromExpr"))
+	result, thrown := e.currGenerator().YieldFrom(e.expr(expr.Expr))
+	if !thrown.IsUndef() {
+		ThrowObject(e.ctx, thrown)
+	}
+	return result
+}
+
+func (e *Executor) currGenerator() *Generator {
+	g := e.ctx.CurrEX().generator
+	if g == nil {
+		panic(perr.Internalf("yield 只能用于生成器函数体内"))
+	}
+	return g
 }
 
 func (e *Executor) funcCallExpr(expr *ast.FuncCallExpr) types.Zval {
@@ -1100,18 +1459,70 @@ func (e *Executor) funcCallExpr(expr *ast.FuncCallExpr) types.Zval {
 	case *ast.Name:
 		name = nameNode.ToString()
 	case ast.Expr:
-		name = ZvalGetStrVal(e.ctx, e.expr(nameNode))
+		callable := e.exprDeref(nameNode)
+		if !callable.IsString() {
+			fn, scope := e.initDynamicCall(callable)
+			if fn == nil {
+				return UninitializedZval()
+			}
+			return e.doCallExprs(fn, expr.Args, scope)
+		}
+		name = callable.String()
+		if idx := strings.Index(name, "::"); idx > 0 {
+			fn, scope := e.initStaticMethodCall(e.fetchClass(name[:idx]), name[idx+2:], false)
+			if fn == nil {
+				return UninitializedZval()
+			}
+			return e.doCallExprs(fn, expr.Args, scope)
+		}
 	default:
 		panic(perr.Unreachable())
 	}
 
-	fn := e.initStringCall(name)
+	var fn *types.Function
+	if nsName := namespacedName(expr.Name); nsName != "" {
+		fn = e.ctx.EG().FindFunctionNs(nsName, name)
+		if fn == nil {
+			ThrowError(e.ctx, nil, fmt.Sprintf("Call to undefined function %s()", nsName))
+			return UninitializedZval()
+		}
+	} else {
+		fn = e.initStringCall(name)
+	}
 	if fn == nil {
 		panic(perr.Internalf("函数未找到: %s", name))
 	}
 	return e.doCallExprs(fn, expr.Args, nil)
 }
 
+// initDynamicCall 解析闭包、实现了 __invoke 的对象或数组形式的回调，返回被调用的函数及其 $this 或类
+func (e *Executor) initDynamicCall(callable types.Zval) (*types.Function, any) {
+	if callable.IsArray() {
+		arr := callable.Array()
+		target, method := arr.IndexFind(0).DeRef(), arr.IndexFind(1).DeRef()
+		switch {
+		case arr.Len() != 2 || target.IsUndef() || method.IsUndef():
+			ThrowError(e.ctx, nil, "Array callback must have exactly two elements")
+		case !method.IsString():
+			ThrowError(e.ctx, nil, "Second array member is not a valid method")
+		case target.IsObject():
+			return e.initMethodCall(target.Object(), method.String())
+		case target.IsString():
+			return e.initStaticMethodCall(e.fetchClass(target.String()), method.String(), false)
+		default:
+			ThrowError(e.ctx, nil, "First array member is not a valid class name or object")
+		}
+		return nil, nil
+	}
+	if callable.IsObject() {
+		if fn, scope, _ := ResolveCallable(e.ctx, callable); fn != nil {
+			return fn, scope
+		}
+	}
+	ThrowError(e.ctx, nil, "Function name must be a string")
+	return nil, nil
+}
+
 func (e *Executor) newExpr(expr *ast.NewExpr) types.Zval {
 	var ce *types.Class
 	switch x := expr.Class.(type) {
@@ -1130,7 +1541,9 @@ func (e *Executor) newExpr(expr *ast.NewExpr) types.Zval {
 		return UninitializedZval()
 	}
 
-	// todo constructor
+	if ctor := ce.GetConstructor(); ctor != nil {
+		e.doCallExprs(ctor, expr.Args, obj)
+	}
 	return types.ZvalObject(obj)
 }
 
@@ -1142,17 +1555,106 @@ func (e *Executor) methodCallExpr(expr *ast.MethodCallExpr) types.Zval {
 		return UninitializedZval()
 	}
 
-	method := obj.Object().GetMethod(methodName)
+	method, scope := e.initMethodCall(obj.Object(), methodName)
 	if method == nil {
-		ThrowError(e.ctx, nil, fmt.Sprintf("Call to undefined method %s::%s()", obj.Object().Class().Name(), methodName))
 		return UninitializedZval()
 	}
+	return e.doCallExprs(method, expr.Args, scope)
+}
 
-	return e.doCallExprs(method, expr.Args, obj.Object())
+func (e *Executor) initMethodCall(obj *types.Object, methodName string) (*types.Function, any) {
+	method := obj.GetMethod(methodName)
+	if method == nil {
+		ThrowError(e.ctx, nil, fmt.Sprintf("Call to undefined method %s::%s()", obj.Class().Name(), methodName))
+		return nil, nil
+	}
+	if method.IsStatic() {
+		return method, obj.Class()
+	}
+	return method, obj
 }
 
 func (e *Executor) staticCallExpr(expr *ast.StaticCallExpr) types.Zval {
-	panic(perr.Todof("e.staticCallExpr"))
+	var ce *types.Class
+	var forwarding bool
+	switch x := expr.Class.(type) {
+	case *ast.Name:
+		name := x.ToCodeString()
+		ce = e.fetchClass(name)
+		switch ascii.StrToLower(name) {
+		case "self", "parent", "static":
+			forwarding = true
+		}
+	case ast.Expr:
+		if class := e.exprDeref(x); class.IsObject() {
+			ce = class.Object().Class()
+		} else {
+			ce = e.fetchClass(ZvalGetStrVal(e.ctx, class))
+		}
+	default:
+		panic(perr.Unreachable())
+	}
+
+	method, scope := e.initStaticMethodCall(ce, e.identOrExprAsString(expr.Name), forwarding)
+	if method == nil {
+		return UninitializedZval()
+	}
+	return e.doCallExprs(method, expr.Args, scope)
+}
+
+// initStaticMethodCall 以 Class::method() 的形式调用方法。非静态方法只能在子类的实例方法内调用(如 parent::foo())，
+// 此时传递当前的 $this；forwarding 为 true 时(self::、parent::、static::)保留调用时的类
+func (e *Executor) initStaticMethodCall(ce *types.Class, methodName string, forwarding bool) (*types.Function, any) {
+	if ce == nil {
+		return nil, nil
+	}
+	method := ce.FunctionTable().Get(ascii.StrToLower(methodName))
+	if method == nil {
+		ThrowError(e.ctx, nil, fmt.Sprintf("Call to undefined method %s::%s()", ce.Name(), methodName))
+		return nil, nil
+	}
+
+	ex := e.ctx.CurrEX()
+	if !method.IsStatic() {
+		this := ex.ThisObject()
+		if this == nil || !InstanceofFunction(this.Class(), ce) {
+			ThrowError(e.ctx, nil, fmt.Sprintf("Non-static method %s::%s() cannot be called statically", ce.Name(), method.Name()))
+			return nil, nil
+		}
+		return method, this
+	}
+	if called := ex.ThisClass(); forwarding && called != nil && InstanceofFunction(called, ce) {
+		return method, called
+	}
+	return method, ce
+}
+
+// fetchClass 查找类，支持 self、parent 及 static
+func (e *Executor) fetchClass(name string) *types.Class {
+	ex := e.ctx.CurrEX()
+	switch ascii.StrToLower(name) {
+	case "self":
+		if scope := ex.Fn().Scope(); scope != nil {
+			return scope
+		}
+		ThrowError(e.ctx, nil, `Cannot access self:: when no class scope is active`)
+	case "parent":
+		if scope := ex.Fn().Scope(); scope == nil {
+			ThrowError(e.ctx, nil, `Cannot access parent:: when no class scope is active`)
+		} else if scope.Parent() == nil {
+			ThrowError(e.ctx, nil, `Cannot access parent:: when current class scope has no parent`)
+		} else {
+			return scope.Parent()
+		}
+	case "static":
+		if called := ex.ThisClass(); called != nil {
+			return called
+		}
+		ThrowError(e.ctx, nil, `Cannot access static:: when no class scope is active`)
+	default:
+		return ZendFetchClassByName(e.ctx, name, "", 0)
+	}
+	return nil
 }
 
 // ---
@@ -1163,7 +1665,7 @@ func (e *Executor) variableSet(variable ast.Expr, value types.Zval) {
 
 func (e *Executor) variableRefArray(variable ast.Expr) types.Zval {
 	ref := e.variableRef(variable)
-	value := ref.Get()
+	value := ref.Get().DeRef()
 	if value.IsUndef() {
 		value = types.ZvalArrayInit()
 		ref.Set(value)
@@ -1261,9 +1763,35 @@ func (e *Executor) doCallExprs(fn *types.Function, argNodes []*ast.Arg, scope an
 func (e *Executor) doCall(fn *types.Function, args []types.Zval, scope any) types.Zval {
 	assert.Assert(fn != nil)
 
-	// push && pop executeData
 	ex := NewExecuteData(e.ctx, fn, args)
 	ex.SetScope(scope)
+	retval := e.execute(ex)
+	e.callGenerator = ex.generator
+	if retval.IsUndef() {
+		retval = UninitializedZval()
+	}
+	return retval
+}
+
+// includeFile 执行被包含文件的顶层函数，与调用方共享符号表及作用域。文件中没有 return 时返回 1
+func (e *Executor) includeFile(fn *types.Function) types.Zval {
+	ex := NewExecuteData(e.ctx, fn, nil)
+	if caller := e.ctx.CurrEX(); caller != nil {
+		ex.SetSymbols(caller.Symbols())
+		ex.SetScope(caller.Scope())
+	}
+	retval := e.execute(ex)
+	if retval.IsUndef() {
+		return types.ZvalLong(1)
+	}
+	return retval
+}
+
+// execute 执行函数，函数没有返回值时返回 Undef
+func (e *Executor) execute(ex *ExecuteData) types.Zval {
+	fn := ex.Fn()
+
+	// push && pop executeData
 	e.ctx.EG().PushExecuteData(ex)
 	defer func() {
 		popEx := e.ctx.EG().PopExecuteData()
@@ -1278,15 +1806,30 @@ func (e *Executor) doCall(fn *types.Function, args []types.Zval, scope any) type
 		} else {
 			perr.Panic(fmt.Sprintf("不支持的内部函数 handler 类型: %T", fn.Handler()))
 		}
+	} else if handler, ok := fn.Handler().(ZifHandler); ok {
+		// 转译为 go 代码的用户函数
+		handler(ex, &retval)
+	} else if fn.IsGenerator() {
+		retval = e.generatorFunction(ex)
 	} else {
-		retval = e.userFunction(fn, args)
-	}
-	if retval.IsUndef() {
-		retval = UninitializedZval()
+		retval = e.userFunction(fn, ex.Args())
 	}
 	return retval
 }
 
+// generatorFunction 调用生成器函数: 绑定参数后返回 Generator 对象，函数体在首次使用生成器时才开始执行
+func (e *Executor) generatorFunction(ex *ExecuteData) types.Zval {
+	fn := ex.Fn()
+	e.bindArgs(fn, ex.Args())
+	obj := NewGenerator(e.ctx, ex, func(g *Generator) types.Zval {
+		if retval := e.runFunction(fn); !retval.IsUndef() {
+			return retval.DeRef()
+		}
+		return types.Null
+	})
+	return types.ZvalObject(obj)
+}
+
 func (e *Executor) initCallArgs(fn *types.Function, argNodes []*ast.Arg) []types.Zval {
 	if len(argNodes) == 0 {
 		return nil
@@ -26,7 +26,16 @@ type ExecutorGlobals struct {
 
 	currentExecuteData *ExecuteData `prop:""`
 
+	includedFiles map[string]bool // 已包含的文件(绝对路径)，用于 include_once/require_once
+
 	nextObjectHandle uint
+
+	generators map[*Generator]struct{} // 已开始且未结束的生成器，请求结束时销毁
+
+	fibers       map[*types.Object]*Fiber // 请求中创建的 Fiber 对象及其执行状态，请求结束时销毁挂起中的 Fiber
+	currentFiber *Fiber                   // 正在执行的 Fiber，主流程中为 nil
+
+	exception *types.Object // 抛出中且尚未被捕获的异常
 }
 
 func (eg *ExecutorGlobals) InitBase(ctx *Context) {
@@ -54,17 +63,30 @@ func (eg *ExecutorGlobals) Init(ctx *Context, base *ExecutorGlobals) {
 	}
 }
 
+// FindFunction 按名称查找函数，函数名大小写无关，可带有前导的 "\\"。
+// 命名空间内非限定名称的回退由调用方处理，见 FindFunctionNs
 func (eg *ExecutorGlobals) FindFunction(name string) *types.Function {
-	// todo 完善 caseIgnore 及命名空间处理
-	lcName := strings.ToLower(name)
+	lcName := strings.ToLower(CleanNsName(name))
 	return eg.functionTable.Get(lcName)
 }
 
+// FindFunctionNs 查找命名空间内以非限定名称调用的函数: 先查找命名空间下的函数 nsName，不存在时回退到全局函数 name
+func (eg *ExecutorGlobals) FindFunctionNs(nsName string, name string) *types.Function {
+	if fn := eg.FindFunction(nsName); fn != nil {
+		return fn
+	}
+	return eg.FindFunction(name)
+}
+
+// HasException 是否有抛出中的异常。异常以 panic 传播，只在异常抛出到被捕获之间(如 defer 中)为 true
 func (eg *ExecutorGlobals) HasException() bool {
-	// todo
-	return false
+	return eg.exception != nil
 }
-func (eg *ExecutorGlobals) NoException() bool { return !eg.HasException() }
+func (eg *ExecutorGlobals) NoException() bool        { return !eg.HasException() }
+func (eg *ExecutorGlobals) Exception() *types.Object { return eg.exception }
+
+// ClearException 异常被捕获后清除当前异常
+func (eg *ExecutorGlobals) ClearException() { eg.exception = nil }
 
 func (eg *ExecutorGlobals) ErrorSuppress() bool {
 	return eg.errorSuppress > 0
@@ -93,6 +115,40 @@ func (eg *ExecutorGlobals) PopExecuteData() *ExecuteData {
 	return result
 }
 
+func (eg *ExecutorGlobals) addGenerator(g *Generator) {
+	if eg.generators == nil {
+		eg.generators = map[*Generator]struct{}{}
+	}
+	eg.generators[g] = struct{}{}
+}
+
+func (eg *ExecutorGlobals) removeGenerator(g *Generator) {
+	delete(eg.generators, g)
+}
+
+// DestroyGenerators 销毁未执行完的生成器，执行其中的 finally 并释放其 goroutine。
+// 对象没有引用计数，保存在变量中的生成器即使被 unset 也要到请求结束时才在这里销毁，
+// 只有 foreach 中的临时生成器在循环结束时立即销毁
+func (eg *ExecutorGlobals) DestroyGenerators() {
+	for g := range eg.generators {
+		callReportingUncaught(g.ctx, g.destroy)
+	}
+}
+
+func (eg *ExecutorGlobals) addFiber(f *Fiber) {
+	if eg.fibers == nil {
+		eg.fibers = map[*types.Object]*Fiber{}
+	}
+	eg.fibers[f.obj] = f
+}
+
+// DestroyFibers 销毁挂起中的 Fiber，执行其中的 finally 并释放其 goroutine
+func (eg *ExecutorGlobals) DestroyFibers() {
+	for _, f := range eg.fibers {
+		callReportingUncaught(f.ctx, f.destroy)
+	}
+}
+
 func (eg *ExecutorGlobals) NextObjectHandle() uint {
 	eg.nextObjectHandle++
 	return eg.nextObjectHandle
@@ -77,13 +77,16 @@ func HashOf(p types.Zval) *types.Array {
 	}
 }
 
+// ThrowException 抛出 ce 类的异常，ce 为 nil 时为 Exception，对应 zend_throw_exception
 func ThrowException(ctx *Context, ce *types.Class, message string, code int) {
 	if ctx.eh != nil {
 		ctx.eh.OnException(ce, message, code)
 		return
 	}
-
-	panic(perr.Todof("ThrowException: message=%s, code=%d", message, code))
+	if ce == nil {
+		ce = ThrowableClass(ctx, ExceptionClassName)
+	}
+	throwNew(ctx, ce, message, code)
 }
 
 func ManglePropertyName(src1 string, src2 string) string {
@@ -0,0 +1,320 @@
+package php
+
+import (
+	"fmt"
+	"github.com/heyuuu/gophp/php/types"
+)
+
+const (
+	FiberClassName      = "Fiber"
+	FiberErrorClassName = "FiberError"
+)
+
+// RegisterFiberClass 注册 Fiber 类及 FiberError 异常类。FiberError 的父类 Error 需已注册
+func RegisterFiberClass(ctx *Context, moduleNumber int) {
+	ce := RegisterInternalClass(ctx, moduleNumber, &types.InternalClassDecl{
+		Name:  FiberClassName,
+		Flags: types.AccFinal,
+	})
+	methods := []struct {
+		name    string
+		flags   uint32
+		handler ZifHandler
+	}{
+		{"__construct", types.AccPublic, fiberConstruct},
+		{"start", types.AccPublic, fiberStart},
+		{"resume", types.AccPublic, fiberResume},
+		{"throw", types.AccPublic, fiberThrow},
+		{"getReturn", types.AccPublic, fiberGetReturn},
+		{"isStarted", types.AccPublic, fiberStatusChecker(func(f *Fiber) bool { return f.status != fiberInit })},
+		{"isSuspended", types.AccPublic, fiberStatusChecker(func(f *Fiber) bool { return f.status == fiberSuspended })},
+		{"isRunning", types.AccPublic, fiberStatusChecker(func(f *Fiber) bool { return f.status == fiberRunning })},
+		{"isTerminated", types.AccPublic, fiberStatusChecker(func(f *Fiber) bool { return f.status == fiberTerminated })},
+		{"suspend", types.AccPublic | types.AccStatic, fiberSuspend},
+		{"getCurrent", types.AccPublic | types.AccStatic, fiberGetCurrent},
+	}
+	for _, m := range methods {
+		fn := types.NewInternalFunction(m.name, m.handler, moduleNumber)
+		fn.AddFnFlags(m.flags)
+		DeclMethod(ce, fn)
+	}
+
+	errorCe := RegisterInternalClass(ctx, moduleNumber, &types.InternalClassDecl{
+		Name:   FiberErrorClassName,
+		Parent: ErrorClassName,
+		Flags:  types.AccFinal,
+	})
+	fn := types.NewInternalFunction("__construct", ZifHandler(func(ex *ExecuteData, returnValue *types.Zval) {
+		ThrowError(ex.Ctx(), nil, `The "FiberError" class is reserved for internal use and cannot be manually instantiated`)
+	}), moduleNumber)
+	fn.AddFnFlags(types.AccPublic)
+	DeclMethod(errorCe, fn)
+	DoLinkClass(ctx, errorCe)
+}
+
+func throwFiberError(ctx *Context, message string) {
+	ThrowError(ctx, ThrowableClass(ctx, FiberErrorClassName), message)
+}
+
+// thisFiber 方法调用者对应的 Fiber，未经构造时返回 nil
+func thisFiber(ex *ExecuteData) *Fiber {
+	return ex.Ctx().EG().fibers[ex.ThisObject()]
+}
+
+// fiberConstruct Fiber::__construct(callable $callback)
+func fiberConstruct(ex *ExecuteData, returnValue *types.Zval) {
+	ctx := ex.Ctx()
+	fp := NewParamParser(ex, 1, 1, 0)
+	callback := fp.ParseZval()
+	if fp.HasError() {
+		return
+	}
+	fn, scope, errMsg := ResolveCallable(ctx, callback)
+	if fn == nil {
+		ThrowError(ctx, ThrowableClass(ctx, TypeErrorClassName), "Fiber::__construct(): Argument #1 ($callback) must be a valid callback, "+errMsg)
+		return
+	}
+	if f := thisFiber(ex); f != nil && f.status != fiberInit {
+		// 已开始的 Fiber 不允许重新构造
+		throwFiberError(ctx, "Cannot start a fiber that has already been started")
+		return
+	}
+	ctx.EG().addFiber(newFiber(ctx, ex.ThisObject(), fn, scope))
+}
+
+// fiberStart Fiber::start(mixed ...$args)，开始执行并返回第一次 Fiber::suspend() 传出的值
+func fiberStart(ex *ExecuteData, returnValue *types.Zval) {
+	fp := NewParamParser(ex, 0, -1, 0)
+	args := fp.ParseVariadic(0)
+	if fp.HasError() {
+		return
+	}
+	f := thisFiber(ex)
+	if f == nil {
+		return
+	}
+	if f.status != fiberInit {
+		throwFiberError(ex.Ctx(), "Cannot start a fiber that has already been started")
+		return
+	}
+	*returnValue = f.transferTo(fiberSignal{}, args)
+}
+
+// fiberResume Fiber::resume(mixed $value = null)，$value 作为 Fiber::suspend() 的返回值
+func fiberResume(ex *ExecuteData, returnValue *types.Zval) {
+	f := thisFiber(ex)
+	if f == nil {
+		return
+	}
+	if f.status != fiberSuspended {
+		throwFiberError(ex.Ctx(), "Cannot resume a fiber that is not suspended")
+		return
+	}
+	*returnValue = f.transferTo(fiberSignal{value: argOrNull(ex, 0)}, nil)
+}
+
+// fiberThrow Fiber::throw(Throwable $exception)，在 Fiber::suspend() 处抛出异常
+func fiberThrow(ex *ExecuteData, returnValue *types.Zval) {
+	ctx := ex.Ctx()
+	fp := NewParamParser(ex, 1, 1, 0)
+	exception := fp.ParseZval()
+	if fp.HasError() {
+		return
+	}
+	if exception = exception.DeRef(); !exception.IsObject() || !IsThrowable(ctx, exception.Object()) {
+		ThrowError(ctx, ThrowableClass(ctx, TypeErrorClassName), fmt.Sprintf("Fiber::throw(): Argument #1 ($exception) must be of type Throwable, %s given", types.ZendZvalTypeName(exception)))
+		return
+	}
+	f := thisFiber(ex)
+	if f == nil {
+		return
+	}
+	if f.status != fiberSuspended {
+		throwFiberError(ctx, "Cannot resume a fiber that is not suspended")
+		return
+	}
+	*returnValue = f.transferTo(fiberSignal{thrown: exception}, nil)
+}
+
+func fiberGetReturn(ex *ExecuteData, returnValue *types.Zval) {
+	f := thisFiber(ex)
+	if f == nil {
+		return
+	}
+	var reason string
+	switch {
+	case f.status == fiberTerminated && f.threw:
+		reason = "The fiber threw an exception"
+	case f.status == fiberTerminated:
+		*returnValue = f.retval
+		return
+	case f.status == fiberInit:
+		reason = "The fiber has not been started"
+	default:
+		reason = "The fiber has not returned"
+	}
+	throwFiberError(ex.Ctx(), "Cannot get fiber return value: "+reason)
+}
+
+func fiberStatusChecker(check func(f *Fiber) bool) ZifHandler {
+	return func(ex *ExecuteData, returnValue *types.Zval) {
+		if f := thisFiber(ex); f != nil {
+			*returnValue = types.ZvalBool(check(f))
+		}
+	}
+}
+
+// fiberSuspend Fiber::suspend(mixed $value = null)，挂起当前 Fiber 并将 $value 传给恢复它的调用方
+func fiberSuspend(ex *ExecuteData, returnValue *types.Zval) {
+	ctx := ex.Ctx()
+	f := ctx.EG().currentFiber
+	if f == nil {
+		throwFiberError(ctx, "Cannot suspend outside of fiber")
+		return
+	}
+	if f.destroyed {
+		throwFiberError(ctx, "Cannot suspend in a force-closed fiber")
+		return
+	}
+	*returnValue = f.suspend(argOrNull(ex, 0))
+}
+
+func fiberGetCurrent(ex *ExecuteData, returnValue *types.Zval) {
+	if f := ex.Ctx().EG().currentFiber; f != nil {
+		*returnValue = types.ZvalObject(f.obj)
+		return
+	}
+	*returnValue = types.Null
+}
+
+type fiberStatus uint8
+
+const (
+	fiberInit fiberStatus = iota
+	fiberRunning
+	fiberSuspended
+	fiberTerminated
+)
+
+// fiberSignal 恢复 Fiber 执行时传入的信号
+type fiberSignal struct {
+	value   types.Zval // resume() 传入的值
+	thrown  types.Zval // throw() 传入的异常
+	destroy bool       // 销毁挂起中的 Fiber
+}
+
+// fiberDestroyed 销毁 Fiber 时在 Fiber::suspend() 处触发的 panic，用于结束 Fiber 所在的 goroutine
+type fiberDestroyed struct{}
+
+// Fiber Fiber 对象的执行状态。函数在独立的 goroutine 中执行并拥有自己的执行栈，
+// 与恢复它的调用方严格交替运行，同一 Context 中同一时刻只有一个 Fiber 或主流程在执行
+type Fiber struct {
+	ctx   *Context
+	obj   *types.Object
+	fn    *types.Function
+	scope any // 调用 fn 时的 $this 或类
+
+	status    fiberStatus
+	threw     bool // 因未捕获的异常结束
+	destroyed bool // 已被销毁，正在执行 finally
+
+	bottom   *ExecuteData // fiber 函数的执行帧，即 Fiber 执行栈的栈底，恢复时链接到调用方的执行栈上
+	top      *ExecuteData // 挂起时 Fiber 执行栈的栈顶
+	previous *Fiber       // 恢复此 Fiber 时正在执行的 Fiber，主流程时为 nil
+
+	retval   types.Zval // fiber 函数的返回值
+	transfer types.Zval // Fiber::suspend() 传出的值
+
+	resumeCh  chan fiberSignal
+	suspendCh chan struct{}
+	panicVal  any // Fiber 内的 panic，交由调用方重新抛出
+}
+
+func newFiber(ctx *Context, obj *types.Object, fn *types.Function, scope any) *Fiber {
+	return &Fiber{
+		ctx:       ctx,
+		obj:       obj,
+		fn:        fn,
+		scope:     scope,
+		retval:    types.Null,
+		resumeCh:  make(chan fiberSignal),
+		suspendCh: make(chan struct{}),
+	}
+}
+
+// transferTo 切换到 Fiber 执行，直到其挂起或结束。返回 Fiber::suspend() 传出的值，Fiber 结束时返回 null
+func (f *Fiber) transferTo(sig fiberSignal, args []types.Zval) types.Zval {
+	eg := f.ctx.EG()
+	caller := eg.currentExecuteData
+	started := f.status != fiberInit
+	f.previous, eg.currentFiber = eg.currentFiber, f
+	f.status = fiberRunning
+	if !started {
+		go f.run(args)
+	} else {
+		// Fiber 的执行栈接在调用方之上，使调用栈(如异常的 trace)包含恢复它的位置
+		f.bottom.prev = caller
+		eg.currentExecuteData = f.top
+		f.resumeCh <- sig
+	}
+	<-f.suspendCh
+	eg.currentExecuteData = caller
+	eg.currentFiber, f.previous = f.previous, nil
+
+	if p := f.panicVal; p != nil {
+		f.panicVal = nil
+		panic(p)
+	}
+	if f.status == fiberTerminated {
+		return types.Null
+	}
+	value := f.transfer
+	f.transfer = types.Undef
+	return value
+}
+
+// run 在独立的 goroutine 中执行 fiber 函数
+func (f *Fiber) run(args []types.Zval) {
+	defer func() {
+		if e := recover(); e != nil {
+			if _, ok := e.(fiberDestroyed); !ok {
+				f.panicVal = e
+				f.threw = true
+			}
+		}
+		f.status = fiberTerminated
+		f.bottom, f.top = nil, nil
+		f.suspendCh <- struct{}{}
+	}()
+	ex := NewExecuteData(f.ctx, f.fn, args)
+	ex.SetScope(f.scope)
+	f.bottom = ex
+	if retval := f.ctx.executor.execute(ex); !retval.IsUndef() {
+		f.retval = retval.DeRef()
+	}
+}
+
+// suspend 在 Fiber 内挂起，交还控制权给调用方并等待下一次恢复
+func (f *Fiber) suspend(value types.Zval) types.Zval {
+	f.transfer = value
+	f.top = f.ctx.EG().currentExecuteData
+	f.status = fiberSuspended
+	f.suspendCh <- struct{}{}
+	sig := <-f.resumeCh
+	if sig.destroy {
+		f.destroyed = true
+		panic(fiberDestroyed{})
+	}
+	if !sig.thrown.IsUndef() {
+		ThrowObject(f.ctx, sig.thrown)
+		return types.Null
+	}
+	return sig.value
+}
+
+// destroy 结束挂起中的 Fiber，执行挂起处外层的 finally 语句块并释放其 goroutine
+func (f *Fiber) destroy() {
+	if f.status == fiberSuspended {
+		f.transferTo(fiberSignal{destroy: true}, nil)
+	}
+}
@@ -30,6 +30,19 @@ func (f *FileHandle) ReadAllEx() (string, error) {
 	return string(data), nil
 }
 
+// stat 返回底层文件信息，仅当句柄由真实文件打开时有效
+func (f *FileHandle) stat() (os.FileInfo, bool) {
+	fp, ok := f.reader.(*os.File)
+	if !ok {
+		return nil, false
+	}
+	info, err := fp.Stat()
+	if err != nil {
+		return nil, false
+	}
+	return info, true
+}
+
 func (f *FileHandle) Close() error {
 	if c, ok := f.reader.(io.Closer); ok {
 		return c.Close()
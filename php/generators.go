@@ -0,0 +1,326 @@
+package php
+
+import (
+	"github.com/heyuuu/gophp/kits/ascii"
+	"github.com/heyuuu/gophp/php/assert"
+	"github.com/heyuuu/gophp/php/types"
+)
+
+const GeneratorClassName = "Generator"
+
+// RegisterGeneratorClass 注册 Generator 类，其实例只能通过调用生成器函数创建
+func RegisterGeneratorClass(ctx *Context, moduleNumber int) {
+	ce := RegisterInternalClass(ctx, moduleNumber, &types.InternalClassDecl{
+		Name:       GeneratorClassName,
+		Flags:      types.AccFinal,
+		Interfaces: []string{IteratorClassName},
+	})
+	ce.SetCreateObject(func(classType *types.Class) *types.Object {
+		ThrowError(ctx, nil, `The "Generator" class is reserved for internal use and cannot be manually instantiated`)
+		return nil
+	})
+
+	methods := []struct {
+		name    string
+		handler func(g *Generator, ex *ExecuteData) types.Zval
+	}{
+		{"current", func(g *Generator, ex *ExecuteData) types.Zval { return g.Current() }},
+		{"key", func(g *Generator, ex *ExecuteData) types.Zval { return g.Key() }},
+		{"next", func(g *Generator, ex *ExecuteData) types.Zval { g.Next(); return types.Null }},
+		{"send", func(g *Generator, ex *ExecuteData) types.Zval { return g.Send(argOrNull(ex, 0)) }},
+		{"throw", func(g *Generator, ex *ExecuteData) types.Zval { return g.Throw(argOrNull(ex, 0)) }},
+		{"valid", func(g *Generator, ex *ExecuteData) types.Zval { return types.ZvalBool(g.Valid()) }},
+		{"rewind", func(g *Generator, ex *ExecuteData) types.Zval { g.Rewind(); return types.Null }},
+		{"getReturn", func(g *Generator, ex *ExecuteData) types.Zval { return g.GetReturn() }},
+	}
+	for _, m := range methods {
+		handler := m.handler
+		fn := types.NewInternalFunction(m.name, ZifHandler(func(ex *ExecuteData, returnValue *types.Zval) {
+			if g, ok := ex.ThisObject().Intern().(*Generator); ok {
+				*returnValue = handler(g, ex)
+			}
+		}), moduleNumber)
+		fn.AddFnFlags(types.AccPublic)
+		DeclMethod(ce, fn)
+	}
+	DoLinkClass(ctx, ce)
+}
+
+func argOrNull(ex *ExecuteData, pos int) types.Zval {
+	if arg := ex.Arg(pos); !arg.IsUndef() {
+		return arg.DeRef()
+	}
+	return types.Null
+}
+
+// generatorSignal 恢复生成器执行时传入的信号
+type generatorSignal struct {
+	sent    types.Zval // send() 传入的值，next() 时为 null
+	thrown  types.Zval // throw() 传入的异常
+	destroy bool       // 销毁未执行完的生成器
+}
+
+// generatorDestroyed 销毁生成器时在 yield 处触发的 panic，用于结束函数体所在的 goroutine
+type generatorDestroyed struct{}
+
+// Generator 生成器对象。函数体在独立的 goroutine 中执行，与恢复它的调用方严格交替运行，同一时刻只有一方在执行
+type Generator struct {
+	*StdInternObject
+	ex   *ExecuteData                  // 生成器函数的执行帧，恢复执行时压入执行栈
+	body func(g *Generator) types.Zval // 函数体，返回 return 的值
+
+	started      bool
+	finished     bool
+	running      bool
+	destroyed    bool // 已被销毁，正在执行 finally
+	atFirstYield bool // 停在第一个 yield 处，此时允许 rewind()
+
+	key, value    types.Zval
+	largestIntKey int // 已使用的最大整数键，用于自动生成键
+	retval        types.Zval
+
+	resumeCh  chan generatorSignal
+	suspendCh chan struct{}
+	panicVal  any // 函数体内的 panic，交由调用方重新抛出
+}
+
+// NewGenerator 创建生成器对象。ex 为生成器函数的执行帧(参数已绑定)，函数体在首次恢复时开始执行
+func NewGenerator(ctx *Context, ex *ExecuteData, body func(g *Generator) types.Zval) *types.Object {
+	ce := ctx.EG().ClassTable().Get(ascii.StrToLower(GeneratorClassName))
+	ex.Fn().SetIsGenerator(true)
+	g := &Generator{
+		StdInternObject: NewStdInternObject(ctx, ce),
+		ex:              ex,
+		body:            body,
+		largestIntKey:   -1,
+		retval:          types.Null,
+		resumeCh:        make(chan generatorSignal),
+		suspendCh:       make(chan struct{}),
+	}
+	ex.generator = g
+	return types.NewObjectSkipPropertiesInit(ctx.EG().NextObjectHandle(), g)
+}
+
+func (g *Generator) CanClone() bool { return false }
+
+// -- 调用方使用的方法
+
+func (g *Generator) Current() types.Zval {
+	return g.CurrentRef().DeRef()
+}
+
+// CurrentRef 当前值，按引用产出(function &gen())时为引用，用于 foreach 按引用遍历
+func (g *Generator) CurrentRef() types.Zval {
+	g.ensureInitialized()
+	if g.finished {
+		return types.Null
+	}
+	return g.value
+}
+
+// ByRef 生成器函数是否按引用产出
+func (g *Generator) ByRef() bool { return g.ex.Fn().IsReturnReference() }
+
+func (g *Generator) Key() types.Zval {
+	g.ensureInitialized()
+	if g.finished {
+		return types.Null
+	}
+	return g.key
+}
+
+func (g *Generator) Next() {
+	g.ensureInitialized()
+	g.resume(generatorSignal{sent: types.Null})
+}
+
+// Send 将 v 作为当前 yield 表达式的结果并继续执行，返回新的当前值
+func (g *Generator) Send(v types.Zval) types.Zval {
+	g.ensureInitialized()
+	if g.finished {
+		return types.Null
+	}
+	g.resume(generatorSignal{sent: v})
+	return g.Current()
+}
+
+// Throw 在当前 yield 处抛出异常并继续执行，返回新的当前值
+func (g *Generator) Throw(ex types.Zval) types.Zval {
+	g.ensureInitialized()
+	if g.finished {
+		// 已结束的生成器在调用方抛出异常
+		ThrowObject(g.ctx, ex)
+		return types.Null
+	}
+	g.resume(generatorSignal{thrown: ex})
+	return g.Current()
+}
+
+// Finished 函数体已执行结束
+func (g *Generator) Finished() bool { return g.finished }
+
+func (g *Generator) Valid() bool {
+	g.ensureInitialized()
+	return !g.finished
+}
+
+func (g *Generator) Rewind() {
+	g.ensureInitialized()
+	if !g.atFirstYield {
+		ThrowException(g.ctx, nil, "Cannot rewind a generator that was already run", 0)
+	}
+}
+
+func (g *Generator) GetReturn() types.Zval {
+	g.ensureInitialized()
+	if !g.finished {
+		ThrowException(g.ctx, nil, "Cannot get return value of a generator that hasn't returned", 0)
+		return types.Null
+	}
+	return g.retval
+}
+
+// ensureInitialized 未开始执行的生成器先执行到第一个 yield
+func (g *Generator) ensureInitialized() {
+	if !g.started && !g.running {
+		g.resume(generatorSignal{})
+		g.atFirstYield = true
+	}
+}
+
+// resume 恢复函数体的执行，直到下一个 yield 或函数结束
+func (g *Generator) resume(sig generatorSignal) {
+	if g.finished {
+		return
+	}
+	if g.running {
+		ThrowError(g.ctx, nil, "Cannot resume an already running generator")
+		return
+	}
+
+	g.running = true
+	g.atFirstYield = false
+	eg := g.ctx.EG()
+	eg.PushExecuteData(g.ex)
+	if !g.started {
+		g.started = true
+		eg.addGenerator(g)
+		go g.run()
+	} else {
+		g.resumeCh <- sig
+	}
+	<-g.suspendCh
+	popEx := eg.PopExecuteData()
+	assert.AssertEx(popEx == g.ex, "生成器恢复前后的 executeData 不相同，执行堆栈可能有错误")
+	g.running = false
+
+	if p := g.panicVal; p != nil {
+		g.panicVal = nil
+		panic(p)
+	}
+}
+
+// run 在独立的 goroutine 中执行函数体
+func (g *Generator) run() {
+	defer func() {
+		if e := recover(); e != nil {
+			if _, ok := e.(generatorDestroyed); !ok {
+				g.panicVal = e
+			}
+		}
+		g.finished = true
+		g.key, g.value = types.Undef, types.Undef
+		g.ctx.EG().removeGenerator(g)
+		g.suspendCh <- struct{}{}
+	}()
+	g.retval = g.body(g)
+}
+
+// destroy 结束未执行完的生成器，执行挂起处外层的 finally 语句块并释放其 goroutine
+func (g *Generator) destroy() {
+	if g.started && !g.finished && !g.running {
+		g.resume(generatorSignal{destroy: true})
+	}
+}
+
+// -- 函数体内使用的方法
+
+// Yield 产出键值并挂起，key 为 Undef 时自动生成整数键。
+// 恢复后返回 send() 传入的值；通过 throw() 恢复时 thrown 为抛入的异常，由函数体在 yield 处抛出
+func (g *Generator) Yield(key types.Zval, value types.Zval) (sent types.Zval, thrown types.Zval) {
+	if key.IsUndef() {
+		g.largestIntKey++
+		key = types.ZvalLong(g.largestIntKey)
+	} else if key.IsLong() && key.Long() > g.largestIntKey {
+		g.largestIntKey = key.Long()
+	}
+	g.key, g.value = key, value
+	return g.suspend()
+}
+
+// YieldFrom 委托给数组、另一个生成器或 Traversable 对象，依次产出其中的键值。返回内部生成器的返回值，数组时返回 null
+func (g *Generator) YieldFrom(from types.Zval) (result types.Zval, thrown types.Zval) {
+	from = from.DeRef()
+	switch {
+	case from.IsArray():
+		for _, pair := range from.Array().Pairs() {
+			g.key, g.value = pair.Key.ToZval(), pair.Val.DeRef()
+			if _, thrown = g.suspend(); !thrown.IsUndef() {
+				return types.Null, thrown
+			}
+		}
+		return types.Null, types.Undef
+	case from.IsObject():
+		inner, ok := from.Object().Intern().(*Generator)
+		if !ok {
+			return g.yieldFromTraversable(from.Object())
+		}
+		if inner == g || inner.running {
+			ThrowError(g.ctx, nil, "Impossible to yield from the Generator being currently run")
+			return types.Null, types.Undef
+		}
+		// 内部生成器的异常在外部生成器的 yield from 处抛出，由调用方的 resume 转交
+		for inner.Valid() {
+			g.key, g.value = inner.key, inner.value
+			sent, thrown := g.suspend()
+			if !thrown.IsUndef() {
+				inner.resume(generatorSignal{thrown: thrown})
+			} else {
+				inner.resume(generatorSignal{sent: sent})
+			}
+		}
+		return inner.GetReturn(), types.Undef
+	default:
+		ThrowError(g.ctx, nil, `Can use "yield from" only with arrays and Traversables`)
+		return types.Null, types.Undef
+	}
+}
+
+// yieldFromTraversable 委托给生成器以外的 Traversable 对象，通过其 Iterator 依次产出键值，send() 传入的值被忽略
+func (g *Generator) yieldFromTraversable(obj *types.Object) (result types.Zval, thrown types.Zval) {
+	thrown = types.Undef
+	traversable := IterateObject(g.ctx, obj, func(key types.Zval, value types.Zval) bool {
+		g.key, g.value = key, value
+		_, thrown = g.suspend()
+		return thrown.IsUndef()
+	})
+	if !traversable {
+		ThrowError(g.ctx, nil, `Can use "yield from" only with arrays and Traversables`)
+	}
+	return types.Null, thrown
+}
+
+// suspend 交还控制权给调用方，等待下一次恢复
+func (g *Generator) suspend() (sent types.Zval, thrown types.Zval) {
+	if g.destroyed {
+		ThrowError(g.ctx, nil, "Cannot yield from finally in a force-closed generator")
+		return types.Null, types.Undef
+	}
+	g.suspendCh <- struct{}{}
+	sig := <-g.resumeCh
+	if sig.destroy {
+		g.destroyed = true
+		panic(generatorDestroyed{})
+	}
+	return sig.sent, sig.thrown
+}
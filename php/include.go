@@ -0,0 +1,102 @@
+package php
+
+import (
+	"fmt"
+	"github.com/heyuuu/gophp/compile/ast"
+	"github.com/heyuuu/gophp/php/perr"
+	"github.com/heyuuu/gophp/php/types"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// IncludeFile 执行 include/include_once/require/require_once，优先使用已注册的转译脚本。
+// 返回文件中 return 的值，没有 return 时为 1；*_once 文件已包含时为 true；失败时为 false
+func IncludeFile(ctx *Context, kind ast.IncludeKind, path string) types.Zval {
+	if path == "" {
+		ThrowError(ctx, nil, "Path cannot be empty")
+		return types.False
+	}
+
+	resolved, ok := resolveIncludePath(ctx, path)
+	if !ok {
+		includeFailed(ctx, kind, path)
+		return types.False
+	}
+
+	if kind == ast.KindIncludeOnce || kind == ast.KindRequireOnce {
+		if isFileIncluded(ctx, resolved) {
+			return types.True
+		}
+	}
+
+	var fn *types.Function
+	if compile, ok := lookupCompiledScript(resolved); ok {
+		fn = compile()
+	} else {
+		fileHandle, err := NewFileHandleByFilename(resolved)
+		if err != nil {
+			includeFailed(ctx, kind, path)
+			return types.False
+		}
+		defer fileHandle.Close()
+
+		fn, err = CompileFile(ctx, fileHandle, false)
+		if err != nil {
+			return types.False
+		}
+	}
+
+	addIncludedFile(ctx, resolved)
+	return ctx.executor.includeFile(fn)
+}
+
+// resolveIncludePath 查找被包含的文件，include_path 固定为 "."。
+// 相对路径依次相对于当前工作目录及当前执行文件所在目录查找，./ 或 ../ 开头的路径仅相对于当前工作目录
+func resolveIncludePath(ctx *Context, path string) (string, bool) {
+	candidates := []string{path}
+	if !filepath.IsAbs(path) && !strings.HasPrefix(path, "./") && !strings.HasPrefix(path, "../") {
+		if filename := GetExecutedFilenameVal(ctx); filename != "" && filename != CommandLineFileName {
+			candidates = append(candidates, filepath.Join(filepath.Dir(filename), path))
+		}
+	}
+
+	for _, candidate := range candidates {
+		if HasCompiledScript(candidate) {
+			return candidate, true
+		}
+		if info, err := os.Stat(candidate); err == nil && !info.IsDir() {
+			return candidate, true
+		}
+	}
+	return "", false
+}
+
+// includeFailed 报告文件打开失败。include/include_once 只产生警告；require/require_once 为致命错误，结束执行且不能被 catch 捕获
+func includeFailed(ctx *Context, kind ast.IncludeKind, path string) {
+	Error(ctx, perr.E_WARNING, fmt.Sprintf("%s(%s): Failed to open stream: No such file or directory", kind, path))
+	if kind == ast.KindRequire || kind == ast.KindRequireOnce {
+		ErrorNoreturn(ctx, perr.E_COMPILE_ERROR, fmt.Sprintf("%s(): Failed opening required '%s' (include_path='.')", kind, path))
+	} else {
+		Error(ctx, perr.E_WARNING, fmt.Sprintf("%s(): Failed opening '%s' for inclusion (include_path='.')", kind, path))
+	}
+}
+
+func includedFileKey(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return path
+}
+
+func isFileIncluded(ctx *Context, path string) bool {
+	return ctx.EG().includedFiles[includedFileKey(path)]
+}
+
+func addIncludedFile(ctx *Context, path string) {
+	eg := ctx.EG()
+	if eg.includedFiles == nil {
+		eg.includedFiles = map[string]bool{}
+	}
+	eg.includedFiles[includedFileKey(path)] = true
+}
@@ -1,7 +1,11 @@
 package php
 
 import (
+	"fmt"
+	"github.com/heyuuu/gophp/kits/ascii"
+	"github.com/heyuuu/gophp/php/perr"
 	"github.com/heyuuu/gophp/php/types"
+	"strings"
 )
 
 func visibilityString(flags uint32) string {
@@ -15,72 +19,237 @@ func visibilityString(flags uint32) string {
 	}
 }
 
-func inheritProperty(ctx *Context, parentInfo *types.PropertyInfo, key string, ce *types.Class) {
-	var childInfo = ce.PropertyTable().Get(key)
-	if childInfo != nil {
-		if parentInfo.HasFlags(types.AccPrivate | types.AccChanged) {
-			childInfo.MarkIsChanged()
+// DoLinkClass 链接类: 解析父类及接口，继承常量、属性及方法，并计算属性偏移。失败时已报错并返回 false
+func DoLinkClass(ctx *Context, ce *types.Class) bool {
+	if ce.IsLinked() {
+		return true
+	}
+
+	var parent *types.Class
+	if ce.ParentName() != "" {
+		parent = ZendFetchClassByName(ctx, ce.ParentName(), "", 0)
+		if parent == nil {
+			return false
+		}
+		switch {
+		case parent.IsInterface():
+			Error(ctx, perr.E_COMPILE_ERROR, fmt.Sprintf("Class %s cannot extend interface %s", ce.Name(), parent.Name()))
+			return false
+		case parent.IsTrait():
+			Error(ctx, perr.E_COMPILE_ERROR, fmt.Sprintf("Class %s cannot extend trait %s", ce.Name(), parent.Name()))
+			return false
+		case parent.IsFinal():
+			Error(ctx, perr.E_COMPILE_ERROR, fmt.Sprintf("Class %s cannot extend final class %s", ce.Name(), parent.Name()))
+			return false
+		}
+		if !DoLinkClass(ctx, parent) {
+			return false
 		}
-		if !parentInfo.IsPrivate() {
+		ce.SetParent(parent)
+		ce.SetParentName("")
+	}
 
+	interfaces, ok := linkInterfaces(ctx, ce, parent)
+	if !ok {
+		return false
+	}
+	ce.SetInterfaces(interfaces)
+
+	if parent != nil {
+		inheritConstants(ce, parent)
+		inheritProperties(ce, parent)
+		if !inheritMethods(ctx, ce, parent) {
+			return false
 		}
-	} else {
-		childInfo = parentInfo
-		ce.PropertyTable().Add(key, childInfo)
 	}
-}
+	for _, iface := range interfaces {
+		inheritConstants(ce, iface)
+		iface.FunctionTable().Each(func(lcName string, fn *types.Function) {
+			ce.FunctionTable().Add(lcName, fn)
+		})
+	}
 
-func doInheritanceEx(ctx *Context, ce *types.Class, parentCe *types.Class, checked bool) {
-	ce.SetParent(parentCe)
-	ce.SetIsResolvedParent(true)
+	if !ce.HasFlags(types.AccInterface) && !ce.HasFlags(types.AccTrait) && !ce.IsExplicitAbstractClass() {
+		if !verifyAbstractClass(ctx, ce) {
+			return false
+		}
+	}
 
-	/* Inherit properties */
-	if true {
+	initPropertyOffsets(ce)
+	ce.SetIsLinked(true)
+	return true
+}
 
+// linkInterfaces 解析类实现(或接口继承)的接口，返回包括父类及父接口在内的全部接口
+func linkInterfaces(ctx *Context, ce *types.Class, parent *types.Class) ([]*types.Class, bool) {
+	var interfaces []*types.Class
+	add := func(iface *types.Class) {
+		for _, exists := range interfaces {
+			if exists == iface {
+				return
+			}
+		}
+		interfaces = append(interfaces, iface)
 	}
 
-	ce.PropertyTable().Each(func(key string, propInfo *types.PropertyInfo) {
-		if propInfo.Ce() == ce {
+	if parent != nil {
+		for _, iface := range parent.Interfaces() {
+			add(iface)
+		}
+	}
+	for _, name := range ce.InterfaceNames() {
+		iface := ZendFetchClassByName(ctx, name.Name(), "", 0)
+		if iface == nil {
+			return nil, false
+		}
+		if !iface.IsInterface() {
+			Error(ctx, perr.E_COMPILE_ERROR, fmt.Sprintf("%s cannot implement %s - it is not an interface", ce.Name(), iface.Name()))
+			return nil, false
+		}
+		if !DoLinkClass(ctx, iface) {
+			return nil, false
+		}
+		for _, sub := range iface.Interfaces() {
+			add(sub)
+		}
+		add(iface)
+	}
+	return interfaces, true
+}
 
+func inheritConstants(ce *types.Class, parent *types.Class) {
+	parent.ConstantTable().Each(func(lcName string, c *types.ClassConstant) {
+		if c.Flags()&types.AccPrivate == 0 {
+			ce.ConstantTable().Add(lcName, c)
 		}
 	})
 }
 
-func DoLinkClass(ctx *Context, ce *types.Class) {
-	if ce.IsLinked() {
-		return
-	}
+// inheritProperties 父类属性在前，子类重新声明的属性覆盖父类属性
+func inheritProperties(ce *types.Class, parent *types.Class) {
+	own := ce.PropertyTable().Clone()
+	ce.PropertyTable().Clean()
+	parent.PropertyTable().Each(func(key string, info *types.PropertyInfo) {
+		if childInfo := own.Get(key); childInfo != nil {
+			ce.PropertyTable().Add(key, childInfo)
+			return
+		}
+		// 复制一份，避免计算子类属性偏移时修改父类
+		inherited := *info
+		ce.PropertyTable().Add(key, &inherited)
+	})
+	own.Each(func(key string, info *types.PropertyInfo) {
+		ce.PropertyTable().Add(key, info)
+	})
+}
 
-	var parent *types.Class
-	if ce.ParentName() != "" {
-		parent = ZendFetchClassByName(ctx, ce.ParentName(), "", 0)
-		if parent == nil {
-			panic("parent is not found: " + ce.ParentName())
+func inheritMethods(ctx *Context, ce *types.Class, parent *types.Class) bool {
+	ok := true
+	parent.FunctionTable().Each(func(lcName string, parentFn *types.Function) {
+		if !ok {
+			return
 		}
-		ce.SetParent(parent)
+		childFn := ce.FunctionTable().Get(lcName)
+		if childFn == nil {
+			ce.FunctionTable().Add(lcName, parentFn)
+			setMagicMethod(ce, lcName, parentFn)
+			return
+		}
+		if parentFn.IsPrivate() {
+			return
+		}
+		if parentFn.IsFinal() {
+			Error(ctx, perr.E_COMPILE_ERROR, fmt.Sprintf("Cannot override final method %s::%s()", parentFn.Scope().Name(), parentFn.Name()))
+			ok = false
+		} else if parentFn.IsStatic() && !childFn.IsStatic() {
+			Error(ctx, perr.E_COMPILE_ERROR, fmt.Sprintf("Cannot make static method %s::%s() non static in class %s", parentFn.Scope().Name(), parentFn.Name(), ce.Name()))
+			ok = false
+		} else if !parentFn.IsStatic() && childFn.IsStatic() {
+			Error(ctx, perr.E_COMPILE_ERROR, fmt.Sprintf("Cannot make non static method %s::%s() static in class %s", parentFn.Scope().Name(), parentFn.Name(), ce.Name()))
+			ok = false
+		}
+	})
+	return ok
+}
+
+// verifyAbstractClass 非抽象类不能含有未实现的抽象方法
+func verifyAbstractClass(ctx *Context, ce *types.Class) bool {
+	var names []string
+	ce.FunctionTable().Each(func(_ string, fn *types.Function) {
+		if fn.IsAbstract() {
+			names = append(names, fn.Scope().Name()+"::"+fn.Name())
+		}
+	})
+	if len(names) == 0 {
+		return true
 	}
 
-	if parent != nil {
-		parent.PropertyTable().Each(func(name string, parentPropInfo *types.PropertyInfo) {
-			childPropInfo := ce.PropertyTable().Get(name)
-			if childPropInfo == nil {
-				ce.PropertyTable().Add(name, parentPropInfo)
-			} else {
-				// todo 检验 prop 是否兼容
-			}
-		})
+	count := len(names)
+	if len(names) > 3 {
+		names = append(names[:3], "...")
 	}
+	suffix := ""
+	if count > 1 {
+		suffix = "s"
+	}
+	Error(ctx, perr.E_ERROR, fmt.Sprintf("Class %s contains %d abstract method%s and must therefore be declared abstract or implement the remaining methods (%s)", ce.Name(), count, suffix, strings.Join(names, ", ")))
+	return false
+}
 
-	// fix offset
+// initPropertyOffsets 计算属性偏移，并初始化静态属性。继承的静态属性与父类共享同一个值
+func initPropertyOffsets(ce *types.Class) {
 	var propOffset uint32 = 0
-	var staticOffset uint32 = 0
-	ce.PropertyTable().Each(func(s string, info *types.PropertyInfo) {
-		if info.IsStatic() {
-			info.SetOffset(staticOffset)
-			staticOffset++
-		} else {
+	var staticMembers []types.Zval
+	ce.PropertyTable().Each(func(key string, info *types.PropertyInfo) {
+		if !info.IsStatic() {
 			info.SetOffset(propOffset)
 			propOffset++
+			return
+		}
+
+		member := types.ZvalRef(types.NewReference(info.DefaultVal()))
+		if info.Ce() != ce && ce.Parent() != nil {
+			if parentInfo := ce.Parent().PropertyTable().Get(key); parentInfo != nil && parentInfo.IsStatic() {
+				member = ce.Parent().DefaultStaticMembersTable()[parentInfo.Offset()]
+			}
 		}
+		info.SetOffset(uint32(len(staticMembers)))
+		staticMembers = append(staticMembers, member)
 	})
+	ce.SetDefaultStaticMembersTable(staticMembers)
+}
+
+// InstanceofFunction ce 是否为 target 本身、子类或实现了接口 target
+func InstanceofFunction(ce *types.Class, target *types.Class) bool {
+	for c := ce; c != nil; c = c.Parent() {
+		if c == target {
+			return true
+		}
+	}
+	if target.IsInterface() {
+		for _, iface := range ce.Interfaces() {
+			if iface == target {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// StaticPropertyVariable 查找可访问的静态属性。不存在或不可访问时报错并返回 nil
+func StaticPropertyVariable(ctx *Context, ce *types.Class, name string) Variable {
+	if !DoLinkClass(ctx, ce) {
+		return nil
+	}
+
+	info := ce.PropertyTable().Get(ascii.StrToLower(name))
+	if info == nil || !info.IsStatic() {
+		ThrowError(ctx, nil, fmt.Sprintf("Access to undeclared static property %s::$%s", ce.Name(), name))
+		return nil
+	}
+	if !PropertyAccessible(ctx, info) {
+		throwBadPropertyAccess(ctx, info, ce, name)
+		return nil
+	}
+	return &staticPropertyVariable{members: ce.DefaultStaticMembersTable(), offset: info.Offset()}
 }
@@ -0,0 +1,90 @@
+package php
+
+import (
+	"fmt"
+	"github.com/heyuuu/gophp/kits/ascii"
+	"github.com/heyuuu/gophp/php/types"
+)
+
+const (
+	TraversableClassName       = "Traversable"
+	IteratorClassName          = "Iterator"
+	IteratorAggregateClassName = "IteratorAggregate"
+)
+
+// RegisterIteratorInterfaces 注册 Traversable、Iterator 及 IteratorAggregate 接口，需在 Generator 类之前注册
+func RegisterIteratorInterfaces(ctx *Context, moduleNumber int) {
+	RegisterInternalInterface(ctx, moduleNumber, &types.InternalClassDecl{
+		Name: TraversableClassName,
+	})
+	interfaces := []struct {
+		name    string
+		methods []string
+	}{
+		{IteratorClassName, []string{"current", "next", "key", "valid", "rewind"}},
+		{IteratorAggregateClassName, []string{"getIterator"}},
+	}
+	for _, i := range interfaces {
+		ce := RegisterInternalInterface(ctx, moduleNumber, &types.InternalClassDecl{
+			Name:       i.name,
+			Interfaces: []string{TraversableClassName},
+		})
+		for _, name := range i.methods {
+			DeclMethod(ce, abstractMethod(i.name, name, moduleNumber))
+		}
+		DoLinkClass(ctx, ce)
+	}
+}
+
+func abstractMethod(className string, name string, moduleNumber int) *types.Function {
+	fn := types.NewInternalFunction(name, ZifHandler(func(ex *ExecuteData, returnValue *types.Zval) {
+		ThrowError(ex.Ctx(), nil, fmt.Sprintf("Cannot call abstract method %s::%s()", className, name))
+	}), moduleNumber)
+	fn.AddFnFlags(types.AccPublic | types.AccAbstract)
+	return fn
+}
+
+// instanceofInterface 对象是否实现了名为 name 的内置接口
+func instanceofInterface(ctx *Context, obj *types.Object, name string) bool {
+	ce := ctx.EG().ClassTable().Get(ascii.StrToLower(name))
+	return ce != nil && InstanceofFunction(obj.Class(), ce)
+}
+
+// iteratorOf 取得对象用于遍历的 Iterator，IteratorAggregate 依次调用 getIterator() 直到得到 Iterator。
+// 对象不可遍历时 traversable 为 false；getIterator() 出错时返回 nil
+func iteratorOf(ctx *Context, obj *types.Object) (it *types.Object, traversable bool) {
+	for !instanceofInterface(ctx, obj, IteratorClassName) {
+		if !instanceofInterface(ctx, obj, IteratorAggregateClassName) {
+			return nil, false
+		}
+		it := callIteratorMethod(ctx, obj, "getIterator").DeRef()
+		if !it.IsObject() || !instanceofInterface(ctx, it.Object(), TraversableClassName) {
+			ThrowException(ctx, nil, fmt.Sprintf("Objects returned by %s::getIterator() must be traversable or implement interface Iterator", obj.Class().Name()), 0)
+			return nil, true
+		}
+		obj = it.Object()
+	}
+	return obj, true
+}
+
+func callIteratorMethod(ctx *Context, obj *types.Object, name string) types.Zval {
+	fn := obj.Class().FunctionTable().Get(ascii.StrToLower(name))
+	return CallMethod(ctx, obj, obj.Class(), fn, nil)
+}
+
+// IterateObject 通过 Iterator 接口的方法遍历对象，依次以键值调用 f，f 返回 false 时停止遍历。
+// 对象未实现 Traversable 时返回 false
+func IterateObject(ctx *Context, obj *types.Object, f func(key types.Zval, value types.Zval) bool) bool {
+	it, traversable := iteratorOf(ctx, obj)
+	if it == nil {
+		return traversable
+	}
+	for callIteratorMethod(ctx, it, "rewind"); ZvalIsTrue(ctx, callIteratorMethod(ctx, it, "valid")); callIteratorMethod(ctx, it, "next") {
+		value := callIteratorMethod(ctx, it, "current").DeRef()
+		key := callIteratorMethod(ctx, it, "key").DeRef()
+		if !f(key, value) {
+			break
+		}
+	}
+	return true
+}
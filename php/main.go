@@ -91,6 +91,12 @@ var MainIniEntries = []*IniEntryDef{
 	//*php.NewIniEntryDef("realpath_cache_ttl", IniSystem).Value("120").OnModifyLong(func(i int) { php.CWDG__().SetRealpathCacheTtl(i) }),
 	NewIniEntryDef("user_ini.filename", IniSystem).Value(".user.ini").OnModifyString(func(ctx *Context, s string) { ctx.PG().SetUserIniFilename(s) }),
 	NewIniEntryDef("user_ini.cache_ttl", IniSystem).Value("300").OnModifyLong(func(ctx *Context, i int) { ctx.PG().SetUserIniCacheTtl(i) }),
+	NewIniEntryDef("ast.file_cache", IniSystem).Value("").OnModifyString(func(ctx *Context, s string) { ctx.PG().SetAstFileCache(s) }),
+	NewIniEntryDef("opcache.enable", IniSystem).Value("1").OnModifyBool(func(ctx *Context, b bool) { ctx.PG().SetOpcacheEnable(b) }),
+	NewIniEntryDef("opcache.enable_cli", IniSystem).Value("0").OnModifyBool(func(ctx *Context, b bool) { ctx.PG().SetOpcacheEnableCli(b) }),
+	NewIniEntryDef("opcache.max_accelerated_files", IniSystem).Value("10000").OnModifyLong(func(ctx *Context, i int) { ctx.PG().SetOpcacheMaxAcceleratedFiles(i) }),
+	NewIniEntryDef("opcache.validate_timestamps", IniAll).Value("1").OnModifyBool(func(ctx *Context, b bool) { ctx.PG().SetOpcacheValidateTimestamps(b) }),
+	NewIniEntryDef("opcache.revalidate_freq", IniAll).Value("2").OnModifyLong(func(ctx *Context, i int) { ctx.PG().SetOpcacheRevalidateFreq(i) }),
 	//NewIniEntryDef("hard_timeout", IniSystem).Value("2").OnModifyLong(func(ctx *Context, i int) { ctx.EG().SetHardTimeout(i) }),
 	NewIniEntryDef("syslog.facility", IniSystem).Value("LOG_USER").OnModify(onSetFacility),
 	NewIniEntryDef("syslog.ident", IniSystem).Value("php").OnModifyString(func(ctx *Context, s string) { ctx.PG().SetSyslogIdent(s) }),
@@ -9,7 +9,8 @@ import (
 func StdObjectInitDirect(ctx *Context, classType *types.Class) *types.Object {
 	handle := ctx.EG().NextObjectHandle()
 	intern := NewStdInternObject(ctx, classType)
-	return types.NewObject(handle, intern)
+	// 属性默认值已由 NewStdInternObject 初始化，无需经过 WriteProperty 的可访问性检查
+	return types.NewObjectSkipPropertiesInit(handle, intern)
 }
 
 func IsStdGetProperties(o *types.Object) bool {
@@ -26,7 +27,7 @@ func IsStdObject(o *types.Object) bool {
 // 常规对象初始化入口
 
 func ObjectInit(ctx *Context, classType *types.Class) *types.Object {
-	if classType.HasFlags(types.AccInterface | types.AccTrait | types.AccImplicitAbstractClass | types.AccExplicitAbstractClass) {
+	if classType.Flags()&(types.AccInterface|types.AccTrait|types.AccImplicitAbstractClass|types.AccExplicitAbstractClass) != 0 {
 		if classType.IsInterface() {
 			ThrowError(ctx, nil, fmt.Sprintf("Cannot instantiate interface %s", classType.Name()))
 		} else if classType.IsTrait() {
@@ -36,17 +37,22 @@ func ObjectInit(ctx *Context, classType *types.Class) *types.Object {
 		}
 		return nil
 	}
-	if !classType.IsLinked() {
-		DoLinkClass(ctx, classType)
+	if !DoLinkClass(ctx, classType) {
+		return nil
 	}
 	if !classType.IsConstantsUpdated() {
 
 	}
+	var obj *types.Object
 	if classType.GetCreateObject() == nil {
-		return StdObjectInitDirect(ctx, classType)
+		obj = StdObjectInitDirect(ctx, classType)
 	} else {
-		return classType.GetCreateObject()(classType)
+		obj = classType.GetCreateObject()(classType)
+	}
+	if obj != nil && IsThrowable(ctx, obj) {
+		initThrowableObject(ctx, obj)
 	}
+	return obj
 }
 
 func ObjectInitZval(ctx *Context, arg *types.Zval, classType *types.Class) bool {
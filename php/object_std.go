@@ -26,6 +26,11 @@ func NewStdInternObject(ctx *Context, ce *types.Class) *StdInternObject {
 		propertiesTable: make([]types.Zval, ce.PropertyTable().Len()),
 		properties:      make(map[string]types.Zval),
 	}
+	ce.PropertyTable().Each(func(_ string, info *types.PropertyInfo) {
+		if !info.IsStatic() {
+			o.propertiesTable[info.Offset()] = info.DefaultVal()
+		}
+	})
 	return o
 }
 
@@ -47,10 +52,15 @@ func (o *StdInternObject) Clone() *types.Object {
 func (o *StdInternObject) getPropertyInfo(member string, silent bool) (*types.PropertyInfo, bool) {
 	var propInfo *types.PropertyInfo
 	lcMember := ascii.StrToLower(member)
-	if propInfo = o.class.PropertyTable().Get(lcMember); propInfo == nil {
+	if propInfo = o.class.PropertyTable().Get(lcMember); propInfo == nil || propInfo.IsStatic() {
 		return nil, true
 	}
-	// todo 可访问性检查
+	if !PropertyAccessible(o.ctx, propInfo) {
+		if !silent {
+			throwBadPropertyAccess(o.ctx, propInfo, o.class, member)
+		}
+		return nil, false
+	}
 	return propInfo, true
 }
 
@@ -95,6 +105,9 @@ func (o *StdInternObject) WriteProperty(member types.Zval, value types.Zval) {
 	}
 
 	propInfo, ok := o.getPropertyInfo(name, false)
+	if !ok {
+		return
+	}
 	if propInfo != nil {
 		o.propertiesTable[propInfo.Offset()] = value
 	} else {
@@ -104,12 +117,23 @@ func (o *StdInternObject) WriteProperty(member types.Zval, value types.Zval) {
 
 func (o *StdInternObject) HasProperty(member types.Zval, hasSetExists int) bool {
 	name := ZvalGetStrVal(o.ctx, member)
+	if propInfo, ok := o.getPropertyInfo(name, true); !ok {
+		return false
+	} else if propInfo != nil {
+		return o.propertiesTable[propInfo.Offset()].IsNotUndef()
+	}
 	_, exists := o.properties[name]
 	return exists
 }
 
 func (o *StdInternObject) UnsetProperty(member types.Zval) {
 	name := ZvalGetStrVal(o.ctx, member)
+	if propInfo, ok := o.getPropertyInfo(name, false); !ok {
+		return
+	} else if propInfo != nil {
+		o.propertiesTable[propInfo.Offset()] = types.Undef
+		return
+	}
 	delete(o.properties, name)
 }
 
@@ -121,7 +145,9 @@ func (o *StdInternObject) GetPropertyPtr(member types.Zval, typ int) *types.Zval
 func (o *StdInternObject) GetPropertiesArray() *types.Array {
 	arr := types.NewArrayCap(len(o.properties))
 	o.class.PropertyTable().Each(func(_ string, info *types.PropertyInfo) {
-		arr.KeyAdd(info.Name(), o.propertiesTable[info.Offset()])
+		if !info.IsStatic() && o.propertiesTable[info.Offset()].IsNotUndef() {
+			arr.KeyAdd(info.Name(), o.propertiesTable[info.Offset()])
+		}
 	})
 	for key, value := range o.properties {
 		arr.KeyAdd(key, value)
@@ -731,7 +731,7 @@ func OpMod(ctx *Context, op1, op2 types.Zval) types.Zval {
 
 	if op2Lval == 0 {
 		/* modulus by zero */
-		opThrowException(ctx, nil, "Modulo by zero")
+		opThrowException(ctx, ThrowableClass(ctx, DivisionByZeroErrorClassName), "Modulo by zero")
 	}
 
 	return Long(op1Lval % op2Lval)
@@ -757,7 +757,7 @@ func OpSL(ctx *Context, op1, op2 types.Zval) types.Zval {
 	}
 
 	if op2Lval < 0 {
-		opThrowException(ctx, nil, "Bit shift by negative number")
+		opThrowException(ctx, ThrowableClass(ctx, ArithmeticErrorClassName), "Bit shift by negative number")
 	}
 
 	return Long(op1Lval << op2Lval)
@@ -783,7 +783,7 @@ func OpSR(ctx *Context, op1, op2 types.Zval) types.Zval {
 	}
 
 	if op2Lval < 0 {
-		opThrowException(ctx, nil, "Bit shift by negative number")
+		opThrowException(ctx, ThrowableClass(ctx, ArithmeticErrorClassName), "Bit shift by negative number")
 	}
 
 	return Long(op1Lval >> op2Lval)
@@ -874,7 +874,7 @@ func OpPowLong(ctx *Context, i1, i2 int) types.Zval {
 				pow--
 				l1, _, overflow = OpMulLongVal(ctx, l1, l2)
 			} else {
-				i2 /= 2
+				pow /= 2
 				l2, _, overflow = OpMulLongVal(ctx, l2, l2)
 			}
 			if overflow {
@@ -1133,7 +1133,7 @@ func opThrowError(ctx *Context, exceptionCe *types.Class, message string) {
 	panic(perr.Unreachable())
 }
 func opThrowException(ctx *Context, exceptionCe *types.Class, message string) {
-	panic(perr.Todof("opThrowException"))
+	ThrowException(ctx, exceptionCe, message, 0)
 }
 func opObjectGetArray(ctx *Context, obj *types.Object) *types.Array {
 	panic(perr.Todof("opObjectGetArray"))
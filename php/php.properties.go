@@ -20,6 +20,9 @@ func (engine *Engine) Port() int {
 func (engine *Engine) BaseCtx() *Context {
 	return engine.baseCtx
 }
+func (engine *Engine) ScriptCache() *ScriptCache {
+	return engine.scriptCache
+}
 
 // properties for ExecutorGlobals
 func (eg *ExecutorGlobals) SymbolTable() ISymtable {
@@ -492,6 +495,42 @@ func (pg *PhpGlobals) UserIniCacheTtl() int {
 func (pg *PhpGlobals) SetUserIniCacheTtl(v int) {
 	pg.userIniCacheTtl = v
 }
+func (pg *PhpGlobals) AstFileCache() string {
+	return pg.astFileCache
+}
+func (pg *PhpGlobals) SetAstFileCache(v string) {
+	pg.astFileCache = v
+}
+func (pg *PhpGlobals) OpcacheEnable() bool {
+	return pg.opcacheEnable
+}
+func (pg *PhpGlobals) SetOpcacheEnable(v bool) {
+	pg.opcacheEnable = v
+}
+func (pg *PhpGlobals) OpcacheEnableCli() bool {
+	return pg.opcacheEnableCli
+}
+func (pg *PhpGlobals) SetOpcacheEnableCli(v bool) {
+	pg.opcacheEnableCli = v
+}
+func (pg *PhpGlobals) OpcacheValidateTimestamps() bool {
+	return pg.opcacheValidateTimestamps
+}
+func (pg *PhpGlobals) SetOpcacheValidateTimestamps(v bool) {
+	pg.opcacheValidateTimestamps = v
+}
+func (pg *PhpGlobals) OpcacheRevalidateFreq() int {
+	return pg.opcacheRevalidateFreq
+}
+func (pg *PhpGlobals) SetOpcacheRevalidateFreq(v int) {
+	pg.opcacheRevalidateFreq = v
+}
+func (pg *PhpGlobals) OpcacheMaxAcceleratedFiles() int {
+	return pg.opcacheMaxAcceleratedFiles
+}
+func (pg *PhpGlobals) SetOpcacheMaxAcceleratedFiles(v int) {
+	pg.opcacheMaxAcceleratedFiles = v
+}
 func (pg *PhpGlobals) RequestOrder() string {
 	return pg.requestOrder
 }
@@ -73,6 +73,7 @@ type PhpGlobals struct {
 	inUserInclude           bool       `prop:""`
 	userIniFilename         string     `prop:""`
 	userIniCacheTtl         int        `prop:""`
+	astFileCache            string     `prop:""` // ast 缓存目录，为空时不启用缓存
 	requestOrder            string     `prop:""`
 	mailXHeader             bool       `prop:""`
 	mailLog                 string     `prop:""`
@@ -82,6 +83,13 @@ type PhpGlobals struct {
 	haveCalledOpenlog       bool       `prop:""`
 	syslogFilter            int        `prop:""`
 
+	// 引擎级已编译脚本缓存(opcache)配置
+	opcacheEnable              bool `prop:""`
+	opcacheEnableCli           bool `prop:""` // 命令行执行时是否启用
+	opcacheValidateTimestamps  bool `prop:""` // 是否校验文件修改时间
+	opcacheRevalidateFreq      int  `prop:""` // 校验间隔(秒)
+	opcacheMaxAcceleratedFiles int  `prop:""` // 最多缓存的脚本数，超出时淘汰最久未使用的脚本
+
 	// 迁移自 SG(sapi_globals_struct) 的配置项
 	defaultMimetype string `prop:""` // config
 	defaultCharset  string `prop:""` // config
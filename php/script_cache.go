@@ -0,0 +1,211 @@
+package php
+
+import (
+	"github.com/heyuuu/gophp/php/types"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ScriptCache 引擎级的已编译脚本缓存(类似 opcache)，按文件路径保存编译后的顶层函数(含其中的函数、类声明)。
+// 可被多个 Context 并发使用
+type ScriptCache struct {
+	mu        sync.RWMutex
+	scripts   map[string]*cachedScript
+	hits      atomic.Int64
+	misses    atomic.Int64
+	startTime time.Time
+	resetTime time.Time
+}
+
+type cachedScript struct {
+	path        string
+	fn          *types.Function
+	skipShebang bool
+	mtime       time.Time
+	size        int64
+	cachedAt    time.Time
+	checkedAt   atomic.Int64 // 上次校验时间(UnixNano)
+	usedAt      atomic.Int64 // 上次命中时间(UnixNano)，未命中过时为缓存时间
+	hits        atomic.Int64
+}
+
+// CachedScriptStatus 单个缓存脚本的状态
+type CachedScriptStatus struct {
+	FullPath  string
+	Hits      int64
+	Size      int64
+	Timestamp time.Time // 文件修改时间
+	CachedAt  time.Time
+	LastUsed  time.Time // 上次命中时间，未命中过时为缓存时间
+}
+
+// ScriptCacheStatus 缓存整体状态，对应 opcache_get_status() 的统计信息
+type ScriptCacheStatus struct {
+	NumCachedScripts int
+	Hits             int64
+	Misses           int64
+	StartTime        time.Time
+	LastRestartTime  time.Time
+	Scripts          []CachedScriptStatus
+}
+
+// HitRate 命中率，取值 0~100
+func (s ScriptCacheStatus) HitRate() float64 {
+	total := s.Hits + s.Misses
+	if total == 0 {
+		return 0
+	}
+	return float64(s.Hits) * 100 / float64(total)
+}
+
+func NewScriptCache() *ScriptCache {
+	return &ScriptCache{
+		scripts:   map[string]*cachedScript{},
+		startTime: time.Now(),
+	}
+}
+
+// Lookup 查找路径对应的缓存。
+// validate 为 true 时，距上次校验超过 revalidateFreq 则重新比对文件修改时间及大小，文件已变更时淘汰缓存
+func (c *ScriptCache) Lookup(path string, skipShebang bool, validate bool, revalidateFreq time.Duration) (*types.Function, bool) {
+	c.mu.RLock()
+	script := c.scripts[path]
+	c.mu.RUnlock()
+
+	if script == nil || script.skipShebang != skipShebang {
+		c.misses.Add(1)
+		return nil, false
+	}
+
+	if validate {
+		now := time.Now()
+		if now.Sub(time.Unix(0, script.checkedAt.Load())) >= revalidateFreq {
+			info, err := os.Stat(path)
+			if err != nil || !info.ModTime().Equal(script.mtime) || info.Size() != script.size {
+				c.evict(script)
+				c.misses.Add(1)
+				return nil, false
+			}
+			script.checkedAt.Store(now.UnixNano())
+		}
+	}
+
+	script.usedAt.Store(time.Now().UnixNano())
+	script.hits.Add(1)
+	c.hits.Add(1)
+	return script.fn, true
+}
+
+// Store 保存编译结果，info 为编译时读取的文件信息。
+// maxEntries 大于 0 时为最多缓存的脚本数，缓存已满时先淘汰最久未使用的脚本
+func (c *ScriptCache) Store(path string, skipShebang bool, fn *types.Function, info os.FileInfo, maxEntries int) {
+	now := time.Now()
+	script := &cachedScript{
+		path:        path,
+		fn:          fn,
+		skipShebang: skipShebang,
+		mtime:       info.ModTime(),
+		size:        info.Size(),
+		cachedAt:    now,
+	}
+	script.checkedAt.Store(now.UnixNano())
+	script.usedAt.Store(now.UnixNano())
+
+	c.mu.Lock()
+	if _, ok := c.scripts[path]; !ok && maxEntries > 0 {
+		for len(c.scripts) >= maxEntries {
+			c.evictLeastRecentlyUsed()
+		}
+	}
+	c.scripts[path] = script
+	c.mu.Unlock()
+}
+
+// evictLeastRecentlyUsed 淘汰最久未命中的脚本，调用方需持有写锁
+func (c *ScriptCache) evictLeastRecentlyUsed() {
+	var oldest *cachedScript
+	for _, script := range c.scripts {
+		if oldest == nil || script.usedAt.Load() < oldest.usedAt.Load() {
+			oldest = script
+		}
+	}
+	delete(c.scripts, oldest.path)
+}
+
+// Invalidate 淘汰指定路径的缓存，返回缓存是否存在
+func (c *ScriptCache) Invalidate(path string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.scripts[path]; !ok {
+		return false
+	}
+	delete(c.scripts, path)
+	return true
+}
+
+// evict 仅当缓存未被其他 Context 替换时淘汰
+func (c *ScriptCache) evict(script *cachedScript) {
+	c.mu.Lock()
+	if c.scripts[script.path] == script {
+		delete(c.scripts, script.path)
+	}
+	c.mu.Unlock()
+}
+
+// Reset 清空缓存及统计信息
+func (c *ScriptCache) Reset() {
+	c.mu.Lock()
+	c.scripts = map[string]*cachedScript{}
+	c.resetTime = time.Now()
+	c.mu.Unlock()
+	c.hits.Store(0)
+	c.misses.Store(0)
+}
+
+// Status 返回缓存状态，includeScripts 为 true 时包含每个脚本的信息(按路径排序)
+func (c *ScriptCache) Status(includeScripts bool) ScriptCacheStatus {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	status := ScriptCacheStatus{
+		NumCachedScripts: len(c.scripts),
+		Hits:             c.hits.Load(),
+		Misses:           c.misses.Load(),
+		StartTime:        c.startTime,
+		LastRestartTime:  c.resetTime,
+	}
+	if includeScripts {
+		status.Scripts = make([]CachedScriptStatus, 0, len(c.scripts))
+		for _, script := range c.scripts {
+			status.Scripts = append(status.Scripts, CachedScriptStatus{
+				FullPath:  script.path,
+				Hits:      script.hits.Load(),
+				Size:      script.size,
+				Timestamp: script.mtime,
+				CachedAt:  script.cachedAt,
+				LastUsed:  time.Unix(0, script.usedAt.Load()),
+			})
+		}
+		sort.Slice(status.Scripts, func(i, j int) bool {
+			return status.Scripts[i].FullPath < status.Scripts[j].FullPath
+		})
+	}
+	return status
+}
+
+// scriptCachePath 返回文件句柄在缓存中使用的绝对路径，非真实文件(如命令行代码)返回 false
+func scriptCachePath(fileHandle *FileHandle) (string, os.FileInfo, bool) {
+	info, ok := fileHandle.stat()
+	if !ok || !info.Mode().IsRegular() {
+		return "", nil, false
+	}
+	path, err := filepath.Abs(fileHandle.OpenedPath())
+	if err != nil {
+		return "", nil, false
+	}
+	return path, info, true
+}
@@ -0,0 +1,104 @@
+package php
+
+import (
+	"github.com/heyuuu/gophp/php/types"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestScriptCache(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "a.php")
+	if err := os.WriteFile(path, []byte("<?php echo 1;"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	info, _ := os.Stat(path)
+
+	cache := NewScriptCache()
+	fn := types.NewAstFunction("", nil, nil)
+	if _, ok := cache.Lookup(path, false, true, 0); ok {
+		t.Fatal("Lookup() hit on empty cache")
+	}
+	cache.Store(path, false, fn, info, 0)
+	if got, ok := cache.Lookup(path, false, true, 0); !ok || got != fn {
+		t.Errorf("Lookup() = %v, %v, want stored function", got, ok)
+	}
+	if _, ok := cache.Lookup(path, true, true, 0); ok {
+		t.Error("Lookup() hit with different skipShebang")
+	}
+
+	// 修改文件后，未到校验间隔时仍命中，到达间隔后失效
+	mtime := info.ModTime().Add(time.Second)
+	if err := os.WriteFile(path, []byte("<?php echo 2;"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	_ = os.Chtimes(path, mtime, mtime)
+	if _, ok := cache.Lookup(path, false, true, time.Hour); !ok {
+		t.Error("Lookup() miss before revalidate_freq elapsed")
+	}
+	if _, ok := cache.Lookup(path, false, false, 0); !ok {
+		t.Error("Lookup() miss with validate_timestamps disabled")
+	}
+	if _, ok := cache.Lookup(path, false, true, 0); ok {
+		t.Error("Lookup() hit after file modified")
+	}
+
+	status := cache.Status(true)
+	if status.NumCachedScripts != 0 || status.Hits != 3 || status.Misses != 3 || len(status.Scripts) != 0 {
+		t.Errorf("Status() = %+v", status)
+	}
+}
+
+func TestScriptCacheLastUsed(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "a.php")
+	if err := os.WriteFile(path, []byte("<?php echo 1;"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	info, _ := os.Stat(path)
+
+	cache := NewScriptCache()
+	cache.Store(path, false, types.NewAstFunction("", nil, nil), info, 0)
+	if script := cache.Status(true).Scripts[0]; !script.LastUsed.Equal(script.CachedAt) {
+		t.Errorf("LastUsed = %v before any hit, want CachedAt %v", script.LastUsed, script.CachedAt)
+	}
+
+	time.Sleep(10 * time.Millisecond)
+	before := time.Now()
+	cache.Lookup(path, false, false, 0)
+	if script := cache.Status(true).Scripts[0]; script.LastUsed.Before(before) {
+		t.Errorf("LastUsed = %v, want the time of the last hit (after %v)", script.LastUsed, before)
+	}
+}
+
+func TestScriptCacheEviction(t *testing.T) {
+	dir := t.TempDir()
+	paths := make([]string, 3)
+	infos := make([]os.FileInfo, 3)
+	for i := range paths {
+		paths[i] = filepath.Join(dir, string(rune('a'+i))+".php")
+		if err := os.WriteFile(paths[i], []byte("<?php echo 1;"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		infos[i], _ = os.Stat(paths[i])
+	}
+
+	cache := NewScriptCache()
+	cache.Store(paths[0], false, types.NewAstFunction("", nil, nil), infos[0], 2)
+	time.Sleep(time.Millisecond)
+	cache.Store(paths[1], false, types.NewAstFunction("", nil, nil), infos[1], 2)
+	time.Sleep(time.Millisecond)
+	// 命中 a.php 后，b.php 成为最久未使用的脚本
+	cache.Lookup(paths[0], false, false, 0)
+	time.Sleep(time.Millisecond)
+	cache.Store(paths[2], false, types.NewAstFunction("", nil, nil), infos[2], 2)
+
+	if n := cache.Status(false).NumCachedScripts; n != 2 {
+		t.Errorf("NumCachedScripts = %d, want 2", n)
+	}
+	for i, want := range []bool{true, false, true} {
+		if _, ok := cache.Lookup(paths[i], false, false, 0); ok != want {
+			t.Errorf("Lookup(%s) hit = %v, want %v", filepath.Base(paths[i]), ok, want)
+		}
+	}
+}
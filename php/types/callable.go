@@ -1,10 +1,17 @@
 package types
 
+// UserCallable 已解析的回调，call 执行实际调用，失败(如抛出异常)时返回 false
 type UserCallable struct {
-	// todo
+	fn   *Function
+	call func(args []Zval) (Zval, bool)
 }
 
+func NewUserCallable(fn *Function, call func(args []Zval) (Zval, bool)) *UserCallable {
+	return &UserCallable{fn: fn, call: call}
+}
+
+func (u *UserCallable) Function() *Function { return u.fn }
+
 func (u *UserCallable) Call(args ...Zval) (Zval, bool) {
-	// todo
-	return Undef, true
+	return u.call(args)
 }
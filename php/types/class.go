@@ -40,14 +40,14 @@ type Class struct {
 
 	// 继承接口列表，在 link 前只有 interfaceNames 可能有值，在 link 后只有 interfaces 可能有值(union)。
 	interfaceNames []ClassName `get:""`
-	interfaces     []*Class    `get:""`
+	interfaces     []*Class    `prop:""`
 
 	// 默认属性表
 	defaultPropertiesCount int    `get:""`
 	defaultPropertiesTable []Zval `get:""`
 
 	defaultStaticMembersCount int    `get:""`
-	defaultStaticMembersTable []Zval `get:""`
+	defaultStaticMembersTable []Zval `prop:""`
 
 	functionTable *FunctionTable      `get:""`
 	propertyTable *PropertyInfoTable  `get:""`
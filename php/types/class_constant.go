@@ -5,7 +5,7 @@ type ClassConstant struct {
 	name       string `get:""`
 	value      Zval   `get:""`
 	docComment string `get:""`
-	ce         *Class `get:""`
+	ce         *Class `prop:""`
 	flags      uint32 `get:""`
 }
 
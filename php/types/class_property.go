@@ -6,9 +6,9 @@ type PropertyInfo struct {
 	flags      uint32    `get:""`
 	name       string    `get:""`
 	docComment string    `get:""`
-	ce         *Class    `get:""`
+	ce         *Class    `prop:""`
 	typ        *TypeHint `get:"Type"`
-	defaultVal Zval
+	defaultVal Zval      `get:""`
 }
 
 func NewPropertyInfo(flags uint32, name string, typ *TypeHint, defaultVal Zval) *PropertyInfo {
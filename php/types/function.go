@@ -25,7 +25,8 @@ type Function struct {
 	handler      any `get:""`
 
 	// fields for user function
-	stmts []ast.Stmt `get:""`
+	stmts           []ast.Stmt `get:""`
+	staticVariables *Array     `prop:""` // 闭包绑定的外部变量，调用时写入符号表
 
 	blockInfo
 }
@@ -58,6 +59,16 @@ func NewAstFunction(name string, argInfos []ArgInfo, stmts []ast.Stmt) *Function
 	}
 }
 
+// NewCompiledFunction 用户函数，函数体为转译后的 go 代码(见 php/def)，由 handler 执行
+func NewCompiledFunction(name string, argInfos []ArgInfo, handler any) *Function {
+	return &Function{
+		typ:      TypeUserFunction,
+		name:     name,
+		argInfos: argInfos,
+		handler:  handler,
+	}
+}
+
 func (f *Function) IsInternalFunction() bool { return f.typ == TypeInternalFunction }
 func (f *Function) IsUserFunction() bool     { return f.typ == TypeUserFunction }
 func (f *Function) IsEvalCode() bool         { return f.typ == TypeEvalCode }
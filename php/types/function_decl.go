@@ -1,18 +1,26 @@
 package types
 
 type FunctionDecl struct {
-	name     string
-	handler  any
-	argInfos []ArgInfo
+	name       string
+	handler    any
+	argInfos   []ArgInfo
+	minNumArgs int
+	maxNumArgs int
 }
 
-func DefFunc(name string, handler any, argInfos []ArgInfo) FunctionDecl {
+// DefFunc 内置函数声明，maxNumArgs 为 -1 时参数个数不限
+func DefFunc(name string, handler any, argInfos []ArgInfo, minNumArgs int, maxNumArgs int) FunctionDecl {
 	return FunctionDecl{
-		name:     name,
-		handler:  handler,
-		argInfos: argInfos,
+		name:       name,
+		handler:    handler,
+		argInfos:   argInfos,
+		minNumArgs: minNumArgs,
+		maxNumArgs: maxNumArgs,
 	}
 }
 
-func (f FunctionDecl) Name() string { return f.name }
-func (f FunctionDecl) Handler() any { return f.handler }
+func (f FunctionDecl) Name() string        { return f.name }
+func (f FunctionDecl) Handler() any        { return f.handler }
+func (f FunctionDecl) ArgInfos() []ArgInfo { return f.argInfos }
+func (f FunctionDecl) MinNumArgs() int     { return f.minNumArgs }
+func (f FunctionDecl) MaxNumArgs() int     { return f.maxNumArgs }
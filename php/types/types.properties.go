@@ -41,6 +41,9 @@ func (ce *Class) InterfaceNames() []ClassName {
 func (ce *Class) Interfaces() []*Class {
 	return ce.interfaces
 }
+func (ce *Class) SetInterfaces(v []*Class) {
+	ce.interfaces = v
+}
 func (ce *Class) DefaultPropertiesCount() int {
 	return ce.defaultPropertiesCount
 }
@@ -53,6 +56,9 @@ func (ce *Class) DefaultStaticMembersCount() int {
 func (ce *Class) DefaultStaticMembersTable() []Zval {
 	return ce.defaultStaticMembersTable
 }
+func (ce *Class) SetDefaultStaticMembersTable(v []Zval) {
+	ce.defaultStaticMembersTable = v
+}
 func (ce *Class) FunctionTable() *FunctionTable {
 	return ce.functionTable
 }
@@ -175,6 +181,9 @@ func (t *ClassConstant) DocComment() string {
 func (t *ClassConstant) Ce() *Class {
 	return t.ce
 }
+func (t *ClassConstant) SetCe(v *Class) {
+	t.ce = v
+}
 func (t *ClassConstant) Flags() uint32 {
 	return t.flags
 }
@@ -230,6 +239,12 @@ func (f *Function) Handler() any {
 func (f *Function) Stmts() []ast.Stmt {
 	return f.stmts
 }
+func (f *Function) StaticVariables() *Array {
+	return f.staticVariables
+}
+func (f *Function) SetStaticVariables(v *Array) {
+	f.staticVariables = v
+}
 
 // properties for PropertyInfo
 func (prop *PropertyInfo) Offset() uint32 {
@@ -250,9 +265,15 @@ func (prop *PropertyInfo) DocComment() string {
 func (prop *PropertyInfo) Ce() *Class {
 	return prop.ce
 }
+func (prop *PropertyInfo) SetCe(v *Class) {
+	prop.ce = v
+}
 func (prop *PropertyInfo) Type() *TypeHint {
 	return prop.typ
 }
+func (prop *PropertyInfo) DefaultVal() Zval {
+	return prop.defaultVal
+}
 
 // properties for Resource
 func (res *Resource) Handle() int {
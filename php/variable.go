@@ -12,6 +12,27 @@ type iVariable interface {
 	MakeRef() *types.Reference
 }
 
+// Variable 可读写的变量位置，供转译后代码的运行时(php/def)使用
+type Variable = iVariable
+
+func NewSymbolVariable(symbols ISymtable, name string) Variable {
+	return newSymbolVariable(symbols, name)
+}
+func NewArrayAppendVariable(ctx *Context, arr types.Zval) Variable {
+	return newArrayAppendVariable(ctx, arr)
+}
+func NewArrayDimVariable(ctx *Context, arr types.Zval, key types.ArrayKey) Variable {
+	return newArrayDimVariable(ctx, arr, key)
+}
+func NewPropertyVariable(obj *types.Object, member types.Zval) Variable {
+	return newPropertyVariable(obj, member)
+}
+
+// ArrayGet 读取数组(或字符串)下标，下标不存在时返回 Undef
+func ArrayGet(ctx *Context, arr types.Zval, key types.ArrayKey) types.Zval {
+	return ctx.executor.arrayGet(arr, key)
+}
+
 type symbolVariable struct {
 	symbols ISymtable
 	name    string
@@ -204,3 +225,27 @@ func (v propertyVariable) MakeRef() *types.Reference {
 		return ref
 	}
 }
+
+// staticPropertyVariable 静态属性，值总是保存为引用，继承的静态属性与父类共享
+type staticPropertyVariable struct {
+	members []types.Zval
+	offset  uint32
+}
+
+func (v *staticPropertyVariable) Get() types.Zval {
+	return v.members[v.offset]
+}
+
+func (v *staticPropertyVariable) Set(value types.Zval) {
+	if value.IsRef() {
+		v.members[v.offset] = value
+	} else {
+		v.members[v.offset].Ref().SetVal(value)
+	}
+}
+
+func (v *staticPropertyVariable) Unset() {}
+
+func (v *staticPropertyVariable) MakeRef() *types.Reference {
+	return v.members[v.offset].Ref()
+}
@@ -3,7 +3,6 @@ package php
 import (
 	"fmt"
 	"github.com/heyuuu/gophp/php/lang"
-	"github.com/heyuuu/gophp/php/perr"
 	"github.com/heyuuu/gophp/php/types"
 	"github.com/heyuuu/gophp/php/zpp"
 	"strings"
@@ -498,9 +497,18 @@ func (p *FastParamParser) parseResourceEx(checkNull bool, separate bool) (dest t
 	return
 }
 
+// @see Micro: Z_PARAM_FUNC，Old: 'f'
 func (p *FastParamParser) ParseCallable() *types.UserCallable {
-	//TODO implement me
-	panic(perr.Todof("ParseCallable"))
+	arg, ok := p.nextArg(true, false)
+	if !ok {
+		return nil
+	}
+
+	callable, errMsg := NewUserCallable(p.ctx, arg)
+	if callable == nil {
+		p.triggerError(ZPP_ERROR_WRONG_CALLBACK, errMsg)
+	}
+	return callable
 }
 
 // @see Micro: Z_PARAM_ZVAL，Old: 'z'
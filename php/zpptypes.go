@@ -183,3 +183,54 @@ func zppParseZvalDeref(arg *types.Zval, checkNull bool) (dest *types.Zval) {
 //	}
 //	return class, true
 //}
+
+// CoerceScalarArg 按标量类型声明 typeName(int、float、string 或 bool)转换参数或返回值，对应 zend_verify_scalar_type_hint。
+// strict 模式下仅允许 int 转为 float；null 只能传给可空类型，总是失败
+func CoerceScalarArg(ctx *Context, typeName string, arg types.Zval, strict bool) (types.Zval, bool) {
+	arg = arg.DeRef()
+	switch typeName {
+	case "int":
+		if arg.IsLong() {
+			return arg, true
+		}
+	case "float":
+		if arg.IsDouble() {
+			return arg, true
+		} else if arg.IsLong() {
+			return types.ZvalDouble(float64(arg.Long())), true
+		}
+	case "string":
+		if arg.IsString() {
+			return arg, true
+		}
+	case "bool":
+		if arg.IsBool() {
+			return arg, true
+		}
+	default:
+		return arg, true
+	}
+	if strict || arg.IsNull() {
+		return arg, false
+	}
+
+	switch typeName {
+	case "int":
+		if lval, ok := zppParseLongWeak(ctx, arg, false); ok {
+			return types.ZvalLong(lval), true
+		}
+	case "float":
+		if dval, ok := zppParseDoubleWeak(ctx, arg); ok {
+			return types.ZvalDouble(dval), true
+		}
+	case "string":
+		if str, ok := zppParseStrWeak(ctx, arg); ok {
+			return types.ZvalString(str), true
+		}
+	case "bool":
+		if b, ok := zppParseBoolWeak(ctx, arg); ok {
+			return types.ZvalBool(b), true
+		}
+	}
+	return arg, false
+}
@@ -19,6 +19,10 @@ func (c *Cmd) runCli(engine *php.Engine, optArgs *OptArgs) (err error) {
 		var skipShebang bool
 		if optArgs.mode == modeCliFile {
 			fileHandle, err = php.NewFileHandleByFilename(optArgs.ScriptFile)
+			if err != nil && php.HasCompiledScript(optArgs.ScriptFile) {
+				// 已转译的脚本可以不附带源码
+				fileHandle, err = php.NewFileHandleByString(optArgs.ScriptFile, ""), nil
+			}
 			if err != nil {
 				log.Panicln("Could not open input file: " + optArgs.ScriptFile)
 			}
@@ -32,6 +36,9 @@ func (c *Cmd) runCli(engine *php.Engine, optArgs *OptArgs) (err error) {
 		_, err = php.ExecuteScript(ctx, fileHandle, skipShebang)
 		if err != nil {
 			err = withCode(1, fmt.Errorf("Execute failed: %w", err))
+		} else if status := ctx.EG().ExitStatus(); status != 0 {
+			// 未捕获的异常等致命错误
+			err = withCode(status, fmt.Errorf("exit status %d", status))
 		}
 	})
 	return
@@ -0,0 +1,127 @@
+package sapi
+
+import (
+	"flag"
+	"fmt"
+	"github.com/heyuuu/gophp/compile/downlevel"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const downlevelUsage = `Usage: php downlevel [options] <path>
+
+  -target <version> Target PHP version, 7.0 or later (default "7.4")
+  -o <path>         Output file or directory. A file is written to stdout by default,
+                    a directory to "<path>_php<version>"
+`
+
+// runDownlevel 将文件或目录下的 PHP 文件降级为目标版本的语法。单个文件失败不影响其他文件，存在失败时返回错误码 1
+func (c *Cmd) runDownlevel(args []string) error {
+	stdout, stderr := c.Stdout, c.Stderr
+	if stdout == nil {
+		stdout = os.Stdout
+	}
+	if stderr == nil {
+		stderr = os.Stderr
+	}
+
+	flags := flag.NewFlagSet("downlevel", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	targetFlag := flags.String("target", "7.4", "")
+	out := flags.String("o", "", "")
+	err := flags.Parse(args)
+	var target downlevel.Version
+	if err == nil {
+		target, err = downlevel.ParseVersion(*targetFlag)
+	}
+	if err != nil || flags.NArg() != 1 {
+		if err != nil {
+			_, _ = fmt.Fprintln(stderr, err.Error())
+		}
+		_, _ = fmt.Fprint(stderr, downlevelUsage)
+		return errRunFail
+	}
+
+	src := flags.Arg(0)
+	info, err := os.Stat(src)
+	if err != nil {
+		_, _ = fmt.Fprintln(stderr, "Downlevel failed: "+err.Error())
+		return errRunFail
+	}
+
+	// 单个文件
+	if !info.IsDir() {
+		code, err := downlevelFile(src, target)
+		if err != nil {
+			_, _ = fmt.Fprintln(stderr, err.Error())
+			return errRunFail
+		}
+		if *out == "" {
+			_, err = io.WriteString(stdout, code)
+		} else {
+			err = os.WriteFile(*out, []byte(code), 0644)
+		}
+		return err
+	}
+
+	// 目录，按相对路径输出到目标目录
+	outDir := *out
+	if outDir == "" {
+		outDir = strings.TrimRight(src, `/\`) + "_php" + strings.ReplaceAll(target.String(), ".", "")
+	}
+	var files, failed int
+	err = filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if path != src && strings.HasPrefix(d.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if filepath.Ext(path) != ".php" {
+			return nil
+		}
+		files++
+		code, err := downlevelFile(path, target)
+		if err != nil {
+			failed++
+			_, _ = fmt.Fprintln(stderr, err.Error())
+			return nil
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		outPath := filepath.Join(outDir, rel)
+		if err = os.MkdirAll(filepath.Dir(outPath), 0755); err != nil {
+			return err
+		}
+		return os.WriteFile(outPath, []byte(code), 0644)
+	})
+	if err != nil {
+		_, _ = fmt.Fprintln(stderr, "Downlevel failed: "+err.Error())
+		return errRunFail
+	}
+	_, _ = fmt.Fprintf(stdout, "Downleveled %d files to PHP %s in %s, %d failed\n", files-failed, target, outDir, failed)
+	if failed > 0 {
+		return errRunFail
+	}
+	return nil
+}
+
+func downlevelFile(path string, target downlevel.Version) (string, error) {
+	code, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	result, err := downlevel.Code(string(code), target)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", path, err)
+	}
+	return result, nil
+}
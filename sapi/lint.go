@@ -0,0 +1,65 @@
+package sapi
+
+import (
+	"flag"
+	"fmt"
+	"github.com/heyuuu/gophp/compile/lint"
+	"github.com/heyuuu/gophp/php"
+	"io"
+	"os"
+)
+
+const lintUsage = `Usage: php lint [options] <path>...
+
+  -format <format> Output format: text or json (default "text")
+`
+
+// runLint 静态检查文件或目录下的 PHP 文件，存在诊断时返回错误码 1
+func (c *Cmd) runLint(args []string) error {
+	stdout, stderr := c.Stdout, c.Stderr
+	if stdout == nil {
+		stdout = os.Stdout
+	}
+	if stderr == nil {
+		stderr = os.Stderr
+	}
+
+	flags := flag.NewFlagSet("lint", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	format := flags.String("format", "text", "")
+	err := flags.Parse(args)
+	if err == nil && *format != "text" && *format != "json" {
+		err = fmt.Errorf("unknown output format: %s", *format)
+	}
+	if err != nil || flags.NArg() == 0 {
+		if err != nil {
+			_, _ = fmt.Fprintln(stderr, err.Error())
+		}
+		_, _ = fmt.Fprint(stderr, lintUsage)
+		return errRunFail
+	}
+
+	sources, err := lint.LoadPaths(flags.Args())
+	if err != nil {
+		_, _ = fmt.Fprintln(stderr, "Lint failed: "+err.Error())
+		return errRunFail
+	}
+
+	// 内置函数来自已注册的模块，不需要启动 engine
+	cfg := lint.Config{Funcs: lint.EngineFuncs(php.NewEngine())}
+	results := lint.Check(sources, cfg)
+	if *format == "json" {
+		err = lint.WriteJSON(stdout, results)
+	} else {
+		err = lint.WriteText(stdout, results)
+	}
+	if err != nil {
+		return err
+	}
+	for _, result := range results {
+		if len(result.Diagnostics) > 0 {
+			return errRunFail
+		}
+	}
+	return nil
+}
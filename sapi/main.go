@@ -17,6 +17,9 @@ const usage = `Usage: php [options] [-f] <file> [--] [args...]
    php [options] -r <code> [--] [args...]
    php [options] -S <addr>:<port> [-t docroot] [router]
    php [options] -- [args...]
+   php transpile [-o <dir>] [-p <package>] [-no-opt <passes>] [-no-check] <srcdir>
+   php lint [-format text|json] <path>...
+   php downlevel [-target <version>] [-o <path>] <path>
 
   -c <path>|<file> Look for php.ini file in this directory
   -n               No configuration (ini) files will be used
@@ -35,6 +38,10 @@ const usage = `Usage: php [options] [-f] <file> [--] [args...]
                    starts with - or script is read from stdin
 
   --ini            Show configuration file names
+
+  transpile        Transpile PHP files in <srcdir> into a Go package
+  lint             Report undefined variables, unreachable code and other issues in <path>
+  downlevel        Rewrite PHP files in <path> into syntax supported by an older PHP version
 `
 
 var Options = []php.Opt{
@@ -223,6 +230,19 @@ func (c *Cmd) RunSafe() (err error) {
 }
 
 func (c *Cmd) Run() error {
+	// 转译模式，不需要启动 engine
+	if len(c.Args) > 0 && c.Args[0] == "transpile" {
+		return c.runTranspile(c.Args[1:])
+	}
+	// 静态检查模式
+	if len(c.Args) > 0 && c.Args[0] == "lint" {
+		return c.runLint(c.Args[1:])
+	}
+	// 降级模式
+	if len(c.Args) > 0 && c.Args[0] == "downlevel" {
+		return c.runDownlevel(c.Args[1:])
+	}
+
 	optArgs, err := parseArgs(c.Args)
 	if err != nil {
 		fmt.Println(err.Error())
@@ -232,16 +252,17 @@ func (c *Cmd) Run() error {
 
 	// prepare engine
 	engine := php.NewEngine()
-	err = engine.Start()
-	if err != nil {
-		return err
-	}
 
-	// ini
+	// ini，需要在 engine 启动前添加，启动时会被加载
 	for _, ini := range optArgs.IniAppend {
 		engine.BaseCtx().INI().AppendIniEntries(ini)
 	}
 
+	err = engine.Start()
+	if err != nil {
+		return err
+	}
+
 	// switch mode
 	switch optArgs.mode {
 	case modeVersion:
@@ -1,6 +1,7 @@
 package sapi
 
 import (
+	"bytes"
 	"reflect"
 	"testing"
 )
@@ -53,3 +54,167 @@ func TestRunOpt(t *testing.T) {
 		})
 	}
 }
+
+func TestRunOutput(t *testing.T) {
+	tests := []struct {
+		name string
+		code string
+		want string
+	}{
+		{"__LINE__", "echo __LINE__;\n\necho __LINE__;", "13"},
+		{"warning line", "function f() {\n  str_repeat('a', -1);\n}\nf();", "\nWarning: str_repeat(): Second argument has to be greater than or equal to 0 in Command line code on line 2\n"},
+		{"echo order", `function f($s) { echo "[$s]"; return $s; }
+echo "a", f("b"), "c", f("d");`, "a[b]bc[d]d"},
+		{"namespace fallback", `namespace Foo;
+const X = "nsX"; const PHP_EOL = "nsEOL";
+function bar() { return "bar"; } function strlen($s) { return "ns"; }
+echo bar(), \Foo\bar(), namespace\bar(), strlen("ab"), \strlen("ab"), strtoupper("a"), ",";
+echo X, PHP_EOL, \PHP_EOL === "\n" ? "global" : "", E_ALL, ",";
+try { nope(); } catch (\Error $e) { echo $e->getMessage(); }`, "barbarbarns2A,nsXnsEOLglobal32767,Call to undefined function Foo\\nope()"},
+		{"match", `function f($x) { return match ($x) { 1, 2 => "small", "a" => "letter", default => "other" }; }
+echo f(1), f("2"), f("a"), ",";
+try { echo match (5) { 1 => 'x' }; } catch (UnhandledMatchError $e) { echo $e->getMessage(), ","; }
+try { echo match ("it's") {}; } catch (Error $e) { echo $e->getMessage(), ","; }
+try { echo match (true) {}; } catch (Error $e) { echo $e->getMessage(); }`, "smallotherletter,Unhandled match case 5,Unhandled match case 'it\\'s',Unhandled match case of type bool"},
+		{"try catch", `class MyEx extends Exception {}
+function f($x) { if ($x) throw new MyEx("m$x", 3); return "ok"; }
+try { echo f(0); f(1); } catch (LogicException | MyEx $e) { echo get_class($e), $e->getMessage(), $e->getCode(), $e->getLine(); } finally { echo ",fin"; }`, "okMyExm132,fin"},
+		{"rethrow", `try { try { throw new Exception("a"); } catch (Exception $e) { throw $e; } finally { echo "f"; } } catch (Exception $e2) { echo $e === $e2 ? "same" : "diff"; }`, "fsame"},
+		{"finally overrides", `function f() { try { throw new Exception("x"); } finally { return "fin"; } }
+for ($i = 0; $i < 3; $i++) { try { if ($i == 1) break; } finally { echo $i; } }
+echo f();`, "01fin"},
+		{"previous", `try { try { throw new Exception("a"); } finally { throw new Exception("b"); } } catch (Exception $e) { echo $e->getMessage(), $e->getPrevious()->getMessage(); }
+$e = new Exception("c", 0, new Error("d")); echo $e->getPrevious()->getMessage();`, "bad"},
+		{"internal error", `try { echo 1 % 0; } catch (DivisionByZeroError $e) { echo get_class($e), ":", $e->getMessage(); }`, "DivisionByZeroError:Modulo by zero"},
+		{"hierarchy", `class MyEx extends InvalidArgumentException {}
+try { throw new MyEx("m", 2, new ErrorException("inner", 1, E_WARNING, "f.php", 7)); }
+catch (LogicException $e) { $p = $e->getPrevious(); echo get_class($p), $p->getSeverity(), $p->getFile(), $p->getLine(), $e instanceof Throwable ? "T" : "F"; }
+try { intdiv(PHP_INT_MIN, -1); } catch (ArithmeticError $e) { echo get_class($e); }`, "ErrorException2f.php7TArithmeticError"},
+		{"trace", `function f($x) { return intdiv($x, 0); }
+try { f(1); } catch (Error $e) { echo $e->getTraceAsString(), "|", count($e->getTrace()), "|", $e->getTrace()[1]["function"]; }`, "#0 Command line code(1): intdiv(1, 0)\n#1 Command line code(2): f(1)\n#2 {main}|2|f"},
+		{"to string", `class M extends Exception { function __toString() { return "custom"; } }
+echo new M("c"), "|", new Exception("");`, "custom|Exception in Command line code:2\nStack trace:\n#0 {main}"},
+		{"closure use", `$a = 1; $b = 2;
+$f = function ($x) use ($a, &$b) { $a++; $b++; return $x + $a + $b; };
+echo $f(10), $f(10), $a, $b, ",";
+$arr = [3, 1, 2]; usort($arr, function ($p, $q) { return $p <=> $q; }); echo implode($arr);`, "151614,123"},
+		{"arrow function", `$k = 2;
+$f = fn($x) => fn($y) => $x * $k + $y;
+echo $f(3)(1), implode(",", array_map(fn($v) => $v + $k, [1, 2]));`, "73,4"},
+		{"closure this", `class A { private $v = 42; function f() { return function () { return $this->v; }; } }
+$f = (new A)->f(); echo $f(), $f->__invoke(), ",";
+$peek = function ($d) { return $this->v + $d; };
+echo Closure::bind($peek, new A, "A")(1), $peek->bindTo(new A, new A)(2), $peek->call(new A, 3);`, "4242,434445"},
+		{"bind errors", `$s = static function () {}; var_dump($s->bindTo(new stdClass));
+var_dump(Closure::bind(function () {}, null, "Nope"));`, "\nWarning: Cannot bind an instance to a static closure in Command line code on line 1\nNULL\n\nWarning: Class 'Nope' not found in Command line code on line 2\nNULL\n"},
+		{"generator", `function gen($n) { for ($i = 1; $i <= $n; $i++) { $x = yield $i; if ($x) echo "[$x]"; } return "done"; }
+foreach (gen(2) as $k => $v) echo "$k=$v,";
+$g = gen(3); echo $g->current(), $g->send("a"), $g->key(); $g->next(); $g->next(); var_dump($g->valid()); echo $g->getReturn();
+function kv() { yield "a" => 1; yield 2; yield 10 => 3; yield 4; }
+foreach (kv() as $k => $v) echo ",$k=$v";`, "0=1,1=2,1[a]21bool(false)\ndone,a=1,0=2,10=3,11=4"},
+		{"yield from", `function inner() { yield 1; $x = yield 2; echo "[$x]"; return "r"; }
+function outer() { $r = yield from inner(); yield $r; yield from ["x" => 5, 6]; }
+foreach (outer() as $k => $v) echo "$k=$v,";
+$o = outer(); $o->next(); echo $o->send("s"), ",";
+$lazy = (function () { echo "start,"; yield 1; })(); echo "before,"; $lazy->current();`, "0=1,1=2,[]0=r,x=5,0=6,[s]r,before,start,"},
+		{"yield from traversable", `class It implements Iterator { private $i = 0; function current() { return $this->i * 10; } function key() { return "k$this->i"; } function next() { $this->i++; } function valid() { return $this->i < 2; } function rewind() { $this->i = 0; } }
+class Agg implements IteratorAggregate { function getIterator() { return new It; } }
+function g($x) { $r = yield from $x; var_dump($r); }
+foreach (g(new Agg) as $k => $v) echo "$k=$v,";
+try { foreach (g(new stdClass) as $v) {} } catch (Error $e) { echo $e->getMessage(); }`, "k0=0,k1=10,NULL\nCan use \"yield from\" only with arrays and Traversables"},
+		{"generator throw", `function t() { try { yield 1; } catch (Exception $e) { echo $e->getMessage(); yield 2; } finally { echo ",fin"; } }
+$t = t(); $t->current(); echo $t->throw(new Exception("boom")); $t->next();
+$g = t(); foreach ($g as $v) {}
+try { $g->rewind(); } catch (Exception $e) { echo ",", $e->getMessage(); }
+try { foreach ($g as $v) {} } catch (Exception $e) { echo ",", $e->getMessage(); }`, "boom2,fin,fin,Cannot rewind a generator that was already run,Cannot traverse an already closed generator"},
+		{"generator destroy", `function g() { try { yield 1; yield 2; } finally { echo "cleanup,"; } }
+foreach (g() as $v) break;
+echo "after,";
+$kept = g(); foreach ($kept as $v) break; echo $kept->current(), ",";
+function y() { try { yield 1; } finally { try { yield 2; } catch (Error $e) { echo $e->getMessage(), ","; } } }
+foreach (y() as $v) break;
+$end = g(); $end->current(); echo "end,";`, "cleanup,after,1,Cannot yield from finally in a force-closed generator,end,cleanup,cleanup,"},
+		// 变量中的生成器 unset 后不会立即销毁，finally 在请求结束时执行(PHP 输出 cleanup,after,)
+		{"generator release", `function g() { try { yield 1; } finally { echo "cleanup,"; } }
+$g = g(); $g->current(); unset($g); echo "after,";`, "after,cleanup,"},
+		{"generator by ref", `function &refs(array &$arr) { foreach ([0, 1] as $i) { yield $arr[$i]; } }
+$data = [1, 2]; foreach (refs($data) as &$v) { $v *= 10; } unset($v); echo implode(",", $data);
+function plain() { yield 1; }
+try { foreach (plain() as &$v) {} } catch (Exception $e) { echo ",", $e->getMessage(); }`, "10,20,You can only iterate a generator by-reference if it declared that it yields by-reference"},
+		{"from callable", `class B { function m($x) { return "m$x"; } static function s() { return "s"; } }
+$f = Closure::fromCallable("strtoupper"); $g = Closure::fromCallable([new B, "m"]);
+echo $f("a"), $g(1), Closure::fromCallable("B::s")(), get_class($f), ",";
+try { Closure::fromCallable("nope"); } catch (TypeError $e) { echo $e->getMessage(); }`, "Am1sClosure,Failed to create closure from callable: function 'nope' not found or invalid function name"},
+		{"fiber", `$f = new Fiber(function ($x) { $y = Fiber::suspend($x . "1"); try { Fiber::suspend($y); } catch (Exception $e) { echo "[", $e->getMessage(), "]"; } return Fiber::suspend(3) * 2; });
+echo $f->start("s"), $f->isSuspended() ? "S" : "-", $f->resume("r"), $f->throw(new Exception("t"));
+var_dump($f->resume(5)); echo $f->getReturn(), $f->isTerminated() ? "T" : "-";
+$outer = new Fiber(function () { $inner = new Fiber(function () { echo Fiber::getCurrent() ? "in," : ""; Fiber::suspend(); echo "inner,"; }); $inner->start(); Fiber::suspend($inner); echo "outer,"; });
+$outer->start()->resume(); $outer->resume(); echo Fiber::getCurrent() === null ? "main" : "";`, "s1Sr[t]3NULL\n10Tin,inner,outer,main"},
+		{"fiber destroy", `$f = new Fiber(function () { try { Fiber::suspend(); } finally { echo "fiber finally"; } });
+$f->start(); echo "end,";`, "end,fiber finally"},
+		{"fiber errors", `$f = new Fiber(function () { Fiber::suspend(); });
+foreach (["getReturn", "resume", "start", "start", "getReturn"] as $m) { try { $f->$m(); } catch (FiberError $e) { echo $e->getMessage(), ","; } }
+try { Fiber::suspend(); } catch (FiberError $e) { echo $e->getMessage(), ","; }
+$g = new Fiber(function () { throw new LogicException("x"); });
+try { $g->start(); } catch (LogicException $e) { echo $e->getTraceAsString(), ","; }
+try { $g->getReturn(); } catch (FiberError $e) { echo $e->getMessage(); }`, "Cannot get fiber return value: The fiber has not been started,Cannot resume a fiber that is not suspended,Cannot start a fiber that has already been started,Cannot get fiber return value: The fiber has not returned,Cannot suspend outside of fiber,#0 [internal function]: {closure}()\n#1 Command line code(5): Fiber->start()\n#2 {main},Cannot get fiber return value: The fiber threw an exception"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			cmd := Command("-d", "error_reporting=32767", "-r", tt.code)
+			cmd.Stdout = &buf
+			if err := cmd.Run(); err != nil {
+				t.Fatalf("Run() error = %v", err)
+			}
+			if got := buf.String(); got != tt.want {
+				t.Errorf("Run() output = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRunUncaught(t *testing.T) {
+	code := "function f($a, $s) {\n  throw new Exception(\"x\");\n}\ntry { f([1], 'a long string value'); } finally { echo 'fin'; }"
+	want := `fin
+Fatal error: Uncaught Exception: x in Command line code:2
+Stack trace:
+#0 Command line code(4): f(Array, 'a long string v...')
+#1 {main}
+  thrown in Command line code on line 2
+`
+	var buf bytes.Buffer
+	cmd := Command("-d", "error_reporting=32767", "-r", code)
+	cmd.Stdout = &buf
+	err := cmd.Run()
+	if codeErr, ok := err.(interface{ Code() int }); !ok || codeErr.Code() != 255 {
+		t.Errorf("Run() error = %v, want exit status 255", err)
+	}
+	if got := buf.String(); got != want {
+		t.Errorf("Run() output = %q, want %q", got, want)
+	}
+}
+
+// require 失败为致命错误，不能被 catch 捕获，也不执行 finally；include 失败只产生警告
+func TestRunRequireFailed(t *testing.T) {
+	code := "echo (include 'missing.php') === false ? 'false' : '', ',';\ntry { require 'missing.php'; } catch (Error $e) { echo 'caught'; } finally { echo 'fin'; }\necho 'after';"
+	want := `
+Warning: include(missing.php): Failed to open stream: No such file or directory in Command line code on line 1
+
+Warning: include(): Failed opening 'missing.php' for inclusion (include_path='.') in Command line code on line 1
+false,
+Warning: require(missing.php): Failed to open stream: No such file or directory in Command line code on line 2
+
+Fatal error: require(): Failed opening required 'missing.php' (include_path='.') in Command line code on line 2
+`
+	var buf bytes.Buffer
+	cmd := Command("-d", "error_reporting=32767", "-r", code)
+	cmd.Stdout = &buf
+	err := cmd.Run()
+	if codeErr, ok := err.(interface{ Code() int }); !ok || codeErr.Code() != 255 {
+		t.Errorf("Run() error = %v, want exit status 255", err)
+	}
+	if got := buf.String(); got != want {
+		t.Errorf("Run() output = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,102 @@
+package sapi
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/heyuuu/gophp/compile/optimizer"
+	"github.com/heyuuu/gophp/compile/transpile"
+	"io"
+	"os"
+	"strings"
+)
+
+const transpileUsage = `Usage: php transpile [options] <srcdir>
+
+  -o <dir>         Output directory of the generated Go package (default "<srcdir>_go")
+  -p <package>     Package name of the generated Go package (default: name of output directory)
+  -no-opt <passes> Disable optimisation passes, comma separated: const, fold, deadcode or all
+  -no-check        Do not type-check the generated Go code
+`
+
+// runTranspile 转译目录下的 PHP 文件为 go 包。单个文件失败不影响其他文件，存在失败时返回错误码 1
+func (c *Cmd) runTranspile(args []string) error {
+	stdout, stderr := c.Stdout, c.Stderr
+	if stdout == nil {
+		stdout = os.Stdout
+	}
+	if stderr == nil {
+		stderr = os.Stderr
+	}
+
+	flags := flag.NewFlagSet("transpile", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	outDir := flags.String("o", "", "")
+	pkg := flags.String("p", "", "")
+	noOpt := flags.String("no-opt", "", "")
+	noCheck := flags.Bool("no-check", false, "")
+	err := flags.Parse(args)
+	var opts optimizer.Options
+	if err == nil {
+		opts, err = parseNoOpt(*noOpt)
+	}
+	if err != nil || flags.NArg() != 1 {
+		if err != nil {
+			_, _ = fmt.Fprintln(stderr, err.Error())
+		}
+		_, _ = fmt.Fprint(stderr, transpileUsage)
+		return errRunFail
+	}
+
+	cfg := transpile.Config{
+		SrcDir:  flags.Arg(0),
+		OutDir:  *outDir,
+		Package: *pkg,
+
+		Optimize: opts,
+		NoCheck:  *noCheck,
+	}
+	if cfg.OutDir == "" {
+		cfg.OutDir = cfg.SrcDir + "_go"
+	}
+
+	result, err := transpile.Dir(cfg)
+	if err != nil {
+		_, _ = fmt.Fprintln(stderr, "Transpile failed: "+err.Error())
+		if errors.Is(err, transpile.ErrCheckUnavailable) {
+			_, _ = fmt.Fprintln(stderr, "Run inside a module that requires gophp, or use -no-check to skip type checking")
+		}
+		return errRunFail
+	}
+	for _, fileErr := range result.Errors {
+		_, _ = fmt.Fprintln(stderr, fileErr.Error())
+	}
+	_, _ = fmt.Fprintf(stdout, "Transpiled %d files to %s, %d failed\n", len(result.Files), cfg.OutDir, len(result.Errors))
+	if len(result.Errors) > 0 {
+		return errRunFail
+	}
+	return nil
+}
+
+// parseNoOpt 解析 -no-opt 参数，返回关闭对应优化的选项
+func parseNoOpt(s string) (optimizer.Options, error) {
+	var opts optimizer.Options
+	if s == "" {
+		return opts, nil
+	}
+	for _, name := range strings.Split(s, ",") {
+		switch strings.TrimSpace(name) {
+		case "const":
+			opts.NoConst = true
+		case "fold":
+			opts.NoFold = true
+		case "deadcode":
+			opts.NoDeadCode = true
+		case "all":
+			opts = optimizer.Options{NoConst: true, NoFold: true, NoDeadCode: true}
+		default:
+			return opts, fmt.Errorf("unknown optimisation pass: %s", name)
+		}
+	}
+	return opts, nil
+}